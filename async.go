@@ -0,0 +1,94 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"syscall/js"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// asyncSuffix names the Promise-returning variant of every export, e.g.
+// renderASCIIAsync.
+const asyncSuffix = "Async"
+
+// jobs runs the calls of the exports one at a time.
+var jobs = render.NewJobQueue()
+
+// syncExport wraps fn as a function that returns what it returns, right
+// away. fn that mutates runs as a job of jobs: the JS thread cannot wait for
+// a running job, which may be waiting for the event loop, so while one runs
+// fn is not called and the function returns a BUSY error response instead.
+// A panic, which would otherwise kill the Go runtime, is recovered and
+// returned as a RENDER_ERROR response.
+func syncExport(fn exportFunc, mutates bool) exportFunc {
+	return func(this js.Value, args []js.Value) (result any) {
+		defer func() {
+			if r := recover(); r != nil {
+				render.CountPanic()
+				render.Logger().Error("recovered panic", "panic", fmt.Sprint(r))
+				result = errorValue(args, render.NewRenderError(fmt.Sprintf("Internal error: %v", r)))
+			}
+		}()
+		if !mutates {
+			return fn(this, args)
+		}
+		if err := jobs.Run(func() { result = fn(this, args) }); err != nil {
+			return errorValue(args, err)
+		}
+		return result
+	}
+}
+
+// asyncExport wraps fn as a function that returns a Promise that resolves
+// with what fn returns. fn runs as a job of jobs, after the calls before it
+// have finished, so that calls that yield to the event loop do not overlap;
+// when too many calls are waiting, the Promise resolves with a QUEUE_FULL
+// error response instead. A panic, which would otherwise kill the Go runtime
+// and leave every export dead until the page is reloaded, is recovered and
+// rejects the Promise. The work still runs on the JS thread.
+func asyncExport(fn exportFunc) exportFunc {
+	return func(this js.Value, args []js.Value) any {
+		executor := js.FuncOf(func(_ js.Value, callbacks []js.Value) any {
+			resolve, reject := callbacks[0], callbacks[1]
+			_, err := jobs.Submit(func() {
+				defer func() {
+					if r := recover(); r != nil {
+						render.CountPanic()
+						render.Logger().Error("recovered panic", "panic", fmt.Sprint(r))
+						reject.Invoke(panicError(r, debug.Stack()))
+					}
+				}()
+				resolve.Invoke(fn(this, args))
+			})
+			if err != nil {
+				resolve.Invoke(errorValue(args, err))
+			}
+			return nil
+		})
+		defer executor.Release()
+		return js.Global().Get("Promise").New(executor)
+	}
+}
+
+// errorValue returns the error response for err in the form fn of
+// asyncExport and syncExport returns it: an object for calls with an object argument, such
+// as renderASCII with a plain object, and otherwise a JSON string.
+func errorValue(args []js.Value, err error) any {
+	if len(args) == 1 && args[0].Type() == js.TypeObject {
+		return responseValue(render.Respond(render.Response{}, err))
+	}
+	return marshalResponse(render.Respond(render.Response{}, err))
+}
+
+// panicError converts a recovered panic to a JS Error carrying the fields of
+// a RENDER_ERROR response, so that callers can handle it like any other
+// error response.
+func panicError(r any, stack []byte) js.Value {
+	err := js.Global().Get("Error").New(fmt.Sprintf("Internal error: %v", r))
+	err.Set("type", render.ErrorTypeRenderError)
+	err.Set("details", string(stack))
+	return err
+}
@@ -0,0 +1,101 @@
+// Command rendertree-server serves the rendering core over HTTP, for server
+// side report generation and bots that cannot run WASM. Requests and
+// responses have the same JSON schema as the WASM API, so that clients are
+// interchangeable:
+//
+//	POST /render        renderASCII parameters in, Response out
+//	GET  /capabilities  Response of getCapabilities
+//	GET  /version       Response of getVersionInfo
+//
+// Error responses are sent with a 4xx status for invalid requests and inputs
+// and 500 for render errors.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// buildTime is set by the build scripts with
+// -ldflags "-X main.buildTime=2025-01-02T03:04:05Z".
+var buildTime string
+
+func main() {
+	render.BuildTime = buildTime
+	addr := flag.String("addr", ":8080", "address to listen on")
+	maxBodyBytes := flag.Int64("max-body-bytes", 64<<20, "maximum size of a request body in bytes")
+	flag.Parse()
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           newHandler(*maxBodyBytes),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("rendertree-server listening on %s", *addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+}
+
+// newHandler returns the handler of the API, which reads request bodies of
+// up to maxBodyBytes.
+func newHandler(maxBodyBytes int64) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /render", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeResponse(w, http.StatusRequestEntityTooLarge, render.Respond(render.Response{}, render.NewInvalidParametersError(
+					fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))))
+				return
+			}
+			writeResponse(w, http.StatusBadRequest, render.Respond(render.Response{}, render.NewParseError(fmt.Sprintf("Failed to read request body: %v", err))))
+			return
+		}
+		resp := render.Invoke("renderASCII", string(body))
+		writeResponse(w, statusCode(resp), resp)
+	})
+	mux.HandleFunc("GET /capabilities", func(w http.ResponseWriter, r *http.Request) {
+		resp := render.Invoke("getCapabilities", "")
+		writeResponse(w, statusCode(resp), resp)
+	})
+	mux.HandleFunc("GET /version", func(w http.ResponseWriter, r *http.Request) {
+		resp := render.Invoke("getVersionInfo", "")
+		writeResponse(w, statusCode(resp), resp)
+	})
+	return mux
+}
+
+// statusCode returns the HTTP status of resp.
+func statusCode(resp render.Response) int {
+	if resp.Error == nil {
+		return http.StatusOK
+	}
+	switch resp.Error.Type {
+	case render.ErrorTypeInputTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case render.ErrorTypeParseError, render.ErrorTypeInvalidSpannerFormat, render.ErrorTypeInvalidParameters, render.ErrorTypeUnsupportedOption:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp render.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+func TestHandler(t *testing.T) {
+	input, err := os.ReadFile("../../render/samples/simple-scan.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderBody, err := json.Marshal(map[string]any{"input": string(input), "mode": "PROFILE", "format": "CURRENT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := render.Render(input, render.Options{Mode: "PROFILE", Format: "CURRENT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{"render", http.MethodPost, "/render", string(renderBody), http.StatusOK, ""},
+		{"invalid json", http.MethodPost, "/render", "{", http.StatusBadRequest, render.ErrorTypeParseError},
+		{"unparsable plan", http.MethodPost, "/render", `{"input":"{}","mode":"PLAN","format":"CURRENT"}`, http.StatusBadRequest, render.ErrorTypeParseError},
+		{"unknown format", http.MethodPost, "/render", `{"input":"{}","format":"NOPE"}`, http.StatusBadRequest, render.ErrorTypeInvalidParameters},
+		{"too large", http.MethodPost, "/render", `{"input":"` + strings.Repeat("x", len(renderBody)) + `"}`, http.StatusRequestEntityTooLarge, render.ErrorTypeInvalidParameters},
+		{"capabilities", http.MethodGet, "/capabilities", "", http.StatusOK, ""},
+		{"version", http.MethodGet, "/version", "", http.StatusOK, ""},
+		{"wrong method", http.MethodGet, "/render", "", http.StatusMethodNotAllowed, ""},
+	}
+	// The sample plan is the largest body allowed
+	handler := newHandler(int64(len(renderBody)))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			resp := checkResponse(t, rec, tt.wantStatus, tt.wantError)
+			if tt.name == "render" && resp.Result != want.Result {
+				t.Errorf("POST /render result differs from Render:\n%s", resp.Result)
+			}
+		})
+	}
+}
+
+// checkResponse checks the status and the error type of a JSON response and
+// returns it.
+func checkResponse(t *testing.T, rec *httptest.ResponseRecorder, wantStatus int, wantError string) render.Response {
+	t.Helper()
+	if rec.Code != wantStatus {
+		t.Fatalf("status = %d, want %d, body:\n%s", rec.Code, wantStatus, rec.Body.String())
+	}
+	if wantStatus == http.StatusMethodNotAllowed {
+		return render.Response{}
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var resp render.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v\n%s", err, rec.Body.String())
+	}
+	if resp.Success != (wantError == "") {
+		t.Errorf("success = %v, want %v: %+v", resp.Success, wantError == "", resp.Error)
+	}
+	if wantError != "" && (resp.Error == nil || resp.Error.Type != wantError) {
+		t.Errorf("error = %+v, want type %s", resp.Error, wantError)
+	}
+	return resp
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		errorType string
+		want      int
+	}{
+		{"", http.StatusOK},
+		{render.ErrorTypeInputTooLarge, http.StatusRequestEntityTooLarge},
+		{render.ErrorTypeParseError, http.StatusBadRequest},
+		{render.ErrorTypeInvalidSpannerFormat, http.StatusBadRequest},
+		{render.ErrorTypeInvalidParameters, http.StatusBadRequest},
+		{render.ErrorTypeUnsupportedOption, http.StatusBadRequest},
+		{render.ErrorTypeRenderError, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		resp := render.Response{}
+		if tt.errorType != "" {
+			resp.Error = &render.Error{Type: tt.errorType}
+		}
+		if got := statusCode(resp); got != tt.want {
+			t.Errorf("statusCode(%q) = %d, want %d", tt.errorType, got, tt.want)
+		}
+	}
+}
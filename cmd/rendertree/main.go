@@ -0,0 +1,234 @@
+// Command rendertree renders a Spanner query plan on the command line with the
+// same rendering core as the web UI, e.g. to attach rendered plans to pull
+// requests in CI. It reads the plan from the file given as its argument, or
+// from stdin, and writes the rendered plan to stdout:
+//
+//	rendertree --mode PROFILE --format CURRENT --wrap-width 120 profile.yaml
+//	gcloud spanner databases execute-sql ... --query-mode=PROFILE --format=yaml | rendertree
+//
+// Options without a flag are given as renderASCII parameters in JSON with
+// --options; flags take precedence over them.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// buildTime is set by the build scripts with
+// -ldflags "-X main.buildTime=2025-01-02T03:04:05Z".
+var buildTime string
+
+func main() {
+	render.BuildTime = buildTime
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with args and returns its exit status: 0 on success,
+// 1 when rendering failed, and 2 for invalid command lines.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts := render.Options{}
+	fs, cmd := newFlagSet(&opts, stderr)
+
+	// The flags are parsed once, over the options of --options, which is found
+	// first with a flag set whose flags set nothing
+	probe, probeCmd := newFlagSet(&render.Options{}, io.Discard)
+	if probe.Parse(args) == nil && *probeCmd.options != "" {
+		if err := decodeOptions(*probeCmd.options, &opts); err != nil {
+			fmt.Fprintf(stderr, "rendertree: %v\n", err)
+			return 2
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *cmd.version {
+		fmt.Fprintln(stdout, render.Invoke("getVersionInfo", "").Result)
+		return 0
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	input, err := readInput(fs.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "rendertree: %v\n", err)
+		return 2
+	}
+	return writeResponse(stdout, stderr, render.Respond(render.Render(input, opts)), *cmd.json)
+}
+
+// commandFlags are the flags that are not options.
+type commandFlags struct {
+	options *string
+	json    *bool
+	version *bool
+}
+
+// newFlagSet returns the flag set of the command, whose option flags set
+// opts, writing its usage to output.
+func newFlagSet(opts *render.Options, output io.Writer) (*flag.FlagSet, commandFlags) {
+	fs := flag.NewFlagSet("rendertree", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		fmt.Fprintln(output, "Usage: rendertree [flags] [file]\n\nRenders the query plan in file, or stdin, to stdout.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	cmd := commandFlags{
+		options: fs.String("options", "", "renderASCII parameters as `JSON`, or @file to read them from a file"),
+		json:    fs.Bool("json", false, "write the whole JSON response instead of the rendered plan"),
+		version: fs.Bool("version", false, "print the version info as JSON and exit"),
+	}
+	bindFlags(fs, opts)
+	return fs, cmd
+}
+
+// decodeOptions decodes the value of --options, JSON or @file, into opts.
+func decodeOptions(value string, opts *render.Options) error {
+	b := []byte(value)
+	if name, ok := strings.CutPrefix(value, "@"); ok {
+		var err error
+		if b, err = os.ReadFile(name); err != nil {
+			return err
+		}
+	}
+	if _, err := render.DecodeParams(b, opts); err != nil {
+		return fmt.Errorf("invalid --options: %v", err)
+	}
+	return nil
+}
+
+// bindFlags defines the flags that set opts.
+func bindFlags(fs *flag.FlagSet, opts *render.Options) {
+	fs.StringVar(&opts.Mode, "mode", "AUTO", "render mode: AUTO, PLAN, PROFILE, or TIMELINE")
+	fs.StringVar(&opts.Format, "format", "CURRENT", "output format, e.g. CURRENT, TRADITIONAL, COMPACT, TREE, CSV, JSONL, HTML, ANSI, SPANNER-CLI, or JSON-NORMALIZED")
+	fs.IntVar(&opts.WrapWidth, "wrap-width", 0, "wrap the operator column at this width; 0 does not wrap")
+	fs.StringVar(&opts.WrapMode, "wrap-mode", "", "how to wrap: char, word, or smart")
+	fs.BoolVar(&opts.HangingIndent, "hanging-indent", false, "indent wrapped lines of the operator column")
+	fs.IntVar(&opts.TargetWidth, "target-width", 0, "pick the wrap width that fits the table in this width")
+	fs.StringVar(&opts.InputEncoding, "input-encoding", "", "proto-base64 for base64-encoded ResultSetStats protobuf input")
+	fs.Func("plan-index", "render the plan at this index of an input with several plans", func(s string) error {
+		i, err := strconv.Atoi(s)
+		opts.PlanIndex = &i
+		return err
+	})
+	fs.BoolVar(&opts.Recover, "recover", false, "render broken plans as far as possible")
+	fs.BoolVar(&opts.Lenient, "lenient", false, "render operators that fail to render as placeholder rows; implies --recover")
+	fs.BoolVar(&opts.ConsoleNaming, "console-naming", false, "name operators like the Cloud Console")
+	fs.BoolVar(&opts.PrettyMetadataKeys, "pretty-metadata-keys", false, "write metadata keys in words")
+	fs.StringVar(&opts.ScalarRepresentation, "scalar-representation", "", "scalar representations: short, full, or footnote")
+	fs.BoolVar(&opts.ShowQueryText, "show-query-text", false, "prepend the query text")
+	fs.BoolVar(&opts.ShowOptimizerInfo, "show-optimizer-info", false, "prepend the optimizer version and statistics package")
+	fs.BoolVar(&opts.SubstituteParameters, "substitute-parameters", false, "substitute the query parameters in the query text")
+	fs.Func("columns", "comma-separated `titles` of the table columns to show, in order", func(s string) error {
+		opts.Columns = strings.Split(s, ",")
+		return nil
+	})
+	fs.Func("stats-fields", "comma-separated execution stat `keys` to add as columns, e.g. remote_calls", func(s string) error {
+		opts.StatsFields = strings.Split(s, ",")
+		return nil
+	})
+	fs.BoolVar(&opts.ExperimentalStats, "experimental-stats", false, "add a column for every other numeric execution stat")
+	fs.BoolVar(&opts.Deterministic, "deterministic", false, "guarantee output that depends only on the input and the options, for snapshot tests")
+	fs.StringVar(&opts.SortBy, "sort-by", "", "sort the rows by latency, rows, or id")
+	fs.StringVar(&opts.SortChildrenBy, "sort-children-by", "", "sort the children of each operator by latency, rows, cpu, or none")
+	fs.StringVar(&opts.OperatorFilter, "operator-filter", "", "show only scans-only, joins-only, distributed-only, or compute-only operators")
+	fs.StringVar(&opts.Filter, "filter", "", "show only the operators matching this filter expression")
+	fs.StringVar(&opts.ChildLinks, "child-links", "", "scalar subqueries to show: all, hideScalar, or relational")
+	fs.BoolVar(&opts.LinkLabels, "link-labels", false, "label the relational child links with their type and variable")
+	fs.Func("collapse", "comma-separated `IDs` of operators to render as one row summarizing their subtree", func(s string) error {
+		for _, f := range strings.Split(s, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(f), 10, 32)
+			if err != nil {
+				return err
+			}
+			opts.CollapsedNodeIDs = append(opts.CollapsedNodeIDs, int32(id))
+		}
+		return nil
+	})
+	fs.Func("root-node-id", "render only the subtree of this operator", func(s string) error {
+		id, err := strconv.ParseInt(s, 10, 32)
+		opts.RootNodeID = int32(id)
+		return err
+	})
+	fs.BoolVar(&opts.RootBreadcrumb, "root-breadcrumb", false, "prepend the path from the plan root to the --root-node-id operator")
+	fs.StringVar(&opts.LatencyBudget, "latency-budget", "", "split this target latency, e.g. 50ms, across the operators")
+	fs.BoolVar(&opts.EstimateColumn, "estimate-column", false, "add the Est/Actual column")
+	fs.BoolVar(&opts.LatencyBars, "latency-bars", false, "add the latency bar column")
+	fs.BoolVar(&opts.LatencyDistribution, "latency-distribution", false, "add p50 and p99, or mean ± stddev, to the latency column")
+	fs.BoolVar(&opts.OperatorGlossary, "operator-glossary", false, "append an explanation of each kind of operator under the table")
+	fs.Func("totals", "append a row with the `sum` or max of the leaf operator stats", func(s string) error {
+		if opts.Totals == nil {
+			opts.Totals = &render.TotalsOptions{}
+		}
+		opts.Totals.Aggregate = s
+		return nil
+	})
+	fs.BoolFunc("totals-rollups", "annotate branch operators with the totals of their subtrees; implies --totals", func(string) error {
+		if opts.Totals == nil {
+			opts.Totals = &render.TotalsOptions{}
+		}
+		opts.Totals.Rollups = true
+		return nil
+	})
+	fs.BoolVar(&opts.Lint, "lint", false, "append the lint findings")
+	fs.StringVar(&opts.Charset, "charset", "", "characters of the tree and bars: unicode or ascii")
+	fs.StringVar(&opts.BorderStyle, "border-style", "", "borders of the table: heavy, light, double, minimal, or none")
+	fs.StringVar(&opts.ColorTheme, "color-theme", "", "colors of the ANSI format: dark or light")
+	fs.BoolVar(&opts.NoColor, "no-color", false, "render the ANSI format without colors")
+	fs.BoolVar(&opts.TreeOneLine, "tree-one-line", false, "omit the predicates in the TREE format")
+	fs.StringVar(&opts.DecimalSeparator, "decimal-separator", "", "decimal separator of execution stats spelled with digit grouping: . or ,")
+	fs.StringVar(&opts.StatDurationUnit, "stat-duration-unit", "", "convert the duration execution stats to s, ms, or µs")
+	fs.BoolVar(&opts.VariableLinks, "variable-links", false, "list the variables each operator refers to with the node that defines them")
+	fs.BoolVar(&opts.PrettyPredicates, "pretty-predicates", false, "break predicates at their AND and OR operators, one condition per line")
+}
+
+// readInput reads the file name, or stdin for "" and "-".
+func readInput(name string, stdin io.Reader) ([]byte, error) {
+	if name == "" || name == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// writeResponse writes resp, either as JSON or as its rendered result with
+// the warnings and error on stderr, and returns the exit status.
+func writeResponse(stdout, stderr io.Writer, resp render.Response, asJSON bool) int {
+	status := 0
+	if !resp.Success {
+		status = 1
+	}
+	if asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(resp); err != nil {
+			fmt.Fprintf(stderr, "rendertree: %v\n", err)
+			return 1
+		}
+		return status
+	}
+
+	for _, w := range resp.Warnings {
+		fmt.Fprintf(stderr, "warning: %s: %s\n", w.Code, w.Message)
+	}
+	if resp.Error != nil {
+		fmt.Fprintf(stderr, "rendertree: %s: %s\n", resp.Error.Type, resp.Error.Message)
+		for _, issue := range resp.Error.Issues {
+			fmt.Fprintf(stderr, "  %s: %s\n", issue.Type, issue.Message)
+		}
+		return status
+	}
+	if _, err := io.WriteString(stdout, resp.Result); err != nil {
+		fmt.Fprintf(stderr, "rendertree: %v\n", err)
+		return 1
+	}
+	return status
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+// sampleInput is a PROFILE capture shipped with the web UI.
+const sampleInput = "../../render/samples/simple-scan.yaml"
+
+func TestRunGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"current", []string{"--mode", "PROFILE", sampleInput}},
+		{"compact-wrapped", []string{"--format", "COMPACT", "--wrap-width", "30", sampleInput}},
+		{"options-under-flags", []string{"--options", `{"mode":"PLAN","format":"TRADITIONAL"}`, "--format", "CURRENT", sampleInput}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if status := run(tt.args, nil, &stdout, &stderr); status != 0 {
+				t.Fatalf("run(%q) = %d, stderr:\n%s", tt.args, status, stderr.String())
+			}
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, stdout.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stdout.String(); got != string(want) {
+				t.Errorf("run(%q) output differs from %s:\n%s", tt.args, golden, got)
+			}
+		})
+	}
+}
+
+func TestRunStdin(t *testing.T) {
+	input, err := os.ReadFile(sampleInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromFile, fromStdin, stderr bytes.Buffer
+	run([]string{sampleInput}, nil, &fromFile, &stderr)
+	if status := run([]string{"-"}, bytes.NewReader(input), &fromStdin, &stderr); status != 0 {
+		t.Fatalf("run(-) = %d, stderr:\n%s", status, stderr.String())
+	}
+	if fromStdin.String() != fromFile.String() {
+		t.Errorf("stdin output differs from file output:\n%s", fromStdin.String())
+	}
+}
+
+func TestRunWarnings(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "unknown option",
+			args: []string{"--options", `{"wrapwidth":40}`, sampleInput},
+			want: "warning: UNKNOWN_OPTION: Unknown option \"wrapwidth\" is read as \"wrapWidth\"; spell it with that case\n",
+		},
+		{
+			// --options used to parse the flags twice, collapsing 99 twice
+			name: "collapse with options",
+			args: []string{"--options", `{"wrapWidth":40}`, "--collapse", "99", sampleInput},
+			want: "warning: UNKNOWN_COLLAPSED_NODE: No operator 99 to collapse\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if status := run(tt.args, nil, &stdout, &stderr); status != 0 {
+				t.Fatalf("run(%q) = %d, stderr:\n%s", tt.args, status, stderr.String())
+			}
+			if got := stderr.String(); got != tt.want {
+				t.Errorf("run(%q) stderr = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+		wantStderr string
+	}{
+		{"unknown flag", []string{"--no-such-flag"}, 2, "flag provided but not defined: -no-such-flag"},
+		{"invalid options", []string{"--options", "{", sampleInput}, 2, "rendertree: invalid --options: Failed to parse parameters"},
+		{"missing file", []string{"testdata/missing.yaml"}, 2, "rendertree: open testdata/missing.yaml"},
+		{"two files", []string{sampleInput, sampleInput}, 2, "Usage: rendertree"},
+		{"unknown format", []string{"--format", "NOPE", sampleInput}, 1, "rendertree: INVALID_PARAMETERS:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if status := run(tt.args, nil, &stdout, &stderr); status != tt.wantStatus {
+				t.Errorf("run(%q) = %d, want %d", tt.args, status, tt.wantStatus)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("run(%q) stderr = %q, want it to contain %q", tt.args, stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// registerFormatter registers a JS callback as the renderer for a custom
+// renderASCII format name. The callback receives the row model and returns
+// the rendered text; passing null or undefined unregisters the name.
+func registerFormatter(_ js.Value, args []js.Value) any {
+	if len(args) != 2 {
+		return errorResponse(render.ErrorTypeInvalidParameters,
+			"Invalid number of arguments",
+			fmt.Sprintf("Expected 2 arguments, got %d", len(args)))
+	}
+	if err := registerFormatterImpl(args[0], args[1]); err != nil {
+		return marshalResponse(render.ErrorResponse(err))
+	}
+	return marshalResponse(render.Respond(render.Response{}, nil))
+}
+
+func registerFormatterImpl(nameValue, callback js.Value) error {
+	name := ""
+	if nameValue.Type() == js.TypeString {
+		name = nameValue.String()
+	}
+	switch callback.Type() {
+	case js.TypeNull, js.TypeUndefined:
+		return render.RegisterFormatter(name, nil)
+	case js.TypeFunction:
+		return render.RegisterFormatter(name, jsFormatter(name, callback))
+	default:
+		return render.NewInvalidParametersError(fmt.Sprintf("Formatter callback must be a function, got %s", callback.Type()))
+	}
+}
+
+// jsFormatter calls callback with the row model converted to a JS object.
+// Exceptions are recovered by render; non-string results are render errors.
+func jsFormatter(name string, callback js.Value) render.Formatter {
+	return func(modelJSON []byte) (string, error) {
+		out := callback.Invoke(js.Global().Get("JSON").Call("parse", string(modelJSON)))
+		if out.Type() != js.TypeString {
+			return "", render.NewRenderError(fmt.Sprintf("Formatter %s returned %s, expected string", name, out.Type()))
+		}
+		return out.String(), nil
+	}
+}
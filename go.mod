@@ -3,25 +3,26 @@ module github.com/apstndb/rendertree-web
 go 1.25.0
 
 require (
+	cloud.google.com/go/spanner v1.48.0
 	github.com/apstndb/spannerplan v0.3.0
 	github.com/apstndb/spannerplanviz v0.11.0
+	github.com/goccy/go-yaml v1.17.1
+	github.com/olekukonko/tablewriter v1.0.9
+	github.com/rivo/uniseg v0.2.0
+	google.golang.org/protobuf v1.36.10
 )
 
 require (
-	cloud.google.com/go/spanner v1.48.0 // indirect
 	github.com/apstndb/go-tabwrap v0.1.3 // indirect
 	github.com/apstndb/protoyaml v0.1.1 // indirect
 	github.com/clipperhouse/displaywidth v0.11.0 // indirect
 	github.com/clipperhouse/uax29/v2 v2.7.0 // indirect
 	github.com/fatih/color v1.15.0 // indirect
-	github.com/goccy/go-yaml v1.17.1 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/olekukonko/errors v1.1.0 // indirect
 	github.com/olekukonko/ll v0.0.9 // indirect
-	github.com/olekukonko/tablewriter v1.0.9 // indirect
-	github.com/rivo/uniseg v0.2.0 // indirect
 	github.com/samber/lo v1.53.0 // indirect
 	golang.org/x/net v0.55.0 // indirect
 	golang.org/x/sys v0.45.0 // indirect
@@ -29,5 +30,4 @@ require (
 	google.golang.org/genproto/googleapis/api v0.0.0-20251202230838-ff82c1b0f217 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20251202230838-ff82c1b0f217 // indirect
 	google.golang.org/grpc v1.79.3 // indirect
-	google.golang.org/protobuf v1.36.10 // indirect
 )
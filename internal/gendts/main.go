@@ -0,0 +1,302 @@
+// Command gendts generates TypeScript definitions of the JSON shapes of the
+// render package: the renderASCII parameters, Response, Error, the option
+// structs they contain, and the error type constants. It is run by go
+// generate in render:
+//
+//	go generate ./render
+//
+// Types are read with reflection, so that the definitions follow the JSON
+// encoding exactly, and their doc comments and the ErrorType constants from
+// the source in -dir.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+func main() {
+	out := flag.String("o", "", "output `file`; stdout by default")
+	dir := flag.String("dir", ".", "`directory` of the render package source, for doc comments and constants")
+	flag.Parse()
+
+	src, err := parseSource(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	b := generate(src)
+	if *out == "" {
+		os.Stdout.Write(b)
+		return
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// source is what is read from the render package source.
+type source struct {
+	// typeDocs and fieldDocs are the doc comments of types and of their
+	// fields by Go name
+	typeDocs  map[string]string
+	fieldDocs map[string]map[string]string
+	// errorTypes are the ErrorType constants in declaration order
+	errorTypes []constant
+}
+
+type constant struct {
+	name, value, doc string
+}
+
+func parseSource(dir string) (*source, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	src := &source{typeDocs: make(map[string]string), fieldDocs: make(map[string]map[string]string)}
+	fset := token.NewFileSet()
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					src.addType(gd, spec)
+				case *ast.ValueSpec:
+					if gd.Tok == token.CONST {
+						src.addConstant(spec)
+					}
+				}
+			}
+		}
+	}
+	return src, nil
+}
+
+func (src *source) addType(gd *ast.GenDecl, spec *ast.TypeSpec) {
+	doc := spec.Doc
+	if doc == nil && len(gd.Specs) == 1 {
+		doc = gd.Doc
+	}
+	src.typeDocs[spec.Name.Name] = doc.Text()
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return
+	}
+	docs := make(map[string]string)
+	for _, field := range st.Fields.List {
+		text := field.Doc.Text()
+		if text == "" {
+			text = field.Comment.Text()
+		}
+		for _, name := range field.Names {
+			docs[name.Name] = text
+		}
+	}
+	src.fieldDocs[spec.Name.Name] = docs
+}
+
+func (src *source) addConstant(spec *ast.ValueSpec) {
+	for i, name := range spec.Names {
+		if !strings.HasPrefix(name.Name, "ErrorType") || i >= len(spec.Values) {
+			continue
+		}
+		lit, ok := spec.Values[i].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			continue
+		}
+		value, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			continue
+		}
+		text := spec.Doc.Text()
+		if text == "" {
+			text = spec.Comment.Text()
+		}
+		src.errorTypes = append(src.errorTypes, constant{name: name.Name, value: value, doc: text})
+	}
+}
+
+// generator writes the definitions of struct types as they are reached.
+type generator struct {
+	src *source
+	buf bytes.Buffer
+	// done are the struct types already written or queued
+	done map[reflect.Type]bool
+	// queue are the struct types to write, with whether they are only read
+	// from JSON, which makes all their fields optional
+	queue []queued
+}
+
+type queued struct {
+	t     reflect.Type
+	input bool
+}
+
+func generate(src *source) []byte {
+	g := &generator{src: src, done: make(map[reflect.Type]bool)}
+	g.buf.WriteString("// Code generated by gendts from the render package; DO NOT EDIT.\n")
+
+	g.writeErrorTypes()
+	g.buf.WriteString(`
+/** Parameters of renderASCII: the plan to render and the Options */
+export interface RenderParams extends Options {
+  input: string;
+}
+`)
+	g.enqueue(reflect.TypeFor[render.Options](), true)
+	g.enqueue(reflect.TypeFor[render.Response](), false)
+	for len(g.queue) > 0 {
+		q := g.queue[0]
+		g.queue = g.queue[1:]
+		g.writeStruct(q.t, q.input)
+	}
+	return g.buf.Bytes()
+}
+
+func (g *generator) writeErrorTypes() {
+	var union []string
+	for _, c := range g.src.errorTypes {
+		g.buf.WriteString("\n")
+		writeDoc(&g.buf, "", c.doc)
+		fmt.Fprintf(&g.buf, "export type %s = %q;\n", c.name, c.value)
+		union = append(union, c.name)
+	}
+	g.buf.WriteString("\n/** Type of Error and Issue */\n")
+	fmt.Fprintf(&g.buf, "export type ErrorType =\n  | %s;\n", strings.Join(union, "\n  | "))
+}
+
+func (g *generator) enqueue(t reflect.Type, input bool) {
+	if g.done[t] {
+		return
+	}
+	g.done[t] = true
+	g.queue = append(g.queue, queued{t, input})
+}
+
+func (g *generator) writeStruct(t reflect.Type, input bool) {
+	var extends []string
+	var fields bytes.Buffer
+	docs := g.src.fieldDocs[t.Name()]
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.enqueue(f.Type, input)
+			extends = append(extends, f.Type.Name())
+			continue
+		}
+		if f.Type.Kind() == reflect.Func || f.Type.Kind() == reflect.Chan {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		optional := input || strings.Contains(","+opts+",", ",omitempty,")
+		typ := g.tsType(f.Type, input)
+		if f.Type.Kind() == reflect.Pointer && !optional {
+			typ += " | null"
+		}
+		writeDoc(&fields, "  ", docs[f.Name])
+		if optional {
+			fmt.Fprintf(&fields, "  %s?: %s;\n", name, typ)
+		} else {
+			fmt.Fprintf(&fields, "  %s: %s;\n", name, typ)
+		}
+	}
+
+	g.buf.WriteString("\n")
+	writeDoc(&g.buf, "", g.src.typeDocs[t.Name()])
+	fmt.Fprintf(&g.buf, "export interface %s", t.Name())
+	if len(extends) > 0 {
+		fmt.Fprintf(&g.buf, " extends %s", strings.Join(extends, ", "))
+	}
+	g.buf.WriteString(" {\n")
+	g.buf.Write(fields.Bytes())
+	g.buf.WriteString("}\n")
+}
+
+// tsType returns the TypeScript type of the JSON encoding of t, queueing the
+// struct types it refers to.
+func (g *generator) tsType(t reflect.Type, input bool) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.tsType(t.Elem(), input)
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// base64
+			return "string"
+		}
+		elem := g.tsType(t.Elem(), input)
+		if strings.ContainsAny(elem, " |") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case reflect.Map:
+		key := "string"
+		if t.Key().Kind() != reflect.String {
+			key = "number"
+		}
+		return fmt.Sprintf("Record<%s, %s>", key, g.tsType(t.Elem(), input))
+	case reflect.Struct:
+		if t.Name() == "" {
+			return "unknown"
+		}
+		g.enqueue(t, input)
+		return t.Name()
+	default:
+		return "unknown"
+	}
+}
+
+// writeDoc writes the Go doc comment doc as a JSDoc comment.
+func writeDoc(buf *bytes.Buffer, indent, doc string) {
+	doc = strings.TrimSpace(doc)
+	if doc == "" {
+		return
+	}
+	lines := strings.Split(strings.ReplaceAll(doc, "*/", "*\\/"), "\n")
+	if len(lines) == 1 {
+		fmt.Fprintf(buf, "%s/** %s */\n", indent, lines[0])
+		return
+	}
+	fmt.Fprintf(buf, "%s/**\n", indent)
+	for _, line := range lines {
+		fmt.Fprintf(buf, "%s *%s\n", indent, strings.TrimRight(" "+line, " "))
+	}
+	fmt.Fprintf(buf, "%s */\n", indent)
+}
@@ -0,0 +1,130 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"syscall/js"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// renderJobPrefix starts every render job ID, e.g. "render-1".
+const renderJobPrefix = "render-"
+
+// renderJobs holds the cancel functions of the renders started with
+// renderAsync that have not finished, keyed by job ID.
+type renderJobs struct {
+	mu      sync.Mutex
+	nextID  int
+	cancels map[string]context.CancelFunc
+}
+
+var activeJobs = &renderJobs{nextID: 1, cancels: make(map[string]context.CancelFunc)}
+
+type cancelRenderParams struct {
+	JobID string `json:"jobId"`
+}
+
+// add stores cancel and returns the ID of the new job.
+func (j *renderJobs) add(cancel context.CancelFunc) string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id := renderJobPrefix + strconv.Itoa(j.nextID)
+	j.nextID++
+	j.cancels[id] = cancel
+	return id
+}
+
+// finish forgets the job id, reporting whether it was still running.
+func (j *renderJobs) finish(id string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cancel, ok := j.cancels[id]
+	if ok {
+		cancel()
+		delete(j.cancels, id)
+	}
+	return ok
+}
+
+// cancelAll cancels every running job.
+func (j *renderJobs) cancelAll() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for id, cancel := range j.cancels {
+		cancel()
+		delete(j.cancels, id)
+	}
+}
+
+// yieldToEventLoop waits for a setTimeout, so that the page can paint and
+// handle input. It must not be called from a js.FuncOf callback directly,
+// only from a goroutine such as those of asyncExport.
+func yieldToEventLoop() {
+	done := make(chan struct{})
+	callback := js.FuncOf(func(js.Value, []js.Value) any {
+		close(done)
+		return nil
+	})
+	defer callback.Release()
+	js.Global().Call("setTimeout", callback, 0)
+	<-done
+}
+
+// renderAsync starts renderASCII with JSON parameters as a job that can be
+// stopped with cancelRender. It returns an object with the jobId and a
+// response Promise of the JSON response. The job yields to the event loop
+// between the stages of the render, after parsing, after validation, and
+// before and after rendering the table, and stops at the first of them after
+// cancellation with a CANCELLED error. A stage in progress is not
+// interrupted.
+func renderAsync(_ js.Value, args []js.Value) any {
+	ctx, cancel := context.WithCancel(context.Background())
+	id := activeJobs.add(cancel)
+	checkpoint := func() error {
+		yieldToEventLoop()
+		if ctx.Err() != nil {
+			return render.NewCancelledError(fmt.Sprintf("Render job %s was cancelled", id))
+		}
+		return nil
+	}
+	run := func(js.Value, []js.Value) any {
+		defer activeJobs.finish(id)
+		return invokeWasm(args, func(paramsJSON string) (render.Response, error) {
+			opts := render.Options{}
+			input, err := render.DecodeParams([]byte(paramsJSON), &opts)
+			if err != nil {
+				return render.Response{}, err
+			}
+			// Jobs cancelled before they start do not parse the input
+			if err := checkpoint(); err != nil {
+				return render.Response{}, err
+			}
+			opts.Checkpoint = checkpoint
+			return render.Render([]byte(input), opts)
+		})
+	}
+	return js.ValueOf(map[string]any{
+		"jobId":    id,
+		"response": asyncExport(run)(js.Undefined(), nil),
+	})
+}
+
+// cancelRender cancels a render job started with renderAsync
+func cancelRender(_ js.Value, args []js.Value) any {
+	return invokeWasm(args, func(paramsJSON string) (render.Response, error) {
+		par := cancelRenderParams{}
+		if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+			return render.Response{}, render.NewParseError(fmt.Sprintf("Failed to parse parameters: %v", err))
+		}
+		if !activeJobs.finish(par.JobID) {
+			return render.Response{}, render.NewInvalidParametersError(fmt.Sprintf("Unknown render job: %q (it finished or was cancelled)", par.JobID))
+		}
+		return render.Response{}, nil
+	})
+}
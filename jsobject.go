@@ -0,0 +1,80 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// renderInputFromJS reads the input of renderASCII parameters from a plain JS
+// object. The input, which can be megabytes of PROFILE output, is read
+// directly, either from a string or from a Uint8Array of its bytes.
+func renderInputFromJS(v js.Value) ([]byte, error) {
+	switch input := v.Get("input"); input.Type() {
+	case js.TypeString:
+		return []byte(input.String()), nil
+	case js.TypeObject:
+		if !input.InstanceOf(js.Global().Get("Uint8Array")) {
+			return nil, render.NewInvalidParametersError("input must be a string or a Uint8Array")
+		}
+		return bytesFromJS(input), nil
+	case js.TypeUndefined, js.TypeNull:
+		return nil, nil
+	default:
+		return nil, render.NewInvalidParametersError(fmt.Sprintf("input must be a string or a Uint8Array, got %s", input.Type()))
+	}
+}
+
+// optionsFromJS reads the renderASCII options besides the input from a plain
+// JS object. They are small and go through JSON so that they are decoded
+// exactly like the string form.
+func optionsFromJS(v js.Value) (render.Options, error) {
+	object := js.Global().Get("Object")
+	options := object.Call("assign", object.New(), v, map[string]any{"input": js.Undefined()})
+
+	opts := render.Options{}
+	if _, err := render.DecodeParams([]byte(js.Global().Get("JSON").Call("stringify", options).String()), &opts); err != nil {
+		return render.Options{}, err
+	}
+	return opts, nil
+}
+
+// bytesFromJS copies a Uint8Array into Go.
+func bytesFromJS(v js.Value) []byte {
+	b := make([]byte, v.Length())
+	js.CopyBytesToGo(b, v)
+	return b
+}
+
+// responseValue converts resp to a JS object with the same shape as its JSON
+// form. The result is set directly so that large renderings are not escaped
+// into and parsed out of JSON.
+func responseValue(resp render.Response) js.Value {
+	result := resp.Result
+	resp.Result = ""
+	b, _ := json.Marshal(resp)
+	v := js.Global().Get("JSON").Call("parse", string(b))
+	if result != "" {
+		v.Set("result", result)
+	}
+	return v
+}
+
+// invokeWasmObject is invokeWasm for renderASCII called with a JS object,
+// which gets a JS object back.
+func invokeWasmObject(arg js.Value) js.Value {
+	opts, err := optionsFromJS(arg)
+	var input []byte
+	if err == nil {
+		input, err = renderInputFromJS(arg)
+	}
+	var resp render.Response
+	if err == nil {
+		resp, err = render.Render(input, opts)
+	}
+	return responseValue(render.Respond(resp, err))
+}
@@ -0,0 +1,73 @@
+//go:build js && wasm && !nolint
+
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+func init() {
+	registerJSExports(mutatingExport, map[string]exportFunc{
+		"registerLintRule": registerLintRule,
+	})
+}
+
+// registerLintRule registers a JS callback as a custom lint rule. With the
+// "node" scope (the default) the callback is called with each PlanRow; with
+// the "tree" scope it is called once with the whole row model. It returns an
+// array of {message, nodeId?} findings, or null. Passing a null callback
+// unregisters the rule.
+func registerLintRule(_ js.Value, args []js.Value) any {
+	if len(args) < 2 || len(args) > 3 {
+		return errorResponse(render.ErrorTypeInvalidParameters,
+			"Invalid number of arguments",
+			fmt.Sprintf("Expected 2 or 3 arguments, got %d", len(args)))
+	}
+	scope := js.Undefined()
+	if len(args) == 3 {
+		scope = args[2]
+	}
+	if err := registerLintRuleImpl(args[0], args[1], scope); err != nil {
+		return marshalResponse(render.ErrorResponse(err))
+	}
+	return marshalResponse(render.Respond(render.Response{}, nil))
+}
+
+func registerLintRuleImpl(nameValue, callback, scopeValue js.Value) error {
+	name := ""
+	if nameValue.Type() == js.TypeString {
+		name = nameValue.String()
+	}
+	scope := ""
+	switch scopeValue.Type() {
+	case js.TypeUndefined, js.TypeNull:
+	case js.TypeString:
+		scope = scopeValue.String()
+	default:
+		return render.NewInvalidParametersError(fmt.Sprintf("Lint rule scope must be a string, got %s", scopeValue.Type()))
+	}
+
+	switch callback.Type() {
+	case js.TypeNull, js.TypeUndefined:
+		return render.RegisterLintRule(name, scope, nil)
+	case js.TypeFunction:
+		return render.RegisterLintRule(name, scope, jsLintRule(callback))
+	default:
+		return render.NewInvalidParametersError(fmt.Sprintf("Lint rule callback must be a function, got %s", callback.Type()))
+	}
+}
+
+// jsLintRule calls callback with the rule input converted to a JS object.
+// Exceptions are recovered by render.
+func jsLintRule(callback js.Value) render.LintRule {
+	return func(inputJSON []byte) ([]byte, error) {
+		out := callback.Invoke(js.Global().Get("JSON").Call("parse", string(inputJSON)))
+		if out.IsNull() || out.IsUndefined() {
+			return []byte("null"), nil
+		}
+		return []byte(js.Global().Get("JSON").Call("stringify", out).String()), nil
+	}
+}
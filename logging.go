@@ -0,0 +1,39 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+func init() {
+	registerJSExports(mutatingExport, map[string]exportFunc{
+		"setLogHandler": setLogHandler,
+	})
+}
+
+// setLogHandler sets a JS callback that receives each log record of the
+// renderer as a LogRecord object, at the levels selected with logLevel;
+// passing null or undefined removes it.
+func setLogHandler(_ js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return errorResponse(render.ErrorTypeInvalidParameters,
+			"Invalid number of arguments",
+			fmt.Sprintf("Expected 1 argument, got %d", len(args)))
+	}
+	switch callback := args[0]; callback.Type() {
+	case js.TypeNull, js.TypeUndefined:
+		render.SetLogHandler(nil)
+	case js.TypeFunction:
+		render.SetLogHandler(func(recordJSON []byte) {
+			callback.Invoke(js.Global().Get("JSON").Call("parse", string(recordJSON)))
+		})
+	default:
+		err := render.NewInvalidParametersError(fmt.Sprintf("Log handler must be a function, got %s", callback.Type()))
+		return marshalResponse(render.ErrorResponse(err))
+	}
+	return marshalResponse(render.Respond(render.Response{}, nil))
+}
@@ -1,110 +1,26 @@
 //go:build js && wasm
 
+// Command rendertree-web is the WASM build of the renderer. It sets the
+// exports of the render package on globalThis, taking and returning JSON
+// strings, together with the exports that take JS callbacks.
 package main
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"syscall/js"
 
-	queryplan "github.com/apstndb/spannerplan"
-	"github.com/apstndb/spannerplan/plantree/reference"
-	"github.com/apstndb/spannerplanviz/d2"
-	"github.com/apstndb/spannerplanviz/dot"
-	"github.com/apstndb/spannerplanviz/mermaid"
-	"github.com/apstndb/spannerplanviz/visualize"
+	"github.com/apstndb/rendertree-web/render"
 )
 
-type params struct {
-	Input                      string                   `json:"input"`
-	Mode                       string                   `json:"mode"`
-	Format                     string                   `json:"format"`
-	WrapWidth                  int                      `json:"wrapWidth"`
-	HangingIndent              bool                     `json:"hangingIndent"`
-	PrintSections              *reference.PrintSections `json:"printSections,omitempty"`
-	ShowScalarVars             bool                     `json:"showScalarVars,omitempty"`
-	ResolveScalarVars          bool                     `json:"resolveScalarVars,omitempty"`
-	ResolveScalarVarsRecursive bool                     `json:"resolveScalarVarsRecursive,omitempty"`
-}
-
-type planVizParams struct {
-	Input             string `json:"input"`
-	Full              bool   `json:"full"`
-	Metadata          bool   `json:"metadata,omitempty"`
-	ExecutionStats    bool   `json:"executionStats,omitempty"`
-	ExecutionSummary  bool   `json:"executionSummary,omitempty"`
-	SerializeResult   bool   `json:"serializeResult,omitempty"`
-	HideScanTarget    bool   `json:"hideScanTarget,omitempty"`
-	NonVariableScalar bool   `json:"nonVariableScalar,omitempty"`
-	VariableScalar    bool   `json:"variableScalar,omitempty"`
-}
-
-// Response represents the structured response from WASM
-type Response struct {
-	Success bool   `json:"success"`
-	Result  string `json:"result,omitempty"`
-	Error   *Error `json:"error,omitempty"`
-}
-
-// Error represents detailed error information
-type Error struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
-}
-
-// Error types for better error handling
-const (
-	ErrorTypeParseError           = "PARSE_ERROR"
-	ErrorTypeInvalidSpannerFormat = "INVALID_SPANNER_FORMAT"
-	ErrorTypeRenderError          = "RENDER_ERROR"
-	ErrorTypeInvalidParameters    = "INVALID_PARAMETERS"
-)
-
-// Custom error types for better classification
-// These correspond to WasmErrorType constants in TypeScript
-
-// ParseError represents JSON/YAML parsing failures
-type ParseError struct {
-	msg string
-}
-
-func (e ParseError) Error() string {
-	return e.msg
-}
-
-// InvalidSpannerFormatError represents invalid Spanner query plan format or structure
-type InvalidSpannerFormatError struct {
-	msg string
-}
-
-func (e InvalidSpannerFormatError) Error() string {
-	return e.msg
-}
-
-// RenderError represents general rendering failures
-type RenderError struct {
-	msg string
-}
-
-func (e RenderError) Error() string {
-	return e.msg
-}
-
-// InvalidParametersError represents invalid function parameters
-type InvalidParametersError struct {
-	msg string
-}
-
-func (e InvalidParametersError) Error() string {
-	return e.msg
-}
+// buildTime is set by the build scripts with
+// -ldflags "-X main.buildTime=2025-01-02T03:04:05Z".
+var buildTime string
 
 func errorResponse(errorType, message, details string) string {
-	resp := Response{
+	resp := render.Response{
 		Success: false,
-		Error: &Error{
+		Error: &render.Error{
 			Type:    errorType,
 			Message: message,
 			Details: details,
@@ -114,235 +30,80 @@ func errorResponse(errorType, message, details string) string {
 	return string(jsonBytes)
 }
 
-func successResponse(result string) string {
-	resp := Response{
-		Success: true,
-		Result:  result,
-	}
+// marshalResponse returns resp as the JSON string returned to JavaScript.
+func marshalResponse(resp render.Response) string {
 	jsonBytes, _ := json.Marshal(resp)
 	return string(jsonBytes)
 }
 
-func invokeWasm(args []js.Value, run func(string) (string, error)) any {
+func invokeWasm(args []js.Value, run func(string) (render.Response, error)) any {
 	if len(args) != 1 {
-		return errorResponse(ErrorTypeInvalidParameters,
+		return errorResponse(render.ErrorTypeInvalidParameters,
 			"Invalid number of arguments",
 			fmt.Sprintf("Expected 1 argument, got %d", len(args)))
 	}
-
-	result, err := run(args[0].String())
-	if err != nil {
-		return errorResponse(classifyError(err), err.Error(), "")
-	}
-	return successResponse(result)
-}
-
-// renderASCII is the main WASM function exposed to JavaScript
-// It takes JSON string parameters and returns structured JSON responses
-// instead of throwing JavaScript errors directly
-func renderASCII(_ js.Value, args []js.Value) any {
-	return invokeWasm(args, func(paramsJSON string) (string, error) {
-		par := params{}
-		if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
-			return "", ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
-		}
-		return renderASCIIImpl(par)
-	})
+	return marshalResponse(render.Respond(run(args[0].String())))
 }
 
-func renderMermaid(_ js.Value, args []js.Value) any {
-	return invokeWasm(args, func(paramsJSON string) (string, error) {
-		par := planVizParams{}
-		if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
-			return "", ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
-		}
-		return renderMermaidImpl(par)
-	})
-}
-
-func renderDOT(_ js.Value, args []js.Value) any {
-	return invokeWasm(args, func(paramsJSON string) (string, error) {
-		par := planVizParams{}
-		if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
-			return "", ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
-		}
-		return renderDOTImpl(par)
-	})
-}
-
-func renderD2(_ js.Value, args []js.Value) any {
-	return invokeWasm(args, func(paramsJSON string) (string, error) {
-		par := planVizParams{}
-		if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
-			return "", ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
-		}
-		return renderD2Impl(par)
-	})
-}
-
-// classifyError determines the error type using errors.As for type-safe classification
-func classifyError(err error) string {
-	// Check for custom error types first
-	var parseErr ParseError
-	if errors.As(err, &parseErr) {
-		return ErrorTypeParseError
-	}
-
-	var spannerErr InvalidSpannerFormatError
-	if errors.As(err, &spannerErr) {
-		return ErrorTypeInvalidSpannerFormat
-	}
-
-	var renderErr RenderError
-	if errors.As(err, &renderErr) {
-		return ErrorTypeRenderError
-	}
-
-	var paramErr InvalidParametersError
-	if errors.As(err, &paramErr) {
-		return ErrorTypeInvalidParameters
-	}
-
-	// Default to render error for unknown error types
-	return ErrorTypeRenderError
-}
-
-// renderASCIIImpl implements the core rendering logic
-// Validates parameters, extracts query plan, and renders ASCII output
-func renderASCIIImpl(par params) (string, error) {
-	stats, _, err := queryplan.ExtractQueryPlan([]byte(par.Input))
-	if err != nil {
-		// Wrap external parsing errors in our custom type
-		return "", ParseError{msg: fmt.Sprintf("Failed to extract query plan: %v", err)}
-	}
-
-	mode, err := reference.ParseRenderMode(par.Mode)
-	if err != nil {
-		return "", InvalidParametersError{msg: fmt.Sprintf("Invalid render mode: %v", err)}
-	}
-
-	format, err := reference.ParseFormat(par.Format)
-	if err != nil {
-		return "", InvalidParametersError{msg: fmt.Sprintf("Invalid format type: %v", err)}
-	}
-
-	if par.PrintSections != nil {
-		for _, section := range *par.PrintSections {
-			if _, err := reference.ParsePrintSection(string(section)); err != nil {
-				return "", InvalidParametersError{msg: fmt.Sprintf("Invalid print section: %v", err)}
+// wasmExport adapts a render export to JavaScript: it takes the JSON
+// parameters, or no arguments for exports without parameters.
+func wasmExport(export render.Export) exportFunc {
+	if export.NoParams {
+		return func(_ js.Value, args []js.Value) any {
+			if len(args) != 0 {
+				return errorResponse(render.ErrorTypeInvalidParameters,
+					"Invalid number of arguments",
+					fmt.Sprintf("Expected 0 arguments, got %d", len(args)))
 			}
+			return marshalResponse(render.Respond(export.Run("")))
 		}
 	}
-
-	// Validate Spanner query plan structure
-	queryPlan := stats.GetQueryPlan()
-	if queryPlan == nil {
-		return "", InvalidSpannerFormatError{msg: "Query plan is missing from input"}
-	}
-
-	planNodes := queryPlan.GetPlanNodes()
-	if len(planNodes) == 0 {
-		return "", InvalidSpannerFormatError{msg: "Plan nodes are missing from query plan"}
-	}
-
-	config := reference.RenderConfig{
-		WrapWidth:                  par.WrapWidth,
-		HangingIndent:              par.HangingIndent,
-		PrintSections:              par.PrintSections,
-		ShowScalarVars:             par.ShowScalarVars,
-		ResolveScalarVars:          par.ResolveScalarVars,
-		ResolveScalarVarsRecursive: par.ResolveScalarVarsRecursive,
-	}
-	s, err := reference.RenderTreeTableWithConfig(planNodes, mode, format, config)
-	if err != nil {
-		return "", RenderError{msg: fmt.Sprintf("Failed to render tree table: %v", err)}
+	return func(_ js.Value, args []js.Value) any {
+		return invokeWasm(args, export.Run)
 	}
-	return s, nil
 }
 
-func buildPlanFromParams(par planVizParams) (*visualize.Plan, error) {
-	stats, rowType, err := queryplan.ExtractQueryPlan([]byte(par.Input))
-	if err != nil {
-		return nil, ParseError{msg: fmt.Sprintf("Failed to extract query plan: %v", err)}
-	}
-
-	queryPlan := stats.GetQueryPlan()
-	if queryPlan == nil {
-		return nil, InvalidSpannerFormatError{msg: "Query plan is missing from input"}
-	}
-	if len(queryPlan.GetPlanNodes()) == 0 {
-		return nil, InvalidSpannerFormatError{msg: "Plan nodes are missing from query plan"}
-	}
-
-	buildOpts := visualize.BuildOptions{
-		Full:              par.Full,
-		Metadata:          par.Metadata,
-		ExecutionStats:    par.ExecutionStats,
-		ExecutionSummary:  par.ExecutionSummary,
-		SerializeResult:   par.SerializeResult,
-		HideScanTarget:    par.HideScanTarget,
-		NonVariableScalar: par.NonVariableScalar,
-		VariableScalar:    par.VariableScalar,
-	}
-	buildOpts.ApplyFull()
-
-	plan, err := visualize.BuildPlan(rowType, stats, buildOpts)
-	if err != nil {
-		return nil, RenderError{msg: fmt.Sprintf("Failed to build plan: %v", err)}
-	}
-	return plan, nil
-}
-
-func renderMermaidImpl(par planVizParams) (string, error) {
-	plan, err := buildPlanFromParams(par)
-	if err != nil {
-		return "", err
-	}
-
-	src, err := mermaid.Source(plan)
-	if err != nil {
-		return "", RenderError{msg: fmt.Sprintf("Failed to render mermaid diagram: %v", err)}
+// renderASCII is the main WASM function exposed to JavaScript
+// It takes JSON string parameters and returns structured JSON responses
+// instead of throwing JavaScript errors directly. Called with a plain object
+// instead, it returns the response as an object, which avoids serializing
+// large inputs and results twice.
+func renderASCII(_ js.Value, args []js.Value) any {
+	if len(args) == 1 && args[0].Type() == js.TypeObject {
+		return invokeWasmObject(args[0])
 	}
-	return src, nil
+	export, _ := render.Lookup("renderASCII")
+	return invokeWasm(args, export.Run)
 }
 
-// renderDOTImpl returns Graphviz DOT source text. Layout and SVG generation
-// happen in the browser (see renderSVGDiagram in src/wasm.ts), so the WASM
-// binary does not need to embed a Graphviz runtime.
-func renderDOTImpl(par planVizParams) (string, error) {
-	plan, err := buildPlanFromParams(par)
-	if err != nil {
-		return "", err
+// setUsageStatsEnabled turns usage counting on or off; counts are kept when
+// counting is turned off
+func setUsageStatsEnabled(_ js.Value, args []js.Value) any {
+	if len(args) != 1 || args[0].Type() != js.TypeBoolean {
+		return errorResponse(render.ErrorTypeInvalidParameters,
+			"Invalid arguments",
+			"Expected 1 boolean argument")
 	}
-
-	src, err := dot.Source(plan)
-	if err != nil {
-		return "", RenderError{msg: fmt.Sprintf("Failed to render DOT source: %v", err)}
-	}
-	return src, nil
+	render.SetUsageStatsEnabled(args[0].Bool())
+	return marshalResponse(render.Respond(render.Response{}, nil))
 }
 
-// renderD2Impl returns D2 (https://d2lang.com) diagram source text. Layout and
-// image generation happen externally via the d2 CLI, so the WASM binary does
-// not embed a D2 runtime (the official D2 browser bundle is far too large).
-func renderD2Impl(par planVizParams) (string, error) {
-	plan, err := buildPlanFromParams(par)
-	if err != nil {
-		return "", err
-	}
-
-	src, err := d2.Source(plan)
-	if err != nil {
-		return "", RenderError{msg: fmt.Sprintf("Failed to render D2 source: %v", err)}
-	}
-	return src, nil
+func init() {
+	registerJSExports(directExport, map[string]exportFunc{
+		"renderASCII":          renderASCII,
+		"setUsageStatsEnabled": setUsageStatsEnabled,
+		"cancelRender":         cancelRender,
+	})
+	registerJSExports(mutatingExport, map[string]exportFunc{
+		"registerFormatter": registerFormatter,
+	})
+	registerJSExports(promiseExport, map[string]exportFunc{
+		"renderAsync": renderAsync,
+	})
 }
 
 func main() {
-	js.Global().Set("renderASCII", js.FuncOf(renderASCII))
-	js.Global().Set("renderMermaid", js.FuncOf(renderMermaid))
-	js.Global().Set("renderDOT", js.FuncOf(renderDOT))
-	js.Global().Set("renderD2", js.FuncOf(renderD2))
-	c := make(<-chan struct{})
-	<-c
+	render.BuildTime = buildTime
+	exportFeatures()
+	<-stopped
 }
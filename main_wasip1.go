@@ -0,0 +1,25 @@
+//go:build wasip1
+
+package main
+
+import (
+	"os"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// buildTime is set by the build scripts with
+// -ldflags "-X main.buildTime=2025-01-02T03:04:05Z".
+var buildTime string
+
+// main is the entry point of the WASI build for runtimes such as wasmtime and
+// workerd. It reads the JSON parameters on stdin, calls the export named by
+// the first argument, renderASCII by default, and writes the JSON response to
+// stdout, exiting with 1 for error responses:
+//
+//	wasmtime rendertree-wasi.wasm < params.json
+//	wasmtime rendertree-wasi.wasm getCapabilities < /dev/null
+func main() {
+	render.BuildTime = buildTime
+	os.Exit(runWASI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
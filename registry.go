@@ -0,0 +1,97 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// The exports of the render package, including those of its optional
+// features, are set on globalThis from render.Features. Exports that take JS
+// values other than a JSON string, such as callbacks, are registered here
+// from an init function instead; files for optional features carry the same
+// build tags as the render files they call into.
+
+// exportFunc is the signature of a function exposed on globalThis.
+type exportFunc func(this js.Value, args []js.Value) any
+
+// exportKind is how exportFeatures exposes an export.
+type exportKind int
+
+const (
+	// directExport returns synchronously and runs right away, even while a
+	// job of jobs runs, as it only reads state or manages the jobs, such as
+	// cancelling them; it has a Promise-returning variant
+	directExport exportKind = iota
+	// mutatingExport returns synchronously and changes state that other
+	// calls use, so it runs as a job of jobs; it has a Promise-returning
+	// variant
+	mutatingExport
+	// promiseExport returns a Promise and queues its own work, so it has no
+	// Promise-returning variant
+	promiseExport
+)
+
+// jsExport is an export registered with registerJSExports.
+type jsExport struct {
+	fn   exportFunc
+	kind exportKind
+}
+
+// jsExports are the exports registered with registerJSExports. They replace
+// render exports of the same name.
+var jsExports = make(map[string]jsExport)
+
+func registerJSExports(kind exportKind, exports map[string]exportFunc) {
+	for name, fn := range exports {
+		jsExports[name] = jsExport{fn: fn, kind: kind}
+	}
+}
+
+// exportedFuncs are the functions set on globalThis by exportFeatures, by
+// name, for shutdown to remove and release.
+var exportedFuncs = make(map[string]js.Func)
+
+// exportFeatures sets every export on globalThis, together with its
+// Promise-returning variant unless it returns a Promise itself.
+func exportFeatures() {
+	exports := make(map[string]jsExport)
+	for _, f := range render.Features() {
+		for name, export := range f.Exports {
+			kind := directExport
+			if export.Mutates {
+				kind = mutatingExport
+			}
+			exports[name] = jsExport{fn: wasmExport(export), kind: kind}
+		}
+	}
+	for name, export := range jsExports {
+		exports[name] = export
+	}
+	for name, export := range exports {
+		switch export.kind {
+		case promiseExport:
+			exportedFuncs[name] = js.FuncOf(export.fn)
+		default:
+			exportedFuncs[name] = js.FuncOf(syncExport(export.fn, export.kind == mutatingExport))
+		}
+		if export.kind != promiseExport {
+			exportedFuncs[name+asyncSuffix] = js.FuncOf(asyncExport(export.fn))
+		}
+	}
+	for name, f := range exportedFuncs {
+		js.Global().Set(name, f)
+	}
+}
+
+// unexportFeatures removes the exports from globalThis and releases them.
+// A function may release itself while it runs.
+func unexportFeatures() {
+	for name, f := range exportedFuncs {
+		js.Global().Delete(name)
+		f.Release()
+	}
+	clear(exportedFuncs)
+}
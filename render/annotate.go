@@ -0,0 +1,93 @@
+package render
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// WarningCodeUnknownAnnotationNode is reported for annotations whose node ID
+// has no row in the rendered output.
+const WarningCodeUnknownAnnotationNode = "UNKNOWN_ANNOTATION_NODE"
+
+// annotationMarker starts each injected annotation line.
+const annotationMarker = "» "
+
+// injectAnnotations inserts the annotations under the rows of the rendered
+// table, after any wrapped continuation lines of the row. Each annotation
+// line has an empty ID cell and starts with annotationMarker; multi-line
+// annotations produce one line each. The IDs of annotations without a row
+// are returned in ascending order.
+func injectAnnotations(table string, annotations map[int32]string) (string, []int32) {
+	if len(annotations) == 0 {
+		return table, nil
+	}
+
+	lines := strings.Split(table, "\n")
+	out := make([]string, 0, len(lines)+len(annotations))
+	seen := make(map[int32]bool, len(annotations))
+	var pending []string
+	flush := func() {
+		out = append(out, pending...)
+		pending = nil
+	}
+	for _, line := range lines {
+		id, width, ok := tableRowID(line)
+		if ok || !strings.HasPrefix(line, "|") {
+			flush()
+		}
+		out = append(out, line)
+		if !ok {
+			continue
+		}
+		note, found := annotations[id]
+		if !found {
+			continue
+		}
+		seen[id] = true
+		prefix := "|" + strings.Repeat(" ", width-2) + "| " + annotationMarker
+		for _, l := range strings.Split(note, "\n") {
+			pending = append(pending, prefix+l)
+		}
+	}
+	flush()
+	return strings.Join(out, "\n"), unplacedAnnotations(annotations, seen)
+}
+
+// annotateRows sets the annotations on the rows of the row model and returns
+// the IDs of annotations without a row.
+func annotateRows(rows []planRow, annotations map[int32]string) []int32 {
+	placed := make(map[int32]bool, len(annotations))
+	for i := range rows {
+		if note, ok := annotations[rows[i].ID]; ok {
+			rows[i].Annotation = note
+			placed[rows[i].ID] = true
+		}
+	}
+	return unplacedAnnotations(annotations, placed)
+}
+
+// unplacedAnnotations returns the IDs of annotations not in placed, sorted.
+func unplacedAnnotations(annotations map[int32]string, placed map[int32]bool) []int32 {
+	var unknown []int32
+	for _, id := range slices.Sorted(maps.Keys(annotations)) {
+		if !placed[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	return unknown
+}
+
+// annotationWarnings reports annotations that could not be placed.
+func annotationWarnings(unknown []int32) []Warning {
+	var warnings []Warning
+	for _, id := range unknown {
+		warnings = append(warnings, Warning{
+			Code:    WarningCodeUnknownAnnotationNode,
+			Message: fmt.Sprintf("No rendered row for annotated node %d", id),
+			NodeID:  &id,
+		})
+	}
+	return warnings
+}
@@ -0,0 +1,201 @@
+//go:build !noanonymize
+
+package render
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func init() {
+	registerFeature("anonymize", map[string]Export{
+		"anonymizePlan": {Run: anonymizePlan},
+	})
+}
+
+// sessionSeed is used when the caller does not pass a seed, so that pseudonyms
+// are stable within one page session but differ between sessions.
+var sessionSeed = rand.Text()
+
+var (
+	identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+	// variablePattern matches variable references such as "$SingerId".
+	variablePattern = regexp.MustCompile(`\$[A-Za-z_][A-Za-z0-9_]*`)
+	// literalPattern matches single- and double-quoted string literals in
+	// scalar expressions, which may contain user data.
+	literalPattern = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+)
+
+// Pseudonym prefixes by identifier kind
+const (
+	pseudonymTable  = "table"
+	pseudonymColumn = "col"
+)
+
+type anonymizeParams struct {
+	Input string `json:"input"`
+	// Seed makes pseudonyms stable across invocations and inputs: the same
+	// identifier maps to the same pseudonym whenever the seed is the same.
+	Seed    string `json:"seed,omitempty"`
+	Recover bool   `json:"recover,omitempty"`
+}
+
+// anonymizer replaces table, index, and column names with pseudonyms derived
+// from a keyed hash of the name, so that they depend only on the seed and the
+// name and not on the plan they appear in.
+type anonymizer struct {
+	seed  string
+	names map[string]string
+}
+
+func newAnonymizer(seed string) *anonymizer {
+	if seed == "" {
+		seed = sessionSeed
+	}
+	return &anonymizer{seed: seed, names: make(map[string]string)}
+}
+
+// add registers the identifiers in name, e.g. both parts of "v1.SingerId".
+// The first kind registered for an identifier wins, so callers add tables
+// before columns.
+func (a *anonymizer) add(kind, name string) {
+	for _, id := range identifierPattern.FindAllString(name, -1) {
+		if _, ok := a.names[id]; ok {
+			continue
+		}
+		sum := sha256.Sum256([]byte(a.seed + "\x00" + id))
+		a.names[id] = kind + "_" + hex.EncodeToString(sum[:4])
+	}
+}
+
+// text replaces registered identifiers in s and masks string literals.
+func (a *anonymizer) text(s string) string {
+	s = literalPattern.ReplaceAllStringFunc(s, func(lit string) string {
+		return lit[:1] + "?" + lit[:1]
+	})
+	return identifierPattern.ReplaceAllStringFunc(s, func(id string) string {
+		if p, ok := a.names[id]; ok {
+			return p
+		}
+		return id
+	})
+}
+
+// collect registers the identifiers of planNodes and rowType.
+func (a *anonymizer) collect(planNodes []*sppb.PlanNode, rowType *sppb.StructType) {
+	for _, node := range planNodes {
+		fields := node.GetMetadata().GetFields()
+		// Batch scans read a variable, not a table
+		if valueString(fields["scan_type"]) != "BatchScan" {
+			a.add(pseudonymTable, valueString(fields["scan_target"]))
+		}
+		a.add(pseudonymTable, valueString(fields["distribution_table"]))
+	}
+	for _, node := range planNodes {
+		for _, link := range node.GetChildLinks() {
+			a.add(pseudonymColumn, link.GetVariable())
+		}
+		desc := node.GetShortRepresentation().GetDescription()
+		if node.GetDisplayName() == "Reference" {
+			a.add(pseudonymColumn, desc)
+		}
+		for _, ref := range variablePattern.FindAllString(desc, -1) {
+			a.add(pseudonymColumn, ref)
+		}
+	}
+	for _, field := range rowType.GetFields() {
+		a.add(pseudonymColumn, field.GetName())
+	}
+}
+
+// rewritePlanNode anonymizes node in place.
+func (a *anonymizer) rewritePlanNode(node *sppb.PlanNode) {
+	for _, link := range node.GetChildLinks() {
+		link.Variable = a.text(link.GetVariable())
+	}
+	if sr := node.GetShortRepresentation(); sr != nil {
+		sr.Description = a.text(sr.GetDescription())
+		if len(sr.GetSubqueries()) > 0 {
+			subqueries := make(map[string]int32, len(sr.GetSubqueries()))
+			for name, index := range sr.GetSubqueries() {
+				subqueries[a.text(name)] = index
+			}
+			sr.Subqueries = subqueries
+		}
+	}
+	for _, v := range node.GetMetadata().GetFields() {
+		a.rewriteValue(v)
+	}
+}
+
+// rewriteValue anonymizes the strings in a metadata value in place.
+func (a *anonymizer) rewriteValue(v *structpb.Value) {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		k.StringValue = a.text(k.StringValue)
+	case *structpb.Value_StructValue:
+		for _, f := range k.StructValue.GetFields() {
+			a.rewriteValue(f)
+		}
+	case *structpb.Value_ListValue:
+		for _, e := range k.ListValue.GetValues() {
+			a.rewriteValue(e)
+		}
+	}
+}
+
+// anonymizePlan returns the plan with identifiers replaced by pseudonyms
+func anonymizePlan(paramsJSON string) (Response, error) {
+	par := anonymizeParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return anonymizePlanImpl(par)
+}
+
+// anonymizePlanImpl returns the anonymized plan as JSON that the renderers
+// accept as input: a ResultSet when the input has a row type and a
+// ResultSetStats otherwise. The query text is dropped from the query stats.
+func anonymizePlanImpl(par anonymizeParams) (Response, error) {
+	loaded, rowType, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	// The plan nodes may be shared with the parse cache
+	stats := proto.Clone(loaded).(*sppb.ResultSetStats)
+	if rowType != nil {
+		rowType = proto.Clone(rowType).(*sppb.StructType)
+	}
+
+	a := newAnonymizer(par.Seed)
+	a.collect(stats.GetQueryPlan().GetPlanNodes(), rowType)
+	for _, node := range stats.GetQueryPlan().GetPlanNodes() {
+		a.rewritePlanNode(node)
+	}
+	for _, field := range rowType.GetFields() {
+		field.Name = a.text(field.GetName())
+	}
+	delete(stats.GetQueryStats().GetFields(), "query_text")
+
+	var msg proto.Message = stats
+	if len(rowType.GetFields()) > 0 {
+		msg = &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{RowType: rowType},
+			Stats:    stats,
+		}
+	}
+	b, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(msg)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal anonymized plan: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// formatANSI is the renderASCII format that renders the CURRENT table with
+// ANSI color escapes for terminals.
+const formatANSI = "ANSI"
+
+func isANSIFormat(format string) bool {
+	return strings.EqualFold(format, formatANSI)
+}
+
+// Color themes of the ANSI format
+const (
+	colorThemeDark  = "dark"
+	colorThemeLight = "light"
+)
+
+// ansiTheme holds the SGR parameters of each part of an ANSI table
+type ansiTheme struct {
+	operator string
+	// hot and critical color the operator names of operators whose share of
+	// the cost metric reaches the hot and critical cost shares
+	hot      string
+	critical string
+	// metadata is for the metadata after operator names and on wrapped lines
+	metadata string
+}
+
+var ansiThemes = map[string]ansiTheme{
+	colorThemeDark:  {operator: "1;36", hot: "1;33", critical: "1;31", metadata: "2"},
+	colorThemeLight: {operator: "1;34", hot: "1;33", critical: "1;31", metadata: "90"},
+}
+
+// checkColorTheme validates the colorTheme parameter; "" is "dark".
+func checkColorTheme(theme string) error {
+	if _, ok := ansiThemes[theme]; ok || theme == "" {
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid color theme: %q (expected %q or %q)", theme, colorThemeDark, colorThemeLight)}
+}
+
+// sgr wraps s in the SGR escape of code and a reset, or returns "" for an
+// empty s.
+func sgr(code, s string) string {
+	if s == "" {
+		return ""
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// costShares returns each operator's share of the self time of the cost
+// metric of opts, or nil for plans without the stat.
+func costShares(tree *planTree, opts CostOptions) map[int32]float64 {
+	if opts.Metric == costMetricCPU {
+		return selfTimeShares(tree, "cpu_time")
+	}
+	return selfTimeShares(tree, "latency")
+}
+
+// colorizeTable colors the Operator column of the table at the start of
+// rendered. Operator names get the operator color, or the hot or critical
+// color by their share in shares, and the metadata after them and on wrapped
+// lines is dimmed. Escapes are only added inside the cells, so borders, ID
+// cells, and the other columns are unchanged. Tables without an Operator
+// column are returned as they are.
+func colorizeTable(rendered string, shares map[int32]float64, opts CostOptions, theme ansiTheme) string {
+	lines := strings.Split(rendered, "\n")
+	headEnd, left, right, ok := findOperatorColumn(lines)
+	if !ok {
+		return rendered
+	}
+	for i := headEnd + 1; i < len(lines) && strings.HasPrefix(lines[i], "|"); i++ {
+		cell, ok := cellText(lines[i], left, right)
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(cell, " "+annotationMarker) {
+			continue
+		}
+		prefix, text := splitTreePrefix(cell)
+		trimmed := strings.TrimRight(text, " ")
+		padding := text[len(trimmed):]
+
+		var colored string
+		if id, _, ok := tableRowID(lines[i]); ok {
+			color := theme.operator
+			if share, ok := shares[id]; ok {
+				switch {
+				case share >= opts.Critical:
+					color = theme.critical
+				case share >= opts.Hot:
+					color = theme.hot
+				}
+			}
+			name, metadata := splitOperatorTitle(trimmed)
+			colored = sgr(color, name) + sgr(theme.metadata, metadata)
+		} else {
+			colored = sgr(theme.metadata, trimmed)
+		}
+		lines[i] = replaceCell(lines[i], left, right, prefix+colored+padding)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitOperatorTitle splits an operator title before the first labeled
+// "<...>" or raw "(...)" metadata, keeping the space with the metadata.
+func splitOperatorTitle(title string) (string, string) {
+	i := -1
+	for _, open := range []string{" <", " ("} {
+		if j := strings.Index(title, open); j >= 0 && (i < 0 || j < i) {
+			i = j
+		}
+	}
+	if i < 0 {
+		return title, ""
+	}
+	return title[:i], title[i:]
+}
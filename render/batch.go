@@ -0,0 +1,90 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// batchParams are renderASCII parameters applied to several plans: the
+// elements of Inputs, or the JSON documents concatenated in Input.
+type batchParams struct {
+	params
+	Inputs []string `json:"inputs,omitempty"`
+}
+
+// splitJSONDocuments splits input into the JSON values it concatenates, such
+// as a log dump of several EXPLAIN results. Input that does not start with a
+// JSON value is returned whole, so YAML and prototext pass through; text
+// after the last valid value is returned as a final document so that its
+// error is reported for that document only.
+func splitJSONDocuments(input string) []string {
+	dec := json.NewDecoder(strings.NewReader(input))
+	var docs []string
+	for {
+		var raw json.RawMessage
+		offset := dec.InputOffset()
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return docs
+		}
+		if err != nil {
+			if len(docs) == 0 {
+				return []string{input}
+			}
+			return append(docs, strings.TrimSpace(input[offset:]))
+		}
+		docs = append(docs, string(raw))
+	}
+}
+
+// renderBatch renders several plans with the same options and returns their
+// responses as a JSON array, so that one bad plan does not block the others
+func renderBatch(paramsJSON string) (Response, error) {
+	par := batchParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	par.noteUnknownOptions([]byte(paramsJSON), par)
+	return renderBatchImpl(par)
+}
+
+func renderBatchImpl(par batchParams) (Response, error) {
+	inputs := par.Inputs
+	switch {
+	case len(inputs) > 0 && par.Input != "":
+		return Response{}, InvalidParametersError{msg: "Specify either input or inputs, not both"}
+	case len(inputs) == 0 && par.Input != "":
+		inputs = splitJSONDocuments(par.Input)
+	case len(inputs) == 0:
+		return Response{}, InvalidParametersError{msg: "No inputs to render"}
+	}
+
+	b, err := json.Marshal(renderBatchInputs(par.params, inputs))
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal batch responses: %v", err)}
+	}
+	return par.withUnknownOptions(Response{Result: string(b)}), nil
+}
+
+// renderBatchInput renders input with the options of par as one response of
+// a batch. Errors, and panics, which would otherwise fail the whole batch or
+// kill a worker, become the error response of the input.
+func renderBatchInput(par params, input string) (resp Response) {
+	defer func() {
+		if r := recover(); r != nil {
+			CountPanic()
+			logger.Error("recovered panic in batch render", "panic", fmt.Sprint(r))
+			resp = ErrorResponse(RenderError{msg: fmt.Sprintf("Render panicked: %v", r)})
+		}
+	}()
+	par.Input = input
+	resp, err := renderASCIIImpl(par)
+	if err != nil {
+		usage.countError(classifyError(err))
+		return ErrorResponse(err)
+	}
+	resp.succeed()
+	return resp
+}
@@ -0,0 +1,30 @@
+//go:build !(js && wasm)
+
+package render
+
+import (
+	"runtime"
+	"sync"
+)
+
+// renderBatchInputs renders the inputs of a batch on a pool of one worker per
+// CPU, so that independent plans render in parallel while at most that many
+// renders hold memory at once. Responses keep the order of inputs.
+func renderBatchInputs(par params, inputs []string) []Response {
+	responses := make([]Response, len(inputs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(inputs)) {
+		wg.Go(func() {
+			for i := range next {
+				responses[i] = renderBatchInput(par, inputs[i])
+			}
+		})
+	}
+	for i := range inputs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return responses
+}
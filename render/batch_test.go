@@ -0,0 +1,41 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRenderBatchOrder(t *testing.T) {
+	var inputs []string
+	for i := range 40 {
+		if i == 17 {
+			inputs = append(inputs, "{not a plan")
+			continue
+		}
+		inputs = append(inputs, fmt.Sprintf(`{"planNodes": [{"index": 0, "kind": "RELATIONAL", "displayName": "Operator%d"}]}`, i))
+	}
+	resp, err := renderBatchImpl(batchParams{params: params{Options: Options{Mode: "PLAN", Format: "TREE"}}, Inputs: inputs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var responses []Response
+	if err := json.Unmarshal([]byte(resp.Result), &responses); err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != len(inputs) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(inputs))
+	}
+	for i, r := range responses {
+		if i == 17 {
+			if r.Success || r.Error == nil || r.Error.Type != ErrorTypeParseError {
+				t.Errorf("response %d = %+v, want a %s", i, r, ErrorTypeParseError)
+			}
+			continue
+		}
+		if want := fmt.Sprintf("Operator%d", i); !r.Success || !strings.Contains(r.Result, want) {
+			t.Errorf("response %d = %+v, want the render of %s", i, r, want)
+		}
+	}
+}
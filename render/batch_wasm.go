@@ -0,0 +1,14 @@
+//go:build js && wasm
+
+package render
+
+// renderBatchInputs renders the inputs of a batch one after another: the
+// js/wasm port runs every goroutine on the JS thread, so a worker pool would
+// not render in parallel.
+func renderBatchInputs(par params, inputs []string) []Response {
+	responses := make([]Response, len(inputs))
+	for i, input := range inputs {
+		responses[i] = renderBatchInput(par, input)
+	}
+	return responses
+}
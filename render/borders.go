@@ -0,0 +1,275 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/apstndb/spannerplan/plantree/reference"
+)
+
+// Values of the borderStyle option. Without one, tables keep the ASCII
+// borders of the table writer, "+", "-", and "|".
+const (
+	borderStyleHeavy   = "heavy"
+	borderStyleLight   = "light"
+	borderStyleDouble  = "double"
+	borderStyleMinimal = "minimal"
+	borderStyleNone    = "none"
+)
+
+// boxGlyphs are the box-drawing characters of the Unicode border styles: the
+// horizontal and vertical lines, then the corners ┌ ┐ └ ┘, the tees ├ ┤ ┬ ┴,
+// and the cross.
+var boxGlyphs = map[string][]rune{
+	borderStyleHeavy:  []rune("━┃┏┓┗┛┣┫┳┻╋"),
+	borderStyleLight:  []rune("─│┌┐└┘├┤┬┴┼"),
+	borderStyleDouble: []rune("═║╔╗╚╝╠╣╦╩╬"),
+}
+
+// checkBorderStyle validates the borderStyle parameter; "" keeps the ASCII
+// borders.
+func checkBorderStyle(style string) error {
+	switch style {
+	case "", borderStyleHeavy, borderStyleLight, borderStyleDouble, borderStyleMinimal, borderStyleNone:
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid border style: %q (expected %q, %q, %q, %q, or %q)",
+		style, borderStyleHeavy, borderStyleLight, borderStyleDouble, borderStyleMinimal, borderStyleNone)}
+}
+
+// renderWithBorderStyle renders par with the ASCII borders, which the stages
+// that find rows and columns in the table read, and then redraws the borders
+// of table formats in par.BorderStyle. Other formats ignore the option.
+func renderWithBorderStyle(par params) (Response, error) {
+	style := par.BorderStyle
+	par.BorderStyle = ""
+	if err := checkBorderStyle(style); err != nil {
+		return Response{}, err
+	}
+	resp, err := renderASCIIImpl(par)
+	if err != nil || !isTextTableFormat(par.Format) {
+		return resp, err
+	}
+	var lineIndexes []int
+	resp.Result, lineIndexes = applyBorderStyle(resp.Result, style)
+	for i := range resp.LineMap {
+		resp.LineMap[i].Line = lineIndexes[resp.LineMap[i].Line]
+	}
+	return resp, nil
+}
+
+// isTextTableFormat reports whether format renders a text table, whose
+// borders are drawn with "+", "-", and "|".
+func isTextTableFormat(format string) bool {
+	_, err := reference.ParseFormat(format)
+	return err == nil || isANSIFormat(format) || isSpannerCLIFormat(format)
+}
+
+// tableGrid is the table at the start of a rendered plan as a grid of
+// visible runes, so that ANSI escapes in cells do not shift the columns.
+type tableGrid struct {
+	lines []string
+	// cols are the byte offsets of the visible runes of each line
+	cols [][]int
+	// seps are the rune offsets of the column separators, which are those
+	// of the "+" of the top border: column groups only merge columns
+	seps []int
+}
+
+// visibleRunes returns the byte offsets of the runes of line outside ANSI
+// escape sequences such as "\x1b[1;36m".
+func visibleRunes(line string) []int {
+	var cols []int
+	for i := 0; i < len(line); {
+		if strings.HasPrefix(line[i:], "\x1b[") {
+			j := i + 2
+			for j < len(line) && (line[j] < 0x40 || line[j] > 0x7e) {
+				j++
+			}
+			i = j + 1
+			continue
+		}
+		cols = append(cols, i)
+		_, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+	}
+	return cols
+}
+
+// at returns the visible rune of line i at rune offset p, or 0 outside the
+// grid.
+func (g tableGrid) at(i, p int) rune {
+	if i < 0 || i >= len(g.lines) || p < 0 || p >= len(g.cols[i]) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(g.lines[i][g.cols[i][p]:])
+	return r
+}
+
+// isSep reports whether rune offset p is a column separator.
+func (g tableGrid) isSep(p int) bool {
+	_, ok := slices.BinarySearch(g.seps, p)
+	return ok
+}
+
+// isRule reports whether line i is a border, or the rule under a column
+// group row, which has "+" at separators.
+func (g tableGrid) isRule(i int) bool {
+	if strings.HasPrefix(g.lines[i], "+") {
+		return true
+	}
+	for _, p := range g.seps {
+		if g.at(i, p) == '+' {
+			return true
+		}
+	}
+	return false
+}
+
+// glyph returns the box-drawing character of glyphs for the rune of line i
+// at rune offset p, or false if it is not part of a border. Junctions are
+// drawn by the lines they join.
+func (g tableGrid) glyph(i, p int, glyphs []rune) (rune, bool) {
+	vertical := func(r rune) bool { return r == '|' || r == '+' }
+	horizontal := func(r rune) bool { return r == '-' || r == '+' }
+	switch r := g.at(i, p); {
+	case r == '-' && g.isRule(i):
+		return glyphs[0], true
+	case r == '|' && g.isSep(p):
+		return glyphs[1], true
+	case r != '+' || !g.isSep(p) && !strings.HasPrefix(g.lines[i], "+"):
+		return 0, false
+	}
+	up := g.isSep(p) && vertical(g.at(i-1, p))
+	down := g.isSep(p) && vertical(g.at(i+1, p))
+	left, right := horizontal(g.at(i, p-1)), horizontal(g.at(i, p+1))
+	switch {
+	case up && down && left && right:
+		return glyphs[10], true
+	case up && down && right:
+		return glyphs[6], true
+	case up && down && left:
+		return glyphs[7], true
+	case down && left && right:
+		return glyphs[8], true
+	case up && left && right:
+		return glyphs[9], true
+	case down && right:
+		return glyphs[2], true
+	case down && left:
+		return glyphs[3], true
+	case up && right:
+		return glyphs[4], true
+	case up && left:
+		return glyphs[5], true
+	case up || down:
+		return glyphs[1], true
+	default:
+		return glyphs[0], true
+	}
+}
+
+// replaceRunes returns line with the visible runes at the rune offsets of
+// replace substituted.
+func replaceRunes(line string, cols []int, replace map[int]string) string {
+	if len(replace) == 0 {
+		return line
+	}
+	var b strings.Builder
+	b.Grow(len(line) + len(replace)*2)
+	last := 0
+	for p, col := range cols {
+		s, ok := replace[p]
+		if !ok {
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(line[col:])
+		b.WriteString(line[last:col])
+		b.WriteString(s)
+		last = col + size
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// applyBorderStyle redraws the borders of the table at the start of
+// rendered, the lines from the top border through the bottom border, in a
+// border style: heavy, light, and double draw them with box-drawing
+// characters, minimal keeps only the column separators and the rule under
+// the header, as in Markdown tables, and none draws no borders, separating
+// the columns with spaces. Operator rows keep their tree connectors, and the
+// lines before and after the table are unchanged. It also returns the index
+// of each line of rendered in the result, or -1 for removed lines.
+func applyBorderStyle(rendered, style string) (string, []int) {
+	lines := strings.Split(rendered, "\n")
+	indexes := make([]int, len(lines))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	top := slices.IndexFunc(lines, func(line string) bool { return strings.HasPrefix(line, "+") })
+	if top < 0 {
+		return rendered, indexes
+	}
+	end := top
+	for end < len(lines) && (strings.HasPrefix(lines[end], "+") || strings.HasPrefix(lines[end], "|")) {
+		end++
+	}
+
+	g := tableGrid{lines: lines[top:end], cols: make([][]int, end-top)}
+	for i, line := range g.lines {
+		g.cols[i] = visibleRunes(line)
+	}
+	for p := range g.cols[0] {
+		if g.at(0, p) == '+' {
+			g.seps = append(g.seps, p)
+		}
+	}
+	// The rule under the header is the first border after the top one
+	headerRule := slices.IndexFunc(g.lines[1:], func(line string) bool { return strings.HasPrefix(line, "+") }) + 1
+
+	table := make([]string, 0, len(g.lines))
+	for i, line := range g.lines {
+		replace := make(map[int]string)
+		keep := true
+		switch style {
+		case borderStyleMinimal:
+			keep = !strings.HasPrefix(line, "+") || i == headerRule
+			for p := range g.cols[i] {
+				if g.at(i, p) == '+' && g.isSep(p) && g.isRule(i) {
+					replace[p] = "|"
+				}
+			}
+		case borderStyleNone:
+			keep = !g.isRule(i)
+			for _, p := range g.seps {
+				if g.at(i, p) == '|' {
+					replace[p] = " "
+				}
+			}
+		default:
+			for p := range g.cols[i] {
+				if r, ok := g.glyph(i, p, boxGlyphs[style]); ok {
+					replace[p] = string(r)
+				}
+			}
+		}
+		if !keep {
+			indexes[top+i] = -1
+			continue
+		}
+		line = replaceRunes(line, g.cols[i], replace)
+		if style == borderStyleNone {
+			// Without the left border, the table starts at the first cell
+			line = strings.TrimRight(strings.TrimPrefix(line, " "), " ")
+		}
+		indexes[top+i] = top + len(table)
+		table = append(table, line)
+	}
+	for i := end; i < len(lines); i++ {
+		indexes[i] = i - (end - top) + len(table)
+	}
+	result := slices.Concat(lines[:top], table, lines[end:])
+	return strings.Join(result, "\n"), indexes
+}
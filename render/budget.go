@@ -0,0 +1,131 @@
+package render
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// WarningCodeNoLatencyStats is reported when a latency budget is requested
+// for a plan without latency stats.
+const WarningCodeNoLatencyStats = "NO_LATENCY_STATS"
+
+// maxBudgetViolators bounds the violator summary.
+const maxBudgetViolators = 5
+
+// budgetNode is the latency budget check of one operator subtree.
+type budgetNode struct {
+	node    *treeNode
+	latency float64 // msecs
+	budget  float64 // msecs
+}
+
+func (b budgetNode) overrun() float64 {
+	return b.latency - b.budget
+}
+
+// parseLatencyBudget parses the latencyBudget option, e.g. "50ms", into
+// milliseconds.
+func parseLatencyBudget(s string) (float64, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, InvalidParametersError{msg: fmt.Sprintf("Invalid latencyBudget: %q (expected a positive duration such as \"50ms\")", s)}
+	}
+	return float64(d) / float64(time.Millisecond), nil
+}
+
+// checkLatencyBudget splits target evenly across the relational operators,
+// so that each subtree's budget is proportional to its operator count, and
+// compares it with the subtree latency. Operators without latency stats are
+// skipped.
+func checkLatencyBudget(tree *planTree, target float64) []budgetNode {
+	sizes := make(map[int32]int)
+	var size func(n *treeNode) int
+	size = func(n *treeNode) int {
+		s := 1
+		for _, child := range n.relationalChildren() {
+			s += size(child)
+		}
+		sizes[n.id()] = s
+		return s
+	}
+	total := size(tree.root)
+
+	var checks []budgetNode
+	tree.root.walk(func(n *treeNode) {
+		latency, ok := n.durationMillis("latency")
+		if !ok {
+			return
+		}
+		checks = append(checks, budgetNode{
+			node:    n,
+			latency: latency,
+			budget:  target * float64(sizes[n.id()]) / float64(total),
+		})
+	})
+	return checks
+}
+
+// formatMillis formats a duration in milliseconds like the execution stats.
+func formatMillis(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64) + " msecs"
+}
+
+// budgetAnnotations returns one annotation per checked operator, merged after
+// any annotation the caller passed for the same node.
+func budgetAnnotations(checks []budgetNode, annotations map[int32]string) map[int32]string {
+	merged := make(map[int32]string, len(annotations)+len(checks))
+	for id, note := range annotations {
+		merged[id] = note
+	}
+	for _, c := range checks {
+		var note string
+		if c.overrun() > 0 {
+			note = fmt.Sprintf("over budget: %s of %s (+%s)", formatMillis(c.latency), formatMillis(c.budget), formatMillis(c.overrun()))
+		} else {
+			note = fmt.Sprintf("within budget: %s of %s", formatMillis(c.latency), formatMillis(c.budget))
+		}
+		if prev, ok := merged[c.node.id()]; ok {
+			note = prev + "\n" + note
+		}
+		merged[c.node.id()] = note
+	}
+	return merged
+}
+
+// budgetSummary lists the subtrees with the largest overruns.
+func budgetSummary(checks []budgetNode, target float64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Latency budget %s", formatMillis(target))
+	if len(checks) > 0 && checks[0].node.parent == nil {
+		fmt.Fprintf(&b, " (actual %s)", formatMillis(checks[0].latency))
+	}
+	b.WriteString(":\n")
+
+	violators := slices.DeleteFunc(slices.Clone(checks), func(c budgetNode) bool { return c.overrun() <= 0 })
+	if len(violators) == 0 {
+		b.WriteString("  every operator is within its budget\n")
+		return b.String()
+	}
+	slices.SortStableFunc(violators, func(x, y budgetNode) int { return cmp.Compare(y.overrun(), x.overrun()) })
+	for _, v := range violators[:min(len(violators), maxBudgetViolators)] {
+		fmt.Fprintf(&b, "  %d %s: +%s over %s\n", v.node.id(), v.node.title(), formatMillis(v.overrun()), formatMillis(v.budget))
+	}
+	return b.String()
+}
+
+// applyLatencyBudget returns annotations with the budget check of every
+// operator added and the violator summary for the latencyBudget option.
+func applyLatencyBudget(planNodes []*sppb.PlanNode, target float64, annotations map[int32]string) (map[int32]string, string, []Warning) {
+	checks := checkLatencyBudget(buildPlanTree(planNodes), target)
+	if len(checks) == 0 {
+		return annotations, "", []Warning{{Code: WarningCodeNoLatencyStats, Message: "The input has no latency stats; the latency budget is ignored"}}
+	}
+	return budgetAnnotations(checks, annotations), budgetSummary(checks, target), nil
+}
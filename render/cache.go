@@ -0,0 +1,106 @@
+package render
+
+import (
+	"hash/maphash"
+	"sync"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// parseCacheSize bounds the number of parsed inputs kept in memory. The UI
+// re-renders the same input whenever an option changes, so a handful of
+// entries covers the common case without pinning many large plans.
+const parseCacheSize = 4
+
+type parseCacheEntry struct {
+	hash    uint64
+	input   string
+	stats   *sppb.ResultSetStats
+	rowType *sppb.StructType
+	format  string
+	// layout is shared by the renders of the input
+	layout *layoutCache
+}
+
+// parseCache memoizes parsed query plans keyed by input hash, so that
+// renders that only change options skip parsing. Entries are kept in
+// most-recently-used order. Cached values are shared and must not be modified.
+type parseCache struct {
+	mu      sync.Mutex
+	seed    maphash.Seed
+	entries []parseCacheEntry
+}
+
+var inputCache = &parseCache{seed: maphash.MakeSeed()}
+
+func (c *parseCache) get(input string) (*sppb.ResultSetStats, *sppb.StructType, string, bool) {
+	hash := maphash.String(c.seed, input)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, entry := range c.entries {
+		if entry.hash == hash && entry.input == input {
+			copy(c.entries[1:i+1], c.entries[:i])
+			c.entries[0] = entry
+			return entry.stats, entry.rowType, entry.format, true
+		}
+	}
+	return nil, nil, "", false
+}
+
+func (c *parseCache) put(input string, stats *sppb.ResultSetStats, rowType *sppb.StructType, format string) {
+	entry := parseCacheEntry{
+		hash:    maphash.String(c.seed, input),
+		input:   input,
+		stats:   stats,
+		rowType: rowType,
+		format:  format,
+		layout:  &layoutCache{},
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) < parseCacheSize {
+		c.entries = append(c.entries, parseCacheEntry{})
+	}
+	copy(c.entries[1:], c.entries)
+	c.entries[0] = entry
+}
+
+// extractQueryPlan is parseQueryPlan backed by inputCache, without the input
+// format.
+func extractQueryPlan(input string) (*sppb.ResultSetStats, *sppb.StructType, error) {
+	stats, rowType, _, err := extractQueryPlanFormat(input)
+	return stats, rowType, err
+}
+
+// extractQueryPlanFormat is parseQueryPlan backed by inputCache.
+// Parse failures are not cached.
+func extractQueryPlanFormat(input string) (*sppb.ResultSetStats, *sppb.StructType, string, error) {
+	stats, rowType, format, ok := inputCache.get(input)
+	usage.countCacheLookup(ok)
+	logger.Debug("parse cache lookup", "hit", ok, "inputBytes", len(input))
+	if ok {
+		return stats, rowType, format, nil
+	}
+	stats, rowType, format, err := parseQueryPlan(input)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	inputCache.put(input, stats, rowType, format)
+	return stats, rowType, format, nil
+}
+
+// clear drops every entry.
+func (c *parseCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// len returns the number of entries.
+func (c *parseCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
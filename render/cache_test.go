@@ -0,0 +1,67 @@
+package render
+
+import (
+	"fmt"
+	"hash/maphash"
+	"slices"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// cachedInputs returns the inputs of c in most-recently-used order.
+func (c *parseCache) cachedInputs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	inputs := make([]string, len(c.entries))
+	for i, entry := range c.entries {
+		inputs[i] = entry.input
+	}
+	return inputs
+}
+
+func TestParseCache(t *testing.T) {
+	c := &parseCache{seed: maphash.MakeSeed()}
+	stats := make(map[string]*sppb.ResultSetStats)
+	for i := range parseCacheSize + 1 {
+		input := fmt.Sprintf("plan %d", i)
+		stats[input] = &sppb.ResultSetStats{}
+		c.put(input, stats[input], nil, inputFormatYAML)
+	}
+	// The oldest entry is evicted first
+	if got, want := c.cachedInputs(), []string{"plan 4", "plan 3", "plan 2", "plan 1"}; !slices.Equal(got, want) {
+		t.Fatalf("entries = %q, want %q", got, want)
+	}
+	if _, _, _, ok := c.get("plan 0"); ok {
+		t.Error("get(plan 0) hit after eviction")
+	}
+
+	got, _, format, ok := c.get("plan 2")
+	if !ok || got != stats["plan 2"] || format != inputFormatYAML {
+		t.Fatalf("get(plan 2) = %p, %q, %v, want %p, %q, true", got, format, ok, stats["plan 2"], inputFormatYAML)
+	}
+	// A hit makes the entry the most recently used, so that it outlives the
+	// next eviction
+	c.put("plan 5", &sppb.ResultSetStats{}, nil, inputFormatYAML)
+	if got, want := c.cachedInputs(), []string{"plan 5", "plan 2", "plan 4", "plan 3"}; !slices.Equal(got, want) {
+		t.Errorf("entries after hit = %q, want %q", got, want)
+	}
+
+	c.clear()
+	if c.len() != 0 {
+		t.Errorf("len after clear = %d, want 0", c.len())
+	}
+	if _, _, _, ok := c.get("plan 5"); ok {
+		t.Error("get(plan 5) hit after clear")
+	}
+}
+
+func TestParseCacheHashCollision(t *testing.T) {
+	c := &parseCache{seed: maphash.MakeSeed()}
+	c.put("plan a", &sppb.ResultSetStats{}, nil, inputFormatYAML)
+	// Give the entry the hash of another input, as a collision would
+	c.entries[0].hash = maphash.String(c.seed, "plan b")
+	if _, _, _, ok := c.get("plan b"); ok {
+		t.Error("get returned the entry of another input with the same hash")
+	}
+}
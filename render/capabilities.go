@@ -0,0 +1,245 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/apstndb/spannerplan/plantree/reference"
+)
+
+// Kinds of renderASCII formats, which decide the options that apply
+const (
+	formatKindTable   = "table"
+	formatKindDiagram = "diagram"
+	formatKindCustom  = "custom"
+	formatKindHTML    = "html"
+	formatKindFlat    = "flat"
+	formatKindANSI    = "ansi"
+	formatKindTree    = "tree"
+	formatKindJSON    = "json"
+
+	// formatKindExperimental is for the formats of RegisterRenderer
+	formatKindExperimental = "experimental"
+)
+
+// Capabilities is returned by getCapabilities
+type Capabilities struct {
+	Modes []EnumValue `json:"modes"`
+	// DefaultMode and DefaultFormat are the values to preselect; mode and
+	// format are required parameters
+	DefaultMode   string             `json:"defaultMode"`
+	Formats       []FormatCapability `json:"formats"`
+	DefaultFormat string             `json:"defaultFormat"`
+	Options       []OptionCapability `json:"options"`
+	// APIVersion is the latest apiVersion this build renders
+	APIVersion int `json:"apiVersion"`
+}
+
+// EnumValue is an accepted value of an enumerated option
+type EnumValue struct {
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+// FormatCapability is an accepted renderASCII format
+type FormatCapability struct {
+	Value       string `json:"value"`
+	Description string `json:"description"`
+	// Kind is "table", "ansi", "diagram", "html", "flat", "tree", "json", or
+	// "custom" for registered formatters
+	Kind string `json:"kind"`
+}
+
+// OptionCapability describes a renderASCII option
+type OptionCapability struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Type is "boolean", "number", "string", "enum", "enumList", or "object"
+	Type string `json:"type"`
+	// Values lists the values of enum options
+	Values []EnumValue `json:"values,omitempty"`
+	// Default is the value used when the option is omitted, if not the zero
+	// value
+	Default string `json:"default,omitempty"`
+	// FormatKinds lists the kinds of the formats the option applies to
+	FormatKinds []string `json:"formatKinds"`
+}
+
+var (
+	allFormatKinds = []string{formatKindTable, formatKindANSI, formatKindDiagram, formatKindHTML, formatKindFlat, formatKindTree, formatKindCustom, formatKindExperimental}
+	// inputFormatKinds are for the options about the input and the response,
+	// which JSON-NORMALIZED has too
+	inputFormatKinds  = []string{formatKindTable, formatKindANSI, formatKindDiagram, formatKindHTML, formatKindFlat, formatKindTree, formatKindCustom, formatKindJSON, formatKindExperimental}
+	rowFormatKinds    = []string{formatKindTable, formatKindANSI, formatKindHTML, formatKindCustom}
+	tableFormatKinds  = []string{formatKindTable, formatKindANSI}
+	columnFormatKinds = []string{formatKindTable, formatKindANSI, formatKindHTML}
+	ansiFormatKinds   = []string{formatKindANSI}
+	treeFormatKinds   = []string{formatKindTree}
+	customFormatKinds = []string{formatKindCustom}
+
+	// predicateFormatKinds are the formats that print predicates as text
+	predicateFormatKinds = []string{formatKindTable, formatKindANSI, formatKindTree}
+)
+
+// buildCapabilities lists the values renderASCII accepts. Modes, formats, and
+// print sections are the library's, so they match what the parsers accept;
+// registered formatters are listed as custom formats.
+func buildCapabilities() Capabilities {
+	caps := Capabilities{
+		Modes: []EnumValue{
+			{string(reference.RenderModeAuto), "Show execution statistics when the capture has them"},
+			{string(reference.RenderModePlan), "Show the plan without execution statistics"},
+			{string(reference.RenderModeProfile), "Show the plan with execution statistics"},
+			{renderModeTimeline, "Show the plan with execution statistics and a Gantt-style Timeline column"},
+		},
+		DefaultMode: string(reference.RenderModeAuto),
+		Formats: []FormatCapability{
+			{string(reference.FormatCurrent), "Labeled metadata in angle brackets", formatKindTable},
+			{string(reference.FormatTraditional), "Raw metadata in node titles", formatKindTable},
+			{string(reference.FormatCompact), "Tree with minimal spacing", formatKindTable},
+		},
+		DefaultFormat: string(reference.FormatCurrent),
+		APIVersion:    APIVersion,
+	}
+	caps.Formats = append(caps.Formats, FormatCapability{formatANSI, "CURRENT table with ANSI colors for terminals", formatKindANSI})
+	caps.Formats = append(caps.Formats, FormatCapability{formatSpannerCLI, "TRADITIONAL table with the headers and alignment of spanner-cli EXPLAIN and EXPLAIN ANALYZE", formatKindTable})
+	caps.Formats = append(caps.Formats, FormatCapability{formatHTML, "HTML table with a CSS class per column and an anchor per operator", formatKindHTML})
+	for _, name := range rendererNames {
+		r := renderers[name]
+		caps.Formats = append(caps.Formats, FormatCapability{name, r.description, r.kind})
+	}
+	for _, name := range sortedKeys(customFormatters) {
+		caps.Formats = append(caps.Formats, FormatCapability{name, "Registered with registerFormatter", formatKindCustom})
+	}
+
+	operatorFilterValues := make([]EnumValue, 0, len(operatorFilters))
+	for _, name := range sortedKeys(operatorFilters) {
+		operatorFilterValues = append(operatorFilterValues, EnumValue{name, operatorFilterDescriptions[name]})
+	}
+	columnValues := make([]EnumValue, len(selectableColumns))
+	for i, c := range selectableColumns {
+		columnValues[i] = EnumValue{c.header, c.description}
+	}
+	caps.Options = []OptionCapability{
+		{Name: "wrapWidth", Description: "Text wrapping width; 0 disables wrapping", Type: "number", FormatKinds: tableFormatKinds},
+		{Name: "targetWidth", Description: "Total table width to fit by choosing the wrap width; an alternative to wrapWidth", Type: "number", FormatKinds: tableFormatKinds},
+		{Name: "wrapMode", Description: "Where wrapped text breaks in the Operator column", Type: "enum", Values: []EnumValue{
+			{wrapModeChar, "At wrapWidth, mid-token"},
+			{wrapModeWord, "At spaces; longer tokens are broken mid-token"},
+			{wrapModeSmart, "At spaces, commas, and parentheses, for metadata and predicates"},
+		}, Default: wrapModeChar, FormatKinds: tableFormatKinds},
+		{Name: "hangingIndent", Description: "Align wrapped lines after node-local prefixes", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "printSections", Description: "Appendix sections printed after the table", Type: "enumList", Values: []EnumValue{
+			{string(reference.PrintPredicates), "Predicate-like scalar links"},
+			{string(reference.PrintOrdering), "Ordering of sort operators"},
+			{string(reference.PrintAggregate), "Grouping and aggregates of aggregate operators"},
+			{string(reference.PrintTyped), "All typed scalar links, as a raw dump"},
+			{string(reference.PrintFull), "All scalar links, including unnamed links"},
+		}, Default: string(reference.PrintPredicates), FormatKinds: tableFormatKinds},
+		{Name: "showScalarVars", Description: "Show scalar variable names", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "resolveScalarVars", Description: "Resolve scalar variable references in appendices", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "resolveScalarVarsRecursive", Description: "Resolve scalar variable references recursively", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "consoleNaming", Description: "Use Cloud Console names for operators and metadata labels", Type: "boolean", FormatKinds: allFormatKinds},
+		{Name: "prettyMetadataKeys", Description: "Show metadata keys as readable labels with units", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "recover", Description: "Render invalid plan nodes as placeholders with warnings", Type: "boolean", FormatKinds: inputFormatKinds},
+		{Name: "lenient", Description: "Render plan nodes that fail to render as placeholder rows with warnings instead of failing; implies recover", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "inputEncoding", Description: "Encoding of the input; detected when omitted", Type: "enum", Values: []EnumValue{
+			{inputEncodingProtoBase64, "Base64-encoded binary ResultSetStats, ResultSet, or QueryPlan"},
+		}, FormatKinds: inputFormatKinds},
+		{Name: "planIndex", Description: "Plan to render of inputs with several, such as batch DML responses; the first by default", Type: "number", FormatKinds: inputFormatKinds},
+		{Name: "scalarRepresentation", Description: "How scalar expressions are displayed", Type: "enum", Values: []EnumValue{
+			{scalarRepresentationShort, "Short representation with $variable references"},
+			{scalarRepresentationFull, "Variable references expanded"},
+			{scalarRepresentationFootnote, "Short representation with footnotes of the full expressions"},
+		}, Default: scalarRepresentationShort, FormatKinds: rowFormatKinds},
+		{Name: "showQueryText", Description: "Prepend the query text", Type: "boolean", FormatKinds: rowFormatKinds},
+		{Name: "showOptimizerInfo", Description: "Prepend the optimizer version and statistics package", Type: "boolean", FormatKinds: rowFormatKinds},
+		{Name: "substituteParameters", Description: "Prepend the query text with parameter values substituted", Type: "boolean", FormatKinds: rowFormatKinds},
+		{Name: "annotations", Description: "Comments keyed by node ID", Type: "object", FormatKinds: rowFormatKinds},
+		{Name: "sortBy", Description: "Order of the rows handed to custom formatters", Type: "enum", Values: []EnumValue{
+			{sortByLatency, "Latency descending"},
+			{sortByRows, "Returned rows descending"},
+			{sortByID, "Node ID ascending"},
+		}, FormatKinds: customFormatKinds},
+		{Name: "sortChildrenBy", Description: "Order of the children of each operator, so that the most expensive branch comes first", Type: "enum", Values: []EnumValue{
+			{sortByLatency, "Total latency descending"},
+			{sortByRows, "Returned rows descending"},
+			{sortChildrenByCPU, "Total CPU time descending"},
+			{sortChildrenByNone, "Plan order"},
+		}, Default: sortChildrenByNone, FormatKinds: allFormatKinds},
+		{Name: "childLinks", Description: "Which child links of each operator are drawn", Type: "enum", Values: []EnumValue{
+			{childLinksAll, "Relational inputs and scalar subqueries"},
+			{childLinksHideScalar, "Scalar subqueries hidden, with their inputs attached to the operator"},
+			{childLinksRelational, "Relational inputs only, without scalar subqueries"},
+		}, Default: childLinksAll, FormatKinds: allFormatKinds},
+		{Name: "linkLabels", Description: "Label each relational edge with its child link type and variable, e.g. [Map $v1]", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "operatorFilter", Description: "Keep only the operators of one category", Type: "enum", Values: operatorFilterValues, FormatKinds: rowFormatKinds},
+		{Name: "filter", Description: "Keep only the operators matching conditions such as latency>10ms, and their ancestors", Type: "string", FormatKinds: rowFormatKinds},
+		{Name: "latencyBudget", Description: "Target latency such as \"50ms\" split across the operators", Type: "string", FormatKinds: rowFormatKinds},
+		{Name: "estimateColumn", Description: "Add an Est/Actual rows column", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "cost", Description: "Add a Cost column of each operator's share of self latency or CPU time, marking hot operators, and return the shares", Type: "object", FormatKinds: rowFormatKinds},
+		{Name: "heatmap", Description: "Return each operator's share of the latency, rows, and CPU time of the plan, for heat-colored trees", Type: "boolean", FormatKinds: rowFormatKinds},
+		{Name: "lint", Description: "Append the lint findings under the table; not available in builds with the nolint tag", Type: "boolean", FormatKinds: columnFormatKinds},
+		{Name: "latencyBars", Description: "Add a Latency Share bar column", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "decimalSeparator", Description: "Decimal separator of execution stats spelled with digit grouping; stats are normalized to plain decimals and canonical duration units", Type: "enum", Values: []EnumValue{
+			{decimalSeparatorPoint, "1,234.5 msecs"},
+			{decimalSeparatorComma, "1.234,5 msecs"},
+		}, Default: decimalSeparatorPoint, FormatKinds: inputFormatKinds},
+		{Name: "statDurationUnit", Description: "Unit the duration execution stats are converted to as they are normalized, in every format and the JSON APIs", Type: "enum", Values: []EnumValue{
+			{"s", "Seconds (secs)"},
+			{"ms", "Milliseconds (msecs)"},
+			{"µs", "Microseconds (usecs); \"us\" is accepted too"},
+		}, FormatKinds: inputFormatKinds},
+		{Name: "charset", Description: "Characters of the table decorations; tree connectors are always ASCII and borders are drawn per borderStyle", Type: "enum", Values: []EnumValue{
+			{charsetUnicode, "Unicode latency bars, annotation markers, and ellipses"},
+			{charsetASCII, "ASCII replacements of the same width, for terminals and tools that mangle Unicode"},
+		}, Default: charsetUnicode, FormatKinds: tableFormatKinds},
+		{Name: "borderStyle", Description: "Borders of the table; without a style they are drawn with +, -, and |", Type: "enum", Values: []EnumValue{
+			{borderStyleHeavy, "Heavy box-drawing lines"},
+			{borderStyleLight, "Light box-drawing lines"},
+			{borderStyleDouble, "Double box-drawing lines"},
+			{borderStyleMinimal, "Column separators and the rule under the header, as in Markdown tables"},
+			{borderStyleNone, "No borders; columns are separated by spaces"},
+		}, FormatKinds: tableFormatKinds},
+		{Name: "colorTheme", Description: "Colors of the ANSI format; operator names are colored by their cost share", Type: "enum", Values: []EnumValue{
+			{colorThemeDark, "Colors for dark terminal backgrounds, with faint metadata"},
+			{colorThemeLight, "Colors for light terminal backgrounds, with gray metadata"},
+		}, Default: colorThemeDark, FormatKinds: ansiFormatKinds},
+		{Name: "noColor", Description: "Render the ANSI format without escapes", Type: "boolean", FormatKinds: ansiFormatKinds},
+		{Name: "variableLinks", Description: "Append the variables each operator refers to with the node that defines them", Type: "boolean", FormatKinds: columnFormatKinds},
+		{Name: "prettyPredicates", Description: "Break predicates at their AND and OR operators, one condition per line", Type: "boolean", FormatKinds: predicateFormatKinds},
+		{Name: "treeOneLine", Description: "Render one line per operator in the tree format, without predicates", Type: "boolean", FormatKinds: treeFormatKinds},
+		{Name: "thresholds", Description: "Thresholds of built-in warnings", Type: "object", FormatKinds: tableFormatKinds},
+		{Name: "columns", Description: "Columns to show, in order; names are case-insensitive", Type: "enumList", Values: columnValues, FormatKinds: columnFormatKinds},
+		{Name: "templateColumns", Description: "Columns computed per operator by Go text/template expressions", Type: "object", FormatKinds: columnFormatKinds},
+		{Name: "numberFormat", Description: "Thousands separators, SI units, and the duration unit of the execution stat columns", Type: "object", FormatKinds: tableFormatKinds},
+		{Name: "latencyDistribution", Description: "Add p50 and p99, or mean ± standard deviation, to the latency of operators with distributions", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "operatorGlossary", Description: "Append an explanation of each kind of operator in the plan under the table", Type: "boolean", FormatKinds: columnFormatKinds},
+		{Name: "totals", Description: "Append a row with the sum or max of the stats of the leaf operators, and optionally annotate branch operators with the totals of their subtrees", Type: "object", FormatKinds: tableFormatKinds},
+		{Name: "statsFields", Description: "Raw execution stat keys, e.g. \"remote_calls\", added as columns; response metadata lists the keys of the plan", Type: "object", FormatKinds: tableFormatKinds},
+		{Name: "experimentalStats", Description: "Add a column for every numeric execution stat of the plan that no other column shows", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "deterministic", Description: "Output that depends only on the input and the options, for snapshot tests; rejects includeMetrics and renderLimits.maxMillis", Type: "boolean", FormatKinds: inputFormatKinds},
+		{Name: "collapsedNodeIds", Description: "Operators rendered as one row summarizing their subtree, e.g. \"▶ Distributed Union (+23 nodes, 120 msecs)\"", Type: "object", FormatKinds: rowFormatKinds},
+		{Name: "columnConfig", Description: "Maximum width, truncation with an ellipsis, and alignment by column", Type: "object", FormatKinds: tableFormatKinds},
+		{Name: "columnGroups", Description: "Super-headers spanning adjacent columns", Type: "object", FormatKinds: columnFormatKinds},
+		{Name: "renderLimits", Description: "Output bounds; larger output is collapsed or summarized", Type: "object", FormatKinds: allFormatKinds},
+		{Name: "inputLimits", Description: "Largest input in bytes and plan nodes; larger input fails with INPUT_TOO_LARGE", Type: "object", FormatKinds: inputFormatKinds},
+		{Name: "chunkSize", Description: "Return larger outputs in chunks read with nextChunk", Type: "number", FormatKinds: inputFormatKinds},
+		{Name: "lineMap", Description: "Return the plan node of each table line", Type: "boolean", FormatKinds: tableFormatKinds},
+		{Name: "rootNodeId", Description: "Render only the subtree of this operator; not supported by the DOT and MERMAID formats", Type: "number", FormatKinds: allFormatKinds},
+		{Name: "includeMetrics", Description: "Return the parse and render times, sizes, and Go heap in use", Type: "boolean", FormatKinds: inputFormatKinds},
+		{Name: "rootBreadcrumb", Description: "Prepend the path from the plan root to the rootNodeId operator", Type: "boolean", FormatKinds: rowFormatKinds},
+		{Name: "apiVersion", Description: "Version of the options the caller was written for; other versions are rejected with UNSUPPORTED_OPTION", Type: "number", FormatKinds: inputFormatKinds},
+	}
+	return caps
+}
+
+// getCapabilities returns the Capabilities of this build as JSON, so that
+// the UI can build its controls from what the Go side accepts
+func getCapabilities(string) (Response, error) {
+	b, err := json.Marshal(buildCapabilities())
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal capabilities: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
@@ -0,0 +1,36 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Charsets of the table formats. Tree connectors are always drawn with
+// ASCII, and table borders per the borderStyle option; "ascii" also replaces
+// the Unicode decorations added to the table, such as latency bars and
+// annotation markers.
+const (
+	charsetUnicode = "unicode"
+	charsetASCII   = "ascii"
+)
+
+// asciiDecorations maps each Unicode decoration to one ASCII character, so
+// that column widths are kept. Latency bar cells of at least half a
+// character become "=" and thinner ones "-".
+var asciiDecorations = strings.NewReplacer(
+	"█", "#",
+	"▉", "=", "▊", "=", "▋", "=", "▌", "=",
+	"▍", "-", "▎", "-", "▏", "-",
+	"…", "~",
+	annotationMarker, "> ",
+	collapsedMarker, "> ",
+)
+
+// checkCharset validates the charset parameter; "" is "unicode".
+func checkCharset(charset string) error {
+	switch charset {
+	case "", charsetUnicode, charsetASCII:
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid charset: %q (expected %q or %q)", charset, charsetUnicode, charsetASCII)}
+}
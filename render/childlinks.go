@@ -0,0 +1,130 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// Values accepted by the childLinks option
+const (
+	childLinksAll = "all"
+	// childLinksHideScalar hides the scalar subquery operators linked with
+	// type "Scalar" and attaches their inputs to the operator in their place
+	childLinksHideScalar = "hideScalar"
+	// childLinksRelational keeps only the links between relational operators,
+	// dropping scalar subqueries with their inputs
+	childLinksRelational = "relational"
+)
+
+func checkChildLinks(childLinks string) error {
+	switch childLinks {
+	case "", childLinksAll, childLinksHideScalar, childLinksRelational:
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid childLinks: %q (expected %q, %q, or %q)",
+		childLinks, childLinksAll, childLinksHideScalar, childLinksRelational)}
+}
+
+// isScalarSubqueryLink reports whether l links to a scalar node that the
+// renderers draw as an operator, as spannerplan does for links of type
+// "Scalar".
+func isScalarSubqueryLink(planNodes []*sppb.PlanNode, l *sppb.PlanNode_ChildLink) bool {
+	i := int(l.GetChildIndex())
+	return l.GetType() == "Scalar" && i < len(planNodes) && planNodes[i].GetKind() == sppb.PlanNode_SCALAR
+}
+
+// filterChildLinks returns planNodes with the scalar subquery links removed or
+// replaced by the inputs of the subqueries, as chosen by the childLinks
+// option. Node IDs do not change; removed nodes become unreachable. Changed
+// nodes are shallow copies.
+func filterChildLinks(planNodes []*sppb.PlanNode, childLinks string) []*sppb.PlanNode {
+	if childLinks == "" || childLinks == childLinksAll {
+		return planNodes
+	}
+	// splice returns the links that replace l, following nested scalar
+	// subqueries. onPath guards against cyclic plans kept by recover.
+	onPath := make([]bool, len(planNodes))
+	var splice func(l *sppb.PlanNode_ChildLink) []*sppb.PlanNode_ChildLink
+	splice = func(l *sppb.PlanNode_ChildLink) []*sppb.PlanNode_ChildLink {
+		if !isScalarSubqueryLink(planNodes, l) {
+			return []*sppb.PlanNode_ChildLink{l}
+		}
+		if childLinks == childLinksRelational || onPath[l.GetChildIndex()] {
+			return nil
+		}
+		onPath[l.GetChildIndex()] = true
+		defer func() { onPath[l.GetChildIndex()] = false }()
+		var links []*sppb.PlanNode_ChildLink
+		for _, cl := range planNodes[l.GetChildIndex()].GetChildLinks() {
+			i := int(cl.GetChildIndex())
+			if i < len(planNodes) && planNodes[i].GetKind() == sppb.PlanNode_RELATIONAL || isScalarSubqueryLink(planNodes, cl) {
+				links = append(links, splice(cl)...)
+			}
+		}
+		return links
+	}
+
+	filtered := make([]*sppb.PlanNode, len(planNodes))
+	for i, node := range planNodes {
+		filtered[i] = node
+		var links []*sppb.PlanNode_ChildLink
+		changed := false
+		for _, l := range node.GetChildLinks() {
+			if isScalarSubqueryLink(planNodes, l) {
+				changed = true
+			}
+			links = append(links, splice(l)...)
+		}
+		if changed {
+			filtered[i] = withChildLinks(node, links)
+		}
+	}
+	return filtered
+}
+
+// labelChildLinks returns planNodes with the relational links typed with
+// their link type and variable, e.g. "[Map $v1]", for display only: analyses
+// that read link types must use the original nodes. Apply inputs keep the
+// "Input" label that spannerplan gives them. Scalar subquery links keep their
+// type, which spannerplan draws them by.
+func labelChildLinks(planNodes []*sppb.PlanNode) []*sppb.PlanNode {
+	labeled := make([]*sppb.PlanNode, len(planNodes))
+	for i, node := range planNodes {
+		labeled[i] = node
+		var links []*sppb.PlanNode_ChildLink
+		changed := false
+		for j, l := range node.GetChildLinks() {
+			c := int(l.GetChildIndex())
+			relational := c < len(planNodes) && planNodes[c].GetKind() == sppb.PlanNode_RELATIONAL
+			if relational && l.GetVariable() != "" {
+				linkType := l.GetType()
+				if linkType == "" && j == 0 && hasOperatorSuffix(node.GetDisplayName(), "Apply") {
+					linkType = "Input"
+				}
+				linkType = strings.TrimSpace(linkType + " $" + l.GetVariable())
+				l = &sppb.PlanNode_ChildLink{ChildIndex: l.GetChildIndex(), Type: linkType, Variable: l.GetVariable()}
+				changed = true
+			}
+			links = append(links, l)
+		}
+		if changed {
+			labeled[i] = withChildLinks(node, links)
+		}
+	}
+	return labeled
+}
+
+// withChildLinks returns a shallow copy of node with links.
+func withChildLinks(node *sppb.PlanNode, links []*sppb.PlanNode_ChildLink) *sppb.PlanNode {
+	return &sppb.PlanNode{
+		Index:               node.GetIndex(),
+		Kind:                node.GetKind(),
+		DisplayName:         node.GetDisplayName(),
+		ChildLinks:          links,
+		ShortRepresentation: node.GetShortRepresentation(),
+		Metadata:            node.GetMetadata(),
+		ExecutionStats:      node.GetExecutionStats(),
+	}
+}
@@ -0,0 +1,186 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// chunkHandlePrefix starts every chunked result handle, e.g. "chunks-1".
+const chunkHandlePrefix = "chunks-"
+
+// maxPendingChunkedResults bounds the chunked results kept for nextChunk, so
+// that results abandoned without releaseChunks do not accumulate. The oldest
+// is dropped first.
+const maxPendingChunkedResults = 8
+
+// ChunkInfo describes the chunk in Result of a chunked response
+type ChunkInfo struct {
+	// Handle reads the next chunk with nextChunk; it is released after the
+	// last chunk
+	Handle string `json:"handle"`
+	// Index is the index of this chunk, from 0
+	Index      int `json:"index"`
+	Count      int `json:"count"`
+	TotalBytes int `json:"totalBytes"`
+}
+
+// chunkedResult is an output being read chunk by chunk.
+type chunkedResult struct {
+	chunks     []string
+	next       int
+	totalBytes int
+	// hash is the resultHash of the whole output
+	hash string
+}
+
+// chunkStore holds the chunked results with unread chunks, keyed by handle.
+// Callers pull chunks with nextChunk when they are ready to display them, so
+// a slow consumer never has more than one chunk in flight.
+type chunkStore struct {
+	mu      sync.Mutex
+	nextID  int
+	results map[string]*chunkedResult
+}
+
+var pendingChunks = &chunkStore{nextID: 1, results: make(map[string]*chunkedResult)}
+
+type chunkHandleParams struct {
+	Handle string `json:"handle"`
+}
+
+// splitChunks splits s into chunks of at most size bytes, at line ends where
+// possible and otherwise between runes, so that every chunk is valid UTF-8.
+func splitChunks(s string, size int) []string {
+	var chunks []string
+	for len(s) > size {
+		cut := strings.LastIndexByte(s[:size], '\n') + 1
+		if cut == 0 {
+			cut = size
+			for cut > 0 && !utf8.RuneStart(s[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				// A rune longer than size still makes progress
+				_, cut = utf8.DecodeRuneInString(s)
+			}
+		}
+		chunks = append(chunks, s[:cut])
+		s = s[cut:]
+	}
+	if s != "" {
+		chunks = append(chunks, s)
+	}
+	return chunks
+}
+
+// add stores a chunked result and returns its handle.
+func (s *chunkStore) add(result *chunkedResult) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.results) >= maxPendingChunkedResults {
+		oldest := ""
+		for handle := range s.results {
+			if oldest == "" || chunkHandleNumber(handle) < chunkHandleNumber(oldest) {
+				oldest = handle
+			}
+		}
+		delete(s.results, oldest)
+	}
+	handle := chunkHandlePrefix + strconv.Itoa(s.nextID)
+	s.nextID++
+	s.results[handle] = result
+	return handle
+}
+
+// next returns the next chunk of the result with handle and its ChunkInfo,
+// releasing the result after its last chunk.
+func (s *chunkStore) next(handle string) (string, ChunkInfo, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[handle]
+	if !ok {
+		return "", ChunkInfo{}, "", InvalidParametersError{msg: fmt.Sprintf("Unknown chunked result: %q (it was read to the end, released, or evicted)", handle)}
+	}
+	info := ChunkInfo{Handle: handle, Index: result.next, Count: len(result.chunks), TotalBytes: result.totalBytes}
+	chunk := result.chunks[result.next]
+	result.next++
+	if result.next == len(result.chunks) {
+		delete(s.results, handle)
+	}
+	return chunk, info, result.hash, nil
+}
+
+func (s *chunkStore) release(handle string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.results[handle]; !ok {
+		return InvalidParametersError{msg: fmt.Sprintf("Unknown chunked result: %q (it was read to the end, released, or evicted)", handle)}
+	}
+	delete(s.results, handle)
+	return nil
+}
+
+// chunkHandleNumber returns the number of a handle, which orders results by
+// age.
+func chunkHandleNumber(handle string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(handle, chunkHandlePrefix))
+	return n
+}
+
+// renderChunked renders with renderASCII and, when the output is larger than
+// par.ChunkSize bytes, returns its first chunk with a handle for the rest.
+// ResultHash is the hash of the whole output in every chunk.
+func renderChunked(par params) (Response, error) {
+	size := par.ChunkSize
+	if size < 0 {
+		return Response{}, InvalidParametersError{msg: fmt.Sprintf("Invalid chunkSize: %d (must not be negative)", size)}
+	}
+	par.ChunkSize = 0
+	resp, err := renderASCIIImpl(par)
+	if err != nil || len(resp.Result) <= size {
+		return resp, err
+	}
+
+	result := &chunkedResult{
+		chunks:     splitChunks(resp.Result, size),
+		totalBytes: len(resp.Result),
+		hash:       resultHash(resp.Result),
+	}
+	handle := pendingChunks.add(result)
+	chunk, info, hash, err := pendingChunks.next(handle)
+	if err != nil {
+		return Response{}, err
+	}
+	resp.Result, resp.ResultHash, resp.Chunks = chunk, hash, &info
+	return resp, nil
+}
+
+// nextChunk returns the next chunk of a chunked renderASCII output
+func nextChunk(paramsJSON string) (Response, error) {
+	par := chunkHandleParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	chunk, info, hash, err := pendingChunks.next(par.Handle)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Result: chunk, ResultHash: hash, Chunks: &info}, nil
+}
+
+// releaseChunks drops the unread chunks of a chunked output, e.g. when the
+// user rendered again before it was displayed
+func releaseChunks(paramsJSON string) (Response, error) {
+	par := chunkHandleParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	if err := pendingChunks.release(par.Handle); err != nil {
+		return Response{}, err
+	}
+	return Response{}, nil
+}
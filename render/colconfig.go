@@ -0,0 +1,186 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/olekukonko/tablewriter/pkg/twwidth"
+)
+
+// Alignments of the columnConfig option
+const (
+	alignLeft  = "left"
+	alignRight = "right"
+)
+
+// ellipsis ends cells cut by the truncate setting of columnConfig.
+const ellipsis = "…"
+
+// ColumnConfig is the layout of a table column in the columnConfig option.
+type ColumnConfig struct {
+	// MaxWidth cuts longer cells at the width; Truncate also ends them in an
+	// ellipsis
+	MaxWidth int `json:"maxWidth,omitempty"`
+	Truncate int `json:"truncate,omitempty"`
+	// Align is "left" or "right"; by default cells keep the alignment of the
+	// table writer
+	Align string `json:"align,omitempty"`
+}
+
+func (c ColumnConfig) check(header string) error {
+	switch {
+	case c.MaxWidth < 0 || c.Truncate < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid columnConfig for %q: widths must not be negative", header)}
+	case c.MaxWidth > 0 && c.Truncate > 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid columnConfig for %q: set maxWidth or truncate, not both", header)}
+	case c.Align != "" && c.Align != alignLeft && c.Align != alignRight:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid columnConfig for %q: align %q (expected %q or %q)", header, c.Align, alignLeft, alignRight)}
+	case c.Align != "" && header == "Operator":
+		return InvalidParametersError{msg: "Invalid columnConfig for \"Operator\": the operator tree cannot be aligned"}
+	}
+	return nil
+}
+
+// limit returns the maximum width of the column's text, or 0 for none.
+func (c ColumnConfig) limit() int {
+	return max(c.MaxWidth, c.Truncate)
+}
+
+// resolveColumnConfig returns the configs by column header, resolving names
+// like the columns option. extra are the titles of template columns.
+func resolveColumnConfig(configs map[string]ColumnConfig, extra []string) (map[string]ColumnConfig, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	names := sortedKeys(configs)
+	headers, err := resolveColumns(names, extra)
+	if err != nil {
+		return nil, err
+	}
+	resolved := make(map[string]ColumnConfig, len(headers))
+	for i, header := range headers {
+		c := configs[names[i]]
+		if err := c.check(header); err != nil {
+			return nil, err
+		}
+		resolved[header] = c
+	}
+	return resolved, nil
+}
+
+// applyColumnConfig narrows, truncates, and aligns the configured columns of
+// the table at the start of rendered. Columns the table does not have are
+// skipped, so that one config suits every mode. The Operator cells keep their
+// leading tree connectors; other cells are realigned by Align, or keep their
+// alignment.
+func applyColumnConfig(rendered string, configs map[string]ColumnConfig) string {
+	if len(configs) == 0 {
+		return rendered
+	}
+	return relayColumns(rendered, func(title string, width int) cellLayout {
+		config, ok := configs[title]
+		if !ok {
+			return nil
+		}
+		return config.layout(title == "Operator", width)
+	})
+}
+
+// tableLine says which line of a table a cell is on.
+type tableLine struct {
+	border bool
+	// header is set on the line with the column titles
+	header bool
+	// id is the node ID of the first lines of operator rows, where row is
+	// true
+	id  int32
+	row bool
+}
+
+// cellLayout returns the new text of a cell, including its padding, or the
+// new border segment of its column.
+type cellLayout func(cell string, line tableLine) string
+
+// relayColumns rewrites the columns of the table at the start of rendered
+// that layout returns a cellLayout for, given their title and text width.
+// Annotation lines keep their text after the cells before them. Lines after
+// the table are unchanged.
+func relayColumns(rendered string, layout func(title string, width int) cellLayout) string {
+	lines := strings.Split(rendered, "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "+") || !strings.HasPrefix(lines[1], "|") {
+		return rendered
+	}
+	seps := borderSeparators(lines[0])
+	cells := make([]cellLayout, len(seps)-1)
+	for c := range cells {
+		title, ok := cellText(lines[1], seps[c], seps[c+1])
+		if !ok {
+			return rendered
+		}
+		cells[c] = layout(strings.TrimSpace(title), seps[c+1]-seps[c]-3)
+	}
+
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "|") {
+			break
+		}
+		kind := tableLine{border: line[0] == '+', header: i == 1}
+		if !kind.border && i > 1 {
+			kind.id, _, kind.row = tableRowID(line)
+		}
+		var b strings.Builder
+		b.WriteByte(line[0])
+		// l is the byte offset of the separator before column c
+		c, l := 0, 0
+		for ; c < len(cells); c++ {
+			r, ok := columnOffset(line, seps[c+1])
+			if !ok || line[r] != line[0] {
+				break
+			}
+			cell := line[l+1 : r]
+			if cells[c] != nil {
+				cell = cells[c](cell, kind)
+			}
+			b.WriteString(cell)
+			b.WriteByte(line[r])
+			l = r
+		}
+		if c < len(cells) {
+			b.WriteString(line[l+1:])
+		}
+		lines[i] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// layout returns the cellLayout of a column whose text is width wide.
+func (c ColumnConfig) layout(operator bool, width int) cellLayout {
+	if limit := c.limit(); limit > 0 {
+		width = min(width, limit)
+	}
+	return func(cell string, line tableLine) string {
+		if line.border {
+			return strings.Repeat("-", width+2)
+		}
+		text := strings.TrimRight(strings.TrimPrefix(cell, " "), " ")
+		align := c.Align
+		if !operator {
+			if align == "" && strings.HasPrefix(text, " ") {
+				align = alignRight
+			}
+			text = strings.TrimLeft(text, " ")
+		}
+		if displayWidth(text) > width {
+			if c.Truncate > 0 {
+				text = twwidth.Truncate(text, width, ellipsis)
+			} else {
+				text = twwidth.Truncate(text, width)
+			}
+		}
+		pad := strings.Repeat(" ", width-displayWidth(text))
+		if align == alignRight {
+			return " " + pad + text + " "
+		}
+		return " " + text + pad + " "
+	}
+}
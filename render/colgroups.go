@@ -0,0 +1,152 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// ColumnGroup is a super-header spanning adjacent table columns, such as
+// "Execution" over Rows, Exec., and Total Latency.
+type ColumnGroup struct {
+	Title string `json:"title"`
+	// Columns are the headers of the spanned columns, matched
+	// case-insensitively
+	Columns []string `json:"columns"`
+}
+
+// checkColumnGroups validates the groups before rendering. Whether the
+// columns exist and are adjacent is only known from the rendered table.
+func checkColumnGroups(groups []ColumnGroup) error {
+	seen := make(map[string]bool)
+	for i, g := range groups {
+		if strings.TrimSpace(g.Title) == "" {
+			return InvalidParametersError{msg: fmt.Sprintf("Column group %d has no title", i)}
+		}
+		if len(g.Columns) == 0 {
+			return InvalidParametersError{msg: fmt.Sprintf("Column group %q has no columns", g.Title)}
+		}
+		for _, c := range g.Columns {
+			key := strings.ToLower(strings.TrimSpace(c))
+			if seen[key] {
+				return InvalidParametersError{msg: fmt.Sprintf("Column %q is in more than one column group", c)}
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
+
+// addColumnGroups inserts a group header row above the header line of the
+// table at the start of a rendered plan:
+//
+//	+----+----------+------+-------+---------------+
+//	|    |          |          Execution           |
+//	|    |          +------+-------+---------------+
+//	| ID | Operator | Rows | Exec. | Total Latency |
+//	+----+----------+------+-------+---------------+
+//
+// Only the top border and the header line, which are plain ASCII, are read,
+// so operator rows are unchanged. Titles wider than their columns are
+// truncated.
+func addColumnGroups(rendered string, groups []ColumnGroup) (string, error) {
+	if len(groups) == 0 {
+		return rendered, nil
+	}
+	lines := strings.SplitN(rendered, "\n", 3)
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "+") || len(lines[1]) != len(lines[0]) {
+		return rendered, nil
+	}
+	border, header := lines[0], lines[1]
+
+	// Column i spans the bytes between borders[i] and borders[i+1]
+	var borders []int
+	for i := range len(border) {
+		if border[i] == '+' {
+			borders = append(borders, i)
+		}
+	}
+	headers := make([]string, len(borders)-1)
+	for i := range headers {
+		headers[i] = strings.TrimSpace(header[borders[i]+1 : borders[i+1]])
+	}
+
+	groupOf, err := columnGroupSpans(headers, groups)
+	if err != nil {
+		return "", err
+	}
+
+	var row, rule strings.Builder
+	row.WriteByte('|')
+	for i := 0; i < len(headers); {
+		gi := groupOf[i]
+		if gi < 0 {
+			row.WriteString(strings.Repeat(" ", borders[i+1]-borders[i]-1) + "|")
+			i++
+			continue
+		}
+		end := i
+		for end < len(headers) && groupOf[end] == gi {
+			end++
+		}
+		row.WriteString(centerCell(groups[gi].Title, borders[end]-borders[i]-1) + "|")
+		i = end
+	}
+	// The rule under the group row only runs under grouped columns
+	ruleBorder := func(i int) byte {
+		if i > 0 && groupOf[i-1] >= 0 || i < len(headers) && groupOf[i] >= 0 {
+			return '+'
+		}
+		return '|'
+	}
+	rule.WriteByte(ruleBorder(0))
+	for i := range headers {
+		fill := " "
+		if groupOf[i] >= 0 {
+			fill = "-"
+		}
+		rule.WriteString(strings.Repeat(fill, borders[i+1]-borders[i]-1))
+		rule.WriteByte(ruleBorder(i + 1))
+	}
+	return strings.Join(append([]string{border, row.String(), rule.String()}, lines[1:]...), "\n"), nil
+}
+
+// columnGroupSpans returns the index of the group spanning each of the
+// columns with the given headers, or -1. Unknown and non-adjacent columns are
+// errors.
+func columnGroupSpans(headers []string, groups []ColumnGroup) ([]int, error) {
+	groupOf := make([]int, len(headers))
+	for i := range groupOf {
+		groupOf[i] = -1
+	}
+	for gi, g := range groups {
+		var cols []int
+		for _, c := range g.Columns {
+			i := slices.IndexFunc(headers, func(h string) bool { return strings.EqualFold(h, strings.TrimSpace(c)) })
+			if i < 0 {
+				return nil, InvalidParametersError{msg: fmt.Sprintf("Column group %q: unknown column %q (columns are %s)", g.Title, c, strings.Join(headers, ", "))}
+			}
+			cols = append(cols, i)
+		}
+		slices.Sort(cols)
+		if cols[len(cols)-1]-cols[0] != len(cols)-1 {
+			return nil, InvalidParametersError{msg: fmt.Sprintf("Column group %q: columns are not adjacent", g.Title)}
+		}
+		for _, i := range cols {
+			groupOf[i] = gi
+		}
+	}
+	return groupOf, nil
+}
+
+// centerCell centers title in a cell of width characters, keeping a space
+// on both sides.
+func centerCell(title string, width int) string {
+	title = strings.TrimSpace(title)
+	if n := utf8.RuneCountInString(title); n > width-2 {
+		title = string([]rune(title)[:max(width-3, 0)]) + "…"
+	}
+	pad := width - utf8.RuneCountInString(title)
+	return strings.Repeat(" ", pad/2) + title + strings.Repeat(" ", pad-pad/2)
+}
@@ -0,0 +1,147 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// WarningCodeUnknownCollapsedNode is reported for collapsedNodeIds that are
+// not operators of the plan.
+const WarningCodeUnknownCollapsedNode = "UNKNOWN_COLLAPSED_NODE"
+
+// collapsedMarker starts the operator title of a collapsed subtree.
+const collapsedMarker = "▶ "
+
+// collapseSubtrees removes the operators below each operator of ids from
+// planNodes for the collapsedNodeIds option, and returns the number of
+// operators hidden under each collapsed operator. Operators without inputs,
+// and operators in a subtree that is collapsed already, stay as they are. IDs
+// that are not operators reachable from the plan root are reported.
+func collapseSubtrees(planNodes []*sppb.PlanNode, ids []int32) ([]*sppb.PlanNode, map[int32]int, []Warning) {
+	if len(ids) == 0 {
+		return planNodes, nil, nil
+	}
+	tree := buildPlanTree(planNodes)
+	var warnings []Warning
+	requested := make(map[int32]bool, len(ids))
+	for _, id := range ids {
+		if _, err := subtreeRoot(tree, id); err != nil {
+			warnings = append(warnings, Warning{
+				Code:    WarningCodeUnknownCollapsedNode,
+				Message: fmt.Sprintf("No operator %d to collapse", id),
+				NodeID:  &id,
+			})
+			continue
+		}
+		requested[id] = true
+	}
+
+	planNodes = slices.Clone(planNodes)
+	hidden := make(map[int32]int)
+	var visit func(n *treeNode)
+	visit = func(n *treeNode) {
+		if requested[n.id()] {
+			count := -1
+			n.walk(func(*treeNode) { count++ })
+			if count > 0 {
+				planNodes[n.id()] = withoutRelationalInputs(n)
+				hidden[n.id()] = count
+				return
+			}
+		}
+		for _, child := range n.relationalChildren() {
+			visit(child)
+		}
+	}
+	visit(tree.root)
+	return planNodes, hidden, warnings
+}
+
+// setCollapsedRows sets the number of hidden operators on the rows of the
+// collapsed operators.
+func setCollapsedRows(rows []planRow, hidden map[int32]int) {
+	for i := range rows {
+		rows[i].Collapsed = hidden[rows[i].ID]
+	}
+}
+
+// collapsedSummary returns the summary after the title of a collapsed row,
+// e.g. "(+23 nodes, 120 msecs)", with the latency of the operator, which
+// includes the latency of the hidden operators.
+func collapsedSummary(row planRow) string {
+	summary := "+" + strconv.Itoa(row.Collapsed) + " nodes"
+	if row.Collapsed == 1 {
+		summary = "+1 node"
+	}
+	if latency, ok := row.Stats["latency"]; ok {
+		summary += ", " + strings.TrimSpace(latency.Total+" "+latency.Unit)
+	}
+	return "(" + summary + ")"
+}
+
+// markCollapsedRows marks the Operator cells of the collapsed rows of the
+// table at the start of rendered with collapsedMarker before the title and
+// the summary after it, on the last line of wrapped cells. The column is
+// widened if a marked cell does not fit. Lines after the table are unchanged.
+func markCollapsedRows(rendered string, summaries map[int32]string) string {
+	if len(summaries) == 0 {
+		return rendered
+	}
+	lines := strings.Split(rendered, "\n")
+	headEnd, left, right, ok := findOperatorColumn(lines)
+	if !ok {
+		return rendered
+	}
+	end := headEnd + 1
+	for end < len(lines) && (strings.HasPrefix(lines[end], "|") || strings.HasPrefix(lines[end], "+")) {
+		end++
+	}
+
+	// cells are the new Operator cells by line index, without padding
+	cells := make(map[int]string)
+	cell := func(i int) string {
+		if c, ok := cells[i]; ok {
+			return c
+		}
+		c, _ := cellText(lines[i], left, right)
+		return strings.TrimRight(c, " ")
+	}
+	for i := headEnd + 1; i < end; i++ {
+		id, _, ok := tableRowID(lines[i])
+		summary, collapsed := summaries[id]
+		if !ok || !collapsed {
+			continue
+		}
+		prefix, text := splitTreePrefix(cell(i))
+		cells[i] = prefix + collapsedMarker + text
+		last := i
+		for last+1 < end && strings.HasPrefix(lines[last+1], "|") {
+			if _, _, ok := tableRowID(lines[last+1]); ok {
+				break
+			}
+			if _, ok := cellText(lines[last+1], left, right); !ok {
+				break
+			}
+			last++
+		}
+		cells[last] = cell(last) + " " + summary
+	}
+
+	width := right - left - 1
+	extra := 0
+	for _, c := range cells {
+		extra = max(extra, displayWidth(c)+1-width)
+	}
+	if extra > 0 {
+		widenColumn(lines[:end], left, right, extra, false)
+		width += extra
+	}
+	for i, c := range cells {
+		lines[i] = replaceCell(lines[i], left, right+extra, c+strings.Repeat(" ", width-displayWidth(c)))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,165 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// WarningCodeColumnUnavailable is reported for selected columns that the
+// rendered table does not have, e.g. stats columns in PLAN mode.
+const WarningCodeColumnUnavailable = "COLUMN_UNAVAILABLE"
+
+// cpuColumnTitle is the header of the CPU time column.
+const cpuColumnTitle = "CPU"
+
+// tableColumn is a table column that the columns option can select.
+type tableColumn struct {
+	header      string
+	description string
+	// aliases are other accepted names, e.g. "Latency" for "Total Latency"
+	aliases []string
+}
+
+// selectableColumns are the columns of the table format: the columns of the
+// reference renderer, then the columns added here.
+var selectableColumns = []tableColumn{
+	{header: "ID", description: "Node ID"},
+	{header: "Operator", description: "Operator tree"},
+	{header: "Rows", description: "Returned rows"},
+	{header: "Exec.", description: "Number of executions", aliases: []string{"Exec", "Executions"}},
+	{header: "Total Latency", description: "Total latency", aliases: []string{"Latency"}},
+	{header: cpuColumnTitle, description: "Total CPU time", aliases: []string{"CPU Time"}},
+	{header: estimateColumnTitle, description: "Estimated and actual rows", aliases: []string{"Estimate"}},
+	{header: latencyBarColumnTitle, description: "Latency relative to the slowest operator"},
+	{header: timelineColumnTitle, description: "Estimated span of the operator within the query, as in the TIMELINE mode"},
+	{header: costColumnTitle, description: "Share of the self latency or CPU time of all operators, with * and !! on hot operators"},
+}
+
+// resolveColumns returns the headers of the named columns, matching headers
+// and aliases case-insensitively. extra are the titles of template columns.
+// All unknown names are reported at once so that typos can be fixed in one
+// pass.
+func resolveColumns(names []string, extra []string) ([]string, error) {
+	columns := slices.Clone(selectableColumns)
+	for _, title := range extra {
+		columns = append(columns, tableColumn{header: title})
+	}
+	headers := make([]string, 0, len(names))
+	var unknown []string
+	for _, name := range names {
+		i := slices.IndexFunc(columns, func(c tableColumn) bool {
+			return strings.EqualFold(c.header, strings.TrimSpace(name)) ||
+				slices.ContainsFunc(c.aliases, func(a string) bool { return strings.EqualFold(a, strings.TrimSpace(name)) })
+		})
+		if i < 0 {
+			unknown = append(unknown, fmt.Sprintf("%q", name))
+			continue
+		}
+		header := columns[i].header
+		if slices.Contains(headers, header) {
+			return nil, InvalidParametersError{msg: fmt.Sprintf("Column %q is selected more than once", header)}
+		}
+		headers = append(headers, header)
+	}
+	if len(unknown) > 0 {
+		known := make([]string, len(columns))
+		for i, c := range columns {
+			known[i] = c.header
+		}
+		return nil, InvalidParametersError{msg: fmt.Sprintf("Unknown columns: %s (columns are %s)",
+			strings.Join(unknown, ", "), strings.Join(known, ", "))}
+	}
+	return headers, nil
+}
+
+// applyCPUColumn appends a column with the total CPU time of each operator.
+// Plans without CPU time stats are left unchanged.
+func applyCPUColumn(rendered string, tree *planTree) string {
+	cells := make(map[int32]string)
+	tree.root.walk(func(n *treeNode) {
+		if _, ok := n.stat("cpu_time"); ok {
+			total := valueString(n.node.GetExecutionStats().GetFields()["cpu_time"].GetStructValue().GetFields()["total"])
+			cells[n.id()] = strings.TrimSpace(total + " " + n.statUnit("cpu_time"))
+		}
+	})
+	if len(cells) == 0 {
+		return rendered
+	}
+	return appendTableColumn(rendered, cpuColumnTitle, cells)
+}
+
+// selectTableColumns keeps the given columns of the table at the start of a
+// rendered plan, in the given order. Every cell is padded to its column width
+// by the table writer, so cells are moved as they are. Only the Operator
+// column may contain "|", so extra separators on a line are kept in it.
+// Annotation lines keep their text after an empty first cell. Columns the
+// table does not have are skipped with a warning. Lines after the table are
+// unchanged.
+func selectTableColumns(rendered string, columns []string) (string, []Warning) {
+	lines := strings.Split(rendered, "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "+") || !strings.HasPrefix(lines[1], "|") {
+		return rendered, nil
+	}
+	borders := strings.Split(lines[0], "+")
+	borders = borders[1 : len(borders)-1]
+	headerCells := strings.Split(lines[1], "|")
+	if len(headerCells) != len(borders)+2 {
+		return rendered, nil
+	}
+	headers := make([]string, len(borders))
+	for i := range headers {
+		headers[i] = strings.TrimSpace(headerCells[i+1])
+	}
+	operator := slices.Index(headers, "Operator")
+
+	var warnings []Warning
+	var order []int
+	for _, c := range columns {
+		i := slices.Index(headers, c)
+		if i < 0 {
+			warnings = append(warnings, Warning{
+				Code:    WarningCodeColumnUnavailable,
+				Message: fmt.Sprintf("Column %q is not available for this plan and mode", c),
+			})
+			continue
+		}
+		order = append(order, i)
+	}
+	if len(order) == 0 {
+		return rendered, warnings
+	}
+
+	for li, line := range lines {
+		var sep string
+		var cells []string
+		switch {
+		case strings.HasPrefix(line, "+"):
+			sep, cells = "+", strings.Split(line, "+")
+			cells = cells[1 : len(cells)-1]
+		case strings.HasPrefix(line, "|"):
+			sep, cells = "|", strings.Split(line, "|")
+			if rest := strings.TrimPrefix(line, "|"+cells[1]); strings.HasPrefix(rest, "| "+annotationMarker) {
+				lines[li] = "|" + strings.Repeat(" ", len(borders[order[0]])) + rest
+				continue
+			}
+			cells = cells[1 : len(cells)-1]
+			if extra := len(cells) - len(borders); extra > 0 && operator >= 0 {
+				merged := strings.Join(cells[operator:operator+extra+1], "|")
+				cells = slices.Replace(cells, operator, operator+extra+1, merged)
+			}
+		default:
+			return strings.Join(lines, "\n"), warnings
+		}
+		if len(cells) != len(borders) {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString(sep)
+		for _, i := range order {
+			b.WriteString(cells[i] + sep)
+		}
+		lines[li] = b.String()
+	}
+	return strings.Join(lines, "\n"), warnings
+}
@@ -0,0 +1,82 @@
+package render
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// maxDecompressedSize bounds the size of decompressed input, so that a small
+// compressed blob cannot exhaust the memory of the page.
+const maxDecompressedSize = 256 << 20
+
+// Magic numbers of compressed input, and their prefixes once base64-encoded
+const (
+	gzipMagic       = "\x1f\x8b"
+	gzipBase64Magic = "H4sI"
+	zstdMagic       = "\x28\xb5\x2f\xfd"
+	zstdBase64Magic = "KLUv/"
+)
+
+// compressedPayload returns the compressed bytes of gzip or zstd input, raw
+// or base64-encoded as tooling stores plans, and whether input is
+// compressed. Base64 is only decoded after its prefix matches a magic
+// number, so that other input is not decoded twice.
+func compressedPayload(input string) ([]byte, bool) {
+	if strings.HasPrefix(input, gzipMagic) || strings.HasPrefix(input, zstdMagic) {
+		return []byte(input), true
+	}
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasPrefix(trimmed, gzipBase64Magic) && !strings.HasPrefix(trimmed, zstdBase64Magic) || !looksLikeProtoBase64(trimmed) {
+		return nil, false
+	}
+	b, err := decodeBase64(trimmed)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// decompressInput decompresses gzip data. zstd is detected to report that it
+// is not supported, as the standard library has no decoder.
+func decompressInput(b []byte) ([]byte, error) {
+	if bytes.HasPrefix(b, []byte(zstdMagic)) {
+		return nil, errors.New("zstd-compressed input is not supported; use gzip or decompress it first")
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip input: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip input: %w", err)
+	}
+	if len(data) > maxDecompressedSize {
+		return nil, fmt.Errorf("decompressed input exceeds %d MiB", maxDecompressedSize>>20)
+	}
+	return data, nil
+}
+
+// parseCompressedQueryPlan decompresses b and parses the plan inside: text
+// formats go through the input sniffer, anything else is taken as binary
+// protobuf. The detected format is that of the decompressed plan.
+func parseCompressedQueryPlan(b []byte) (*sppb.ResultSetStats, *sppb.StructType, string, error) {
+	data, err := decompressInput(b)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if utf8.Valid(data) {
+		return parseQueryPlan(string(data))
+	}
+	stats, rowType, err := extractQueryPlanProtoBinary(data)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return stats, rowType, inputFormatProtoBase64, nil
+}
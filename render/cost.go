@@ -0,0 +1,188 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// costColumnTitle is the header of the cost column.
+const costColumnTitle = "Cost"
+
+// Metrics of the cost option
+const (
+	costMetricLatency = "latency"
+	costMetricCPU     = "cpu"
+)
+
+// Default shares from which operators are marked, and their markers
+const (
+	defaultHotCostShare      = 0.1
+	defaultCriticalCostShare = 0.3
+	hotCostMarker            = "*"
+	criticalCostMarker       = "!!"
+)
+
+// CostOptions configure the cost option. Zero fields use the defaults.
+type CostOptions struct {
+	// Metric is "latency" (the default) or "cpu"
+	Metric string `json:"metric,omitempty"`
+	// Hot and Critical are the shares from which operators get the "*" and
+	// "!!" markers
+	Hot      float64 `json:"hot,omitempty"`
+	Critical float64 `json:"critical,omitempty"`
+}
+
+// NodeCost is the relative cost of an operator, returned in Response.Costs
+type NodeCost struct {
+	NodeID int32 `json:"nodeId"`
+	// LatencyShare and CPUShare are the operator's share of the self time of
+	// all operators, from 0 to 1. Self time excludes the time of the
+	// operator's relational inputs.
+	LatencyShare *float64 `json:"latencyShare,omitempty"`
+	CPUShare     *float64 `json:"cpuShare,omitempty"`
+	// Marker is "*" for hot and "!!" for critical operators by the share of
+	// the chosen metric, or empty
+	Marker string `json:"marker,omitempty"`
+}
+
+func (o CostOptions) check() error {
+	switch {
+	case o.Metric != "" && o.Metric != costMetricLatency && o.Metric != costMetricCPU:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid cost metric: %q (expected %q or %q)", o.Metric, costMetricLatency, costMetricCPU)}
+	case o.Hot < 0 || o.Hot > 1:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid hot cost share: %v (must be between 0 and 1)", o.Hot)}
+	case o.Critical < 0 || o.Critical > 1:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid critical cost share: %v (must be between 0 and 1)", o.Critical)}
+	}
+	if d := o.withDefaults(); d.Critical < d.Hot {
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid cost shares: critical %v is less than hot %v", d.Critical, d.Hot)}
+	}
+	return nil
+}
+
+func (o CostOptions) withDefaults() CostOptions {
+	if o.Metric == "" {
+		o.Metric = costMetricLatency
+	}
+	if o.Hot == 0 {
+		o.Hot = defaultHotCostShare
+	}
+	if o.Critical == 0 {
+		o.Critical = defaultCriticalCostShare
+	}
+	return o
+}
+
+// selfTimes returns each operator's self time in milliseconds by the duration
+// stat name: its time minus the time of its relational inputs. Inputs that
+// run in parallel can take longer than their parent, so self times are
+// clamped at zero.
+func selfTimes(tree *planTree, name string) map[int32]float64 {
+	self := make(map[int32]float64)
+	tree.root.walk(func(n *treeNode) {
+		v, ok := n.durationMillis(name)
+		if !ok {
+			return
+		}
+		for _, child := range n.relationalChildren() {
+			if c, ok := child.durationMillis(name); ok {
+				v -= c
+			}
+		}
+		self[n.id()] = max(v, 0)
+	})
+	return self
+}
+
+// selfTimeShares returns each operator's share of the total self time by the
+// duration stat name, or nil if no operator has the stat.
+func selfTimeShares(tree *planTree, name string) map[int32]float64 {
+	self := selfTimes(tree, name)
+	if len(self) == 0 {
+		return nil
+	}
+	var total float64
+	for _, v := range self {
+		total += v
+	}
+	for id, v := range self {
+		if total > 0 {
+			self[id] = v / total
+		} else {
+			self[id] = 0
+		}
+	}
+	return self
+}
+
+// nodeCosts returns the NodeCost of every operator with latency or CPU time
+// stats in pre-order, or nil for plans without them.
+func nodeCosts(tree *planTree, opts CostOptions) []NodeCost {
+	latency := selfTimeShares(tree, "latency")
+	cpu := selfTimeShares(tree, "cpu_time")
+	if latency == nil && cpu == nil {
+		return nil
+	}
+	shares := latency
+	if opts.Metric == costMetricCPU {
+		shares = cpu
+	}
+
+	var costs []NodeCost
+	tree.root.walk(func(n *treeNode) {
+		c := NodeCost{NodeID: n.id()}
+		if v, ok := latency[n.id()]; ok {
+			c.LatencyShare = &v
+		}
+		if v, ok := cpu[n.id()]; ok {
+			c.CPUShare = &v
+		}
+		if c.LatencyShare == nil && c.CPUShare == nil {
+			return
+		}
+		if share, ok := shares[n.id()]; ok {
+			c.Marker = costMarker(share, opts)
+		}
+		costs = append(costs, c)
+	})
+	return costs
+}
+
+func costMarker(share float64, opts CostOptions) string {
+	switch {
+	case share >= opts.Critical:
+		return criticalCostMarker
+	case share >= opts.Hot:
+		return hotCostMarker
+	}
+	return ""
+}
+
+// costCell formats the share of the chosen metric with its marker, e.g.
+// "35.2% !!", or "" if the operator has no share of the metric.
+func costCell(c NodeCost, opts CostOptions) string {
+	share := c.LatencyShare
+	if opts.Metric == costMetricCPU {
+		share = c.CPUShare
+	}
+	if share == nil {
+		return ""
+	}
+	cell := strconv.FormatFloat(*share*100, 'f', 1, 64) + "%"
+	if c.Marker != "" {
+		cell += " " + c.Marker
+	}
+	return cell
+}
+
+// applyCostColumn appends the cost column to the rendered table.
+func applyCostColumn(rendered string, costs []NodeCost, opts CostOptions) string {
+	if len(costs) == 0 {
+		return rendered
+	}
+	cells := make(map[int32]string, len(costs))
+	for _, c := range costs {
+		cells[c.NodeID] = costCell(c, opts)
+	}
+	return appendTableColumn(rendered, costColumnTitle, cells)
+}
@@ -0,0 +1,77 @@
+package render
+
+import (
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// ResponseMetadata carries facts about the rendered plan alongside the result
+type ResponseMetadata struct {
+	Counts PlanCounts `json:"counts"`
+	// DetectedFormat is the input format that was understood, e.g.
+	// "json-rest" or "yaml", when known
+	DetectedFormat string `json:"detectedFormat,omitempty"`
+	// PlanCount is the number of plans of inputs with more than one, such as
+	// batch DML responses
+	PlanCount int `json:"planCount,omitempty"`
+	// DML describes the mutations of DML plans
+	DML *DMLSummary `json:"dml,omitempty"`
+	// StatsFields are the keys of the numeric execution stats of the plan,
+	// which the statsFields option can add as columns
+	StatsFields []string `json:"statsFields,omitempty"`
+}
+
+// PlanCounts are lightweight plan statistics for badges in the UI.
+type PlanCounts struct {
+	TotalNodes      int `json:"totalNodes"`
+	RelationalNodes int `json:"relationalNodes"`
+	ScalarNodes     int `json:"scalarNodes"`
+	// LeafScans counts scan operators without relational inputs
+	LeafScans int `json:"leafScans"`
+	// DistributedOperators counts Distributed Union, Distributed Cross Apply,
+	// and the other distributed operators
+	DistributedOperators int  `json:"distributedOperators"`
+	HasExecutionStats    bool `json:"hasExecutionStats"`
+}
+
+// countPlanNodes computes PlanCounts. Names are matched case-insensitively so
+// that the counts are the same with and without console naming.
+func countPlanNodes(planNodes []*sppb.PlanNode) PlanCounts {
+	var counts PlanCounts
+	for _, node := range planNodes {
+		counts.TotalNodes++
+		if len(node.GetExecutionStats().GetFields()) > 0 {
+			counts.HasExecutionStats = true
+		}
+		if node.GetKind() != sppb.PlanNode_RELATIONAL {
+			counts.ScalarNodes++
+			continue
+		}
+		counts.RelationalNodes++
+
+		name := strings.ToLower(node.GetDisplayName())
+		if strings.HasPrefix(name, "distributed ") {
+			counts.DistributedOperators++
+		}
+		if strings.Contains(name, "scan") && !hasRelationalChild(planNodes, node) {
+			counts.LeafScans++
+		}
+	}
+	return counts
+}
+
+func hasRelationalChild(planNodes []*sppb.PlanNode, node *sppb.PlanNode) bool {
+	for _, link := range node.GetChildLinks() {
+		i := int(link.GetChildIndex())
+		if i >= 0 && i < len(planNodes) && planNodes[i].GetKind() == sppb.PlanNode_RELATIONAL {
+			return true
+		}
+	}
+	return false
+}
+
+// planMetadata returns the response metadata for a rendered plan.
+func planMetadata(planNodes []*sppb.PlanNode) *ResponseMetadata {
+	return &ResponseMetadata{Counts: countPlanNodes(planNodes), StatsFields: discoverStatsFields(planNodes)}
+}
@@ -0,0 +1,126 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CriticalPathHop is one operator on the critical path.
+type CriticalPathHop struct {
+	NodeID   int32  `json:"nodeId"`
+	Operator string `json:"operator"`
+	// LatencyMillis is the latency of the operator, its inputs included
+	LatencyMillis float64 `json:"latencyMillis"`
+	// ContributionMillis is LatencyMillis minus the latency of the next hop,
+	// the time the path spends in this operator, rounded to nanoseconds
+	ContributionMillis float64 `json:"contributionMillis"`
+	// Share is ContributionMillis over the latency of the first hop
+	Share float64 `json:"share"`
+}
+
+// CriticalPath is returned by analyzeCriticalPath
+type CriticalPath struct {
+	// Hops runs from the plan root to a leaf operator, following the input
+	// with the highest latency at each operator; empty without latency stats
+	Hops          []CriticalPathHop `json:"hops"`
+	LatencyMillis float64           `json:"latencyMillis"`
+	// Text is the path rendered as a table, with the render option
+	Text string `json:"text,omitempty"`
+}
+
+type criticalPathParams struct {
+	Input   string `json:"input"`
+	Recover bool   `json:"recover,omitempty"`
+	Render  bool   `json:"render,omitempty"`
+}
+
+// buildCriticalPath follows the slowest relational input from the root until
+// an operator without inputs that have latency stats. Inputs that run in
+// parallel can take longer than their parent, so contributions are clamped
+// at zero.
+func buildCriticalPath(tree *planTree) CriticalPath {
+	path := CriticalPath{Hops: []CriticalPathHop{}}
+	n := tree.root
+	latency, ok := n.durationMillis("latency")
+	if !ok {
+		return path
+	}
+	path.LatencyMillis = latency
+	for n != nil {
+		var next *treeNode
+		var nextLatency float64
+		for _, c := range n.relationalChildren() {
+			if v, ok := c.durationMillis("latency"); ok && (next == nil || v > nextLatency) {
+				next, nextLatency = c, v
+			}
+		}
+		hop := CriticalPathHop{
+			NodeID:             n.id(),
+			Operator:           n.title(),
+			LatencyMillis:      latency,
+			ContributionMillis: math.Round(max(latency-nextLatency, 0)*1e6) / 1e6,
+		}
+		if path.LatencyMillis > 0 {
+			hop.Share = hop.ContributionMillis / path.LatencyMillis
+		}
+		path.Hops = append(path.Hops, hop)
+		n, latency = next, nextLatency
+	}
+	return path
+}
+
+// criticalPathText renders the hops of path, one per line, e.g.
+//
+//	Critical path: 2.41 msecs
+//	  ID  Latency      Contribution  Share  Operator
+//	   0  2.41 msecs   0.08 msecs     3.3%  Distributed Union
+func criticalPathText(path CriticalPath) string {
+	if len(path.Hops) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Critical path: %s\n", formatMillis(path.LatencyMillis))
+	idWidth := len("ID")
+	for _, h := range path.Hops {
+		idWidth = max(idWidth, len(strconv.Itoa(int(h.NodeID))))
+	}
+	fmt.Fprintf(&b, "  %*s  %-11s  %-12s  %5s  %s\n", idWidth, "ID", "Latency", "Contribution", "Share", "Operator")
+	for _, h := range path.Hops {
+		fmt.Fprintf(&b, "  %*d  %-11s  %-12s  %5s  %s\n", idWidth, h.NodeID,
+			formatMillis(h.LatencyMillis), formatMillis(h.ContributionMillis),
+			strconv.FormatFloat(h.Share*100, 'f', 1, 64)+"%", h.Operator)
+	}
+	return b.String()
+}
+
+// analyzeCriticalPath returns the longest-latency path from the root to a
+// leaf operator as JSON
+func analyzeCriticalPath(paramsJSON string) (Response, error) {
+	par := criticalPathParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return analyzeCriticalPathImpl(par)
+}
+
+func analyzeCriticalPathImpl(par criticalPathParams) (Response, error) {
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	path := buildCriticalPath(buildPlanTree(stats.GetQueryPlan().GetPlanNodes()))
+	if len(path.Hops) == 0 {
+		warnings = append(warnings, Warning{Code: WarningCodeNoLatencyStats, Message: "The input has no latency stats; the critical path is empty"})
+	}
+	if par.Render {
+		path.Text = criticalPathText(path)
+	}
+	b, err := json.Marshal(path)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal critical path: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
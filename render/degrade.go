@@ -0,0 +1,218 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan/plantree/reference"
+)
+
+// WarningCodeRenderDegraded is reported when output was reduced to fit the
+// renderLimits option.
+const WarningCodeRenderDegraded = "RENDER_DEGRADED"
+
+// Degradation levels reported in Response.Degradation, from the most to the
+// least detailed
+const (
+	degradationFull      = "full"
+	degradationCollapsed = "collapsed"
+	degradationTreeOnly  = "tree-only"
+	degradationSummary   = "summary"
+)
+
+// RenderLimits bounds the output of renderASCII. Zero fields are unlimited.
+type RenderLimits struct {
+	MaxBytes int `json:"maxBytes,omitempty"`
+	MaxLines int `json:"maxLines,omitempty"`
+	// MaxMillis bounds the time spent looking for output that fits; once
+	// exceeded, the summary is returned
+	MaxMillis int `json:"maxMillis,omitempty"`
+}
+
+func (l RenderLimits) check() error {
+	if l.MaxBytes < 0 || l.MaxLines < 0 || l.MaxMillis < 0 {
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid renderLimits: %+v (must not be negative)", l)}
+	}
+	return nil
+}
+
+func (l RenderLimits) fits(s string) bool {
+	return (l.MaxBytes == 0 || len(s) <= l.MaxBytes) &&
+		(l.MaxLines == 0 || strings.Count(strings.TrimSuffix(s, "\n"), "\n")+1 <= l.MaxLines)
+}
+
+// renderWithinLimits renders with the degradation ladder: the full render,
+// then deep subtrees collapsed, then the operator tree alone without stats,
+// appendices, or added columns, collapsed if need be, and finally a summary.
+// The first level whose output fits par.RenderLimits is returned.
+func renderWithinLimits(par params) (Response, error) {
+	start := time.Now()
+	limits := *par.RenderLimits
+	if err := limits.check(); err != nil {
+		return Response{}, err
+	}
+	par.RenderLimits = nil
+	outOfTime := func() bool {
+		return limits.MaxMillis > 0 && time.Since(start) > time.Duration(limits.MaxMillis)*time.Millisecond
+	}
+
+	full, err := renderASCIIImpl(par)
+	if err != nil {
+		return Response{}, err
+	}
+	if limits.fits(full.Result) {
+		full.Degradation = degradationFull
+		return full, nil
+	}
+
+	stats, _, _, err := par.queryPlan()
+	if err != nil {
+		return Response{}, extractError(err)
+	}
+	planNodes := stats.GetQueryPlan().GetPlanNodes()
+	if par.Recover {
+		planNodes, _ = recoverPlanNodes(planNodes, par.Input)
+	}
+	tree := buildPlanTree(planNodes)
+	depths := operatorsPerDepth(tree.root, 0, nil)
+
+	treeOnly := par
+	treeOnly.Mode = string(reference.RenderModePlan)
+	treeOnly.PrintSections = &reference.PrintSections{}
+	treeOnly.EstimateColumn, treeOnly.LatencyBars, treeOnly.LatencyBudget, treeOnly.Cost, treeOnly.Lint = false, false, "", nil, false
+	treeOnly.Columns, treeOnly.TemplateColumns, treeOnly.ColumnGroups, treeOnly.Thresholds = nil, nil, nil, Thresholds{}
+	treeOnly.ShowQueryText, treeOnly.SubstituteParameters, treeOnly.ShowOptimizerInfo = false, false, false
+
+	// Output grows with the depth, so search for the deepest depth that
+	// fits; the full render already showed that the whole tree does not fit
+	// with every option
+	levels := len(depths)
+	for _, level := range []struct {
+		name     string
+		par      params
+		maxDepth int
+	}{{degradationCollapsed, par, levels - 2}, {degradationTreeOnly, treeOnly, levels - 1}} {
+		resp, depth, ok := deepestFit(level.maxDepth, func(depth int) (Response, bool) {
+			if outOfTime() {
+				return Response{}, false
+			}
+			resp, err := renderCollapsed(level.par, stats, tree, depth)
+			return resp, err == nil && limits.fits(resp.Result)
+		})
+		if ok {
+			resp.Warnings = append(full.Warnings, degradedWarning(level.name, depth, levels))
+			resp.Metadata = full.Metadata
+			resp.Degradation = level.name
+			return resp, nil
+		}
+	}
+
+	return Response{
+		Result:      planShapeText(buildPlanSummary(&sppb.ResultSetStats{QueryPlan: &sppb.QueryPlan{PlanNodes: planNodes}})),
+		Warnings:    append(full.Warnings, degradedWarning(degradationSummary, 0, levels)),
+		Metadata:    full.Metadata,
+		Degradation: degradationSummary,
+	}, nil
+}
+
+// deepestFit returns the render of the deepest depth up to maxDepth that
+// fits, by binary search as output grows with the depth. It reports false if
+// not even depth 0 fits.
+func deepestFit(maxDepth int, render func(depth int) (Response, bool)) (Response, int, bool) {
+	var best Response
+	bestDepth := -1
+	for lo, hi := 0, maxDepth; lo <= hi; {
+		mid := (lo + hi) / 2
+		if resp, ok := render(mid); ok {
+			best, bestDepth = resp, mid
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+	return best, bestDepth, bestDepth >= 0
+}
+
+// renderCollapsed renders the plan with the operators below depth removed
+// and an annotation on each operator whose inputs were removed. A depth at
+// or beyond the deepest operator renders the whole tree.
+func renderCollapsed(par params, stats *sppb.ResultSetStats, tree *planTree, depth int) (Response, error) {
+	planNodes := make([]*sppb.PlanNode, len(tree.nodes))
+	annotations := make(map[int32]string, len(par.Annotations))
+	for id, note := range par.Annotations {
+		annotations[id] = note
+	}
+	for i, n := range tree.nodes {
+		planNodes[i] = n.node
+	}
+	var cut func(n *treeNode, d int)
+	cut = func(n *treeNode, d int) {
+		if d < depth {
+			for _, c := range n.relationalChildren() {
+				cut(c, d+1)
+			}
+			return
+		}
+		hidden := -1
+		n.walk(func(*treeNode) { hidden++ })
+		if hidden == 0 {
+			return
+		}
+		planNodes[n.id()] = withoutRelationalInputs(n)
+		note := fmt.Sprintf("%d operators collapsed", hidden)
+		if existing := annotations[n.id()]; existing != "" {
+			note = existing + "; " + note
+		}
+		annotations[n.id()] = note
+	}
+	cut(tree.root, 0)
+
+	par.Annotations = annotations
+	par.Recover = false
+	par.parsed = &parsedPlan{stats: &sppb.ResultSetStats{
+		QueryPlan:  &sppb.QueryPlan{PlanNodes: planNodes},
+		QueryStats: stats.GetQueryStats(),
+		RowCount:   stats.GetRowCount(),
+	}}
+	return renderASCIIImpl(par)
+}
+
+// withoutRelationalInputs returns a copy of the plan node of n linked to its
+// scalar children only, so that the operators below it are not rendered.
+func withoutRelationalInputs(n *treeNode) *sppb.PlanNode {
+	var links []*sppb.PlanNode_ChildLink
+	for _, c := range n.children {
+		if !c.node.isRelational() {
+			links = append(links, c.link)
+		}
+	}
+	node := n.node
+	return &sppb.PlanNode{
+		Index:               node.GetIndex(),
+		Kind:                node.GetKind(),
+		DisplayName:         node.GetDisplayName(),
+		ChildLinks:          links,
+		ShortRepresentation: node.GetShortRepresentation(),
+		Metadata:            node.GetMetadata(),
+		ExecutionStats:      node.GetExecutionStats(),
+	}
+}
+
+// degradedWarning explains the degradation level chosen for a plan of
+// levels operator levels.
+func degradedWarning(level string, depth, levels int) Warning {
+	msg := fmt.Sprintf("Output exceeded renderLimits; rendered %s", level)
+	if level != degradationSummary && depth+1 < levels {
+		msg += fmt.Sprintf(" down to operator depth %d of %d", depth, levels-1)
+	}
+	return Warning{Code: WarningCodeRenderDegraded, Message: msg}
+}
+
+// planShapeText is the summary level of the degradation ladder.
+func planShapeText(summary PlanSummary) string {
+	return fmt.Sprintf("Plan too large to render within renderLimits: %d operators (%d scans, %d distributed), %d levels, widest level %d with %d operators\n",
+		summary.Counts.RelationalNodes, summary.Counts.LeafScans, summary.Counts.DistributedOperators,
+		summary.Depth, summary.WidestDepth, summary.WidestOperators)
+}
@@ -0,0 +1,152 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// OperatorDescription is returned by describeOperator
+type OperatorDescription struct {
+	GlossaryEntry
+	// Qualifier are the words of the looked up name before the glossary
+	// name, such as "Local" of "Local Distributed Union" or "Index" of
+	// "Index Scan"
+	Qualifier string `json:"qualifier,omitempty"`
+	// Hints are what to look for when the operator is slow or unexpected
+	Hints []string `json:"hints,omitempty"`
+	// Related are the glossary names of operators that are often confused
+	// with or appear together with the operator
+	Related []string `json:"related,omitempty"`
+}
+
+// operatorHints are the Hints of OperatorDescription by glossary name.
+var operatorHints = map[string][]string{
+	"Apply Mutations": {
+		"Its input finds the rows to modify; a full scan below it means the WHERE clause does not use a key or index.",
+	},
+	"Cross Apply": {
+		"The map side runs once per input row, so its latency grows with the rows of the input side.",
+		"A seek in the map side keeps each execution cheap; a scan there is a sign of a missing index.",
+	},
+	"Distributed Cross Apply": {
+		"Many remote calls mean the input rows are spread over many splits; an index interleaved with the input table can avoid them.",
+	},
+	"Distributed Union": {
+		"The Split Range condition limits the splits it visits; without one it runs on every split of the table.",
+		"A Local Distributed Union runs on the splits of one server and needs no remote calls.",
+	},
+	"Filter": {
+		"Filters discard rows after they were read; conditions that can seek on a key or index read fewer rows.",
+	},
+	"Filter Scan": {
+		"The Seek Condition is applied by seeking on the key; the Residual Condition is checked on every row read.",
+	},
+	"Hash Join": {
+		"The build side is held in memory; the smaller input should be the build side.",
+	},
+	"Scan": {
+		"A full scan reads every row of the table or index; check that the query can seek on a key.",
+		"An Index Scan followed by a join back to the base table may be avoided with STORING columns.",
+	},
+	"Serialize Result": {
+		"It is the root of most queries and its latency includes the whole query; look at its inputs for the cost.",
+	},
+	"Sort": {
+		"Sorting needs all input rows before it returns any; an index in the ORDER BY order avoids the sort.",
+	},
+	"Sort Limit": {
+		"It keeps only the first rows, so it is cheaper than Sort, but still reads all of its input.",
+	},
+}
+
+// relatedOperators are the Related of OperatorDescription by glossary name.
+var relatedOperators = map[string][]string{
+	"Cross Apply":             {"Outer Apply", "Distributed Cross Apply"},
+	"Distributed Cross Apply": {"Cross Apply", "Create Batch", "Distributed Outer Apply"},
+	"Distributed Outer Apply": {"Distributed Cross Apply", "Outer Apply"},
+	"Distributed Union":       {"Local Split Union", "Distributed Merge Union"},
+	"Filter":                  {"Filter Scan"},
+	"Filter Scan":             {"Scan", "Filter"},
+	"Hash Join":               {"Push Broadcast Hash Join", "Merge Join"},
+	"Merge Join":              {"Hash Join"},
+	"Outer Apply":             {"Cross Apply", "Distributed Outer Apply"},
+	"Scan":                    {"Filter Scan"},
+	"Sort":                    {"Sort Limit"},
+	"Sort Limit":              {"Sort", "Limit"},
+}
+
+type describeOperatorParams struct {
+	Name string `json:"name"`
+}
+
+// resolveOperator finds the glossary entry of an operator name: a plan
+// display name, a console name, or a name qualified by its call, iterator, or
+// scan type such as "Local Distributed Union" or "Index Scan". Names are
+// matched case-insensitively. It returns the words before the glossary name.
+func resolveOperator(name string) (GlossaryEntry, string, bool) {
+	words := strings.Fields(name)
+	for i := range words {
+		candidate := strings.Join(words[i:], " ")
+		for _, e := range operatorGlossary {
+			if strings.EqualFold(e.Name, candidate) {
+				return e, strings.Join(words[:i], " "), true
+			}
+		}
+	}
+	return GlossaryEntry{}, "", false
+}
+
+// describeOperator explains a query plan operator
+func describeOperator(paramsJSON string) (Response, error) {
+	par := describeOperatorParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return describeOperatorImpl(par)
+}
+
+func describeOperatorImpl(par describeOperatorParams) (Response, error) {
+	if strings.TrimSpace(par.Name) == "" {
+		return Response{}, InvalidParametersError{msg: "name is required"}
+	}
+	entry, qualifier, ok := resolveOperator(par.Name)
+	if !ok {
+		return Response{}, InvalidParametersError{msg: fmt.Sprintf("Unknown operator: %q", par.Name)}
+	}
+
+	b, err := json.Marshal(OperatorDescription{
+		GlossaryEntry: entry,
+		Qualifier:     qualifier,
+		Hints:         operatorHints[entry.Name],
+		Related:       relatedOperators[entry.Name],
+	})
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal operator description: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
+
+// glossaryFootnotes returns the explanations of the operators of tree,
+// appended under the table with the operatorGlossary option: one line per
+// glossary entry, in the order the operators first appear, named as they
+// appear. keep limits the operators to a subtree, or is nil for all.
+func glossaryFootnotes(tree *planTree, keep map[int32]bool) string {
+	var lines []string
+	seen := make(map[string]bool)
+	tree.root.walk(func(n *treeNode) {
+		if keep != nil && !keep[n.id()] {
+			return
+		}
+		entry, _, ok := resolveOperator(n.operatorName())
+		if !ok || seen[entry.Name] {
+			return
+		}
+		seen[entry.Name] = true
+		lines = append(lines, fmt.Sprintf("  %s: %s", n.node.GetDisplayName(), entry.Description))
+	})
+	if len(lines) == 0 {
+		return ""
+	}
+	return "Operators:\n" + strings.Join(lines, "\n") + "\n"
+}
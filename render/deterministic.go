@@ -0,0 +1,14 @@
+package render
+
+// checkDeterministic rejects the options whose output depends on more than
+// the input and the options with the deterministic option: the render time
+// of includeMetrics and the time bound of renderLimits.maxMillis.
+func checkDeterministic(o Options) error {
+	switch {
+	case o.IncludeMetrics:
+		return InvalidParametersError{msg: "includeMetrics reports timings and cannot be deterministic"}
+	case o.RenderLimits != nil && o.RenderLimits.MaxMillis > 0:
+		return InvalidParametersError{msg: "renderLimits.maxMillis depends on timing and cannot be deterministic"}
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// diagramSyntax selects the source language produced by writeDiagram.
+type diagramSyntax int
+
+const (
+	diagramDOT diagramSyntax = iota
+	diagramMermaid
+	diagramD2
+	diagramPlantUML
+)
+
+func (s diagramSyntax) String() string {
+	switch s {
+	case diagramDOT:
+		return "DOT"
+	case diagramMermaid:
+		return "MERMAID"
+	case diagramD2:
+		return "D2"
+	case diagramPlantUML:
+		return "PLANTUML"
+	default:
+		return fmt.Sprintf("diagramSyntax(%d)", int(s))
+	}
+}
+
+// diagramFormats maps the renderASCII formats that writeDiagram emits to
+// their syntax. The DOT and MERMAID formats render with spannerplanviz, as
+// renderDOT and renderMermaid do.
+var diagramFormats = map[string]diagramSyntax{
+	"PLANTUML": diagramPlantUML,
+}
+
+// lookupDiagramFormat returns the diagram syntax of a renderASCII format, if
+// it is one.
+func lookupDiagramFormat(format string) (diagramSyntax, bool) {
+	syntax, ok := diagramFormats[strings.ToUpper(format)]
+	return syntax, ok
+}
+
+// Values accepted by the weightBy option
+const (
+	weightByLatency = "latency"
+	weightByRows    = "rows"
+	weightByCPU     = "cpu"
+)
+
+// WarningCodeUnsupportedOption is the warning code for diagram options that
+// the Go-side emitters of weightBy and edgeRows do not render.
+const WarningCodeUnsupportedOption = "UNSUPPORTED_OPTION"
+
+// goDiagramWarnings reports the options of par that spannerplanviz renders
+// and the Go-side emitters drop.
+func goDiagramWarnings(par planVizParams) []Warning {
+	var dropped []string
+	for _, o := range []struct {
+		name string
+		set  bool
+	}{
+		{"full", par.Full},
+		{"metadata", par.Metadata},
+		{"executionStats", par.ExecutionStats},
+		{"executionSummary", par.ExecutionSummary},
+		{"serializeResult", par.SerializeResult},
+		{"hideScanTarget", par.HideScanTarget},
+		{"nonVariableScalar", par.NonVariableScalar},
+		{"variableScalar", par.VariableScalar},
+	} {
+		if o.set {
+			dropped = append(dropped, o.name)
+		}
+	}
+	if len(dropped) == 0 {
+		return nil
+	}
+	return []Warning{{
+		Code:    WarningCodeUnsupportedOption,
+		Message: fmt.Sprintf("Ignored %s: with weightBy or edgeRows, diagrams show the operator titles, rows, and latency only", strings.Join(dropped, ", ")),
+	}}
+}
+
+// goDiagram reports whether par renders with the Go-side emitters rather
+// than spannerplanviz.
+func (par planVizParams) goDiagram() (bool, error) {
+	if par.WeightStyle != "" && par.WeightBy == "" {
+		return false, InvalidParametersError{msg: "weightStyle requires weightBy"}
+	}
+	return par.WeightBy != "" || par.EdgeRows, nil
+}
+
+// Values accepted by the weightStyle option
+const (
+	weightStyleColor = "color"
+	weightStyleSize  = "size"
+	weightStyleBoth  = "both"
+)
+
+// diagramOptions controls the Go-side diagram emitters.
+type diagramOptions struct {
+	// weights maps plan node IDs to 0..1 heat values; nil disables weighting.
+	weights map[int32]float64
+	// colorWeights fills weighted nodes with their heatColor, and
+	// sizeWeights scales their text with weightFontSize.
+	colorWeights, sizeWeights bool
+	// edgeRows labels each edge with the rows the child returned.
+	edgeRows bool
+}
+
+// newDiagramOptions returns the diagramOptions of the weightBy, weightStyle,
+// and edgeRows options for tree. weightStyle defaults to coloring.
+func newDiagramOptions(tree *planTree, weightBy, weightStyle string, edgeRows bool) (diagramOptions, error) {
+	opts := diagramOptions{edgeRows: edgeRows}
+	switch weightStyle {
+	case "", weightStyleColor:
+		opts.colorWeights = true
+	case weightStyleSize:
+		opts.sizeWeights = true
+	case weightStyleBoth:
+		opts.colorWeights, opts.sizeWeights = true, true
+	default:
+		return diagramOptions{}, InvalidParametersError{msg: fmt.Sprintf("Invalid weightStyle: %q (expected %q, %q, or %q)", weightStyle, weightStyleColor, weightStyleSize, weightStyleBoth)}
+	}
+	if weightBy == "" {
+		return opts, nil
+	}
+	var err error
+	opts.weights, err = nodeWeights(tree, weightBy)
+	return opts, err
+}
+
+// nodeStyle returns the fill color and font size of a weighted node, empty
+// and 0 when the node is not weighted or the style does not apply.
+func (opts diagramOptions) nodeStyle(n *treeNode) (fill string, fontSize int) {
+	w, ok := opts.weights[n.id()]
+	if !ok {
+		return "", 0
+	}
+	if opts.colorWeights {
+		fill = heatColor(w)
+	}
+	if opts.sizeWeights {
+		fontSize = weightFontSize(w)
+	}
+	return fill, fontSize
+}
+
+// edgeLabel returns the label of the edge to child, or "" for none.
+func (opts diagramOptions) edgeLabel(child *treeNode) string {
+	if !opts.edgeRows {
+		return ""
+	}
+	rows, ok := child.stat("rows")
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(rows, 'f', -1, 64) + " rows"
+}
+
+// nodeWeights computes, for every relational node, its share of the largest
+// value of the weightBy metric in the plan. Nodes without the stat weigh 0.
+func nodeWeights(tree *planTree, weightBy string) (map[int32]float64, error) {
+	var metric func(*treeNode) (float64, bool)
+	switch weightBy {
+	case weightByLatency:
+		metric = func(n *treeNode) (float64, bool) { return n.durationMillis("latency") }
+	case weightByRows:
+		metric = func(n *treeNode) (float64, bool) { return n.stat("rows") }
+	case weightByCPU:
+		metric = func(n *treeNode) (float64, bool) { return n.durationMillis("cpu_time") }
+	default:
+		return nil, InvalidParametersError{msg: fmt.Sprintf("Invalid weightBy: %q (expected %q, %q, or %q)", weightBy, weightByLatency, weightByRows, weightByCPU)}
+	}
+
+	values := make(map[int32]float64)
+	var maxValue float64
+	tree.root.walk(func(n *treeNode) {
+		v, _ := metric(n)
+		values[n.id()] = v
+		maxValue = max(maxValue, v)
+	})
+	for id, v := range values {
+		if maxValue > 0 {
+			values[id] = v / maxValue
+		} else {
+			values[id] = 0
+		}
+	}
+	return values, nil
+}
+
+// heatColor maps a 0..1 weight onto a white-to-red gradient.
+func heatColor(weight float64) string {
+	weight = min(max(weight, 0), 1)
+	green := 255 - int(weight*175)
+	blue := 255 - int(weight*215)
+	return fmt.Sprintf("#ff%02x%02x", green, blue)
+}
+
+// weightFontSize maps a 0..1 weight onto font sizes from 10 to 24 points,
+// so that the text of hot nodes, and the boxes around it, grow.
+func weightFontSize(weight float64) int {
+	weight = min(max(weight, 0), 1)
+	return 10 + int(math.Round(weight*14))
+}
+
+// diagramLabel returns the operator title followed by the rows and latency
+// stats when present.
+func diagramLabel(n *treeNode) []string {
+	lines := []string{fmt.Sprintf("%d: %s", n.id(), n.title())}
+	if rows, ok := n.stat("rows"); ok {
+		lines = append(lines, "rows: "+strconv.FormatFloat(rows, 'f', -1, 64))
+	}
+	if latency, ok := n.stat("latency"); ok {
+		lines = append(lines, fmt.Sprintf("latency: %s %s", strconv.FormatFloat(latency, 'f', -1, 64), n.statUnit("latency")))
+	}
+	return lines
+}
+
+func diagramNodeName(n *treeNode) string {
+	return fmt.Sprintf("n%d", n.id())
+}
+
+// writeDiagram emits the relational nodes of tree as diagram source.
+func writeDiagram(syntax diagramSyntax, tree *planTree, opts diagramOptions) string {
+	var nodes []*treeNode
+	seen := make(map[int32]bool)
+	tree.root.walk(func(n *treeNode) {
+		if !seen[n.id()] {
+			seen[n.id()] = true
+			nodes = append(nodes, n)
+		}
+	})
+
+	var b strings.Builder
+	switch syntax {
+	case diagramDOT:
+		b.WriteString("digraph plan {\n")
+		b.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\", fontname=\"Helvetica\"];\n")
+		for _, n := range nodes {
+			fmt.Fprintf(&b, "  %s [label=%s", diagramNodeName(n), dotQuote(strings.Join(diagramLabel(n), "\n")))
+			fill, fontSize := opts.nodeStyle(n)
+			if fill != "" {
+				fmt.Fprintf(&b, ", fillcolor=%q", fill)
+			}
+			if fontSize != 0 {
+				fmt.Fprintf(&b, ", fontsize=%d", fontSize)
+			}
+			b.WriteString("];\n")
+		}
+		for _, n := range nodes {
+			for _, child := range n.relationalChildren() {
+				fmt.Fprintf(&b, "  %s -> %s", diagramNodeName(n), diagramNodeName(child))
+				if label := opts.edgeLabel(child); label != "" {
+					fmt.Fprintf(&b, " [label=%s]", dotQuote(label))
+				}
+				b.WriteString(";\n")
+			}
+		}
+		b.WriteString("}\n")
+	case diagramMermaid:
+		b.WriteString("flowchart TD\n")
+		for _, n := range nodes {
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", diagramNodeName(n), mermaidEscape(diagramLabel(n)))
+		}
+		for _, n := range nodes {
+			for _, child := range n.relationalChildren() {
+				if label := opts.edgeLabel(child); label != "" {
+					fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", diagramNodeName(n), mermaidEscape([]string{label}), diagramNodeName(child))
+				} else {
+					fmt.Fprintf(&b, "  %s --> %s\n", diagramNodeName(n), diagramNodeName(child))
+				}
+			}
+		}
+		for _, n := range nodes {
+			var styles []string
+			fill, fontSize := opts.nodeStyle(n)
+			if fill != "" {
+				styles = append(styles, "fill:"+fill)
+			}
+			if fontSize != 0 {
+				styles = append(styles, fmt.Sprintf("font-size:%dpx", fontSize))
+			}
+			if len(styles) > 0 {
+				fmt.Fprintf(&b, "  style %s %s\n", diagramNodeName(n), strings.Join(styles, ","))
+			}
+		}
+	case diagramD2:
+		for _, n := range nodes {
+			fmt.Fprintf(&b, "%s: %s", diagramNodeName(n), d2Quote(strings.Join(diagramLabel(n), "\n")))
+			var styles []string
+			fill, fontSize := opts.nodeStyle(n)
+			if fill != "" {
+				styles = append(styles, fmt.Sprintf("style.fill: %q", fill))
+			}
+			if fontSize != 0 {
+				styles = append(styles, fmt.Sprintf("style.font-size: %d", fontSize))
+			}
+			if len(styles) > 0 {
+				fmt.Fprintf(&b, " {%s}", strings.Join(styles, "; "))
+			}
+			b.WriteString("\n")
+		}
+		for _, n := range nodes {
+			for _, child := range n.relationalChildren() {
+				fmt.Fprintf(&b, "%s -> %s", diagramNodeName(n), diagramNodeName(child))
+				if label := opts.edgeLabel(child); label != "" {
+					fmt.Fprintf(&b, ": %s", d2Quote(label))
+				}
+				b.WriteString("\n")
+			}
+		}
+	case diagramPlantUML:
+		// A work breakdown structure lays out the children of the root side by
+		// side, which keeps wide but shallow plans short. It has no edge
+		// labels, so edgeRows does not apply.
+		b.WriteString("@startwbs\n")
+		seen := make(map[int32]bool)
+		var visit func(n *treeNode, level int)
+		visit = func(n *treeNode, level int) {
+			if seen[n.id()] {
+				return
+			}
+			seen[n.id()] = true
+			b.WriteString(strings.Repeat("*", level))
+			fill, fontSize := opts.nodeStyle(n)
+			if fill != "" {
+				fmt.Fprintf(&b, "[%s]", fill)
+			}
+			if fontSize != 0 {
+				fmt.Fprintf(&b, " <size:%d>%s</size>\n", fontSize, plantUMLEscape(diagramLabel(n)))
+			} else {
+				fmt.Fprintf(&b, " %s\n", plantUMLEscape(diagramLabel(n)))
+			}
+			for _, child := range n.relationalChildren() {
+				visit(child, level+1)
+			}
+		}
+		visit(tree.root, 1)
+		b.WriteString("@endwbs\n")
+	}
+	return b.String()
+}
+
+// dotQuote returns s as a DOT double-quoted string; newlines become centered
+// line breaks.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// mermaidEscape joins label lines for a Mermaid double-quoted node label,
+// which does not support backslash escapes and uses entity codes instead.
+func mermaidEscape(lines []string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = r.Replace(line)
+	}
+	return strings.Join(escaped, "<br/>")
+}
+
+// d2Quote returns s as a D2 double-quoted string.
+func d2Quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// plantUMLEscape joins label lines for a single-line PlantUML WBS node, with
+// "\n" line breaks. Backslashes, tags, and the doubled characters of Creole
+// markup are escaped with "~".
+func plantUMLEscape(lines []string) string {
+	r := strings.NewReplacer("~", "~~", `\`, `~\`, "<", "~<", "**", "~**", "//", "~//", "__", "~__", "--", "~--", `""`, `~""`, "[[", "~[[")
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = r.Replace(line)
+	}
+	return strings.Join(escaped, `\n`)
+}
@@ -0,0 +1,199 @@
+//go:build !nodiagram
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplanviz/d2"
+	"github.com/apstndb/spannerplanviz/dot"
+	"github.com/apstndb/spannerplanviz/mermaid"
+	"github.com/apstndb/spannerplanviz/visualize"
+)
+
+// The diagram exporters are the only users of spannerplanviz, which accounts
+// for a large part of the binary; build with -tags nodiagram to drop them.
+func init() {
+	registerFeature("diagram", map[string]Export{
+		"renderMermaid": {Run: renderMermaid},
+		"renderDOT":     {Run: renderDOT},
+		"renderD2":      {Run: renderD2},
+	})
+
+	// The renderASCII DOT and MERMAID formats render with spannerplanviz too,
+	// so that a plan has one DOT and one Mermaid rendering
+	for _, f := range []struct {
+		syntax      diagramSyntax
+		description string
+		source      func(plan *visualize.Plan) (string, error)
+	}{
+		{diagramDOT, "Graphviz DOT source of the operator tree", dot.Source},
+		{diagramMermaid, "Mermaid flowchart source of the operator tree", mermaid.Source},
+	} {
+		registerRenderer(registeredRenderer{
+			Renderer: rendererFunc{f.syntax.String(), func(nodes []*sppb.PlanNode, opts RendererOptions) (string, error) {
+				if opts.RootNodeID != 0 {
+					return "", InvalidParametersError{msg: fmt.Sprintf("rootNodeId is not supported by the %s format", f.syntax)}
+				}
+				stats := &sppb.ResultSetStats{
+					QueryPlan:  &sppb.QueryPlan{PlanNodes: nodes},
+					QueryStats: opts.Stats.GetQueryStats(),
+					RowCount:   opts.Stats.GetRowCount(),
+				}
+				plan, err := visualize.BuildPlan(opts.rowType, stats, visualize.BuildOptions{ExecutionStats: opts.WithStats})
+				if err != nil {
+					return "", RenderError{msg: fmt.Sprintf("Failed to build plan: %v", err)}
+				}
+				src, err := f.source(plan)
+				if err != nil {
+					return "", RenderError{msg: fmt.Sprintf("Failed to render %s source: %v", f.syntax, err)}
+				}
+				return src, nil
+			}},
+			description: f.description,
+			kind:        formatKindDiagram,
+		})
+	}
+}
+
+func renderMermaid(paramsJSON string) (Response, error) {
+	par := planVizParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return renderMermaidImpl(par)
+}
+
+func renderDOT(paramsJSON string) (Response, error) {
+	par := planVizParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return renderDOTImpl(par)
+}
+
+func renderD2(paramsJSON string) (Response, error) {
+	par := planVizParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return renderD2Impl(par)
+}
+
+func buildPlanFromParams(par planVizParams) (*visualize.Plan, []Warning, error) {
+	stats, rowType, warnings, err := loadPlanVizStats(par)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buildOpts := visualize.BuildOptions{
+		Full:              par.Full,
+		Metadata:          par.Metadata,
+		ExecutionStats:    par.ExecutionStats,
+		ExecutionSummary:  par.ExecutionSummary,
+		SerializeResult:   par.SerializeResult,
+		HideScanTarget:    par.HideScanTarget,
+		NonVariableScalar: par.NonVariableScalar,
+		VariableScalar:    par.VariableScalar,
+	}
+	buildOpts.ApplyFull()
+
+	plan, err := visualize.BuildPlan(rowType, stats, buildOpts)
+	if err != nil {
+		return nil, nil, RenderError{msg: fmt.Sprintf("Failed to build plan: %v", err)}
+	}
+	return plan, warnings, nil
+}
+
+// renderGoDiagramImpl renders diagram source with the Go-side emitters, for
+// the weightBy and edgeRows options: weightBy fills each node with a heat
+// color or scales its text, as weightStyle selects, by its share of the
+// metric, and edgeRows labels edges with the rows flowing between operators.
+// spannerplanviz does not expose per-node or per-edge styling, so these
+// options replace its output with this simpler rendering, and its options
+// are reported as ignored.
+func renderGoDiagramImpl(par planVizParams, syntax diagramSyntax) (Response, error) {
+	stats, _, warnings, err := loadPlanVizStats(par)
+	if err != nil {
+		return Response{}, err
+	}
+
+	tree := buildPlanTree(stats.GetQueryPlan().GetPlanNodes())
+	opts, err := newDiagramOptions(tree, par.WeightBy, par.WeightStyle, par.EdgeRows)
+	if err != nil {
+		return Response{}, err
+	}
+	usage.countRender(syntax.String(), "")
+	return Response{Result: writeDiagram(syntax, tree, opts), Warnings: append(warnings, goDiagramWarnings(par)...)}, nil
+}
+
+func renderMermaidImpl(par planVizParams) (Response, error) {
+	goDiagram, err := par.goDiagram()
+	if err != nil {
+		return Response{}, err
+	}
+	if goDiagram {
+		return renderGoDiagramImpl(par, diagramMermaid)
+	}
+	plan, warnings, err := buildPlanFromParams(par)
+	if err != nil {
+		return Response{}, err
+	}
+
+	src, err := mermaid.Source(plan)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to render mermaid diagram: %v", err)}
+	}
+	usage.countRender(diagramMermaid.String(), "")
+	return Response{Result: src, Warnings: warnings}, nil
+}
+
+// renderDOTImpl returns Graphviz DOT source text. Layout and SVG generation
+// happen in the browser (see renderSVGDiagram in src/wasm.ts), so the WASM
+// binary does not need to embed a Graphviz runtime.
+func renderDOTImpl(par planVizParams) (Response, error) {
+	goDiagram, err := par.goDiagram()
+	if err != nil {
+		return Response{}, err
+	}
+	if goDiagram {
+		return renderGoDiagramImpl(par, diagramDOT)
+	}
+	plan, warnings, err := buildPlanFromParams(par)
+	if err != nil {
+		return Response{}, err
+	}
+
+	src, err := dot.Source(plan)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to render DOT source: %v", err)}
+	}
+	usage.countRender(diagramDOT.String(), "")
+	return Response{Result: src, Warnings: warnings}, nil
+}
+
+// renderD2Impl returns D2 (https://d2lang.com) diagram source text. Layout and
+// image generation happen externally via the d2 CLI, so the WASM binary does
+// not embed a D2 runtime (the official D2 browser bundle is far too large).
+func renderD2Impl(par planVizParams) (Response, error) {
+	goDiagram, err := par.goDiagram()
+	if err != nil {
+		return Response{}, err
+	}
+	if goDiagram {
+		return renderGoDiagramImpl(par, diagramD2)
+	}
+	plan, warnings, err := buildPlanFromParams(par)
+	if err != nil {
+		return Response{}, err
+	}
+
+	src, err := d2.Source(plan)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to render D2 source: %v", err)}
+	}
+	usage.countRender(diagramD2.String(), "")
+	return Response{Result: src, Warnings: warnings}, nil
+}
@@ -0,0 +1,143 @@
+package render
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+// diagramTestInput has a stat of each weightBy metric on both operators, in
+// different units.
+const diagramTestInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        executionStats:
+          rows: { total: "500", unit: "rows" }
+          latency: { total: "1.5", unit: "secs" }
+          cpu_time: { total: "250", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        executionStats:
+          rows: { total: "2000", unit: "rows" }
+          latency: { total: "500", unit: "msecs" }
+          cpu_time: { total: "1", unit: "secs" }
+`
+
+func diagramTestTree(t *testing.T) *planTree {
+	t.Helper()
+	stats, _, _, err := extractQueryPlanFormat(diagramTestInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buildPlanTree(stats.GetQueryPlan().GetPlanNodes())
+}
+
+func TestNodeWeights(t *testing.T) {
+	tree := diagramTestTree(t)
+	for _, tt := range []struct {
+		weightBy string
+		want     map[int32]float64
+	}{
+		{weightByLatency, map[int32]float64{0: 1, 1: 1.0 / 3}},
+		{weightByRows, map[int32]float64{0: 0.25, 1: 1}},
+		{weightByCPU, map[int32]float64{0: 0.25, 1: 1}},
+	} {
+		t.Run(tt.weightBy, func(t *testing.T) {
+			got, err := nodeWeights(tree, tt.weightBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("nodeWeights = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := nodeWeights(tree, "bytes"); err == nil {
+		t.Error("nodeWeights accepted an unknown metric")
+	}
+}
+
+func TestWriteDiagramWeightStyle(t *testing.T) {
+	tree := diagramTestTree(t)
+	// With weightBy rows, the scan weighs 1 and the union 0.25
+	tests := []struct {
+		syntax diagramSyntax
+		style  string
+		want   []string
+		absent []string
+	}{
+		{diagramDOT, weightStyleColor, []string{`n1 [label="1: Scan\nrows: 2000\nlatency: 500 msecs", fillcolor="#ff5028"];`, `fillcolor="#ffd4ca"];`}, []string{"fontsize"}},
+		{diagramDOT, weightStyleSize, []string{`n1 [label="1: Scan\nrows: 2000\nlatency: 500 msecs", fontsize=24];`, "fontsize=14];"}, []string{"#ff5028", "#ffd4ca"}},
+		{diagramDOT, weightStyleBoth, []string{`fillcolor="#ff5028", fontsize=24];`}, nil},
+		{diagramMermaid, weightStyleColor, []string{"  style n1 fill:#ff5028\n"}, []string{"font-size"}},
+		{diagramMermaid, weightStyleSize, []string{"  style n1 font-size:24px\n", "  style n0 font-size:14px\n"}, []string{"fill:"}},
+		{diagramMermaid, weightStyleBoth, []string{"  style n1 fill:#ff5028,font-size:24px\n"}, nil},
+		{diagramD2, weightStyleColor, []string{`{style.fill: "#ff5028"}`}, []string{"font-size"}},
+		{diagramD2, weightStyleSize, []string{"{style.font-size: 24}"}, []string{"style.fill"}},
+		{diagramD2, weightStyleBoth, []string{`{style.fill: "#ff5028"; style.font-size: 24}`}, nil},
+		{diagramPlantUML, weightStyleColor, []string{"**[#ff5028] 1: Scan"}, []string{"<size:"}},
+		{diagramPlantUML, weightStyleSize, []string{"** <size:24>1: Scan"}, []string{"[#"}},
+		{diagramPlantUML, weightStyleBoth, []string{"**[#ff5028] <size:24>1: Scan"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.syntax.String()+"/"+tt.style, func(t *testing.T) {
+			opts, err := newDiagramOptions(tree, weightByRows, tt.style, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := writeDiagram(tt.syntax, tree, opts)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output does not contain %q:\n%s", want, got)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(got, absent) {
+					t.Errorf("output contains %q:\n%s", absent, got)
+				}
+			}
+		})
+	}
+
+	if _, err := newDiagramOptions(tree, weightByRows, "shape", false); err == nil {
+		t.Error("newDiagramOptions accepted an unknown weightStyle")
+	}
+}
+
+func TestGoDiagram(t *testing.T) {
+	tests := []struct {
+		name         string
+		par          planVizParams
+		wantGo       bool
+		wantErr      bool
+		wantWarnings string
+	}{
+		{name: "spannerplanviz", par: planVizParams{}},
+		{name: "weightBy", par: planVizParams{WeightBy: weightByRows, WeightStyle: weightStyleSize}, wantGo: true},
+		{name: "edgeRows drops options", par: planVizParams{EdgeRows: true, Full: true, VariableScalar: true}, wantGo: true, wantWarnings: "Ignored full, variableScalar"},
+		{name: "weightStyle without weightBy", par: planVizParams{WeightStyle: weightStyleSize}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			goDiagram, err := tt.par.goDiagram()
+			if (err != nil) != tt.wantErr || goDiagram != tt.wantGo {
+				t.Fatalf("goDiagram() = %v, %v, want %v, error %v", goDiagram, err, tt.wantGo, tt.wantErr)
+			}
+			warnings := goDiagramWarnings(tt.par)
+			switch {
+			case tt.wantWarnings == "" && len(warnings) != 0:
+				t.Errorf("warnings = %+v, want none", warnings)
+			case tt.wantWarnings != "" && (len(warnings) != 1 || warnings[0].Code != WarningCodeUnsupportedOption || !strings.HasPrefix(warnings[0].Message, tt.wantWarnings)):
+				t.Errorf("warnings = %+v, want an %s warning starting with %q", warnings, WarningCodeUnsupportedOption, tt.wantWarnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package render
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Change markers of diffPlans lines
+const (
+	diffUnchanged = ' '
+	diffAdded     = '+'
+	diffRemoved   = '-'
+	diffChanged   = '~'
+)
+
+type diffParams struct {
+	Before  string `json:"before,omitempty"`
+	After   string `json:"after,omitempty"`
+	Recover bool   `json:"recover,omitempty"`
+	// BeforeID and AfterID are loadPlan handles to compare instead of
+	// inputs; the diff is headed with their labels
+	BeforeID string `json:"beforeId,omitempty"`
+	AfterID  string `json:"afterId,omitempty"`
+}
+
+// diffSide is one of the plans compared by diffPlans.
+type diffSide struct {
+	input   string
+	recover bool
+	// header names the plan above the diff, or is "" for inputs
+	header string
+}
+
+// resolveDiffSide returns the plan to compare for side, given as input or as a
+// loadPlan handle.
+func resolveDiffSide(side, input, id string, recover bool) (diffSide, error) {
+	if id == "" {
+		return diffSide{input: input, recover: recover}, nil
+	}
+	if input != "" {
+		return diffSide{}, InvalidParametersError{msg: fmt.Sprintf("%s and %sId are mutually exclusive", side, side)}
+	}
+	plan, err := session.get(id)
+	if err != nil {
+		return diffSide{}, err
+	}
+	return diffSide{input: plan.Input, recover: plan.Recover, header: plan.describe(id)}, nil
+}
+
+// planDiffNode is an operator of the merged tree. before or after is nil for
+// removed and added operators.
+type planDiffNode struct {
+	marker   rune
+	before   *treeNode
+	after    *treeNode
+	children []*planDiffNode
+}
+
+// alignPlans aligns the operator trees rooted at before and after. Children
+// are aligned by the longest common subsequence of their operator names;
+// unaligned children with the same display name at the same place, such as
+// a table scan replaced by an index scan, are paired as changed operators.
+// The remaining children are added or removed with their subtrees.
+func alignPlans(before, after *treeNode) *planDiffNode {
+	d := &planDiffNode{marker: diffUnchanged, before: before, after: after}
+	if before.title() != after.title() {
+		d.marker = diffChanged
+	}
+
+	bc, ac := before.relationalChildren(), after.relationalChildren()
+	// lcs[i][j] is the LCS length of bc[i:] and ac[j:]
+	lcs := make([][]int, len(bc)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(ac)+1)
+	}
+	for i := len(bc) - 1; i >= 0; i-- {
+		for j := len(ac) - 1; j >= 0; j-- {
+			if bc[i].operatorName() == ac[j].operatorName() {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var removed, added []*treeNode
+	flush := func() {
+		for len(removed) > 0 && len(added) > 0 && removed[0].node.GetDisplayName() == added[0].node.GetDisplayName() {
+			d.children = append(d.children, alignPlans(removed[0], added[0]))
+			removed, added = removed[1:], added[1:]
+		}
+		for _, n := range removed {
+			d.children = append(d.children, oneSidedDiff(diffRemoved, n))
+		}
+		for _, n := range added {
+			d.children = append(d.children, oneSidedDiff(diffAdded, n))
+		}
+		removed, added = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(bc) && j < len(ac) {
+		switch {
+		case bc[i].operatorName() == ac[j].operatorName():
+			flush()
+			d.children = append(d.children, alignPlans(bc[i], ac[j]))
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			removed = append(removed, bc[i])
+			i++
+		default:
+			added = append(added, ac[j])
+			j++
+		}
+	}
+	removed = append(removed, bc[i:]...)
+	added = append(added, ac[j:]...)
+	flush()
+	return d
+}
+
+// oneSidedDiff marks the subtree rooted at n as added or removed.
+func oneSidedDiff(marker rune, n *treeNode) *planDiffNode {
+	d := &planDiffNode{marker: marker}
+	if marker == diffAdded {
+		d.after = n
+	} else {
+		d.before = n
+	}
+	for _, child := range n.relationalChildren() {
+		d.children = append(d.children, oneSidedDiff(marker, child))
+	}
+	return d
+}
+
+// diffIDs returns the before and after node IDs, "-" for a missing side.
+func (d *planDiffNode) diffIDs() string {
+	id := func(n *treeNode) string {
+		if n == nil {
+			return "-"
+		}
+		return strconv.Itoa(int(n.id()))
+	}
+	return id(d.before) + " → " + id(d.after)
+}
+
+// statDelta formats a stat of the before and after operators, with the
+// relative change when both sides have it. format renders one value.
+func statDelta(name string, before, after *treeNode, value func(*treeNode) (float64, bool), format func(float64) string) string {
+	b, bok := 0.0, false
+	if before != nil {
+		b, bok = value(before)
+	}
+	a, aok := 0.0, false
+	if after != nil {
+		a, aok = value(after)
+	}
+	switch {
+	case bok && aok:
+		s := fmt.Sprintf("%s: %s → %s", name, format(b), format(a))
+		if b > 0 && a != b {
+			s += fmt.Sprintf(" (%+.0f%%)", (a-b)/b*100)
+		}
+		return s
+	case bok:
+		return fmt.Sprintf("%s: %s", name, format(b))
+	case aok:
+		return fmt.Sprintf("%s: %s", name, format(a))
+	}
+	return ""
+}
+
+// writePlanDiff writes d and its descendants, one operator per line.
+func writePlanDiff(b *strings.Builder, d *planDiffNode, depth int, counts map[rune]int) {
+	counts[d.marker]++
+
+	var title string
+	switch {
+	case d.before == nil:
+		title = d.after.title()
+	case d.after == nil:
+		title = d.before.title()
+	case d.before.title() != d.after.title():
+		title = d.before.title() + " → " + d.after.title()
+	default:
+		title = d.after.title()
+	}
+	fmt.Fprintf(b, "%c %s%s (%s)", d.marker, strings.Repeat("  ", depth), title, d.diffIDs())
+
+	var stats []string
+	rows := statDelta("rows", d.before, d.after, func(n *treeNode) (float64, bool) { return n.stat("rows") }, formatCount)
+	latency := statDelta("latency", d.before, d.after, func(n *treeNode) (float64, bool) { return n.durationMillis("latency") }, formatMillis)
+	for _, s := range []string{rows, latency} {
+		if s != "" {
+			stats = append(stats, s)
+		}
+	}
+	if len(stats) > 0 {
+		b.WriteString("  " + strings.Join(stats, ", "))
+	}
+	b.WriteString("\n")
+
+	for _, child := range d.children {
+		writePlanDiff(b, child, depth+1, counts)
+	}
+}
+
+// diffPlans renders the merged operator tree of two plans, marking added (+),
+// removed (-), and changed (~) operators with their row and latency deltas.
+// Plans are given as inputs or as loadPlan handles.
+func diffPlans(paramsJSON string) (Response, error) {
+	par := diffParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return diffPlansImpl(par)
+}
+
+// loadDiffTrees resolves and parses the plans of par. Errors and warnings
+// name the plan they are about.
+func loadDiffTrees(par diffParams) (sides [2]diffSide, trees [2]*planTree, warnings []Warning, err error) {
+	if sides[0], err = resolveDiffSide("before", par.Before, par.BeforeID, par.Recover); err != nil {
+		return sides, trees, nil, err
+	}
+	if sides[1], err = resolveDiffSide("after", par.After, par.AfterID, par.Recover); err != nil {
+		return sides, trees, nil, err
+	}
+
+	for i, plan := range sides {
+		side := [2]string{"before", "after"}[i]
+		stats, _, w, err := loadPlanVizStats(planVizParams{Input: plan.input, Recover: plan.recover})
+		if err != nil {
+			return sides, trees, nil, fmt.Errorf("%s plan: %w", side, err)
+		}
+		for _, warning := range w {
+			warning.Message = side + " plan: " + warning.Message
+			warnings = append(warnings, warning)
+		}
+		trees[i] = buildPlanTree(stats.GetQueryPlan().GetPlanNodes())
+	}
+	return sides, trees, warnings, nil
+}
+
+func diffPlansImpl(par diffParams) (Response, error) {
+	sides, trees, warnings, err := loadDiffTrees(par)
+	if err != nil {
+		return Response{}, err
+	}
+	before, after := sides[0], sides[1]
+
+	var b strings.Builder
+	if before.header != "" || after.header != "" {
+		fmt.Fprintf(&b, "--- %s\n+++ %s\n\n", cmp.Or(before.header, "before"), cmp.Or(after.header, "after"))
+	}
+	counts := make(map[rune]int)
+	writePlanDiff(&b, alignPlans(trees[0].root, trees[1].root), 0, counts)
+	fmt.Fprintf(&b, "\n%d added, %d removed, %d changed, %d unchanged\n",
+		counts[diffAdded], counts[diffRemoved], counts[diffChanged], counts[diffUnchanged])
+	usage.countRender("DIFF", "")
+	return Response{Result: b.String(), Warnings: warnings}, nil
+}
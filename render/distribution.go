@@ -0,0 +1,153 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// statDistribution is the distribution of an execution stat over the
+// executions of an operator, as reported with PROFILE stats. Values are in
+// the unit of the stat.
+type statDistribution struct {
+	Mean         *float64          `json:"mean,omitempty"`
+	StdDeviation *float64          `json:"stdDeviation,omitempty"`
+	Histogram    []histogramBucket `json:"histogram,omitempty"`
+	// P50 and P99 are interpolated from the histogram, if there is one
+	P50 *float64 `json:"p50,omitempty"`
+	P99 *float64 `json:"p99,omitempty"`
+}
+
+// histogramBucket is a bucket of an execution stat histogram.
+type histogramBucket struct {
+	LowerBound float64 `json:"lowerBound"`
+	UpperBound float64 `json:"upperBound"`
+	Count      float64 `json:"count"`
+	Percentage float64 `json:"percentage,omitempty"`
+}
+
+// parseStatFloat parses a stat field, which Spanner sends as a string or a
+// number.
+func parseStatFloat(fields map[string]*structpb.Value, key string) (float64, bool) {
+	v, ok := fields[key]
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(valueString(v), 64)
+	return f, err == nil
+}
+
+// distribution returns the distribution of an execution stat such as
+// "latency", or nil if the stat has neither mean, standard deviation, nor
+// histogram.
+func (n *treeNode) distribution(name string) *statDistribution {
+	fields := n.node.GetExecutionStats().GetFields()[name].GetStructValue().GetFields()
+	var d statDistribution
+	if v, ok := parseStatFloat(fields, "mean"); ok {
+		d.Mean = &v
+	}
+	if v, ok := parseStatFloat(fields, "std_deviation"); ok {
+		d.StdDeviation = &v
+	}
+	buckets := fields["histogram"].GetListValue().GetValues()
+	if len(buckets) > 0 {
+		d.Histogram = make([]histogramBucket, 0, len(buckets))
+	}
+	for _, b := range buckets {
+		bucket := b.GetStructValue().GetFields()
+		var h histogramBucket
+		h.LowerBound, _ = parseStatFloat(bucket, "lower_bound")
+		h.UpperBound, _ = parseStatFloat(bucket, "upper_bound")
+		h.Count, _ = parseStatFloat(bucket, "count")
+		h.Percentage, _ = parseStatFloat(bucket, "percentage")
+		d.Histogram = append(d.Histogram, h)
+	}
+	if p, ok := histogramPercentile(d.Histogram, 0.5); ok {
+		d.P50 = &p
+	}
+	if p, ok := histogramPercentile(d.Histogram, 0.99); ok {
+		d.P99 = &p
+	}
+	if d.Mean == nil && d.StdDeviation == nil && len(d.Histogram) == 0 {
+		return nil
+	}
+	return &d
+}
+
+// histogramPercentile interpolates the percentile p, in [0, 1], of a
+// histogram linearly within its bucket. Buckets are weighted by count, or by
+// percentage if no bucket has a count.
+func histogramPercentile(buckets []histogramBucket, p float64) (float64, bool) {
+	weight := func(b histogramBucket) float64 { return b.Count }
+	var total float64
+	for _, b := range buckets {
+		total += b.Count
+	}
+	if total == 0 {
+		weight = func(b histogramBucket) float64 { return b.Percentage }
+		for _, b := range buckets {
+			total += b.Percentage
+		}
+	}
+	if total <= 0 {
+		return 0, false
+	}
+
+	target := p * total
+	var cumulative float64
+	for _, b := range buckets {
+		w := weight(b)
+		if w > 0 && cumulative+w >= target {
+			return b.LowerBound + (b.UpperBound-b.LowerBound)*(target-cumulative)/w, true
+		}
+		cumulative += w
+	}
+	last := buckets[len(buckets)-1]
+	return last.UpperBound, true
+}
+
+// latencyDistributionCell formats the latency of n for the latencyDistribution
+// option: the total, followed by p50 and p99 if the stat has a histogram, or
+// else by mean ± standard deviation. Values are formatted by f.
+func latencyDistributionCell(n *treeNode, f NumberFormat) (string, bool) {
+	latency := n.node.GetExecutionStats().GetFields()["latency"]
+	total, ok := n.stat("latency")
+	if !ok {
+		return "", false
+	}
+	unit := n.statUnit("latency")
+	cell := strings.TrimSpace(valueString(latency.GetStructValue().GetFields()["total"]) + " " + unit)
+	if f != (NumberFormat{}) {
+		cell = f.duration(total, unit)
+	}
+
+	value := func(v float64) string {
+		v, _ = f.convertDuration(v, unit)
+		return f.group(formatDecimal(v, 3))
+	}
+	d := n.distribution("latency")
+	switch {
+	case d == nil:
+	case d.P50 != nil && d.P99 != nil:
+		cell += fmt.Sprintf(" (p50 %s, p99 %s)", value(*d.P50), value(*d.P99))
+	case d.Mean != nil && d.StdDeviation != nil:
+		cell += fmt.Sprintf(" (%s ± %s)", value(*d.Mean), value(*d.StdDeviation))
+	case d.Mean != nil:
+		cell += fmt.Sprintf(" (mean %s)", value(*d.Mean))
+	}
+	return cell, true
+}
+
+// applyLatencyDistribution replaces the cells of the Total Latency column of
+// the table at the start of rendered with latencyDistributionCell.
+func applyLatencyDistribution(rendered string, tree *planTree, f NumberFormat) string {
+	cells := make(map[int32]string)
+	tree.root.walk(func(n *treeNode) {
+		if cell, ok := latencyDistributionCell(n, f); ok {
+			cells[n.id()] = cell
+		}
+	})
+	return replaceColumnCells(rendered, map[string]map[int32]string{"Total Latency": cells})
+}
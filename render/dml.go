@@ -0,0 +1,127 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// DMLSummary describes the DML operators of a DML plan and, for PROFILE
+// captures, the rows the statement modified.
+type DMLSummary struct {
+	// Operations are the DML operators in pre-order
+	Operations []DMLOperation `json:"operations"`
+	// RowsModified is the exact number of modified rows
+	RowsModified *int64 `json:"rowsModified,omitempty"`
+	// RowsModifiedLowerBound is the lower bound of the modified rows that
+	// partitioned DML reports instead
+	RowsModifiedLowerBound *int64 `json:"rowsModifiedLowerBound,omitempty"`
+	// Partitioned is set for partitioned DML, which reports a lower bound
+	Partitioned bool `json:"partitioned,omitempty"`
+	// PartitionExecutions is the number of executions of the plan root of
+	// partitioned DML, one per partition
+	PartitionExecutions *int64 `json:"partitionExecutions,omitempty"`
+}
+
+// DMLOperation is an operator that applies mutations.
+type DMLOperation struct {
+	NodeID int32 `json:"nodeId"`
+	// Operation is the operation type, e.g. "INSERT", "UPDATE", or "DELETE"
+	Operation string `json:"operation,omitempty"`
+	Table     string `json:"table,omitempty"`
+	// Mutations is the number of rows the operator applied mutations for,
+	// from its execution stats
+	Mutations *float64 `json:"mutations,omitempty"`
+	// Executions is the number of executions of the operator
+	Executions *float64 `json:"executions,omitempty"`
+}
+
+// isDMLOperator reports whether node applies mutations. Names are matched
+// case-insensitively so that console naming gives the same result.
+func isDMLOperator(node *sppb.PlanNode) bool {
+	return strings.EqualFold(node.GetDisplayName(), "Apply Mutations")
+}
+
+// numExecutions returns the number of executions of n from its execution
+// summary.
+func (n *treeNode) numExecutions() (float64, bool) {
+	summary := n.node.GetExecutionStats().GetFields()["execution_summary"]
+	v, err := strconv.ParseFloat(valueString(summary.GetStructValue().GetFields()["num_executions"]), 64)
+	return v, err == nil
+}
+
+// buildDMLSummary returns the DMLSummary of the plan, or nil if it is not a
+// DML plan: it has neither DML operators nor a modified row count.
+func buildDMLSummary(stats *sppb.ResultSetStats, planNodes []*sppb.PlanNode) *DMLSummary {
+	summary := DMLSummary{Operations: []DMLOperation{}}
+	switch count := stats.GetRowCount().(type) {
+	case *sppb.ResultSetStats_RowCountExact:
+		summary.RowsModified = &count.RowCountExact
+	case *sppb.ResultSetStats_RowCountLowerBound:
+		summary.RowsModifiedLowerBound = &count.RowCountLowerBound
+		summary.Partitioned = true
+	}
+
+	tree := buildPlanTree(planNodes)
+	tree.root.walk(func(n *treeNode) {
+		if !isDMLOperator(n.node) {
+			return
+		}
+		fields := n.node.GetMetadata().GetFields()
+		op := DMLOperation{
+			NodeID:    n.id(),
+			Operation: valueString(fields["operation_type"]),
+			Table:     valueString(fields["table"]),
+		}
+		op.Mutations = optional(n.stat("rows"))
+		op.Executions = optional(n.numExecutions())
+		summary.Operations = append(summary.Operations, op)
+	})
+	if len(summary.Operations) == 0 && summary.RowsModified == nil && !summary.Partitioned {
+		return nil
+	}
+	if summary.Partitioned {
+		if v, ok := tree.root.numExecutions(); ok {
+			executions := int64(v)
+			summary.PartitionExecutions = &executions
+		}
+	}
+	return &summary
+}
+
+// dmlSummaryText returns the rows-modified line appended to text renders of
+// DML plans, or "" without a modified row count.
+func dmlSummaryText(summary *DMLSummary) string {
+	if summary == nil {
+		return ""
+	}
+	var count string
+	switch {
+	case summary.RowsModified != nil:
+		count = strconv.FormatInt(*summary.RowsModified, 10)
+	case summary.RowsModifiedLowerBound != nil:
+		count = "at least " + strconv.FormatInt(*summary.RowsModifiedLowerBound, 10)
+	default:
+		return ""
+	}
+
+	var details []string
+	if summary.Partitioned {
+		details = append(details, "partitioned DML")
+	}
+	for _, op := range summary.Operations {
+		if target := strings.TrimSpace(op.Operation + " " + op.Table); target != "" {
+			details = append(details, target)
+		}
+	}
+	if summary.PartitionExecutions != nil {
+		details = append(details, fmt.Sprintf("%d partition executions", *summary.PartitionExecutions))
+	}
+	s := "Rows modified: " + count
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	return s + "\n"
+}
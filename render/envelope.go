@@ -0,0 +1,83 @@
+package render
+
+import (
+	"encoding/json"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// extractQueryPlanEnvelope finds a ResultSet, ResultSetStats, or QueryPlan
+// wrapped in envelope JSON, such as a Cloud Logging LogEntry exported by a
+// sink with the plan under protoPayload or jsonPayload, and decodes it. Plans
+// serialized as JSON strings inside the envelope are found too. It returns
+// errNoQueryPlan if the envelope has no plan. The JSON flavor is that of the
+// plan, not of the envelope.
+func extractQueryPlanEnvelope(input string) (*sppb.ResultSetStats, *sppb.StructType, string, error) {
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	var envelope any
+	if err := dec.Decode(&envelope); err != nil {
+		return nil, nil, "", err
+	}
+	found := findEnvelopedPlan(envelope)
+	if found == nil {
+		return nil, nil, "", errNoQueryPlan
+	}
+	b, err := json.Marshal(found)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	stats, rowType, err := extractQueryPlanJSON(string(b))
+	return stats, rowType, jsonInputFormat(string(b)), err
+}
+
+// findEnvelopedPlan searches v breadth first, so that the outermost match
+// wins, for an object that extractQueryPlanJSON accepts. Object members are
+// visited in name order to keep the choice deterministic.
+func findEnvelopedPlan(v any) map[string]any {
+	queue := []any{v}
+	for len(queue) > 0 {
+		switch v := queue[0].(type) {
+		case map[string]any:
+			if isPlanObject(v) {
+				return v
+			}
+			for _, key := range sortedKeys(v) {
+				queue = append(queue, v[key])
+			}
+		case []any:
+			queue = append(queue, v...)
+		case string:
+			// Log entries often carry the plan as a serialized JSON string
+			if strings.HasPrefix(strings.TrimSpace(v), "{") {
+				var inner any
+				dec := json.NewDecoder(strings.NewReader(v))
+				dec.UseNumber()
+				if dec.Decode(&inner) == nil {
+					queue = append(queue, inner)
+				}
+			}
+		}
+		queue = queue[1:]
+	}
+	return nil
+}
+
+// isPlanObject reports whether m is a ResultSet, ResultSetStats, or QueryPlan
+// by its members. A stats member only counts if it has a query plan, as
+// envelopes have unrelated stats of their own.
+func isPlanObject(m map[string]any) bool {
+	for _, key := range []string{"queryPlan", "query_plan", "planNodes", "plan_nodes"} {
+		if _, ok := m[key]; ok {
+			return true
+		}
+	}
+	stats, ok := m["stats"].(map[string]any)
+	if !ok {
+		return false
+	}
+	_, camel := stats["queryPlan"]
+	_, snake := stats["query_plan"]
+	return camel || snake
+}
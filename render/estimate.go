@@ -0,0 +1,139 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// WarningCodeMisestimate is reported for operators whose actual row count
+// differs from the optimizer's estimate by the misestimate ratio or more.
+const WarningCodeMisestimate = "ROW_MISESTIMATE"
+
+// estimateColumnTitle is the header of the estimate column.
+const estimateColumnTitle = "Est/Actual"
+
+// estimatedRowsKeys are the metadata keys that carry cardinality estimates,
+// in order of preference.
+var estimatedRowsKeys = []string{"estimated_rows", "estimated_row_count"}
+
+// RowEstimate is the estimate of an operator returned in Response.Estimates
+type RowEstimate struct {
+	NodeID        int32   `json:"nodeId"`
+	EstimatedRows float64 `json:"estimatedRows"`
+	ActualRows    float64 `json:"actualRows"`
+	// Ratio is ActualRows / EstimatedRows, with estimates of zero rows taken
+	// as one row
+	Ratio float64 `json:"ratio"`
+	// Misestimated is set when Ratio is off by the misestimate ratio
+	// threshold or more in either direction
+	Misestimated bool `json:"misestimated,omitempty"`
+}
+
+// rowEstimate compares the estimated and actual rows of one operator.
+type rowEstimate struct {
+	node      *treeNode
+	estimated float64
+	actual    float64
+}
+
+// ratio returns actual / estimated rows. Estimates of zero rows are treated as
+// one row, as the optimizer does.
+func (e rowEstimate) ratio() float64 {
+	return e.actual / max(e.estimated, 1)
+}
+
+// misestimated reports whether the ratio is off by factor or more in either
+// direction. Operators that returned nothing and were expected to return
+// nothing are not flagged.
+func (e rowEstimate) misestimated(factor float64) bool {
+	if e.actual == 0 && e.estimated <= 1 {
+		return false
+	}
+	r := e.ratio()
+	return r >= factor || r <= 1/factor
+}
+
+// estimatedRows returns the cardinality estimate in n's metadata, if any.
+func (n *treeNode) estimatedRows() (float64, bool) {
+	fields := n.node.GetMetadata().GetFields()
+	for _, key := range estimatedRowsKeys {
+		v, ok := fields[key]
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(valueString(v), 64)
+		if err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// rowEstimates returns the operators with both an estimate and an actual row
+// count, in pre-order.
+func rowEstimates(tree *planTree) []rowEstimate {
+	var estimates []rowEstimate
+	tree.root.walk(func(n *treeNode) {
+		estimated, ok := n.estimatedRows()
+		if !ok {
+			return
+		}
+		actual, ok := n.stat("rows")
+		if !ok {
+			return
+		}
+		estimates = append(estimates, rowEstimate{node: n, estimated: estimated, actual: actual})
+	})
+	return estimates
+}
+
+// estimateResults returns estimates as RowEstimates, flagging those off by
+// factor or more.
+func estimateResults(estimates []rowEstimate, factor float64) []RowEstimate {
+	results := make([]RowEstimate, len(estimates))
+	for i, e := range estimates {
+		results[i] = RowEstimate{
+			NodeID:        e.node.id(),
+			EstimatedRows: e.estimated,
+			ActualRows:    e.actual,
+			Ratio:         e.ratio(),
+			Misestimated:  e.misestimated(factor),
+		}
+	}
+	return results
+}
+
+func formatCount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatRatio formats a ratio with three significant digits, e.g. "25x".
+func formatRatio(r float64) string {
+	return strconv.FormatFloat(r, 'g', 3, 64) + "x"
+}
+
+// applyEstimateColumn appends the estimate column to the rendered table and
+// returns warnings for estimates off by t.MisestimateRatio or more. Plans
+// without estimates are left unchanged.
+func applyEstimateColumn(rendered string, tree *planTree, t Thresholds) (string, []Warning) {
+	estimates := rowEstimates(tree)
+	if len(estimates) == 0 {
+		return rendered, nil
+	}
+
+	cells := make(map[int32]string, len(estimates))
+	var warnings []Warning
+	for _, e := range estimates {
+		id := e.node.id()
+		cells[id] = fmt.Sprintf("%s / %s (%s)", formatCount(e.estimated), formatCount(e.actual), formatRatio(e.ratio()))
+		if e.misestimated(t.MisestimateRatio) {
+			warnings = append(warnings, Warning{
+				Code: WarningCodeMisestimate,
+				Message: fmt.Sprintf("%s estimated %s rows but returned %s (%s)",
+					e.node.node.GetDisplayName(), formatCount(e.estimated), formatCount(e.actual), formatRatio(e.ratio())),
+				NodeID: &id,
+			})
+		}
+	}
+	return appendTableColumn(rendered, estimateColumnTitle, cells), warnings
+}
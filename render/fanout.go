@@ -0,0 +1,136 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FanOutPoint estimates how many splits one distributed operator touched.
+// The estimates need execution stats and are omitted in PLAN captures.
+type FanOutPoint struct {
+	NodeID            int32  `json:"nodeId"`
+	Operator          string `json:"operator"`
+	DistributionTable string `json:"distributionTable,omitempty"`
+	// Executions is how often the operator itself ran
+	Executions *float64 `json:"executions,omitempty"`
+	// RemoteCalls counts calls to other servers; zero means every split was
+	// local
+	RemoteCalls *float64 `json:"remoteCalls,omitempty"`
+	// SubqueryExecutions is how often the distributed subquery ran, roughly
+	// once per split or batch of splits
+	SubqueryExecutions *float64 `json:"subqueryExecutions,omitempty"`
+	// EstimatedSplits is the larger of RemoteCalls and SubqueryExecutions
+	EstimatedSplits *float64 `json:"estimatedSplits,omitempty"`
+	// SplitsPerExecution is EstimatedSplits / Executions, the fan-out of a
+	// single execution of the operator
+	SplitsPerExecution *float64 `json:"splitsPerExecution,omitempty"`
+}
+
+// FanOutReport is returned by getFanOutReport
+type FanOutReport struct {
+	Points           []FanOutPoint `json:"points"`
+	TotalRemoteCalls float64       `json:"totalRemoteCalls"`
+	// MaxSplitsPerExecution is the largest SplitsPerExecution in Points
+	MaxSplitsPerExecution float64 `json:"maxSplitsPerExecution"`
+}
+
+type fanOutParams struct {
+	Input   string `json:"input"`
+	Recover bool   `json:"recover,omitempty"`
+}
+
+// isDistributedOperator reports whether n distributes work across splits,
+// e.g. Distributed Union or Distributed Cross Apply.
+func isDistributedOperator(n *treeNode) bool {
+	return strings.HasPrefix(n.node.GetDisplayName(), "Distributed ")
+}
+
+// executions returns the execution count from the execution summary.
+func (n *treeNode) executions() (float64, bool) {
+	summary := n.node.GetExecutionStats().GetFields()["execution_summary"]
+	v, ok := summary.GetStructValue().GetFields()["num_executions"]
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(valueString(v), 64)
+	return f, err == nil
+}
+
+// subqueryNode returns the root of the subquery a distributed operator runs
+// on each split, as named by its subquery_cluster_node metadata.
+func (n *treeNode) subqueryNode(tree *planTree) *treeNode {
+	index, err := strconv.Atoi(valueString(n.node.GetMetadata().GetFields()["subquery_cluster_node"]))
+	if err != nil || index < 0 || index >= len(tree.nodes) {
+		return nil
+	}
+	return tree.nodes[index]
+}
+
+func optional(v float64, ok bool) *float64 {
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// buildFanOutReport lists the distributed operators of tree in pre-order.
+func buildFanOutReport(tree *planTree) FanOutReport {
+	report := FanOutReport{Points: []FanOutPoint{}}
+	tree.root.walk(func(n *treeNode) {
+		if !isDistributedOperator(n) {
+			return
+		}
+		point := FanOutPoint{
+			NodeID:            n.id(),
+			Operator:          n.operatorName(),
+			DistributionTable: valueString(n.node.GetMetadata().GetFields()["distribution_table"]),
+			Executions:        optional(n.executions()),
+			RemoteCalls:       optional(n.stat("remote_calls")),
+		}
+		if sub := n.subqueryNode(tree); sub != nil {
+			point.SubqueryExecutions = optional(sub.executions())
+		}
+
+		if point.RemoteCalls != nil || point.SubqueryExecutions != nil {
+			var splits float64
+			if point.RemoteCalls != nil {
+				report.TotalRemoteCalls += *point.RemoteCalls
+				splits = *point.RemoteCalls
+			}
+			if point.SubqueryExecutions != nil {
+				splits = max(splits, *point.SubqueryExecutions)
+			}
+			point.EstimatedSplits = &splits
+			if point.Executions != nil && *point.Executions > 0 {
+				perExecution := splits / *point.Executions
+				point.SplitsPerExecution = &perExecution
+				report.MaxSplitsPerExecution = max(report.MaxSplitsPerExecution, perExecution)
+			}
+		}
+		report.Points = append(report.Points, point)
+	})
+	return report
+}
+
+// getFanOutReport returns the fan-out of each distributed operator as JSON
+func getFanOutReport(paramsJSON string) (Response, error) {
+	par := fanOutParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return getFanOutReportImpl(par)
+}
+
+func getFanOutReportImpl(par fanOutParams) (Response, error) {
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	b, err := json.Marshal(buildFanOutReport(buildPlanTree(stats.GetQueryPlan().GetPlanNodes())))
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal fan-out report: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
@@ -0,0 +1,174 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+type fingerprintParams struct {
+	Input   string `json:"input"`
+	Recover bool   `json:"recover,omitempty"`
+}
+
+// PlanFingerprint is returned by fingerprintPlan. Plans of the same query
+// share Query; plans the optimizer built the same way share Plan.
+type PlanFingerprint struct {
+	// Plan hashes the operator tree, ignoring execution stats and the tables
+	// and indexes scanned
+	Plan string `json:"plan"`
+	// PlanWithTargets also hashes the table or index each scan reads, so it
+	// changes when the optimizer picks another index for the same plan shape
+	PlanWithTargets string `json:"planWithTargets"`
+	// Query hashes the normalized query text, if the input has one
+	Query string `json:"query,omitempty"`
+	// NormalizedQuery is the text Query hashes
+	NormalizedQuery string `json:"normalizedQuery,omitempty"`
+	// Shape outlines the operator tree, one operator per line indented by
+	// depth, so that checkPlanRegression can tell what changed from it
+	Shape []string `json:"shape,omitempty"`
+}
+
+var (
+	// queryTokenPattern matches, in order of precedence, comments, quoted
+	// identifiers, string and bytes literals, numeric literals, and runs of
+	// whitespace in GoogleSQL text.
+	queryTokenPattern = regexp.MustCompile(`(?s)--[^\n]*|#[^\n]*|/\*.*?\*/` +
+		"|`(?:[^`\\\\]|\\\\.)*`" +
+		`|(?i:[rb]{0,2})(?:'''.*?'''|"""(?:.*?)"""|'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*")` +
+		`|\b(?:0[xX][0-9a-fA-F]+|[0-9]+(?:\.[0-9]*)?(?:[eE][+-]?[0-9]+)?)\b` +
+		`|\s+`)
+	// literalListPattern matches lists of two or more placeholders, such as
+	// the literals of an IN list.
+	literalListPattern = regexp.MustCompile(`\?(?:\s*,\s*\?)+`)
+)
+
+// normalizeQuery replaces literals with "?", drops comments, collapses
+// whitespace and literal lists, and lower-cases everything but quoted
+// identifiers, so that executions of the same query shape with different
+// constants normalize to the same text.
+func normalizeQuery(query string) string {
+	normalized := queryTokenPattern.ReplaceAllStringFunc(query, func(tok string) string {
+		switch {
+		case strings.HasPrefix(tok, "--"), strings.HasPrefix(tok, "#"), strings.HasPrefix(tok, "/*"):
+			return " "
+		case strings.HasPrefix(tok, "`"):
+			return tok
+		case strings.TrimSpace(tok) == "":
+			return " "
+		default:
+			return "?"
+		}
+	})
+	normalized = literalListPattern.ReplaceAllString(normalized, "?")
+
+	// Lower-case outside quoted identifiers, which are the only tokens left
+	// whose case matters
+	var b strings.Builder
+	for i, part := range strings.Split(normalized, "`") {
+		if i%2 == 1 {
+			b.WriteString("`" + part + "`")
+		} else {
+			b.WriteString(strings.ToLower(part))
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// planShape returns the canonical text of the operator tree hashed by the
+// plan fingerprint: one line per relational operator in pre-order with its
+// depth, the type of the link from its parent, and its qualified name, and
+// withTargets the scan target of scans.
+func planShape(tree *planTree, withTargets bool) string {
+	var b strings.Builder
+	tree.root.walk(func(n *treeNode) {
+		linkType := ""
+		if n.parent != nil {
+			for _, c := range n.parent.children {
+				if c.node == n {
+					linkType = c.link.GetType()
+					break
+				}
+			}
+		}
+		fmt.Fprintf(&b, "%d\t%s\t%s", n.depth, linkType, n.operatorName())
+		if withTargets {
+			if target := valueString(n.node.GetMetadata().GetFields()["scan_target"]); target != "" {
+				b.WriteString("\t" + target)
+			}
+		}
+		b.WriteString("\n")
+	})
+	return b.String()
+}
+
+// planOutline returns the lines of PlanFingerprint.Shape: each relational
+// operator in pre-order, indented by depth, with the type of the link from
+// its parent and its scan target.
+func planOutline(tree *planTree) []string {
+	var lines []string
+	tree.root.walk(func(n *treeNode) {
+		line := strings.Repeat("  ", n.depth)
+		if n.parent != nil {
+			for _, c := range n.parent.children {
+				if c.node == n && c.link.GetType() != "" {
+					line += c.link.GetType() + ": "
+					break
+				}
+			}
+		}
+		line += n.operatorName()
+		if target := valueString(n.node.GetMetadata().GetFields()["scan_target"]); target != "" {
+			line += " (" + target + ")"
+		}
+		lines = append(lines, line)
+	})
+	return lines
+}
+
+func fingerprintHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// planFingerprint computes the fingerprints of a parsed plan.
+func planFingerprint(stats *sppb.ResultSetStats) PlanFingerprint {
+	tree := buildPlanTree(stats.GetQueryPlan().GetPlanNodes())
+	fp := PlanFingerprint{
+		Plan:            fingerprintHash(planShape(tree, false)),
+		PlanWithTargets: fingerprintHash(planShape(tree, true)),
+		Shape:           planOutline(tree),
+	}
+	if text := valueString(stats.GetQueryStats().GetFields()["query_text"]); text != "" {
+		fp.NormalizedQuery = normalizeQuery(text)
+		fp.Query = fingerprintHash(fp.NormalizedQuery)
+	}
+	return fp
+}
+
+// fingerprintPlan returns the plan and query fingerprints of the input as JSON
+func fingerprintPlan(paramsJSON string) (Response, error) {
+	par := fingerprintParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return fingerprintPlanImpl(par)
+}
+
+func fingerprintPlanImpl(par fingerprintParams) (Response, error) {
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+
+	b, err := json.Marshal(planFingerprint(stats))
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal fingerprint: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
@@ -0,0 +1,77 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// WarningCodeTargetWidthExceeded is reported when the table is wider than the
+// targetWidth option even at the narrowest wrap width.
+const WarningCodeTargetWidthExceeded = "TARGET_WIDTH_EXCEEDED"
+
+// tableWidth returns the width in runes of the first table in rendered, or 0
+// if it has none. Borders have no escapes or wide runes, so the top border is
+// measured.
+func tableWidth(rendered string) int {
+	for _, line := range strings.Split(rendered, "\n") {
+		if strings.HasPrefix(line, "+") {
+			return utf8.RuneCountInString(line)
+		}
+	}
+	return 0
+}
+
+// renderToTargetWidth renders table formats with the widest wrapWidth whose
+// table fits par.TargetWidth. Only the Operator column wraps, and narrower
+// wrap widths never widen the table, so the wrap widths below the unwrapped
+// table width are bisected. Other formats ignore the option.
+func renderToTargetWidth(par params) (Response, error) {
+	target := par.TargetWidth
+	par.TargetWidth = 0
+	switch {
+	case target < 0:
+		return Response{}, InvalidParametersError{msg: fmt.Sprintf("Invalid targetWidth: %d (must not be negative)", target)}
+	case par.WrapWidth != 0:
+		return Response{}, InvalidParametersError{msg: "wrapWidth and targetWidth are alternatives; set one of them"}
+	}
+	if !isTextTableFormat(par.Format) {
+		return renderASCIIImpl(par)
+	}
+
+	full, err := renderASCIIImpl(par)
+	if err != nil || tableWidth(full.Result) <= target {
+		return full, err
+	}
+	render := func(wrapWidth int) (Response, error) {
+		par.WrapWidth = wrapWidth
+		return renderASCIIImpl(par)
+	}
+	var best *Response
+	lo, hi := 1, tableWidth(full.Result)-1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		resp, err := render(mid)
+		if err != nil {
+			return Response{}, err
+		}
+		if tableWidth(resp.Result) <= target {
+			best, lo = &resp, mid+1
+		} else {
+			hi = mid - 1
+		}
+	}
+	if best != nil {
+		return *best, nil
+	}
+
+	narrowest, err := render(1)
+	if err != nil {
+		return Response{}, err
+	}
+	narrowest.Warnings = append(narrowest.Warnings, Warning{
+		Code:    WarningCodeTargetWidthExceeded,
+		Message: fmt.Sprintf("The table is %d characters wide at the narrowest wrap width, wider than targetWidth %d", tableWidth(narrowest.Result), target),
+	})
+	return narrowest, nil
+}
@@ -0,0 +1,153 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Flat export formats of renderASCII, one row per plan node
+const (
+	formatCSV   = "CSV"
+	formatTSV   = "TSV"
+	formatJSONL = "JSONL"
+)
+
+// flatNodes returns the nodes of tree, or with a non-nil root the nodes of
+// its subtree, in index order.
+func flatNodes(tree *planTree, root *treeNode) []*treeNode {
+	if root == nil {
+		return tree.nodes
+	}
+	return slices.DeleteFunc(slices.Clone(tree.nodes), func(n *treeNode) bool { return !inSubtree(n, root) })
+}
+
+// flatColumns are the leading columns of flat exports. Column names only use
+// letters, digits, and underscores so that they load into BigQuery as is.
+var flatColumns = []string{"id", "parent_id", "depth", "kind", "display_name", "title", "short_representation"}
+
+// writeFlatTable writes every plan node, scalar nodes included, or with a
+// non-nil root the nodes of its subtree, as a row of
+// the leading columns followed by a "metadata_<key>" column per metadata key
+// and, withStats, a "stats_<stat>_<field>" column per execution stat field,
+// e.g. stats_latency_total. Columns are the union over all nodes in name
+// order; nodes without a key get an empty cell.
+func writeFlatTable(tree *planTree, root *treeNode, comma rune, withStats bool) string {
+	nodes := flatNodes(tree, root)
+	cells := make([]map[string]string, len(nodes))
+	keys := make(map[string]bool)
+	for i, n := range nodes {
+		row := make(map[string]string)
+		for key, v := range n.node.GetMetadata().GetFields() {
+			flattenValue(row, "metadata_"+flatColumnName(key), v)
+		}
+		if withStats {
+			for key, v := range n.node.GetExecutionStats().GetFields() {
+				flattenValue(row, "stats_"+flatColumnName(key), v)
+			}
+		}
+		for key := range row {
+			keys[key] = true
+		}
+		cells[i] = row
+	}
+	extra := sortedKeys(keys)
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Comma = comma
+	// Writes to a strings.Builder cannot fail
+	_ = w.Write(slices.Concat(flatColumns, extra))
+	for i, n := range nodes {
+		parent := ""
+		if n.parent != nil {
+			parent = strconv.Itoa(int(n.parent.id()))
+		}
+		record := []string{
+			strconv.Itoa(int(n.id())),
+			parent,
+			strconv.Itoa(n.depth),
+			n.node.GetKind().String(),
+			n.node.GetDisplayName(),
+			n.title(),
+			n.node.GetShortRepresentation().GetDescription(),
+		}
+		for _, key := range extra {
+			record = append(record, cells[i][key])
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// jsonLinesNode is a line of the JSONL format. Fields are named like the
+// columns of the CSV format; metadata and stats keep their nesting.
+type jsonLinesNode struct {
+	ID                  int32          `json:"id"`
+	ParentID            *int32         `json:"parent_id"`
+	Depth               int            `json:"depth"`
+	Kind                string         `json:"kind"`
+	DisplayName         string         `json:"display_name"`
+	Title               string         `json:"title"`
+	ShortRepresentation string         `json:"short_representation,omitempty"`
+	Metadata            map[string]any `json:"metadata,omitempty"`
+	Stats               map[string]any `json:"stats,omitempty"`
+}
+
+// writeJSONLines writes the nodes like writeFlatTable, as a self-contained
+// JSON object per line, e.g. for log pipelines or DuckDB's read_json. Roots
+// have a null parent_id; withStats adds the execution stats.
+func writeJSONLines(tree *planTree, root *treeNode, withStats bool) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	for _, n := range flatNodes(tree, root) {
+		line := jsonLinesNode{
+			ID:                  n.id(),
+			Depth:               n.depth,
+			Kind:                n.node.GetKind().String(),
+			DisplayName:         n.node.GetDisplayName(),
+			Title:               n.title(),
+			ShortRepresentation: n.node.GetShortRepresentation().GetDescription(),
+			Metadata:            n.node.GetMetadata().AsMap(),
+		}
+		if n.parent != nil {
+			parentID := n.parent.id()
+			line.ParentID = &parentID
+		}
+		if withStats {
+			line.Stats = n.node.GetExecutionStats().AsMap()
+		}
+		// Plan nodes are protobuf values, which always encode
+		_ = enc.Encode(line)
+	}
+	return b.String()
+}
+
+// flattenValue stores v under name, and the fields of struct values under
+// name_<field>, recursively.
+func flattenValue(row map[string]string, name string, v *structpb.Value) {
+	if s, ok := v.GetKind().(*structpb.Value_StructValue); ok {
+		for key, field := range s.StructValue.GetFields() {
+			flattenValue(row, name+"_"+flatColumnName(key), field)
+		}
+		return
+	}
+	row[name] = valueString(v)
+}
+
+// flatColumnName replaces the characters of key that are not letters, digits,
+// or underscores with underscores, e.g. "Full scan" becomes "Full_scan".
+func flatColumnName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return '_'
+	}, key)
+}
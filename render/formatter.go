@@ -0,0 +1,74 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Formatter is a custom renderASCII format registered with
+// RegisterFormatter. It is called with the JSON of the row model, an object
+// with the rows, and returns the rendered text.
+type Formatter func(modelJSON []byte) (string, error)
+
+// customFormatters holds the formatters registered with RegisterFormatter,
+// keyed by upper-cased format name like the built-in formats.
+var customFormatters = make(map[string]Formatter)
+
+// formatterModel is the argument passed to a formatter.
+type formatterModel struct {
+	Rows []planRow `json:"rows"`
+}
+
+// RegisterFormatter registers f as the renderer for a custom renderASCII
+// format name. A nil f unregisters the name.
+func RegisterFormatter(name string, f Formatter) error {
+	if name == "" {
+		return InvalidParametersError{msg: "Formatter name must be a non-empty string"}
+	}
+	if isBuiltinFormat(name) {
+		return InvalidParametersError{msg: fmt.Sprintf("Cannot override built-in format: %s", name)}
+	}
+	if _, ok := lookupRenderer(name); ok {
+		return InvalidParametersError{msg: fmt.Sprintf("Format %s is registered with RegisterRenderer", name)}
+	}
+
+	key := strings.ToUpper(name)
+	if f == nil {
+		delete(customFormatters, key)
+		return nil
+	}
+	customFormatters[key] = f
+	return nil
+}
+
+// lookupFormatter returns the custom formatter registered for format, if any.
+func lookupFormatter(format string) (Formatter, bool) {
+	f, ok := customFormatters[strings.ToUpper(format)]
+	return f, ok
+}
+
+// runFormatter renders rows with a custom formatter. Failures and panics of
+// the formatter are reported as render errors.
+func runFormatter(name string, f Formatter, rows []planRow) (result string, err error) {
+	b, err := json.Marshal(formatterModel{Rows: rows})
+	if err != nil {
+		return "", RenderError{msg: fmt.Sprintf("Failed to marshal row model: %v", err)}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = RenderError{msg: fmt.Sprintf("Formatter %s failed: %v", name, r)}
+		}
+	}()
+	result, err = f(b)
+	if err != nil {
+		var renderErr RenderError
+		if !errors.As(err, &renderErr) {
+			err = RenderError{msg: fmt.Sprintf("Formatter %s failed: %v", name, err)}
+		}
+		return "", err
+	}
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Operator categories, following the sections of the Spanner "Query execution
+// operators" reference
+const (
+	operatorCategoryLeaf        = "leaf"
+	operatorCategoryUnary       = "unary"
+	operatorCategoryBinary      = "binary"
+	operatorCategoryNary        = "n-ary"
+	operatorCategoryDistributed = "distributed"
+	operatorCategoryScalar      = "scalar"
+)
+
+const operatorDocBaseURL = "https://cloud.google.com/spanner/docs/query-execution-operators"
+
+// GlossaryEntry describes a query plan operator
+type GlossaryEntry struct {
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+	DocURL      string `json:"docUrl"`
+}
+
+// operatorGlossary is the authoritative operator reference shared by the
+// narrative generator, lint messages, and the frontend (via getGlossary).
+// Names are plan display names; entries are ordered by category, then name.
+var operatorGlossary = []GlossaryEntry{
+	glossaryEntry("Array Unnest", operatorCategoryLeaf, "array_unnest", "Flattens an input array into rows of elements."),
+	glossaryEntry("Empty Relation", operatorCategoryLeaf, "empty_relation", "Produces no rows; used when the optimizer proves a subtree empty."),
+	glossaryEntry("Generate Relation", operatorCategoryLeaf, "generate_relation", "Returns the rows of a literal table such as a VALUES list."),
+	glossaryEntry("Scan", operatorCategoryLeaf, "scan", "Reads rows from a table, an index, or a batch of rows produced earlier in the plan."),
+	glossaryEntry("Unit Relation", operatorCategoryLeaf, "unit_relation", "Produces a single row with no columns."),
+
+	glossaryEntry("Aggregate", operatorCategoryUnary, "aggregate", "Groups its input rows and computes aggregate functions such as COUNT or SUM."),
+	glossaryEntry("Apply Mutations", operatorCategoryUnary, "apply_mutations", "Applies the mutations of a DML statement to a table."),
+	glossaryEntry("Compute", operatorCategoryUnary, "compute", "Evaluates scalar expressions for each input row."),
+	glossaryEntry("Compute Struct", operatorCategoryUnary, "compute_struct", "Builds a STRUCT value from the columns of each input row."),
+	glossaryEntry("Create Batch", operatorCategoryUnary, "create_batch", "Groups input rows into batches that are sent to a distributed apply."),
+	glossaryEntry("DataBlockToRowAdapter", operatorCategoryUnary, "datablocktorowadapter", "Converts batch-oriented data blocks back into rows."),
+	glossaryEntry("Filter", operatorCategoryUnary, "filter", "Keeps only the input rows that satisfy a condition."),
+	glossaryEntry("Filter Scan", operatorCategoryUnary, "filter_scan", "Restricts a scan to key ranges (seek) and applies residual conditions while reading."),
+	glossaryEntry("Limit", operatorCategoryUnary, "limit", "Returns at most a fixed number of input rows."),
+	glossaryEntry("Local Split Union", operatorCategoryUnary, "local_split_union", "Runs its input on each split local to the server and combines the results."),
+	glossaryEntry("Random Id Assign", operatorCategoryUnary, "random_id_assign", "Assigns random identifiers to input rows."),
+	glossaryEntry("RowToDataBlockAdapter", operatorCategoryUnary, "rowtodatablockadapter", "Converts rows into batch-oriented data blocks."),
+	glossaryEntry("Serialize Result", operatorCategoryUnary, "serialize_result", "Serializes the final rows of the query to return them to the client."),
+	glossaryEntry("Sort", operatorCategoryUnary, "sort", "Sorts all of its input rows."),
+	glossaryEntry("Sort Limit", operatorCategoryUnary, "sort_limit", "Sorts its input and keeps only the first rows, like ORDER BY with LIMIT."),
+	glossaryEntry("TVF", operatorCategoryUnary, "tvf", "Evaluates a table-valued function."),
+	glossaryEntry("Union Input", operatorCategoryUnary, "union_input", "Feeds one input of a Union All."),
+
+	glossaryEntry("Cross Apply", operatorCategoryBinary, "cross_apply", "For each row of the input side, evaluates the map side and returns the joined rows."),
+	glossaryEntry("Hash Join", operatorCategoryBinary, "hash_join", "Builds a hash table from the build side and probes it with the rows of the probe side."),
+	glossaryEntry("Merge Join", operatorCategoryBinary, "merge_join", "Joins two inputs that are sorted on the join keys."),
+	glossaryEntry("Outer Apply", operatorCategoryBinary, "outer_apply", "Like Cross Apply, but keeps input rows for which the map side returns no rows."),
+	glossaryEntry("Push Broadcast Hash Join", operatorCategoryBinary, "push_broadcast_hash_join", "Broadcasts the build side to the servers holding the probe side and joins there."),
+	glossaryEntry("Recursive Union", operatorCategoryBinary, "recursive_union", "Evaluates a recursive query by repeatedly applying the recursive term."),
+
+	glossaryEntry("Union All", operatorCategoryNary, "union_all", "Concatenates the rows of all its inputs."),
+
+	glossaryEntry("Distributed Cross Apply", operatorCategoryDistributed, "distributed_cross_apply", "Sends batches of input rows to the servers owning the matching splits and runs the map side there."),
+	glossaryEntry("Distributed Merge Union", operatorCategoryDistributed, "distributed_merge_union", "Distributes its input across splits and merges the sorted results."),
+	glossaryEntry("Distributed Outer Apply", operatorCategoryDistributed, "distributed_outer_apply", "Like Distributed Cross Apply, but keeps input rows without matches."),
+	glossaryEntry("Distributed Union", operatorCategoryDistributed, "distributed_union", "Runs its input on the splits that may contain matching rows and unions the results."),
+
+	glossaryEntry("Array Subquery", operatorCategoryScalar, "array_subqueries", "Evaluates a subquery into an ARRAY value."),
+	glossaryEntry("Function", operatorCategoryScalar, "", "Evaluates a scalar function or operator."),
+	glossaryEntry("Reference", operatorCategoryScalar, "", "Refers to a column or variable produced elsewhere in the plan."),
+	glossaryEntry("Scalar Subquery", operatorCategoryScalar, "scalar_subqueries", "Evaluates a subquery that returns a single value."),
+}
+
+func glossaryEntry(name, category, anchor, description string) GlossaryEntry {
+	url := operatorDocBaseURL
+	if anchor != "" {
+		url += "#" + anchor
+	}
+	return GlossaryEntry{Name: name, Category: category, Description: description, DocURL: url}
+}
+
+// lookupOperator returns the glossary entry for a plan display name.
+func lookupOperator(name string) (GlossaryEntry, bool) {
+	i := slices.IndexFunc(operatorGlossary, func(e GlossaryEntry) bool { return e.Name == name })
+	if i < 0 {
+		return GlossaryEntry{}, false
+	}
+	return operatorGlossary[i], true
+}
+
+type glossaryParams struct {
+	Name     string `json:"name,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// getGlossaryImpl returns the glossary entries matching the optional name and
+// category filters as a JSON array in Response.Result.
+func getGlossaryImpl(par glossaryParams) (Response, error) {
+	entries := []GlossaryEntry{}
+	for _, e := range operatorGlossary {
+		if par.Name != "" && e.Name != par.Name {
+			continue
+		}
+		if par.Category != "" && e.Category != par.Category {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if par.Name != "" && len(entries) == 0 {
+		return Response{}, InvalidParametersError{msg: fmt.Sprintf("Unknown operator: %q", par.Name)}
+	}
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal glossary: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
@@ -0,0 +1,61 @@
+package render
+
+// NodeAnnotation is the heat of an operator, returned in Response.Annotations
+// with the heatmap option. Shares are from 0 to 1 and sum to 1 over the
+// operators that have the stat; operators without it have no share.
+type NodeAnnotation struct {
+	NodeID int32 `json:"nodeId"`
+	// LatencyShare and CPUShare are the operator's share of the self time of
+	// all operators, as in NodeCost
+	LatencyShare *float64 `json:"latencyShare,omitempty"`
+	// RowsShare is the operator's share of the rows returned by all
+	// operators
+	RowsShare *float64 `json:"rowsShare,omitempty"`
+	CPUShare  *float64 `json:"cpuShare,omitempty"`
+}
+
+// rowsShares returns each operator's share of the rows returned by all
+// operators, or nil if no operator has the rows stat.
+func rowsShares(tree *planTree) map[int32]float64 {
+	shares := make(map[int32]float64)
+	var total float64
+	tree.root.walk(func(n *treeNode) {
+		if rows, ok := n.stat("rows"); ok {
+			shares[n.id()] = rows
+			total += rows
+		}
+	})
+	if len(shares) == 0 {
+		return nil
+	}
+	for id, rows := range shares {
+		if total > 0 {
+			shares[id] = rows / total
+		} else {
+			shares[id] = 0
+		}
+	}
+	return shares
+}
+
+// heatmapAnnotations returns the NodeAnnotation of every operator of tree in
+// pre-order, so that frontends can paint a tree or minimap of the whole plan.
+func heatmapAnnotations(tree *planTree) []NodeAnnotation {
+	latency := selfTimeShares(tree, "latency")
+	rows := rowsShares(tree)
+	cpu := selfTimeShares(tree, "cpu_time")
+	share := func(shares map[int32]float64, id int32) *float64 {
+		v, ok := shares[id]
+		return optional(v, ok)
+	}
+	annotations := []NodeAnnotation{}
+	tree.root.walk(func(n *treeNode) {
+		annotations = append(annotations, NodeAnnotation{
+			NodeID:       n.id(),
+			LatencyShare: share(latency, n.id()),
+			RowsShare:    share(rows, n.id()),
+			CPUShare:     share(cpu, n.id()),
+		})
+	})
+	return annotations
+}
@@ -0,0 +1,224 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Codes of ErrorHint
+const (
+	// HintCodeCaptureProfile is for query results captured without the plan
+	HintCodeCaptureProfile = "CAPTURE_PROFILE"
+	// HintCodeFieldCasing is for plan fields spelled in the casing of
+	// another source, or mixing the casings of two
+	HintCodeFieldCasing = "FIELD_CASING"
+	// HintCodeTabIndentation is for YAML indented with tabs
+	HintCodeTabIndentation = "TAB_INDENTATION"
+	// HintCodeRaiseInputLimit is for input over an inputLimits limit
+	HintCodeRaiseInputLimit = "RAISE_INPUT_LIMIT"
+)
+
+// ErrorHint is a suggested fix for an error, e.g. how to capture the input
+// again
+type ErrorHint struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// hintedError is an error about an input with the hints for that input.
+type hintedError struct {
+	err   error
+	hints []ErrorHint
+}
+
+func (e hintedError) Error() string {
+	return e.err.Error()
+}
+
+func (e hintedError) Unwrap() error {
+	return e.err
+}
+
+// inputHintRules derive hints from an input that failed to parse or validate.
+var inputHintRules = []func(input string) (ErrorHint, bool){
+	queryResultsHint,
+	fieldCasingHint,
+	tabIndentationHint,
+}
+
+// withInputHints returns err with the hints of inputHintRules for input, or
+// err itself if there are none.
+func withInputHints(err error, input string) error {
+	var hints []ErrorHint
+	for _, rule := range inputHintRules {
+		if hint, ok := rule(input); ok {
+			hints = append(hints, hint)
+		}
+	}
+	if len(hints) == 0 {
+		return err
+	}
+	return hintedError{err: err, hints: hints}
+}
+
+// errorHints returns the hints of the errors joined in err, each once.
+func errorHints(err error) []ErrorHint {
+	var hints []ErrorHint
+	add := func(hint ErrorHint) {
+		if !slices.Contains(hints, hint) {
+			hints = append(hints, hint)
+		}
+	}
+	for _, e := range flattenErrors(err) {
+		var hinted hintedError
+		if errors.As(e, &hinted) {
+			for _, hint := range hinted.hints {
+				add(hint)
+			}
+		}
+		var tooLargeErr InputTooLargeError
+		if errors.As(e, &tooLargeErr) {
+			add(ErrorHint{Code: HintCodeRaiseInputLimit, Message: fmt.Sprintf("Raise %s to at least %d, or pass a smaller plan", tooLargeErr.option, tooLargeErr.size)})
+		}
+	}
+	return hints
+}
+
+// inputKeyPattern matches the keys of JSON objects and YAML mappings, quoted
+// or not.
+var inputKeyPattern = regexp.MustCompile(`(?m)(?:^|[{,])[ \t]*(?:-[ \t]+)?["']?([A-Za-z][A-Za-z0-9_]*)["']?[ \t]*:`)
+
+// inputKeys returns the keys of input in order of appearance, each once.
+func inputKeys(input string) []string {
+	var keys []string
+	for _, m := range inputKeyPattern.FindAllStringSubmatch(input, -1) {
+		if !slices.Contains(keys, m[1]) {
+			keys = append(keys, m[1])
+		}
+	}
+	return keys
+}
+
+// foldFieldName folds the spellings of a field name, e.g. "plan_nodes" and
+// "PlanNodes" to "plannodes".
+func foldFieldName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// casedField is a field of the input messages with different proto and JSON
+// names, e.g. "plan_nodes" and "planNodes".
+type casedField struct {
+	snake, camel string
+}
+
+// casedFields are the casedField of ResultSet and the messages it nests by
+// their folded name.
+var casedFields = func() map[string]casedField {
+	fields := make(map[string]casedField)
+	seen := make(map[protoreflect.FullName]bool)
+	var visit func(protoreflect.MessageDescriptor)
+	visit = func(md protoreflect.MessageDescriptor) {
+		// The well-known types such as Struct are JSON values, not objects
+		if seen[md.FullName()] || strings.HasPrefix(string(md.FullName()), "google.protobuf.") {
+			return
+		}
+		seen[md.FullName()] = true
+		for i := 0; i < md.Fields().Len(); i++ {
+			fd := md.Fields().Get(i)
+			if snake, camel := string(fd.Name()), fd.JSONName(); snake != camel {
+				fields[foldFieldName(snake)] = casedField{snake: snake, camel: camel}
+			}
+			if fd.Message() != nil && !fd.IsMap() {
+				visit(fd.Message())
+			}
+		}
+	}
+	visit((&sppb.ResultSet{}).ProtoReflect().Descriptor())
+	return fields
+}()
+
+// queryResultsHint is for input with the rows or row type of a result set
+// but no plan, as printed by queries run in the default NORMAL mode.
+func queryResultsHint(input string) (ErrorHint, bool) {
+	results := false
+	for _, key := range inputKeys(input) {
+		switch foldFieldName(key) {
+		case "queryplan", "plannodes":
+			return ErrorHint{}, false
+		case "rows", "rowtype":
+			results = true
+		}
+	}
+	if !results {
+		return ErrorHint{}, false
+	}
+	return ErrorHint{
+		Code:    HintCodeCaptureProfile,
+		Message: "The input looks like query results without a query plan; re-run the query with --query-mode=PROFILE, e.g. gcloud spanner databases execute-sql --query-mode=PROFILE --format=yaml",
+	}, true
+}
+
+// fieldCasingHint is for input with plan fields that are neither snake_case,
+// as printed by gRPC tooling, nor camelCase, as returned by the REST API and
+// gcloud, e.g. "PlanNodes", or that mix the two. The casing of the most
+// fields is taken as the source of the input.
+func fieldCasingHint(input string) (ErrorHint, bool) {
+	var snake, camel, other []string
+	for _, key := range inputKeys(input) {
+		field, ok := casedFields[foldFieldName(key)]
+		switch {
+		case !ok:
+		case key == field.snake:
+			snake = append(snake, key)
+		case key == field.camel:
+			camel = append(camel, key)
+		default:
+			other = append(other, key)
+		}
+	}
+	source, mismatched := "the REST API or gcloud, whose fields are camelCase", snake
+	spell := func(f casedField) string { return f.camel }
+	if len(snake) > len(camel) {
+		source, mismatched = "gRPC tooling, whose fields are snake_case", camel
+		spell = func(f casedField) string { return f.snake }
+	}
+	mismatched = append(slices.Clip(mismatched), other...)
+	if len(mismatched) == 0 {
+		return ErrorHint{}, false
+	}
+	var renames []string
+	for i, key := range mismatched {
+		if i == 3 {
+			renames = append(renames, fmt.Sprintf("%d more", len(mismatched)-i))
+			break
+		}
+		renames = append(renames, fmt.Sprintf("%s to %s", key, spell(casedFields[foldFieldName(key)])))
+	}
+	return ErrorHint{
+		Code:    HintCodeFieldCasing,
+		Message: fmt.Sprintf("The input looks like the output of %s, but spells some fields otherwise; rename %s", source, strings.Join(renames, ", ")),
+	}, true
+}
+
+// tabIndentationHint is for YAML with a tab in the indentation of a line.
+func tabIndentationHint(input string) (ErrorHint, bool) {
+	if strings.HasPrefix(strings.TrimSpace(input), "{") {
+		return ErrorHint{}, false
+	}
+	for i, line := range strings.Split(input, "\n") {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if strings.Contains(indent, "\t") {
+			return ErrorHint{
+				Code:    HintCodeTabIndentation,
+				Message: fmt.Sprintf("Line %d is indented with a tab, which YAML does not allow; indent with spaces", i+1),
+			}, true
+		}
+	}
+	return ErrorHint{}, false
+}
@@ -0,0 +1,228 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// formatHTML is the renderASCII format that renders a semantic HTML table.
+const formatHTML = "HTML"
+
+func isHTMLFormat(format string) bool {
+	return strings.EqualFold(format, formatHTML)
+}
+
+// htmlColumn is a column of the HTML table. class is its CSS class, on both
+// the header and the cells.
+type htmlColumn struct {
+	header string
+	class  string
+	cell   func(n *treeNode, row planRow) string
+}
+
+// htmlStatCell returns the cell of an execution stat, e.g. "1.5 msecs".
+func htmlStatCell(name string) func(n *treeNode, row planRow) string {
+	return func(_ *treeNode, row planRow) string {
+		stat, ok := row.Stats[name]
+		if !ok {
+			return ""
+		}
+		if name == "rows" {
+			return stat.Total
+		}
+		return strings.TrimSpace(stat.Total + " " + stat.Unit)
+	}
+}
+
+// htmlColumns are the built-in columns of the HTML table, named like those
+// of the text table so that the columns option selects the same columns.
+var htmlColumns = []htmlColumn{
+	{header: "ID", class: "id", cell: func(_ *treeNode, row planRow) string { return strconv.Itoa(int(row.ID)) }},
+	{header: "Operator", class: "operator"},
+	{header: "Rows", class: "rows", cell: htmlStatCell("rows")},
+	{header: "Exec.", class: "executions", cell: func(n *treeNode, _ planRow) string {
+		summary := n.node.GetExecutionStats().GetFields()["execution_summary"]
+		return valueString(summary.GetStructValue().GetFields()["num_executions"])
+	}},
+	{header: "Total Latency", class: "latency", cell: htmlStatCell("latency")},
+	{header: cpuColumnTitle, class: "cpu", cell: htmlStatCell("cpu_time")},
+}
+
+// htmlTemplateColumn returns the column of a templateColumns template.
+// Failures leave the cell empty and are reported by the caller.
+func htmlTemplateColumn(t *template.Template, failed map[int32]error) htmlColumn {
+	return htmlColumn{header: t.Name(), class: "template", cell: func(_ *treeNode, row planRow) string {
+		var b strings.Builder
+		if err := t.Execute(&b, row); err != nil {
+			failed[row.ID] = err
+			return ""
+		}
+		return strings.Join(strings.Fields(b.String()), " ")
+	}}
+}
+
+// htmlOptions are the renderASCII options that apply to the HTML format.
+type htmlOptions struct {
+	withStats bool
+	// columns are the selected columns, or nil for the default columns
+	columns   []string
+	templates []*template.Template
+	groups    []ColumnGroup
+	// keep limits the rows to an operator filter, or nil for all rows
+	keep map[int32]bool
+	// costs are the cells of the cost column, or nil without the column
+	costs map[int32]string
+}
+
+// renderHTMLTable renders rows as a <table>. Each row has a data-node-id
+// attribute and a "node-<ID>" anchor, and each cell the CSS class of its
+// column. The operator cell is indented by depth with an inline style, so
+// that exported snippets keep the tree shape without a style sheet, and holds
+// the predicates and annotation of the operator.
+func renderHTMLTable(tree *planTree, rows []planRow, opts htmlOptions) (string, []Warning, error) {
+	failed := make(map[int32]error)
+	available := []htmlColumn{htmlColumns[0], htmlColumns[1]}
+	if opts.withStats {
+		available = append(available, htmlColumns[2:]...)
+	}
+	if opts.costs != nil {
+		available = append(available, htmlColumn{header: costColumnTitle, class: "cost", cell: func(_ *treeNode, row planRow) string {
+			return opts.costs[row.ID]
+		}})
+	}
+	for _, t := range opts.templates {
+		available = append(available, htmlTemplateColumn(t, failed))
+	}
+
+	var warnings []Warning
+	columns := available
+	if opts.columns != nil {
+		columns = nil
+		for _, name := range opts.columns {
+			i := slices.IndexFunc(available, func(c htmlColumn) bool { return c.header == name })
+			if i < 0 {
+				warnings = append(warnings, Warning{
+					Code:    WarningCodeColumnUnavailable,
+					Message: fmt.Sprintf("Column %q is not available for this plan, mode, and format", name),
+				})
+				continue
+			}
+			columns = append(columns, available[i])
+		}
+	} else {
+		// CPU is only shown when selected, as in the text table
+		columns = slices.DeleteFunc(slices.Clone(available), func(c htmlColumn) bool { return c.header == cpuColumnTitle })
+	}
+
+	groupRow, err := htmlGroupRow(columns, opts.groups)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString("<table class=\"rendertree-plan\">\n<thead>\n")
+	b.WriteString(groupRow)
+	b.WriteString("<tr>")
+	for _, c := range columns {
+		fmt.Fprintf(&b, "<th class=\"%s\">%s</th>", c.class, html.EscapeString(c.header))
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, row := range rows {
+		if opts.keep != nil && !opts.keep[row.ID] {
+			continue
+		}
+		n := tree.nodes[row.ID]
+		fmt.Fprintf(&b, "<tr id=\"node-%d\" data-node-id=\"%d\" data-depth=\"%d\">", row.ID, row.ID, row.Depth)
+		for _, c := range columns {
+			if c.cell == nil {
+				b.WriteString(htmlOperatorCell(row))
+				continue
+			}
+			fmt.Fprintf(&b, "<td class=\"%s\">%s</td>", c.class, html.EscapeString(c.cell(n, row)))
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+
+	if len(failed) > 0 {
+		ids := slices.Sorted(maps.Keys(failed))
+		id := ids[0]
+		warnings = append(warnings, Warning{
+			Code:    WarningCodeTemplateColumnError,
+			Message: fmt.Sprintf("Template columns failed for %d operators: %v", len(ids), failed[id]),
+			NodeID:  &id,
+		})
+	}
+	return b.String(), warnings, nil
+}
+
+// htmlOperatorCell returns the operator cell of row.
+func htmlOperatorCell(row planRow) string {
+	var b strings.Builder
+	b.WriteString("<td class=\"operator\"")
+	if row.Depth > 0 {
+		fmt.Fprintf(&b, " style=\"padding-left: %gem\"", float64(row.Depth)*1.5)
+	}
+	b.WriteString(">")
+	if row.Collapsed > 0 {
+		b.WriteString(html.EscapeString(collapsedMarker + row.Title + " " + collapsedSummary(row)))
+	} else {
+		b.WriteString(html.EscapeString(row.Title))
+	}
+	for _, p := range row.Predicates {
+		fmt.Fprintf(&b, "<div class=\"predicate\"><span class=\"predicate-type\">%s</span>: %s</div>",
+			html.EscapeString(p.Type), html.EscapeString(p.Description))
+	}
+	if row.Annotation != "" {
+		fmt.Fprintf(&b, "<div class=\"annotation\">%s</div>", html.EscapeString(row.Annotation))
+	}
+	b.WriteString("</td>")
+	return b.String()
+}
+
+// htmlGroupRow returns the header row of the column groups, with a cell
+// spanning the columns of each group, or "" without groups. Unknown and
+// non-adjacent columns are errors, as in the text table.
+func htmlGroupRow(columns []htmlColumn, groups []ColumnGroup) (string, error) {
+	if len(groups) == 0 {
+		return "", nil
+	}
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = c.header
+	}
+	groupOf, err := columnGroupSpans(headers, groups)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("<tr class=\"column-groups\">")
+	for i := 0; i < len(columns); {
+		gi := groupOf[i]
+		if gi < 0 {
+			b.WriteString("<th></th>")
+			i++
+			continue
+		}
+		end := i
+		for end < len(columns) && groupOf[end] == gi {
+			end++
+		}
+		fmt.Fprintf(&b, "<th colspan=\"%d\">%s</th>", end-i, html.EscapeString(strings.TrimSpace(groups[gi].Title)))
+		i = end
+	}
+	b.WriteString("</tr>\n")
+	return b.String(), nil
+}
+
+// htmlPre wraps text, such as the query header or footnotes, in a <pre>
+// element with the given class.
+func htmlPre(class, text string) string {
+	return fmt.Sprintf("<pre class=\"%s\">%s</pre>\n", class, html.EscapeString(strings.TrimRight(text, "\n")))
+}
@@ -0,0 +1,596 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	queryplan "github.com/apstndb/spannerplan"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// Input formats reported as detectedFormat
+const (
+	inputFormatProtoBase64 = "proto-base64"
+	inputFormatPrototext   = "prototext"
+	// inputFormatJSONGRPC is JSON with the proto field names, e.g.
+	// plan_nodes, as printed by gRPC tooling
+	inputFormatJSONGRPC = "json-grpc"
+	// inputFormatJSONREST is JSON with lowerCamelCase field names, e.g.
+	// planNodes, as returned by the REST API
+	inputFormatJSONREST = "json-rest"
+	inputFormatYAML     = "yaml"
+	// inputFormatJSON names failed JSON attempts, whose flavor is unknown
+	inputFormatJSON = "json"
+)
+
+// inputAttempt is a decoder that rejected the input.
+type inputAttempt struct {
+	format string
+	err    error
+}
+
+// inputDetectionError is a parse failure with every decoder that was tried.
+// Its message is that of err, the most relevant failure; the attempts are
+// reported as the error details.
+type inputDetectionError struct {
+	err      error
+	attempts []inputAttempt
+}
+
+func (e inputDetectionError) Error() string {
+	return e.err.Error()
+}
+
+func (e inputDetectionError) Unwrap() error {
+	return e.err
+}
+
+// details lists the attempts, e.g. "Tried json: unexpected EOF; yaml: ...".
+// Only the first line of each error is kept, as YAML errors quote the input.
+func (e inputDetectionError) details() string {
+	tried := make([]string, len(e.attempts))
+	for i, a := range e.attempts {
+		msg, _, _ := strings.Cut(a.err.Error(), "\n")
+		tried[i] = a.format + ": " + msg
+	}
+	return "Tried " + strings.Join(tried, "; ")
+}
+
+// jsonInputFormat tells gRPC from REST JSON by the casing of the plan
+// fields.
+func jsonInputFormat(input string) string {
+	for _, key := range []string{`"plan_nodes"`, `"query_plan"`, `"child_links"`, `"display_name"`} {
+		if strings.Contains(input, key) {
+			return inputFormatJSONGRPC
+		}
+	}
+	return inputFormatJSONREST
+}
+
+// parseQueryPlan is the input sniffer: gzip input, raw or base64-encoded, is
+// decompressed first, base64 that decodes to a binary plan is used as such,
+// inputs that look like prototext are decoded as such, JSON objects are
+// streamed or, without a plan at the top level, searched for one wrapped in an
+// envelope such as a Cloud Logging entry, and everything else (or input the
+// specialized decoders reject) goes to queryplan.ExtractQueryPlan, which reads
+// YAML such as gcloud --format=yaml and spanner-cli output. JSON and YAML
+// syntax errors are reported with their line and column. It returns the input
+// format that was understood, or an inputDetectionError.
+func parseQueryPlan(input string) (*sppb.ResultSetStats, *sppb.StructType, string, error) {
+	if b, ok := compressedPayload(input); ok {
+		return parseCompressedQueryPlan(b)
+	}
+	var attempts []inputAttempt
+	if looksLikeProtoBase64(input) {
+		stats, rowType, err := extractQueryPlanProtoBase64(input)
+		if err == nil {
+			return stats, rowType, inputFormatProtoBase64, nil
+		}
+		attempts = append(attempts, inputAttempt{inputFormatProtoBase64, err})
+	}
+	if looksLikePrototext(input) {
+		stats, rowType, err := extractQueryPlanPrototext(input)
+		if err == nil {
+			return stats, rowType, inputFormatPrototext, nil
+		}
+		attempts = append(attempts, inputAttempt{inputFormatPrototext, err})
+		stats, rowType, yamlErr := queryplan.ExtractQueryPlan([]byte(input))
+		if yamlErr == nil {
+			return stats, rowType, inputFormatYAML, nil
+		}
+		attempts = append(attempts, inputAttempt{inputFormatYAML, yamlErr})
+		return nil, nil, "", inputDetectionError{fmt.Errorf("invalid prototext: %w", err), attempts}
+	}
+	if strings.HasPrefix(strings.TrimSpace(input), "{") {
+		stats, rowType, err := extractQueryPlanJSON(input)
+		if err == nil {
+			return stats, rowType, jsonInputFormat(input), nil
+		}
+		if errors.Is(err, errNoQueryPlan) {
+			stats, rowType, format, envelopeErr := extractQueryPlanEnvelope(input)
+			if envelopeErr == nil {
+				return stats, rowType, format, nil
+			}
+		}
+		attempts = append(attempts, inputAttempt{inputFormatJSON, err})
+		stats, rowType, yamlErr := queryplan.ExtractQueryPlan([]byte(input))
+		if yamlErr == nil {
+			return stats, rowType, inputFormatYAML, nil
+		}
+		attempts = append(attempts, inputAttempt{inputFormatYAML, yamlErr})
+		if syntaxErr := jsonSyntaxError(input, err); syntaxErr != nil {
+			return nil, nil, "", inputDetectionError{syntaxErr, attempts}
+		}
+		return nil, nil, "", inputDetectionError{yamlErr, attempts}
+	}
+	stats, rowType, err := queryplan.ExtractQueryPlan([]byte(input))
+	if err == nil {
+		return stats, rowType, inputFormatYAML, nil
+	}
+	attempts = append(attempts, inputAttempt{inputFormatYAML, err})
+	if syntaxErr := checkYAMLSyntax(input); syntaxErr != nil {
+		return nil, nil, "", inputDetectionError{syntaxErr, attempts}
+	}
+	return nil, nil, "", inputDetectionError{err, attempts}
+}
+
+// checkYAMLSyntax returns an error locating the first YAML syntax problem in
+// input, such as a tab in the indentation of gcloud or spanner-cli output
+// edited by hand, or nil if input is well-formed YAML.
+func checkYAMLSyntax(input string) error {
+	_, err := parser.ParseBytes([]byte(input), 0)
+	if err == nil {
+		return nil
+	}
+	var yamlErr yaml.Error
+	if errors.As(err, &yamlErr) {
+		if tk := yamlErr.GetToken(); tk != nil && tk.Position != nil {
+			return inputSyntaxError{
+				msg: fmt.Sprintf("invalid YAML at line %d, column %d: %s", tk.Position.Line, tk.Position.Column, yamlErr.GetMessage()),
+				pos: positionAt(input, tk.Position.Line, tk.Position.Column),
+			}
+		}
+		return fmt.Errorf("invalid YAML: %s", yamlErr.GetMessage())
+	}
+	return fmt.Errorf("invalid YAML: %w", err)
+}
+
+var protojsonOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// errNoQueryPlan is returned by extractQueryPlanJSON for JSON objects that
+// are not a ResultSet, ResultSetStats, or QueryPlan.
+var errNoQueryPlan = errors.New("no query plan found")
+
+// extractQueryPlanJSON decodes a JSON ResultSet, ResultSetStats, or QueryPlan
+// with a streaming json.Decoder. Plan nodes are decoded one at a time and
+// result rows are skipped token by token, so that peak memory stays close to
+// the size of the input string instead of several copies of it, as with
+// whole-document decoding of large PROFILE captures. Like
+// spannerplan.ExtractQueryPlan, a top-level queryPlan makes the input a
+// ResultSetStats, then planNodes a QueryPlan, then stats a ResultSet.
+func extractQueryPlanJSON(input string) (*sppb.ResultSetStats, *sppb.StructType, error) {
+	dec := json.NewDecoder(strings.NewReader(input))
+
+	var stats *sppb.ResultSetStats
+	var plan *sppb.QueryPlan
+	var planNodes []*sppb.PlanNode
+	rest, err := decodeObjectFields(dec, func(key string) (bool, error) {
+		var err error
+		switch key {
+		case "stats":
+			// stats without a plan only matters when nothing else has one
+			stats, err = decodeStatsJSON(dec)
+			if errors.Is(err, errNoQueryPlan) {
+				return true, nil
+			}
+		case "queryPlan", "query_plan":
+			plan, err = decodeQueryPlanJSON(dec)
+		case "planNodes", "plan_nodes":
+			planNodes, err = decodePlanNodesJSON(dec)
+		case "rows":
+			err = skipValue(dec)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, nil, errors.New("unexpected data after top-level value")
+	}
+
+	switch {
+	case plan != nil:
+		stats = &sppb.ResultSetStats{}
+		if err := unmarshalRest(rest, stats); err != nil {
+			return nil, nil, err
+		}
+		stats.QueryPlan = plan
+		return stats, nil, nil
+	case planNodes != nil:
+		plan = &sppb.QueryPlan{}
+		if err := unmarshalRest(rest, plan); err != nil {
+			return nil, nil, err
+		}
+		plan.PlanNodes = planNodes
+		return &sppb.ResultSetStats{QueryPlan: plan}, nil, nil
+	case stats != nil:
+		var metadata sppb.ResultSetMetadata
+		if err := unmarshalMember(rest, "metadata", &metadata); err != nil {
+			return nil, nil, err
+		}
+		return stats, metadata.GetRowType(), nil
+	default:
+		return nil, nil, errNoQueryPlan
+	}
+}
+
+// decodeStatsJSON decodes a ResultSetStats object, streaming its query plan.
+func decodeStatsJSON(dec *json.Decoder) (*sppb.ResultSetStats, error) {
+	var plan *sppb.QueryPlan
+	rest, err := decodeObjectFields(dec, func(key string) (bool, error) {
+		if key != "queryPlan" && key != "query_plan" {
+			return false, nil
+		}
+		var err error
+		plan, err = decodeQueryPlanJSON(dec)
+		return true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, errNoQueryPlan
+	}
+
+	stats := &sppb.ResultSetStats{}
+	if err := unmarshalRest(rest, stats); err != nil {
+		return nil, err
+	}
+	stats.QueryPlan = plan
+	return stats, nil
+}
+
+// decodeQueryPlanJSON decodes a QueryPlan object, streaming its plan nodes.
+func decodeQueryPlanJSON(dec *json.Decoder) (*sppb.QueryPlan, error) {
+	var planNodes []*sppb.PlanNode
+	rest, err := decodeObjectFields(dec, func(key string) (bool, error) {
+		if key != "planNodes" && key != "plan_nodes" {
+			return false, nil
+		}
+		var err error
+		planNodes, err = decodePlanNodesJSON(dec)
+		return true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &sppb.QueryPlan{}
+	if err := unmarshalRest(rest, plan); err != nil {
+		return nil, err
+	}
+	plan.PlanNodes = planNodes
+	return plan, nil
+}
+
+// decodePlanNodesJSON decodes a planNodes array one element at a time.
+func decodePlanNodesJSON(dec *json.Decoder) ([]*sppb.PlanNode, error) {
+	if err := expectDelim(dec, '['); err != nil {
+		return nil, err
+	}
+	planNodes := []*sppb.PlanNode{}
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		node := &sppb.PlanNode{}
+		if err := protojsonOptions.Unmarshal(raw, node); err != nil {
+			return nil, fmt.Errorf("planNodes[%d]: %w", len(planNodes), err)
+		}
+		planNodes = append(planNodes, node)
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		return nil, err
+	}
+	return planNodes, nil
+}
+
+// decodeObjectFields reads a JSON object member by member. For each key,
+// stream is called with the decoder positioned at the value; if it does not
+// handle the key, the value is collected as raw JSON into the returned map.
+func decodeObjectFields(dec *json.Decoder, stream func(key string) (bool, error)) (map[string]json.RawMessage, error) {
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	rest := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v", tok)
+		}
+		handled, err := stream(key)
+		if err != nil {
+			return nil, err
+		}
+		if handled {
+			continue
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		rest[key] = raw
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	return rest, nil
+}
+
+// unmarshalRest decodes the collected object members into m.
+func unmarshalRest(rest map[string]json.RawMessage, m proto.Message) error {
+	if len(rest) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(rest)
+	if err != nil {
+		return err
+	}
+	return protojsonOptions.Unmarshal(b, m)
+}
+
+// unmarshalMember decodes the collected member key, if present, into m.
+func unmarshalMember(rest map[string]json.RawMessage, key string, m proto.Message) error {
+	b, ok := rest[key]
+	if !ok {
+		return nil
+	}
+	return protojsonOptions.Unmarshal(b, m)
+}
+
+// skipValue consumes the next value token by token without buffering it.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("expected %v, got %v", want, tok)
+	}
+	return nil
+}
+
+// planPaths spells the JSON paths of the plan elements of an input as the
+// input does, from its root: a ResultSet, ResultSetStats, or QueryPlan, with
+// lowerCamelCase or proto field names.
+type planPaths struct {
+	// queryPlan is the path of the query plan, empty when the input is one
+	queryPlan string
+	// planNodes is the path of the plan nodes
+	planNodes string
+	// protoNames spells the fields by their proto names, e.g. child_links
+	protoNames bool
+}
+
+// Roots of plan inputs, by the message they hold
+const (
+	planRootResultSet = "ResultSet"
+	planRootStats     = "ResultSetStats"
+	planRootQueryPlan = "QueryPlan"
+)
+
+func newPlanPaths(root string, protoNames bool) planPaths {
+	p := planPaths{protoNames: protoNames}
+	switch root {
+	case planRootResultSet:
+		p.queryPlan = "stats." + p.name("queryPlan")
+	case planRootStats:
+		p.queryPlan = p.name("queryPlan")
+	}
+	p.planNodes = p.name("planNodes")
+	if p.queryPlan != "" {
+		p.planNodes = p.queryPlan + "." + p.planNodes
+	}
+	return p
+}
+
+// name spells the lowerCamelCase field name camel as the input does.
+func (p planPaths) name(camel string) string {
+	if !p.protoNames {
+		return camel
+	}
+	var b strings.Builder
+	for _, r := range camel {
+		if unicode.IsUpper(r) {
+			b.WriteByte('_')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (p planPaths) planNode(i int) string {
+	return fmt.Sprintf("%s[%d]", p.planNodes, i)
+}
+
+func (p planPaths) nodeIndex(i int) string {
+	return p.planNode(i) + "." + p.name("index")
+}
+
+func (p planPaths) childIndex(i, j int) string {
+	return fmt.Sprintf("%s.%s[%d].%s", p.planNode(i), p.name("childLinks"), j, p.name("childIndex"))
+}
+
+// detectPlanPaths returns the planPaths of input, which holds a single plan.
+// The root is the one parseQueryPlan decodes, so that paths can be looked up
+// directly in the input; plans found in an envelope have paths from the plan
+// object. It reads the input again, so it is only called to report problems.
+func detectPlanPaths(input string) planPaths {
+	if b, ok := compressedPayload(input); ok {
+		data, err := decompressInput(b)
+		if err != nil || !utf8.Valid(data) {
+			return newPlanPaths(binaryPlanRoot(data), true)
+		}
+		input = string(data)
+	}
+	if looksLikeProtoBase64(input) {
+		if b, err := decodeBase64(input); err == nil {
+			return newPlanPaths(binaryPlanRoot(b), true)
+		}
+	}
+	if looksLikePrototext(input) {
+		return newPlanPaths(prototextPlanRoot(input), true)
+	}
+	var keys []string
+	if strings.HasPrefix(strings.TrimSpace(input), "{") {
+		keys = jsonTopLevelKeys(input)
+		if !slices.ContainsFunc(keys, isPlanKey) {
+			keys = envelopedPlanKeys(input)
+		}
+	} else {
+		keys = yamlTopLevelKeys(input)
+	}
+	// The precedence of extractQueryPlanJSON and queryplan.ExtractQueryPlan
+	root := planRootResultSet
+	switch {
+	case slices.Contains(keys, "queryPlan") || slices.Contains(keys, "query_plan"):
+		root = planRootStats
+	case slices.Contains(keys, "planNodes") || slices.Contains(keys, "plan_nodes"):
+		root = planRootQueryPlan
+	}
+	return newPlanPaths(root, usesProtoNames(input))
+}
+
+// usesProtoNames reports whether a JSON or YAML input spells the plan fields
+// by their proto names.
+func usesProtoNames(input string) bool {
+	for _, name := range []string{"query_plan", "plan_nodes", "child_links", "child_index"} {
+		if strings.Contains(input, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// binaryPlanRoot is the root that extractQueryPlanProtoBinary decodes.
+func binaryPlanRoot(b []byte) string {
+	var stats sppb.ResultSetStats
+	if proto.Unmarshal(b, &stats) == nil && plausiblePlanNodes(stats.GetQueryPlan().GetPlanNodes()) {
+		return planRootStats
+	}
+	var resultSet sppb.ResultSet
+	if proto.Unmarshal(b, &resultSet) == nil && plausiblePlanNodes(resultSet.GetStats().GetQueryPlan().GetPlanNodes()) {
+		return planRootResultSet
+	}
+	return planRootQueryPlan
+}
+
+// prototextPlanRoot is the root that extractQueryPlanPrototext decodes.
+func prototextPlanRoot(input string) string {
+	var resultSet sppb.ResultSet
+	if prototext.Unmarshal([]byte(input), &resultSet) == nil && resultSet.GetStats().GetQueryPlan() != nil {
+		return planRootResultSet
+	}
+	var stats sppb.ResultSetStats
+	if prototext.Unmarshal([]byte(input), &stats) == nil && stats.GetQueryPlan() != nil {
+		return planRootStats
+	}
+	return planRootQueryPlan
+}
+
+// jsonTopLevelKeys returns the member names of a JSON object, skipping their
+// values.
+func jsonTopLevelKeys(input string) []string {
+	dec := json.NewDecoder(strings.NewReader(input))
+	var keys []string
+	decodeObjectFields(dec, func(key string) (bool, error) {
+		keys = append(keys, key)
+		return true, skipValue(dec)
+	})
+	return keys
+}
+
+// isPlanKey reports whether key is a member of a plan object that
+// extractQueryPlanJSON decodes.
+func isPlanKey(key string) bool {
+	switch key {
+	case "stats", "queryPlan", "query_plan", "planNodes", "plan_nodes":
+		return true
+	}
+	return false
+}
+
+// envelopedPlanKeys returns the member names of the plan object that
+// extractQueryPlanEnvelope finds in input, if any.
+func envelopedPlanKeys(input string) []string {
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	var envelope any
+	if err := dec.Decode(&envelope); err != nil {
+		return nil
+	}
+	return sortedKeys(findEnvelopedPlan(envelope))
+}
+
+// yamlTopLevelKeys returns the keys of the top-level mapping of a YAML
+// document.
+func yamlTopLevelKeys(input string) []string {
+	file, err := parser.ParseBytes([]byte(input), 0)
+	if err != nil || len(file.Docs) == 0 {
+		return nil
+	}
+	var values []*ast.MappingValueNode
+	switch body := file.Docs[0].Body.(type) {
+	case *ast.MappingNode:
+		values = body.Values
+	case *ast.MappingValueNode:
+		values = []*ast.MappingValueNode{body}
+	}
+	keys := make([]string, 0, len(values))
+	for _, v := range values {
+		if tk := v.Key.GetToken(); tk != nil {
+			keys = append(keys, tk.Value)
+		}
+	}
+	return keys
+}
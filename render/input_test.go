@@ -0,0 +1,46 @@
+package render
+
+import (
+	"testing"
+
+	queryplan "github.com/apstndb/spannerplan"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExtractQueryPlanJSON(t *testing.T) {
+	const planNodes = `[{"index": 0, "kind": "RELATIONAL", "displayName": "Distributed Union", "childLinks": [{"childIndex": 1}]}, {"index": 1, "kind": "RELATIONAL", "displayName": "Scan"}]`
+	const otherNodes = `[{"index": 0, "kind": "RELATIONAL", "displayName": "Other"}]`
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"ResultSet", `{"metadata": {"rowType": {"fields": [{"name": "SingerId", "type": {"code": "INT64"}}]}}, "stats": {"queryPlan": {"planNodes": ` + planNodes + `}, "queryStats": {"elapsed_time": "1 msecs"}}}`},
+		{"ResultSet with rows", `{"rows": [["1"], ["2", {"nested": [null, true]}]], "stats": {"queryPlan": {"planNodes": ` + planNodes + `}}}`},
+		{"ResultSetStats", `{"queryPlan": {"planNodes": ` + planNodes + `}, "rowCountExact": "3"}`},
+		{"QueryPlan", `{"planNodes": ` + planNodes + `}`},
+		{"QueryPlan with advice", `{"planNodes": ` + planNodes + `, "queryAdvice": {"indexAdvice": [{"ddl": ["CREATE INDEX i ON t(c)"]}]}}`},
+		{"snake_case", `{"stats": {"query_plan": {"plan_nodes": ` + planNodes + `}, "row_count_exact": "3"}}`},
+		{"queryPlan over stats", `{"stats": {"queryPlan": {"planNodes": ` + otherNodes + `}}, "queryPlan": {"planNodes": ` + planNodes + `}}`},
+		{"planNodes over stats", `{"stats": {"queryPlan": {"planNodes": ` + otherNodes + `}}, "planNodes": ` + planNodes + `}`},
+		{"queryPlan over planNodes", `{"planNodes": ` + otherNodes + `, "queryPlan": {"planNodes": ` + planNodes + `}}`},
+		{"queryPlan with stats without a plan", `{"stats": {"rowCountExact": "1"}, "queryPlan": {"planNodes": ` + planNodes + `}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantStats, wantRowType, err := queryplan.ExtractQueryPlan([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("ExtractQueryPlan: %v", err)
+			}
+			stats, rowType, err := extractQueryPlanJSON(tt.input)
+			if err != nil {
+				t.Fatalf("extractQueryPlanJSON: %v", err)
+			}
+			if !proto.Equal(stats, wantStats) {
+				t.Errorf("stats = %v, want %v", stats, wantStats)
+			}
+			if !proto.Equal(rowType, wantRowType) {
+				t.Errorf("rowType = %v, want %v", rowType, wantRowType)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Default input limits, high enough for the largest real plans and low
+// enough that a pasted log file fails fast instead of hanging the page
+const (
+	defaultMaxInputBytes = 64 << 20
+	defaultMaxPlanNodes  = 100_000
+)
+
+// InputLimits bounds the input of renderASCII. They are checked before the
+// input is parsed and before the plan is rendered. Zero fields use the
+// defaults.
+type InputLimits struct {
+	// MaxBytes is the largest input, in bytes
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+	// MaxNodes is the largest number of plan nodes
+	MaxNodes int `json:"maxNodes,omitempty"`
+}
+
+func (l InputLimits) check() error {
+	switch {
+	case l.MaxBytes < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid inputLimits.maxBytes: %d (must not be negative)", l.MaxBytes)}
+	case l.MaxNodes < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid inputLimits.maxNodes: %d (must not be negative)", l.MaxNodes)}
+	}
+	return nil
+}
+
+func (l InputLimits) withDefaults() InputLimits {
+	if l.MaxBytes == 0 {
+		l.MaxBytes = defaultMaxInputBytes
+	}
+	if l.MaxNodes == 0 {
+		l.MaxNodes = defaultMaxPlanNodes
+	}
+	return l
+}
+
+// checkInputBytes rejects input larger than l.MaxBytes.
+func (l InputLimits) checkInputBytes(input string) error {
+	if size := int64(len(input)); size > l.MaxBytes {
+		return InputTooLargeError{
+			msg:    fmt.Sprintf("Input is %s, over the limit of %s; render a smaller capture, or raise inputLimits.maxBytes", formatByteSize(size), formatByteSize(l.MaxBytes)),
+			option: "inputLimits.maxBytes",
+			size:   size,
+			limit:  l.MaxBytes,
+		}
+	}
+	return nil
+}
+
+// checkPlanNodes rejects plans with more than l.MaxNodes nodes.
+func (l InputLimits) checkPlanNodes(count int) error {
+	if count > l.MaxNodes {
+		return InputTooLargeError{
+			msg:    fmt.Sprintf("Plan has %d nodes, over the limit of %d; render a subtree with rootNodeId, or raise inputLimits.maxNodes", count, l.MaxNodes),
+			option: "inputLimits.maxNodes",
+			size:   int64(count),
+			limit:  int64(l.MaxNodes),
+		}
+	}
+	return nil
+}
+
+// InputTooLargeError represents input over an InputLimits limit
+type InputTooLargeError struct {
+	msg string
+	// option is the option of the limit
+	option string
+	// size is the measured size and limit the limit, in bytes or plan nodes
+	size, limit int64
+}
+
+func (e InputTooLargeError) Error() string {
+	return e.msg
+}
+
+// inputTooLarge returns the measured size and the limit of an
+// InputTooLargeError in err, if any.
+func inputTooLarge(err error) (size, limit int64) {
+	var tooLarge InputTooLargeError
+	if errors.As(err, &tooLarge) {
+		return tooLarge.size, tooLarge.limit
+	}
+	return 0, 0
+}
+
+// formatByteSize formats a size in bytes with a binary unit, e.g. "200.0 MiB".
+func formatByteSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
@@ -0,0 +1,229 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Join types of JoinOperator
+const (
+	joinTypeHash  = "hash"
+	joinTypeApply = "apply"
+	joinTypeMerge = "merge"
+)
+
+// JoinSide is one input of a join.
+type JoinSide struct {
+	// Role is the child link type, or the role the join gives an untyped
+	// input: "Build", "Probe", "Input", "Map", "Left", or "Right"
+	Role     string `json:"role"`
+	NodeID   int32  `json:"nodeId"`
+	Operator string `json:"operator"`
+	// Rows is the number of rows the input returned, over all executions
+	Rows *float64 `json:"rows,omitempty"`
+}
+
+// JoinOperator is one join of a plan. The build side of a hash join is held
+// in memory and probed by the rows of the probe side; an apply runs its map
+// side, reported as the probe side, once per row of its input side; a merge
+// join reports its left input as the build side.
+type JoinOperator struct {
+	NodeID   int32  `json:"nodeId"`
+	Operator string `json:"operator"`
+	// Type is "hash", "apply", or "merge"
+	Type  string    `json:"type"`
+	Build *JoinSide `json:"build,omitempty"`
+	Probe *JoinSide `json:"probe,omitempty"`
+	// OutputRows is the number of rows the join returned
+	OutputRows *float64 `json:"outputRows,omitempty"`
+	// Selectivity is OutputRows per row of the side that drives the join:
+	// the probe side of hash joins, and the build side of applies and merge
+	// joins
+	Selectivity *float64 `json:"selectivity,omitempty"`
+}
+
+// JoinReport is returned by analyzeJoins
+type JoinReport struct {
+	// Joins are the join operators in pre-order
+	Joins []JoinOperator `json:"joins"`
+	// Text is the joins rendered as a table, with the render option
+	Text string `json:"text,omitempty"`
+}
+
+type analyzeJoinsParams struct {
+	Input   string `json:"input"`
+	Recover bool   `json:"recover,omitempty"`
+	Render  bool   `json:"render,omitempty"`
+}
+
+// joinType returns the Type of JoinOperator for n, or "" if n is not a join.
+// Names are matched case-insensitively so that console naming gives the same
+// result.
+func joinType(n *treeNode) string {
+	name := strings.ToLower(n.node.GetDisplayName())
+	switch {
+	case strings.HasSuffix(name, "hash join"):
+		return joinTypeHash
+	case strings.HasSuffix(name, "merge join"):
+		return joinTypeMerge
+	case strings.HasSuffix(name, " apply"):
+		return joinTypeApply
+	default:
+		return ""
+	}
+}
+
+// joinSides returns the build and probe sides of the join n of type typ. An
+// input with the link type of a side is that side; untyped inputs take the
+// remaining sides in order.
+func joinSides(n *treeNode, typ string) (build, probe *JoinSide) {
+	roles := map[string][2]string{
+		joinTypeHash:  {"Build", "Probe"},
+		joinTypeApply: {"Input", "Map"},
+		joinTypeMerge: {"Left", "Right"},
+	}[typ]
+	var untyped []treeChild
+	for _, c := range n.children {
+		if !c.node.isRelational() {
+			continue
+		}
+		switch c.link.GetType() {
+		case roles[0]:
+			build = joinSide(roles[0], c.node)
+		case roles[1]:
+			probe = joinSide(roles[1], c.node)
+		default:
+			untyped = append(untyped, c)
+		}
+	}
+	for _, c := range untyped {
+		switch {
+		case build == nil:
+			build = joinSide(roles[0], c.node)
+		case probe == nil:
+			probe = joinSide(roles[1], c.node)
+		}
+	}
+	return build, probe
+}
+
+func joinSide(role string, n *treeNode) *JoinSide {
+	return &JoinSide{Role: role, NodeID: n.id(), Operator: n.title(), Rows: optional(n.stat("rows"))}
+}
+
+// buildJoinReport lists the joins of tree in pre-order.
+func buildJoinReport(tree *planTree) JoinReport {
+	report := JoinReport{Joins: []JoinOperator{}}
+	tree.root.walk(func(n *treeNode) {
+		typ := joinType(n)
+		if typ == "" {
+			return
+		}
+		join := JoinOperator{
+			NodeID:     n.id(),
+			Operator:   n.title(),
+			Type:       typ,
+			OutputRows: optional(n.stat("rows")),
+		}
+		join.Build, join.Probe = joinSides(n, typ)
+
+		driving := join.Build
+		if typ == joinTypeHash {
+			driving = join.Probe
+		}
+		if join.OutputRows != nil && driving != nil && driving.Rows != nil && *driving.Rows > 0 {
+			selectivity := *join.OutputRows / *driving.Rows
+			join.Selectivity = &selectivity
+		}
+		report.Joins = append(report.Joins, join)
+	})
+	return report
+}
+
+// joinReportText renders the joins of report, one per line, e.g.
+//
+//	Joins: 1
+//	  ID  Type   Build        Probe         Output  Selectivity  Operator
+//	   2  apply  3 (20 rows)  12 (60 rows)  60      3            Distributed Cross Apply
+func joinReportText(report JoinReport) string {
+	if len(report.Joins) == 0 {
+		return ""
+	}
+	rows := func(v *float64) string {
+		if v == nil {
+			return "-"
+		}
+		return formatCount(*v)
+	}
+	side := func(s *JoinSide) string {
+		if s == nil {
+			return "-"
+		}
+		if s.Rows == nil {
+			return strconv.Itoa(int(s.NodeID))
+		}
+		return fmt.Sprintf("%d (%s rows)", s.NodeID, formatCount(*s.Rows))
+	}
+
+	header := []string{"ID", "Type", "Build", "Probe", "Output", "Selectivity", "Operator"}
+	lines := [][]string{header}
+	for _, j := range report.Joins {
+		selectivity := "-"
+		if j.Selectivity != nil {
+			selectivity = formatDecimal(*j.Selectivity, 3)
+		}
+		lines = append(lines, []string{strconv.Itoa(int(j.NodeID)), j.Type, side(j.Build), side(j.Probe), rows(j.OutputRows), selectivity, j.Operator})
+	}
+	widths := make([]int, len(header))
+	for _, line := range lines {
+		for i, cell := range line {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Joins: %d\n", len(report.Joins))
+	for _, line := range lines {
+		cells := make([]string, len(line))
+		for i, cell := range line {
+			switch {
+			case i == 0:
+				cells[i] = fmt.Sprintf("%*s", widths[i], cell)
+			case i == len(line)-1:
+				cells[i] = cell
+			default:
+				cells[i] = fmt.Sprintf("%-*s", widths[i], cell)
+			}
+		}
+		b.WriteString("  " + strings.Join(cells, "  ") + "\n")
+	}
+	return b.String()
+}
+
+// analyzeJoins returns the join operators of a plan with their inputs and
+// selectivity as JSON
+func analyzeJoins(paramsJSON string) (Response, error) {
+	par := analyzeJoinsParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return analyzeJoinsImpl(par)
+}
+
+func analyzeJoinsImpl(par analyzeJoinsParams) (Response, error) {
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	report := buildJoinReport(buildPlanTree(stats.GetQueryPlan().GetPlanNodes()))
+	if par.Render {
+		report.Text = joinReportText(report)
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal join report: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
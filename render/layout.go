@@ -0,0 +1,91 @@
+package render
+
+import (
+	"hash/maphash"
+	"slices"
+	"sync"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// layoutCache keeps the layout of the last plan nodes rendered from a parsed
+// input or a plan handle, the parts that do not depend on the format: the
+// operator tree and the row model, whose cells the table, HTML, and custom
+// formats emit. Renders of the input that differ only in format build them
+// once. Like tableCache, it is keyed by the identity of the plan nodes:
+// options that change the operators, such as consoleNaming, produce other
+// nodes.
+type layoutCache struct {
+	mu    sync.Mutex
+	nodes []*sppb.PlanNode
+	tree  *planTree
+	// rows are built on first use, as most stages only walk the tree
+	rows []planRow
+}
+
+// getTree returns the tree of planNodes, building it on a miss.
+func (c *layoutCache) getTree(planNodes []*sppb.PlanNode) *planTree {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tree != nil && slices.Equal(c.nodes, planNodes) {
+		return c.tree
+	}
+	// Callers may reuse their slice
+	c.nodes, c.tree, c.rows = slices.Clone(planNodes), buildPlanTree(planNodes), nil
+	return c.tree
+}
+
+// getRows returns a copy of the row model of tree if it is the cached tree.
+func (c *layoutCache) getRows(tree *planTree) ([]planRow, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tree == nil || c.tree != tree {
+		return nil, false
+	}
+	if c.rows == nil {
+		c.rows = buildPlanRows(tree)
+	}
+	return slices.Clone(c.rows), true
+}
+
+// clear drops the cached layout.
+func (c *layoutCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nodes, c.tree, c.rows = nil, nil, nil
+}
+
+// planTree returns the tree of planNodes, from the layout cache of the parsed
+// input or plan handle if there is one. The tree must not be modified.
+func (par params) planTree(planNodes []*sppb.PlanNode) *planTree {
+	if par.layout == nil {
+		return buildPlanTree(planNodes)
+	}
+	return par.layout.getTree(planNodes)
+}
+
+// planRows returns the row model of tree, from the layout cache of the parsed
+// input or plan handle when tree is its cached tree. The rows are a copy that
+// the caller may reorder and annotate.
+func (par params) planRows(tree *planTree) []planRow {
+	if par.layout != nil {
+		if rows, ok := par.layout.getRows(tree); ok {
+			return rows
+		}
+	}
+	return buildPlanRows(tree)
+}
+
+// layout returns the layout cache of input if its parse is cached, or nil.
+func (c *parseCache) layout(input string) *layoutCache {
+	hash := maphash.String(c.seed, input)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range c.entries {
+		if entry.hash == hash && entry.input == input {
+			return entry.layout
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package render
+
+import (
+	"encoding/json"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+func TestLayoutCache(t *testing.T) {
+	c := &layoutCache{}
+	nodes := []*sppb.PlanNode{testPlanNode(0, "Union", 1), testPlanNode(1, "Scan")}
+	tree := c.getTree(nodes)
+	if got := c.getTree(append([]*sppb.PlanNode(nil), nodes...)); got != tree {
+		t.Error("getTree rebuilt the tree of the same plan nodes")
+	}
+
+	rows, ok := c.getRows(tree)
+	if !ok || len(rows) != 2 {
+		t.Fatalf("getRows = %d rows, %v, want 2 rows", len(rows), ok)
+	}
+	// The rows are a copy that callers may change
+	rows[0].Annotation = "changed"
+	if again, _ := c.getRows(tree); again[0].Annotation != "" {
+		t.Error("a change to the rows returned by getRows reached the cache")
+	}
+	if _, ok := c.getRows(buildPlanTree(nodes)); ok {
+		t.Error("getRows returned the rows of the cached tree for another tree")
+	}
+
+	// Other plan nodes, such as those of consoleNaming, replace the layout
+	renamed := []*sppb.PlanNode{nodes[0], testPlanNode(1, "Table Scan")}
+	if got := c.getTree(renamed); got == tree || got.nodes[1].title() != "Table Scan" {
+		t.Error("getTree returned the cached tree for other plan nodes")
+	}
+
+	c.clear()
+	if c.tree != nil || c.rows != nil {
+		t.Error("clear kept the layout")
+	}
+}
+
+func TestLayoutCacheAcrossFormats(t *testing.T) {
+	load, err := json.Marshal(loadPlanParams{Input: diagramTestInput})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := loadPlan(string(load))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info SessionPlanInfo
+	if err := json.Unmarshal([]byte(resp.Result), &info); err != nil {
+		t.Fatal(err)
+	}
+	defer session.release(info.ID)
+	plan, err := session.get(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The table formats share the tree, and HTML also the row model
+	var tree *planTree
+	var row *planRow
+	for _, format := range []string{"HTML", "CURRENT", "COMPACT", "HTML"} {
+		options, _ := json.Marshal(Options{Mode: "PROFILE", Format: format})
+		if _, err := renderPlanImpl(renderPlanParams{ID: info.ID, Options: options}); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if tree == nil {
+			tree, row = plan.layout.tree, &plan.layout.rows[0]
+			continue
+		}
+		if plan.layout.tree != tree || &plan.layout.rows[0] != row {
+			t.Errorf("%s rebuilt the layout of the plan handle", format)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan/plantree/reference"
+)
+
+// WarningCodeNodeRenderError is reported with the lenient option for each
+// plan node that failed to render and was replaced by a placeholder row.
+const WarningCodeNodeRenderError = "NODE_RENDER_ERROR"
+
+// renderTableLenient renders the table like reference.RenderTreeTableWithConfig.
+// When that fails, even with a panic, the relational nodes whose content
+// breaks the render are found by bisection and replaced by placeholder rows
+// that keep their relational children. It returns the table and the
+// repaired nodes by index.
+func renderTableLenient(planNodes []*sppb.PlanNode, mode reference.RenderMode, format reference.Format, config reference.RenderConfig) (string, map[int]*sppb.PlanNode, []Warning, error) {
+	render := func(nodes []*sppb.PlanNode) (s string, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v", r)
+			}
+		}()
+		return reference.RenderTreeTableWithConfig(nodes, mode, format, config)
+	}
+	s, err := render(planNodes)
+	if err == nil {
+		return s, nil, nil, nil
+	}
+	renderErr := RenderError{msg: fmt.Sprintf("Failed to render tree table: %v", err)}
+
+	var candidates []int
+	for i, node := range planNodes {
+		if node.GetKind() != sppb.PlanNode_SCALAR {
+			candidates = append(candidates, i)
+		}
+	}
+	// Stripping every node must render, or the failure is not a node's
+	if _, err := render(keepNodeContent(planNodes, nil)); err != nil {
+		return "", nil, nil, renderErr
+	}
+	broken := make(map[int]error)
+	var bisect func(indexes []int)
+	bisect = func(indexes []int) {
+		_, err := render(keepNodeContent(planNodes, indexes))
+		switch {
+		case err == nil:
+		case len(indexes) == 1:
+			broken[indexes[0]] = err
+		default:
+			bisect(indexes[:len(indexes)/2])
+			bisect(indexes[len(indexes)/2:])
+		}
+	}
+	bisect(candidates)
+	// Nodes that only fail together are not found
+	if len(broken) == 0 {
+		return "", nil, nil, renderErr
+	}
+
+	repaired := make(map[int]*sppb.PlanNode, len(broken))
+	nodes := make([]*sppb.PlanNode, len(planNodes))
+	copy(nodes, planNodes)
+	var warnings []Warning
+	for _, i := range candidates {
+		err, ok := broken[i]
+		if !ok {
+			continue
+		}
+		msg := strings.Join(strings.Fields(innermostError(err).Error()), " ")
+		repaired[i] = strippedNode(planNodes, i, fmt.Sprintf("<render error: %s>", msg))
+		nodes[i] = repaired[i]
+		nodeID := planNodes[i].GetIndex()
+		warnings = append(warnings, Warning{
+			Code:    WarningCodeNodeRenderError,
+			Message: fmt.Sprintf("Plan node %d could not be rendered and is shown as a placeholder: %s", nodeID, msg),
+			NodeID:  &nodeID,
+		})
+	}
+	s, err = render(nodes)
+	if err != nil {
+		return "", nil, nil, renderErr
+	}
+	return s, repaired, warnings, nil
+}
+
+// keepNodeContent returns planNodes with the relational nodes other than
+// those at keep stripped to their relational child links.
+func keepNodeContent(planNodes []*sppb.PlanNode, keep []int) []*sppb.PlanNode {
+	nodes := make([]*sppb.PlanNode, len(planNodes))
+	copy(nodes, planNodes)
+	kept := make(map[int]bool, len(keep))
+	for _, i := range keep {
+		kept[i] = true
+	}
+	for i, node := range planNodes {
+		if node.GetKind() != sppb.PlanNode_SCALAR && !kept[i] {
+			nodes[i] = strippedNode(planNodes, i, "-")
+		}
+	}
+	return nodes
+}
+
+// strippedNode returns planNodes[i] named displayName, without metadata,
+// stats, or scalar child links, and with its relational child links without
+// types and variables.
+func strippedNode(planNodes []*sppb.PlanNode, i int, displayName string) *sppb.PlanNode {
+	node := planNodes[i]
+	var links []*sppb.PlanNode_ChildLink
+	for _, link := range node.GetChildLinks() {
+		child := int(link.GetChildIndex())
+		if child >= 0 && child < len(planNodes) && planNodes[child].GetKind() != sppb.PlanNode_SCALAR {
+			links = append(links, &sppb.PlanNode_ChildLink{ChildIndex: link.GetChildIndex()})
+		}
+	}
+	return &sppb.PlanNode{
+		Index:       node.GetIndex(),
+		Kind:        node.GetKind(),
+		DisplayName: displayName,
+		ChildLinks:  links,
+	}
+}
+
+// innermostError returns the error at the end of the chain of err, which
+// names the problem without the path to the node.
+func innermostError(err error) error {
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return err
+		}
+		err = inner
+	}
+}
@@ -0,0 +1,44 @@
+package render
+
+import "fmt"
+
+// LineMapEntry maps a line of a rendered table to the plan node of its row
+type LineMapEntry struct {
+	// Line is the index of the line in Result, from 0
+	Line   int   `json:"line"`
+	NodeID int32 `json:"nodeId"`
+	// Continuation marks the lines of a row after its first line: wrapped
+	// text and annotations
+	Continuation bool `json:"continuation,omitempty"`
+	// WrapIndex is the index of the line within its row, from 0
+	WrapIndex int `json:"wrapIndex"`
+	// RowID identifies the line across renders of the same plan with other
+	// options, e.g. "12:0" for the first line of node 12, so that UIs can
+	// keep the scroll position when switching between renders
+	RowID string `json:"rowId"`
+}
+
+// buildLineMap returns an entry per operator row line of the table in
+// rendered, or nil if rendered has no table. Borders, headers, and the lines
+// around the table have no entry.
+func buildLineMap(rendered string) []LineMapEntry {
+	table, ok := splitRenderedTable(rendered)
+	if !ok {
+		return nil
+	}
+	var entries []LineMapEntry
+	line := len(table.head)
+	for _, row := range table.rows {
+		for i := range row.lines {
+			entries = append(entries, LineMapEntry{
+				Line:         line,
+				NodeID:       row.id,
+				Continuation: i > 0,
+				WrapIndex:    i,
+				RowID:        fmt.Sprintf("%d:%d", row.id, i),
+			})
+			line++
+		}
+	}
+	return entries
+}
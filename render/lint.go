@@ -0,0 +1,472 @@
+//go:build !nolint
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerFeature("lint", map[string]Export{
+		"lintPlan":      {Run: lintPlan},
+		"suggestWhatIf": {Run: suggestWhatIf},
+	})
+	lintSummary = lintSummaryText
+	clearLintRules = func() { clear(customLintRules) }
+}
+
+// Finding severities
+const (
+	severityInfo    = "info"
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// Finding is a plan problem reported by a lint rule. ID, Rule, and Severity
+// are stable across releases, so tools can key off them.
+type Finding struct {
+	// ID identifies the finding within the plan: the rule followed by the
+	// affected node IDs, e.g. "full-scan:3"
+	ID       string `json:"id"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// NodeID is the node the finding is reported on
+	NodeID *int32 `json:"nodeId,omitempty"`
+	// NodeIDs are all affected nodes, starting with NodeID
+	NodeIDs []int32 `json:"nodeIds,omitempty"`
+	DocURL  string  `json:"docUrl,omitempty"`
+	// Suggestions are the what-if changes of suggestWhatIf findings
+	Suggestions []WhatIfSuggestion `json:"suggestions,omitempty"`
+}
+
+// lintRule is a built-in rule. Exactly one of check, which is called for
+// every relational node, and checkTree, which is called once, is set. Both
+// are passed the thresholds with defaults applied.
+type lintRule struct {
+	name      string
+	severity  string
+	docURL    string
+	check     func(n *treeNode, t Thresholds) []Finding
+	checkTree func(tree *planTree, t Thresholds) []Finding
+}
+
+var builtinLintRules = []lintRule{
+	{name: "full-scan", severity: severityWarning, docURL: "https://cloud.google.com/spanner/docs/secondary-indexes", check: checkFullScan},
+	{name: "high-fan-out", severity: severityWarning, docURL: "https://cloud.google.com/spanner/docs/schema-and-data-model#parent-child", checkTree: checkHighFanOut},
+	{name: "stale-statistics", severity: severityInfo, docURL: "https://cloud.google.com/spanner/docs/query-optimizer/manage-query-optimizer", checkTree: checkStaleStatistics},
+	{name: "large-table-scan", severity: severityWarning, docURL: "https://cloud.google.com/spanner/docs/secondary-indexes", check: checkLargeTableScan},
+	{name: "large-cross-apply", severity: severityWarning, docURL: "https://cloud.google.com/spanner/docs/query-execution-operators#cross-apply", check: checkLargeCrossApply},
+	{name: "large-hash-build", severity: severityWarning, docURL: "https://cloud.google.com/spanner/docs/query-syntax#join-hints", check: checkLargeHashBuild},
+	{name: "many-distributed-operators", severity: severityInfo, docURL: "https://cloud.google.com/spanner/docs/query-execution-operators#distributed-operators", checkTree: checkManyDistributedOperators},
+}
+
+func nodeFinding(rule string, n *treeNode, format string, args ...any) Finding {
+	id := n.id()
+	return Finding{Rule: rule, Message: fmt.Sprintf(format, args...), NodeID: &id}
+}
+
+// completeFinding fills in the severity and documentation URL defaults, the
+// affected nodes, and the ID of f.
+func completeFinding(f Finding, severity, docURL string) Finding {
+	if f.Severity == "" {
+		f.Severity = severity
+	}
+	if f.DocURL == "" {
+		f.DocURL = docURL
+	}
+	if f.NodeID != nil && (len(f.NodeIDs) == 0 || f.NodeIDs[0] != *f.NodeID) {
+		f.NodeIDs = append([]int32{*f.NodeID}, f.NodeIDs...)
+	}
+
+	ids := make([]string, len(f.NodeIDs))
+	for i, id := range f.NodeIDs {
+		ids[i] = strconv.Itoa(int(id))
+	}
+	f.ID = f.Rule
+	if len(ids) > 0 {
+		f.ID += ":" + strings.Join(ids, ",")
+	}
+	return f
+}
+
+// checkFullScan reports scans that read every row of their table or index,
+// unless they returned fewer than t.FullScanMinRows rows.
+func checkFullScan(n *treeNode, t Thresholds) []Finding {
+	fields := n.node.GetMetadata().GetFields()
+	if n.node.GetDisplayName() != "Scan" || valueString(fields["Full scan"]) != "true" {
+		return nil
+	}
+	if rows, ok := n.stat("rows"); ok && rows < t.FullScanMinRows {
+		return nil
+	}
+	return []Finding{nodeFinding("full-scan", n, "%s reads every row; add a filter on a key prefix or an index if the query is selective", n.title())}
+}
+
+// checkHighFanOut reports distributed operators that touched t.FanOutLimit or
+// more splits per execution.
+func checkHighFanOut(tree *planTree, t Thresholds) []Finding {
+	var findings []Finding
+	for _, p := range buildFanOutReport(tree).Points {
+		if p.SplitsPerExecution == nil || *p.SplitsPerExecution < t.FanOutLimit {
+			continue
+		}
+		n := tree.nodes[p.NodeID]
+		findings = append(findings, nodeFinding("high-fan-out", n,
+			"%s touched about %s splits per execution; a key range or an interleaved table could keep the query on fewer splits",
+			n.title(), formatCount(math.Round(*p.SplitsPerExecution))))
+	}
+	return findings
+}
+
+// checkLargeTableScan reports scans of the base table, rather than an index,
+// that returned at least t.LargeScanRows rows. Full scans are left to the
+// full-scan rule, and scans that seek by key are skipped.
+func checkLargeTableScan(n *treeNode, t Thresholds) []Finding {
+	fields := n.node.GetMetadata().GetFields()
+	if n.node.GetDisplayName() != "Scan" || valueString(fields["scan_type"]) != "TableScan" || valueString(fields["Full scan"]) == "true" {
+		return nil
+	}
+	if n.parent != nil && scalarChildDescription(n.parent, "Seek Condition") != "" {
+		return nil
+	}
+	rows, ok := n.stat("rows")
+	if !ok || rows < t.LargeScanRows {
+		return nil
+	}
+	return []Finding{nodeFinding("large-table-scan", n,
+		"%s returned %s rows from the base table; if the query filters on non-key columns, a secondary index could narrow the scan",
+		n.title(), formatCount(rows))}
+}
+
+// checkLargeCrossApply reports cross applies whose input returned at least
+// t.ApplyInputRows rows, as the map side runs once per input row.
+func checkLargeCrossApply(n *treeNode, t Thresholds) []Finding {
+	if !hasOperatorSuffix(n.node.GetDisplayName(), "Cross Apply") {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.link.GetType() == "Map" || !c.node.isRelational() {
+			continue
+		}
+		rows, ok := c.node.stat("rows")
+		if !ok || rows < t.ApplyInputRows {
+			return nil
+		}
+		f := nodeFinding("large-cross-apply", n,
+			"%s runs its map side for each of %s input rows; a hash join could process them in bulk",
+			n.title(), formatCount(rows))
+		f.NodeIDs = []int32{n.id(), c.node.id()}
+		return []Finding{f}
+	}
+	return nil
+}
+
+// checkLargeHashBuild reports hash joins whose build side returned at least
+// t.HashBuildRows rows, as the build side is held in memory.
+func checkLargeHashBuild(n *treeNode, t Thresholds) []Finding {
+	if !hasOperatorSuffix(n.node.GetDisplayName(), "Hash Join") {
+		return nil
+	}
+	var build *treeNode
+	for _, c := range n.children {
+		if !c.node.isRelational() {
+			continue
+		}
+		if c.link.GetType() == "Build" {
+			build = c.node
+			break
+		}
+		if build == nil {
+			build = c.node
+		}
+	}
+	if build == nil {
+		return nil
+	}
+	rows, ok := build.stat("rows")
+	if !ok || rows < t.HashBuildRows {
+		return nil
+	}
+	f := nodeFinding("large-hash-build", n,
+		"%s builds its hash table from %s rows; filter the build side or make the smaller input the build side with the HASH_JOIN_BUILD_SIDE hint",
+		n.title(), formatCount(rows))
+	f.NodeIDs = []int32{n.id(), build.id()}
+	return []Finding{f}
+}
+
+// checkManyDistributedOperators reports plans with t.DistributedOperatorLimit
+// or more distributed operators, on the root, as each one is a round of
+// remote calls.
+func checkManyDistributedOperators(tree *planTree, t Thresholds) []Finding {
+	var ids []int32
+	tree.root.walk(func(n *treeNode) {
+		if isDistributedOperator(n) {
+			ids = append(ids, n.id())
+		}
+	})
+	if float64(len(ids)) < t.DistributedOperatorLimit {
+		return nil
+	}
+	f := nodeFinding("many-distributed-operators", tree.root,
+		"The plan has %d distributed operators, each a round of remote calls; interleaving the joined tables could keep the joins local",
+		len(ids))
+	f.NodeIDs = ids
+	return []Finding{f}
+}
+
+// staleStatisticsSkew is the geometric mean misestimation factor across the
+// plan from which checkStaleStatistics suggests refreshing statistics. Single
+// misestimates are common, so the rule looks at the plan as a whole.
+const staleStatisticsSkew = 4
+
+// checkStaleStatistics aggregates the estimate/actual row ratios of the plan
+// and reports, on the worst operator, when they are skewed overall.
+func checkStaleStatistics(tree *planTree, t Thresholds) []Finding {
+	estimates := rowEstimates(tree)
+	if len(estimates) < 2 {
+		return nil
+	}
+
+	var sumLog float64
+	var worst rowEstimate
+	var worstFactor float64
+	var misestimated []rowEstimate
+	for _, e := range estimates {
+		// The misestimation factor is symmetric: 10x too many and 10x too
+		// few rows are equally wrong
+		factor := math.Abs(math.Log10(max(e.ratio(), 1e-9)))
+		sumLog += factor
+		if factor > worstFactor {
+			worst, worstFactor = e, factor
+		}
+		if e.misestimated(t.MisestimateRatio) {
+			misestimated = append(misestimated, e)
+		}
+	}
+	skew := math.Pow(10, sumLog/float64(len(estimates)))
+	if skew < staleStatisticsSkew {
+		return nil
+	}
+	f := nodeFinding("stale-statistics", worst.node,
+		"Row estimates are off by %s on average across %d operators (%d by %s or more, worst: %s); the optimizer statistics may be stale, consider running ANALYZE to construct a new statistics package",
+		formatRatio(skew), len(estimates), len(misestimated), formatRatio(t.MisestimateRatio), worst.node.node.GetDisplayName())
+	for _, e := range misestimated {
+		if e.node != worst.node {
+			f.NodeIDs = append(f.NodeIDs, e.node.id())
+		}
+	}
+	return []Finding{f}
+}
+
+// Scopes of custom lint rules
+const (
+	lintScopeNode = "node"
+	lintScopeTree = "tree"
+)
+
+// LintRule is a custom lint rule registered with RegisterLintRule. It is
+// called with the JSON of its input and returns the JSON of an array of
+// {message, nodeId?} findings, or of null.
+type LintRule func(inputJSON []byte) ([]byte, error)
+
+type customLintRule struct {
+	scope string
+	rule  LintRule
+}
+
+// customLintRules holds the rules registered with RegisterLintRule, run after
+// the built-in rules in name order.
+var customLintRules = make(map[string]customLintRule)
+
+// customFinding is a finding returned by a custom rule; the rule name and ID are
+// filled in by Go, and the severity defaults to "warning".
+type customFinding struct {
+	Message  string  `json:"message"`
+	Severity string  `json:"severity,omitempty"`
+	NodeID   *int32  `json:"nodeId,omitempty"`
+	NodeIDs  []int32 `json:"nodeIds,omitempty"`
+	DocURL   string  `json:"docUrl,omitempty"`
+}
+
+type lintParams struct {
+	Input      string     `json:"input"`
+	Recover    bool       `json:"recover,omitempty"`
+	Thresholds Thresholds `json:"thresholds,omitempty"`
+}
+
+// lintPlan runs the built-in and registered lint rules against the plan
+func lintPlan(paramsJSON string) (Response, error) {
+	par := lintParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return lintPlanImpl(par)
+}
+
+// RegisterLintRule registers rule as a custom lint rule, run after the
+// built-in rules in name order. With the "node" scope (the default for an
+// empty scope) it is called with each PlanRow; with the "tree" scope it is
+// called once with the whole row model. A nil rule unregisters the name.
+func RegisterLintRule(name, scope string, rule LintRule) error {
+	if name == "" {
+		return InvalidParametersError{msg: "Lint rule name must be a non-empty string"}
+	}
+	for _, builtin := range builtinLintRules {
+		if builtin.name == name {
+			return InvalidParametersError{msg: fmt.Sprintf("Cannot override built-in lint rule: %s", name)}
+		}
+	}
+
+	switch scope {
+	case "":
+		scope = lintScopeNode
+	case lintScopeNode, lintScopeTree:
+	default:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid lint rule scope: %q (expected %q or %q)", scope, lintScopeNode, lintScopeTree)}
+	}
+
+	if rule == nil {
+		delete(customLintRules, name)
+		return nil
+	}
+	customLintRules[name] = customLintRule{scope: scope, rule: rule}
+	return nil
+}
+
+// lintPlanImpl returns the findings of all rules as a JSON array in
+// Response.Result.
+func lintPlanImpl(par lintParams) (Response, error) {
+	if err := par.Thresholds.check(); err != nil {
+		return Response{}, err
+	}
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	tree := buildPlanTree(stats.GetQueryPlan().GetPlanNodes())
+
+	findings, err := runLintRules(tree, par.Thresholds.withDefaults())
+	if err != nil {
+		return Response{}, err
+	}
+	b, err := json.Marshal(findings)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal findings: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
+
+// runLintRules runs the built-in rules with thresholds t followed by the
+// custom rules.
+func runLintRules(tree *planTree, t Thresholds) ([]Finding, error) {
+	findings := []Finding{}
+	tree.root.walk(func(n *treeNode) {
+		for _, rule := range builtinLintRules {
+			if rule.check != nil {
+				for _, f := range rule.check(n, t) {
+					findings = append(findings, completeFinding(f, rule.severity, rule.docURL))
+				}
+			}
+		}
+	})
+	for _, rule := range builtinLintRules {
+		if rule.checkTree != nil {
+			for _, f := range rule.checkTree(tree, t) {
+				findings = append(findings, completeFinding(f, rule.severity, rule.docURL))
+			}
+		}
+	}
+	if len(customLintRules) == 0 {
+		return findings, nil
+	}
+
+	rows := buildPlanRows(tree)
+	for _, name := range sortedKeys(customLintRules) {
+		rule := customLintRules[name]
+		var inputs []any
+		if rule.scope == lintScopeTree {
+			inputs = []any{formatterModel{Rows: rows}}
+		} else {
+			for _, row := range rows {
+				inputs = append(inputs, row)
+			}
+		}
+		for _, input := range inputs {
+			found, err := runCustomLintRule(name, rule.rule, input)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range found {
+				if row, ok := input.(planRow); ok && f.NodeID == nil {
+					f.NodeID = &row.ID
+				}
+				findings = append(findings, completeFinding(f, severityWarning, ""))
+			}
+		}
+	}
+	return findings, nil
+}
+
+// lintSummaryText renders the findings of all rules for the lint option of
+// renderASCII, one per line, e.g.
+//
+//	Lint findings:
+//	  warning full-scan (node 4): Table Scan (Table: Singers) reads every row; ...
+func lintSummaryText(tree *planTree, t Thresholds) (string, error) {
+	findings, err := runLintRules(tree, t)
+	if err != nil {
+		return "", err
+	}
+	if len(findings) == 0 {
+		return "Lint findings: none\n", nil
+	}
+	var b strings.Builder
+	b.WriteString("Lint findings:\n")
+	for _, f := range findings {
+		fmt.Fprintf(&b, "  %s %s", f.Severity, f.Rule)
+		if f.NodeID != nil {
+			fmt.Fprintf(&b, " (node %d)", *f.NodeID)
+		}
+		fmt.Fprintf(&b, ": %s\n", f.Message)
+	}
+	return b.String(), nil
+}
+
+// runCustomLintRule calls a custom rule with input. Failures, panics, and
+// malformed results are reported as render errors.
+func runCustomLintRule(name string, rule LintRule, input any) (findings []Finding, err error) {
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, RenderError{msg: fmt.Sprintf("Failed to marshal lint rule input: %v", err)}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = RenderError{msg: fmt.Sprintf("Lint rule %s failed: %v", name, r)}
+		}
+	}()
+	out, err := rule(b)
+	if err != nil {
+		return nil, RenderError{msg: fmt.Sprintf("Lint rule %s failed: %v", name, err)}
+	}
+
+	// null unmarshals to no findings
+	var returned []customFinding
+	if err := json.Unmarshal(out, &returned); err != nil {
+		return nil, RenderError{msg: fmt.Sprintf("Lint rule %s returned malformed findings: %v", name, err)}
+	}
+	for _, f := range returned {
+		switch f.Severity {
+		case "", severityInfo, severityWarning, severityError:
+		default:
+			return nil, RenderError{msg: fmt.Sprintf("Lint rule %s returned an invalid severity: %q", name, f.Severity)}
+		}
+		findings = append(findings, Finding{Rule: name, Severity: f.Severity, Message: f.Message, NodeID: f.NodeID, NodeIDs: f.NodeIDs, DocURL: f.DocURL})
+	}
+	return findings, nil
+}
@@ -0,0 +1,261 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Levels of the logLevel export. logLevelOff drops every record.
+const (
+	logLevelDebug = "debug"
+	logLevelInfo  = "info"
+	logLevelWarn  = "warn"
+	logLevelError = "error"
+	logLevelOff   = "off"
+)
+
+var logLevels = map[string]slog.Level{
+	logLevelDebug: slog.LevelDebug,
+	logLevelInfo:  slog.LevelInfo,
+	logLevelWarn:  slog.LevelWarn,
+	logLevelError: slog.LevelError,
+	logLevelOff:   math.MaxInt32,
+}
+
+// LogHandler receives each log record of the renderer as the JSON of a
+// LogRecord. It is set from JS with setLogHandler.
+type LogHandler func(recordJSON []byte)
+
+// LogRecord is a log record passed to the handler set with setLogHandler
+type LogRecord struct {
+	// Time is RFC 3339 with milliseconds
+	Time string `json:"time"`
+	// Level is "debug", "info", "warn", or "error"
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	// Attrs are the structured fields of the record; groups are nested
+	// objects
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// logSink holds the handler and level shared by every logger. Records
+// emitted while the handler runs, such as by exports it calls, are dropped
+// so that a handler cannot recurse into itself.
+type logSink struct {
+	mu      sync.Mutex
+	handler LogHandler
+	level   slog.LevelVar
+	busy    bool
+}
+
+var logs = func() *logSink {
+	s := &logSink{}
+	s.level.Set(slog.LevelWarn)
+	return s
+}()
+
+// logger is the logger of the package. Records go nowhere until a handler is
+// set, and cost little more than the level check.
+var logger = slog.New(&logBridge{sink: logs})
+
+// Logger returns the logger of the renderer, for frontends to log to the
+// same handler.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// SetLogHandler sets the handler of the log records; nil drops them.
+func SetLogHandler(h LogHandler) {
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	logs.handler = h
+}
+
+// logBridge is the slog.Handler that converts records to LogRecord for the
+// handler of sink.
+type logBridge struct {
+	sink   *logSink
+	attrs  []slog.Attr
+	groups []string
+}
+
+func (b *logBridge) Enabled(_ context.Context, level slog.Level) bool {
+	if level < b.sink.level.Level() {
+		return false
+	}
+	b.sink.mu.Lock()
+	defer b.sink.mu.Unlock()
+	return b.sink.handler != nil
+}
+
+func (b *logBridge) Handle(_ context.Context, r slog.Record) error {
+	attrs := make(map[string]any)
+	for _, a := range b.attrs {
+		addLogAttr(attrs, a)
+	}
+	// Attributes of the record go into the groups opened with WithGroup
+	group := attrs
+	for _, name := range b.groups {
+		sub, ok := group[name].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			group[name] = sub
+		}
+		group = sub
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addLogAttr(group, a)
+		return true
+	})
+	record := LogRecord{
+		Time:    r.Time.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		Level:   strings.ToLower(r.Level.String()),
+		Message: r.Message,
+	}
+	if len(attrs) > 0 {
+		record.Attrs = attrs
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	b.sink.mu.Lock()
+	h := b.sink.handler
+	if h == nil || b.sink.busy {
+		b.sink.mu.Unlock()
+		return nil
+	}
+	b.sink.busy = true
+	b.sink.mu.Unlock()
+	defer func() {
+		b.sink.mu.Lock()
+		b.sink.busy = false
+		b.sink.mu.Unlock()
+		// Logging never fails a render; a failing handler loses the record
+		_ = recover()
+	}()
+	h(recordJSON)
+	return nil
+}
+
+func (b *logBridge) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(b.groups) > 0 {
+		// Nest the attributes in the open groups, innermost first
+		a := slog.Attr{Key: b.groups[len(b.groups)-1], Value: slog.GroupValue(attrs...)}
+		for i := len(b.groups) - 2; i >= 0; i-- {
+			a = slog.Attr{Key: b.groups[i], Value: slog.GroupValue(a)}
+		}
+		attrs = []slog.Attr{a}
+	}
+	return &logBridge{sink: b.sink, attrs: slices.Concat(b.attrs, attrs), groups: b.groups}
+}
+
+func (b *logBridge) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return b
+	}
+	return &logBridge{sink: b.sink, attrs: b.attrs, groups: append(slices.Clip(b.groups), name)}
+}
+
+// addLogAttr adds a to attrs as a JSON value. Durations are in milliseconds,
+// times are RFC 3339, and errors are their messages.
+func addLogAttr(attrs map[string]any, a slog.Attr) {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := attrs
+		if a.Key != "" {
+			sub, ok := attrs[a.Key].(map[string]any)
+			if !ok {
+				sub = make(map[string]any)
+				attrs[a.Key] = sub
+			}
+			group = sub
+		}
+		for _, ga := range v.Group() {
+			addLogAttr(group, ga)
+		}
+	case slog.KindDuration:
+		attrs[a.Key] = float64(v.Duration()) / float64(time.Millisecond)
+	case slog.KindTime:
+		attrs[a.Key] = v.Time().UTC().Format(time.RFC3339Nano)
+	default:
+		switch x := v.Any().(type) {
+		case error:
+			attrs[a.Key] = x.Error()
+		case fmt.Stringer:
+			attrs[a.Key] = x.String()
+		default:
+			attrs[a.Key] = x
+		}
+	}
+}
+
+// logRender logs a renderASCII call that started at start: its options and
+// sizes at debug level, and the codes of its warnings at info level.
+func logRender(par params, start time.Time, resp Response) {
+	logger.Debug("rendered plan",
+		"format", par.Format,
+		"mode", par.Mode,
+		"inputBytes", len(par.Input),
+		"outputBytes", len(resp.Result),
+		"duration", time.Since(start))
+	if len(resp.Warnings) > 0 {
+		codes := make([]string, len(resp.Warnings))
+		for i, w := range resp.Warnings {
+			codes[i] = w.Code
+		}
+		logger.Info("plan rendered with warnings", "codes", codes)
+	}
+}
+
+type logLevelParams struct {
+	// Level is set when not empty
+	Level string `json:"level,omitempty"`
+}
+
+// LogLevelResult is returned by logLevel
+type LogLevelResult struct {
+	// Level is the level in effect after the call
+	Level string `json:"level"`
+}
+
+// currentLogLevel returns the name of the level of logs.
+func currentLogLevel() string {
+	level := logs.level.Level()
+	for _, name := range sortedKeys(logLevels) {
+		if logLevels[name] == level {
+			return name
+		}
+	}
+	return strings.ToLower(level.String())
+}
+
+// logLevel sets the lowest level of the records passed to the log handler,
+// "warn" by default, and returns the level in effect as JSON
+func logLevel(paramsJSON string) (Response, error) {
+	par := logLevelParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	if par.Level != "" {
+		level, ok := logLevels[strings.ToLower(par.Level)]
+		if !ok {
+			return Response{}, InvalidParametersError{msg: fmt.Sprintf("Invalid log level: %q (expected one of %s)", par.Level, strings.Join(sortedKeys(logLevels), ", "))}
+		}
+		logs.level.Set(level)
+	}
+	b, err := json.Marshal(LogLevelResult{Level: currentLogLevel()})
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal log level: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
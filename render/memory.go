@@ -0,0 +1,102 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// MemoryStats is returned by getMemoryStats and freeMemory
+type MemoryStats struct {
+	// HeapAllocBytes is the size of live and not yet collected heap objects
+	HeapAllocBytes uint64 `json:"heapAllocBytes"`
+	HeapInUseBytes uint64 `json:"heapInUseBytes"`
+	// SysBytes is the memory obtained by the Go runtime. WebAssembly memory
+	// cannot shrink, so it only grows; freed memory is reused by later
+	// renders instead of growing it further.
+	SysBytes uint64 `json:"sysBytes"`
+	GCCycles uint32 `json:"gcCycles"`
+	// ParseCacheEntries and SessionPlans are the parsed inputs and loadPlan
+	// plans held in memory
+	ParseCacheEntries int `json:"parseCacheEntries"`
+	SessionPlans      int `json:"sessionPlans"`
+}
+
+func readMemoryStats() MemoryStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	session.mu.Lock()
+	sessionPlans := len(session.plans)
+	session.mu.Unlock()
+	return MemoryStats{
+		HeapAllocBytes:    m.HeapAlloc,
+		HeapInUseBytes:    m.HeapInuse,
+		SysBytes:          m.Sys,
+		GCCycles:          m.NumGC,
+		ParseCacheEntries: inputCache.len(),
+		SessionPlans:      sessionPlans,
+	}
+}
+
+// memoryStatsResponse returns stats as a JSON response.
+func memoryStatsResponse(stats MemoryStats) (Response, error) {
+	b, err := json.Marshal(stats)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal memory stats: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
+
+// getMemoryStats returns the MemoryStats of the Go runtime as JSON
+func getMemoryStats(string) (Response, error) {
+	return memoryStatsResponse(readMemoryStats())
+}
+
+// freeMemory clears the parse and table caches and the layouts of session
+// plans, collects garbage, and returns as much memory as possible to the
+// runtime, for long sessions that have rendered many large plans. Session
+// plans, presets, and unread chunked results are kept. It returns the
+// MemoryStats afterwards as JSON.
+func freeMemory(string) (Response, error) {
+	inputCache.clear()
+	renderedTables.clear()
+	session.clearLayouts()
+	lastSplit.mu.Lock()
+	lastSplit.input, lastSplit.plans = "", nil
+	lastSplit.mu.Unlock()
+	lastNormalized.mu.Lock()
+	lastNormalized.planNodes, lastNormalized.result = nil, nil
+	lastNormalized.mu.Unlock()
+	// FreeOSMemory runs a garbage collection first
+	debug.FreeOSMemory()
+	return memoryStatsResponse(readMemoryStats())
+}
+
+// Shutdown drops the state kept between calls, for frontends that stop the
+// WASM instance to start a new one: the parse and table caches, session
+// plans, presets, unread chunked results, registered formatters and lint
+// rules, the log handler, and the usage counts. Plans and presets that should
+// survive the restart are carried over with exportSession and importSession.
+func Shutdown() {
+	inputCache.clear()
+	renderedTables.clear()
+	lastSplit.mu.Lock()
+	lastSplit.input, lastSplit.plans = "", nil
+	lastSplit.mu.Unlock()
+	lastNormalized.mu.Lock()
+	lastNormalized.planNodes, lastNormalized.result = nil, nil
+	lastNormalized.mu.Unlock()
+	session.replace(sessionBlob{})
+	presets.replace(nil)
+	pendingChunks.mu.Lock()
+	clear(pendingChunks.results)
+	pendingChunks.mu.Unlock()
+	clear(customFormatters)
+	if clearLintRules != nil {
+		clearLintRules()
+	}
+	SetLogHandler(nil)
+	usage.snapshot(true)
+	usage.setEnabled(false)
+}
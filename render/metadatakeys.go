@@ -0,0 +1,86 @@
+package render
+
+import (
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// metadataKeyDoc describes a raw plan node metadata key.
+type metadataKeyDoc struct {
+	// label is the sentence-case label, which the Cloud Console also shows
+	label string
+	// unit is the unit of the values, if they are quantities or references
+	unit string
+}
+
+// metadataKeyDocs documents the metadata keys of the Spanner "Query execution
+// operators" reference. Keys that the renderers fold into operator titles
+// (call_type, iterator_type, scan_type, scan_target) are absent: they are
+// already shown as readable titles such as "Local Distributed Union" or
+// "Table Scan: Singers".
+var metadataKeyDocs = map[string]metadataKeyDoc{
+	"distribution_table":    {label: "Distribution table"},
+	"estimated_row_count":   {label: "Estimated row count"},
+	"estimated_rows":        {label: "Estimated rows"},
+	"execution_method":      {label: "Execution method"},
+	"join_type":             {label: "Join type"},
+	"scan_method":           {label: "Scan method"},
+	"seekable_key_size":     {label: "Seekable key size", unit: "key columns"},
+	"split_ranges_aligned":  {label: "Split ranges aligned"},
+	"subquery_cluster_node": {label: "Subquery cluster node"},
+}
+
+// titleMetadataKeys are the other keys the table renderer formats itself,
+// e.g. execution_method as "<Row>" and split_ranges_aligned as a label, so
+// they keep their raw names.
+var titleMetadataKeys = map[string]bool{
+	"distribution_table":    true,
+	"execution_method":      true,
+	"Full scan":             true,
+	"split_ranges_aligned":  true,
+	"subquery_cluster_node": true,
+	"table":                 true,
+}
+
+// prettyMetadataKey returns the label of a metadata key followed by the unit
+// of its values, e.g. "Seekable key size (key columns)". Unknown snake_case
+// keys are converted to sentence case.
+func prettyMetadataKey(key string) string {
+	label := consoleMetadataLabel(key)
+	if unit := metadataKeyDocs[key].unit; unit != "" {
+		return label + " (" + unit + ")"
+	}
+	return label
+}
+
+// applyPrettyMetadataKeys returns planNodes with their metadata keys replaced
+// by prettyMetadataKey labels, for display only: analyses that read metadata
+// by raw key must use the original nodes. Nodes without metadata are shared
+// with the input.
+func applyPrettyMetadataKeys(planNodes []*sppb.PlanNode) []*sppb.PlanNode {
+	pretty := make([]*sppb.PlanNode, len(planNodes))
+	for i, node := range planNodes {
+		md := node.GetMetadata()
+		if len(md.GetFields()) == 0 {
+			pretty[i] = node
+			continue
+		}
+		fields := make(map[string]*structpb.Value, len(md.GetFields()))
+		for key, value := range md.GetFields() {
+			if !isSemanticMetadataKey(key) {
+				key = prettyMetadataKey(key)
+			}
+			fields[key] = value
+		}
+		pretty[i] = &sppb.PlanNode{
+			Index:               node.GetIndex(),
+			Kind:                node.GetKind(),
+			DisplayName:         node.GetDisplayName(),
+			ChildLinks:          node.GetChildLinks(),
+			ShortRepresentation: node.GetShortRepresentation(),
+			Metadata:            &structpb.Struct{Fields: fields},
+			ExecutionStats:      node.GetExecutionStats(),
+		}
+	}
+	return pretty
+}
@@ -0,0 +1,64 @@
+package render
+
+import (
+	"runtime"
+	"time"
+)
+
+// RenderMetrics are the timings and sizes of a render, returned in
+// Response.Metrics with the includeMetrics option
+type RenderMetrics struct {
+	// ParseMillis is the time to extract the plan from the input; near zero
+	// when the parse cache has it
+	ParseMillis float64 `json:"parseMillis"`
+	// RenderMillis is the rest of the call
+	RenderMillis float64 `json:"renderMillis"`
+	InputBytes   int     `json:"inputBytes"`
+	// OutputBytes is the size of the whole output, also for chunked outputs
+	OutputBytes int `json:"outputBytes"`
+	NodeCount   int `json:"nodeCount"`
+	// HeapInUseBytes is the Go heap in use after the render
+	HeapInUseBytes uint64 `json:"heapInUseBytes"`
+}
+
+// elapsedMillis returns the time since start in milliseconds, to the
+// microsecond.
+func elapsedMillis(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// renderWithMetrics runs renderASCIIImpl and adds its RenderMetrics. The plan
+// is parsed first, so that the render finds it in the parse cache and the
+// two phases are timed apart. Errors are returned as they are.
+func renderWithMetrics(par params) (Response, error) {
+	par.IncludeMetrics = false
+
+	start := time.Now()
+	stats, rowType, format, err := par.queryPlan()
+	parseMillis := elapsedMillis(start)
+	if err == nil && par.InputEncoding == inputEncodingProtoBase64 && par.parsed == nil {
+		// Binary inputs bypass the parse cache
+		par.parsed = &parsedPlan{stats: stats, rowType: rowType, format: format}
+	}
+
+	start = time.Now()
+	resp, err := renderASCIIImpl(par)
+	if err != nil {
+		return Response{}, err
+	}
+	metrics := &RenderMetrics{
+		ParseMillis:  parseMillis,
+		RenderMillis: elapsedMillis(start),
+		InputBytes:   len(par.Input),
+		OutputBytes:  len(resp.Result),
+		NodeCount:    len(stats.GetQueryPlan().GetPlanNodes()),
+	}
+	if resp.Chunks != nil {
+		metrics.OutputBytes = resp.Chunks.TotalBytes
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	metrics.HeapInUseBytes = m.HeapInuse
+	resp.Metrics = metrics
+	return resp, nil
+}
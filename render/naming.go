@@ -0,0 +1,112 @@
+package render
+
+import (
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// consoleOperatorNames maps plan display names to the terminology used by the
+// Cloud Console query plan visualizer, which follows the sentence-case names
+// of the Spanner "Query execution operators" reference.
+var consoleOperatorNames = map[string]string{
+	"Apply Mutations":          "Apply mutations",
+	"Array Subquery":           "Array subquery",
+	"Array Unnest":             "Array unnest",
+	"Compute Struct":           "Compute struct",
+	"Create Batch":             "Create batch",
+	"Cross Apply":              "Cross apply",
+	"Distributed Cross Apply":  "Distributed cross apply",
+	"Distributed Outer Apply":  "Distributed outer apply",
+	"Distributed Union":        "Distributed union",
+	"Filter Scan":              "Filter scan",
+	"Hash Join":                "Hash join",
+	"KeyRangeAccumulator":      "Key range accumulator",
+	"Local Limit":              "Local limit",
+	"Merge Join":               "Merge join",
+	"Minor Sort":               "Minor sort",
+	"Outer Apply":              "Outer apply",
+	"Push Broadcast Hash Join": "Push broadcast hash join",
+	"Random Id Assign":         "Random ID assign",
+	"Recursive Union":          "Recursive union",
+	"Scalar Subquery":          "Scalar subquery",
+	"Serialize Result":         "Serialize result",
+	"Sort Limit":               "Sort limit",
+	"Union All":                "Union all",
+	"Union Input":              "Union input",
+}
+
+// consoleOperatorName returns the Cloud Console name for a display name,
+// falling back to the original name for operators not in the table.
+func consoleOperatorName(name string) string {
+	if mapped, ok := consoleOperatorNames[name]; ok {
+		return mapped
+	}
+	return name
+}
+
+// hasOperatorSuffix reports whether a display name ends with suffix in
+// either naming, e.g. "Cross Apply" matches "Distributed cross apply".
+func hasOperatorSuffix(name, suffix string) bool {
+	return len(name) >= len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix)
+}
+
+// consoleMetadataLabel returns the Cloud Console label for a metadata key,
+// the label in metadataKeyDocs. Unknown snake_case keys are converted to
+// sentence case.
+func consoleMetadataLabel(key string) string {
+	if doc, ok := metadataKeyDocs[key]; ok {
+		return doc.label
+	}
+	if !strings.Contains(key, "_") {
+		return key
+	}
+	label := strings.ReplaceAll(key, "_", " ")
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// isSemanticMetadataKey reports whether the renderers derive operator titles
+// from the key, in which case it must not be renamed: the keys folded into
+// titles and those spannerplan formats itself, titleMetadataKeys.
+func isSemanticMetadataKey(key string) bool {
+	switch key {
+	case "call_type", "iterator_type", "scan_type", "scan_target":
+		return true
+	}
+	return titleMetadataKeys[key]
+}
+
+// applyConsoleNaming returns copies of planNodes whose display names and
+// metadata labels follow Cloud Console terminology. The input is not modified.
+// Names that follow a call or iterator type in titles continue the sentence,
+// e.g. "Local distributed union", and apply inputs keep their "Input" label,
+// which spannerplan derives from the display name.
+func applyConsoleNaming(planNodes []*sppb.PlanNode) []*sppb.PlanNode {
+	renamed := make([]*sppb.PlanNode, len(planNodes))
+	for i, node := range planNodes {
+		clone := proto.Clone(node).(*sppb.PlanNode)
+		name := consoleOperatorName(clone.GetDisplayName())
+		fields := clone.GetMetadata().GetFields()
+		if name != clone.GetDisplayName() && (fields["call_type"].GetStringValue() != "" || fields["iterator_type"].GetStringValue() != "") {
+			name = strings.ToLower(name[:1]) + name[1:]
+		}
+		if links := clone.GetChildLinks(); len(links) > 0 && links[0].GetType() == "" && strings.HasSuffix(clone.GetDisplayName(), "Apply") {
+			links[0].Type = "Input"
+		}
+		clone.DisplayName = name
+		if md := clone.GetMetadata(); md != nil {
+			fields := make(map[string]*structpb.Value, len(md.GetFields()))
+			for key, value := range md.GetFields() {
+				if !isSemanticMetadataKey(key) {
+					key = consoleMetadataLabel(key)
+				}
+				fields[key] = value
+			}
+			clone.Metadata = &structpb.Struct{Fields: fields}
+		}
+		renamed[i] = clone
+	}
+	return renamed
+}
@@ -0,0 +1,251 @@
+//go:build !nonarrative
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerFeature("narrative", map[string]Export{
+		"explainPlan": {Run: explainPlan},
+	})
+}
+
+// explainPlan returns a natural-language narrative of the plan
+func explainPlan(paramsJSON string) (Response, error) {
+	par := explainParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return explainPlanImpl(par)
+}
+
+// Locales supported by explainPlan
+const (
+	localeEnglish  = "en"
+	localeJapanese = "ja"
+)
+
+// narrativeMessages holds the sentence templates of one locale. Templates are
+// fmt format strings; the comments describe their arguments.
+type narrativeMessages struct {
+	intro                 string // number of operators
+	scan                  string // scanned object kind, scan target
+	scanAny               string // scanned object kind
+	scanFull              string
+	scanSeek              string // number of seekable key columns
+	residual              string // condition
+	distributedUnion      string // distribution table
+	distributedUnionAny   string
+	localDistributedUnion string
+	distributedCrossApply string
+	crossApply            string
+	hashJoin              string
+	aggregate             string
+	sort                  string
+	limit                 string
+	serializeResult       string
+	filter                string // condition
+	applyMutations        string // operation type, table
+	generic               string // operator title
+	produced              string // rows
+	producedIn            string // rows, latency with unit
+	table                 string
+	index                 string
+	batch                 string
+	step                  string // step number
+	sentenceSeparator     string
+	// glossary appends the operator glossary description to generic
+	// sentences; the glossary is written in English.
+	glossary bool
+}
+
+var narrativeCatalog = map[string]narrativeMessages{
+	localeEnglish: {
+		intro:                 "This plan has %d operators. Spanner executes it from the leaves up:",
+		scan:                  "Spanner scans the %s %s.",
+		scanAny:               "Spanner scans a %s.",
+		scanFull:              "This is a full scan that reads every row.",
+		scanSeek:              "It seeks on the first %d key column(s) instead of reading the whole range.",
+		residual:              "It applies the residual filter %s to the rows it reads.",
+		distributedUnion:      "Spanner distributes the work below across the splits of %s and unions their results.",
+		distributedUnionAny:   "Spanner distributes the work below across splits and unions their results.",
+		localDistributedUnion: "The rows from the splits local to the executing server are combined.",
+		distributedCrossApply: "For each batch of input rows, Spanner sends the map side to the servers owning the matching splits (distributed cross apply).",
+		crossApply:            "For each input row, Spanner evaluates the map side and joins the results (cross apply).",
+		hashJoin:              "Spanner builds a hash table from the build side and probes it with rows from the probe side (hash join).",
+		aggregate:             "The rows are grouped and aggregated.",
+		sort:                  "The rows are sorted.",
+		limit:                 "Only the first rows are kept (limit).",
+		serializeResult:       "The final rows are serialized and returned to the client.",
+		filter:                "Rows are filtered by %s.",
+		applyMutations:        "Spanner applies the %s mutations of the input rows to the table %s.",
+		generic:               "Spanner applies %s.",
+		produced:              "It produced %s rows.",
+		producedIn:            "It produced %s rows in %s.",
+		table:                 "table",
+		index:                 "index",
+		batch:                 "batch",
+		step:                  "Step %d: ",
+		sentenceSeparator:     " ",
+		glossary:              true,
+	},
+	localeJapanese: {
+		intro:                 "このプランは %d 個の演算子で構成されています。Spanner は葉から順に実行します。",
+		scan:                  "Spanner は%s %s をスキャンします。",
+		scanAny:               "Spanner は%sをスキャンします。",
+		scanFull:              "これはすべての行を読み取るフルスキャンです。",
+		scanSeek:              "範囲全体を読む代わりに先頭 %d 個のキー列でシークします。",
+		residual:              "読み取った行に残余フィルタ %s を適用します。",
+		distributedUnion:      "Spanner は配下の処理を %s のスプリットに分散し、結果を結合します。",
+		distributedUnionAny:   "Spanner は配下の処理をスプリットに分散し、結果を結合します。",
+		localDistributedUnion: "実行サーバーにローカルなスプリットからの行を結合します。",
+		distributedCrossApply: "入力行のバッチごとに、対応するスプリットを持つサーバーへ map 側を送信します (distributed cross apply)。",
+		crossApply:            "入力行ごとに map 側を評価して結果を結合します (cross apply)。",
+		hashJoin:              "build 側からハッシュテーブルを構築し、probe 側の行で照合します (hash join)。",
+		aggregate:             "行をグループ化して集約します。",
+		sort:                  "行をソートします。",
+		limit:                 "先頭の行だけを残します (limit)。",
+		serializeResult:       "最終的な行をシリアライズしてクライアントへ返します。",
+		filter:                "%s で行をフィルタします。",
+		applyMutations:        "入力行の %[1]s ミューテーションをテーブル %[2]s に適用します。",
+		generic:               "Spanner は %s を適用します。",
+		produced:              "%s 行を出力しました。",
+		producedIn:            "%s 行を %s で出力しました。",
+		table:                 "テーブル",
+		index:                 "インデックス",
+		batch:                 "バッチ",
+		step:                  "ステップ %d: ",
+		sentenceSeparator:     "",
+	},
+}
+
+type explainParams struct {
+	Input  string `json:"input"`
+	Locale string `json:"locale,omitempty"`
+}
+
+// explainPlanImpl describes the plan in prose, one paragraph per relational
+// operator in execution order (children before their parent).
+func explainPlanImpl(par explainParams) (Response, error) {
+	locale := par.Locale
+	if locale == "" {
+		locale = localeEnglish
+	}
+	msgs, ok := narrativeCatalog[locale]
+	if !ok {
+		return Response{}, InvalidParametersError{msg: fmt.Sprintf("Unsupported locale: %q (expected %q or %q)", par.Locale, localeEnglish, localeJapanese)}
+	}
+
+	stats, _, err := extractQueryPlan(par.Input)
+	if err != nil {
+		return Response{}, withInputHints(extractError(err), par.Input)
+	}
+	planNodes, err := queryPlanNodes(stats, par.Input)
+	if err != nil {
+		return Response{}, withInputHints(err, par.Input)
+	}
+	if err := validatePlanNodes(planNodes, par.Input); err != nil {
+		return Response{}, err
+	}
+	tree := buildPlanTree(planNodes)
+
+	var order []*treeNode
+	var visit func(n *treeNode)
+	visit = func(n *treeNode) {
+		for _, child := range n.relationalChildren() {
+			visit(child)
+		}
+		order = append(order, n)
+	}
+	visit(tree.root)
+
+	paragraphs := []string{fmt.Sprintf(msgs.intro, len(order))}
+	for i, n := range order {
+		paragraphs = append(paragraphs, fmt.Sprintf(msgs.step, i+1)+narrateNode(n, msgs))
+	}
+	return Response{Result: strings.Join(paragraphs, "\n\n") + "\n"}, nil
+}
+
+// narrateNode returns the sentences describing a single operator.
+func narrateNode(n *treeNode, msgs narrativeMessages) string {
+	var sentences []string
+	fields := n.node.GetMetadata().GetFields()
+	name := n.node.GetDisplayName()
+	switch {
+	case name == "Scan":
+		kind := msgs.table
+		switch valueString(fields["scan_type"]) {
+		case "IndexScan":
+			kind = msgs.index
+		case "BatchScan":
+			kind = msgs.batch
+		}
+		if target := valueString(fields["scan_target"]); target != "" {
+			sentences = append(sentences, fmt.Sprintf(msgs.scan, kind, target))
+		} else {
+			sentences = append(sentences, fmt.Sprintf(msgs.scanAny, kind))
+		}
+		if valueString(fields["Full scan"]) == "true" {
+			sentences = append(sentences, msgs.scanFull)
+		}
+	case name == "Filter Scan":
+		if size, err := strconv.Atoi(valueString(fields["seekable_key_size"])); err == nil && size > 0 {
+			sentences = append(sentences, fmt.Sprintf(msgs.scanSeek, size))
+		}
+	case name == "Distributed Union" && valueString(fields["call_type"]) == "Local":
+		sentences = append(sentences, msgs.localDistributedUnion)
+	case name == "Distributed Union":
+		if table := valueString(fields["distribution_table"]); table != "" {
+			sentences = append(sentences, fmt.Sprintf(msgs.distributedUnion, table))
+		} else {
+			sentences = append(sentences, msgs.distributedUnionAny)
+		}
+	case name == "Distributed Cross Apply":
+		sentences = append(sentences, msgs.distributedCrossApply)
+	case name == "Cross Apply":
+		sentences = append(sentences, msgs.crossApply)
+	case name == "Hash Join":
+		sentences = append(sentences, msgs.hashJoin)
+	case name == "Aggregate":
+		sentences = append(sentences, msgs.aggregate)
+	case name == "Sort":
+		sentences = append(sentences, msgs.sort)
+	case name == "Limit" || name == "Sort Limit":
+		sentences = append(sentences, msgs.limit)
+	case name == "Serialize Result":
+		sentences = append(sentences, msgs.serializeResult)
+	case name == "Filter":
+		if cond := scalarChildDescription(n, "Condition"); cond != "" {
+			sentences = append(sentences, fmt.Sprintf(msgs.filter, cond))
+		}
+	case isDMLOperator(n.node):
+		operation, table := valueString(fields["operation_type"]), valueString(fields["table"])
+		if operation != "" && table != "" {
+			sentences = append(sentences, fmt.Sprintf(msgs.applyMutations, operation, table))
+		}
+	}
+	if len(sentences) == 0 {
+		sentences = append(sentences, fmt.Sprintf(msgs.generic, n.title()))
+		if entry, ok := lookupOperator(name); ok && msgs.glossary {
+			sentences = append(sentences, entry.Description)
+		}
+	}
+	if cond := scalarChildDescription(n, "Residual Condition"); cond != "" {
+		sentences = append(sentences, fmt.Sprintf(msgs.residual, cond))
+	}
+
+	if rows, ok := n.stat("rows"); ok {
+		rowsText := strconv.FormatFloat(rows, 'f', -1, 64)
+		if latency, ok := n.stat("latency"); ok {
+			sentences = append(sentences, fmt.Sprintf(msgs.producedIn, rowsText, strconv.FormatFloat(latency, 'f', -1, 64)+" "+n.statUnit("latency")))
+		} else {
+			sentences = append(sentences, fmt.Sprintf(msgs.produced, rowsText))
+		}
+	}
+	return strings.Join(sentences, msgs.sentenceSeparator)
+}
@@ -0,0 +1,153 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+type nodeDetailParams struct {
+	// Input and ID, a loadPlan handle, are alternatives
+	Input   string `json:"input,omitempty"`
+	ID      string `json:"id,omitempty"`
+	NodeID  *int32 `json:"nodeId"`
+	Recover bool   `json:"recover,omitempty"`
+}
+
+// NodeDetail is everything about one plan node, returned by getNodeDetail
+type NodeDetail struct {
+	ID                  int32                    `json:"id"`
+	Kind                string                   `json:"kind"`
+	DisplayName         string                   `json:"displayName"`
+	Title               string                   `json:"title,omitempty"`
+	ShortRepresentation *NodeShortRepresentation `json:"shortRepresentation,omitempty"`
+	Metadata            map[string]any           `json:"metadata,omitempty"`
+	ExecutionStats      map[string]any           `json:"executionStats,omitempty"`
+	ChildLinks          []NodeDetailLink         `json:"childLinks,omitempty"`
+	// Predicates are the predicate-like scalar children, such as "Residual
+	// Condition", with the structure of their descriptions
+	Predicates []NodeDetailPredicate `json:"predicates,omitempty"`
+	// ParentChain lists the ancestors from the plan root down to the parent;
+	// it is empty for the root and for unreachable nodes
+	ParentChain []NodeDetailAncestor `json:"parentChain"`
+}
+
+// NodeShortRepresentation is the short representation of a scalar node
+type NodeShortRepresentation struct {
+	Description string           `json:"description"`
+	Subqueries  map[string]int32 `json:"subqueries,omitempty"`
+}
+
+// NodeDetailLink is a child link of a NodeDetail with the kind and name of
+// the child
+type NodeDetailLink struct {
+	ChildID     int32  `json:"childId"`
+	Type        string `json:"type,omitempty"`
+	Variable    string `json:"variable,omitempty"`
+	Kind        string `json:"kind"`
+	DisplayName string `json:"displayName"`
+}
+
+// NodeDetailPredicate is a predicate of a NodeDetail
+type NodeDetailPredicate struct {
+	ChildID     int32               `json:"childId"`
+	Type        string              `json:"type"`
+	Description string              `json:"description"`
+	Expression  PredicateExpression `json:"expression"`
+}
+
+// NodeDetailAncestor is an ancestor in NodeDetail.ParentChain
+type NodeDetailAncestor struct {
+	ID          int32  `json:"id"`
+	DisplayName string `json:"displayName"`
+	Title       string `json:"title,omitempty"`
+}
+
+// buildNodeDetail describes n, a node of its plan tree.
+func buildNodeDetail(n *treeNode) NodeDetail {
+	detail := NodeDetail{
+		ID:          n.id(),
+		Kind:        n.node.GetKind().String(),
+		DisplayName: n.node.GetDisplayName(),
+		ParentChain: []NodeDetailAncestor{},
+	}
+	if n.isRelational() {
+		detail.Title = n.title()
+	}
+	if sr := n.node.GetShortRepresentation(); sr != nil {
+		detail.ShortRepresentation = &NodeShortRepresentation{Description: sr.GetDescription(), Subqueries: sr.GetSubqueries()}
+	}
+	if m := n.node.GetMetadata(); len(m.GetFields()) > 0 {
+		detail.Metadata = m.AsMap()
+	}
+	if s := n.node.GetExecutionStats(); len(s.GetFields()) > 0 {
+		detail.ExecutionStats = s.AsMap()
+	}
+	for _, c := range n.children {
+		detail.ChildLinks = append(detail.ChildLinks, NodeDetailLink{
+			ChildID:     c.node.id(),
+			Type:        c.link.GetType(),
+			Variable:    c.link.GetVariable(),
+			Kind:        c.node.node.GetKind().String(),
+			DisplayName: c.node.node.GetDisplayName(),
+		})
+		if !c.node.isRelational() && isPredicateLink(c.link.GetType()) {
+			description := c.node.node.GetShortRepresentation().GetDescription()
+			detail.Predicates = append(detail.Predicates, NodeDetailPredicate{
+				ChildID:     c.node.id(),
+				Type:        c.link.GetType(),
+				Description: description,
+				Expression:  parsePredicate(description),
+			})
+		}
+	}
+	for p := n.parent; p != nil; p = p.parent {
+		ancestor := NodeDetailAncestor{ID: p.id(), DisplayName: p.node.GetDisplayName()}
+		if p.isRelational() {
+			ancestor.Title = p.title()
+		}
+		detail.ParentChain = append(detail.ParentChain, ancestor)
+	}
+	slices.Reverse(detail.ParentChain)
+	return detail
+}
+
+// getNodeDetail returns the metadata, stats, child links, and ancestors of
+// one node as JSON, for the detail panel of a clicked row
+func getNodeDetail(paramsJSON string) (Response, error) {
+	par := nodeDetailParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return getNodeDetailImpl(par)
+}
+
+func getNodeDetailImpl(par nodeDetailParams) (Response, error) {
+	switch {
+	case par.NodeID == nil:
+		return Response{}, InvalidParametersError{msg: "nodeId is required"}
+	case par.ID != "" && par.Input != "":
+		return Response{}, InvalidParametersError{msg: "input and id are mutually exclusive"}
+	case par.ID != "":
+		plan, err := session.get(par.ID)
+		if err != nil {
+			return Response{}, err
+		}
+		par.Input, par.Recover = plan.Input, par.Recover || plan.Recover
+	}
+
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	tree := buildPlanTree(stats.GetQueryPlan().GetPlanNodes())
+	id := *par.NodeID
+	if id < 0 || int(id) >= len(tree.nodes) {
+		return Response{}, InvalidParametersError{msg: fmt.Sprintf("Invalid nodeId: %d (the plan has nodes 0 to %d)", id, len(tree.nodes)-1)}
+	}
+	b, err := json.Marshal(buildNodeDetail(tree.nodes[id]))
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal node detail: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
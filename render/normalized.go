@@ -0,0 +1,50 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// formatNormalizedJSON is the renderASCII format that re-serializes the parsed
+// plan as canonical JSON, so that plans stored in git diff by their content
+// whatever format they were captured in.
+const formatNormalizedJSON = "JSON-NORMALIZED"
+
+// writeNormalizedJSON returns stats with planNodes as its plan, as
+// ResultSetStats JSON with the lowerCamelCase field names of the REST API,
+// object keys sorted, two-space indents, and a final newline. protojson alone
+// is not canonical: it varies its whitespace between builds and keeps the
+// order of Struct keys from the input.
+func writeNormalizedJSON(stats *sppb.ResultSetStats, planNodes []*sppb.PlanNode) (string, error) {
+	// The plan nodes may be shared with the parse cache
+	stats = proto.Clone(stats).(*sppb.ResultSetStats)
+	if stats.GetQueryPlan() != nil {
+		stats.QueryPlan.PlanNodes = planNodes
+	}
+	b, err := protojson.Marshal(stats)
+	if err != nil {
+		return "", RenderError{msg: fmt.Sprintf("Failed to marshal normalized plan: %v", err)}
+	}
+
+	// Numbers are decoded as json.Number so that they keep their spelling
+	var v any
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return "", RenderError{msg: fmt.Sprintf("Failed to normalize plan: %v", err)}
+	}
+	var out bytes.Buffer
+	e := json.NewEncoder(&out)
+	// Predicates such as "($a < $b)" stay readable
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+	if err := e.Encode(v); err != nil {
+		return "", RenderError{msg: fmt.Sprintf("Failed to marshal normalized plan: %v", err)}
+	}
+	return out.String(), nil
+}
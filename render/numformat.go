@@ -0,0 +1,179 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Duration units of the numberFormat option, with the execution stat units
+// that label them in the table
+var durationUnits = map[string]string{
+	"s":  "secs",
+	"ms": "msecs",
+	"µs": "usecs",
+	"us": "usecs",
+}
+
+// siPrefixes are the SI prefixes of counts from 1,000 up
+var siPrefixes = []string{"k", "M", "G", "T", "P", "E"}
+
+// NumberFormat is the numberFormat option, which reformats the execution
+// stat columns of the table formats. Structured APIs keep the raw values.
+type NumberFormat struct {
+	// ThousandsSeparator groups the integer digits with commas
+	ThousandsSeparator bool `json:"thousandsSeparator,omitempty"`
+	// SIUnits abbreviates counts of 1,000 and more, e.g. 1.2M; durations
+	// have units already
+	SIUnits bool `json:"siUnits,omitempty"`
+	// DurationUnit converts the durations to "s", "ms", or "µs" ("us")
+	DurationUnit string `json:"durationUnit,omitempty"`
+}
+
+func (f NumberFormat) check() error {
+	if _, ok := durationUnits[f.DurationUnit]; !ok && f.DurationUnit != "" {
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid durationUnit: %q (expected \"s\", \"ms\", or \"µs\")", f.DurationUnit)}
+	}
+	return nil
+}
+
+// count formats a count of rows or executions.
+func (f NumberFormat) count(v float64) string {
+	if f.SIUnits && math.Abs(v) >= 1000 {
+		prefix := -1
+		for math.Abs(v) >= 999.95 && prefix+1 < len(siPrefixes) {
+			v /= 1000
+			prefix++
+		}
+		return formatDecimal(v, 1) + siPrefixes[prefix]
+	}
+	return f.group(formatDecimal(v, 3))
+}
+
+// duration formats a duration stat of total in unit, converted to
+// DurationUnit if set.
+func (f NumberFormat) duration(total float64, unit string) string {
+	total, unit = f.convertDuration(total, unit)
+	return f.group(formatDecimal(total, 3)) + " " + unit
+}
+
+// convertDuration converts a duration in unit to DurationUnit, if set, and
+// returns it with its unit.
+func (f NumberFormat) convertDuration(v float64, unit string) (float64, string) {
+	if f.DurationUnit == "" {
+		return v, unit
+	}
+	v = millis(v, unit)
+	switch unit = durationUnits[f.DurationUnit]; unit {
+	case "secs":
+		v /= 1000
+	case "usecs":
+		v *= 1000
+	}
+	return v, unit
+}
+
+// group adds thousands separators to the integer digits of a formatted
+// number, if enabled.
+func (f NumberFormat) group(s string) string {
+	if !f.ThousandsSeparator {
+		return s
+	}
+	sign, digits := "", s
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	fraction := ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		digits, fraction = digits[:i], digits[i:]
+	}
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String() + fraction
+}
+
+// formatDecimal formats v with at most decimals fraction digits, without
+// trailing zeros.
+func formatDecimal(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// numberCells returns the reformatted cells of the execution stat columns by
+// title and node ID.
+func (f NumberFormat) numberCells(tree *planTree) map[string]map[int32]string {
+	columns := map[string]map[int32]string{
+		"Rows":          {},
+		"Exec.":         {},
+		"Total Latency": {},
+		cpuColumnTitle:  {},
+	}
+	tree.root.walk(func(n *treeNode) {
+		if v, ok := n.stat("rows"); ok {
+			columns["Rows"][n.id()] = f.count(v)
+		}
+		if v, ok := n.numExecutions(); ok {
+			columns["Exec."][n.id()] = f.count(v)
+		}
+		if v, ok := n.stat("latency"); ok {
+			columns["Total Latency"][n.id()] = f.duration(v, n.statUnit("latency"))
+		}
+		if v, ok := n.stat("cpu_time"); ok {
+			columns[cpuColumnTitle][n.id()] = f.duration(v, n.statUnit("cpu_time"))
+		}
+	})
+	return columns
+}
+
+// applyNumberFormat replaces the cells of the execution stat columns of the
+// table at the start of rendered with their values formatted by f. The
+// zero numberFormat leaves the table unchanged, unless canonical is set for
+// the deterministic option, which formats the stats the same way however the
+// input spelled them, e.g. "3.50" as "3.5".
+func applyNumberFormat(rendered string, tree *planTree, f NumberFormat, canonical bool) string {
+	if f == (NumberFormat{}) && !canonical {
+		return rendered
+	}
+	return replaceColumnCells(rendered, f.numberCells(tree))
+}
+
+// replaceColumnCells replaces the cells of the operator rows in the columns
+// of the table at the start of rendered with the given cells by title and
+// node ID. The columns are resized to their new cells and right-aligned.
+func replaceColumnCells(rendered string, columns map[string]map[int32]string) string {
+	return relayColumns(rendered, func(title string, _ int) cellLayout {
+		cells, ok := columns[title]
+		if !ok {
+			return nil
+		}
+		width := utf8.RuneCountInString(title)
+		for _, cell := range cells {
+			width = max(width, utf8.RuneCountInString(cell))
+		}
+		return func(cell string, line tableLine) string {
+			switch {
+			case line.border:
+				return strings.Repeat("-", width+2)
+			case line.row:
+				cell = cells[line.id]
+			default:
+				cell = strings.TrimSpace(cell)
+			}
+			pad := strings.Repeat(" ", width-utf8.RuneCountInString(cell))
+			if cell == title {
+				return " " + cell + pad + " "
+			}
+			return " " + pad + cell + " "
+		}
+	})
+}
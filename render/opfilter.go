@@ -0,0 +1,338 @@
+package render
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Built-in triage views selectable with the operatorFilter option
+const (
+	operatorFilterScans       = "scans-only"
+	operatorFilterJoins       = "joins-only"
+	operatorFilterDistributed = "distributed-only"
+	operatorFilterCompute     = "compute-only"
+)
+
+// operatorFilters classify operators by their display name. Names are
+// matched case-insensitively so that console naming does not change the
+// result.
+var operatorFilters = map[string]func(name string) bool{
+	operatorFilterScans: func(name string) bool {
+		return strings.Contains(name, "scan")
+	},
+	operatorFilterJoins: func(name string) bool {
+		return strings.Contains(name, "join") || (strings.Contains(name, "apply") && !strings.Contains(name, "mutations"))
+	},
+	operatorFilterDistributed: func(name string) bool {
+		return strings.HasPrefix(name, "distributed ")
+	},
+	operatorFilterCompute: func(name string) bool {
+		return strings.Contains(name, "compute") || strings.Contains(name, "aggregate") || strings.Contains(name, "sort") ||
+			name == "filter" || name == "limit"
+	},
+}
+
+// operatorFilterDescriptions describe the operatorFilters for getCapabilities.
+var operatorFilterDescriptions = map[string]string{
+	operatorFilterScans:       "Table, index, and filter scans",
+	operatorFilterJoins:       "Joins and applies",
+	operatorFilterDistributed: "Distributed unions and applies",
+	operatorFilterCompute:     "Compute, aggregate, sort, filter, and limit operators",
+}
+
+func checkOperatorFilter(filter string) error {
+	if _, ok := operatorFilters[filter]; filter == "" || ok {
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid operatorFilter: %q (expected one of %s)", filter, strings.Join(sortedKeys(operatorFilters), ", "))}
+}
+
+// operatorFilterIDs returns the IDs of the operators of tree that pass
+// filter.
+func operatorFilterIDs(tree *planTree, filter string) map[int32]bool {
+	match := operatorFilters[filter]
+	keep := make(map[int32]bool)
+	tree.root.walk(func(n *treeNode) {
+		if match(strings.ToLower(n.node.GetDisplayName())) {
+			keep[n.id()] = true
+		}
+	})
+	return keep
+}
+
+// filterTableRows removes the operator rows of the table at the start of a
+// rendered plan whose IDs are not in keep, together with their continuation
+// and annotation lines. Lines after the table are unchanged.
+func filterTableRows(rendered string, keep map[int32]bool) string {
+	lines := strings.Split(rendered, "\n")
+	out := make([]string, 0, len(lines))
+	dropping := false
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "|") && !strings.HasPrefix(line, "+") {
+			out = append(out, lines[i:]...)
+			break
+		}
+		if id, _, ok := tableRowID(line); ok {
+			dropping = !keep[id]
+		} else if strings.HasPrefix(line, "+") {
+			dropping = false
+		}
+		if !dropping {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// filterCondition is a condition of the filter option, e.g. latency>10ms.
+type filterCondition struct {
+	field string
+	op    string
+	value string
+	// re is the case-insensitive pattern of the ~ and !~ operators
+	re *regexp.Regexp
+	// numeric marks comparisons of numbers; number is the value, in
+	// milliseconds for durations
+	numeric bool
+	number  float64
+}
+
+// nodeFilter is the parsed filter option: conditions that must all hold.
+type nodeFilter []filterCondition
+
+// Fields of filter conditions besides metadata.<key> and stats.<name>
+const (
+	filterFieldOperator   = "operator"
+	filterFieldID         = "id"
+	filterFieldRows       = "rows"
+	filterFieldLatency    = "latency"
+	filterFieldCPU        = "cpu"
+	filterFieldExecutions = "executions"
+)
+
+// filterOperators are the comparison operators, longest first so that
+// parsing finds ">=" before ">".
+var filterOperators = []string{"!=", "!~", ">=", "<=", "=", "~", ">", "<"}
+
+// parseNodeFilter parses the filter option, a list of conditions separated
+// by whitespace or AND, each a field, an operator, and a value that is quoted
+// if it has spaces. Metadata keys with spaces are written with underscores:
+//
+//	operator~"Scan" metadata.scan_type=TableScan latency>10ms
+//
+// ~ and !~ match a case-insensitive regular expression; =, != and the
+// ordering operators compare numbers when the value is a number or, for
+// latency and cpu, a duration, and strings otherwise.
+func parseNodeFilter(s string) (nodeFilter, error) {
+	var filter nodeFilter
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		if word, after, ok := strings.Cut(rest, " "); ok && strings.EqualFold(word, "AND") && len(filter) > 0 {
+			rest = strings.TrimSpace(after)
+			continue
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool { return strings.ContainsRune("=!~<> ", r) })
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, InvalidParametersError{msg: fmt.Sprintf("Invalid filter: expected a condition such as latency>10ms at %q", rest)}
+		}
+		c := filterCondition{field: filterField(rest[:end])}
+		rest = rest[end:]
+		for _, op := range filterOperators {
+			if strings.HasPrefix(rest, op) {
+				c.op = op
+				break
+			}
+		}
+		if c.op == "" {
+			return nil, InvalidParametersError{msg: fmt.Sprintf("Invalid filter: expected one of %s after %q", strings.Join(filterOperators, " "), c.field)}
+		}
+		rest = rest[len(c.op):]
+
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, InvalidParametersError{msg: fmt.Sprintf("Invalid filter: unterminated string in %q", rest)}
+			}
+			c.value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else {
+			end := strings.IndexByte(rest, ' ')
+			if end < 0 {
+				end = len(rest)
+			}
+			c.value, rest = rest[:end], rest[end:]
+		}
+		rest = strings.TrimSpace(rest)
+
+		if err := c.compile(); err != nil {
+			return nil, err
+		}
+		filter = append(filter, c)
+	}
+	return filter, nil
+}
+
+// filterField lower-cases the field of a condition except for the metadata
+// key or stat name.
+func filterField(field string) string {
+	prefix, key, found := strings.Cut(field, ".")
+	if !found {
+		return strings.ToLower(field)
+	}
+	return strings.ToLower(prefix) + "." + key
+}
+
+// compile checks the field and operator of c and parses its value.
+func (c *filterCondition) compile() error {
+	duration := c.field == filterFieldLatency || c.field == filterFieldCPU
+	textual := c.field == filterFieldOperator || strings.HasPrefix(c.field, "metadata.")
+	switch {
+	case textual && c.field != "metadata.", c.field == filterFieldID, c.field == filterFieldRows, c.field == filterFieldExecutions, duration:
+	case strings.HasPrefix(c.field, "stats.") && c.field != "stats.":
+	default:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid filter field: %q (expected operator, id, rows, latency, cpu, executions, metadata.<key>, or stats.<name>)", c.field)}
+	}
+
+	if c.op == "~" || c.op == "!~" {
+		re, err := regexp.Compile("(?i)" + c.value)
+		if err != nil {
+			return InvalidParametersError{msg: fmt.Sprintf("Invalid filter pattern %q: %v", c.value, err)}
+		}
+		c.re = re
+		return nil
+	}
+	if f, err := strconv.ParseFloat(c.value, 64); err == nil {
+		c.number, c.numeric = f, true
+		return nil
+	}
+	if d, err := time.ParseDuration(c.value); err == nil && duration {
+		c.number, c.numeric = float64(d)/float64(time.Millisecond), true
+		return nil
+	}
+	if textual && (c.op == "=" || c.op == "!=") {
+		return nil
+	}
+	expected := "a number"
+	if duration {
+		expected += " or a duration such as 10ms"
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid filter value for %s%s: %q (expected %s)", c.field, c.op, c.value, expected)}
+}
+
+// fieldValue returns the value of the field of c for n as text and, if it
+// is numeric, as a number in the unit of c.number. ok is false if n has no
+// such field.
+func (c filterCondition) fieldValue(n *treeNode) (text string, number float64, numeric, ok bool) {
+	switch {
+	case c.field == filterFieldOperator:
+		return n.operatorName(), 0, false, true
+	case c.field == filterFieldID:
+		return strconv.Itoa(int(n.id())), float64(n.id()), true, true
+	case c.field == filterFieldLatency, c.field == filterFieldCPU:
+		name := map[string]string{filterFieldLatency: "latency", filterFieldCPU: "cpu_time"}[c.field]
+		v, ok := n.durationMillis(name)
+		return strconv.FormatFloat(v, 'f', -1, 64), v, true, ok
+	case c.field == filterFieldRows:
+		v, ok := n.stat("rows")
+		return strconv.FormatFloat(v, 'f', -1, 64), v, true, ok
+	case c.field == filterFieldExecutions:
+		summary := n.node.GetExecutionStats().GetFields()["execution_summary"]
+		text = valueString(summary.GetStructValue().GetFields()["num_executions"])
+	case strings.HasPrefix(c.field, "stats."):
+		v, ok := n.stat(strings.TrimPrefix(c.field, "stats."))
+		return strconv.FormatFloat(v, 'f', -1, 64), v, true, ok
+	default:
+		// Keys with spaces are written with underscores, as in flat exports,
+		// e.g. metadata.Full_scan
+		key := strings.TrimPrefix(c.field, "metadata.")
+		fields := n.node.GetMetadata().GetFields()
+		v, found := fields[key]
+		for k, field := range fields {
+			if !found && flatColumnName(k) == key {
+				v, found = field, true
+			}
+		}
+		if !found {
+			return "", 0, false, false
+		}
+		text = valueString(v)
+	}
+	if text == "" {
+		return "", 0, false, false
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	return text, f, err == nil, true
+}
+
+// match reports whether n satisfies c. Operators without the field only
+// satisfy != and !~.
+func (c filterCondition) match(n *treeNode) bool {
+	text, number, numeric, ok := c.fieldValue(n)
+	switch {
+	case !ok:
+		return c.op == "!=" || c.op == "!~"
+	case c.re != nil:
+		return c.re.MatchString(text) == (c.op == "~")
+	case !c.numeric:
+		// Text is compared case-insensitively, like operator names elsewhere
+		return strings.EqualFold(text, c.value) == (c.op == "=")
+	case !numeric:
+		return c.op == "!="
+	}
+	switch c.op {
+	case "=":
+		return number == c.number
+	case "!=":
+		return number != c.number
+	case ">":
+		return number > c.number
+	case ">=":
+		return number >= c.number
+	case "<":
+		return number < c.number
+	default:
+		return number <= c.number
+	}
+}
+
+// ids returns the IDs of the operators of tree matching every condition of
+// f and of their ancestors, so that the matches keep their place in the
+// tree.
+func (f nodeFilter) ids(tree *planTree) map[int32]bool {
+	keep := make(map[int32]bool)
+	tree.root.walk(func(n *treeNode) {
+		for _, c := range f {
+			if !c.match(n) {
+				return
+			}
+		}
+		for p := n; p != nil && !keep[p.id()]; p = p.parent {
+			keep[p.id()] = true
+		}
+	})
+	return keep
+}
+
+// keptRowIDs returns the IDs of the operators kept by the operatorFilter and
+// filter options, or nil if neither is set.
+func keptRowIDs(tree *planTree, operatorFilter string, filter nodeFilter) map[int32]bool {
+	var keep map[int32]bool
+	if operatorFilter != "" {
+		keep = operatorFilterIDs(tree, operatorFilter)
+	}
+	if len(filter) > 0 {
+		matched := filter.ids(tree)
+		if keep == nil {
+			return matched
+		}
+		maps.DeleteFunc(keep, func(id int32, _ bool) bool { return !matched[id] })
+	}
+	return keep
+}
@@ -0,0 +1,84 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// WarningCodeNoOptimizerInfo is for showOptimizerInfo without optimizer
+// settings in the input
+const WarningCodeNoOptimizerInfo = "NO_OPTIMIZER_INFO"
+
+// OptimizerInfo is the optimizer version and statistics package recorded in
+// the query stats of PROFILE captures, which explain why the plans of the same
+// query differ between environments.
+type OptimizerInfo struct {
+	Version           string `json:"version,omitempty"`
+	StatisticsPackage string `json:"statisticsPackage,omitempty"`
+	// StatisticsTimestamp is when the statistics package was constructed in
+	// RFC 3339, as encoded in names such as "auto_20250601_05_12_34UTC"
+	StatisticsTimestamp string `json:"statisticsTimestamp,omitempty"`
+}
+
+// statisticsPackagePattern matches the construction time that ends the names
+// of statistics packages, e.g. "auto_20250601_05_12_34UTC".
+var statisticsPackagePattern = regexp.MustCompile(`(\d{8}_\d{2}_\d{2}_\d{2})UTC$`)
+
+// statisticsPackageTime returns the construction time encoded in the name of
+// a statistics package.
+func statisticsPackageTime(name string) (time.Time, bool) {
+	m := statisticsPackagePattern.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("20060102_15_04_05", m[1])
+	return t, err == nil
+}
+
+// buildOptimizerInfo reads the optimizer settings of the query stats, or
+// returns nil if there are none.
+func buildOptimizerInfo(queryStats *structpb.Struct) *OptimizerInfo {
+	fields := queryStats.GetFields()
+	info := OptimizerInfo{
+		Version:           valueString(fields["optimizer_version"]),
+		StatisticsPackage: valueString(fields["optimizer_statistics_package"]),
+	}
+	if t, ok := statisticsPackageTime(info.StatisticsPackage); ok {
+		info.StatisticsTimestamp = t.Format(time.RFC3339)
+	}
+	if info == (OptimizerInfo{}) {
+		return nil
+	}
+	return &info
+}
+
+// optimizerHeader returns the "Optimizer:" header for the rendered output,
+// e.g.
+//
+//	Optimizer:
+//	 Version: 7
+//	 Statistics package: auto_20250601_05_12_34UTC (constructed 2025-06-01 05:12:34 UTC)
+func optimizerHeader(stats *sppb.ResultSetStats) (string, []Warning) {
+	info := buildOptimizerInfo(stats.GetQueryStats())
+	if info == nil {
+		return "", []Warning{{Code: WarningCodeNoOptimizerInfo, Message: "The input has no optimizer version or statistics package; the optimizer header is omitted"}}
+	}
+	var b strings.Builder
+	b.WriteString("Optimizer:\n")
+	if info.Version != "" {
+		fmt.Fprintf(&b, " Version: %s\n", info.Version)
+	}
+	if info.StatisticsPackage != "" {
+		fmt.Fprintf(&b, " Statistics package: %s", info.StatisticsPackage)
+		if t, ok := statisticsPackageTime(info.StatisticsPackage); ok {
+			fmt.Fprintf(&b, " (constructed %s)", t.Format("2006-01-02 15:04:05 UTC"))
+		}
+		b.WriteString("\n")
+	}
+	return b.String() + "\n", nil
+}
@@ -0,0 +1,171 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// APIVersion is the version of the renderASCII options. It is incremented
+// when an option changes its meaning, so that frontends written for another
+// version get an UNSUPPORTED_OPTION error instead of different output.
+const APIVersion = 1
+
+// minAPIVersion is the oldest apiVersion whose options are still rendered
+// as documented.
+const minAPIVersion = 1
+
+// WarningCodeUnknownOption is reported for parameters that are not options,
+// such as misspelled ones, which are ignored or, when they differ from an
+// option only in case, read as that option.
+const WarningCodeUnknownOption = "UNKNOWN_OPTION"
+
+// checkAPIVersion rejects the apiVersion option unless it is 0, the current
+// version, or an older version with the same options.
+func checkAPIVersion(version int) error {
+	supported := fmt.Sprintf("apiVersion %d", APIVersion)
+	if minAPIVersion < APIVersion {
+		supported = fmt.Sprintf("apiVersion %d to %d", minAPIVersion, APIVersion)
+	}
+	switch {
+	case version == 0 || (version >= minAPIVersion && version <= APIVersion):
+		return nil
+	case version < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid apiVersion: %d (must not be negative)", version)}
+	case version > APIVersion:
+		return UnsupportedOptionError{msg: fmt.Sprintf("apiVersion %d is not supported; this renderer supports %s. Update the renderer", version, supported)}
+	default:
+		return UnsupportedOptionError{msg: fmt.Sprintf("apiVersion %d is no longer supported; this renderer supports %s. Update the frontend", version, supported)}
+	}
+}
+
+// unknownOptionWarnings returns an UNKNOWN_OPTION warning for each member of
+// paramsJSON, and of its nested option objects, that is not a field of v.
+// encoding/json ignores such members, or reads them into the field whose
+// name differs only in case, without an error.
+func unknownOptionWarnings(paramsJSON []byte, v any) []Warning {
+	return unknownFields(paramsJSON, reflect.TypeOf(v), "")
+}
+
+// DecodeParams decodes renderASCII parameters from JSON into opts, over the
+// options it already has, and returns the input. Render reports the members
+// of data that are not parameters as UNKNOWN_OPTION warnings, as renderASCII
+// does.
+func DecodeParams(data []byte, opts *Options) (string, error) {
+	par := params{Options: *opts}
+	if err := json.Unmarshal(data, &par); err != nil {
+		return "", ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	par.noteUnknownOptions(data, par)
+	*opts = par.Options
+	return par.Input, nil
+}
+
+// noteUnknownOptions keeps the UNKNOWN_OPTION warnings of paramsJSON, which v
+// was decoded from, for withUnknownOptions.
+func (o *Options) noteUnknownOptions(paramsJSON []byte, v any) {
+	o.unknownOptions = append(o.unknownOptions, unknownOptionWarnings(paramsJSON, v)...)
+}
+
+// withUnknownOptions returns resp with the UNKNOWN_OPTION warnings noted in o
+// before its own.
+func (o Options) withUnknownOptions(resp Response) Response {
+	resp.Warnings = slices.Concat(o.unknownOptions, resp.Warnings)
+	return resp
+}
+
+func unknownFields(data []byte, t reflect.Type, path string) []Warning {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+	case reflect.Slice, reflect.Array:
+		var elems []json.RawMessage
+		if json.Unmarshal(data, &elems) != nil {
+			return nil
+		}
+		var warnings []Warning
+		for i, elem := range elems {
+			warnings = append(warnings, unknownFields(elem, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return warnings
+	case reflect.Map:
+		var members map[string]json.RawMessage
+		if json.Unmarshal(data, &members) != nil {
+			return nil
+		}
+		var warnings []Warning
+		for _, key := range sortedKeys(members) {
+			warnings = append(warnings, unknownFields(members[key], t.Elem(), joinPath(path, key))...)
+		}
+		return warnings
+	default:
+		return nil
+	}
+
+	var members map[string]json.RawMessage
+	if json.Unmarshal(data, &members) != nil {
+		return nil
+	}
+	fields := jsonFields(t)
+	var warnings []Warning
+	for _, name := range sortedKeys(members) {
+		memberPath := joinPath(path, name)
+		if f, ok := fields[name]; ok {
+			warnings = append(warnings, unknownFields(members[name], f.Type, memberPath)...)
+			continue
+		}
+		var folded string
+		for field := range fields {
+			if strings.EqualFold(field, name) {
+				folded = field
+				break
+			}
+		}
+		if folded != "" {
+			warnings = append(warnings, Warning{
+				Code:    WarningCodeUnknownOption,
+				Message: fmt.Sprintf("Unknown option %q is read as %q; spell it with that case", memberPath, joinPath(path, folded)),
+				Path:    memberPath,
+			})
+			continue
+		}
+		warnings = append(warnings, Warning{
+			Code:    WarningCodeUnknownOption,
+			Message: fmt.Sprintf("Unknown option %q is ignored", memberPath),
+			Path:    memberPath,
+		})
+	}
+	return warnings
+}
+
+// jsonFields returns the fields of the struct type t by their JSON names,
+// including those of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,182 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// rangeParams are renderASCII parameters with the operator rows of a page.
+type rangeParams struct {
+	params
+	// Offset is the index of the first operator row of the page
+	Offset int `json:"offset,omitempty"`
+	// Limit is the number of operator rows of the page; zero means the rest
+	Limit int `json:"limit,omitempty"`
+	// StickyPrefix repeats the first lines of the ancestors of the first row
+	// above a page that starts mid-subtree
+	StickyPrefix bool `json:"stickyPrefix,omitempty"`
+}
+
+// RenderRangeResult is a page of a rendered table
+type RenderRangeResult struct {
+	Text string `json:"text"`
+	// TotalRows is the number of operator rows of the whole table
+	TotalRows int `json:"totalRows"`
+	Offset    int `json:"offset"`
+	// Rows is the number of operator rows on the page, not counting the
+	// ancestors repeated by stickyPrefix
+	Rows int `json:"rows"`
+}
+
+// tableRow is an operator row of a rendered table: its first line and any
+// continuation and annotation lines.
+type tableRow struct {
+	id    int32
+	lines []string
+}
+
+// renderedTable is a rendered plan split into the parts a page is built from.
+type renderedTable struct {
+	// head is any query text header, the top border, and the header lines,
+	// through the header border
+	head []string
+	rows []tableRow
+	// tail is the bottom border and the appendices after it
+	tail []string
+}
+
+// splitRenderedTable splits the table of rendered into rows. It reports false
+// if rendered has no table.
+func splitRenderedTable(rendered string) (renderedTable, bool) {
+	lines := strings.Split(rendered, "\n")
+	isBorder := func(line string) bool { return strings.HasPrefix(line, "+") }
+	top := slices.IndexFunc(lines, isBorder)
+	if top < 0 {
+		return renderedTable{}, false
+	}
+	// Column groups add header lines, so find the header border
+	headEnd := slices.IndexFunc(lines[top+1:], isBorder)
+	if headEnd < 0 {
+		return renderedTable{}, false
+	}
+	headEnd += top + 1
+
+	t := renderedTable{head: slices.Clip(lines[:headEnd+1])}
+	for i := headEnd + 1; i < len(lines); i++ {
+		line := lines[i]
+		if !strings.HasPrefix(line, "|") {
+			t.tail = lines[i:]
+			break
+		}
+		if id, _, ok := tableRowID(line); ok {
+			t.rows = append(t.rows, tableRow{id: id})
+		} else if len(t.rows) == 0 {
+			t.head = append(t.head, line)
+			continue
+		}
+		last := &t.rows[len(t.rows)-1]
+		last.lines = append(last.lines, line)
+	}
+	return t, true
+}
+
+// stickyLines returns the first lines of the ancestors of the row with ID
+// id that are rows of the table, from the root down.
+func (t renderedTable) stickyLines(tree *planTree, id int32) []string {
+	firstLines := make(map[int32]string, len(t.rows))
+	for _, row := range t.rows {
+		firstLines[row.id] = row.lines[0]
+	}
+	if int(id) >= len(tree.nodes) {
+		return nil
+	}
+	var lines []string
+	for n := tree.nodes[id].parent; n != nil; n = n.parent {
+		if line, ok := firstLines[n.id()]; ok {
+			lines = append(lines, line)
+		}
+	}
+	slices.Reverse(lines)
+	return lines
+}
+
+// renderRange renders a page of the operator rows of renderASCII's table
+// output, so that huge plans can be shown incrementally. Appendices follow
+// the last page.
+func renderRange(paramsJSON string) (Response, error) {
+	par := rangeParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	par.noteUnknownOptions([]byte(paramsJSON), par)
+	return renderRangeImpl(par)
+}
+
+func renderRangeImpl(par rangeParams) (Response, error) {
+	if par.Offset < 0 || par.Limit < 0 {
+		return Response{}, InvalidParametersError{msg: fmt.Sprintf("Invalid range: offset %d, limit %d (must not be negative)", par.Offset, par.Limit)}
+	}
+	_, custom := lookupFormatter(par.Format)
+	_, registered := lookupRenderer(par.Format)
+	if custom || registered || isHTMLFormat(par.Format) {
+		return Response{}, InvalidParametersError{msg: fmt.Sprintf("renderRange does not support format %q: only table formats have rows", par.Format)}
+	}
+
+	// Rows are found in the ASCII borders; the page is styled once assembled
+	borderStyle := par.BorderStyle
+	if err := checkBorderStyle(borderStyle); err != nil {
+		return Response{}, err
+	}
+	par.BorderStyle = ""
+	resp, err := renderASCIIImpl(par.params)
+	if err != nil {
+		return Response{}, err
+	}
+	table, ok := splitRenderedTable(resp.Result)
+	if !ok {
+		return Response{}, RenderError{msg: "Rendered plan has no table"}
+	}
+
+	start := min(par.Offset, len(table.rows))
+	end := len(table.rows)
+	if par.Limit > 0 {
+		end = min(start+par.Limit, end)
+	}
+	page := slices.Clone(table.head)
+	if par.StickyPrefix && start > 0 && start < end {
+		// IDs are the same with and without console naming, so the tree of
+		// the parsed plan finds the ancestors
+		stats, _, _, err := par.queryPlan()
+		if err != nil {
+			return Response{}, extractError(err)
+		}
+		planNodes := stats.GetQueryPlan().GetPlanNodes()
+		if par.Recover {
+			planNodes, _ = recoverPlanNodes(planNodes, par.Input)
+		}
+		if sticky := table.stickyLines(buildPlanTree(planNodes), table.rows[start].id); len(sticky) > 0 {
+			page = append(page, sticky...)
+			page = append(page, table.head[len(table.head)-1])
+		}
+	}
+	for _, row := range table.rows[start:end] {
+		page = append(page, row.lines...)
+	}
+	if end == len(table.rows) {
+		page = append(page, table.tail...)
+	} else if len(table.tail) > 0 {
+		page = append(page, table.tail[0], "")
+	}
+
+	text := strings.Join(page, "\n")
+	if borderStyle != "" {
+		text, _ = applyBorderStyle(text, borderStyle)
+	}
+	b, err := json.Marshal(RenderRangeResult{Text: text, TotalRows: len(table.rows), Offset: start, Rows: end - start})
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal page: %v", err)}
+	}
+	return par.withUnknownOptions(Response{Result: string(b), Warnings: resp.Warnings, Metadata: resp.Metadata}), nil
+}
@@ -0,0 +1,131 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type parsePlanParams struct {
+	Input            string `json:"input"`
+	ConsoleNaming    bool   `json:"consoleNaming,omitempty"`
+	Recover          bool   `json:"recover,omitempty"`
+	DecimalSeparator string `json:"decimalSeparator,omitempty"`
+	StatDurationUnit string `json:"statDurationUnit,omitempty"`
+}
+
+// ParsedPlan is the structured plan returned by parsePlan
+type ParsedPlan struct {
+	// Nodes is indexed by node ID; Nodes[0] is the root
+	Nodes []ParsedNode `json:"nodes"`
+	// Variables are the variables bound by child links, sorted by name;
+	// names bound to different expressions are left out
+	Variables  []VariableDefinition `json:"variables"`
+	QueryStats map[string]any       `json:"queryStats,omitempty"`
+}
+
+// ParsedNode is one plan node with its links resolved. Nodes unreachable from
+// the root have no parentId and depth 0.
+type ParsedNode struct {
+	ID             int32          `json:"id"`
+	Kind           string         `json:"kind"`
+	DisplayName    string         `json:"displayName"`
+	Title          string         `json:"title,omitempty"`
+	Description    string         `json:"description,omitempty"`
+	ParentID       *int32         `json:"parentId,omitempty"`
+	Depth          int            `json:"depth"`
+	Children       []ParsedLink   `json:"children,omitempty"`
+	Metadata       map[string]any `json:"metadata,omitempty"`
+	ExecutionStats map[string]any `json:"executionStats,omitempty"`
+	// StatValues are the totals of the execution stats as numbers, with
+	// durations in milliseconds whatever their unit
+	StatValues map[string]float64 `json:"statValues,omitempty"`
+	// References are the variables the description of a scalar node, or the
+	// scan target of an operator such as "Batch Scan on $v2", refers to, with
+	// the nodes that define them
+	References []VariableReference `json:"references,omitempty"`
+}
+
+// ParsedLink is a child link of a ParsedNode
+type ParsedLink struct {
+	ChildID  int32  `json:"childId"`
+	Type     string `json:"type,omitempty"`
+	Variable string `json:"variable,omitempty"`
+}
+
+// buildParsedPlan converts tree to its JSON form. Titles are set for
+// relational nodes and descriptions for scalar nodes.
+func buildParsedPlan(tree *planTree) ParsedPlan {
+	defs := variableDefinitions(tree)
+	plan := ParsedPlan{Nodes: make([]ParsedNode, len(tree.nodes)), Variables: []VariableDefinition{}}
+	for _, name := range sortedKeys(defs) {
+		plan.Variables = append(plan.Variables, defs[name])
+	}
+	for i, n := range tree.nodes {
+		node := ParsedNode{
+			ID:          n.id(),
+			Kind:        n.node.GetKind().String(),
+			DisplayName: n.node.GetDisplayName(),
+			Depth:       n.depth,
+		}
+		if n.isRelational() {
+			node.Title = n.title()
+			node.References = variableReferences(valueString(n.node.GetMetadata().GetFields()["scan_target"]), defs, n.id())
+		} else {
+			node.Description = n.node.GetShortRepresentation().GetDescription()
+			node.References = variableReferences(node.Description, defs, n.id())
+		}
+		if n.parent != nil {
+			parentID := n.parent.id()
+			node.ParentID = &parentID
+		}
+		for _, c := range n.children {
+			node.Children = append(node.Children, ParsedLink{
+				ChildID:  c.node.id(),
+				Type:     c.link.GetType(),
+				Variable: c.link.GetVariable(),
+			})
+		}
+		if m := n.node.GetMetadata(); len(m.GetFields()) > 0 {
+			node.Metadata = m.AsMap()
+		}
+		if s := n.node.GetExecutionStats(); len(s.GetFields()) > 0 {
+			node.ExecutionStats = s.AsMap()
+			for name := range s.GetFields() {
+				if v, ok := n.statValue(name); ok {
+					if node.StatValues == nil {
+						node.StatValues = make(map[string]float64)
+					}
+					node.StatValues[name] = v
+				}
+			}
+		}
+		plan.Nodes[i] = node
+	}
+	return plan
+}
+
+// parsePlan returns the plan as structured JSON for interactive views
+func parsePlan(paramsJSON string) (Response, error) {
+	par := parsePlanParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return parsePlanImpl(par)
+}
+
+func parsePlanImpl(par parsePlanParams) (Response, error) {
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, ConsoleNaming: par.ConsoleNaming, Recover: par.Recover, DecimalSeparator: par.DecimalSeparator, StatDurationUnit: par.StatDurationUnit})
+	if err != nil {
+		return Response{}, err
+	}
+	planNodes := stats.GetQueryPlan().GetPlanNodes()
+	plan := buildParsedPlan(buildPlanTree(planNodes))
+	if qs := stats.GetQueryStats(); len(qs.GetFields()) > 0 {
+		plan.QueryStats = qs.AsMap()
+	}
+	b, err := json.Marshal(plan)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal plan: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings, Metadata: planMetadata(planNodes)}, nil
+}
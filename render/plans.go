@@ -0,0 +1,100 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// WarningCodeMultiplePlans is the warning code for inputs with more than one
+// plan rendered without planIndex.
+const WarningCodeMultiplePlans = "MULTIPLE_PLANS"
+
+// lastSplit memoizes splitPlans for the latest input, as a render looks the
+// plans up both to select one and to report their number.
+var lastSplit struct {
+	mu    sync.Mutex
+	input string
+	plans []string
+}
+
+// splitPlans returns the plans of inputs that hold several result sets: a
+// JSON array of ResultSets or ResultSetStats, or an ExecuteBatchDmlResponse
+// with resultSets, as returned for batch DML. Each plan is returned as JSON
+// for the input sniffer; result sets without a query plan are skipped. It
+// returns nil for all other inputs. Unlike single plans, these inputs are
+// decoded as a whole.
+func splitPlans(input string) []string {
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasPrefix(trimmed, "[") &&
+		!(strings.HasPrefix(trimmed, "{") && (strings.Contains(input, `"resultSets"`) || strings.Contains(input, `"result_sets"`))) {
+		return nil
+	}
+
+	lastSplit.mu.Lock()
+	defer lastSplit.mu.Unlock()
+	if lastSplit.input == input {
+		return lastSplit.plans
+	}
+
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	var resultSets []any
+	switch v := v.(type) {
+	case []any:
+		resultSets = v
+	case map[string]any:
+		resultSets, _ = v["resultSets"].([]any)
+		if resultSets == nil {
+			resultSets, _ = v["result_sets"].([]any)
+		}
+	}
+	var plans []string
+	for _, rs := range resultSets {
+		m, ok := rs.(map[string]any)
+		if !ok || !isPlanObject(m) {
+			continue
+		}
+		b, err := json.Marshal(m)
+		if err != nil {
+			continue
+		}
+		plans = append(plans, string(b))
+	}
+	lastSplit.input, lastSplit.plans = input, plans
+	return plans
+}
+
+// selectPlan returns the plan at index of input, the first by default, and
+// the number of plans. Inputs with a single plan are returned as is.
+func selectPlan(input string, index *int) (string, int, error) {
+	plans := splitPlans(input)
+	if len(plans) == 0 {
+		if index != nil && *index != 0 {
+			return "", 0, InvalidParametersError{msg: fmt.Sprintf("Invalid planIndex: %d (the input has a single plan)", *index)}
+		}
+		return input, 1, nil
+	}
+	i := 0
+	if index != nil {
+		i = *index
+	}
+	if i < 0 || i >= len(plans) {
+		return "", 0, InvalidParametersError{msg: fmt.Sprintf("Invalid planIndex: %d (the input has plans 0 to %d)", i, len(plans)-1)}
+	}
+	return plans[i], len(plans), nil
+}
+
+// multiplePlansWarning is the warning for rendering the first of count plans
+// without planIndex, or nil.
+func multiplePlansWarning(count int, index *int) []Warning {
+	if count < 2 || index != nil {
+		return nil
+	}
+	return []Warning{{Code: WarningCodeMultiplePlans, Message: fmt.Sprintf("The input has %d plans; plan 0 is rendered. Set planIndex to choose another", count)}}
+}
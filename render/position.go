@@ -0,0 +1,99 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetWidth is the maximum number of characters of a line quoted by an
+// error snippet.
+const snippetWidth = 80
+
+// inputPosition locates a problem in the input text. line and column are
+// 1-based and columns count characters, as in the input editor.
+type inputPosition struct {
+	line    int
+	column  int
+	snippet string
+}
+
+// inputSyntaxError is a syntax error at a known position in the input.
+type inputSyntaxError struct {
+	msg string
+	pos inputPosition
+}
+
+func (e inputSyntaxError) Error() string {
+	return e.msg
+}
+
+// errorPosition returns the input position of err, if it has one.
+func errorPosition(err error) (inputPosition, bool) {
+	var syntaxErr inputSyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.pos, true
+	}
+	return inputPosition{}, false
+}
+
+// positionAt returns the position of line and column of input, quoting the
+// line as the snippet.
+func positionAt(input string, line, column int) inputPosition {
+	lines := strings.Split(input, "\n")
+	if line < 1 || line > len(lines) {
+		return inputPosition{line: line, column: column}
+	}
+	return inputPosition{line: line, column: column, snippet: snippetAround(strings.TrimSuffix(lines[line-1], "\r"), column)}
+}
+
+// positionAtOffset returns the position of the byte at offset of input.
+func positionAtOffset(input string, offset int64) inputPosition {
+	offset = min(max(offset, 0), int64(len(input)))
+	before := input[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+	return positionAt(input, line, column)
+}
+
+// snippetAround shortens line to snippetWidth characters around column,
+// marking cut text with an ellipsis.
+func snippetAround(line string, column int) string {
+	runes := []rune(line)
+	if len(runes) <= snippetWidth {
+		return line
+	}
+	start := min(max(column-1-snippetWidth/2, 0), len(runes)-snippetWidth)
+	snippet := string(runes[start : start+snippetWidth])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if start+snippetWidth < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// jsonSyntaxError converts a JSON decoding error of input that carries a byte
+// offset to an inputSyntaxError, or returns nil.
+func jsonSyntaxError(input string, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The offset is past the byte that was rejected
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset - 1
+	default:
+		return nil
+	}
+	pos := positionAtOffset(input, offset)
+	return inputSyntaxError{
+		msg: fmt.Sprintf("invalid JSON at line %d, column %d: %v", pos.line, pos.column, err),
+		pos: pos,
+	}
+}
@@ -0,0 +1,223 @@
+package render
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Operators of PredicateExpression
+const (
+	predicateOpAnd = "AND"
+	predicateOpOr  = "OR"
+)
+
+// predicateIndent is the indentation per nesting level of pretty-printed
+// predicates.
+const predicateIndent = "  "
+
+// predicateAppendixTitle is the title of the predicates section that
+// spannerplan appends to tables.
+const predicateAppendixTitle = "Predicates(identified by ID):"
+
+// PredicateExpression is the structure of a predicate description such as
+// "(($a > 1) AND (($b = 2) OR ($c = 3)))". Conditions without AND or OR at
+// their top level are leaves.
+type PredicateExpression struct {
+	// Op is "AND" or "OR" for a conjunction or disjunction of Operands, or
+	// empty for a condition
+	Op       string                `json:"op,omitempty"`
+	Operands []PredicateExpression `json:"operands,omitempty"`
+	// Text is the condition as in the description, e.g. "($a > 1)"
+	Text string `json:"text,omitempty"`
+}
+
+// predicateAppendixItemPattern matches the items of the predicates section:
+// the ID, or spaces for further items of the same operator, and the item.
+var predicateAppendixItemPattern = regexp.MustCompile(`^( +(?:\d+:)? )(.*)$`)
+
+// enclosedInParens reports whether s is one parenthesized expression, such as
+// "($a AND $b)" but not "($a) AND ($b)".
+func enclosedInParens(s string) bool {
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return false
+	}
+	end := -1
+	scanTopLevel(s, func(i, depth int) bool {
+		if s[i] == ')' && depth == 0 {
+			end = i
+			return false
+		}
+		return true
+	})
+	return end == len(s)-1
+}
+
+// scanTopLevel calls visit with the index and the nesting depth after each
+// byte of s outside quoted literals, until visit returns false. Brackets and
+// parentheses nest.
+func scanTopLevel(s string, visit func(i, depth int) bool) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			continue
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		}
+		if !visit(i, depth) {
+			return
+		}
+	}
+}
+
+// splitTopLevel splits s at the occurrences of the operator op outside
+// parentheses and literals. The AND of "x BETWEEN a AND b" does not split.
+func splitTopLevel(s, op string) []string {
+	sep := " " + op + " "
+	var parts []string
+	start := 0
+	between := false
+	scanTopLevel(s, func(i, depth int) bool {
+		if depth != 0 || s[i] != ' ' {
+			return true
+		}
+		rest := s[i:]
+		switch {
+		case strings.HasPrefix(rest, " BETWEEN "):
+			between = true
+		case strings.HasPrefix(rest, sep):
+			if op == predicateOpAnd && between {
+				between = false
+				return true
+			}
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + len(sep)
+		}
+		return true
+	})
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// parsePredicate returns the structure of the predicate description s.
+// Nested operations with the same operator are flattened, so "(a AND b) AND
+// c" has three operands.
+func parsePredicate(s string) PredicateExpression {
+	s = strings.TrimSpace(s)
+	inner := s
+	for enclosedInParens(inner) {
+		inner = strings.TrimSpace(inner[1 : len(inner)-1])
+	}
+	// OR binds looser than AND
+	for _, op := range []string{predicateOpOr, predicateOpAnd} {
+		parts := splitTopLevel(inner, op)
+		if len(parts) < 2 || slices.Contains(parts, "") {
+			continue
+		}
+		e := PredicateExpression{Op: op}
+		for _, part := range parts {
+			operand := parsePredicate(part)
+			if operand.Op == op {
+				e.Operands = append(e.Operands, operand.Operands...)
+			} else {
+				e.Operands = append(e.Operands, operand)
+			}
+		}
+		return e
+	}
+	return PredicateExpression{Text: s}
+}
+
+// lines returns e pretty-printed: one operand per line, each after the first
+// led by the operator, and nested operations in parentheses one level deeper.
+func (e PredicateExpression) lines() []string {
+	if e.Op == "" {
+		return []string{e.Text}
+	}
+	var lines []string
+	for i, operand := range e.Operands {
+		lead := ""
+		if i > 0 {
+			lead = e.Op + " "
+		}
+		if operand.Op == "" {
+			lines = append(lines, lead+operand.Text)
+			continue
+		}
+		lines = append(lines, lead+"(")
+		for _, line := range operand.lines() {
+			lines = append(lines, predicateIndent+line)
+		}
+		lines = append(lines, ")")
+	}
+	return lines
+}
+
+// prettyPredicateLines returns the predicate description s as lines broken
+// at its AND and OR operators, or s alone if it has none.
+func prettyPredicateLines(s string) []string {
+	return parsePredicate(s).lines()
+}
+
+// hangPredicate returns the item "<label><description>" with the description
+// pretty-printed, continuation lines aligned under its first line after
+// indent.
+func hangPredicate(indent, label, description string) string {
+	lines := prettyPredicateLines(description)
+	pad := strings.Repeat(" ", len(indent)+len(label))
+	var b strings.Builder
+	for i, line := range lines {
+		if i == 0 {
+			b.WriteString(indent + label + line)
+		} else {
+			b.WriteString("\n" + pad + line)
+		}
+	}
+	return b.String()
+}
+
+// prettyPrintPredicateAppendix pretty-prints the descriptions of the
+// predicates section of a rendered table, e.g.
+//
+//	Predicates(identified by ID):
+//	 3: Residual Condition: ($a > 1)
+//	                        AND (
+//	                          ($b = 2)
+//	                          OR ($c = 3)
+//	                        )
+func prettyPrintPredicateAppendix(rendered string) string {
+	lines := strings.Split(rendered, "\n")
+	inAppendix := false
+	for i, line := range lines {
+		switch {
+		case line == predicateAppendixTitle:
+			inAppendix = true
+			continue
+		case !inAppendix:
+			continue
+		}
+		m := predicateAppendixItemPattern.FindStringSubmatch(line)
+		if m == nil {
+			inAppendix = false
+			continue
+		}
+		typ, description, ok := strings.Cut(m[2], ": ")
+		if !ok {
+			continue
+		}
+		lines[i] = hangPredicate(m[1], typ+": ", description)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,142 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+)
+
+// renderPresets holds named renderASCII option bundles for the lifetime of
+// the WASM instance. Go does not persist them; savePreset returns every preset
+// so that the frontend can store them and save them again on the next load.
+type renderPresets struct {
+	mu      sync.Mutex
+	presets map[string]json.RawMessage
+}
+
+var presets = &renderPresets{presets: make(map[string]json.RawMessage)}
+
+type savePresetParams struct {
+	Name string `json:"name"`
+	// Options are renderASCII parameters without input; null deletes the preset
+	Options json.RawMessage `json:"options"`
+}
+
+type applyPresetParams struct {
+	Name string `json:"name"`
+}
+
+// checkRenderOptions rejects options that are not renderASCII parameters
+// without input, so that typos surface when storing options instead of being
+// ignored when rendering. kind names the options in errors, e.g. "Preset".
+func checkRenderOptions(kind string, options json.RawMessage) error {
+	dec := json.NewDecoder(bytes.NewReader(options))
+	dec.DisallowUnknownFields()
+	var par params
+	if err := dec.Decode(&par); err != nil {
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid %s options: %v", strings.ToLower(kind), err)}
+	}
+	if par.Input != "" {
+		return InvalidParametersError{msg: kind + " options must not contain input"}
+	}
+	return nil
+}
+
+// compactOptions validates options with checkRenderOptions and returns them
+// without insignificant whitespace.
+func compactOptions(kind string, options json.RawMessage) (json.RawMessage, error) {
+	if err := checkRenderOptions(kind, options); err != nil {
+		return nil, err
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, options); err != nil {
+		return nil, InvalidParametersError{msg: fmt.Sprintf("Invalid %s options: %v", strings.ToLower(kind), err)}
+	}
+	return compact.Bytes(), nil
+}
+
+// save stores or, for null options, deletes a preset and returns all presets.
+func (p *renderPresets) save(name string, options json.RawMessage) (map[string]json.RawMessage, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, InvalidParametersError{msg: "Preset name must be a non-empty string"}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(options) == 0 || string(options) == "null" {
+		delete(p.presets, name)
+		return maps.Clone(p.presets), nil
+	}
+	compact, err := compactOptions("Preset", options)
+	if err != nil {
+		return nil, err
+	}
+	p.presets[name] = compact
+	return maps.Clone(p.presets), nil
+}
+
+func (p *renderPresets) lookup(name string) (json.RawMessage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	options, ok := p.presets[name]
+	if !ok {
+		return nil, InvalidParametersError{msg: fmt.Sprintf("Unknown preset: %q", name)}
+	}
+	return options, nil
+}
+
+// all returns a copy of every preset.
+func (p *renderPresets) all() map[string]json.RawMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return maps.Clone(p.presets)
+}
+
+// replace discards every preset and stores all instead.
+func (p *renderPresets) replace(all map[string]json.RawMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.presets = make(map[string]json.RawMessage, len(all))
+	maps.Copy(p.presets, all)
+}
+
+// merge stores all, replacing the presets of the same names.
+func (p *renderPresets) merge(all map[string]json.RawMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	maps.Copy(p.presets, all)
+}
+
+// savePreset stores a named renderASCII option bundle and returns every
+// preset as a JSON object for persistence
+func savePreset(paramsJSON string) (Response, error) {
+	par := savePresetParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	all, err := presets.save(par.Name, par.Options)
+	if err != nil {
+		return Response{}, err
+	}
+	b, err := json.Marshal(all)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal presets: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
+
+// applyPreset returns the options of a named preset as a JSON object, for the
+// caller to merge into renderASCII parameters
+func applyPreset(paramsJSON string) (Response, error) {
+	par := applyPresetParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	options, err := presets.lookup(par.Name)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Result: string(options)}, nil
+}
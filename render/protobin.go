@@ -0,0 +1,103 @@
+package render
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// inputEncodingProtoBase64 is the inputEncoding of base64-encoded binary
+// protobuf plans, as exported programmatically from the client libraries.
+const inputEncodingProtoBase64 = "proto-base64"
+
+// protoBase64Pattern matches input made only of standard or URL-safe base64
+// characters once line breaks are removed.
+var protoBase64Pattern = regexp.MustCompile(`^[A-Za-z0-9+/_-]+={0,2}$`)
+
+// checkInputEncoding validates the inputEncoding parameter; "" detects the
+// encoding.
+func checkInputEncoding(encoding string) error {
+	switch encoding {
+	case "", inputEncodingProtoBase64:
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid input encoding: %q (expected %q or none)", encoding, inputEncodingProtoBase64)}
+}
+
+// looksLikeProtoBase64 reports whether input may be base64. Plain words also
+// match, so callers fall back to the text decoders when decoding fails.
+func looksLikeProtoBase64(input string) bool {
+	return protoBase64Pattern.MatchString(joinLines(input))
+}
+
+// joinLines removes the line breaks of wrapped base64.
+func joinLines(input string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(strings.TrimSpace(input))
+}
+
+// decodeBase64 decodes standard or URL-safe base64, padded or not, ignoring
+// line breaks.
+func decodeBase64(input string) ([]byte, error) {
+	s := joinLines(input)
+	var firstErr error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		b, err := enc.DecodeString(s)
+		if err == nil {
+			return b, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// plausiblePlanNodes reports whether planNodes were decoded from a real plan.
+// Binary protobuf has no type information, so bytes of another message often
+// decode without error; their node indexes rarely match positions.
+func plausiblePlanNodes(planNodes []*sppb.PlanNode) bool {
+	if len(planNodes) == 0 {
+		return false
+	}
+	for i, node := range planNodes {
+		if node.GetIndex() != int32(i) || node.GetKind() == sppb.PlanNode_KIND_UNSPECIFIED {
+			return false
+		}
+	}
+	return true
+}
+
+// extractQueryPlanProtoBase64 decodes a base64-encoded binary ResultSetStats,
+// ResultSet, or QueryPlan, tried in that order as ResultSetStats is what
+// client libraries usually return for plans. The row type is only available
+// from a ResultSet.
+func extractQueryPlanProtoBase64(input string) (*sppb.ResultSetStats, *sppb.StructType, error) {
+	b, err := decodeBase64(input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	return extractQueryPlanProtoBinary(b)
+}
+
+// extractQueryPlanProtoBinary decodes a binary ResultSetStats, ResultSet, or
+// QueryPlan, as extractQueryPlanProtoBase64.
+func extractQueryPlanProtoBinary(b []byte) (*sppb.ResultSetStats, *sppb.StructType, error) {
+	var stats sppb.ResultSetStats
+	if proto.Unmarshal(b, &stats) == nil && plausiblePlanNodes(stats.GetQueryPlan().GetPlanNodes()) {
+		return &stats, nil, nil
+	}
+	var resultSet sppb.ResultSet
+	if proto.Unmarshal(b, &resultSet) == nil && plausiblePlanNodes(resultSet.GetStats().GetQueryPlan().GetPlanNodes()) {
+		return resultSet.GetStats(), resultSet.GetMetadata().GetRowType(), nil
+	}
+	var plan sppb.QueryPlan
+	if proto.Unmarshal(b, &plan) == nil && plausiblePlanNodes(plan.GetPlanNodes()) {
+		return &sppb.ResultSetStats{QueryPlan: &plan}, nil, nil
+	}
+	return nil, nil, errors.New("input is not a binary ResultSetStats, ResultSet, or QueryPlan")
+}
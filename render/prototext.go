@@ -0,0 +1,105 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// prototextPlanFields are the snake_case message fields that open a plan in
+// protobuf text format, as printed by client-library debug logs: either
+// multi-line ("query_plan {") or the compact String() form ("plan_nodes:{").
+// YAML and JSON captures use camelCase or quoted keys and rarely match.
+var prototextPlanFields = []string{"query_plan", "plan_nodes"}
+
+// prototextSpace is the whitespace between prototext tokens, as \s of regexp.
+const prototextSpace = " \t\n\f\r"
+
+// looksLikePrototext reports whether input should be decoded as prototext:
+// whether a field of prototextPlanFields, at the start of the input or after
+// whitespace or "{", is followed by an optional ":" and "{". The fields are
+// searched as literals, as every JSON and YAML input is sniffed here first
+// and a regexp over large plans takes longer than decoding them.
+func looksLikePrototext(input string) bool {
+	for _, field := range prototextPlanFields {
+		for offset := 0; ; {
+			i := strings.Index(input[offset:], field)
+			if i < 0 {
+				break
+			}
+			start, end := offset+i, offset+i+len(field)
+			offset = end
+			if start > 0 && !strings.ContainsRune(prototextSpace+"{", rune(input[start-1])) {
+				continue
+			}
+			rest := strings.TrimLeft(input[end:], prototextSpace)
+			rest = strings.TrimLeft(strings.TrimPrefix(rest, ":"), prototextSpace)
+			if strings.HasPrefix(rest, "{") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// extractQueryPlanPrototext decodes a ResultSet, ResultSetStats, or QueryPlan
+// in protobuf text format. The row type is only available from a ResultSet.
+func extractQueryPlanPrototext(input string) (*sppb.ResultSetStats, *sppb.StructType, error) {
+	var resultSet sppb.ResultSet
+	errResultSet := prototext.Unmarshal([]byte(input), &resultSet)
+	if errResultSet == nil && resultSet.GetStats().GetQueryPlan() != nil {
+		return resultSet.GetStats(), resultSet.GetMetadata().GetRowType(), nil
+	}
+
+	var stats sppb.ResultSetStats
+	errStats := prototext.Unmarshal([]byte(input), &stats)
+	if errStats == nil && stats.GetQueryPlan() != nil {
+		return &stats, nil, nil
+	}
+
+	var plan sppb.QueryPlan
+	errPlan := prototext.Unmarshal([]byte(input), &plan)
+	if errPlan == nil && len(plan.GetPlanNodes()) > 0 {
+		return &sppb.ResultSetStats{QueryPlan: &plan}, nil, nil
+	}
+
+	// Pasted plan_nodes are the common case, so prefer the QueryPlan error
+	for _, err := range []error{errPlan, errStats, errResultSet} {
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return nil, nil, errors.New("prototext input does not contain a query plan")
+}
+
+type prototextParams struct {
+	Input   string `json:"input"`
+	Recover bool   `json:"recover,omitempty"`
+}
+
+// renderPrototextImpl re-emits the parsed plan in protobuf text format, the
+// format expected by proto-based tooling and Spanner support. The output is a
+// ResultSet when the input carried a row type and a ResultSetStats otherwise.
+func renderPrototextImpl(par prototextParams) (Response, error) {
+	stats, rowType, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+
+	var msg proto.Message = stats
+	if rowType != nil {
+		msg = &sppb.ResultSet{
+			Metadata: &sppb.ResultSetMetadata{RowType: rowType},
+			Stats:    stats,
+		}
+	}
+	b, err := prototext.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(msg)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal prototext: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
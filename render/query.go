@@ -0,0 +1,159 @@
+package render
+
+import (
+	"strconv"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Warning codes for the query header
+const (
+	WarningCodeNoQueryText       = "NO_QUERY_TEXT"
+	WarningCodeNoQueryParameters = "NO_QUERY_PARAMETERS"
+	WarningCodeUnboundParameter  = "UNBOUND_QUERY_PARAMETER"
+)
+
+// queryParametersKey is the query stats field that tools capturing plans use
+// to record parameter values alongside query_text.
+const queryParametersKey = "query_parameters"
+
+// queryParameters returns the parameter values recorded in the capture,
+// either as a struct or as a JSON object string, overridden by explicit.
+func queryParameters(stats *sppb.ResultSetStats, explicit map[string]any) (map[string]*structpb.Value, error) {
+	params := make(map[string]*structpb.Value)
+	switch v := stats.GetQueryStats().GetFields()[queryParametersKey]; v.GetKind().(type) {
+	case *structpb.Value_StructValue:
+		for name, value := range v.GetStructValue().GetFields() {
+			params[name] = value
+		}
+	case *structpb.Value_StringValue:
+		var s structpb.Struct
+		if err := s.UnmarshalJSON([]byte(v.GetStringValue())); err == nil {
+			for name, value := range s.GetFields() {
+				params[name] = value
+			}
+		}
+	}
+	for name, raw := range explicit {
+		value, err := structpb.NewValue(raw)
+		if err != nil {
+			return nil, InvalidParametersError{msg: "Invalid queryParameters." + name + ": " + err.Error()}
+		}
+		params[name] = value
+	}
+	return params, nil
+}
+
+// sqlLiteral formats a parameter value as a GoogleSQL literal.
+func sqlLiteral(v *structpb.Value) string {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return strconv.Quote(k.StringValue)
+	case *structpb.Value_BoolValue:
+		return strings.ToUpper(strconv.FormatBool(k.BoolValue))
+	case *structpb.Value_ListValue:
+		elems := make([]string, len(k.ListValue.GetValues()))
+		for i, e := range k.ListValue.GetValues() {
+			elems[i] = sqlLiteral(e)
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case nil, *structpb.Value_NullValue:
+		return "NULL"
+	default:
+		return valueString(v)
+	}
+}
+
+// substituteParameters replaces @name references outside string literals,
+// quoted identifiers, and comments with the parameter values. Each value is
+// followed by a /*@name*/ marker so the substitution stays visible. Names
+// without a value are returned as unbound.
+func substituteParameters(query string, params map[string]*structpb.Value) (string, []string) {
+	var b strings.Builder
+	var unbound []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(query) && query[end] != c {
+				if query[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(query))
+			b.WriteString(query[i:end])
+			i = end
+		case c == '-' && strings.HasPrefix(query[i:], "--"), c == '#':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '@' && i+1 < len(query) && isIdentStart(query[i+1]):
+			end := i + 1
+			for end < len(query) && isIdentPart(query[end]) {
+				end++
+			}
+			name := query[i+1 : end]
+			if v, ok := params[name]; ok {
+				b.WriteString(sqlLiteral(v) + "/*@" + name + "*/")
+			} else {
+				b.WriteString(query[i:end])
+				unbound = append(unbound, name)
+			}
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), unbound
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || '0' <= c && c <= '9'
+}
+
+// queryHeader returns the "Query:" header for the rendered output, with
+// parameters substituted when requested, and warnings for missing data.
+func queryHeader(stats *sppb.ResultSetStats, substitute bool, explicit map[string]any) (string, []Warning, error) {
+	text := valueString(stats.GetQueryStats().GetFields()["query_text"])
+	if text == "" {
+		return "", []Warning{{Code: WarningCodeNoQueryText, Message: "The input has no query text; the query header is omitted"}}, nil
+	}
+	if !substitute {
+		return "Query:\n" + text + "\n\n", nil, nil
+	}
+
+	params, err := queryParameters(stats, explicit)
+	if err != nil {
+		return "", nil, err
+	}
+	var warnings []Warning
+	if len(params) == 0 {
+		warnings = append(warnings, Warning{Code: WarningCodeNoQueryParameters, Message: "The input has no query parameter values to substitute"})
+	}
+	text, unbound := substituteParameters(text, params)
+	for _, name := range unbound {
+		warnings = append(warnings, Warning{Code: WarningCodeUnboundParameter, Message: "No value for query parameter @" + name})
+	}
+	return "Query (parameters substituted):\n" + text + "\n\n", warnings, nil
+}
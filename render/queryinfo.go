@@ -0,0 +1,130 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// QueryInfo is returned by getQueryInfo
+type QueryInfo struct {
+	// Text is the query text, if the input has one
+	Text       string           `json:"text,omitempty"`
+	Parameters []QueryParameter `json:"parameters"`
+	// OptimizerVersion and OptimizerStatisticsPackage are from the query
+	// stats of PROFILE captures
+	OptimizerVersion           string `json:"optimizerVersion,omitempty"`
+	OptimizerStatisticsPackage string `json:"optimizerStatisticsPackage,omitempty"`
+}
+
+// QueryParameter is a parameter referenced by the query text or recorded in
+// the query stats.
+type QueryParameter struct {
+	Name string `json:"name"`
+	// Type is inferred from the recorded value, e.g. "INT64" or "ARRAY"; it is
+	// empty without a value or for NULL
+	Type string `json:"type,omitempty"`
+	// Value is the recorded value, if any
+	Value any `json:"value,omitempty"`
+	// Referenced is set when the query text uses the parameter
+	Referenced bool `json:"referenced,omitempty"`
+}
+
+type queryInfoParams struct {
+	Input   string `json:"input"`
+	Recover bool   `json:"recover,omitempty"`
+}
+
+// parameterType infers the GoogleSQL type of a recorded parameter value.
+// Whole numbers are taken as INT64.
+func parameterType(v *structpb.Value) string {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return "STRING"
+	case *structpb.Value_NumberValue:
+		if k.NumberValue == math.Trunc(k.NumberValue) {
+			return "INT64"
+		}
+		return "FLOAT64"
+	case *structpb.Value_BoolValue:
+		return "BOOL"
+	case *structpb.Value_ListValue:
+		return "ARRAY"
+	case *structpb.Value_StructValue:
+		return "STRUCT"
+	}
+	return ""
+}
+
+// buildQueryInfo lists the parameters referenced by the query text in order
+// of first use, followed by the other recorded parameters in name order.
+func buildQueryInfo(stats *sppb.ResultSetStats) (QueryInfo, error) {
+	fields := stats.GetQueryStats().GetFields()
+	info := QueryInfo{
+		Text:                       valueString(fields["query_text"]),
+		Parameters:                 []QueryParameter{},
+		OptimizerVersion:           valueString(fields["optimizer_version"]),
+		OptimizerStatisticsPackage: valueString(fields["optimizer_statistics_package"]),
+	}
+	values, err := queryParameters(stats, nil)
+	if err != nil {
+		return QueryInfo{}, err
+	}
+
+	// Substituting no values leaves every reference unbound
+	_, referenced := substituteParameters(info.Text, nil)
+	var names []string
+	for _, name := range referenced {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range sortedKeys(values) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		p := QueryParameter{Name: name, Referenced: slices.Contains(referenced, name)}
+		if v, ok := values[name]; ok {
+			p.Type = parameterType(v)
+			p.Value = v.AsInterface()
+		}
+		info.Parameters = append(info.Parameters, p)
+	}
+	return info, nil
+}
+
+// getQueryInfo returns the query text, parameters, and optimizer settings
+// recorded with the plan as JSON
+func getQueryInfo(paramsJSON string) (Response, error) {
+	par := queryInfoParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return getQueryInfoImpl(par)
+}
+
+func getQueryInfoImpl(par queryInfoParams) (Response, error) {
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	info, err := buildQueryInfo(stats)
+	if err != nil {
+		return Response{}, err
+	}
+	if info.Text == "" {
+		warnings = append(warnings, Warning{Code: WarningCodeNoQueryText, Message: "The input has no query text"})
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal query info: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
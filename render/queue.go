@@ -0,0 +1,107 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// maxQueuedJobs is the number of jobs a JobQueue holds waiting; the running
+// job is not counted.
+const maxQueuedJobs = 32
+
+// QueueFullError represents calls rejected because maxQueuedJobs jobs are
+// waiting already
+type QueueFullError struct {
+	msg string
+}
+
+func (e QueueFullError) Error() string {
+	return e.msg
+}
+
+// BusyError represents calls rejected by JobQueue.Run because a job is
+// running, which the call would change the state of
+type BusyError struct {
+	msg string
+}
+
+func (e BusyError) Error() string {
+	return e.msg
+}
+
+// JobQueue runs jobs one at a time, in submission order, on a worker
+// goroutine. Asynchronous exports yield to the event loop part way through,
+// so without the queue overlapping calls would interleave their use of the
+// caches and registries of the package.
+type JobQueue struct {
+	mu      sync.Mutex
+	nextID  int
+	jobs    chan queuedJob
+	started bool
+	// running is held while a job runs
+	running sync.Mutex
+}
+
+type queuedJob struct {
+	id  string
+	run func()
+}
+
+// NewJobQueue returns an empty queue. Its worker starts with the first job.
+func NewJobQueue() *JobQueue {
+	return &JobQueue{nextID: 1, jobs: make(chan queuedJob, maxQueuedJobs)}
+}
+
+// Submit queues run and returns the ID of its job, e.g. "job-1", or a
+// QueueFullError without queueing it. run should recover its own panics; a
+// panic that reaches the worker is logged and drops the job.
+func (q *JobQueue) Submit(run func()) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.started {
+		q.started = true
+		go q.work()
+	}
+	id := "job-" + strconv.Itoa(q.nextID)
+	select {
+	case q.jobs <- queuedJob{id: id, run: run}:
+		q.nextID++
+		logger.Debug("job queued", "job", id, "waiting", len(q.jobs))
+		return id, nil
+	default:
+		return "", QueueFullError{msg: fmt.Sprintf("Too many calls in progress: %d are waiting; retry after some of them finish", maxQueuedJobs)}
+	}
+}
+
+// Run runs run on the calling goroutine as a job, ahead of the waiting jobs,
+// or returns a BusyError without running it while another job runs. It is
+// for callers that cannot wait, such as synchronous exports on the JS
+// thread, which a job waiting for the event loop would deadlock.
+func (q *JobQueue) Run(run func()) error {
+	if !q.running.TryLock() {
+		return BusyError{msg: "Another call is in progress and this call would change the state it uses; retry after it finishes, or use the Promise-returning variant, which waits for it"}
+	}
+	defer q.running.Unlock()
+	run()
+	return nil
+}
+
+func (q *JobQueue) work() {
+	for job := range q.jobs {
+		q.runJob(job)
+	}
+}
+
+func (q *JobQueue) runJob(job queuedJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("job panicked", "job", job.id, "panic", fmt.Sprint(r))
+		}
+	}()
+	q.running.Lock()
+	defer q.running.Unlock()
+	logger.Debug("job started", "job", job.id)
+	job.run()
+	logger.Debug("job finished", "job", job.id)
+}
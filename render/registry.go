@@ -0,0 +1,68 @@
+package render
+
+import "slices"
+
+// Optional subsystems register their exports here from an init function in a
+// file guarded by a build tag, so that builds which exclude a feature also
+// drop its dependencies from the binary:
+//
+//	nodiagram   renderMermaid, renderDOT, renderD2 (spannerplanviz)
+//	nonarrative explainPlan
+//	nolint      lintPlan, registerLintRule, suggestWhatIf, the lint option
+//	noanonymize anonymizePlan
+//
+// For example, an ASCII-only build:
+//
+//	GOOS=js GOARCH=wasm go build -tags nodiagram,nonarrative,nolint,noanonymize ./
+
+// Export is a function of the rendering API. The WASM build sets every export
+// on globalThis under its name.
+type Export struct {
+	// Run implements the export on its JSON parameters
+	Run func(paramsJSON string) (Response, error)
+	// NoParams is set for exports that are called without parameters; Run
+	// ignores its argument
+	NoParams bool
+	// Mutates is set for exports that change state other calls use, such as
+	// the session plans, presets, and caches; the WASM build does not run
+	// them synchronously while another call runs
+	Mutates bool
+}
+
+// Feature is a set of exports compiled in or out together
+type Feature struct {
+	Name    string
+	Exports map[string]Export
+}
+
+// registeredFeatures lists the features compiled into this binary in
+// registration order.
+var registeredFeatures []Feature
+
+func registerFeature(name string, exports map[string]Export) {
+	registeredFeatures = append(registeredFeatures, Feature{Name: name, Exports: exports})
+}
+
+// Features returns the features compiled into this binary in registration
+// order.
+func Features() []Feature {
+	return slices.Clone(registeredFeatures)
+}
+
+// Lookup returns the export registered under name, if any.
+func Lookup(name string) (Export, bool) {
+	for _, f := range registeredFeatures {
+		if e, ok := f.Exports[name]; ok {
+			return e, true
+		}
+	}
+	return Export{}, false
+}
+
+// lintSummary renders the lint findings for the lint option of renderASCII.
+// It is set by the lint feature and nil in builds without it.
+var lintSummary func(tree *planTree, t Thresholds) (string, error)
+
+// clearLintRules unregisters the rules registered with RegisterLintRule. It is
+// set by the lint feature and nil in builds without it.
+var clearLintRules func()
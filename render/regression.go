@@ -0,0 +1,213 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// WarningCodeBaselineQueryMismatch is reported by checkPlanRegression when no
+// baseline has the query fingerprint of the input, so that the plan was
+// compared with baselines of other queries.
+const WarningCodeBaselineQueryMismatch = "BASELINE_QUERY_MISMATCH"
+
+type regressionParams struct {
+	Input     string         `json:"input"`
+	Baselines []PlanBaseline `json:"baselines"`
+	// IgnoreTargets matches plans by shape alone, so that scans of other
+	// tables or indexes are not a regression
+	IgnoreTargets bool `json:"ignoreTargets,omitempty"`
+	Recover       bool `json:"recover,omitempty"`
+}
+
+// PlanBaseline is a known-good plan given to checkPlanRegression: a
+// PlanFingerprint, e.g. exported from CI, with an optional label.
+type PlanBaseline struct {
+	PlanFingerprint
+	Label string `json:"label,omitempty"`
+}
+
+// PlanRegression is returned by checkPlanRegression.
+type PlanRegression struct {
+	// Matches is whether the plan matches a baseline
+	Matches bool `json:"matches"`
+	// MatchedBaseline is the index of the first baseline the plan matches
+	MatchedBaseline *int `json:"matchedBaseline,omitempty"`
+	// TargetsChanged is set when the plan has the shape of a baseline but
+	// scans other tables or indexes
+	TargetsChanged bool `json:"targetsChanged,omitempty"`
+	// Fingerprint is the fingerprint of the input, to store as a new baseline
+	Fingerprint PlanFingerprint `json:"fingerprint"`
+	// Diff compares the plan with the closest baseline that has a shape,
+	// when the plan matches none
+	Diff *ShapeDiff `json:"diff,omitempty"`
+}
+
+// ShapeDiff is the structural difference between a baseline and the plan.
+type ShapeDiff struct {
+	// Baseline is the index of the baseline compared with
+	Baseline int    `json:"baseline"`
+	Label    string `json:"label,omitempty"`
+	Added    int    `json:"added"`
+	Removed  int    `json:"removed"`
+	// Lines are the shape lines of both, marked "+ " for operators only in
+	// the plan, "- " for operators only in the baseline, and "  " for both
+	Lines []string `json:"lines"`
+	// Summary describes the difference in a sentence
+	Summary string `json:"summary"`
+}
+
+func (par *regressionParams) check() error {
+	if len(par.Baselines) == 0 {
+		return InvalidParametersError{msg: "baselines must have at least one fingerprint"}
+	}
+	for i, b := range par.Baselines {
+		if b.Plan == "" {
+			return InvalidParametersError{msg: fmt.Sprintf("baselines[%d].plan is required", i)}
+		}
+	}
+	return nil
+}
+
+// matches reports whether fp matches the baseline b. The scan targets are
+// compared when b has them, unless ignoreTargets.
+func (b PlanBaseline) matches(fp PlanFingerprint, ignoreTargets bool) bool {
+	if b.Plan != fp.Plan {
+		return false
+	}
+	return ignoreTargets || b.PlanWithTargets == "" || b.PlanWithTargets == fp.PlanWithTargets
+}
+
+// diffShapes aligns the shape lines of a baseline and the plan by their
+// longest common subsequence.
+func diffShapes(before, after []string) (lines []string, added, removed int) {
+	// lcs[i][j] is the LCS length of before[i:] and after[j:]
+	lcs := make([][]int, len(before)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			lines = append(lines, fmt.Sprintf("%c %s", diffUnchanged, before[i]))
+			i++
+			j++
+		case j == len(after) || (i < len(before) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, fmt.Sprintf("%c %s", diffRemoved, before[i]))
+			removed++
+			i++
+		default:
+			lines = append(lines, fmt.Sprintf("%c %s", diffAdded, after[j]))
+			added++
+			j++
+		}
+	}
+	return lines, added, removed
+}
+
+// closestShapeDiff diffs the plan shape with each baseline in candidates that
+// has a shape and returns the smallest diff, or nil when none has a shape.
+func closestShapeDiff(shape []string, baselines []PlanBaseline, candidates []int) *ShapeDiff {
+	var closest *ShapeDiff
+	for _, i := range candidates {
+		b := baselines[i]
+		if len(b.Shape) == 0 {
+			continue
+		}
+		lines, added, removed := diffShapes(b.Shape, shape)
+		if closest != nil && added+removed >= closest.Added+closest.Removed {
+			continue
+		}
+		closest = &ShapeDiff{Baseline: i, Label: b.Label, Added: added, Removed: removed, Lines: lines}
+	}
+	if closest != nil {
+		name := fmt.Sprintf("baseline %d", closest.Baseline)
+		if closest.Label != "" {
+			name = fmt.Sprintf("baseline %q", closest.Label)
+		}
+		if closest.Added+closest.Removed == 0 {
+			closest.Summary = fmt.Sprintf("The shape is that of %s, but its fingerprint differs; the baseline may be from another version", name)
+		} else {
+			closest.Summary = fmt.Sprintf("%s added and %s removed since %s", countOperators(closest.Added), countOperators(closest.Removed), name)
+		}
+	}
+	return closest
+}
+
+func countOperators(n int) string {
+	if n == 1 {
+		return "1 operator"
+	}
+	return fmt.Sprintf("%d operators", n)
+}
+
+// checkPlanRegression fingerprints the input and compares it with known-good
+// baseline fingerprints, returning whether the plan matches one and, if not,
+// how its shape differs from the closest
+func checkPlanRegression(paramsJSON string) (Response, error) {
+	par := regressionParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return checkPlanRegressionImpl(par)
+}
+
+func checkPlanRegressionImpl(par regressionParams) (Response, error) {
+	if err := par.check(); err != nil {
+		return Response{}, err
+	}
+	stats, _, loadWarnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	var warn warningCollector
+	warn.addAll(loadWarnings)
+	fp := planFingerprint(stats)
+	result := PlanRegression{Fingerprint: fp}
+
+	// Baselines of other queries are only compared with when there is no
+	// baseline of this one
+	var candidates []int
+	for i, b := range par.Baselines {
+		if b.Query == "" || fp.Query == "" || b.Query == fp.Query {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		warn.add(WarningCodeBaselineQueryMismatch, "No baseline is of the query of the input; the plan is compared with the baselines of other queries")
+		for i := range par.Baselines {
+			candidates = append(candidates, i)
+		}
+	}
+
+	for _, i := range candidates {
+		b := par.Baselines[i]
+		if b.matches(fp, par.IgnoreTargets) {
+			result.Matches = true
+			result.MatchedBaseline = &i
+			break
+		}
+		if b.Plan == fp.Plan {
+			result.TargetsChanged = true
+		}
+	}
+	if !result.Matches {
+		result.Diff = closestShapeDiff(fp.Shape, par.Baselines, candidates)
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal regression check: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warn.list()}, nil
+}
@@ -0,0 +1,1339 @@
+// Package render renders Spanner query plans as the tables, diagrams, and
+// reports of rendertree-web. The WASM build in the repository root is a thin
+// frontend over its exports.
+package render
+
+//go:generate go run ../internal/gendts -o ../src/types/generated.d.ts
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"time"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan/plantree/reference"
+)
+
+// Options are the options of Render, the renderASCII parameters besides the
+// input.
+type Options struct {
+	// InputEncoding "proto-base64" reads the input as a base64-encoded binary
+	// ResultSetStats, ResultSet, or QueryPlan, which is otherwise detected
+	InputEncoding string `json:"inputEncoding,omitempty"`
+	// Mode is AUTO, PLAN, PROFILE, or TIMELINE
+	Mode string `json:"mode"`
+	// Format is a built-in format or a name registered with registerFormatter
+	Format string `json:"format"`
+	// WrapWidth is the width the Operator column is wrapped at; 0 does not
+	// wrap
+	WrapWidth int `json:"wrapWidth"`
+	// HangingIndent aligns wrapped lines after node-local prefixes such as
+	// [Input] or [Map]
+	HangingIndent bool `json:"hangingIndent"`
+	// WrapMode wraps mid-token ("char", the default), at spaces ("word"), or
+	// at spaces and after commas and parentheses ("smart")
+	WrapMode string `json:"wrapMode,omitempty"`
+	// TargetWidth wraps table formats at the widest WrapWidth whose table
+	// fits this width
+	TargetWidth int `json:"targetWidth,omitempty"`
+	// PrintSections are the appendix sections in order; nil prints the
+	// predicates and an empty list none
+	PrintSections *reference.PrintSections `json:"printSections,omitempty"`
+	// ShowScalarVars shows the scalar assignment variable names in the
+	// semantic appendix sections
+	ShowScalarVars bool `json:"showScalarVars,omitempty"`
+	// ResolveScalarVars resolves direct scalar variable aliases in the
+	// semantic appendix sections
+	ResolveScalarVars bool `json:"resolveScalarVars,omitempty"`
+	// ResolveScalarVarsRecursive resolves scalar variable aliases recursively
+	// in the semantic appendix sections
+	ResolveScalarVarsRecursive bool `json:"resolveScalarVarsRecursive,omitempty"`
+	// ConsoleNaming uses the Cloud Console query plan visualizer names for
+	// operators and metadata labels
+	ConsoleNaming bool `json:"consoleNaming,omitempty"`
+	// PrettyMetadataKeys shows the metadata keys of table formats as readable
+	// labels with the unit of their values where known
+	PrettyMetadataKeys bool `json:"prettyMetadataKeys,omitempty"`
+	// Recover renders invalid plan nodes as placeholders and reports them as
+	// warnings instead of failing
+	Recover bool `json:"recover,omitempty"`
+	// ScalarRepresentation shows scalar expressions "short" (the default),
+	// "full" with variable references expanded, or "footnote"
+	ScalarRepresentation string `json:"scalarRepresentation,omitempty"`
+	// ShowQueryText prepends the query text of the query stats, if present
+	ShowQueryText bool `json:"showQueryText,omitempty"`
+	// ShowOptimizerInfo prepends the optimizer version and statistics
+	// package of the query stats
+	ShowOptimizerInfo bool `json:"showOptimizerInfo,omitempty"`
+	// SubstituteParameters prepends the query text with its @parameters
+	// replaced by their values
+	SubstituteParameters bool `json:"substituteParameters,omitempty"`
+	// QueryParameters are the values of SubstituteParameters keyed by name
+	// without the @, overriding the query_parameters stat
+	QueryParameters map[string]any `json:"queryParameters,omitempty"`
+	// Annotations are comments rendered under the rows of the nodes they
+	// are keyed by
+	Annotations map[int32]string `json:"annotations,omitempty"`
+	// SortBy orders the rows of the row model of registered formatters by
+	// "latency", "rows", or "id"; tree pre-order by default
+	SortBy string `json:"sortBy,omitempty"`
+	// OperatorFilter keeps only the operators of one category, e.g.
+	// "scans-only"
+	OperatorFilter string `json:"operatorFilter,omitempty"`
+	// Filter keeps only the operators matching every condition of the
+	// expression, and their ancestors
+	Filter string `json:"filter,omitempty"`
+	// LatencyBudget is a target latency such as "50ms", split evenly across
+	// the relational operators to annotate those over their share
+	LatencyBudget string `json:"latencyBudget,omitempty"`
+	// EstimateColumn adds an Est/Actual column of the estimated and actual
+	// rows of operators with both
+	EstimateColumn bool `json:"estimateColumn,omitempty"`
+	// LatencyBars adds a Latency Share column of each operator's latency
+	// relative to the slowest operator
+	LatencyBars bool `json:"latencyBars,omitempty"`
+	// Thresholds tune when built-in warnings are reported
+	Thresholds Thresholds `json:"thresholds,omitempty"`
+	// Columns are the columns of table formats to show, in order
+	Columns []string `json:"columns,omitempty"`
+	// TemplateColumns are columns computed for each operator by text/template
+	// expressions over its PlanRow
+	TemplateColumns []TemplateColumn `json:"templateColumns,omitempty"`
+	// ColumnGroups are super-headers spanning adjacent columns of table
+	// formats
+	ColumnGroups []ColumnGroup `json:"columnGroups,omitempty"`
+	// ColumnConfig is the layout of table columns by name or alias
+	ColumnConfig map[string]ColumnConfig `json:"columnConfig,omitempty"`
+	// NumberFormat formats the Rows, Exec., Total Latency, and CPU columns
+	// of table formats
+	NumberFormat NumberFormat `json:"numberFormat,omitempty"`
+	// SortChildrenBy orders the relational children of each operator by the
+	// "latency", "rows", or "cpu" of their subtrees; "none" keeps the plan
+	// order
+	SortChildrenBy string `json:"sortChildrenBy,omitempty"`
+	// ChildLinks draws "all" child links, hides scalar subqueries
+	// ("hideScalar"), or drops them with their inputs ("relational")
+	ChildLinks string `json:"childLinks,omitempty"`
+	// LinkLabels labels the relational edges of the table tree with their
+	// child link type and variable, e.g. [Map $v1]
+	LinkLabels bool `json:"linkLabels,omitempty"`
+	// RenderLimits bound the output, which degrades to less detail to fit
+	RenderLimits *RenderLimits `json:"renderLimits,omitempty"`
+	// ChunkSize returns outputs larger than this many bytes in chunks; 0
+	// does not chunk
+	ChunkSize int `json:"chunkSize,omitempty"`
+	// LineMap returns Response.LineMap, the plan node of each operator row
+	// line of table formats
+	LineMap bool `json:"lineMap,omitempty"`
+	// RootNodeID renders only the subtree of this operator; 0 renders the
+	// whole plan
+	RootNodeID int32 `json:"rootNodeId,omitempty"`
+	// RootBreadcrumb prepends the path from the plan root to the RootNodeID
+	// operator
+	RootBreadcrumb bool `json:"rootBreadcrumb,omitempty"`
+	// Cost adds a Cost column of each operator's share of the self latency
+	// or CPU time, returned in Response.Costs
+	Cost *CostOptions `json:"cost,omitempty"`
+	// Lint appends the lintPlan findings under the table
+	Lint bool `json:"lint,omitempty"`
+	// PlanIndex selects the plan to render of inputs with several; the first
+	// by default
+	PlanIndex *int `json:"planIndex,omitempty"`
+	// IncludeMetrics returns the timings and sizes of the render in
+	// Response.Metrics
+	IncludeMetrics bool `json:"includeMetrics,omitempty"`
+	// Charset "ascii" draws the table decorations with ASCII characters
+	// instead of Unicode ones
+	Charset string `json:"charset,omitempty"`
+	// BorderStyle is the border of table formats: "heavy", "light",
+	// "double", "minimal", or "none"; ASCII borders by default
+	BorderStyle string `json:"borderStyle,omitempty"`
+	// ColorTheme is the "dark" (the default) or "light" colors of the ANSI
+	// format
+	ColorTheme string `json:"colorTheme,omitempty"`
+	// NoColor renders the ANSI format without escapes
+	NoColor bool `json:"noColor,omitempty"`
+	// TreeOneLine renders one line per operator in the TREE format, without
+	// predicates
+	TreeOneLine bool `json:"treeOneLine,omitempty"`
+	// InputLimits bound the input, checked before it is parsed
+	InputLimits InputLimits `json:"inputLimits,omitempty"`
+	// Lenient renders table formats even when plan nodes fail to render,
+	// with placeholder rows for them, and implies Recover
+	Lenient bool `json:"lenient,omitempty"`
+	// LatencyDistribution adds p50 and p99, or mean ± standard deviation, to
+	// the Total Latency cells of operators with latency distributions
+	LatencyDistribution bool `json:"latencyDistribution,omitempty"`
+	// OperatorGlossary appends an explanation of each kind of operator in the
+	// rendered plan under the table
+	OperatorGlossary bool `json:"operatorGlossary,omitempty"`
+	// Totals appends a row with the sum or max of the stats of the leaf
+	// operators to the table, and optionally rolls them up per subtree
+	Totals *TotalsOptions `json:"totals,omitempty"`
+	// CollapsedNodeIDs are operators rendered as one row that summarizes
+	// their subtree, for UIs with collapsible subtrees
+	CollapsedNodeIDs []int32 `json:"collapsedNodeIds,omitempty"`
+	// StatsFields are raw execution stat keys, such as "remote_calls" or
+	// "filesystem_delay_seconds", added as columns titled with the key
+	StatsFields []string `json:"statsFields,omitempty"`
+	// ExperimentalStats adds a column for every numeric execution stat of the
+	// plan that no other column shows, after the StatsFields columns
+	ExperimentalStats bool `json:"experimentalStats,omitempty"`
+	// Deterministic guarantees output that depends only on the input and the
+	// options, for snapshot tests: execution stats are formatted the same way
+	// whatever the input spelled them as, and options that depend on timing
+	// are rejected
+	Deterministic bool `json:"deterministic,omitempty"`
+	// APIVersion is the version of the options the caller was written for;
+	// 0 is the current APIVersion
+	APIVersion int `json:"apiVersion,omitempty"`
+	// PrettyPredicates breaks the conditions of the predicates section and
+	// of the tree format at their AND and OR operators, one per line
+	PrettyPredicates bool `json:"prettyPredicates,omitempty"`
+	// DecimalSeparator is the decimal separator of execution stats spelled
+	// with digit grouping, "." (the default) or ","
+	DecimalSeparator string `json:"decimalSeparator,omitempty"`
+	// StatDurationUnit converts the duration execution stats to "s", "ms",
+	// or "µs" ("us") as they are parsed, so that every format and the JSON
+	// APIs show them in one unit; numberFormat.durationUnit only reformats
+	// the table columns
+	StatDurationUnit string `json:"statDurationUnit,omitempty"`
+	// VariableLinks appends the variables each operator refers to with the
+	// node that defines them, e.g. "$v2 ← node 17"
+	VariableLinks bool `json:"variableLinks,omitempty"`
+	// Heatmap returns each operator's share of the latency, rows, and CPU
+	// time of the plan in Response.Annotations, for heat-colored trees
+	Heatmap bool `json:"heatmap,omitempty"`
+
+	// Checkpoint, if set, is called between the stages of a render, which
+	// stops with its error, e.g. for cancelled render jobs
+	Checkpoint func() error `json:"-"`
+
+	// unknownOptions are the UNKNOWN_OPTION warnings of the JSON the options
+	// were decoded from
+	unknownOptions []Warning
+}
+
+// params are the parameters of renderASCII
+type params struct {
+	Input string `json:"input"`
+	Options
+
+	// parsed is the already parsed input, e.g. of a session plan
+	parsed *parsedPlan
+	// layout is the layout cache of a session plan, or of the parsed input
+	layout *layoutCache
+}
+
+// parsedPlan is a parsed input. It is shared and must not be modified.
+type parsedPlan struct {
+	stats   *sppb.ResultSetStats
+	rowType *sppb.StructType
+	// format is the detected input format, if known
+	format string
+}
+
+type planVizParams struct {
+	Input             string `json:"input"`
+	Full              bool   `json:"full"`
+	Metadata          bool   `json:"metadata,omitempty"`
+	ExecutionStats    bool   `json:"executionStats,omitempty"`
+	ExecutionSummary  bool   `json:"executionSummary,omitempty"`
+	SerializeResult   bool   `json:"serializeResult,omitempty"`
+	HideScanTarget    bool   `json:"hideScanTarget,omitempty"`
+	NonVariableScalar bool   `json:"nonVariableScalar,omitempty"`
+	VariableScalar    bool   `json:"variableScalar,omitempty"`
+	ConsoleNaming     bool   `json:"consoleNaming,omitempty"`
+	Recover           bool   `json:"recover,omitempty"`
+	WeightBy          string `json:"weightBy,omitempty"`
+	WeightStyle       string `json:"weightStyle,omitempty"`
+	EdgeRows          bool   `json:"edgeRows,omitempty"`
+	DecimalSeparator  string `json:"decimalSeparator,omitempty"`
+	StatDurationUnit  string `json:"statDurationUnit,omitempty"`
+}
+
+// Response represents the structured response from WASM
+type Response struct {
+	Success bool   `json:"success"`
+	Result  string `json:"result,omitempty"`
+	// ResultHash is a hash of Result, so that callers can skip updates when
+	// a re-render produced the same output
+	ResultHash string            `json:"resultHash,omitempty"`
+	Warnings   []Warning         `json:"warnings,omitempty"`
+	Metadata   *ResponseMetadata `json:"metadata,omitempty"`
+	// Degradation is the level of detail chosen to fit the renderLimits
+	// option
+	Degradation string `json:"degradation,omitempty"`
+	// Chunks is set when Result is a chunk of an output larger than the
+	// chunkSize option
+	Chunks *ChunkInfo `json:"chunks,omitempty"`
+	// LineMap is set for table formats when params.LineMap is set; chunked
+	// outputs have it in the first chunk
+	LineMap []LineMapEntry `json:"lineMap,omitempty"`
+	// Costs is set with the cost option or the Cost column
+	Costs []NodeCost `json:"costs,omitempty"`
+	// Estimates is set with the estimateColumn option or the Est/Actual
+	// column for plans with estimates
+	Estimates []RowEstimate `json:"estimates,omitempty"`
+	// Timeline is set for table formats in the TIMELINE mode or with the
+	// Timeline column
+	Timeline []TimelineEntry `json:"timeline,omitempty"`
+	// Annotations is set with the heatmap option
+	Annotations []NodeAnnotation `json:"annotations,omitempty"`
+	// Metrics is set with the includeMetrics option
+	Metrics *RenderMetrics `json:"metrics,omitempty"`
+	Error   *Error         `json:"error,omitempty"`
+}
+
+// succeed marks r as a success response and sets its ResultHash.
+func (r *Response) succeed() {
+	r.Success = true
+	if r.Result != "" && r.ResultHash == "" {
+		r.ResultHash = resultHash(r.Result)
+	}
+}
+
+func resultHash(result string) string {
+	h := fnv.New64a()
+	h.Write([]byte(result))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Warning represents a non-fatal problem found while rendering
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	NodeID  *int32 `json:"nodeId,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// Error represents detailed error information
+type Error struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+	// Line, Column, and Snippet locate syntax errors in the input, when
+	// known
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+	// Size and Limit are the measured size and the exceeded limit of
+	// INPUT_TOO_LARGE errors, in bytes or plan nodes by the option in
+	// Details
+	Size  int64 `json:"size,omitempty"`
+	Limit int64 `json:"limit,omitempty"`
+	// Issues lists every problem when validation found more than one
+	Issues []Issue `json:"issues,omitempty"`
+	// Hints are suggested fixes for the error and its issues, such as
+	// capturing the plan with execution stats
+	Hints []ErrorHint `json:"hints,omitempty"`
+}
+
+// Issue represents a single problem in a multi-error validation report
+type Issue struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Error types for better error handling
+const (
+	ErrorTypeParseError           = "PARSE_ERROR"
+	ErrorTypeInvalidSpannerFormat = "INVALID_SPANNER_FORMAT"
+	ErrorTypeRenderError          = "RENDER_ERROR"
+	ErrorTypeInvalidParameters    = "INVALID_PARAMETERS"
+	ErrorTypeCancelled            = "CANCELLED"
+	ErrorTypeUnsupportedOption    = "UNSUPPORTED_OPTION"
+	ErrorTypeInputTooLarge        = "INPUT_TOO_LARGE"
+	ErrorTypeQueueFull            = "QUEUE_FULL"
+	ErrorTypeBusy                 = "BUSY"
+)
+
+// Custom error types for better classification
+// These correspond to WasmErrorType constants in TypeScript
+
+// ParseError represents JSON/YAML parsing failures
+type ParseError struct {
+	msg string
+	// cause is the decoder error, which may locate the problem in the input
+	cause error
+}
+
+func (e ParseError) Error() string {
+	return e.msg
+}
+
+func (e ParseError) Unwrap() error {
+	return e.cause
+}
+
+// extractError is the ParseError for input that extractQueryPlan rejected.
+func extractError(err error) ParseError {
+	return ParseError{msg: fmt.Sprintf("Failed to extract query plan: %v", err), cause: err}
+}
+
+// InvalidSpannerFormatError represents invalid Spanner query plan format or structure
+type InvalidSpannerFormatError struct {
+	msg string
+	// path is the JSON path of the offending element, when known
+	path string
+}
+
+func (e InvalidSpannerFormatError) Error() string {
+	return e.msg
+}
+
+// RenderError represents general rendering failures
+type RenderError struct {
+	msg string
+}
+
+func (e RenderError) Error() string {
+	return e.msg
+}
+
+// InvalidParametersError represents invalid function parameters
+type InvalidParametersError struct {
+	msg string
+}
+
+func (e InvalidParametersError) Error() string {
+	return e.msg
+}
+
+// CancelledError represents renders cancelled with cancelRender
+type CancelledError struct {
+	msg string
+}
+
+func (e CancelledError) Error() string {
+	return e.msg
+}
+
+// UnsupportedOptionError represents options of an API version this build
+// does not support
+type UnsupportedOptionError struct {
+	msg string
+}
+
+func (e UnsupportedOptionError) Error() string {
+	return e.msg
+}
+
+// NewParseError returns a ParseError with the message msg, for frontends
+// that decode parameters themselves.
+func NewParseError(msg string) error {
+	return ParseError{msg: msg}
+}
+
+// NewRenderError returns a RenderError with the message msg.
+func NewRenderError(msg string) error {
+	return RenderError{msg: msg}
+}
+
+// NewInvalidParametersError returns an InvalidParametersError with the
+// message msg.
+func NewInvalidParametersError(msg string) error {
+	return InvalidParametersError{msg: msg}
+}
+
+// NewCancelledError returns a CancelledError with the message msg.
+func NewCancelledError(msg string) error {
+	return CancelledError{msg: msg}
+}
+
+// ErrorResponse builds the error response for err, expanding errors combined
+// with errors.Join into Error.Issues. The top-level type, message, and details
+// describe the first problem.
+func ErrorResponse(err error) Response {
+	errs := flattenErrors(err)
+	if len(errs) == 1 {
+		pos, _ := errorPosition(err)
+		size, limit := inputTooLarge(err)
+		return Response{
+			Success: false,
+			Error: &Error{
+				Type:    classifyError(err),
+				Message: err.Error(),
+				Details: errorDetails(err),
+				Line:    pos.line,
+				Column:  pos.column,
+				Snippet: pos.snippet,
+				Size:    size,
+				Limit:   limit,
+				Hints:   errorHints(err),
+			},
+		}
+	}
+
+	issues := make([]Issue, len(errs))
+	for i, e := range errs {
+		pos, _ := errorPosition(e)
+		issues[i] = Issue{
+			Type:    classifyError(e),
+			Message: e.Error(),
+			Details: errorDetails(e),
+			Line:    pos.line,
+			Column:  pos.column,
+			Snippet: pos.snippet,
+		}
+	}
+	first := issues[0]
+	return Response{
+		Success: false,
+		Error: &Error{
+			Type:    first.Type,
+			Message: fmt.Sprintf("%d problems found; first: %s", len(issues), first.Message),
+			Details: first.Details,
+			Line:    first.Line,
+			Column:  first.Column,
+			Snippet: first.Snippet,
+			Issues:  issues,
+			Hints:   errorHints(err),
+		},
+	}
+}
+
+// Respond returns the response of an export that returned resp and err:
+// the error response for err, or resp marked as a success. Errors are counted
+// in the usage stats.
+func Respond(resp Response, err error) Response {
+	if err != nil {
+		errorType := classifyError(err)
+		usage.countError(errorType)
+		logger.Warn("request failed", "type", errorType, "error", err)
+		return ErrorResponse(err)
+	}
+	resp.succeed()
+	return resp
+}
+
+// Invoke calls the export registered under name with paramsJSON and returns
+// its response, which is the error response for unknown names.
+func Invoke(name, paramsJSON string) Response {
+	export, ok := Lookup(name)
+	if !ok {
+		return Respond(Response{}, InvalidParametersError{msg: fmt.Sprintf("Unknown function: %q", name)})
+	}
+	return Respond(export.Run(paramsJSON))
+}
+
+// Render renders input, a query plan in any of the supported input formats,
+// with opts, like the renderASCII export. Errors are of the error types of
+// this package and classified by ErrorResponse.
+func Render(input []byte, opts Options) (Response, error) {
+	start := time.Now()
+	par := params{Input: string(input), Options: opts}
+	resp, err := renderASCIIImpl(par)
+	if err != nil {
+		return Response{}, err
+	}
+	resp = opts.withUnknownOptions(resp)
+	logRender(par, start, resp)
+	resp.succeed()
+	return resp, nil
+}
+
+// renderASCII renders a plan with JSON parameters
+func renderASCII(paramsJSON string) (Response, error) {
+	par := params{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	par.noteUnknownOptions([]byte(paramsJSON), par)
+	start := time.Now()
+	resp, err := renderASCIIImpl(par)
+	if err != nil {
+		return Response{}, err
+	}
+	resp = par.withUnknownOptions(resp)
+	logRender(par, start, resp)
+	return resp, nil
+}
+
+// getGlossary returns the operator glossary as a JSON array
+func getGlossary(paramsJSON string) (Response, error) {
+	par := glossaryParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return getGlossaryImpl(par)
+}
+
+// renderPrototext re-emits the parsed plan in protobuf text format
+func renderPrototext(paramsJSON string) (Response, error) {
+	par := prototextParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return renderPrototextImpl(par)
+}
+
+// classifyError determines the error type using errors.As for type-safe classification
+func classifyError(err error) string {
+	// Check for custom error types first
+	var parseErr ParseError
+	if errors.As(err, &parseErr) {
+		return ErrorTypeParseError
+	}
+
+	var spannerErr InvalidSpannerFormatError
+	if errors.As(err, &spannerErr) {
+		return ErrorTypeInvalidSpannerFormat
+	}
+
+	var renderErr RenderError
+	if errors.As(err, &renderErr) {
+		return ErrorTypeRenderError
+	}
+
+	var paramErr InvalidParametersError
+	if errors.As(err, &paramErr) {
+		return ErrorTypeInvalidParameters
+	}
+
+	var cancelledErr CancelledError
+	if errors.As(err, &cancelledErr) {
+		return ErrorTypeCancelled
+	}
+
+	var unsupportedErr UnsupportedOptionError
+	if errors.As(err, &unsupportedErr) {
+		return ErrorTypeUnsupportedOption
+	}
+
+	var tooLargeErr InputTooLargeError
+	if errors.As(err, &tooLargeErr) {
+		return ErrorTypeInputTooLarge
+	}
+
+	var queueFullErr QueueFullError
+	if errors.As(err, &queueFullErr) {
+		return ErrorTypeQueueFull
+	}
+
+	var busyErr BusyError
+	if errors.As(err, &busyErr) {
+		return ErrorTypeBusy
+	}
+
+	// Default to render error for unknown error types
+	return ErrorTypeRenderError
+}
+
+// errorDetails returns the Error.Details text for err
+// For format errors this is the JSON path of the offending element
+func errorDetails(err error) string {
+	var spannerErr InvalidSpannerFormatError
+	if errors.As(err, &spannerErr) {
+		return spannerErr.path
+	}
+	var detectionErr inputDetectionError
+	if errors.As(err, &detectionErr) {
+		return detectionErr.details()
+	}
+	var tooLargeErr InputTooLargeError
+	if errors.As(err, &tooLargeErr) {
+		return tooLargeErr.option
+	}
+	return ""
+}
+
+// atCheckpoint calls par.Checkpoint, if set.
+func (par params) atCheckpoint() error {
+	if par.Checkpoint == nil {
+		return nil
+	}
+	return par.Checkpoint()
+}
+
+// queryPlan returns the parsed input, or its plan chosen by planIndex, with
+// its row type and detected format.
+func (par params) queryPlan() (*sppb.ResultSetStats, *sppb.StructType, string, error) {
+	if par.parsed != nil {
+		return par.parsed.stats, par.parsed.rowType, par.parsed.format, nil
+	}
+	if par.InputEncoding == inputEncodingProtoBase64 {
+		stats, rowType, err := extractQueryPlanProtoBase64(par.Input)
+		return stats, rowType, inputFormatProtoBase64, err
+	}
+	input, _, err := selectPlan(par.Input, par.PlanIndex)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return extractQueryPlanFormat(input)
+}
+
+// renderASCIIImpl implements the core rendering logic
+// Validates parameters, extracts query plan, and renders ASCII output
+func renderASCIIImpl(par params) (Response, error) {
+	if err := checkAPIVersion(par.APIVersion); err != nil {
+		return Response{}, err
+	}
+	// Oversized input is rejected before anything reads it
+	if err := par.InputLimits.check(); err != nil {
+		return Response{}, err
+	}
+	if err := par.InputLimits.withDefaults().checkInputBytes(par.Input); err != nil {
+		return Response{}, err
+	}
+	if par.Deterministic {
+		if err := checkDeterministic(par.Options); err != nil {
+			return Response{}, err
+		}
+	}
+	if par.IncludeMetrics {
+		return renderWithMetrics(par)
+	}
+	if par.ChunkSize != 0 {
+		return renderChunked(par)
+	}
+	if par.RenderLimits != nil {
+		return renderWithinLimits(par)
+	}
+	if par.BorderStyle != "" {
+		return renderWithBorderStyle(par)
+	}
+	if par.TargetWidth != 0 {
+		return renderToTargetWidth(par)
+	}
+
+	// Collect every problem so that users can fix their capture in one pass
+	var errs []error
+
+	// The timeline mode renders PROFILE with the Timeline column
+	timelineMode := strings.EqualFold(par.Mode, renderModeTimeline)
+	mode, err := reference.ParseRenderMode(par.Mode)
+	if timelineMode {
+		mode, err = reference.RenderModeProfile, nil
+	}
+	if err != nil {
+		errs = append(errs, InvalidParametersError{msg: fmt.Sprintf("Invalid render mode: %v", err)})
+	}
+
+	// Formats registered from JS with registerFormatter render the row model,
+	// and renderers such as the diagram formats render the plan nodes
+	formatter, custom := lookupFormatter(par.Format)
+	renderer, registered := lookupRenderer(par.Format)
+	htmlFormat := isHTMLFormat(par.Format)
+	// The ANSI format colors the CURRENT table, and the spanner-cli format
+	// relabels the TRADITIONAL table
+	ansiFmt := isANSIFormat(par.Format)
+	spannerCLIFmt := isSpannerCLIFormat(par.Format)
+	format, err := reference.ParseFormat(par.Format)
+	switch {
+	case ansiFmt:
+		format, err = reference.FormatCurrent, nil
+	case spannerCLIFmt:
+		format, err = reference.FormatTraditional, nil
+	}
+	if err != nil && !custom && !registered && !htmlFormat {
+		errs = append(errs, InvalidParametersError{msg: fmt.Sprintf("Invalid format type: %v", err)})
+	}
+
+	if par.PrintSections != nil {
+		for _, section := range *par.PrintSections {
+			if _, err := reference.ParsePrintSection(string(section)); err != nil {
+				errs = append(errs, InvalidParametersError{msg: fmt.Sprintf("Invalid print section: %v", err)})
+			}
+		}
+	}
+
+	if err := checkScalarRepresentation(par.ScalarRepresentation); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkSortBy(par.SortBy); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkSortChildrenBy(par.SortChildrenBy); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkChildLinks(par.ChildLinks); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkOperatorFilter(par.OperatorFilter); err != nil {
+		errs = append(errs, err)
+	}
+	filter, err := parseNodeFilter(par.Filter)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	var costOpts CostOptions
+	if par.Cost != nil {
+		if err := par.Cost.check(); err != nil {
+			errs = append(errs, err)
+		}
+		costOpts = *par.Cost
+	}
+	costOpts = costOpts.withDefaults()
+	var totalsOpts TotalsOptions
+	if par.Totals != nil {
+		if err := par.Totals.check(); err != nil {
+			errs = append(errs, err)
+		}
+		totalsOpts = par.Totals.withDefaults()
+	}
+	if err := par.Thresholds.check(); err != nil {
+		errs = append(errs, err)
+	}
+	if par.Lint && lintSummary == nil {
+		errs = append(errs, InvalidParametersError{msg: "The lint option is not available in this build"})
+	}
+	templates, err := parseTemplateColumns(par.TemplateColumns)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkStatsFields(par.StatsFields); err != nil {
+		errs = append(errs, err)
+	}
+	extraColumns := append(templateColumnTitles(par.TemplateColumns), par.StatsFields...)
+	columns, err := resolveColumns(par.Columns, extraColumns)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	columnConfigs, err := resolveColumnConfig(par.ColumnConfig, extraColumns)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkColumnGroups(par.ColumnGroups); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkInputEncoding(par.InputEncoding); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkCharset(par.Charset); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkDecimalSeparator(par.DecimalSeparator); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkStatDurationUnit(par.StatDurationUnit); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkColorTheme(par.ColorTheme); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkWrapMode(par.WrapMode); err != nil {
+		errs = append(errs, err)
+	}
+	if err := par.NumberFormat.check(); err != nil {
+		errs = append(errs, err)
+	}
+	var latencyBudget float64
+	if par.LatencyBudget != "" {
+		latencyBudget, err = parseLatencyBudget(par.LatencyBudget)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	planCount, planInput := 1, par.Input
+	if par.parsed == nil && par.InputEncoding != inputEncodingProtoBase64 {
+		if planInput, planCount, err = selectPlan(par.Input, par.PlanIndex); err != nil {
+			errs = append(errs, err)
+			return Response{}, errors.Join(errs...)
+		}
+	}
+	stats, rowType, inputFormat, err := par.queryPlan()
+	if err != nil {
+		// Wrap external parsing errors in our custom type
+		errs = append(errs, withInputHints(extractError(err), par.Input))
+		return Response{}, errors.Join(errs...)
+	}
+	if par.layout == nil {
+		// Renders of the input share its layout, like those of a plan handle
+		par.layout = inputCache.layout(planInput)
+	}
+	if err := par.atCheckpoint(); err != nil {
+		return Response{}, err
+	}
+
+	// Validate Spanner query plan structure
+	var warn warningCollector
+	planNodes, err := queryPlanNodes(stats, planInput)
+	if err == nil {
+		if err := par.InputLimits.withDefaults().checkPlanNodes(len(planNodes)); err != nil {
+			return Response{}, err
+		}
+	}
+	switch {
+	case err != nil:
+		errs = append(errs, withInputHints(err, par.Input))
+	case par.Recover || par.Lenient:
+		var recoverWarnings []Warning
+		planNodes, recoverWarnings = recoverPlanNodes(planNodes, planInput)
+		warn.addAll(recoverWarnings)
+	default:
+		if err := validatePlanNodes(planNodes, planInput); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Response{}, err
+	}
+	if err := par.atCheckpoint(); err != nil {
+		return Response{}, err
+	}
+	planNodes = normalizeStatUnits(planNodes, par.DecimalSeparator, par.StatDurationUnit)
+	metadata := planMetadata(planNodes)
+	metadata.DetectedFormat = inputFormat
+	metadata.DML = buildDMLSummary(stats, planNodes)
+	dmlText := dmlSummaryText(metadata.DML)
+	if planCount > 1 {
+		metadata.PlanCount = planCount
+		warn.addAll(multiplePlansWarning(planCount, par.PlanIndex))
+	}
+	rendererOpts := RendererOptions{
+		WithStats:        mode == reference.RenderModeProfile || mode == reference.RenderModeAuto && metadata.Counts.HasExecutionStats,
+		TreeOneLine:      par.TreeOneLine,
+		PrettyPredicates: par.PrettyPredicates,
+		Stats:            stats,
+		rowType:          rowType,
+	}
+	if registered && renderer.parsed {
+		// The plan is exported as parsed; options that change the operators
+		// do not apply
+		result, err := runRenderer(renderer, planNodes, rendererOpts)
+		if err != nil {
+			return Response{}, err
+		}
+		usage.countRender(strings.ToUpper(renderer.Name()), par.Mode)
+		return Response{Result: result, Warnings: warn.list(), Metadata: metadata}, nil
+	}
+	if par.ConsoleNaming {
+		planNodes = applyConsoleNaming(planNodes)
+	}
+	planNodes = filterChildLinks(planNodes, par.ChildLinks)
+	planNodes = sortChildLinks(planNodes, par.SortChildrenBy)
+	planNodes, collapsed, collapseWarnings := collapseSubtrees(planNodes, par.CollapsedNodeIDs)
+	warn.addAll(collapseWarnings)
+
+	// rootNodeId renders the subtree of one operator. IDs do not change, so
+	// each tree built below finds the root by its ID.
+	var subtree map[int32]bool
+	var rootDepth int
+	var breadcrumb string
+	if par.RootNodeID != 0 {
+		root, err := subtreeRoot(par.planTree(planNodes), par.RootNodeID)
+		if err != nil {
+			return Response{}, err
+		}
+		subtree, rootDepth = subtreeIDs(root), root.depth
+		if par.RootBreadcrumb {
+			breadcrumb = breadcrumbText(root)
+		}
+	}
+	if registered {
+		// Renderers render the operators themselves; table options such as
+		// annotations and columns do not apply
+		if subtree != nil {
+			rendererOpts.RootNodeID = par.RootNodeID
+		}
+		result, err := runRenderer(renderer, planNodes, rendererOpts)
+		if err != nil {
+			return Response{}, err
+		}
+		usage.countRender(strings.ToUpper(renderer.Name()), par.Mode)
+		return Response{Result: result, Warnings: warn.list(), Metadata: metadata}, nil
+	}
+	// Variables are linked before full representations expand them away
+	var variablesText string
+	if par.VariableLinks {
+		variablesText = variableLinksText(par.planTree(planNodes), subtree, par.Charset)
+	}
+	planNodes, footnotes := applyScalarRepresentation(planNodes, par.ScalarRepresentation)
+
+	annotations := par.Annotations
+	var budgetText string
+	if latencyBudget > 0 {
+		var budgetWarnings []Warning
+		annotations, budgetText, budgetWarnings = applyLatencyBudget(planNodes, latencyBudget, annotations)
+		warn.addAll(budgetWarnings)
+	}
+	var totals leafTotals
+	if par.Totals != nil {
+		tree := par.planTree(planNodes)
+		root := tree.root
+		if subtree != nil {
+			root = tree.nodes[par.RootNodeID]
+		}
+		totals = subtreeTotals(root, totalsOpts.Aggregate)
+		switch {
+		case !totals.hasStats():
+			warn.add(WarningCodeNoExecutionStats, "The input has no execution stats; the totals option is ignored")
+		case totalsOpts.Rollups:
+			annotations = rollupAnnotations(root, totalsOpts, par.NumberFormat, annotations)
+		}
+	}
+
+	var header string
+	if par.ShowQueryText || par.SubstituteParameters {
+		h, headerWarnings, err := queryHeader(stats, par.SubstituteParameters, par.QueryParameters)
+		if err != nil {
+			return Response{}, err
+		}
+		header = h
+		warn.addAll(headerWarnings)
+	}
+	var optimizerText string
+	if par.ShowOptimizerInfo {
+		var optimizerWarnings []Warning
+		optimizerText, optimizerWarnings = optimizerHeader(stats)
+		warn.addAll(optimizerWarnings)
+	}
+
+	var costs []NodeCost
+	if par.Cost != nil || slices.Contains(columns, costColumnTitle) {
+		costs = nodeCosts(par.planTree(planNodes), costOpts)
+	}
+	var heat []NodeAnnotation
+	if par.Heatmap {
+		heat = heatmapAnnotations(par.planTree(planNodes))
+	}
+
+	if custom {
+		rows := par.planRows(par.planTree(planNodes))
+		setCollapsedRows(rows, collapsed)
+		warn.addAll(annotationWarnings(annotateRows(rows, annotations)))
+		if keep := keptRowIDs(par.planTree(planNodes), par.OperatorFilter, filter); keep != nil {
+			rows = slices.DeleteFunc(rows, func(r planRow) bool { return !keep[r.ID] })
+		}
+		if subtree != nil {
+			rows = rerootRows(rows, subtree, rootDepth)
+		}
+		sortPlanRows(rows, par.SortBy)
+		s, err := runFormatter(par.Format, formatter, rows)
+		if err != nil {
+			return Response{}, err
+		}
+		usage.countRender(par.Format, par.Mode)
+		return Response{Result: header + optimizerText + breadcrumb + s, Warnings: warn.list(), Metadata: metadata, Costs: costs, Annotations: heat}, nil
+	}
+
+	var lintText string
+	if par.Lint {
+		lintText, err = lintSummary(par.planTree(planNodes), par.Thresholds.withDefaults())
+		if err != nil {
+			return Response{}, err
+		}
+	}
+	var glossaryText string
+	if par.OperatorGlossary {
+		glossaryText = glossaryFootnotes(par.planTree(planNodes), subtree)
+	}
+
+	if htmlFormat {
+		tree := par.planTree(planNodes)
+		rows := par.planRows(tree)
+		setCollapsedRows(rows, collapsed)
+		warn.addAll(annotationWarnings(annotateRows(rows, annotations)))
+		if subtree != nil {
+			rows = rerootRows(rows, subtree, rootDepth)
+		}
+		opts := htmlOptions{
+			withStats: mode == reference.RenderModeProfile || mode == reference.RenderModeAuto && metadata.Counts.HasExecutionStats,
+			templates: templates,
+			groups:    par.ColumnGroups,
+			keep:      keptRowIDs(tree, par.OperatorFilter, filter),
+		}
+		if costs != nil {
+			opts.costs = make(map[int32]string, len(costs))
+			for _, c := range costs {
+				opts.costs[c.NodeID] = costCell(c, costOpts)
+			}
+		}
+		if len(par.Columns) > 0 {
+			opts.columns = columns
+		}
+		s, htmlWarnings, err := renderHTMLTable(tree, rows, opts)
+		if err != nil {
+			return Response{}, err
+		}
+		warn.addAll(htmlWarnings)
+		if breadcrumb != "" {
+			s = htmlPre("breadcrumb", breadcrumb) + s
+		}
+		if optimizerText != "" {
+			s = htmlPre("optimizer", optimizerText) + s
+		}
+		if header != "" {
+			s = htmlPre("query", header) + s
+		}
+		if footnotes != "" {
+			s += htmlPre("footnotes", footnotes)
+		}
+		if variablesText != "" {
+			s += htmlPre("variables", variablesText)
+		}
+		if budgetText != "" {
+			s += htmlPre("latency-budget", budgetText)
+		}
+		if lintText != "" {
+			s += htmlPre("lint", lintText)
+		}
+		if dmlText != "" {
+			s += htmlPre("dml", dmlText)
+		}
+		if glossaryText != "" {
+			s += htmlPre("glossary", glossaryText)
+		}
+		usage.countRender(formatHTML, par.Mode)
+		return Response{Result: s, Warnings: warn.list(), Metadata: metadata, Costs: costs, Annotations: heat}, nil
+	}
+
+	config := reference.RenderConfig{
+		WrapWidth:                  par.WrapWidth,
+		HangingIndent:              par.HangingIndent,
+		PrintSections:              par.PrintSections,
+		ShowScalarVars:             par.ShowScalarVars,
+		ResolveScalarVars:          par.ResolveScalarVars,
+		ResolveScalarVarsRecursive: par.ResolveScalarVarsRecursive,
+	}
+	renderNodes := planNodes
+	if par.PrettyMetadataKeys {
+		renderNodes = applyPrettyMetadataKeys(planNodes)
+	}
+	if par.LinkLabels {
+		renderNodes = labelChildLinks(renderNodes)
+	}
+	if err := par.atCheckpoint(); err != nil {
+		return Response{}, err
+	}
+	var s string
+	if par.Lenient {
+		var repaired map[int]*sppb.PlanNode
+		var lenientWarnings []Warning
+		s, repaired, lenientWarnings, err = renderTableLenient(renderNodes, mode, format, config)
+		if err != nil {
+			return Response{}, err
+		}
+		// Later stages read the nodes again, so they see the placeholders
+		if len(repaired) > 0 {
+			renderNodes, planNodes = slices.Clone(renderNodes), slices.Clone(planNodes)
+			for i, node := range repaired {
+				renderNodes[i], planNodes[i] = node, node
+			}
+		}
+		warn.addAll(lenientWarnings)
+	} else {
+		s, err = renderTreeTable(renderNodes, mode, format, config)
+		if err != nil {
+			return Response{}, RenderError{msg: fmt.Sprintf("Failed to render tree table: %v", err)}
+		}
+	}
+	// The library wraps mid-token, so other wrap modes rewrap its cells with
+	// the full text of an unwrapped render
+	if par.WrapWidth > 0 && (par.WrapMode == wrapModeWord || par.WrapMode == wrapModeSmart) {
+		config.WrapWidth = 0
+		unwrapped, err := renderTreeTable(renderNodes, mode, format, config)
+		if err != nil {
+			return Response{}, RenderError{msg: fmt.Sprintf("Failed to render tree table: %v", err)}
+		}
+		s = rewrapOperatorColumn(s, unwrapped, par.WrapMode)
+	}
+	if err := par.atCheckpoint(); err != nil {
+		return Response{}, err
+	}
+	// The nodes do not change from here, so the stages below share a tree
+	tree := par.planTree(planNodes)
+	if subtree != nil {
+		s = rerootTableRows(s, subtree, rootDepth)
+	}
+	if len(collapsed) > 0 {
+		summaries := make(map[int32]string, len(collapsed))
+		rows := par.planRows(tree)
+		setCollapsedRows(rows, collapsed)
+		for _, row := range rows {
+			if row.Collapsed > 0 {
+				summaries[row.ID] = collapsedSummary(row)
+			}
+		}
+		s = markCollapsedRows(s, summaries)
+	}
+	// Selecting an added column adds it
+	var estimates []RowEstimate
+	if par.EstimateColumn || slices.Contains(columns, estimateColumnTitle) {
+		var estimateWarnings []Warning
+		t := par.Thresholds.withDefaults()
+		s, estimateWarnings = applyEstimateColumn(s, tree, t)
+		warn.addAll(estimateWarnings)
+		if e := rowEstimates(tree); len(e) > 0 {
+			estimates = estimateResults(e, t.MisestimateRatio)
+		}
+	}
+	if par.LatencyBars || slices.Contains(columns, latencyBarColumnTitle) {
+		s = applyLatencyBarColumn(s, tree)
+	}
+	var timeline []TimelineEntry
+	if timelineMode || slices.Contains(columns, timelineColumnTitle) {
+		timeline = buildTimeline(tree)
+		if len(timeline) == 0 && timelineMode {
+			warn.add(WarningCodeNoLatencyStats, "The input has no latency stats; the timeline is empty")
+		}
+		s = applyTimelineColumn(s, timeline)
+	}
+	if slices.Contains(columns, cpuColumnTitle) {
+		s = applyCPUColumn(s, tree)
+	}
+	if len(par.StatsFields) > 0 || par.ExperimentalStats {
+		fields, statsWarnings := statsColumnFields(metadata.StatsFields, par.StatsFields, par.ExperimentalStats)
+		warn.addAll(statsWarnings)
+		s = applyStatsColumns(s, tree, fields, par.Deterministic)
+	}
+	s = applyCostColumn(s, costs, costOpts)
+	var templateWarnings []Warning
+	s, templateWarnings = applyTemplateColumns(s, tree, templates)
+	warn.addAll(templateWarnings)
+	s = applyNumberFormat(s, tree, par.NumberFormat, par.Deterministic)
+	if par.LatencyDistribution {
+		s = applyLatencyDistribution(s, tree, par.NumberFormat)
+	}
+	s, unknown := injectAnnotations(s, annotations)
+	warn.addAll(annotationWarnings(unknown))
+	if keep := keptRowIDs(tree, par.OperatorFilter, filter); keep != nil {
+		s = filterTableRows(s, keep)
+	}
+	// Columns are selected after the rows are found by their ID cells
+	if len(columns) > 0 {
+		var columnWarnings []Warning
+		s, columnWarnings = selectTableColumns(s, columns)
+		warn.addAll(columnWarnings)
+	}
+	s = applyColumnConfig(s, columnConfigs)
+	if totals.hasStats() {
+		s = appendTotalsRow(s, totals, par.NumberFormat)
+	}
+	if spannerCLIFmt {
+		s = applySpannerCLILayout(s, mode == reference.RenderModeProfile || mode == reference.RenderModeAuto && metadata.Counts.HasExecutionStats)
+	}
+	// Group headers go last, as added columns look for the header line
+	s, err = addColumnGroups(s, par.ColumnGroups)
+	if err != nil {
+		return Response{}, err
+	}
+	if par.PrettyPredicates {
+		s = prettyPrintPredicateAppendix(s)
+	}
+	if ansiFmt && !par.NoColor {
+		theme := ansiThemes[cmp.Or(par.ColorTheme, colorThemeDark)]
+		s = colorizeTable(s, costShares(tree, costOpts), costOpts, theme)
+	}
+	if footnotes != "" {
+		s += "\n" + footnotes
+	}
+	if variablesText != "" {
+		s += "\n" + variablesText
+	}
+	if budgetText != "" {
+		s += "\n" + budgetText
+	}
+	if lintText != "" {
+		s += "\n" + lintText
+	}
+	if dmlText != "" {
+		s += "\n" + dmlText
+	}
+	if glossaryText != "" {
+		s += "\n" + glossaryText
+	}
+	if par.Charset == charsetASCII {
+		s = asciiDecorations.Replace(s)
+	}
+	usage.countRender(par.Format, par.Mode)
+	resp := Response{Result: header + optimizerText + breadcrumb + s, Warnings: warn.list(), Metadata: metadata, Costs: costs, Estimates: estimates, Timeline: timeline, Annotations: heat}
+	if par.LineMap {
+		resp.LineMap = buildLineMap(resp.Result)
+	}
+	return resp, nil
+}
+
+// loadPlanVizStats extracts and validates the query plan for the diagram
+// renderers, applying the recover and consoleNaming options. The returned
+// stats is a copy that is safe to modify.
+func loadPlanVizStats(par planVizParams) (*sppb.ResultSetStats, *sppb.StructType, []Warning, error) {
+	if err := checkDecimalSeparator(par.DecimalSeparator); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := checkStatDurationUnit(par.StatDurationUnit); err != nil {
+		return nil, nil, nil, err
+	}
+	stats, rowType, err := extractQueryPlan(par.Input)
+	if err != nil {
+		return nil, nil, nil, withInputHints(extractError(err), par.Input)
+	}
+
+	planNodes, err := queryPlanNodes(stats, par.Input)
+	if err != nil {
+		return nil, nil, nil, withInputHints(err, par.Input)
+	}
+	var warnings []Warning
+	if par.Recover {
+		planNodes, warnings = recoverPlanNodes(planNodes, par.Input)
+	} else if err := validatePlanNodes(planNodes, par.Input); err != nil {
+		return nil, nil, nil, err
+	}
+	if par.ConsoleNaming {
+		planNodes = applyConsoleNaming(planNodes)
+	}
+	planNodes = normalizeStatUnits(planNodes, par.DecimalSeparator, par.StatDurationUnit)
+
+	// stats may be shared with the parse cache, so build on a copy
+	stats = &sppb.ResultSetStats{
+		QueryPlan:  &sppb.QueryPlan{PlanNodes: planNodes},
+		QueryStats: stats.GetQueryStats(),
+		RowCount:   stats.GetRowCount(),
+	}
+	return stats, rowType, warnings, nil
+}
+
+func init() {
+	registerFeature("core", map[string]Export{
+		"renderASCII":         {Run: renderASCII},
+		"getGlossary":         {Run: getGlossary},
+		"describeOperator":    {Run: describeOperator},
+		"renderPrototext":     {Run: renderPrototext},
+		"getUsageStats":       {Run: getUsageStats},
+		"logLevel":            {Run: logLevel},
+		"savePreset":          {Run: savePreset, Mutates: true},
+		"applyPreset":         {Run: applyPreset},
+		"fingerprintPlan":     {Run: fingerprintPlan},
+		"checkPlanRegression": {Run: checkPlanRegression},
+		"getFanOutReport":     {Run: getFanOutReport},
+		"extractScans":        {Run: extractScans},
+		"getNodeDetail":       {Run: getNodeDetail},
+		"searchPlan":          {Run: searchPlan},
+		"analyzeCriticalPath": {Run: analyzeCriticalPath},
+		"analyzeJoins":        {Run: analyzeJoins},
+		"parsePlan":           {Run: parsePlan},
+		"renderBatch":         {Run: renderBatch},
+		"renderRange":         {Run: renderRange},
+		"selfTest":            {Run: selfTest},
+		"diffPlans":           {Run: diffPlans},
+		"diffPlanStructure":   {Run: diffPlanStructure},
+		"loadPlan":            {Run: loadPlan, Mutates: true},
+		"releasePlan":         {Run: releasePlan, Mutates: true},
+		"renderPlan":          {Run: renderPlan},
+		"labelPlan":           {Run: labelPlan, Mutates: true},
+		"exportSession":       {Run: exportSession},
+		"importSession":       {Run: importSession, Mutates: true},
+		"summarizePlan":       {Run: summarizePlan},
+		"getQueryInfo":        {Run: getQueryInfo},
+		"getCapabilities":     {Run: getCapabilities, NoParams: true},
+		"getVersionInfo":      {Run: getVersionInfo, NoParams: true},
+		"validateInput":       {Run: validateInput},
+		"listSamples":         {Run: listSamples, NoParams: true},
+		"getSample":           {Run: getSample},
+		"nextChunk":           {Run: nextChunk},
+		"releaseChunks":       {Run: releaseChunks},
+		"getMemoryStats":      {Run: getMemoryStats, NoParams: true},
+		"freeMemory":          {Run: freeMemory, NoParams: true, Mutates: true},
+	})
+}
@@ -0,0 +1,193 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// largePlanSizes are the approximate relational node counts of the benchmark
+// plans, up to the 3,000 operators of the largest plans reported by users.
+var largePlanSizes = []int{1000, 3000}
+
+// largeProfilePlan returns a PROFILE capture as REST JSON with about
+// operators relational nodes: Distributed Unions over Cross Applies of index
+// scans and table lookups, each operator with a predicate or a scan target and
+// execution stats, as in plans of wide UNION ALL queries.
+func largeProfilePlan(operators int) string {
+	var nodes []*sppb.PlanNode
+	add := func(kind sppb.PlanNode_Kind, name string, metadata map[string]any, stats bool) *sppb.PlanNode {
+		n := &sppb.PlanNode{Index: int32(len(nodes)), Kind: kind, DisplayName: name}
+		if metadata != nil {
+			n.Metadata, _ = structpb.NewStruct(metadata)
+		}
+		if stats {
+			i := len(nodes)
+			n.ExecutionStats, _ = structpb.NewStruct(map[string]any{
+				"latency":             map[string]any{"total": fmt.Sprintf("%d.%02d", i%97, i%100), "unit": "msecs"},
+				"rows":                map[string]any{"total": fmt.Sprint(i % 1000), "unit": "rows"},
+				"cpu_time":            map[string]any{"total": fmt.Sprintf("%d.%02d", i%53, i%100), "unit": "msecs"},
+				"execution_summary":   map[string]any{"num_executions": "1"},
+				"remote_calls":        map[string]any{"total": "0", "unit": "calls"},
+				"deleted_rows":        map[string]any{"total": "0", "unit": "rows"},
+				"filesystem_delay_ms": map[string]any{"total": "0", "unit": "msecs"},
+			})
+		}
+		nodes = append(nodes, n)
+		return n
+	}
+	link := func(parent, child *sppb.PlanNode, typ, variable string) {
+		parent.ChildLinks = append(parent.ChildLinks, &sppb.PlanNode_ChildLink{ChildIndex: child.Index, Type: typ, Variable: variable})
+	}
+	scalar := func(description string) *sppb.PlanNode {
+		n := add(sppb.PlanNode_SCALAR, "Function", nil, false)
+		n.ShortRepresentation = &sppb.PlanNode_ShortRepresentation{Description: description}
+		return n
+	}
+
+	root := add(sppb.PlanNode_RELATIONAL, "Serialize Result", nil, true)
+	union := add(sppb.PlanNode_RELATIONAL, "Union All", nil, true)
+	link(root, union, "", "")
+	// Each branch has 6 relational nodes
+	for b := 0; b < operators/6; b++ {
+		du := add(sppb.PlanNode_RELATIONAL, "Distributed Union", map[string]any{"call_type": "Local", "subquery_cluster_node": fmt.Sprint(b)}, true)
+		link(union, du, "", "")
+		apply := add(sppb.PlanNode_RELATIONAL, "Cross Apply", nil, true)
+		link(du, apply, "", "")
+		input := add(sppb.PlanNode_RELATIONAL, "Filter Scan", map[string]any{"seekable_key_size": "0"}, true)
+		link(apply, input, "Input", "")
+		scan := add(sppb.PlanNode_RELATIONAL, "Scan", map[string]any{"scan_type": "IndexScan", "scan_target": fmt.Sprintf("SongsBySongName%d", b%7), "Full scan": "true", "execution_method": "Row"}, true)
+		link(input, scan, "", "")
+		ref := add(sppb.PlanNode_SCALAR, "Reference", nil, false)
+		ref.ShortRepresentation = &sppb.PlanNode_ShortRepresentation{Description: "SongName"}
+		link(scan, ref, "", fmt.Sprintf("SongName_%d", b))
+		link(input, scalar(fmt.Sprintf("(($SongName_%d >= 'A') AND ($SongName_%d < 'B'))", b, b)), "Residual Condition", "")
+		lookup := add(sppb.PlanNode_RELATIONAL, "Distributed Union", map[string]any{"call_type": "Local"}, true)
+		link(apply, lookup, "Map", "")
+		table := add(sppb.PlanNode_RELATIONAL, "Scan", map[string]any{"scan_type": "TableScan", "scan_target": "Songs", "execution_method": "Row"}, true)
+		link(lookup, table, "", "")
+		link(table, scalar(fmt.Sprintf("($SongName_%d = $SongName)", b)), "Seek Condition", "")
+	}
+
+	b, err := protojson.Marshal(&sppb.ResultSetStats{
+		QueryPlan: &sppb.QueryPlan{PlanNodes: nodes},
+		QueryStats: &structpb.Struct{Fields: map[string]*structpb.Value{
+			"elapsed_time":      structpb.NewStringValue("1234.5 msecs"),
+			"optimizer_version": structpb.NewStringValue("7"),
+		}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// benchmarkRender renders a large plan with opts and reports the relational
+// nodes rendered per second. Unless cached, the input is parsed and the table
+// rendered by the library every time, as for the first render of a plan;
+// cached renders are those of the same plan with other options.
+func benchmarkRender(b *testing.B, opts Options, cached bool) {
+	for _, size := range largePlanSizes {
+		input := []byte(largeProfilePlan(size))
+		b.Run(fmt.Sprintf("nodes=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(input)))
+			for b.Loop() {
+				if !cached {
+					inputCache.clear()
+					renderedTables.clear()
+				}
+				if _, err := Render(input, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(size)*float64(b.N)/b.Elapsed().Seconds(), "nodes/s")
+		})
+	}
+}
+
+func BenchmarkRenderCurrent(b *testing.B) {
+	benchmarkRender(b, Options{Mode: "PROFILE", Format: "CURRENT"}, false)
+}
+
+func BenchmarkRenderCurrentCached(b *testing.B) {
+	benchmarkRender(b, Options{Mode: "PROFILE", Format: "CURRENT"}, true)
+}
+
+// BenchmarkRenderColumns adds the columns computed from the tree to the
+// rendered table.
+func BenchmarkRenderColumns(b *testing.B) {
+	benchmarkRender(b, Options{Mode: "PROFILE", Format: "CURRENT", EstimateColumn: true, LatencyBars: true, Columns: []string{"ID", "Operator", "Rows", "Exec.", "Latency", cpuColumnTitle}}, true)
+}
+
+func BenchmarkRenderWrapped(b *testing.B) {
+	benchmarkRender(b, Options{Mode: "PROFILE", Format: "CURRENT", WrapWidth: 80, WrapMode: wrapModeWord}, true)
+}
+
+func BenchmarkRenderHTML(b *testing.B) {
+	benchmarkRender(b, Options{Mode: "PROFILE", Format: "HTML"}, true)
+}
+
+func BenchmarkRenderTree(b *testing.B) {
+	benchmarkRender(b, Options{Mode: "PROFILE", Format: formatTree}, true)
+}
+
+// BenchmarkParsePlan measures parsing alone, which renders of new input
+// start with.
+func BenchmarkParsePlan(b *testing.B) {
+	for _, size := range largePlanSizes {
+		input := largeProfilePlan(size)
+		b.Run(fmt.Sprintf("nodes=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(input)))
+			for b.Loop() {
+				if _, _, _, err := parseQueryPlan(input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRenderPlanFormats switches a loaded plan between the table and
+// HTML formats, as the UI does when the output tab changes. Unless cached,
+// the plan is rendered without its handle, so that the tree and the row model
+// are built on every render.
+func BenchmarkRenderPlanFormats(b *testing.B) {
+	for _, cached := range []bool{false, true} {
+		for _, size := range largePlanSizes {
+			input := largeProfilePlan(size)
+			resp, err := loadPlanImpl(loadPlanParams{Input: input})
+			if err != nil {
+				b.Fatal(err)
+			}
+			var info SessionPlanInfo
+			if err := json.Unmarshal([]byte(resp.Result), &info); err != nil {
+				b.Fatal(err)
+			}
+			formats := []string{"CURRENT", "HTML"}
+			b.Run(fmt.Sprintf("cached=%t/nodes=%d", cached, size), func(b *testing.B) {
+				b.ReportAllocs()
+				i := 0
+				for b.Loop() {
+					options := fmt.Sprintf(`{"mode":"PROFILE","format":%q,"latencyBars":true,"estimateColumn":true}`, formats[i%len(formats)])
+					i++
+					if !cached {
+						session.clearLayouts()
+					}
+					if _, err := renderPlanImpl(renderPlanParams{ID: info.ID, Options: json.RawMessage(options)}); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(size)*float64(b.N)/b.Elapsed().Seconds(), "nodes/s")
+			})
+			if err := session.release(info.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan/plantree/reference"
+)
+
+// Renderer is a renderASCII format that renders the plan nodes itself, unlike
+// the table, ANSI, HTML, and custom formats, which share the row model and
+// the table options. The diagram, flat, tree, and JSON-NORMALIZED formats are
+// renderers, and RegisterRenderer adds experimental ones.
+type Renderer interface {
+	// Name is the format name, matched in any case
+	Name() string
+	// Render renders nodes, the plan nodes by index, after the options that
+	// change the operators such as consoleNaming and childLinks
+	Render(nodes []*sppb.PlanNode, opts RendererOptions) (string, error)
+}
+
+// RendererOptions are the renderASCII options of a Renderer
+type RendererOptions struct {
+	// RootNodeID is the operator of the subtree to render, or 0 for the
+	// whole plan
+	RootNodeID int32
+	// WithStats is set in PROFILE mode, and in AUTO mode for plans with
+	// execution stats
+	WithStats        bool
+	TreeOneLine      bool
+	PrettyPredicates bool
+	// Stats is the parsed input, for its query stats and row count
+	Stats *sppb.ResultSetStats
+	// rowType is the row type of ResultSet inputs, for the diagram formats
+	rowType *sppb.StructType
+}
+
+// registeredRenderer is a Renderer with its capability.
+type registeredRenderer struct {
+	Renderer
+	description string
+	kind        string
+	// parsed renderers export the plan as parsed: they render the nodes
+	// before the options that change the operators
+	parsed bool
+}
+
+// renderers holds the registered renderers by upper-cased name, like the
+// built-in formats, and rendererNames their names in order of registration,
+// which is the order of getCapabilities.
+var (
+	renderers     = make(map[string]registeredRenderer)
+	rendererNames []string
+)
+
+// rendererFunc is a Renderer of a name and a function.
+type rendererFunc struct {
+	name   string
+	render func(nodes []*sppb.PlanNode, opts RendererOptions) (string, error)
+}
+
+func (r rendererFunc) Name() string {
+	return r.name
+}
+
+func (r rendererFunc) Render(nodes []*sppb.PlanNode, opts RendererOptions) (string, error) {
+	return r.render(nodes, opts)
+}
+
+// registerRenderer adds r to the registry, replacing a renderer of the same
+// name in its place.
+func registerRenderer(r registeredRenderer) {
+	key := strings.ToUpper(r.Name())
+	if _, ok := renderers[key]; !ok {
+		rendererNames = append(rendererNames, key)
+	}
+	renderers[key] = r
+}
+
+// lookupRenderer returns the renderer registered for format, if any.
+func lookupRenderer(format string) (registeredRenderer, bool) {
+	r, ok := renderers[strings.ToUpper(format)]
+	return r, ok
+}
+
+// isBuiltinFormat reports whether format is a format of this package: a
+// table format, or a renderer other than those of RegisterRenderer.
+func isBuiltinFormat(format string) bool {
+	if _, err := reference.ParseFormat(format); err == nil || isHTMLFormat(format) || isANSIFormat(format) || isSpannerCLIFormat(format) {
+		return true
+	}
+	r, ok := lookupRenderer(format)
+	return ok && r.kind != formatKindExperimental
+}
+
+// RegisterRenderer registers r as the renderer of the renderASCII format of
+// its name, listed in getCapabilities as an experimental format described by
+// description, so that a new visualization is prototyped without changing
+// the render pipeline. Built-in formats and formats registered with
+// RegisterFormatter cannot be replaced.
+func RegisterRenderer(r Renderer, description string) error {
+	name := r.Name()
+	switch {
+	case name == "":
+		return InvalidParametersError{msg: "Renderer name must be a non-empty string"}
+	case isBuiltinFormat(name):
+		return InvalidParametersError{msg: fmt.Sprintf("Cannot override built-in format: %s", name)}
+	}
+	if _, ok := lookupFormatter(name); ok {
+		return InvalidParametersError{msg: fmt.Sprintf("Format %s is registered with registerFormatter", name)}
+	}
+	registerRenderer(registeredRenderer{Renderer: r, description: description, kind: formatKindExperimental})
+	return nil
+}
+
+// runRenderer renders nodes with r. Failures and panics of experimental
+// renderers are reported as render errors, and options a renderer does not
+// support as invalid parameters.
+func runRenderer(r registeredRenderer, nodes []*sppb.PlanNode, opts RendererOptions) (result string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = RenderError{msg: fmt.Sprintf("Renderer %s failed: %v", r.Name(), p)}
+		}
+	}()
+	result, err = r.Render(nodes, opts)
+	if err != nil {
+		var renderErr RenderError
+		var paramsErr InvalidParametersError
+		if !errors.As(err, &renderErr) && !errors.As(err, &paramsErr) {
+			err = RenderError{msg: fmt.Sprintf("Renderer %s failed: %v", r.Name(), err)}
+		}
+		return "", err
+	}
+	return result, nil
+}
+
+// subtreeOf returns the root of the subtree of opts in tree, or nil for the
+// whole plan.
+func (opts RendererOptions) subtreeOf(tree *planTree) *treeNode {
+	if opts.RootNodeID == 0 {
+		return nil
+	}
+	return tree.nodes[opts.RootNodeID]
+}
+
+// The built-in renderers, in the order of getCapabilities
+func init() {
+	diagramDescriptions := map[diagramSyntax]string{
+		diagramPlantUML: "PlantUML work breakdown structure of the operator tree, for wide plans",
+	}
+	for _, name := range sortedKeys(diagramFormats) {
+		syntax := diagramFormats[name]
+		// Table options such as annotations and columns do not apply
+		registerRenderer(registeredRenderer{
+			Renderer: rendererFunc{name, func(nodes []*sppb.PlanNode, opts RendererOptions) (string, error) {
+				tree := buildPlanTree(nodes)
+				if root := opts.subtreeOf(tree); root != nil {
+					tree.root = root
+				}
+				return writeDiagram(syntax, tree, diagramOptions{}), nil
+			}},
+			description: diagramDescriptions[syntax],
+			kind:        formatKindDiagram,
+		})
+	}
+
+	// Flat exports list every plan node
+	for _, f := range []struct {
+		name, description string
+		write             func(tree *planTree, root *treeNode, withStats bool) string
+	}{
+		{formatCSV, "Comma-separated values with a row per plan node and a column per metadata key and stat field", func(tree *planTree, root *treeNode, withStats bool) string {
+			return writeFlatTable(tree, root, ',', withStats)
+		}},
+		{formatTSV, "Tab-separated values with a row per plan node and a column per metadata key and stat field", func(tree *planTree, root *treeNode, withStats bool) string {
+			return writeFlatTable(tree, root, '\t', withStats)
+		}},
+		{formatJSONL, "JSON Lines with an object per plan node, with nested metadata and stats", writeJSONLines},
+	} {
+		registerRenderer(registeredRenderer{
+			Renderer: rendererFunc{f.name, func(nodes []*sppb.PlanNode, opts RendererOptions) (string, error) {
+				tree := buildPlanTree(nodes)
+				return f.write(tree, opts.subtreeOf(tree), opts.WithStats), nil
+			}},
+			description: f.description,
+			kind:        formatKindFlat,
+		})
+	}
+
+	// The tree format has no columns or stats
+	registerRenderer(registeredRenderer{
+		Renderer: rendererFunc{formatTree, func(nodes []*sppb.PlanNode, opts RendererOptions) (string, error) {
+			tree := buildPlanTree(nodes)
+			root := tree.root
+			if r := opts.subtreeOf(tree); r != nil {
+				root = r
+			}
+			return writeOperatorTree(root, opts.TreeOneLine, opts.PrettyPredicates), nil
+		}},
+		description: "Indented operator lines with scan targets and predicates, without borders or stats",
+		kind:        formatKindTree,
+	})
+
+	registerRenderer(registeredRenderer{
+		Renderer: rendererFunc{formatNormalizedJSON, func(nodes []*sppb.PlanNode, opts RendererOptions) (string, error) {
+			return writeNormalizedJSON(opts.Stats, nodes)
+		}},
+		description: "The parsed plan as ResultSetStats JSON with sorted keys and fixed indentation, for diffs",
+		kind:        formatKindJSON,
+		parsed:      true,
+	})
+}
@@ -0,0 +1,143 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Values accepted by the scalarRepresentation option
+const (
+	scalarRepresentationShort    = "short"
+	scalarRepresentationFull     = "full"
+	scalarRepresentationFootnote = "footnote"
+)
+
+// maxExpansionDepth bounds variable expansion in full representations, which
+// keeps deeply nested computed columns readable.
+const maxExpansionDepth = 8
+
+// variableRefPattern matches variable references in scalar descriptions, such
+// as "$SingerId", "$group_SongGenre'", or "$v2.Batch".
+var variableRefPattern = regexp.MustCompile(`\$[A-Za-z_][A-Za-z0-9_.']*`)
+
+// scalarExpander builds full representations of scalar expressions by
+// replacing variable references with the expressions that define them.
+type scalarExpander struct {
+	planNodes []*sppb.PlanNode
+	// defs maps variable names to the scalar node that defines them. Names
+	// bound to different expressions in different subtrees are ambiguous and
+	// are left unexpanded.
+	defs map[string]int32
+}
+
+func newScalarExpander(planNodes []*sppb.PlanNode) *scalarExpander {
+	e := &scalarExpander{planNodes: planNodes, defs: make(map[string]int32)}
+	ambiguous := make(map[string]bool)
+	for _, node := range planNodes {
+		for _, link := range node.GetChildLinks() {
+			name := link.GetVariable()
+			child := planNodes[link.GetChildIndex()]
+			if name == "" || child.GetKind() != sppb.PlanNode_SCALAR {
+				continue
+			}
+			if prev, ok := e.defs[name]; ok && e.description(prev) != e.description(child.GetIndex()) {
+				ambiguous[name] = true
+			}
+			e.defs[name] = child.GetIndex()
+		}
+	}
+	for name := range ambiguous {
+		delete(e.defs, name)
+	}
+	return e
+}
+
+func (e *scalarExpander) description(index int32) string {
+	return e.planNodes[index].GetShortRepresentation().GetDescription()
+}
+
+// full returns the description of the scalar node with variable references
+// expanded recursively.
+func (e *scalarExpander) full(index int32) string {
+	return e.expand(e.description(index), map[int32]bool{index: true}, 0)
+}
+
+func (e *scalarExpander) expand(s string, expanding map[int32]bool, depth int) string {
+	if depth >= maxExpansionDepth {
+		return s
+	}
+	return variableRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		// Prefer the longest defined name: "$v2.Batch" may refer to "v2"
+		name := ref[1:]
+		for ; name != ""; name = name[:len(name)-1] {
+			index, ok := e.defs[name]
+			if !ok {
+				continue
+			}
+			if expanding[index] {
+				return ref
+			}
+			def := e.description(index)
+			if def == name {
+				// A plain column reference adds nothing
+				return ref
+			}
+			expanding[index] = true
+			expanded := e.expand(def, expanding, depth+1)
+			delete(expanding, index)
+			return expanded + ref[1+len(name):]
+		}
+		return ref
+	})
+}
+
+func checkScalarRepresentation(representation string) error {
+	switch representation {
+	case "", scalarRepresentationShort, scalarRepresentationFull, scalarRepresentationFootnote:
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid scalarRepresentation: %q (expected %q, %q, or %q)",
+		representation, scalarRepresentationShort, scalarRepresentationFull, scalarRepresentationFootnote)}
+}
+
+// applyScalarRepresentation returns planNodes with scalar descriptions in the
+// representation accepted by checkScalarRepresentation, and for the footnote
+// representation the footnote text to append to the output. Changed nodes are
+// copies; the input is not modified.
+func applyScalarRepresentation(planNodes []*sppb.PlanNode, representation string) ([]*sppb.PlanNode, string) {
+	if representation == "" || representation == scalarRepresentationShort {
+		return planNodes, ""
+	}
+
+	e := newScalarExpander(planNodes)
+	result := make([]*sppb.PlanNode, len(planNodes))
+	var footnotes []string
+	for i, node := range planNodes {
+		result[i] = node
+		if node.GetKind() != sppb.PlanNode_SCALAR {
+			continue
+		}
+		short := node.GetShortRepresentation().GetDescription()
+		full := e.full(node.GetIndex())
+		if full == short {
+			continue
+		}
+
+		clone := proto.Clone(node).(*sppb.PlanNode)
+		if representation == scalarRepresentationFull {
+			clone.ShortRepresentation.Description = full
+		} else {
+			footnotes = append(footnotes, fmt.Sprintf("[%d] %s", len(footnotes)+1, full))
+			clone.ShortRepresentation.Description = fmt.Sprintf("%s [%d]", short, len(footnotes))
+		}
+		result[i] = clone
+	}
+	if len(footnotes) == 0 {
+		return result, ""
+	}
+	return result, "Full expressions:\n" + strings.Join(footnotes, "\n") + "\n"
+}
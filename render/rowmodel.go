@@ -0,0 +1,154 @@
+package render
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// planRow is the renderer-independent row model of a plan: one row per
+// relational operator in pre-order, as handed to JS formatter plugins.
+type planRow struct {
+	ID          int32               `json:"id"`
+	ParentID    *int32              `json:"parentId,omitempty"`
+	Depth       int                 `json:"depth"`
+	DisplayName string              `json:"displayName"`
+	Title       string              `json:"title"`
+	Metadata    map[string]string   `json:"metadata,omitempty"`
+	Predicates  []planPredicate     `json:"predicates,omitempty"`
+	Stats       map[string]planStat `json:"stats,omitempty"`
+	// Annotation is the reviewer comment passed in the annotations option
+	Annotation string `json:"annotation,omitempty"`
+	// Collapsed is the number of operators hidden under the row by the
+	// collapsedNodeIds option
+	Collapsed int `json:"collapsed,omitempty"`
+}
+
+// planPredicate is a condition-like scalar child such as "Residual Condition".
+type planPredicate struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// planStat is the total of one execution stat, e.g. rows or latency, with its
+// distribution over executions if reported.
+type planStat struct {
+	Total string `json:"total"`
+	Unit  string `json:"unit,omitempty"`
+	// Value is Total as a number, in milliseconds for durations
+	Value        *float64          `json:"value,omitempty"`
+	Distribution *statDistribution `json:"distribution,omitempty"`
+}
+
+// isPredicateLink reports whether a scalar child link carries a predicate, in
+// the same sense as the "predicates" print section of spannerplan.
+func isPredicateLink(linkType string) bool {
+	return strings.HasSuffix(linkType, "Condition") || linkType == "Split Range"
+}
+
+// buildPlanRows flattens the relational operators of tree into rows.
+func buildPlanRows(tree *planTree) []planRow {
+	rows := make([]planRow, 0, len(tree.nodes))
+	tree.root.walk(func(n *treeNode) {
+		row := planRow{
+			ID:          n.id(),
+			Depth:       n.depth,
+			DisplayName: n.node.GetDisplayName(),
+			Title:       n.title(),
+		}
+		if n.parent != nil {
+			parentID := n.parent.id()
+			row.ParentID = &parentID
+		}
+
+		if fields := n.node.GetMetadata().GetFields(); len(fields) > 0 {
+			row.Metadata = make(map[string]string, len(fields))
+			for key, v := range fields {
+				row.Metadata[key] = valueString(v)
+			}
+		}
+
+		for _, c := range n.children {
+			if !c.node.isRelational() && isPredicateLink(c.link.GetType()) {
+				row.Predicates = append(row.Predicates, planPredicate{
+					Type:        c.link.GetType(),
+					Description: c.node.node.GetShortRepresentation().GetDescription(),
+				})
+			}
+		}
+
+		stats := n.node.GetExecutionStats().GetFields()
+		for name, v := range stats {
+			total, ok := v.GetStructValue().GetFields()["total"]
+			if !ok {
+				continue
+			}
+			if row.Stats == nil {
+				row.Stats = make(map[string]planStat, len(stats))
+			}
+			row.Stats[name] = planStat{Total: valueString(total), Unit: n.statUnit(name), Value: optional(n.statValue(name)), Distribution: n.distribution(name)}
+		}
+		rows = append(rows, row)
+	})
+	return rows
+}
+
+// Values accepted by the sortBy option
+const (
+	sortByLatency = "latency"
+	sortByRows    = "rows"
+	sortByID      = "id"
+)
+
+func checkSortBy(sortBy string) error {
+	switch sortBy {
+	case "", sortByLatency, sortByRows, sortByID:
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid sortBy: %q (expected %q, %q, or %q)",
+		sortBy, sortByLatency, sortByRows, sortByID)}
+}
+
+// sortKey returns the value rows are ordered by for latency and rows, with
+// latency in milliseconds.
+func (r planRow) sortKey(sortBy string) (float64, bool) {
+	stat, ok := r.Stats[sortBy]
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(stat.Total, 64)
+	if err != nil {
+		return 0, false
+	}
+	if sortBy == sortByLatency {
+		v = millis(v, stat.Unit)
+	}
+	return v, true
+}
+
+// sortPlanRows orders rows in place for triage: latency and rows descending
+// with rows lacking the stat last, id ascending. Ties keep ID order. The empty
+// sortBy keeps the pre-order of the tree.
+func sortPlanRows(rows []planRow, sortBy string) {
+	if sortBy == "" {
+		return
+	}
+	slices.SortStableFunc(rows, func(a, b planRow) int {
+		if sortBy != sortByID {
+			av, aok := a.sortKey(sortBy)
+			bv, bok := b.sortKey(sortBy)
+			switch {
+			case aok != bok:
+				if aok {
+					return -1
+				}
+				return 1
+			case av != bv:
+				return cmp.Compare(bv, av)
+			}
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
@@ -0,0 +1,127 @@
+package render
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// sampleFiles are the sample plans, one YAML capture per sample.
+//
+//go:embed samples/*.yaml
+var sampleFiles embed.FS
+
+// sampleDoc describes an embedded sample plan.
+type sampleDoc struct {
+	name        string
+	title       string
+	description string
+	// query is the statement the plan was captured for
+	query string
+}
+
+// sampleDocs lists the samples in the order the UI offers them. Each name
+// has a file samples/<name>.yaml.
+var sampleDocs = []sampleDoc{
+	{
+		name:        "simple-scan",
+		title:       "Simple scan",
+		description: "Profile of a full table scan with a residual filter",
+		query:       "SELECT SingerId, FirstName FROM Singers WHERE LastName = @last_name",
+	},
+	{
+		name:        "distributed-join",
+		title:       "Distributed join",
+		description: "Profile of a batched Distributed Cross Apply join of interleaved tables",
+		query:       "SELECT s.FirstName, a.AlbumTitle FROM Singers AS s JOIN Albums AS a ON s.SingerId = a.SingerId",
+	},
+	{
+		name:        "dml",
+		title:       "DML",
+		description: "Plan of an UPDATE applying mutations to the rows found by a key seek",
+		query:       "UPDATE Albums SET MarketingBudget = MarketingBudget + 1000 WHERE SingerId = 1",
+	},
+	{
+		name:        "graph-query",
+		title:       "Graph query",
+		description: "Plan of a Spanner Graph path query over node and edge tables",
+		query:       "GRAPH FinGraph MATCH (p:Person {id: 1})-[:Owns]->(a:Account) RETURN p.name, a.nick_name",
+	},
+}
+
+// SampleInfo is an entry of the listSamples result
+type SampleInfo struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Query       string `json:"query"`
+	// PlanNodes and HasExecutionStats are read from the sample, so that the
+	// UI can preselect PROFILE mode for profiles
+	PlanNodes         int  `json:"planNodes"`
+	HasExecutionStats bool `json:"hasExecutionStats"`
+}
+
+type getSampleParams struct {
+	Name string `json:"name"`
+}
+
+// readSample returns the text of the named sample.
+func readSample(name string) (string, error) {
+	if !slices.ContainsFunc(sampleDocs, func(d sampleDoc) bool { return d.name == name }) {
+		names := make([]string, len(sampleDocs))
+		for i, d := range sampleDocs {
+			names[i] = d.name
+		}
+		return "", InvalidParametersError{msg: fmt.Sprintf("Unknown sample: %q (samples are %s)", name, strings.Join(names, ", "))}
+	}
+	b, err := sampleFiles.ReadFile("samples/" + name + ".yaml")
+	if err != nil {
+		return "", RenderError{msg: fmt.Sprintf("Failed to read sample %q: %v", name, err)}
+	}
+	return string(b), nil
+}
+
+// listSamples returns the SampleInfo of every embedded sample as JSON
+func listSamples(string) (Response, error) {
+	infos := make([]SampleInfo, len(sampleDocs))
+	for i, d := range sampleDocs {
+		input, err := readSample(d.name)
+		if err != nil {
+			return Response{}, err
+		}
+		stats, _, err := extractQueryPlan(input)
+		if err != nil {
+			return Response{}, RenderError{msg: fmt.Sprintf("Failed to parse sample %q: %v", d.name, err)}
+		}
+		counts := countPlanNodes(stats.GetQueryPlan().GetPlanNodes())
+		infos[i] = SampleInfo{
+			Name:              d.name,
+			Title:             d.title,
+			Description:       d.description,
+			Query:             d.query,
+			PlanNodes:         counts.TotalNodes,
+			HasExecutionStats: counts.HasExecutionStats,
+		}
+	}
+	b, err := json.Marshal(infos)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal samples: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
+
+// getSample returns the YAML text of a sample, ready to use as the input of
+// the render functions
+func getSample(paramsJSON string) (Response, error) {
+	par := getSampleParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	input, err := readSample(par.Name)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Result: input}, nil
+}
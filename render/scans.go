@@ -0,0 +1,183 @@
+package render
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scan methods of ScanUsage, by the scan_type metadata of the scan
+const (
+	scanMethodTable = "table"
+	scanMethodIndex = "index"
+	scanMethodBatch = "batch"
+)
+
+// ScanUsage is one scan operator of a plan.
+type ScanUsage struct {
+	NodeID int32 `json:"nodeId"`
+	// Target is the scanned table or index, or the batch variable of a batch
+	// scan
+	Target string `json:"target"`
+	// Method is "table", "index", or "batch"
+	Method string `json:"method"`
+	// ScanMethod is the scan_method metadata, e.g. "Row" or "Batch"
+	ScanMethod string `json:"scanMethod,omitempty"`
+	FullScan   bool   `json:"fullScan,omitempty"`
+	// KeyRanges are the Seek Condition of the Filter Scan above the scan and
+	// the Split Range of the nearest distributed operator above it
+	KeyRanges []planPredicate `json:"keyRanges,omitempty"`
+	// Rows is the number of rows scanned, from the execution stats
+	Rows *float64 `json:"rows,omitempty"`
+	// Executions is the number of executions of the scan
+	Executions *float64 `json:"executions,omitempty"`
+}
+
+// TableScanSummary aggregates the scans of one table or index.
+type TableScanSummary struct {
+	Target string `json:"target"`
+	// Method is "table" or "index"
+	Method    string `json:"method"`
+	Scans     int    `json:"scans"`
+	FullScans int    `json:"fullScans"`
+	// Rows is the sum of the rows of the scans that report them
+	Rows *float64 `json:"rows,omitempty"`
+}
+
+// ScanReport is returned by extractScans
+type ScanReport struct {
+	// Scans are the scan operators in pre-order
+	Scans []ScanUsage `json:"scans"`
+	// Tables summarize the table and index scans by target and method,
+	// sorted by target; batch scans read rows of the plan, not of a table,
+	// and are not summarized
+	Tables []TableScanSummary `json:"tables"`
+}
+
+type extractScansParams struct {
+	Input   string `json:"input"`
+	Recover bool   `json:"recover,omitempty"`
+}
+
+// scanMethod returns the Method of ScanUsage for n, or "" if n is not a scan.
+// Scans are found by their scan_type metadata so that console naming gives
+// the same result.
+func scanMethod(n *treeNode) string {
+	scanType := valueString(n.node.GetMetadata().GetFields()["scan_type"])
+	switch {
+	case scanType == "":
+		return ""
+	case strings.EqualFold(scanType, "IndexScan"):
+		return scanMethodIndex
+	case strings.EqualFold(scanType, "BatchScan"):
+		return scanMethodBatch
+	default:
+		return scanMethodTable
+	}
+}
+
+// predicatesOf returns the scalar children of n with the link type typ.
+func predicatesOf(n *treeNode, typ string) []planPredicate {
+	var predicates []planPredicate
+	for _, c := range n.children {
+		if !c.node.isRelational() && c.link.GetType() == typ {
+			predicates = append(predicates, planPredicate{
+				Type:        typ,
+				Description: c.node.node.GetShortRepresentation().GetDescription(),
+			})
+		}
+	}
+	return predicates
+}
+
+// scanKeyRanges returns the KeyRanges of ScanUsage for the scan n.
+func scanKeyRanges(n *treeNode) []planPredicate {
+	var ranges []planPredicate
+	if p := n.parent; p != nil && strings.EqualFold(p.node.GetDisplayName(), "Filter Scan") {
+		ranges = append(ranges, predicatesOf(p, "Seek Condition")...)
+	}
+	for p := n.parent; p != nil; p = p.parent {
+		if isDistributedOperator(p) {
+			ranges = append(ranges, predicatesOf(p, "Split Range")...)
+			break
+		}
+	}
+	return ranges
+}
+
+// buildScanReport lists the scans of tree in pre-order and summarizes them by
+// table and index.
+func buildScanReport(tree *planTree) ScanReport {
+	report := ScanReport{Scans: []ScanUsage{}, Tables: []TableScanSummary{}}
+	type tableKey struct{ target, method string }
+	tables := make(map[tableKey]*TableScanSummary)
+	tree.root.walk(func(n *treeNode) {
+		method := scanMethod(n)
+		if method == "" {
+			return
+		}
+		fields := n.node.GetMetadata().GetFields()
+		scan := ScanUsage{
+			NodeID:     n.id(),
+			Target:     valueString(fields["scan_target"]),
+			Method:     method,
+			ScanMethod: valueString(fields["scan_method"]),
+			FullScan:   valueString(fields["Full scan"]) == "true",
+			KeyRanges:  scanKeyRanges(n),
+			Rows:       optional(n.stat("rows")),
+			Executions: optional(n.executions()),
+		}
+		report.Scans = append(report.Scans, scan)
+		if method == scanMethodBatch {
+			return
+		}
+
+		key := tableKey{scan.Target, method}
+		summary, ok := tables[key]
+		if !ok {
+			summary = &TableScanSummary{Target: scan.Target, Method: method}
+			tables[key] = summary
+		}
+		summary.Scans++
+		if scan.FullScan {
+			summary.FullScans++
+		}
+		if scan.Rows != nil {
+			rows := *scan.Rows
+			if summary.Rows != nil {
+				rows += *summary.Rows
+			}
+			summary.Rows = &rows
+		}
+	})
+	for _, summary := range tables {
+		report.Tables = append(report.Tables, *summary)
+	}
+	slices.SortFunc(report.Tables, func(a, b TableScanSummary) int {
+		return cmp.Or(cmp.Compare(a.Target, b.Target), cmp.Compare(a.Method, b.Method))
+	})
+	return report
+}
+
+// extractScans returns the scans of a plan and a summary per table as JSON
+func extractScans(paramsJSON string) (Response, error) {
+	par := extractScansParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return extractScansImpl(par)
+}
+
+func extractScansImpl(par extractScansParams) (Response, error) {
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	b, err := json.Marshal(buildScanReport(buildPlanTree(stats.GetQueryPlan().GetPlanNodes())))
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal scan report: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
@@ -0,0 +1,108 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Fields of a SearchMatch besides "metadata.<key>"
+const (
+	searchFieldDisplayName = "displayName"
+	searchFieldScanTarget  = "scanTarget"
+)
+
+type searchPlanParams struct {
+	Input string `json:"input"`
+	Query string `json:"query"`
+	// Regex matches Query as a case-insensitive regular expression instead
+	// of a substring
+	Regex   bool `json:"regex,omitempty"`
+	Recover bool `json:"recover,omitempty"`
+}
+
+// SearchMatch is a field of an operator that matched the query of searchPlan
+type SearchMatch struct {
+	NodeID int32 `json:"nodeId"`
+	// Field is "displayName", "scanTarget", or "metadata.<key>"
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// SearchResult is returned by searchPlan
+type SearchResult struct {
+	// Matches are in tree pre-order, then in field order
+	Matches []SearchMatch `json:"matches"`
+	// NodeIDs are the distinct operators in Matches
+	NodeIDs []int32 `json:"nodeIds"`
+}
+
+// compileSearchQuery returns a case-insensitive matcher of query.
+func compileSearchQuery(query string, regex bool) (func(string) bool, error) {
+	if query == "" {
+		return nil, InvalidParametersError{msg: "query is required"}
+	}
+	if !regex {
+		query = strings.ToLower(query)
+		return func(s string) bool { return strings.Contains(strings.ToLower(s), query) }, nil
+	}
+	re, err := regexp.Compile("(?i)" + query)
+	if err != nil {
+		return nil, InvalidParametersError{msg: fmt.Sprintf("Invalid query pattern %q: %v", query, err)}
+	}
+	return re.MatchString, nil
+}
+
+// searchTree matches the display name, scan target, and other metadata values
+// of the operators of tree.
+func searchTree(tree *planTree, match func(string) bool) SearchResult {
+	result := SearchResult{Matches: []SearchMatch{}, NodeIDs: []int32{}}
+	tree.root.walk(func(n *treeNode) {
+		var matches []SearchMatch
+		add := func(field, value string) {
+			if value != "" && match(value) {
+				matches = append(matches, SearchMatch{NodeID: n.id(), Field: field, Value: value})
+			}
+		}
+		add(searchFieldDisplayName, n.node.GetDisplayName())
+		fields := n.node.GetMetadata().GetFields()
+		add(searchFieldScanTarget, valueString(fields["scan_target"]))
+		for _, key := range sortedKeys(fields) {
+			if key != "scan_target" {
+				add("metadata."+key, valueString(fields[key]))
+			}
+		}
+		if len(matches) > 0 {
+			result.Matches = append(result.Matches, matches...)
+			result.NodeIDs = append(result.NodeIDs, n.id())
+		}
+	})
+	return result
+}
+
+// searchPlan finds the operators whose display name, scan target, or metadata
+// values contain the query, for find-in-plan boxes
+func searchPlan(paramsJSON string) (Response, error) {
+	par := searchPlanParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return searchPlanImpl(par)
+}
+
+func searchPlanImpl(par searchPlanParams) (Response, error) {
+	match, err := compileSearchQuery(par.Query, par.Regex)
+	if err != nil {
+		return Response{}, err
+	}
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	b, err := json.Marshal(searchTree(buildPlanTree(stats.GetQueryPlan().GetPlanNodes()), match))
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal search result: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
@@ -0,0 +1,252 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// selfTestTableFormats are the built-in table formats of renderASCII; the
+// diagram formats are added from the registered renderers.
+var selfTestTableFormats = []string{"CURRENT", "TRADITIONAL", "COMPACT"}
+
+// adversarialText mixes characters that need escaping in at least one output
+// format with Unicode that is easy to mishandle: combining marks, zero-width
+// and bidi controls, wide characters, and emoji sequences.
+const adversarialText = "\"quoted\" \\back\\slash |pipe| <tag> &amp; `tick` {brace} [bracket] #hash; " +
+	"e\u0301 zero\u200bwidth \u202etxet\u202c \u8868\u793a\u540d \U0001f980 \U0001f469\u200d\U0001f4bb \ufeff"
+
+// selfTestFixture is an adversarial plan embedded in the binary.
+type selfTestFixture struct {
+	name    string
+	input   string
+	recover bool
+	// wantError is the error type rendering must fail with, or "" if it must
+	// succeed
+	wantError string
+	// wantWarning is a warning code a successful render must report
+	wantWarning string
+}
+
+// SelfTestFailure is a fixture and format combination that misbehaved
+type SelfTestFailure struct {
+	Fixture string `json:"fixture"`
+	Format  string `json:"format"`
+	Message string `json:"message"`
+}
+
+// SelfTestReport is returned by selfTest
+type SelfTestReport struct {
+	// Cases is the number of fixture and format combinations run
+	Cases    int               `json:"cases"`
+	Failures []SelfTestFailure `json:"failures"`
+}
+
+type selfTestParams struct {
+	// Fixtures limits the run to the named fixtures
+	Fixtures []string `json:"fixtures,omitempty"`
+}
+
+func selfTestPlan(nodes ...map[string]any) string {
+	b, err := json.Marshal(map[string]any{"stats": map[string]any{"queryPlan": map[string]any{"planNodes": nodes}}})
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func selfTestNode(index int, kind, displayName string, metadata map[string]any, children ...int) map[string]any {
+	node := map[string]any{
+		"index":       index,
+		"kind":        kind,
+		"displayName": displayName,
+		"executionStats": map[string]any{
+			"rows":    map[string]any{"total": "42", "unit": "rows"},
+			"latency": map[string]any{"total": "1.5", "unit": "msecs"},
+		},
+	}
+	if metadata != nil {
+		node["metadata"] = metadata
+	}
+	var links []map[string]any
+	for _, child := range children {
+		links = append(links, map[string]any{"childIndex": child})
+	}
+	if links != nil {
+		node["childLinks"] = links
+	}
+	return node
+}
+
+// selfTestFixtures builds the fixtures on demand, so that their text is not
+// kept in memory between runs. The embedded samples are checked too.
+func selfTestFixtures() []selfTestFixture {
+	hugeMetadata := map[string]any{"scan_type": "TableScan", "scan_target": strings.Repeat("Wide", 1024)}
+	for i := range 200 {
+		hugeMetadata[fmt.Sprintf("key_%03d", i)] = strings.Repeat("v", 1024)
+	}
+
+	weirdScalar := selfTestNode(2, "SCALAR", "Function", nil)
+	weirdScalar["shortRepresentation"] = map[string]any{"description": adversarialText}
+	delete(weirdScalar, "executionStats")
+
+	cyclic := selfTestPlan(
+		selfTestNode(0, "RELATIONAL", "Distributed Union", nil, 1),
+		selfTestNode(1, "RELATIONAL", "Filter", nil, 0),
+	)
+
+	fixtures := []selfTestFixture{
+		{
+			name: "huge-metadata",
+			input: selfTestPlan(
+				selfTestNode(0, "RELATIONAL", "Distributed Union", map[string]any{"call_type": "Local"}, 1),
+				selfTestNode(1, "RELATIONAL", "Scan", hugeMetadata),
+			),
+		},
+		{
+			name: "weird-unicode",
+			input: selfTestPlan(
+				selfTestNode(0, "RELATIONAL", adversarialText, nil, 1, 2),
+				selfTestNode(1, "RELATIONAL", "Scan", map[string]any{"scan_type": "IndexScan", "scan_target": adversarialText}),
+				weirdScalar,
+			),
+		},
+		{name: "cyclic-links", input: cyclic, wantError: ErrorTypeInvalidSpannerFormat},
+		{name: "cyclic-links-recovered", input: cyclic, recover: true, wantWarning: WarningCodeDroppedChildLink},
+	}
+	// The samples must render in every format, as the UI offers them
+	for _, d := range sampleDocs {
+		input, _ := readSample(d.name)
+		fixtures = append(fixtures, selfTestFixture{name: "sample-" + d.name, input: input})
+	}
+	return fixtures
+}
+
+// plantUMLNodePattern matches the nodes of a PlantUML WBS emitted by
+// writeDiagram.
+var plantUMLNodePattern = regexp.MustCompile(`^\*+(?:\[#[0-9a-f]{6}\])? \d+: [^\n]*$`)
+
+// checkSelfTestOutput returns a description of the first problem found in
+// the output of format, or "" if it is well-formed.
+func checkSelfTestOutput(format, out string) string {
+	if !utf8.ValidString(out) {
+		return "output is not valid UTF-8"
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	syntax, diagram := lookupDiagramFormat(format)
+	// The DOT and MERMAID formats are rendered by spannerplanviz, whose
+	// statements are not checked line by line
+	switch {
+	case strings.EqualFold(format, diagramDOT.String()):
+		if !strings.Contains(out, "digraph {") {
+			return "DOT output is not a digraph"
+		}
+	case strings.EqualFold(format, diagramMermaid.String()):
+		if !strings.Contains(out, "graph TD") {
+			return "Mermaid output is not a top-down graph"
+		}
+	case diagram && syntax == diagramPlantUML:
+		if len(lines) < 2 || lines[0] != "@startwbs" || lines[len(lines)-1] != "@endwbs" {
+			return "PlantUML output is not a single WBS"
+		}
+		for i, line := range lines[1 : len(lines)-1] {
+			if !plantUMLNodePattern.MatchString(line) {
+				return fmt.Sprintf("line %d is not a well-formed PlantUML WBS node: %q", i+2, line)
+			}
+		}
+	default:
+		// The table ends at the first blank line or after its bottom border;
+		// appendices follow
+		for i, line := range lines {
+			if line == "" || i > 0 && strings.HasPrefix(lines[i-1], "+") && !strings.HasPrefix(line, "|") && !strings.HasPrefix(line, "+") {
+				break
+			}
+			if !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "|") {
+				return fmt.Sprintf("line %d breaks out of the table: %q", i+1, line)
+			}
+			if strings.ContainsFunc(line, unicode.IsControl) {
+				return fmt.Sprintf("line %d contains a control character: %q", i+1, line)
+			}
+		}
+	}
+	return ""
+}
+
+// runSelfTestCase renders fixture in format and returns a description of the
+// failure, or "".
+func runSelfTestCase(fixture selfTestFixture, format string) (failure string) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure = fmt.Sprintf("panicked: %v", r)
+		}
+	}()
+
+	resp, err := renderASCIIImpl(params{Input: fixture.input, Options: Options{Mode: "AUTO", Format: format, Recover: fixture.recover}})
+	switch {
+	case fixture.wantError != "" && err == nil:
+		return fmt.Sprintf("rendered, want %s error", fixture.wantError)
+	case fixture.wantError != "":
+		if got := classifyError(err); got != fixture.wantError {
+			return fmt.Sprintf("failed with %s error, want %s: %v", got, fixture.wantError, err)
+		}
+		return ""
+	case err != nil:
+		return fmt.Sprintf("failed: %v", err)
+	}
+	if fixture.wantWarning != "" && !slices.ContainsFunc(resp.Warnings, func(w Warning) bool { return w.Code == fixture.wantWarning }) {
+		return fmt.Sprintf("missing %s warning", fixture.wantWarning)
+	}
+	return checkSelfTestOutput(format, resp.Result)
+}
+
+// selfTest renders the embedded adversarial fixtures through every format and
+// reports the combinations that panic, fail unexpectedly, or produce
+// malformed output. Deployments run it to check the binary; users attach its
+// output to bug reports.
+func selfTest(paramsJSON string) (Response, error) {
+	par := selfTestParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return selfTestImpl(par)
+}
+
+func selfTestImpl(par selfTestParams) (Response, error) {
+	fixtures := selfTestFixtures()
+	if len(par.Fixtures) > 0 {
+		for _, name := range par.Fixtures {
+			if !slices.ContainsFunc(fixtures, func(f selfTestFixture) bool { return f.name == name }) {
+				return Response{}, InvalidParametersError{msg: fmt.Sprintf("Unknown self-test fixture: %q", name)}
+			}
+		}
+		fixtures = slices.DeleteFunc(fixtures, func(f selfTestFixture) bool { return !slices.Contains(par.Fixtures, f.name) })
+	}
+	formats := slices.Clone(selfTestTableFormats)
+	for _, name := range rendererNames {
+		if renderers[name].kind == formatKindDiagram {
+			formats = append(formats, name)
+		}
+	}
+
+	// Self-test renders are not user activity
+	defer usage.pause()()
+
+	report := SelfTestReport{Failures: []SelfTestFailure{}}
+	for _, fixture := range fixtures {
+		for _, format := range formats {
+			report.Cases++
+			if msg := runSelfTestCase(fixture, format); msg != "" {
+				report.Failures = append(report.Failures, SelfTestFailure{Fixture: fixture.name, Format: format, Message: msg})
+			}
+		}
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal self-test report: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
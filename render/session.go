@@ -0,0 +1,467 @@
+package render
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sessionBlobVersion is the format version of exportSession blobs.
+const sessionBlobVersion = 1
+
+// sessionPlanIDPrefix starts every plan handle, e.g. "plan-1".
+const sessionPlanIDPrefix = "plan-"
+
+// sessionPlan is a plan loaded into the session.
+type sessionPlan struct {
+	Input       string          `json:"input"`
+	Recover     bool            `json:"recover,omitempty"`
+	Fingerprint PlanFingerprint `json:"fingerprint"`
+	// Options are renderASCII parameters without input kept with the plan
+	Options json.RawMessage `json:"options,omitempty"`
+	// Labels are user-assigned tags such as "before index"
+	Labels []string `json:"labels,omitempty"`
+
+	// parsed is the parsed input, set on load and import so that renders
+	// do not parse it again
+	parsed *parsedPlan
+	// layout keeps the tree and the row model of the last render, so that
+	// renders in another format do not build them again
+	layout *layoutCache
+}
+
+// planSession holds the plans loaded with loadPlan for the lifetime of the
+// WASM instance, keyed by opaque handles. Like presets, the session is not
+// persisted by Go; exportSession and importSession carry it, together with
+// the presets, across page reloads.
+type planSession struct {
+	mu     sync.Mutex
+	nextID int
+	plans  map[string]*sessionPlan
+}
+
+var session = &planSession{nextID: 1, plans: make(map[string]*sessionPlan)}
+
+// sessionBlob is the decoded form of an exportSession blob.
+type sessionBlob struct {
+	Version int                        `json:"version"`
+	Plans   map[string]*sessionPlan    `json:"plans"`
+	Presets map[string]json.RawMessage `json:"presets,omitempty"`
+}
+
+// SessionPlanInfo describes a loaded plan without its input
+type SessionPlanInfo struct {
+	ID          string          `json:"id"`
+	Fingerprint PlanFingerprint `json:"fingerprint"`
+	Options     json.RawMessage `json:"options,omitempty"`
+	Labels      []string        `json:"labels,omitempty"`
+}
+
+type loadPlanParams struct {
+	Input   string `json:"input"`
+	Recover bool   `json:"recover,omitempty"`
+	// Options are renderASCII parameters without input to keep with the plan
+	Options json.RawMessage `json:"options,omitempty"`
+	Labels  []string        `json:"labels,omitempty"`
+}
+
+type releasePlanParams struct {
+	ID string `json:"id"`
+}
+
+type renderPlanParams struct {
+	ID string `json:"id"`
+	// Options are renderASCII parameters without input applied over those
+	// kept with the plan
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+type labelPlanParams struct {
+	ID string `json:"id"`
+	// Labels replace the labels of the plan; empty removes them
+	Labels []string `json:"labels"`
+}
+
+type importSessionParams struct {
+	Blob string `json:"blob"`
+	// Merge adds the plans of the blob to the session under new handles, and
+	// its presets to the presets, instead of replacing them
+	Merge bool `json:"merge,omitempty"`
+}
+
+func (s *planSession) add(plan *sessionPlan) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := sessionPlanIDPrefix + strconv.Itoa(s.nextID)
+	s.nextID++
+	s.plans[id] = plan
+	return id
+}
+
+// get returns a copy of the plan with handle id.
+func (s *planSession) get(id string) (sessionPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plan, ok := s.plans[id]
+	if !ok {
+		return sessionPlan{}, InvalidParametersError{msg: fmt.Sprintf("Unknown plan: %q", id)}
+	}
+	return *plan, nil
+}
+
+// clearLayouts drops the cached layouts of the plans.
+func (s *planSession) clearLayouts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, plan := range s.plans {
+		plan.layout.clear()
+	}
+}
+
+// setLabels replaces the labels of the plan with handle id.
+func (s *planSession) setLabels(id string, labels []string) (SessionPlanInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plan, ok := s.plans[id]
+	if !ok {
+		return SessionPlanInfo{}, InvalidParametersError{msg: fmt.Sprintf("Unknown plan: %q", id)}
+	}
+	// Replace rather than mutate the plan, which exports may still encode
+	updated := *plan
+	updated.Labels = labels
+	s.plans[id] = &updated
+	return updated.info(id), nil
+}
+
+func (s *planSession) release(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.plans[id]; !ok {
+		return InvalidParametersError{msg: fmt.Sprintf("Unknown plan: %q", id)}
+	}
+	delete(s.plans, id)
+	return nil
+}
+
+// infos lists the loaded plans in load order.
+func (s *planSession) infos() []SessionPlanInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := make([]SessionPlanInfo, 0, len(s.plans))
+	for id, plan := range s.plans {
+		infos = append(infos, plan.info(id))
+	}
+	slices.SortFunc(infos, func(a, b SessionPlanInfo) int {
+		return cmp.Compare(planIDNumber(a.ID), planIDNumber(b.ID))
+	})
+	return infos
+}
+
+func (p *sessionPlan) info(id string) SessionPlanInfo {
+	return SessionPlanInfo{ID: id, Fingerprint: p.Fingerprint, Options: p.Options, Labels: p.Labels}
+}
+
+// describe names the plan with handle id in comparison outputs, e.g.
+// "plan-1 [before index, prod 2024-06]".
+func (p *sessionPlan) describe(id string) string {
+	if len(p.Labels) == 0 {
+		return id
+	}
+	return fmt.Sprintf("%s [%s]", id, strings.Join(p.Labels, ", "))
+}
+
+// checkLabels trims labels and drops empty and duplicate ones.
+func checkLabels(labels []string) []string {
+	var checked []string
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label != "" && !slices.Contains(checked, label) {
+			checked = append(checked, label)
+		}
+	}
+	return checked
+}
+
+// planIDNumber returns the number of a plan handle, which orders plans by load
+// time.
+func planIDNumber(id string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(id, sessionPlanIDPrefix))
+	return n
+}
+
+// sortedPlanIDs returns the handles of plans in load order.
+func sortedPlanIDs(plans map[string]*sessionPlan) []string {
+	return slices.SortedFunc(maps.Keys(plans), func(a, b string) int {
+		return cmp.Compare(planIDNumber(a), planIDNumber(b))
+	})
+}
+
+// export encodes the session and presets as gzip-compressed, base64-encoded
+// JSON.
+func (s *planSession) export() (string, error) {
+	s.mu.Lock()
+	blob := sessionBlob{Version: sessionBlobVersion, Plans: maps.Clone(s.plans)}
+	s.mu.Unlock()
+	blob.Presets = presets.all()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(blob); err != nil {
+		return "", RenderError{msg: fmt.Sprintf("Failed to encode session: %v", err)}
+	}
+	if err := zw.Close(); err != nil {
+		return "", RenderError{msg: fmt.Sprintf("Failed to compress session: %v", err)}
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decodeSessionBlob reverses export.
+func decodeSessionBlob(encoded string) (sessionBlob, error) {
+	var blob sessionBlob
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return blob, ParseError{msg: fmt.Sprintf("Invalid session blob: %v", err)}
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return blob, ParseError{msg: fmt.Sprintf("Invalid session blob: %v", err)}
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return blob, ParseError{msg: fmt.Sprintf("Invalid session blob: %v", err)}
+	}
+	if err := json.Unmarshal(data, &blob); err != nil {
+		return blob, ParseError{msg: fmt.Sprintf("Invalid session blob: %v", err)}
+	}
+	if blob.Version != sessionBlobVersion {
+		return blob, InvalidParametersError{msg: fmt.Sprintf("Unsupported session blob version %d (expected %d)", blob.Version, sessionBlobVersion)}
+	}
+	for id, plan := range blob.Plans {
+		if plan == nil || !strings.HasPrefix(id, sessionPlanIDPrefix) {
+			return blob, ParseError{msg: fmt.Sprintf("Invalid session blob: bad plan entry %q", id)}
+		}
+	}
+	if err := blob.rehydrate(); err != nil {
+		return blob, err
+	}
+	return blob, nil
+}
+
+// rehydrate parses the inputs of the plans of blob and checks the options
+// kept with them and the presets, so that a blob that a newer or older build
+// exported, or that was edited, fails to import as a whole rather than plan
+// by plan on render. Plans are checked in handle order, so that the error is
+// about the first bad plan.
+func (blob sessionBlob) rehydrate() error {
+	for _, id := range sortedPlanIDs(blob.Plans) {
+		plan := blob.Plans[id]
+		stats, rowType, format, err := extractQueryPlanFormat(plan.Input)
+		if err != nil {
+			return ParseError{msg: fmt.Sprintf("Invalid session blob: plan %q: %v", id, err), cause: err}
+		}
+		if len(plan.Options) > 0 {
+			if err := checkRenderOptions("Plan", plan.Options); err != nil {
+				return InvalidParametersError{msg: fmt.Sprintf("Invalid session blob: plan %q: %v", id, err)}
+			}
+		}
+		plan.parsed = &parsedPlan{stats: stats, rowType: rowType, format: format}
+		plan.layout = &layoutCache{}
+	}
+	for _, name := range slices.Sorted(maps.Keys(blob.Presets)) {
+		if err := checkRenderOptions("Preset", blob.Presets[name]); err != nil {
+			return InvalidParametersError{msg: fmt.Sprintf("Invalid session blob: preset %q: %v", name, err)}
+		}
+	}
+	return nil
+}
+
+// replace makes blob the current session, keeping its handles valid.
+func (s *planSession) replace(blob sessionBlob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plans = make(map[string]*sessionPlan, len(blob.Plans))
+	s.nextID = 1
+	for id, plan := range blob.Plans {
+		s.plans[id] = plan
+		s.nextID = max(s.nextID, planIDNumber(id)+1)
+	}
+}
+
+// merge adds the plans of blob under new handles, in the order of their
+// handles in blob.
+func (s *planSession) merge(blob sessionBlob) {
+	for _, id := range sortedPlanIDs(blob.Plans) {
+		s.add(blob.Plans[id])
+	}
+}
+
+func marshalSessionInfos() (Response, error) {
+	b, err := json.Marshal(session.infos())
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal session plans: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
+
+// loadPlan validates a plan, adds it to the session, and returns its
+// SessionPlanInfo, whose ID is the plan's handle
+func loadPlan(paramsJSON string) (Response, error) {
+	par := loadPlanParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return loadPlanImpl(par)
+}
+
+func loadPlanImpl(par loadPlanParams) (Response, error) {
+	var options json.RawMessage
+	if len(par.Options) > 0 && string(par.Options) != "null" {
+		var err error
+		if options, err = compactOptions("Plan", par.Options); err != nil {
+			return Response{}, err
+		}
+	}
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	// stats has recover applied, which renders redo, so keep the parsed input
+	parsedStats, rowType, format, err := extractQueryPlanFormat(par.Input)
+	if err != nil {
+		return Response{}, extractError(err)
+	}
+
+	plan := &sessionPlan{
+		Input:       par.Input,
+		Recover:     par.Recover,
+		Fingerprint: planFingerprint(stats),
+		Options:     options,
+		Labels:      checkLabels(par.Labels),
+		parsed:      &parsedPlan{stats: parsedStats, rowType: rowType, format: format},
+		layout:      &layoutCache{},
+	}
+	id := session.add(plan)
+	return marshalSessionInfo(plan.info(id), warnings)
+}
+
+func marshalSessionInfo(info SessionPlanInfo, warnings []Warning) (Response, error) {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal session plan: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
+
+// renderPlan renders a loaded plan with renderASCII without parsing its input
+// again, so that re-rendering a large plan with other options is fast. The
+// options kept with the plan apply first, then those given.
+func renderPlan(paramsJSON string) (Response, error) {
+	par := renderPlanParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return renderPlanImpl(par)
+}
+
+func renderPlanImpl(par renderPlanParams) (Response, error) {
+	if len(par.Options) > 0 {
+		if err := checkRenderOptions("Render", par.Options); err != nil {
+			return Response{}, err
+		}
+	}
+	plan, err := session.get(par.ID)
+	if err != nil {
+		return Response{}, err
+	}
+
+	render := params{}
+	for _, options := range []json.RawMessage{plan.Options, par.Options} {
+		if len(options) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(options, &render); err != nil {
+			return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+		}
+		render.noteUnknownOptions(options, render)
+	}
+	render.Input = plan.Input
+	render.Recover = render.Recover || plan.Recover
+	render.parsed = plan.parsed
+	render.layout = plan.layout
+	resp, err := renderASCIIImpl(render)
+	if err != nil {
+		return Response{}, err
+	}
+	return render.withUnknownOptions(resp), nil
+}
+
+// labelPlan replaces the labels of a loaded plan, which name it in
+// comparison outputs and travel with exports, and returns its
+// SessionPlanInfo
+func labelPlan(paramsJSON string) (Response, error) {
+	par := labelPlanParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	info, err := session.setLabels(par.ID, checkLabels(par.Labels))
+	if err != nil {
+		return Response{}, err
+	}
+	return marshalSessionInfo(info, nil)
+}
+
+// releasePlan removes a plan from the session and returns the remaining
+// plans
+func releasePlan(paramsJSON string) (Response, error) {
+	par := releasePlanParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	if err := session.release(par.ID); err != nil {
+		return Response{}, err
+	}
+	return marshalSessionInfos()
+}
+
+// exportSession returns the loaded plans and the presets as a single
+// compressed, base64-encoded blob
+func exportSession(string) (Response, error) {
+	blob, err := session.export()
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Result: blob}, nil
+}
+
+// importSession replaces the loaded plans and the presets with those of an
+// exportSession blob and returns the restored plans. Handles from the
+// exporting session stay valid. With merge, the plans of the blob are added
+// under new handles, and its presets replace only those of the same name, so
+// that a session shared by a teammate opens next to the current one.
+func importSession(paramsJSON string) (Response, error) {
+	par := importSessionParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	blob, err := decodeSessionBlob(par.Blob)
+	if err != nil {
+		return Response{}, err
+	}
+	if par.Merge {
+		session.merge(blob)
+		presets.merge(blob.Presets)
+	} else {
+		session.replace(blob)
+		presets.replace(blob.Presets)
+	}
+	return marshalSessionInfos()
+}
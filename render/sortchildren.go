@@ -0,0 +1,81 @@
+package render
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// Values accepted by the sortChildrenBy option besides sortByLatency and
+// sortByRows
+const (
+	sortChildrenByCPU  = "cpu"
+	sortChildrenByNone = "none"
+)
+
+func checkSortChildrenBy(by string) error {
+	switch by {
+	case "", sortByLatency, sortByRows, sortChildrenByCPU, sortChildrenByNone:
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid sortChildrenBy: %q (expected %q, %q, %q, or %q)",
+		by, sortByLatency, sortByRows, sortChildrenByCPU, sortChildrenByNone)}
+}
+
+// sortChildLinks returns planNodes with the relational children of each
+// operator in descending order of the metric, so that the most expensive
+// branch comes first. Children without the stat go last and ties keep their
+// order. Scalar child links keep their positions. Latency and CPU time are the
+// totals of the children's subtrees. Changed nodes are shallow copies.
+func sortChildLinks(planNodes []*sppb.PlanNode, by string) []*sppb.PlanNode {
+	if by == "" || by == sortChildrenByNone {
+		return planNodes
+	}
+	tree := buildPlanTree(planNodes)
+	metric := func(l *sppb.PlanNode_ChildLink) (float64, bool) {
+		n := tree.nodes[l.GetChildIndex()]
+		switch by {
+		case sortByLatency:
+			return n.durationMillis("latency")
+		case sortChildrenByCPU:
+			return n.durationMillis("cpu_time")
+		default:
+			return n.stat("rows")
+		}
+	}
+
+	sorted := slices.Clone(planNodes)
+	for i, node := range planNodes {
+		var positions []int
+		var relational []*sppb.PlanNode_ChildLink
+		for j, l := range node.GetChildLinks() {
+			if int(l.GetChildIndex()) < len(planNodes) && planNodes[l.GetChildIndex()].GetKind() == sppb.PlanNode_RELATIONAL {
+				positions = append(positions, j)
+				relational = append(relational, l)
+			}
+		}
+		ordered := slices.Clone(relational)
+		slices.SortStableFunc(ordered, func(a, b *sppb.PlanNode_ChildLink) int {
+			av, aok := metric(a)
+			bv, bok := metric(b)
+			if aok != bok {
+				if aok {
+					return -1
+				}
+				return 1
+			}
+			return cmp.Compare(bv, av)
+		})
+		if slices.Equal(ordered, relational) {
+			continue
+		}
+		links := slices.Clone(node.GetChildLinks())
+		for k, j := range positions {
+			links[j] = ordered[k]
+		}
+		sorted[i] = withChildLinks(node, links)
+	}
+	return sorted
+}
@@ -0,0 +1,58 @@
+package render
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// formatSpannerCLI is the renderASCII format that renders the TRADITIONAL
+// table with the headers and alignment of spanner-cli, so that plans can be
+// compared with those captured by its EXPLAIN and EXPLAIN ANALYZE.
+const formatSpannerCLI = "SPANNER-CLI"
+
+func isSpannerCLIFormat(format string) bool {
+	return strings.EqualFold(format, formatSpannerCLI)
+}
+
+// spannerCLITitles are the spanner-cli headers of the table columns.
+// spanner-cli marks the operator column experimental for EXPLAIN only.
+var spannerCLITitles = map[string]string{
+	"Rows":          "Rows_Returned",
+	"Exec.":         "Executions",
+	"Total Latency": "Total_Latency",
+}
+
+// applySpannerCLILayout renames the columns of the table at the start of
+// rendered to the headers of spanner-cli and left-aligns the execution stat
+// columns, as spanner-cli does. analyze selects the headers of EXPLAIN
+// ANALYZE, which renders the execution stats.
+func applySpannerCLILayout(rendered string, analyze bool) string {
+	return relayColumns(rendered, func(title string, width int) cellLayout {
+		newTitle, ok := spannerCLITitles[title]
+		operator := title == "Operator"
+		switch {
+		case operator && analyze:
+			newTitle = "Query_Execution_Plan"
+		case operator:
+			newTitle = "Query_Execution_Plan (EXPERIMENTAL)"
+		case !ok:
+			return nil
+		}
+		width = max(width, utf8.RuneCountInString(newTitle))
+		return func(cell string, line tableLine) string {
+			var text string
+			switch {
+			case line.border:
+				return strings.Repeat("-", width+2)
+			case line.header:
+				text = newTitle
+			case operator:
+				// Keep the tree connectors at the start of the cell
+				text = strings.TrimRight(strings.TrimPrefix(cell, " "), " ")
+			default:
+				text = strings.TrimSpace(cell)
+			}
+			return " " + text + strings.Repeat(" ", width-utf8.RuneCountInString(text)) + " "
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// latencyBarColumnTitle is the header of the latency bar column.
+const latencyBarColumnTitle = "Latency Share"
+
+// latencyBarWidth is the width of a full bar in characters.
+const latencyBarWidth = 8
+
+// barEighths are the block characters for 1/8 to 8/8 of a character cell.
+var barEighths = []rune("▏▎▍▌▋▊▉█")
+
+// latencyBar draws share, between 0 and 1, as a bar of latencyBarWidth
+// characters with 1/8 character resolution. Any non-zero share gets at least
+// the thinnest bar so that it is not mistaken for no latency.
+func latencyBar(share float64) string {
+	eighths := int(math.Round(share * latencyBarWidth * 8))
+	if eighths == 0 && share > 0 {
+		eighths = 1
+	}
+	bar := strings.Repeat(string(barEighths[7]), eighths/8)
+	if rem := eighths % 8; rem > 0 {
+		bar += string(barEighths[rem-1])
+	}
+	return bar + strings.Repeat(" ", latencyBarWidth-len([]rune(bar)))
+}
+
+// applyLatencyBarColumn appends a column with a bar proportional to each
+// operator's latency relative to the slowest operator, usually the root, for
+// an at-a-glance view of hotspots. Plans without latency stats are left
+// unchanged.
+func applyLatencyBarColumn(rendered string, tree *planTree) string {
+	latencies := make(map[int32]float64)
+	slowest := 0.0
+	tree.root.walk(func(n *treeNode) {
+		if ms, ok := n.durationMillis("latency"); ok {
+			latencies[n.id()] = ms
+			slowest = max(slowest, ms)
+		}
+	})
+	if len(latencies) == 0 {
+		return rendered
+	}
+
+	cells := make(map[int32]string, len(latencies))
+	for id, ms := range latencies {
+		share := 0.0
+		if slowest > 0 {
+			share = ms / slowest
+		}
+		cells[id] = fmt.Sprintf("%s %3.0f%%", latencyBar(share), share*100)
+	}
+	return appendTableColumn(rendered, latencyBarColumnTitle, cells)
+}
@@ -0,0 +1,98 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// builtinStatsFields are the execution stats that the table has columns for
+// already: Rows, Total Latency, and CPU.
+var builtinStatsFields = []string{"rows", "latency", "cpu_time"}
+
+// checkStatsFields validates the statsFields option. Fields are raw stat keys
+// such as "remote_calls" and must not be empty, repeated, or shown by a
+// built-in column.
+func checkStatsFields(fields []string) error {
+	for i, field := range fields {
+		switch {
+		case strings.TrimSpace(field) == "":
+			return InvalidParametersError{msg: fmt.Sprintf("Stats field %d is empty", i)}
+		case slices.Contains(builtinStatsFields, field):
+			return InvalidParametersError{msg: fmt.Sprintf("Stats field %q is already a column", field)}
+		case slices.Contains(fields[:i], field):
+			return InvalidParametersError{msg: fmt.Sprintf("Stats field %q is selected more than once", field)}
+		}
+	}
+	return nil
+}
+
+// discoverStatsFields returns the keys of the execution stats with a numeric
+// total that any operator has, sorted. Stats without a total, such as
+// execution_summary, are not columns.
+func discoverStatsFields(planNodes []*sppb.PlanNode) []string {
+	seen := make(map[string]bool)
+	for _, node := range planNodes {
+		for key, v := range node.GetExecutionStats().GetFields() {
+			total, ok := v.GetStructValue().GetFields()["total"]
+			if !ok || seen[key] {
+				continue
+			}
+			if _, err := strconv.ParseFloat(valueString(total), 64); err == nil {
+				seen[key] = true
+			}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// statsColumnFields returns the stats to add as columns: the fields of the
+// statsFields option in their order, followed with experimentalStats by the
+// other stats of the plan that no built-in column shows. Fields the plan does
+// not have are reported and skipped.
+func statsColumnFields(discovered []string, fields []string, experimental bool) ([]string, []Warning) {
+	var columns []string
+	var warnings []Warning
+	for _, field := range fields {
+		if !slices.Contains(discovered, field) {
+			warnings = append(warnings, Warning{
+				Code:    WarningCodeColumnUnavailable,
+				Message: fmt.Sprintf("No operator has the execution stat %q", field),
+			})
+			continue
+		}
+		columns = append(columns, field)
+	}
+	if experimental {
+		for _, field := range discovered {
+			if !slices.Contains(builtinStatsFields, field) && !slices.Contains(columns, field) {
+				columns = append(columns, field)
+			}
+		}
+	}
+	return columns, warnings
+}
+
+// applyStatsColumns appends a column per stats field, titled with the stat
+// key, with the total and unit of the stat of each operator. Totals are
+// given as in the input, or formatted like the other stat columns if
+// canonical is set for the deterministic option.
+func applyStatsColumns(rendered string, tree *planTree, fields []string, canonical bool) string {
+	for _, field := range fields {
+		cells := make(map[int32]string)
+		tree.root.walk(func(n *treeNode) {
+			if v, ok := n.stat(field); ok {
+				total := valueString(n.node.GetExecutionStats().GetFields()[field].GetStructValue().GetFields()["total"])
+				if canonical {
+					total = formatDecimal(v, 3)
+				}
+				cells[n.id()] = strings.TrimSpace(total + " " + n.statUnit(field))
+			}
+		})
+		rendered = appendTableColumn(rendered, field, cells)
+	}
+	return rendered
+}
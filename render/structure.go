@@ -0,0 +1,228 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Kinds of StructureEdit
+const (
+	editUnchanged = "unchanged"
+	editChanged   = "changed"
+	editAdded     = "added"
+	editRemoved   = "removed"
+	editMoved     = "moved"
+)
+
+// StructureDiff is returned by diffPlanStructure.
+type StructureDiff struct {
+	// Edits are the operators of both plans in pre-order of the merged tree
+	Edits  []StructureEdit `json:"edits"`
+	Counts EditCounts      `json:"counts"`
+}
+
+// EditCounts counts the edits of a StructureDiff by kind.
+type EditCounts struct {
+	Unchanged int `json:"unchanged"`
+	Changed   int `json:"changed"`
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Moved     int `json:"moved"`
+}
+
+// StructureEdit is an operator of the merged tree of two plans.
+type StructureEdit struct {
+	// Kind is "unchanged", "changed" (aligned, with another title), "added",
+	// "removed", or "moved" (in both plans, at another place in the tree)
+	Kind string `json:"kind"`
+	// BeforeID and AfterID are the node IDs in each plan; the side an
+	// operator is not in is omitted
+	BeforeID *int32 `json:"beforeId,omitempty"`
+	AfterID  *int32 `json:"afterId,omitempty"`
+	// BeforeParentID and AfterParentID are the parents of moved operators
+	BeforeParentID *int32 `json:"beforeParentId,omitempty"`
+	AfterParentID  *int32 `json:"afterParentId,omitempty"`
+	// Operator is the qualified operator name, of the after plan when the
+	// operator is in both
+	Operator       string `json:"operator"`
+	BeforeOperator string `json:"beforeOperator,omitempty"`
+	ScanTarget     string `json:"scanTarget,omitempty"`
+	// BeforeScanTarget is set when the scan target changed
+	BeforeScanTarget  string `json:"beforeScanTarget,omitempty"`
+	OperatorChanged   bool   `json:"operatorChanged,omitempty"`
+	ScanTargetChanged bool   `json:"scanTargetChanged,omitempty"`
+	// Stats are the execution stats either side has
+	Stats []StatDelta `json:"stats,omitempty"`
+}
+
+// StatDelta is an execution stat of an operator in the before and after
+// plans. Durations are in milliseconds.
+type StatDelta struct {
+	// Name is "rows", "latency", or "cpuTime"
+	Name   string   `json:"name"`
+	Before *float64 `json:"before,omitempty"`
+	After  *float64 `json:"after,omitempty"`
+	// Delta is After - Before, when both are set
+	Delta *float64 `json:"delta,omitempty"`
+}
+
+// structureStats are the stats of StructureEdit.Stats.
+var structureStats = []struct {
+	name  string
+	value func(*treeNode) (float64, bool)
+}{
+	{"rows", func(n *treeNode) (float64, bool) { return n.stat("rows") }},
+	{"latency", func(n *treeNode) (float64, bool) { return n.durationMillis("latency") }},
+	{"cpuTime", func(n *treeNode) (float64, bool) { return n.durationMillis("cpu_time") }},
+}
+
+func scanTarget(n *treeNode) string {
+	return valueString(n.node.GetMetadata().GetFields()["scan_target"])
+}
+
+// structureEdit returns the edit of the operators before and after, either
+// of which may be nil.
+func structureEdit(kind string, before, after *treeNode) StructureEdit {
+	e := StructureEdit{Kind: kind}
+	if before != nil {
+		id := before.id()
+		e.BeforeID = &id
+		e.Operator, e.ScanTarget = before.operatorName(), scanTarget(before)
+	}
+	if after != nil {
+		id := after.id()
+		e.AfterID = &id
+		if before != nil {
+			e.OperatorChanged = e.Operator != after.operatorName()
+			e.ScanTargetChanged = e.ScanTarget != scanTarget(after)
+			if e.OperatorChanged {
+				e.BeforeOperator = e.Operator
+			}
+			if e.ScanTargetChanged {
+				e.BeforeScanTarget = e.ScanTarget
+			}
+		}
+		e.Operator, e.ScanTarget = after.operatorName(), scanTarget(after)
+	}
+	if kind == editMoved {
+		if before.parent != nil {
+			id := before.parent.id()
+			e.BeforeParentID = &id
+		}
+		if after.parent != nil {
+			id := after.parent.id()
+			e.AfterParentID = &id
+		}
+	}
+
+	for _, s := range structureStats {
+		d := StatDelta{Name: s.name}
+		if before != nil {
+			if v, ok := s.value(before); ok {
+				d.Before = &v
+			}
+		}
+		if after != nil {
+			if v, ok := s.value(after); ok {
+				d.After = &v
+			}
+		}
+		if d.Before != nil && d.After != nil {
+			delta := *d.After - *d.Before
+			d.Delta = &delta
+		}
+		if d.Before != nil || d.After != nil {
+			e.Stats = append(e.Stats, d)
+		}
+	}
+	return e
+}
+
+// structureEdits flattens the merged tree of alignPlans into edits. Removed
+// and added operators with the same title are paired as moved, in pre-order.
+func structureEdits(root *planDiffNode) []StructureEdit {
+	var nodes []*planDiffNode
+	var walk func(d *planDiffNode)
+	walk = func(d *planDiffNode) {
+		nodes = append(nodes, d)
+		for _, child := range d.children {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	// movedTo pairs removed nodes with the added nodes they moved to
+	movedTo := make(map[*planDiffNode]*treeNode)
+	movedFrom := make(map[*planDiffNode]bool)
+	for _, r := range nodes {
+		if r.marker != diffRemoved {
+			continue
+		}
+		for _, a := range nodes {
+			if a.marker == diffAdded && !movedFrom[a] && a.after.title() == r.before.title() {
+				movedTo[r] = a.after
+				movedFrom[a] = true
+				break
+			}
+		}
+	}
+
+	var edits []StructureEdit
+	for _, d := range nodes {
+		switch {
+		case movedFrom[d]:
+			// Reported at the place it moved from
+		case movedTo[d] != nil:
+			edits = append(edits, structureEdit(editMoved, d.before, movedTo[d]))
+		case d.marker == diffAdded:
+			edits = append(edits, structureEdit(editAdded, nil, d.after))
+		case d.marker == diffRemoved:
+			edits = append(edits, structureEdit(editRemoved, d.before, nil))
+		case d.marker == diffChanged:
+			edits = append(edits, structureEdit(editChanged, d.before, d.after))
+		default:
+			edits = append(edits, structureEdit(editUnchanged, d.before, d.after))
+		}
+	}
+	return edits
+}
+
+// diffPlanStructure returns the edit script between two plans as JSON, for
+// UIs that show them side by side. Plans are given as inputs or as loadPlan
+// handles, like to diffPlans.
+func diffPlanStructure(paramsJSON string) (Response, error) {
+	par := diffParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return diffPlanStructureImpl(par)
+}
+
+func diffPlanStructureImpl(par diffParams) (Response, error) {
+	_, trees, warnings, err := loadDiffTrees(par)
+	if err != nil {
+		return Response{}, err
+	}
+
+	result := StructureDiff{Edits: structureEdits(alignPlans(trees[0].root, trees[1].root))}
+	for _, e := range result.Edits {
+		switch e.Kind {
+		case editUnchanged:
+			result.Counts.Unchanged++
+		case editChanged:
+			result.Counts.Changed++
+		case editAdded:
+			result.Counts.Added++
+		case editRemoved:
+			result.Counts.Removed++
+		case editMoved:
+			result.Counts.Moved++
+		}
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal structure diff: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
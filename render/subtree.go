@@ -0,0 +1,116 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// treePrefixWidth is the width of the tree drawing prefix per depth in the
+// operator cells of rendered tables, e.g. "+- " or "|  ".
+const treePrefixWidth = 3
+
+// subtreeRoot returns the operator rendered as the root with the rootNodeId
+// option. It must be a relational node reachable from the plan root.
+func subtreeRoot(tree *planTree, id int32) (*treeNode, error) {
+	if id < 0 || int(id) >= len(tree.nodes) {
+		return nil, InvalidParametersError{msg: fmt.Sprintf("Invalid rootNodeId: %d (the plan has nodes 0 to %d)", id, len(tree.nodes)-1)}
+	}
+	n := tree.nodes[id]
+	if !n.isRelational() {
+		return nil, InvalidParametersError{msg: fmt.Sprintf("Invalid rootNodeId: node %d is a scalar expression, not an operator", id)}
+	}
+	if n.parent == nil && n != tree.root {
+		return nil, InvalidParametersError{msg: fmt.Sprintf("Invalid rootNodeId: node %d is not reachable from the root", id)}
+	}
+	return n, nil
+}
+
+// subtreeIDs returns the IDs of root and its relational descendants.
+func subtreeIDs(root *treeNode) map[int32]bool {
+	ids := make(map[int32]bool)
+	root.walk(func(n *treeNode) { ids[n.id()] = true })
+	return ids
+}
+
+// inSubtree reports whether n is root or one of its descendants, scalar
+// nodes included.
+func inSubtree(n, root *treeNode) bool {
+	for p := n; p != nil; p = p.parent {
+		if p == root {
+			return true
+		}
+	}
+	return false
+}
+
+// breadcrumbText returns the path from the plan root to the parent of n,
+// e.g. "Path: 0 Distributed Union > 1 Local Distributed Union\n", or "" for
+// the plan root.
+func breadcrumbText(n *treeNode) string {
+	var path []string
+	for p := n.parent; p != nil; p = p.parent {
+		path = append(path, strconv.Itoa(int(p.id()))+" "+p.operatorName())
+	}
+	if len(path) == 0 {
+		return ""
+	}
+	slices.Reverse(path)
+	return "Path: " + strings.Join(path, " > ") + "\n"
+}
+
+// rerootRows keeps the rows whose IDs are in keep, with depths relative to a
+// subtree root at depth.
+func rerootRows(rows []planRow, keep map[int32]bool, depth int) []planRow {
+	rows = slices.DeleteFunc(rows, func(r planRow) bool { return !keep[r.ID] })
+	for i := range rows {
+		rows[i].Depth -= depth
+	}
+	return rows
+}
+
+// rerootTableRows keeps the operator rows of the table in rendered whose IDs
+// are in keep and removes depth levels of tree drawing from their operator
+// cells, so that the subtree is drawn from its root. Cells are padded back
+// to the column width; lines whose cell does not start with tree drawing
+// are left unchanged.
+func rerootTableRows(rendered string, keep map[int32]bool, depth int) string {
+	rendered = filterTableRows(rendered, keep)
+	if depth == 0 {
+		return rendered
+	}
+	table, ok := splitRenderedTable(rendered)
+	if !ok {
+		return rendered
+	}
+	// The header border marks the column boundaries; the operator column is
+	// the second
+	var bounds []int
+	for i, r := range []rune(table.head[len(table.head)-1]) {
+		if r == '+' {
+			bounds = append(bounds, i)
+		}
+	}
+	if len(bounds) < 3 {
+		return rendered
+	}
+	start, end := bounds[1]+2, bounds[2]
+	width := depth * treePrefixWidth
+
+	lines := slices.Clone(table.head)
+	for _, row := range table.rows {
+		for _, line := range row.lines {
+			runes := []rune(line)
+			if len(runes) <= end || runes[end] != '|' || end-start < width ||
+				strings.Trim(string(runes[start:start+width]), " |+-") != "" {
+				lines = append(lines, line)
+				continue
+			}
+			cell := slices.Concat(runes[start+width:end], []rune(strings.Repeat(" ", width)))
+			lines = append(lines, string(runes[:start])+string(cell)+string(runes[end:]))
+		}
+	}
+	lines = append(lines, table.tail...)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,232 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// PlanSummary is returned by summarizePlan
+type PlanSummary struct {
+	Counts PlanCounts `json:"counts"`
+	// Depth is the number of levels of the operator tree
+	Depth int `json:"depth"`
+	// OperatorsPerDepth counts the operators, the rows of a table render, at
+	// each depth; index 0 is the root
+	OperatorsPerDepth []int `json:"operatorsPerDepth"`
+	// WidestDepth is the shallowest depth with the most operators
+	WidestDepth int `json:"widestDepth"`
+	// WidestOperators is the number of operators at WidestDepth
+	WidestOperators int `json:"widestOperators"`
+	// QueryStats are the totals of the query stats, if the input has them
+	QueryStats *QueryTotals `json:"queryStats,omitempty"`
+	// OperatorTypes aggregates the operators by type, in the order of
+	// operatorTypes, skipping types without operators
+	OperatorTypes []OperatorTypeStats `json:"operatorTypes"`
+	// DML describes the mutations of DML plans
+	DML *DMLSummary `json:"dml,omitempty"`
+	// Optimizer is the optimizer settings of PROFILE captures
+	Optimizer *OptimizerInfo `json:"optimizer,omitempty"`
+}
+
+// QueryTotals are the query-wide stats of a PROFILE capture. Fields missing
+// from the input are omitted.
+type QueryTotals struct {
+	ElapsedMillis              *float64 `json:"elapsedMillis,omitempty"`
+	CPUMillis                  *float64 `json:"cpuMillis,omitempty"`
+	RowsReturned               *float64 `json:"rowsReturned,omitempty"`
+	RowsScanned                *float64 `json:"rowsScanned,omitempty"`
+	BytesReturned              *float64 `json:"bytesReturned,omitempty"`
+	OptimizerVersion           string   `json:"optimizerVersion,omitempty"`
+	OptimizerStatisticsPackage string   `json:"optimizerStatisticsPackage,omitempty"`
+}
+
+// OperatorTypeStats aggregates the operators of one type. The times are the
+// sums of self times, which exclude the time of relational inputs, and are
+// omitted without execution stats.
+type OperatorTypeStats struct {
+	Type          string   `json:"type"`
+	Operators     int      `json:"operators"`
+	LatencyMillis *float64 `json:"latencyMillis,omitempty"`
+	CPUMillis     *float64 `json:"cpuMillis,omitempty"`
+}
+
+// Operator types of OperatorTypeStats
+const (
+	operatorTypeScan         = "scan"
+	operatorTypeJoin         = "join"
+	operatorTypeDistribution = "distribution"
+	operatorTypeAggregate    = "aggregate"
+	operatorTypeSort         = "sort"
+	operatorTypeDML          = "dml"
+	operatorTypeOther        = "other"
+)
+
+var operatorTypes = []string{operatorTypeScan, operatorTypeJoin, operatorTypeDistribution, operatorTypeAggregate, operatorTypeSort, operatorTypeDML, operatorTypeOther}
+
+type summarizeParams struct {
+	Input   string `json:"input"`
+	Recover bool   `json:"recover,omitempty"`
+}
+
+// operatorsPerDepth counts the operators reachable from n at each depth of the
+// operator tree, where scalar subtrees are not levels.
+func operatorsPerDepth(n *treeNode, depth int, counts []int) []int {
+	if depth == len(counts) {
+		counts = append(counts, 0)
+	}
+	counts[depth]++
+	for _, c := range n.relationalChildren() {
+		counts = operatorsPerDepth(c, depth+1, counts)
+	}
+	return counts
+}
+
+// operatorType classifies n by its name. Names are matched
+// case-insensitively so that console naming gives the same types.
+func operatorType(n *treeNode) string {
+	name := strings.ToLower(n.node.GetDisplayName())
+	switch {
+	case isDMLOperator(n.node):
+		return operatorTypeDML
+	case strings.Contains(name, "join") || strings.Contains(name, "apply"):
+		return operatorTypeJoin
+	case strings.HasPrefix(name, "distributed "):
+		return operatorTypeDistribution
+	case strings.Contains(name, "scan"):
+		return operatorTypeScan
+	case strings.Contains(name, "aggregate"):
+		return operatorTypeAggregate
+	case strings.Contains(name, "sort"):
+		return operatorTypeSort
+	}
+	return operatorTypeOther
+}
+
+// buildOperatorTypeStats sums the operators and their self times per type.
+func buildOperatorTypeStats(tree *planTree) []OperatorTypeStats {
+	latency := selfTimes(tree, "latency")
+	cpu := selfTimes(tree, "cpu_time")
+	byType := make(map[string]*OperatorTypeStats)
+	tree.root.walk(func(n *treeNode) {
+		typ := operatorType(n)
+		s, ok := byType[typ]
+		if !ok {
+			s = &OperatorTypeStats{Type: typ}
+			byType[typ] = s
+		}
+		s.Operators++
+		if v, ok := latency[n.id()]; ok {
+			s.LatencyMillis = addMillis(s.LatencyMillis, v)
+		}
+		if v, ok := cpu[n.id()]; ok {
+			s.CPUMillis = addMillis(s.CPUMillis, v)
+		}
+	})
+	stats := []OperatorTypeStats{}
+	for _, typ := range operatorTypes {
+		s, ok := byType[typ]
+		if !ok {
+			continue
+		}
+		// Round away the float error of the sums
+		for _, sum := range []*float64{s.LatencyMillis, s.CPUMillis} {
+			if sum != nil {
+				*sum = math.Round(*sum*1e6) / 1e6
+			}
+		}
+		stats = append(stats, *s)
+	}
+	return stats
+}
+
+// addMillis adds v to the optional sum.
+func addMillis(sum *float64, v float64) *float64 {
+	if sum == nil {
+		return &v
+	}
+	*sum += v
+	return sum
+}
+
+// buildQueryTotals reads the totals of the query stats, or returns nil if
+// none are set. Durations such as "18.71 msecs" are converted to
+// milliseconds.
+func buildQueryTotals(queryStats *structpb.Struct) *QueryTotals {
+	fields := queryStats.GetFields()
+	number := func(key string) *float64 {
+		f, err := strconv.ParseFloat(valueString(fields[key]), 64)
+		return optional(f, err == nil)
+	}
+	duration := func(key string) *float64 {
+		value, unit, _ := strings.Cut(valueString(fields[key]), " ")
+		f, err := strconv.ParseFloat(value, 64)
+		return optional(millis(f, unit), err == nil)
+	}
+	totals := QueryTotals{
+		ElapsedMillis:              duration("elapsed_time"),
+		CPUMillis:                  duration("cpu_time"),
+		RowsReturned:               number("rows_returned"),
+		RowsScanned:                number("rows_scanned"),
+		BytesReturned:              number("bytes_returned"),
+		OptimizerVersion:           valueString(fields["optimizer_version"]),
+		OptimizerStatisticsPackage: valueString(fields["optimizer_statistics_package"]),
+	}
+	if totals == (QueryTotals{}) {
+		return nil
+	}
+	return &totals
+}
+
+// buildPlanSummary computes the size and shape of the plan, so that users
+// can anticipate the size of a render before asking for it, and the totals
+// for a summary of a PROFILE capture.
+func buildPlanSummary(stats *sppb.ResultSetStats) PlanSummary {
+	planNodes := stats.GetQueryPlan().GetPlanNodes()
+	tree := buildPlanTree(planNodes)
+	summary := PlanSummary{
+		Counts:            countPlanNodes(planNodes),
+		OperatorsPerDepth: []int{},
+		QueryStats:        buildQueryTotals(stats.GetQueryStats()),
+		OperatorTypes:     []OperatorTypeStats{},
+		DML:               buildDMLSummary(stats, planNodes),
+		Optimizer:         buildOptimizerInfo(stats.GetQueryStats()),
+	}
+	if tree.root.isRelational() {
+		summary.OperatorsPerDepth = operatorsPerDepth(tree.root, 0, summary.OperatorsPerDepth)
+		summary.OperatorTypes = buildOperatorTypeStats(tree)
+	}
+	summary.Depth = len(summary.OperatorsPerDepth)
+	for depth, n := range summary.OperatorsPerDepth {
+		if n > summary.WidestOperators {
+			summary.WidestDepth, summary.WidestOperators = depth, n
+		}
+	}
+	return summary
+}
+
+// summarizePlan returns a PlanSummary as JSON without rendering the plan
+func summarizePlan(paramsJSON string) (Response, error) {
+	par := summarizeParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return summarizePlanImpl(par)
+}
+
+func summarizePlanImpl(par summarizeParams) (Response, error) {
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	b, err := json.Marshal(buildPlanSummary(stats))
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal summary: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
@@ -0,0 +1,225 @@
+package render
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/olekukonko/tablewriter/pkg/twwidth"
+	"github.com/rivo/uniseg"
+)
+
+// displayWidth returns the width of s in terminal columns, as the table
+// writer measures cells: wide characters such as CJK take two columns, and
+// combining marks and the joined parts of emoji sequences none.
+func displayWidth(s string) int {
+	return twwidth.Width(s)
+}
+
+// columnOffset returns the byte offset in line of the character at the
+// display column col. It returns false for columns past the end of line and
+// inside wide characters.
+func columnOffset(line string, col int) (int, bool) {
+	// Borders, separators, and most cells are ASCII, one column per byte
+	ascii := 0
+	for ascii < len(line) && line[ascii] < utf8.RuneSelf {
+		ascii++
+	}
+	if col < ascii {
+		return col, true
+	}
+	w := ascii
+	g := uniseg.NewGraphemes(line[ascii:])
+	for g.Next() {
+		width := displayWidth(g.Str())
+		switch {
+		case w == col && width > 0:
+			start, _ := g.Positions()
+			return ascii + start, true
+		case w > col:
+			return 0, false
+		}
+		w += width
+	}
+	return 0, false
+}
+
+// tableRowID returns the node ID of a table row line and the width of its ID
+// cell including borders: the first line of an operator row in the text
+// formats, such as "|  *1 | Filter Scan |". Continuation lines of wrapped
+// rows, borders, and appendix lines do not match. Every line of the table is
+// checked, so the cell is scanned by hand rather than with a regexp.
+func tableRowID(line string) (int32, int, bool) {
+	if !strings.HasPrefix(line, "|") {
+		return 0, 0, false
+	}
+	i := len(line) - len(strings.TrimLeft(line[1:], " \t"))
+	if i < len(line) && line[i] == '*' {
+		i++
+	}
+	start := i
+	for i < len(line) && '0' <= line[i] && line[i] <= '9' {
+		i++
+	}
+	digits := line[start:i]
+	i = len(line) - len(strings.TrimLeft(line[i:], " \t"))
+	if digits == "" || i == len(line) || line[i] != '|' {
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(digits, 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	return int32(id), i + 1, true
+}
+
+// appendTableColumn adds a right-aligned column to the table at the start of
+// a rendered plan. The table writer pads every line to the same width, so the
+// cell is appended to the end of each line: borders are extended, the header
+// line gets the title, operator rows get their cell, and continuation lines
+// of wrapped rows get an empty cell. Lines after the table are unchanged. The
+// column width is found before the lines are written into one buffer, as
+// tables of large plans have thousands of lines.
+func appendTableColumn(rendered, title string, cells map[int32]string) string {
+	width := displayWidth(title)
+	for _, cell := range cells {
+		width = max(width, displayWidth(cell))
+	}
+	border := strings.Repeat("-", width+2) + "+"
+	padding := strings.Repeat(" ", width)
+
+	var b strings.Builder
+	b.Grow(len(rendered) + strings.Count(rendered, "\n")*(width+3))
+	seenRow := false
+	for rest := rendered; ; {
+		line, after, more := strings.Cut(rest, "\n")
+		switch {
+		case strings.HasPrefix(line, "+"):
+			b.WriteString(line)
+			b.WriteString(border)
+		case strings.HasPrefix(line, "|"):
+			cell := ""
+			if id, _, ok := tableRowID(line); ok {
+				cell = cells[id]
+				seenRow = true
+			} else if !seenRow {
+				cell, title = title, ""
+			}
+			b.WriteString(line)
+			b.WriteString(" ")
+			b.WriteString(padding[:width-displayWidth(cell)])
+			b.WriteString(cell)
+			b.WriteString(" |")
+		default:
+			b.WriteString(rest)
+			return b.String()
+		}
+		if !more {
+			return b.String()
+		}
+		b.WriteString("\n")
+		rest = after
+	}
+}
+
+// findOperatorColumn finds the Operator column of the table at the start of
+// the lines. It returns the index of the header border and the display
+// columns of the separators around the column. Column groups add header
+// lines and borders, so columns are found by the header border. Cells may
+// have wide characters such as CJK text, so a separator is at the same
+// display column on every line but not at the same rune or byte offset.
+func findOperatorColumn(lines []string) (headEnd, left, right int, ok bool) {
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "+") {
+		return 0, 0, 0, false
+	}
+	headEnd = 1
+	for headEnd < len(lines) && strings.HasPrefix(lines[headEnd], "|") {
+		headEnd++
+	}
+	if headEnd == len(lines) || !strings.HasPrefix(lines[headEnd], "+") {
+		return 0, 0, 0, false
+	}
+	seps := borderSeparators(lines[headEnd])
+	for _, line := range lines[1:headEnd] {
+		for c := 0; c+1 < len(seps); c++ {
+			if cell, ok := cellText(line, seps[c], seps[c+1]); ok && strings.TrimSpace(cell) == "Operator" {
+				return headEnd, seps[c], seps[c+1], true
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// borderSeparators returns the display columns of the "+" of a border line.
+func borderSeparators(border string) []int {
+	var seps []int
+	col := 0
+	for _, r := range border {
+		if r == '+' {
+			seps = append(seps, col)
+		}
+		col += displayWidth(string(r))
+	}
+	return seps
+}
+
+// widenColumn widens the column between the separators at the display
+// columns left and right of the table lines by extra columns: after left for
+// right-aligned columns, so that the cells stay aligned, and before right
+// otherwise. Borders are extended with "-". Lines without a separator at
+// right, such as annotation lines, are unchanged.
+func widenColumn(lines []string, left, right, extra int, rightAligned bool) {
+	at := right
+	if rightAligned {
+		at = left + 1
+	}
+	for i, line := range lines {
+		r, ok := columnOffset(line, right)
+		if !ok {
+			continue
+		}
+		var pad string
+		switch line[r] {
+		case '+':
+			pad = "-"
+		case '|':
+			pad = " "
+		default:
+			continue
+		}
+		if a, ok := columnOffset(line, at); ok {
+			lines[i] = line[:a] + strings.Repeat(pad, extra) + line[a:]
+		}
+	}
+}
+
+// cellText returns the text of the cell between the separators at the
+// display columns left and right of a table line. Lines whose separators are
+// not there, such as annotation lines, have no cell.
+func cellText(line string, left, right int) (string, bool) {
+	l, ok := columnOffset(line, left)
+	if !ok || line[l] != '|' {
+		return "", false
+	}
+	r, ok := columnOffset(line, right)
+	if !ok || line[r] != '|' {
+		return "", false
+	}
+	return line[l+1 : r], true
+}
+
+// replaceCell returns line with the text of the cell between the separators
+// at the display columns left and right replaced by cell, for lines that
+// cellText finds the cell of.
+func replaceCell(line string, left, right int, cell string) string {
+	l, _ := columnOffset(line, left)
+	r, _ := columnOffset(line, right)
+	return line[:l+1] + cell + line[r:]
+}
+
+// splitTreePrefix splits an Operator cell before its text, after the padding,
+// the tree connectors, and any hanging indent.
+func splitTreePrefix(cell string) (string, string) {
+	text := strings.TrimLeft(cell, " |+-")
+	return cell[:len(cell)-len(text)], text
+}
@@ -0,0 +1,56 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+// wideTable has wide characters in its Operator cells, so that the
+// separators of its lines are at the same display column but at different
+// rune offsets.
+const wideTable = `+----+-------------------+
+| ID | Operator          |
++----+-------------------+
+|  0 | Distributed Union |
+|  1 | Scan on 歌手      |
+|  2 | Scan on 🧑‍🎤 x      |
++----+-------------------+`
+
+func TestAppendTableColumnDisplayWidth(t *testing.T) {
+	got := appendTableColumn(wideTable, "Name", map[int32]string{0: "ab", 1: "歌手名"})
+	want := `+----+-------------------+--------+
+| ID | Operator          |   Name |
++----+-------------------+--------+
+|  0 | Distributed Union |     ab |
+|  1 | Scan on 歌手      | 歌手名 |
+|  2 | Scan on 🧑‍🎤 x      |        |
++----+-------------------+--------+`
+	if got != want {
+		t.Errorf("appendTableColumn =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestOperatorColumnDisplayWidth(t *testing.T) {
+	lines := strings.Split(wideTable, "\n")
+	headEnd, left, right, ok := findOperatorColumn(lines)
+	if !ok || headEnd != 2 || left != 5 || right != 25 {
+		t.Fatalf("findOperatorColumn = %d, %d, %d, %v, want 2, 5, 25, true", headEnd, left, right, ok)
+	}
+	for i, want := range map[int]string{4: " Scan on 歌手      ", 5: " Scan on 🧑‍🎤 x      "} {
+		if cell, ok := cellText(lines[i], left, right); !ok || cell != want {
+			t.Errorf("cellText(line %d) = %q, %v, want %q", i, cell, ok, want)
+		}
+	}
+
+	widenColumn(lines, left, right, 2, false)
+	want := `+----+---------------------+
+| ID | Operator            |
++----+---------------------+
+|  0 | Distributed Union   |
+|  1 | Scan on 歌手        |
+|  2 | Scan on 🧑‍🎤 x        |
++----+---------------------+`
+	if got := strings.Join(lines, "\n"); got != want {
+		t.Errorf("widenColumn =\n%s\nwant\n%s", got, want)
+	}
+}
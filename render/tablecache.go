@@ -0,0 +1,95 @@
+package render
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apstndb/spannerplan/plantree/reference"
+)
+
+// tableCacheSize bounds the number of rendered tables kept in memory, like
+// parseCacheSize.
+const tableCacheSize = 4
+
+type tableCacheEntry struct {
+	nodes    []*sppb.PlanNode
+	settings string
+	table    string
+}
+
+// tableCache memoizes the tables of reference.RenderTreeTableWithConfig. The
+// library renders large plans in hundreds of milliseconds, most of it
+// decoding the execution stats of every node, while the UI re-renders the
+// same plan whenever an option changes, and most options only change what is
+// added to the table. Entries are keyed by the identity of the plan nodes:
+// parsed nodes are shared through inputCache and the transformations before
+// the render copy the nodes they change, so equal pointers mean equal nodes,
+// and entries keep their nodes alive so that pointers are not reused.
+type tableCache struct {
+	mu      sync.Mutex
+	entries []tableCacheEntry
+}
+
+var renderedTables = &tableCache{}
+
+func (c *tableCache) get(nodes []*sppb.PlanNode, settings string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, entry := range c.entries {
+		if entry.settings == settings && slices.Equal(entry.nodes, nodes) {
+			copy(c.entries[1:i+1], c.entries[:i])
+			c.entries[0] = entry
+			return entry.table, true
+		}
+	}
+	return "", false
+}
+
+func (c *tableCache) put(nodes []*sppb.PlanNode, settings, table string) {
+	entry := tableCacheEntry{
+		// Callers may reuse their slice
+		nodes:    slices.Clone(nodes),
+		settings: settings,
+		table:    table,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) < tableCacheSize {
+		c.entries = append(c.entries, tableCacheEntry{})
+	}
+	copy(c.entries[1:], c.entries)
+	c.entries[0] = entry
+}
+
+// clear drops every entry.
+func (c *tableCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// renderTreeTable is reference.RenderTreeTableWithConfig backed by
+// renderedTables. Render failures are not cached.
+func renderTreeTable(planNodes []*sppb.PlanNode, mode reference.RenderMode, format reference.Format, config reference.RenderConfig) (string, error) {
+	b, err := json.Marshal(struct {
+		Mode   reference.RenderMode   `json:"mode"`
+		Format reference.Format       `json:"format"`
+		Config reference.RenderConfig `json:"config"`
+	}{mode, format, config})
+	if err != nil {
+		return reference.RenderTreeTableWithConfig(planNodes, mode, format, config)
+	}
+	settings := string(b)
+	if table, ok := renderedTables.get(planNodes, settings); ok {
+		return table, nil
+	}
+	table, err := reference.RenderTreeTableWithConfig(planNodes, mode, format, config)
+	if err != nil {
+		return "", err
+	}
+	renderedTables.put(planNodes, settings, table)
+	return table, nil
+}
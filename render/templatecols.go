@@ -0,0 +1,149 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// WarningCodeTemplateColumnError is reported for template columns that failed
+// to evaluate for some operators, whose cells are left empty.
+const WarningCodeTemplateColumnError = "TEMPLATE_COLUMN_ERROR"
+
+// TemplateColumn is a table column computed for each operator by a Go
+// text/template over its planRow, the row model of formatter plugins, e.g.
+// {"title": "Target", "template": "{{.Metadata.scan_type}} {{.Metadata.scan_target}}"}.
+type TemplateColumn struct {
+	Title    string `json:"title"`
+	Template string `json:"template"`
+}
+
+// templateColumnFuncs are the functions available to column templates, for
+// ratios such as {{div .Stats.rows .Stats.latency | printf "%.1f"}}. Numbers
+// are given as numbers, numeric strings, or stats, whose total is used.
+var templateColumnFuncs = template.FuncMap{
+	"num": templateNumber,
+	"add": templateArith(func(a, b float64) (float64, error) { return a + b, nil }),
+	"sub": templateArith(func(a, b float64) (float64, error) { return a - b, nil }),
+	"mul": templateArith(func(a, b float64) (float64, error) { return a * b, nil }),
+	"div": templateArith(func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	}),
+	// ms converts a duration stat such as latency or cpu_time to milliseconds
+	"ms": func(s planStat) (float64, error) {
+		v, err := templateNumber(s)
+		return millis(v, s.Unit), err
+	},
+}
+
+func templateNumber(v any) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case planStat:
+		return templateNumber(v.Total)
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("not a number: %q", v)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("not a number: %v", v)
+	}
+}
+
+func templateArith(op func(a, b float64) (float64, error)) func(a, b any) (float64, error) {
+	return func(a, b any) (float64, error) {
+		x, err := templateNumber(a)
+		if err != nil {
+			return 0, err
+		}
+		y, err := templateNumber(b)
+		if err != nil {
+			return 0, err
+		}
+		return op(x, y)
+	}
+}
+
+// parseTemplateColumns compiles the column templates. Titles must be unique
+// and must not be the header of a built-in column.
+func parseTemplateColumns(columns []TemplateColumn) ([]*template.Template, error) {
+	templates := make([]*template.Template, len(columns))
+	var titles []string
+	for i, c := range columns {
+		title := strings.TrimSpace(c.Title)
+		if title == "" {
+			return nil, InvalidParametersError{msg: fmt.Sprintf("Template column %d has no title", i)}
+		}
+		if slices.ContainsFunc(selectableColumns, func(b tableColumn) bool { return strings.EqualFold(b.header, title) }) ||
+			slices.ContainsFunc(titles, func(t string) bool { return strings.EqualFold(t, title) }) {
+			return nil, InvalidParametersError{msg: fmt.Sprintf("Template column title %q is already a column", title)}
+		}
+		titles = append(titles, title)
+		t, err := template.New(title).Funcs(templateColumnFuncs).Option("missingkey=zero").Parse(c.Template)
+		if err != nil {
+			return nil, InvalidParametersError{msg: fmt.Sprintf("Invalid template of column %q: %v", title, err)}
+		}
+		templates[i] = t
+	}
+	return templates, nil
+}
+
+// templateColumnTitles returns the titles of the template columns, which the
+// columns option can select.
+func templateColumnTitles(columns []TemplateColumn) []string {
+	titles := make([]string, len(columns))
+	for i, c := range columns {
+		titles[i] = strings.TrimSpace(c.Title)
+	}
+	return titles
+}
+
+// applyTemplateColumns appends a column per template, evaluated for each
+// operator row. Cells are single lines; operators the template fails for get
+// an empty cell and the column a warning naming the first of them.
+func applyTemplateColumns(rendered string, tree *planTree, templates []*template.Template) (string, []Warning) {
+	if len(templates) == 0 {
+		return rendered, nil
+	}
+	rows := buildPlanRows(tree)
+	var warnings []Warning
+	for _, t := range templates {
+		cells := make(map[int32]string, len(rows))
+		var failed []int32
+		var firstErr error
+		for _, row := range rows {
+			var b bytes.Buffer
+			if err := t.Execute(&b, row); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				failed = append(failed, row.ID)
+				continue
+			}
+			cells[row.ID] = strings.Join(strings.Fields(b.String()), " ")
+		}
+		if len(failed) > 0 {
+			id := failed[0]
+			warnings = append(warnings, Warning{
+				Code:    WarningCodeTemplateColumnError,
+				Message: fmt.Sprintf("Column %q failed for %d operators: %v", t.Name(), len(failed), firstErr),
+				NodeID:  &id,
+			})
+		}
+		rendered = appendTableColumn(rendered, t.Name(), cells)
+	}
+	return rendered, warnings
+}
@@ -0,0 +1,123 @@
+package render
+
+import "fmt"
+
+// Default thresholds, tuned for production-scale plans.
+const (
+	// defaultFullScanMinRows reports every full scan.
+	defaultFullScanMinRows = 0
+	// defaultFanOutLimit is the number of splits per execution from which a
+	// distributed operator is reported.
+	defaultFanOutLimit = 100
+	// defaultMisestimateRatio is the ratio between actual and estimated rows,
+	// in either direction, from which an estimate counts as a misestimate.
+	defaultMisestimateRatio = 10
+	// defaultSelectiveFilterRatio is the fraction of scanned rows a residual
+	// filter may keep for suggestWhatIf to suggest an index.
+	defaultSelectiveFilterRatio = 0.1
+	// defaultLargeSortRows is the number of input rows from which
+	// suggestWhatIf considers a sort below a limit large.
+	defaultLargeSortRows = 10000
+	// defaultLargeScanRows is the number of rows from which a table scan
+	// without an index is reported.
+	defaultLargeScanRows = 100000
+	// defaultApplyInputRows is the number of input rows from which a cross
+	// apply is reported.
+	defaultApplyInputRows = 10000
+	// defaultHashBuildRows is the number of build side rows from which a hash
+	// join is reported.
+	defaultHashBuildRows = 100000
+	// defaultDistributedOperatorLimit is the number of distributed operators
+	// from which a plan is reported.
+	defaultDistributedOperatorLimit = 6
+)
+
+// Thresholds tune when built-in findings and warnings are reported, e.g. to
+// quiet them on small test databases. Zero fields use the defaults.
+type Thresholds struct {
+	// FullScanMinRows is the number of rows a full scan must return to be
+	// reported. Scans without execution stats are always reported.
+	FullScanMinRows float64 `json:"fullScanMinRows,omitempty"`
+	// FanOutLimit is the number of splits per execution from which a
+	// distributed operator is reported
+	FanOutLimit float64 `json:"fanOutLimit,omitempty"`
+	// MisestimateRatio is the ratio between actual and estimated rows from
+	// which an estimate counts as a misestimate; it must be greater than 1
+	MisestimateRatio float64 `json:"misestimateRatio,omitempty"`
+	// SelectiveFilterRatio is the fraction of the scanned rows from which a
+	// residual filter is not selective enough for an index suggestion; it
+	// must not be greater than 1
+	SelectiveFilterRatio float64 `json:"selectiveFilterRatio,omitempty"`
+	// LargeSortRows is the number of input rows from which a sort below a
+	// limit gets an index suggestion
+	LargeSortRows float64 `json:"largeSortRows,omitempty"`
+	// LargeScanRows is the number of rows a table scan must return to be
+	// reported for not using an index
+	LargeScanRows float64 `json:"largeScanRows,omitempty"`
+	// ApplyInputRows is the number of input rows from which a cross apply,
+	// which runs its map side once per row, is reported
+	ApplyInputRows float64 `json:"applyInputRows,omitempty"`
+	// HashBuildRows is the number of build side rows from which a hash join
+	// is reported
+	HashBuildRows float64 `json:"hashBuildRows,omitempty"`
+	// DistributedOperatorLimit is the number of distributed operators from
+	// which a plan is reported
+	DistributedOperatorLimit float64 `json:"distributedOperatorLimit,omitempty"`
+}
+
+// check validates the thresholds set by the caller.
+func (t Thresholds) check() error {
+	switch {
+	case t.FullScanMinRows < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid fullScanMinRows threshold: %v (must not be negative)", t.FullScanMinRows)}
+	case t.FanOutLimit < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid fanOutLimit threshold: %v (must not be negative)", t.FanOutLimit)}
+	case t.MisestimateRatio != 0 && t.MisestimateRatio <= 1:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid misestimateRatio threshold: %v (must be greater than 1)", t.MisestimateRatio)}
+	case t.SelectiveFilterRatio < 0 || t.SelectiveFilterRatio > 1:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid selectiveFilterRatio threshold: %v (must be between 0 and 1)", t.SelectiveFilterRatio)}
+	case t.LargeSortRows < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid largeSortRows threshold: %v (must not be negative)", t.LargeSortRows)}
+	case t.LargeScanRows < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid largeScanRows threshold: %v (must not be negative)", t.LargeScanRows)}
+	case t.ApplyInputRows < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid applyInputRows threshold: %v (must not be negative)", t.ApplyInputRows)}
+	case t.HashBuildRows < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid hashBuildRows threshold: %v (must not be negative)", t.HashBuildRows)}
+	case t.DistributedOperatorLimit < 0:
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid distributedOperatorLimit threshold: %v (must not be negative)", t.DistributedOperatorLimit)}
+	}
+	return nil
+}
+
+// withDefaults returns t with unset fields replaced by their defaults.
+func (t Thresholds) withDefaults() Thresholds {
+	if t.FullScanMinRows == 0 {
+		t.FullScanMinRows = defaultFullScanMinRows
+	}
+	if t.FanOutLimit == 0 {
+		t.FanOutLimit = defaultFanOutLimit
+	}
+	if t.MisestimateRatio == 0 {
+		t.MisestimateRatio = defaultMisestimateRatio
+	}
+	if t.SelectiveFilterRatio == 0 {
+		t.SelectiveFilterRatio = defaultSelectiveFilterRatio
+	}
+	if t.LargeSortRows == 0 {
+		t.LargeSortRows = defaultLargeSortRows
+	}
+	if t.LargeScanRows == 0 {
+		t.LargeScanRows = defaultLargeScanRows
+	}
+	if t.ApplyInputRows == 0 {
+		t.ApplyInputRows = defaultApplyInputRows
+	}
+	if t.HashBuildRows == 0 {
+		t.HashBuildRows = defaultHashBuildRows
+	}
+	if t.DistributedOperatorLimit == 0 {
+		t.DistributedOperatorLimit = defaultDistributedOperatorLimit
+	}
+	return t
+}
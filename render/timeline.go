@@ -0,0 +1,86 @@
+package render
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// renderModeTimeline is the render mode that renders PROFILE with a
+// Gantt-style Timeline column and returns the bars in Response.Timeline.
+const renderModeTimeline = "TIMELINE"
+
+// timelineColumnTitle is the header of the timeline column.
+const timelineColumnTitle = "Timeline"
+
+// timelineWidth is the width of the timeline column in characters.
+const timelineWidth = 40
+
+// TimelineEntry is the bar of an operator with latency stats in the
+// timeline, returned in Response.Timeline in pre-order
+type TimelineEntry struct {
+	NodeID int32 `json:"nodeId"`
+	// StartMillis is an estimate: Spanner reports no start times, so the
+	// inputs of an operator are laid out one after another from its start,
+	// overlapping when they do not fit in it
+	StartMillis    float64 `json:"startMillis"`
+	DurationMillis float64 `json:"durationMillis"`
+}
+
+// buildTimeline lays out the operators with latency stats. Operators without
+// them are skipped and their inputs take their place.
+func buildTimeline(tree *planTree) []TimelineEntry {
+	var entries []TimelineEntry
+	var place func(n *treeNode, start, end float64)
+	place = func(n *treeNode, start, end float64) {
+		if ms, ok := n.durationMillis("latency"); ok {
+			start = max(0, min(start, end-ms))
+			entries = append(entries, TimelineEntry{NodeID: n.id(), StartMillis: start, DurationMillis: ms})
+			end = start + ms
+		}
+		cursor := start
+		for _, child := range n.relationalChildren() {
+			place(child, cursor, end)
+			if ms, ok := child.durationMillis("latency"); ok {
+				cursor = min(cursor+ms, end)
+			}
+		}
+	}
+	place(tree.root, 0, math.Inf(1))
+	return entries
+}
+
+// timelineBar draws the span of an entry within total milliseconds as a bar
+// of timelineWidth characters. Any entry gets at least the thinnest bar.
+func timelineBar(e TimelineEntry, total float64) string {
+	offset, length := 0, 0
+	if total > 0 {
+		offset = int(math.Round(e.StartMillis / total * timelineWidth))
+		length = int(math.Round(e.DurationMillis / total * timelineWidth))
+	}
+	offset = min(offset, timelineWidth-1)
+	length = min(length, timelineWidth-offset)
+	bar := strings.Repeat(string(barEighths[7]), length)
+	if length == 0 {
+		bar = string(barEighths[0])
+	}
+	bar = strings.Repeat(" ", offset) + bar
+	return bar + strings.Repeat(" ", timelineWidth-utf8.RuneCountInString(bar))
+}
+
+// applyTimelineColumn appends a column with the timeline bar of each entry,
+// scaled to the end of the last one.
+func applyTimelineColumn(rendered string, entries []TimelineEntry) string {
+	if len(entries) == 0 {
+		return rendered
+	}
+	total := 0.0
+	for _, e := range entries {
+		total = max(total, e.StartMillis+e.DurationMillis)
+	}
+	cells := make(map[int32]string, len(entries))
+	for _, e := range entries {
+		cells[e.NodeID] = timelineBar(e, total)
+	}
+	return appendTableColumn(rendered, timelineColumnTitle, cells)
+}
@@ -0,0 +1,223 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WarningCodeNoExecutionStats is reported when totals are requested for a
+// plan without execution stats.
+const WarningCodeNoExecutionStats = "NO_EXECUTION_STATS"
+
+// Aggregates of the totals option
+const (
+	totalsAggregateSum = "sum"
+	totalsAggregateMax = "max"
+)
+
+// TotalsOptions configure the totals option. Zero fields use the defaults.
+type TotalsOptions struct {
+	// Aggregate is "sum" (the default) or "max" of the leaf operators
+	Aggregate string `json:"aggregate,omitempty"`
+	// Rollups annotates each branch operator with the totals of the leaf
+	// operators of its subtree
+	Rollups bool `json:"rollups,omitempty"`
+}
+
+func (o TotalsOptions) check() error {
+	if o.Aggregate != "" && o.Aggregate != totalsAggregateSum && o.Aggregate != totalsAggregateMax {
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid totals aggregate: %q (expected %q or %q)", o.Aggregate, totalsAggregateSum, totalsAggregateMax)}
+	}
+	return nil
+}
+
+func (o TotalsOptions) withDefaults() TotalsOptions {
+	if o.Aggregate == "" {
+		o.Aggregate = totalsAggregateSum
+	}
+	return o
+}
+
+// leafTotals are the aggregated stats of the leaf operators of a subtree.
+// Durations are in milliseconds; stats no leaf has are nil.
+type leafTotals struct {
+	aggregate string
+	leaves    int
+	rows      *float64
+	latency   *float64
+	cpu       *float64
+}
+
+func (t *leafTotals) add(total **float64, v float64, ok bool) {
+	switch {
+	case !ok:
+	case *total == nil:
+		*total = &v
+	case t.aggregate == totalsAggregateMax:
+		**total = max(**total, v)
+	default:
+		**total += v
+	}
+}
+
+func (t leafTotals) hasStats() bool {
+	return t.rows != nil || t.latency != nil || t.cpu != nil
+}
+
+// subtreeTotals aggregates the stats of the relational leaves under root.
+func subtreeTotals(root *treeNode, aggregate string) leafTotals {
+	t := leafTotals{aggregate: aggregate}
+	root.walk(func(n *treeNode) {
+		if len(n.relationalChildren()) > 0 {
+			return
+		}
+		t.leaves++
+		rows, ok := n.stat("rows")
+		t.add(&t.rows, rows, ok)
+		latency, ok := n.durationMillis("latency")
+		t.add(&t.latency, latency, ok)
+		cpu, ok := n.durationMillis("cpu_time")
+		t.add(&t.cpu, cpu, ok)
+	})
+	return t
+}
+
+// label describes the aggregate, e.g. "sum of 3 leaves".
+func (t leafTotals) label() string {
+	if t.leaves == 1 {
+		return t.aggregate + " of 1 leaf"
+	}
+	return fmt.Sprintf("%s of %d leaves", t.aggregate, t.leaves)
+}
+
+// cells returns the formatted stats by the title of their table column.
+func (t leafTotals) cells(f NumberFormat) map[string]string {
+	cells := make(map[string]string)
+	if t.rows != nil {
+		cells["Rows"] = f.count(*t.rows)
+	}
+	if t.latency != nil {
+		cells["Total Latency"] = f.duration(*t.latency, "msecs")
+	}
+	if t.cpu != nil {
+		cells[cpuColumnTitle] = f.duration(*t.cpu, "msecs")
+	}
+	return cells
+}
+
+// rollupAnnotations returns annotations with the leaf totals of every
+// operator under root that joins more than one leaf added, merged after any
+// annotation for the same node. Operators with one leaf under them would
+// repeat the stats of the leaf.
+func rollupAnnotations(root *treeNode, opts TotalsOptions, f NumberFormat, annotations map[int32]string) map[int32]string {
+	merged := make(map[int32]string, len(annotations))
+	for id, note := range annotations {
+		merged[id] = note
+	}
+	root.walk(func(n *treeNode) {
+		t := subtreeTotals(n, opts.Aggregate)
+		if t.leaves < 2 || !t.hasStats() {
+			return
+		}
+		cells := t.cells(f)
+		var stats []string
+		for _, s := range []struct{ name, column string }{{"rows", "Rows"}, {"latency", "Total Latency"}, {"CPU", cpuColumnTitle}} {
+			if cell, ok := cells[s.column]; ok {
+				stats = append(stats, s.name+" "+cell)
+			}
+		}
+		note := fmt.Sprintf("subtree: %s (%s)", strings.Join(stats, ", "), t.label())
+		if prev, ok := merged[n.id()]; ok {
+			note = prev + "\n" + note
+		}
+		merged[n.id()] = note
+	})
+	return merged
+}
+
+// appendTotalsRow adds a row with the totals under the operator rows of the
+// table at the start of rendered, separated by a border. The Operator cell
+// gets the label and the execution stat columns the table has get their
+// totals, aligned like the other cells of their column; columns too narrow
+// for a total are widened on the side they are padded. Lines after the table
+// are unchanged.
+func appendTotalsRow(rendered string, t leafTotals, f NumberFormat) string {
+	lines := strings.Split(rendered, "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], "+") || !strings.HasPrefix(lines[1], "|") {
+		return rendered
+	}
+	end := 1
+	for end < len(lines) && (strings.HasPrefix(lines[end], "|") || strings.HasPrefix(lines[end], "+")) {
+		end++
+	}
+	// headEnd is the border under the header
+	headEnd := 1
+	for headEnd < end && strings.HasPrefix(lines[headEnd], "|") {
+		headEnd++
+	}
+	if headEnd >= end-1 {
+		return rendered
+	}
+
+	seps := borderSeparators(lines[headEnd])
+	byTitle := t.cells(f)
+	byTitle["Operator"] = "Total (" + t.label() + ")"
+	cells := make([]string, len(seps)-1)
+	rightAligned := make([]bool, len(cells))
+	for c := range cells {
+		title, ok := cellText(lines[1], seps[c], seps[c+1])
+		if !ok {
+			return rendered
+		}
+		cells[c] = byTitle[strings.TrimSpace(title)]
+		rightAligned[c] = isRightAligned(lines[headEnd+1:end], seps[c], seps[c+1])
+	}
+
+	// Columns are widened from the right, so only the offsets of the columns
+	// already widened move
+	for c := len(cells) - 1; c >= 0; c-- {
+		extra := displayWidth(cells[c]) + 2 - (seps[c+1] - seps[c] - 1)
+		if extra <= 0 {
+			continue
+		}
+		widenColumn(lines[:end], seps[c], seps[c+1], extra, rightAligned[c])
+		for i := c + 1; i < len(seps); i++ {
+			seps[i] += extra
+		}
+	}
+
+	var row strings.Builder
+	row.WriteString("|")
+	for c, cell := range cells {
+		width := seps[c+1] - seps[c] - 3
+		pad := strings.Repeat(" ", width-displayWidth(cell))
+		if rightAligned[c] {
+			row.WriteString(" " + pad + cell + " |")
+		} else {
+			row.WriteString(" " + cell + pad + " |")
+		}
+	}
+	border := lines[end-1]
+	out := append(lines[:end-1:end-1], border, row.String())
+	out = append(out, lines[end-1:]...)
+	return strings.Join(out, "\n")
+}
+
+// isRightAligned reports whether the cells between the separators at the
+// display columns left and right of the row lines are padded on the left, by the
+// first cell that is narrower than the column.
+func isRightAligned(lines []string, left, right int) bool {
+	for _, line := range lines {
+		cell, ok := cellText(line, left, right)
+		if !ok || strings.TrimSpace(cell) == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(cell, "  "):
+			return true
+		case strings.HasSuffix(cell, "  "):
+			return false
+		}
+	}
+	return false
+}
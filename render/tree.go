@@ -0,0 +1,226 @@
+package render
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// planTree is a resolved view of validated plan nodes: parents, depths, and
+// child nodes are linked so that analyses and the Go-side emitters can walk
+// the plan without re-resolving child indexes.
+type planTree struct {
+	nodes []*treeNode // indexed by plan node index
+	root  *treeNode
+}
+
+type treeNode struct {
+	node     *sppb.PlanNode
+	parent   *treeNode
+	depth    int
+	children []treeChild
+}
+
+type treeChild struct {
+	link *sppb.PlanNode_ChildLink
+	node *treeNode
+}
+
+// buildPlanTree links planNodes, which must have passed validatePlanNodes.
+// Nodes unreachable from the root keep a nil parent and depth 0; links that
+// would revisit an ancestor are ignored so that malformed cyclic plans cannot
+// loop forever.
+func buildPlanTree(planNodes []*sppb.PlanNode) *planTree {
+	tree := &planTree{nodes: make([]*treeNode, len(planNodes))}
+	// One allocation for every node, as large plans have thousands
+	backing := make([]treeNode, len(planNodes))
+	for i, node := range planNodes {
+		backing[i].node = node
+		tree.nodes[i] = &backing[i]
+	}
+	tree.root = tree.nodes[0]
+
+	onPath := make([]bool, len(planNodes))
+	var link func(n *treeNode)
+	link = func(n *treeNode) {
+		onPath[n.id()] = true
+		n.children = make([]treeChild, 0, len(n.node.GetChildLinks()))
+		for _, l := range n.node.GetChildLinks() {
+			child := tree.nodes[l.GetChildIndex()]
+			if onPath[child.id()] {
+				continue
+			}
+			if child.parent == nil && child != tree.root {
+				child.parent = n
+				child.depth = n.depth + 1
+				link(child)
+			}
+			n.children = append(n.children, treeChild{link: l, node: child})
+		}
+		onPath[n.id()] = false
+	}
+	link(tree.root)
+	return tree
+}
+
+func (n *treeNode) id() int32 {
+	return n.node.GetIndex()
+}
+
+func (n *treeNode) isRelational() bool {
+	return n.node.GetKind() == sppb.PlanNode_RELATIONAL
+}
+
+// relationalChildren returns the children linked to relational nodes.
+func (n *treeNode) relationalChildren() []*treeNode {
+	var children []*treeNode
+	for _, c := range n.children {
+		if c.node.isRelational() {
+			children = append(children, c.node)
+		}
+	}
+	return children
+}
+
+// scalarChildDescription returns the short representation of the scalar
+// child linked with the given type, e.g. "Residual Condition".
+func scalarChildDescription(n *treeNode, linkType string) string {
+	for _, c := range n.children {
+		if c.link.GetType() == linkType && !c.node.isRelational() {
+			return c.node.node.GetShortRepresentation().GetDescription()
+		}
+	}
+	return ""
+}
+
+// walk visits n and its relational descendants in pre-order.
+func (n *treeNode) walk(visit func(*treeNode)) {
+	visit(n)
+	for _, child := range n.relationalChildren() {
+		child.walk(visit)
+	}
+}
+
+// operatorName returns the operator name qualified by its call, iterator,
+// and scan type, e.g. "Local Distributed Union" or "Index Scan".
+func (n *treeNode) operatorName() string {
+	fields := n.node.GetMetadata().GetFields()
+	var words []string
+	for _, key := range []string{"call_type", "iterator_type"} {
+		if v := valueString(fields[key]); v != "" {
+			words = append(words, v)
+		}
+	}
+	if scanType := strings.TrimSuffix(valueString(fields["scan_type"]), "Scan"); scanType != "" {
+		words = append(words, scanType)
+	}
+	words = append(words, n.node.GetDisplayName())
+	return strings.Join(words, " ")
+}
+
+// title returns the operator title in the style of spannerplan, e.g.
+// "Local Distributed Union" or "Index Scan (Index: SingersByName)".
+func (n *treeNode) title() string {
+	fields := n.node.GetMetadata().GetFields()
+	scanType := strings.TrimSuffix(valueString(fields["scan_type"]), "Scan")
+	title := n.operatorName()
+
+	var params []string
+	if target := valueString(fields["scan_target"]); target != "" {
+		label := "Table"
+		if scanType != "" {
+			label = scanType
+		}
+		params = append(params, fmt.Sprintf("%s: %s", label, target))
+	}
+	for _, key := range sortedKeys(fields) {
+		switch key {
+		case "call_type", "iterator_type", "scan_type", "scan_target", "subquery_cluster_node":
+			continue
+		}
+		params = append(params, fmt.Sprintf("%s: %s", key, valueString(fields[key])))
+	}
+	if len(params) > 0 {
+		title += " (" + strings.Join(params, ", ") + ")"
+	}
+	return title
+}
+
+// stat returns the "total" of an execution stat such as "rows" or "latency".
+// Totals are plain decimals once normalizeStatUnits has run.
+func (n *treeNode) stat(name string) (float64, bool) {
+	v := n.node.GetExecutionStats().GetFields()[name]
+	total, ok := v.GetStructValue().GetFields()["total"]
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(valueString(total), 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// statUnit returns the unit of an execution stat, e.g. "msecs".
+func (n *treeNode) statUnit(name string) string {
+	v := n.node.GetExecutionStats().GetFields()[name]
+	return valueString(v.GetStructValue().GetFields()["unit"])
+}
+
+// durationMillis returns a duration stat such as "latency" or "cpu_time" in
+// milliseconds.
+func (n *treeNode) durationMillis(name string) (float64, bool) {
+	v, ok := n.stat(name)
+	if !ok {
+		return 0, false
+	}
+	return millis(v, n.statUnit(name)), true
+}
+
+// statValue returns the total of an execution stat as a number, converted
+// to milliseconds for durations, which is how the JSON APIs expose stats.
+func (n *treeNode) statValue(name string) (float64, bool) {
+	if isDurationUnit(n.statUnit(name)) {
+		return n.durationMillis(name)
+	}
+	return n.stat(name)
+}
+
+// millis converts a duration in the given execution stat unit to milliseconds.
+func millis(v float64, unit string) float64 {
+	switch unit {
+	case "usecs":
+		return v / 1000
+	case "secs":
+		return v * 1000
+	case "nsecs":
+		return v / 1e6
+	default:
+		return v
+	}
+}
+
+func valueString(v *structpb.Value) string {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return k.StringValue
+	case *structpb.Value_NumberValue:
+		return strconv.FormatFloat(k.NumberValue, 'f', -1, 64)
+	case *structpb.Value_BoolValue:
+		return strconv.FormatBool(k.BoolValue)
+	case nil, *structpb.Value_NullValue:
+		return ""
+	default:
+		b, _ := v.MarshalJSON()
+		return string(b)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -0,0 +1,47 @@
+package render
+
+import (
+	"strings"
+)
+
+// formatTree is the renderASCII format that renders only the operator tree as
+// indented lines, without borders or stats, for pasting inline in chats.
+const formatTree = "TREE"
+
+// treeIndent is the indentation per depth of the tree format.
+const treeIndent = "  "
+
+// treeLine returns the line of an operator in the tree format: its name and
+// the table or index it scans, e.g. "Index Scan on SongsBySingerAlbum".
+func treeLine(n *treeNode) string {
+	line := n.operatorName()
+	if target := valueString(n.node.GetMetadata().GetFields()["scan_target"]); target != "" {
+		line += " on " + target
+	}
+	return line
+}
+
+// writeOperatorTree writes an indented line per operator of the tree rooted
+// at root. Unless oneLine, each operator is followed by a "- " line per
+// predicate, one level deeper, broken at AND and OR operators if pretty.
+func writeOperatorTree(root *treeNode, oneLine, pretty bool) string {
+	var b strings.Builder
+	root.walk(func(n *treeNode) {
+		indent := strings.Repeat(treeIndent, n.depth-root.depth)
+		b.WriteString(indent + treeLine(n) + "\n")
+		if oneLine {
+			return
+		}
+		for _, c := range n.children {
+			if !c.node.isRelational() && isPredicateLink(c.link.GetType()) {
+				label, description := "- "+c.link.GetType()+": ", c.node.node.GetShortRepresentation().GetDescription()
+				if pretty {
+					b.WriteString(hangPredicate(indent+treeIndent, label, description) + "\n")
+				} else {
+					b.WriteString(indent + treeIndent + label + description + "\n")
+				}
+			}
+		}
+	})
+	return b.String()
+}
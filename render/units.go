@@ -0,0 +1,229 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Decimal separators of the decimalSeparator option. Stats are parsed with
+// "." by default; the other of "." and "," groups thousands.
+const (
+	decimalSeparatorPoint = "."
+	decimalSeparatorComma = ","
+)
+
+// statUnitAliases maps the spellings of duration units that plans of some
+// operators and versions use to the units of the Spanner console.
+var statUnitAliases = map[string]string{
+	"s": "secs", "sec": "secs", "secs": "secs", "second": "secs", "seconds": "secs",
+	"ms": "msecs", "msec": "msecs", "msecs": "msecs", "millisecond": "msecs", "milliseconds": "msecs",
+	"us": "usecs", "µs": "usecs", "μs": "usecs", "usec": "usecs", "usecs": "usecs", "microsecond": "usecs", "microseconds": "usecs",
+	"ns": "nsecs", "nsec": "nsecs", "nsecs": "nsecs", "nanosecond": "nsecs", "nanoseconds": "nsecs",
+}
+
+// normalizedStatFields are the numeric fields of an execution stat that may
+// be spelled with a unit or digit grouping.
+var normalizedStatFields = []string{"total", "mean", "std_deviation"}
+
+// statNumberPattern matches a stat number with an optional unit suffix, e.g.
+// "1.23 secs", "1,234", or "15ms".
+var statNumberPattern = regexp.MustCompile(`^([-+]?[0-9][0-9.,' ]*?)\s*([A-Za-zµμ]+)?$`)
+
+func checkDecimalSeparator(separator string) error {
+	switch separator {
+	case "", decimalSeparatorPoint, decimalSeparatorComma:
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid decimalSeparator: %q (expected %q or %q)", separator, decimalSeparatorPoint, decimalSeparatorComma)}
+}
+
+// checkStatDurationUnit accepts the units of the statDurationUnit option,
+// those of numberFormat.durationUnit.
+func checkStatDurationUnit(unit string) error {
+	if _, ok := durationUnits[unit]; !ok && unit != "" {
+		return InvalidParametersError{msg: fmt.Sprintf("Invalid statDurationUnit: %q (expected \"s\", \"ms\", or \"µs\")", unit)}
+	}
+	return nil
+}
+
+// isDurationUnit reports whether unit is a canonical duration unit.
+func isDurationUnit(unit string) bool {
+	switch unit {
+	case "secs", "msecs", "usecs", "nsecs":
+		return true
+	}
+	return false
+}
+
+// parseStatNumber parses a stat number such as "1,234.5 msecs" with the
+// decimal separator, or "." if empty, and returns it with its unit suffix,
+// canonicalized if it is a duration unit. Spaces, apostrophes, and the other
+// separator group digits.
+func parseStatNumber(s, separator string) (float64, string, bool) {
+	m := statNumberPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, "", false
+	}
+	group := decimalSeparatorComma
+	if separator == decimalSeparatorComma {
+		group = decimalSeparatorPoint
+	}
+	digits := strings.NewReplacer(group, "", " ", "", "'", "").Replace(m[1])
+	if separator == decimalSeparatorComma {
+		digits = strings.Replace(digits, decimalSeparatorComma, decimalSeparatorPoint, 1)
+	}
+	v, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	unit := m[2]
+	if canonical, ok := statUnitAliases[strings.ToLower(unit)]; ok {
+		unit = canonical
+	}
+	return v, unit, true
+}
+
+// normalizeStat returns the execution stat fields with its numbers as plain
+// decimals and its unit canonical, or nil if they are already. A unit
+// spelled in the total takes precedence over the unit field. Numbers that
+// are plain decimals already keep their spelling whatever the separator, as
+// the separator only tells apart the ones spelled with digit grouping.
+// Durations are converted to durationUnit, a canonical duration unit, if it
+// is set.
+func normalizeStat(fields map[string]*structpb.Value, separator, durationUnit string) map[string]*structpb.Value {
+	var normalized map[string]*structpb.Value
+	set := func(key, value string) {
+		if valueString(fields[key]) == value {
+			return
+		}
+		if normalized == nil {
+			normalized = make(map[string]*structpb.Value, len(fields))
+			for k, v := range fields {
+				normalized[k] = v
+			}
+		}
+		normalized[key] = structpb.NewStringValue(value)
+	}
+
+	unit := valueString(fields["unit"])
+	if canonical, ok := statUnitAliases[strings.ToLower(unit)]; ok {
+		unit = canonical
+	}
+	values := make(map[string]float64, len(normalizedStatFields))
+	for _, key := range normalizedStatFields {
+		var s *structpb.Value_StringValue
+		switch kind := fields[key].GetKind().(type) {
+		case *structpb.Value_NumberValue:
+			values[key] = kind.NumberValue
+			continue
+		case *structpb.Value_StringValue:
+			s = kind
+		default:
+			continue
+		}
+		// Plain decimals keep their spelling, e.g. "3.50"
+		if v, err := strconv.ParseFloat(s.StringValue, 64); err == nil {
+			values[key] = v
+			continue
+		}
+		v, suffix, ok := parseStatNumber(s.StringValue, separator)
+		if !ok {
+			continue
+		}
+		if suffix != "" {
+			unit = suffix
+		}
+		values[key] = v
+		set(key, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	if durationUnit != "" && isDurationUnit(unit) && unit != durationUnit {
+		for key, v := range values {
+			set(key, formatConvertedStat(millis(v, unit)/millis(1, durationUnit)))
+		}
+		unit = durationUnit
+	}
+	if unit != "" {
+		set("unit", unit)
+	}
+	return normalized
+}
+
+// formatConvertedStat formats a stat number converted to another unit as a
+// plain decimal, rounded to 12 significant digits so that the conversion
+// does not add digits, e.g. 1.23 secs is 1230 msecs.
+func formatConvertedStat(v float64) string {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 12, 64), 64)
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// lastNormalized memoizes normalizeStatUnits for the latest plan, as the UI
+// re-renders the same plan whenever an option changes: its stats are not
+// normalized again, and the renders share the copies of changed nodes, which
+// renderedTables finds by identity.
+var lastNormalized struct {
+	mu           sync.Mutex
+	planNodes    []*sppb.PlanNode
+	separator    string
+	durationUnit string
+	result       []*sppb.PlanNode
+}
+
+// normalizeStatUnits returns planNodes with the numbers of their execution
+// stats parsed with the decimal separator and their duration units
+// canonical, e.g. a total of "1,5 s" with "," as 1.5 secs, so that columns,
+// analyses, and the JSON APIs compare them as numbers. With durationUnit, a
+// unit of the statDurationUnit option, durations are converted to it, so
+// that they are shown in one unit. Stats that are canonical already keep
+// their spelling, and changed nodes are copies.
+func normalizeStatUnits(planNodes []*sppb.PlanNode, separator, durationUnit string) []*sppb.PlanNode {
+	durationUnit = durationUnits[durationUnit]
+	lastNormalized.mu.Lock()
+	defer lastNormalized.mu.Unlock()
+	if lastNormalized.separator != separator || lastNormalized.durationUnit != durationUnit || !slices.Equal(lastNormalized.planNodes, planNodes) {
+		lastNormalized.planNodes = slices.Clone(planNodes)
+		lastNormalized.separator = separator
+		lastNormalized.durationUnit = durationUnit
+		lastNormalized.result = normalizePlanNodes(planNodes, separator, durationUnit)
+	}
+	if slices.Equal(lastNormalized.result, planNodes) {
+		return planNodes
+	}
+	// Callers may replace nodes of the slice
+	return slices.Clone(lastNormalized.result)
+}
+
+// normalizePlanNodes is normalizeStatUnits without memoization, with
+// durationUnit canonical.
+func normalizePlanNodes(planNodes []*sppb.PlanNode, separator, durationUnit string) []*sppb.PlanNode {
+	result, copied := planNodes, false
+	for i, node := range planNodes {
+		var clone *sppb.PlanNode
+		for name, v := range node.GetExecutionStats().GetFields() {
+			fields := v.GetStructValue().GetFields()
+			normalized := normalizeStat(fields, separator, durationUnit)
+			if normalized == nil {
+				continue
+			}
+			if clone == nil {
+				clone = proto.Clone(node).(*sppb.PlanNode)
+			}
+			clone.ExecutionStats.Fields[name] = structpb.NewStructValue(&structpb.Struct{Fields: normalized})
+		}
+		if clone == nil {
+			continue
+		}
+		if !copied {
+			result, copied = slices.Clone(planNodes), true
+		}
+		result[i] = clone
+	}
+	return result
+}
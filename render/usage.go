@@ -0,0 +1,153 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+)
+
+// usageCounters collects in-process usage statistics for the diagnostics
+// view. Counting is opt-in: nothing is recorded until enabled with
+// setUsageStatsEnabled, and counts never leave the page unless the frontend
+// sends them somewhere.
+type usageCounters struct {
+	mu              sync.Mutex
+	enabled         bool
+	rendersByFormat map[string]int
+	rendersByMode   map[string]int
+	errorsByType    map[string]int
+	cacheHits       int
+	cacheMisses     int
+}
+
+var usage = &usageCounters{}
+
+// UsageStats is the snapshot returned by getUsageStats
+type UsageStats struct {
+	Enabled         bool           `json:"enabled"`
+	RendersByFormat map[string]int `json:"rendersByFormat"`
+	RendersByMode   map[string]int `json:"rendersByMode"`
+	ErrorsByType    map[string]int `json:"errorsByType"`
+	CacheHits       int            `json:"cacheHits"`
+	CacheMisses     int            `json:"cacheMisses"`
+	// CacheHitRate is hits / (hits + misses), or 0 before the first lookup
+	CacheHitRate float64 `json:"cacheHitRate"`
+}
+
+func (u *usageCounters) setEnabled(enabled bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.enabled = enabled
+}
+
+// pause stops counting until the returned resume function is called, for
+// internal renders that should not show up in the statistics.
+func (u *usageCounters) pause() (resume func()) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	enabled := u.enabled
+	u.enabled = false
+	return func() { u.setEnabled(enabled) }
+}
+
+// countRender records a successful render. mode is empty for renderers
+// without render modes, such as the diagram exporters.
+func (u *usageCounters) countRender(format, mode string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.enabled {
+		return
+	}
+	if u.rendersByFormat == nil {
+		u.rendersByFormat = make(map[string]int)
+		u.rendersByMode = make(map[string]int)
+	}
+	u.rendersByFormat[strings.ToUpper(format)]++
+	if mode != "" {
+		u.rendersByMode[strings.ToUpper(mode)]++
+	}
+}
+
+func (u *usageCounters) countError(errorType string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.enabled {
+		return
+	}
+	if u.errorsByType == nil {
+		u.errorsByType = make(map[string]int)
+	}
+	u.errorsByType[errorType]++
+}
+
+func (u *usageCounters) countCacheLookup(hit bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.enabled {
+		return
+	}
+	if hit {
+		u.cacheHits++
+	} else {
+		u.cacheMisses++
+	}
+}
+
+// snapshot returns the current counts, clearing them when reset is true.
+func (u *usageCounters) snapshot(reset bool) UsageStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	stats := UsageStats{
+		Enabled:         u.enabled,
+		RendersByFormat: cloneCounts(u.rendersByFormat),
+		RendersByMode:   cloneCounts(u.rendersByMode),
+		ErrorsByType:    cloneCounts(u.errorsByType),
+		CacheHits:       u.cacheHits,
+		CacheMisses:     u.cacheMisses,
+	}
+	if lookups := u.cacheHits + u.cacheMisses; lookups > 0 {
+		stats.CacheHitRate = float64(u.cacheHits) / float64(lookups)
+	}
+	if reset {
+		u.rendersByFormat, u.rendersByMode, u.errorsByType = nil, nil, nil
+		u.cacheHits, u.cacheMisses = 0, 0
+	}
+	return stats
+}
+
+func cloneCounts(m map[string]int) map[string]int {
+	if m == nil {
+		return map[string]int{}
+	}
+	return maps.Clone(m)
+}
+
+type usageStatsParams struct {
+	Reset bool `json:"reset,omitempty"`
+}
+
+// getUsageStats returns the usage counters as JSON, optionally resetting them
+func getUsageStats(paramsJSON string) (Response, error) {
+	par := usageStatsParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	b, err := json.Marshal(usage.snapshot(par.Reset))
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal usage stats: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
+
+// SetUsageStatsEnabled turns usage counting on or off; counts are kept when
+// counting is turned off.
+func SetUsageStatsEnabled(enabled bool) {
+	usage.setEnabled(enabled)
+}
+
+// CountPanic counts a panic recovered by a frontend as a RENDER_ERROR.
+func CountPanic() {
+	usage.countError(ErrorTypeRenderError)
+}
@@ -0,0 +1,293 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// Warning codes reported by recover mode
+const (
+	WarningCodeSkippedInvalidNode = "SKIPPED_INVALID_NODE"
+	WarningCodeDroppedChildLink   = "DROPPED_CHILD_LINK"
+)
+
+// planIssue is a single structural problem found in the plan nodes.
+// link is the offending child link position, or -1 when the node itself is
+// invalid; badIndex is set when the node index is the problem.
+type planIssue struct {
+	node     int
+	link     int
+	badIndex bool
+	msg      string
+}
+
+// path returns the JSON path of the offending element.
+func (i planIssue) path(paths planPaths) string {
+	switch {
+	case i.link >= 0:
+		return paths.childIndex(i.node, i.link)
+	case i.badIndex:
+		return paths.nodeIndex(i.node)
+	default:
+		return paths.planNode(i.node)
+	}
+}
+
+// checkPlanNodes checks the structural invariants the renderers rely on:
+// every node's index matches its position, every child link points at an
+// existing node, and no link leads back to one of its ancestors. Links of a
+// node with a wrong index are checked too, as recover mode keeps them.
+func checkPlanNodes(planNodes []*sppb.PlanNode) []planIssue {
+	return append(checkPlanNodeLinks(planNodes), checkPlanCycles(planNodes)...)
+}
+
+// checkPlanNodeLinks checks node indexes and child link targets.
+func checkPlanNodeLinks(planNodes []*sppb.PlanNode) []planIssue {
+	var issues []planIssue
+	for i, node := range planNodes {
+		if node == nil {
+			issues = append(issues, planIssue{node: i, link: -1, msg: "Plan node is null"})
+			continue
+		}
+		if int(node.GetIndex()) != i {
+			issues = append(issues, planIssue{
+				node:     i,
+				link:     -1,
+				badIndex: true,
+				msg:      fmt.Sprintf("Plan node index %d does not match its position %d", node.GetIndex(), i),
+			})
+		}
+		for j, link := range node.GetChildLinks() {
+			childIndex := link.GetChildIndex()
+			if childIndex < 0 || int(childIndex) >= len(planNodes) {
+				issues = append(issues, planIssue{
+					node: i,
+					link: j,
+					msg:  fmt.Sprintf("Child link references plan node %d, but the plan has %d nodes", childIndex, len(planNodes)),
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// checkPlanCycles reports the child links that lead back to an ancestor
+// reachable from the root. Renderers recurse along child links, so such links
+// would never terminate. Null nodes and dangling links, which
+// checkPlanNodeLinks reports, are not followed.
+func checkPlanCycles(planNodes []*sppb.PlanNode) []planIssue {
+	var issues []planIssue
+	onPath := make([]bool, len(planNodes))
+	done := make([]bool, len(planNodes))
+	var visit func(i int)
+	visit = func(i int) {
+		done[i] = true
+		if planNodes[i] == nil {
+			return
+		}
+		onPath[i] = true
+		for j, link := range planNodes[i].GetChildLinks() {
+			child := int(link.GetChildIndex())
+			switch {
+			case child < 0 || child >= len(planNodes):
+			case onPath[child]:
+				issues = append(issues, planIssue{
+					node: i,
+					link: j,
+					msg:  fmt.Sprintf("Child link to plan node %d forms a cycle", child),
+				})
+			case !done[child]:
+				visit(child)
+			}
+		}
+		onPath[i] = false
+	}
+	if len(planNodes) > 0 {
+		visit(0)
+	}
+	return issues
+}
+
+// queryPlanNodes returns the plan nodes of stats, or an
+// InvalidSpannerFormatError when the query plan or its nodes are absent.
+// input is the plan input that stats was decoded from.
+func queryPlanNodes(stats *sppb.ResultSetStats, input string) ([]*sppb.PlanNode, error) {
+	queryPlan := stats.GetQueryPlan()
+	if queryPlan == nil {
+		return nil, InvalidSpannerFormatError{msg: "Query plan is missing from input", path: detectPlanPaths(input).queryPlan}
+	}
+	planNodes := queryPlan.GetPlanNodes()
+	if len(planNodes) == 0 {
+		return nil, InvalidSpannerFormatError{msg: "Plan nodes are missing from query plan", path: detectPlanPaths(input).planNodes}
+	}
+	return planNodes, nil
+}
+
+// validatePlanNodes returns every structural problem joined with errors.Join,
+// each as an InvalidSpannerFormatError carrying the JSON path of the
+// offending element in input, the plan input that planNodes were decoded
+// from.
+func validatePlanNodes(planNodes []*sppb.PlanNode, input string) error {
+	issues := checkPlanNodes(planNodes)
+	if len(issues) == 0 {
+		return nil
+	}
+	paths := detectPlanPaths(input)
+	errs := make([]error, len(issues))
+	for i, issue := range issues {
+		errs[i] = InvalidSpannerFormatError{msg: issue.msg, path: issue.path(paths)}
+	}
+	return errors.Join(errs...)
+}
+
+// flattenErrors expands errors combined with errors.Join, recursively, into
+// the list of individual errors in report order.
+func flattenErrors(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, flattenErrors(e)...)
+	}
+	return errs
+}
+
+// recoverPlanNodes returns planNodes with invalid nodes replaced by placeholder
+// nodes and dangling or cyclic child links removed, plus a warning for each
+// repair with the path of the problem in input, as validatePlanNodes.
+// Placeholders keep the valid child links of the node they replace, so that
+// the subtrees below still render. Valid nodes are shared with planNodes;
+// repaired nodes are new values.
+func recoverPlanNodes(planNodes []*sppb.PlanNode, input string) ([]*sppb.PlanNode, []Warning) {
+	issues := checkPlanNodes(planNodes)
+	if len(issues) == 0 {
+		return planNodes, nil
+	}
+	paths := detectPlanPaths(input)
+
+	invalid := make(map[int]string)
+	droppedLinks := make(map[int]map[int]bool)
+	warnings := make([]Warning, 0, len(issues))
+	for _, issue := range issues {
+		nodeID := int32(issue.node)
+		if issue.link < 0 {
+			invalid[issue.node] = issue.msg
+			warnings = append(warnings, Warning{
+				Code:    WarningCodeSkippedInvalidNode,
+				Message: fmt.Sprintf("Skipped invalid plan node %d: %s", issue.node, issue.msg),
+				NodeID:  &nodeID,
+				Path:    issue.path(paths),
+			})
+			continue
+		}
+		if droppedLinks[issue.node] == nil {
+			droppedLinks[issue.node] = make(map[int]bool)
+		}
+		droppedLinks[issue.node][issue.link] = true
+		warnings = append(warnings, Warning{
+			Code:    WarningCodeDroppedChildLink,
+			Message: fmt.Sprintf("Dropped child link of plan node %d: %s", issue.node, issue.msg),
+			NodeID:  &nodeID,
+			Path:    issue.path(paths),
+		})
+	}
+
+	recovered := slices.Clone(planNodes)
+	for i, node := range planNodes {
+		msg, isInvalid := invalid[i]
+		dropped := droppedLinks[i]
+		if !isInvalid && dropped == nil {
+			continue
+		}
+		links := make([]*sppb.PlanNode_ChildLink, 0, len(node.GetChildLinks()))
+		for j, link := range node.GetChildLinks() {
+			if !dropped[j] {
+				links = append(links, link)
+			}
+		}
+		if isInvalid {
+			recovered[i] = &sppb.PlanNode{
+				Index:       int32(i),
+				Kind:        sppb.PlanNode_RELATIONAL,
+				DisplayName: fmt.Sprintf("<invalid plan node: %s>", msg),
+				ChildLinks:  links,
+			}
+			continue
+		}
+		recovered[i] = &sppb.PlanNode{
+			Index:               node.GetIndex(),
+			Kind:                node.GetKind(),
+			DisplayName:         node.GetDisplayName(),
+			ChildLinks:          links,
+			ShortRepresentation: node.GetShortRepresentation(),
+			Metadata:            node.GetMetadata(),
+			ExecutionStats:      node.GetExecutionStats(),
+		}
+	}
+	return recovered, warnings
+}
+
+type validateInputParams struct {
+	Input         string `json:"input"`
+	InputEncoding string `json:"inputEncoding,omitempty"`
+	PlanIndex     *int   `json:"planIndex,omitempty"`
+}
+
+// InputValidation is returned by validateInput for valid input
+type InputValidation struct {
+	PlanNodes         int  `json:"planNodes"`
+	HasExecutionStats bool `json:"hasExecutionStats"`
+	// DetectedFormat is the input format that was understood
+	DetectedFormat string `json:"detectedFormat,omitempty"`
+	// Plans is the number of plans of the input; planIndex chooses the one
+	// validated
+	Plans int `json:"plans"`
+}
+
+// validateInput runs extraction and the structural checks of renderASCII
+// without rendering, for live feedback while the input is edited. Errors are
+// those renderASCII would report, including the position of syntax errors.
+func validateInput(paramsJSON string) (Response, error) {
+	par := validateInputParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return validateInputImpl(par)
+}
+
+func validateInputImpl(par validateInputParams) (Response, error) {
+	if err := checkInputEncoding(par.InputEncoding); err != nil {
+		return Response{}, err
+	}
+	plans, input := 1, par.Input
+	if par.InputEncoding != inputEncodingProtoBase64 {
+		var err error
+		if input, plans, err = selectPlan(par.Input, par.PlanIndex); err != nil {
+			return Response{}, err
+		}
+	}
+	stats, _, format, err := params{Input: par.Input, Options: Options{InputEncoding: par.InputEncoding, PlanIndex: par.PlanIndex}}.queryPlan()
+	if err != nil {
+		return Response{}, withInputHints(extractError(err), par.Input)
+	}
+	planNodes, err := queryPlanNodes(stats, input)
+	if err != nil {
+		return Response{}, withInputHints(err, par.Input)
+	}
+	if err := validatePlanNodes(planNodes, input); err != nil {
+		return Response{}, err
+	}
+
+	counts := countPlanNodes(planNodes)
+	b, err := json.Marshal(InputValidation{PlanNodes: counts.TotalNodes, HasExecutionStats: counts.HasExecutionStats, DetectedFormat: format, Plans: plans})
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal validation: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
@@ -0,0 +1,111 @@
+package render
+
+import (
+	"slices"
+	"testing"
+
+	sppb "cloud.google.com/go/spanner/apiv1/spannerpb"
+)
+
+// testPlanNode returns a relational plan node with links to children.
+func testPlanNode(index int32, name string, children ...int32) *sppb.PlanNode {
+	node := &sppb.PlanNode{Index: index, Kind: sppb.PlanNode_RELATIONAL, DisplayName: name}
+	for _, child := range children {
+		node.ChildLinks = append(node.ChildLinks, &sppb.PlanNode_ChildLink{ChildIndex: child})
+	}
+	return node
+}
+
+// reachableNames returns the display names of the nodes reachable from the
+// root, depth first, for plans that passed checkPlanNodes.
+func reachableNames(planNodes []*sppb.PlanNode) []string {
+	var names []string
+	var visit func(i int32)
+	visit = func(i int32) {
+		names = append(names, planNodes[i].GetDisplayName())
+		for _, link := range planNodes[i].GetChildLinks() {
+			visit(link.GetChildIndex())
+		}
+	}
+	visit(0)
+	return names
+}
+
+func TestRecoverPlanNodes(t *testing.T) {
+	type warning struct{ code, path string }
+	tests := []struct {
+		name         string
+		planNodes    []*sppb.PlanNode
+		wantNames    []string
+		wantWarnings []warning
+	}{
+		{
+			name:      "valid",
+			planNodes: []*sppb.PlanNode{testPlanNode(0, "Union", 1), testPlanNode(1, "Scan")},
+			wantNames: []string{"Union", "Scan"},
+		},
+		{
+			name:      "bad index keeps the subtree",
+			planNodes: []*sppb.PlanNode{testPlanNode(0, "Union", 1), testPlanNode(7, "Apply", 2), testPlanNode(2, "Scan")},
+			wantNames: []string{"Union", "<invalid plan node: Plan node index 7 does not match its position 1>", "Scan"},
+			wantWarnings: []warning{
+				{WarningCodeSkippedInvalidNode, "stats.queryPlan.planNodes[1].index"},
+			},
+		},
+		{
+			name:      "null node",
+			planNodes: []*sppb.PlanNode{testPlanNode(0, "Union", 1, 2), nil, testPlanNode(2, "Scan")},
+			wantNames: []string{"Union", "<invalid plan node: Plan node is null>", "Scan"},
+			wantWarnings: []warning{
+				{WarningCodeSkippedInvalidNode, "stats.queryPlan.planNodes[1]"},
+			},
+		},
+		{
+			name:      "dangling link",
+			planNodes: []*sppb.PlanNode{testPlanNode(0, "Union", 1, 5), testPlanNode(1, "Scan")},
+			wantNames: []string{"Union", "Scan"},
+			wantWarnings: []warning{
+				{WarningCodeDroppedChildLink, "stats.queryPlan.planNodes[0].childLinks[1].childIndex"},
+			},
+		},
+		{
+			name:      "cycle",
+			planNodes: []*sppb.PlanNode{testPlanNode(0, "Union", 1), testPlanNode(1, "Apply", 2), testPlanNode(2, "Scan", 1)},
+			wantNames: []string{"Union", "Apply", "Scan"},
+			wantWarnings: []warning{
+				{WarningCodeDroppedChildLink, "stats.queryPlan.planNodes[2].childLinks[0].childIndex"},
+			},
+		},
+		{
+			name:      "cycle through a bad index",
+			planNodes: []*sppb.PlanNode{testPlanNode(0, "Union", 1), testPlanNode(9, "Apply", 0, 2), testPlanNode(2, "Scan")},
+			wantNames: []string{"Union", "<invalid plan node: Plan node index 9 does not match its position 1>", "Scan"},
+			wantWarnings: []warning{
+				{WarningCodeSkippedInvalidNode, "stats.queryPlan.planNodes[1].index"},
+				{WarningCodeDroppedChildLink, "stats.queryPlan.planNodes[1].childLinks[0].childIndex"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := slices.Clone(tt.planNodes)
+			recovered, warnings := recoverPlanNodes(tt.planNodes, "")
+			if issues := checkPlanNodes(recovered); len(issues) != 0 {
+				t.Fatalf("recovered plan has issues: %+v", issues)
+			}
+			if got := reachableNames(recovered); !slices.Equal(got, tt.wantNames) {
+				t.Errorf("reachable nodes = %q, want %q", got, tt.wantNames)
+			}
+			var got []warning
+			for _, w := range warnings {
+				got = append(got, warning{w.Code, w.Path})
+			}
+			if !slices.Equal(got, tt.wantWarnings) {
+				t.Errorf("warnings = %+v, want %+v", got, tt.wantWarnings)
+			}
+			if !slices.Equal(tt.planNodes, input) {
+				t.Error("recoverPlanNodes modified its input")
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// variablesTitle is the title of the section of the variableLinks option.
+const variablesTitle = "Variables(identified by ID):"
+
+// VariableDefinition is a variable of a plan: the child link of OperatorID
+// that binds it to the node NodeID, scalar or relational. Structs and batches
+// such as "v2", of the fields "v2.Batch", are defined by the operator that
+// builds them, both NodeID and OperatorID.
+type VariableDefinition struct {
+	Variable   string `json:"variable"`
+	NodeID     int32  `json:"nodeId"`
+	OperatorID int32  `json:"operatorId"`
+}
+
+// VariableReference is a reference in a scalar description to the variable
+// bound to the node DefinedBy.
+type VariableReference struct {
+	// Reference is the reference as written, e.g. "$v2.Batch"
+	Reference string `json:"reference"`
+	Variable  string `json:"variable"`
+	DefinedBy int32  `json:"definedBy"`
+}
+
+// variableDefinitions returns the variables bound by the child links of the
+// nodes of tree by name. As in scalarExpander, names bound to different
+// expressions in different subtrees are ambiguous and are left out.
+func variableDefinitions(tree *planTree) map[string]VariableDefinition {
+	defs := make(map[string]VariableDefinition)
+	ambiguous := make(map[string]bool)
+	for _, n := range tree.nodes {
+		for _, link := range n.node.GetChildLinks() {
+			name := link.GetVariable()
+			if name == "" {
+				continue
+			}
+			child := tree.nodes[link.GetChildIndex()]
+			if prev, ok := defs[name]; ok && prev.NodeID != child.id() {
+				other := tree.nodes[prev.NodeID]
+				if child.isRelational() || other.isRelational() ||
+					child.node.GetShortRepresentation().GetDescription() != other.node.GetShortRepresentation().GetDescription() {
+					ambiguous[name] = true
+				}
+			}
+			defs[name] = VariableDefinition{Variable: name, NodeID: child.id(), OperatorID: n.id()}
+		}
+	}
+	// A field such as "v2.Batch" is of the struct or batch "v2" that its
+	// operator builds, as scanned by "Batch Scan on $v2"
+	for name, def := range defs {
+		parent, _, ok := strings.Cut(name, ".")
+		if !ok {
+			continue
+		}
+		switch prev, defined := defs[parent]; {
+		case !defined:
+			defs[parent] = VariableDefinition{Variable: parent, NodeID: def.OperatorID, OperatorID: def.OperatorID}
+		case prev.NodeID != def.OperatorID:
+			ambiguous[parent] = true
+		}
+	}
+	for name := range ambiguous {
+		delete(defs, name)
+	}
+	return defs
+}
+
+// variableReferences returns the references of description to the variables
+// of defs in order, each once. The longest defined name is preferred, as
+// "$v2.Batch" may refer to "v2". self, the node of the description, is not a
+// definition of its own references.
+func variableReferences(description string, defs map[string]VariableDefinition, self int32) []VariableReference {
+	var refs []VariableReference
+	for _, ref := range variableRefPattern.FindAllString(description, -1) {
+		for name := ref[1:]; name != ""; name = name[:len(name)-1] {
+			def, ok := defs[name]
+			if !ok {
+				continue
+			}
+			if def.NodeID != self && !slices.ContainsFunc(refs, func(r VariableReference) bool { return r.Reference == ref }) {
+				refs = append(refs, VariableReference{Reference: ref, Variable: name, DefinedBy: def.NodeID})
+			}
+			break
+		}
+	}
+	return refs
+}
+
+// operatorVariableReferences returns the references of the scan target and
+// the scalar subtrees, which are the expressions, of the operator n.
+func operatorVariableReferences(n *treeNode, defs map[string]VariableDefinition) []VariableReference {
+	refs := variableReferences(valueString(n.node.GetMetadata().GetFields()["scan_target"]), defs, n.id())
+	var visit func(*treeNode)
+	visit = func(s *treeNode) {
+		for _, r := range variableReferences(s.node.GetShortRepresentation().GetDescription(), defs, s.id()) {
+			if !slices.Contains(refs, r) {
+				refs = append(refs, r)
+			}
+		}
+		for _, c := range s.children {
+			if !c.node.isRelational() {
+				visit(c.node)
+			}
+		}
+	}
+	for _, c := range n.children {
+		if !c.node.isRelational() {
+			visit(c.node)
+		}
+	}
+	return refs
+}
+
+// variableLinksText lists the variables each operator of tree refers to with
+// the node that defines them, limited to the operators of subtree if not nil,
+// e.g.
+//
+//	Variables(identified by ID):
+//	 0: $SongCount ← node 5
+//	    $group_SongGenre' ← node 4
+func variableLinksText(tree *planTree, subtree map[int32]bool, charset string) string {
+	arrow := "←"
+	if charset == charsetASCII {
+		arrow = "<-"
+	}
+	defs := variableDefinitions(tree)
+	type item struct {
+		id    int32
+		lines []string
+	}
+	var items []item
+	width := 0
+	tree.root.walk(func(n *treeNode) {
+		if subtree != nil && !subtree[n.id()] {
+			return
+		}
+		refs := operatorVariableReferences(n, defs)
+		if len(refs) == 0 {
+			return
+		}
+		lines := make([]string, len(refs))
+		for i, r := range refs {
+			lines[i] = fmt.Sprintf("%s %s node %d", r.Reference, arrow, r.DefinedBy)
+		}
+		items = append(items, item{n.id(), lines})
+		width = max(width, len(fmt.Sprint(n.id())))
+	})
+	if len(items) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(variablesTitle + "\n")
+	for _, it := range items {
+		for i, line := range it.lines {
+			label := ""
+			if i == 0 {
+				label = fmt.Sprintf("%d:", it.id)
+			}
+			fmt.Fprintf(&b, " %*s %s\n", width+1, label, line)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// BuildTime is the time of the build reported by getVersionInfo. The
+// frontends set it from their own variable, which the build scripts set with
+// -ldflags "-X main.buildTime=2025-01-02T03:04:05Z".
+var BuildTime string
+
+// spannerplanModule is the renderer module reported separately by
+// getVersionInfo, as most rendering bugs are fixed there.
+const spannerplanModule = "github.com/apstndb/spannerplan"
+
+// VersionInfo is returned by getVersionInfo
+type VersionInfo struct {
+	// Version is the module version stamped by the Go toolchain, e.g. a
+	// pseudo-version of the commit, or "(devel)"
+	Version            string `json:"version"`
+	GoVersion          string `json:"goVersion"`
+	SpannerplanVersion string `json:"spannerplanVersion,omitempty"`
+	// Revision, RevisionTime, and Modified describe the commit built from
+	Revision     string `json:"revision,omitempty"`
+	RevisionTime string `json:"revisionTime,omitempty"`
+	Modified     bool   `json:"modified,omitempty"`
+	// BuildTime is the time of the build, if the build scripts set it
+	BuildTime string `json:"buildTime,omitempty"`
+	// Dependencies maps module paths to the versions linked in
+	Dependencies map[string]string `json:"dependencies"`
+	// Features lists the optional features compiled in
+	Features []string `json:"features"`
+}
+
+func buildVersionInfo() VersionInfo {
+	info := VersionInfo{
+		GoVersion:    runtime.Version(),
+		BuildTime:    BuildTime,
+		Dependencies: make(map[string]string),
+		Features:     []string{},
+	}
+	for _, f := range registeredFeatures {
+		info.Features = append(info.Features, f.Name)
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Version = bi.Main.Version
+	for _, dep := range bi.Deps {
+		version := dep.Version
+		if dep.Replace != nil {
+			version = fmt.Sprintf("%s => %s %s", version, dep.Replace.Path, dep.Replace.Version)
+		}
+		info.Dependencies[dep.Path] = version
+	}
+	info.SpannerplanVersion = info.Dependencies[spannerplanModule]
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.RevisionTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// getVersionInfo returns the VersionInfo of this build as JSON, for bug
+// reports
+func getVersionInfo(string) (Response, error) {
+	b, err := json.Marshal(buildVersionInfo())
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal version info: %v", err)}
+	}
+	return Response{Result: string(b)}, nil
+}
@@ -0,0 +1,27 @@
+package render
+
+// warningCollector gathers the warnings of a render for Response.Warnings,
+// so that stages report non-fatal problems, such as ignored options, missing
+// stats, or truncated output, where they find them. The zero value is ready
+// to use.
+type warningCollector struct {
+	warnings []Warning
+}
+
+// add reports a problem that is not tied to a plan node.
+func (c *warningCollector) add(code, message string) {
+	c.warnings = append(c.warnings, Warning{Code: code, Message: message})
+}
+
+// addAll reports warnings of a stage that returns them.
+func (c *warningCollector) addAll(warnings []Warning) {
+	c.warnings = append(c.warnings, warnings...)
+}
+
+// list returns the warnings in the order they were reported, or nil.
+func (c *warningCollector) list() []Warning {
+	if len(c.warnings) == 0 {
+		return nil
+	}
+	return c.warnings
+}
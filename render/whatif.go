@@ -0,0 +1,260 @@
+//go:build !nolint
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Kinds of what-if suggestions
+const (
+	whatIfKindDDL  = "ddl"
+	whatIfKindHint = "hint"
+)
+
+// WhatIfSuggestion is a concrete change to try for a suggestWhatIf finding.
+// Suggestions are derived from the plan alone, without the schema, so they
+// are starting points to review rather than changes to apply as is.
+type WhatIfSuggestion struct {
+	// Kind is "ddl" for schema changes and "hint" for query hints
+	Kind string `json:"kind"`
+	// Statement is the DDL statement, or the table reference with the hint
+	Statement string `json:"statement"`
+	Rationale string `json:"rationale"`
+}
+
+// whatIfRules are the analyses of suggestWhatIf. Their findings carry
+// Suggestions.
+var whatIfRules = []lintRule{
+	{name: "selective-residual-filter", severity: severityInfo, docURL: "https://cloud.google.com/spanner/docs/secondary-indexes", check: checkSelectiveResidualFilter},
+	{name: "sort-before-limit", severity: severityInfo, docURL: "https://cloud.google.com/spanner/docs/secondary-indexes", check: checkSortBeforeLimit},
+}
+
+// suggestWhatIf returns experimental what-if suggestions, such as candidate
+// indexes, for the operators of the plan
+func suggestWhatIf(paramsJSON string) (Response, error) {
+	par := lintParams{}
+	if err := json.Unmarshal([]byte(paramsJSON), &par); err != nil {
+		return Response{}, ParseError{msg: fmt.Sprintf("Failed to parse parameters: %v", err)}
+	}
+	return suggestWhatIfImpl(par)
+}
+
+// suggestWhatIfImpl returns the findings of the what-if rules as a JSON array
+// in Response.Result.
+func suggestWhatIfImpl(par lintParams) (Response, error) {
+	if err := par.Thresholds.check(); err != nil {
+		return Response{}, err
+	}
+	stats, _, warnings, err := loadPlanVizStats(planVizParams{Input: par.Input, Recover: par.Recover})
+	if err != nil {
+		return Response{}, err
+	}
+	tree := buildPlanTree(stats.GetQueryPlan().GetPlanNodes())
+	t := par.Thresholds.withDefaults()
+
+	findings := []Finding{}
+	tree.root.walk(func(n *treeNode) {
+		for _, rule := range whatIfRules {
+			for _, f := range rule.check(n, t) {
+				findings = append(findings, completeFinding(f, rule.severity, rule.docURL))
+			}
+		}
+	})
+	b, err := json.Marshal(findings)
+	if err != nil {
+		return Response{}, RenderError{msg: fmt.Sprintf("Failed to marshal findings: %v", err)}
+	}
+	return Response{Result: string(b), Warnings: warnings}, nil
+}
+
+// checkSelectiveResidualFilter suggests an index on the filtered columns for
+// a Filter Scan whose residual condition discards all but t.SelectiveFilterRatio
+// of the rows of a full table scan. Without execution stats the selectivity
+// is unknown and the suggestion is made anyway.
+func checkSelectiveResidualFilter(n *treeNode, t Thresholds) []Finding {
+	if n.node.GetDisplayName() != "Filter Scan" {
+		return nil
+	}
+	cond := scalarChildDescription(n, "Residual Condition")
+	scan := fullTableScanChild(n)
+	if cond == "" || scan == nil {
+		return nil
+	}
+	columns := scanColumnsOf(scan, variableRefs(cond))
+	if len(columns) == 0 {
+		return nil
+	}
+
+	kept := "an unknown fraction"
+	if out, ok := n.stat("rows"); ok {
+		if in, ok := scan.stat("rows"); ok && in > 0 {
+			if out/in > t.SelectiveFilterRatio {
+				return nil
+			}
+			kept = fmt.Sprintf("%s of %s rows", formatCount(out), formatCount(in))
+		}
+	}
+
+	table := valueString(scan.node.GetMetadata().GetFields()["scan_target"])
+	f := nodeFinding("selective-residual-filter", n,
+		"The residual condition %s keeps %s of a full scan of %s; an index on %s(%s) would seek to the matching rows instead",
+		cond, kept, table, table, strings.Join(columns, ", "))
+	f.NodeIDs = []int32{n.id(), scan.id()}
+	f.Suggestions = indexSuggestions(table, columns, nil,
+		"Add a STORING clause for the other non-key columns the query reads to avoid a back join to the table.")
+	return []Finding{f}
+}
+
+// checkSortBeforeLimit suggests an index in the sort order for a sort of at
+// least t.LargeSortRows rows below a limit, so that the limit could stop
+// reading early instead of sorting every row.
+func checkSortBeforeLimit(n *treeNode, t Thresholds) []Finding {
+	switch n.node.GetDisplayName() {
+	case "Sort Limit":
+	case "Sort":
+		if !hasLimitAbove(n) {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	var scan *treeNode
+	n.walk(func(d *treeNode) {
+		if scan == nil && d.node.GetDisplayName() == "Scan" && valueString(d.node.GetMetadata().GetFields()["scan_type"]) == "TableScan" {
+			scan = d
+		}
+	})
+	if scan == nil {
+		return nil
+	}
+	var refs []string
+	var descending []bool
+	for _, c := range n.children {
+		if c.link.GetType() != "Key" || c.node.isRelational() {
+			continue
+		}
+		fields := strings.Fields(c.node.node.GetShortRepresentation().GetDescription())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "$") {
+			return nil
+		}
+		refs = append(refs, strings.TrimPrefix(fields[0], "$"))
+		descending = append(descending, len(fields) > 1 && strings.EqualFold(fields[1], "DESC"))
+	}
+	columns := scanColumnsOf(scan, refs)
+	// Every key must be a column of the table for an index to give the order
+	if len(columns) == 0 || len(columns) != len(refs) {
+		return nil
+	}
+
+	sorted := "its input"
+	if input := n.relationalChildren(); len(input) > 0 {
+		if rows, ok := input[0].stat("rows"); ok {
+			if rows < t.LargeSortRows {
+				return nil
+			}
+			sorted = formatCount(rows) + " rows"
+		}
+	}
+
+	table := valueString(scan.node.GetMetadata().GetFields()["scan_target"])
+	f := nodeFinding("sort-before-limit", n,
+		"%s sorts %s before a limit; an index on %s(%s) could return the first rows in order without sorting",
+		n.title(), sorted, table, strings.Join(columns, ", "))
+	f.NodeIDs = []int32{n.id(), scan.id()}
+	f.Suggestions = indexSuggestions(table, columns, descending,
+		"The limit only stops early if no operator between the scan and the limit needs every row.")
+	return []Finding{f}
+}
+
+// fullTableScanChild returns the relational child of n that is a full scan of
+// a table, or nil.
+func fullTableScanChild(n *treeNode) *treeNode {
+	for _, c := range n.relationalChildren() {
+		fields := c.node.GetMetadata().GetFields()
+		if c.node.GetDisplayName() == "Scan" && valueString(fields["scan_type"]) == "TableScan" && valueString(fields["Full scan"]) == "true" {
+			return c
+		}
+	}
+	return nil
+}
+
+// hasLimitAbove reports whether a limit is applied to the output of n,
+// looking through the operators that pass rows up unchanged.
+func hasLimitAbove(n *treeNode) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		name := p.node.GetDisplayName()
+		switch {
+		case strings.HasSuffix(name, "Limit"):
+			return true
+		case name == "Distributed Union" || name == "Serialize Result" || name == "Compute":
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// variableRefs returns the variables referenced by a scalar expression in
+// order of first use, e.g. ["LastName"] for "($LastName = @last_name)".
+func variableRefs(expr string) []string {
+	var refs []string
+	for _, m := range variableRefPattern.FindAllString(expr, -1) {
+		if v := strings.TrimPrefix(m, "$"); !slices.Contains(refs, v) {
+			refs = append(refs, v)
+		}
+	}
+	return refs
+}
+
+// scanColumnsOf returns the columns of scan that the variables refer to, in
+// order. Variables defined by other operators are skipped.
+func scanColumnsOf(scan *treeNode, variables []string) []string {
+	var columns []string
+	for _, v := range variables {
+		for _, c := range scan.children {
+			if c.link.GetVariable() != v || c.node.isRelational() {
+				continue
+			}
+			column := c.node.node.GetShortRepresentation().GetDescription()
+			if column == "" {
+				column = v
+			}
+			if !slices.Contains(columns, column) {
+				columns = append(columns, column)
+			}
+			break
+		}
+	}
+	return columns
+}
+
+// indexSuggestions returns the CREATE INDEX statement of a candidate index on
+// columns of table, named like SingersByLastName, and the FORCE_INDEX hint
+// that makes the query use it. descending marks the DESC columns, if any.
+func indexSuggestions(table string, columns []string, descending []bool, note string) []WhatIfSuggestion {
+	index := table + "By" + strings.Join(columns, "")
+	keys := make([]string, len(columns))
+	for i, c := range columns {
+		keys[i] = c
+		if i < len(descending) && descending[i] {
+			keys[i] += " DESC"
+		}
+	}
+	return []WhatIfSuggestion{
+		{
+			Kind:      whatIfKindDDL,
+			Statement: fmt.Sprintf("CREATE INDEX %s ON %s(%s)", index, table, strings.Join(keys, ", ")),
+			Rationale: "Candidate index; check that no existing index already starts with these columns. " + note,
+		},
+		{
+			Kind:      whatIfKindHint,
+			Statement: fmt.Sprintf("%s@{FORCE_INDEX=%s}", table, index),
+			Rationale: "Table hint to compare the plan with the index against this one once it exists.",
+		},
+	}
+}
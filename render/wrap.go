@@ -0,0 +1,191 @@
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Wrap modes of the table formats. The library wraps at wrapWidth mid-token
+// ("char"); "word" and "smart" rewrap its Operator cells at the same widths.
+const (
+	wrapModeChar  = "char"
+	wrapModeWord  = "word"
+	wrapModeSmart = "smart"
+)
+
+// checkWrapMode validates the wrapMode parameter; "" is "char".
+func checkWrapMode(mode string) error {
+	switch mode {
+	case "", wrapModeChar, wrapModeWord, wrapModeSmart:
+		return nil
+	}
+	return InvalidParametersError{msg: fmt.Sprintf("Invalid wrap mode: %q (expected %q, %q, or %q)", mode, wrapModeChar, wrapModeWord, wrapModeSmart)}
+}
+
+// rewrapOperatorColumn rewraps the Operator cells of wrapped, the table as
+// wrapped by the library, by the wrap mode. unwrapped is the same table
+// rendered without wrapping, which has the full text of each cell. Each
+// wrapped row keeps its width and the prefix of its continuation lines, so
+// the column keeps its width; rows may get more lines. Lines after the table
+// are unchanged.
+func rewrapOperatorColumn(wrapped, unwrapped, mode string) string {
+	full := operatorCells(unwrapped)
+	lines := strings.Split(wrapped, "\n")
+	headEnd, left, right, ok := findOperatorColumn(lines)
+	if !ok || full == nil {
+		return wrapped
+	}
+
+	out := slices.Clone(lines[:headEnd+1])
+	i := headEnd + 1
+	for i < len(lines) && strings.HasPrefix(lines[i], "|") {
+		end := i + 1
+		for end < len(lines) && strings.HasPrefix(lines[end], "|") {
+			if _, _, ok := tableRowID(lines[end]); ok {
+				break
+			}
+			end++
+		}
+		row := lines[i:end]
+		i = end
+		id, _, ok := tableRowID(row[0])
+		cell, found := full[id]
+		if !ok || !found || len(row) == 1 {
+			out = append(out, row...)
+			continue
+		}
+		out = append(out, rewrapRow(row, cell, left, right, mode)...)
+	}
+	return strings.Join(append(out, lines[i:]...), "\n")
+}
+
+// operatorCells returns the Operator cell of each row of the unwrapped table
+// at the start of rendered, without trailing padding.
+func operatorCells(rendered string) map[int32]string {
+	lines := strings.Split(rendered, "\n")
+	headEnd, left, right, ok := findOperatorColumn(lines)
+	if !ok {
+		return nil
+	}
+	cells := make(map[int32]string)
+	for _, line := range lines[headEnd+1:] {
+		if !strings.HasPrefix(line, "|") {
+			break
+		}
+		id, _, ok := tableRowID(line)
+		if !ok {
+			continue
+		}
+		if cell, ok := cellText(line, left, right); ok {
+			cells[id] = strings.TrimRight(cell, " ")
+		}
+	}
+	return cells
+}
+
+// rewrapRow rewraps the Operator cell of the lines of a row wrapped by the
+// library to the text of full, its unwrapped cell. The library fills every
+// line but the last, so its first line gives the width of the first line and
+// any full continuation lines the width of the others. Lines after the
+// library's use the last line with its other cells blanked.
+func rewrapRow(row []string, full string, left, right int, mode string) []string {
+	cells := make([]string, len(row))
+	for k, line := range row {
+		cell, ok := cellText(line, left, right)
+		if !ok {
+			return row
+		}
+		cells[k] = strings.TrimRight(cell, " ")
+	}
+	prefix, text := splitTreePrefix(full)
+	cont, _ := splitTreePrefix(cells[1])
+	// Cells need a space before the separator
+	column := right - left - 1
+	first := displayWidth(cells[0]) - displayWidth(prefix)
+	rest := column - 1 - displayWidth(cont)
+	if len(row) > 2 {
+		rest = 0
+		for _, c := range cells[1 : len(cells)-1] {
+			rest = max(rest, displayWidth(c)-displayWidth(cont))
+		}
+	} else {
+		rest = min(rest, first)
+	}
+
+	var blank strings.Builder
+	for _, r := range row[len(row)-1] {
+		if r == '|' {
+			blank.WriteRune(r)
+		} else {
+			blank.WriteString(strings.Repeat(" ", displayWidth(string(r))))
+		}
+	}
+	var out []string
+	for k, piece := range wrapText(text, first, rest, mode) {
+		lead, template := cont, blank.String()
+		if k == 0 {
+			lead = prefix
+		}
+		if k < len(row) {
+			template = row[k]
+		}
+		cell := lead + piece
+		cell += strings.Repeat(" ", max(column-displayWidth(cell), 0))
+		out = append(out, replaceCell(template, left, right, cell))
+	}
+	return out
+}
+
+// wrapText wraps text to lines of at most first display columns for the
+// first line and rest columns for the others, breaking where canBreak
+// allows. Tokens longer than a line are broken mid-token. Spaces around
+// breaks are dropped.
+func wrapText(text string, first, rest int, mode string) []string {
+	var lines []string
+	runes := []rune(text)
+	for width := max(first, 1); displayWidth(string(runes)) > width; width = max(rest, 1) {
+		// fit is the number of runes that fit in width, at least one
+		fit, w := 0, 0
+		for fit < len(runes) && w+displayWidth(string(runes[fit])) <= width {
+			w += displayWidth(string(runes[fit]))
+			fit++
+		}
+		if fit == len(runes) {
+			break
+		}
+		fit = max(fit, 1)
+		n := fit
+		for i := fit; i > 0; i-- {
+			if canBreak(runes, i, mode) {
+				n = i
+				break
+			}
+		}
+		line := strings.TrimRight(string(runes[:n]), " ")
+		if line == "" {
+			line, n = string(runes[:fit]), fit
+		}
+		lines = append(lines, line)
+		runes = []rune(strings.TrimLeft(string(runes[n:]), " "))
+	}
+	return append(lines, string(runes))
+}
+
+// canBreak reports whether a line may end before runes[i]: at spaces, and
+// in "smart" mode after commas, opening parentheses, and closing ones that
+// are not followed by more punctuation.
+func canBreak(runes []rune, i int, mode string) bool {
+	prev, next := runes[i-1], runes[i]
+	switch {
+	case prev == ' ' || next == ' ':
+		return true
+	case mode != wrapModeSmart:
+		return false
+	case prev == ',' || prev == '(':
+		return true
+	case prev == ')':
+		return !strings.ContainsRune(",)", next)
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// stopped is closed by shutdown, which makes main return and the Go runtime
+// exit.
+var stopped = make(chan struct{})
+
+func init() {
+	registerJSExports(directExport, map[string]exportFunc{
+		"shutdown": shutdown,
+	})
+}
+
+// shutdown stops the module so that the frontend can instantiate a new one,
+// such as after a panic left it in a bad state. It cancels the running render
+// jobs, drops the state of the render package, and removes every export from
+// globalThis. main returns on the next turn of the event loop, after the
+// response, or the Promise of shutdownAsync, has been delivered; the Promise
+// of Go.run then resolves.
+func shutdown(_ js.Value, args []js.Value) any {
+	if len(args) != 0 {
+		return errorResponse(render.ErrorTypeInvalidParameters,
+			"Invalid number of arguments",
+			fmt.Sprintf("Expected 0 arguments, got %d", len(args)))
+	}
+	activeJobs.cancelAll()
+	render.Shutdown()
+	unexportFeatures()
+
+	var stop js.Func
+	stop = js.FuncOf(func(js.Value, []js.Value) any {
+		stop.Release()
+		close(stopped)
+		return nil
+	})
+	js.Global().Call("setTimeout", stop, 0)
+	return marshalResponse(render.Respond(render.Response{}, nil))
+}
@@ -5,13 +5,18 @@
  * They help prevent type drift between the Go WASM module and TypeScript interfaces.
  * 
  * When adding new types or constants:
- * 1. Update the corresponding Go structs/constants in main.go
- * 2. Update the TypeScript interfaces in src/types/wasm.ts
- * 3. Update these tests to include the new values
+ * 1. Update the corresponding Go structs/constants in render/
+ * 2. Regenerate src/types/generated.d.ts with `go generate ./render`
+ * 3. Update the TypeScript interfaces in src/types/wasm.ts
+ * 4. Update these tests to include the new values
  */
 
-import { describe, it, expect } from 'vitest';
-import type { WasmErrorType, RenderMode, FormatType, PrintSection } from '../wasm.js';
+import { describe, it, expect, expectTypeOf } from 'vitest';
+import type * as Generated from '../generated.js';
+import type {
+  WasmErrorType, RenderMode, FormatType, PrintSection, RenderParams, WasmResponse,
+  WasmError, WasmErrorHint, WasmIssue, WasmWarning, WasmResponseMetadata, PlanCounts, DMLSummary, DMLOperation,
+} from '../wasm.js';
 
 describe('Go-TypeScript Type Synchronization', () => {
   describe('Error Type Constants', () => {
@@ -67,13 +72,15 @@ describe('Go-TypeScript Type Synchronization', () => {
       const expectedGoRenderModes = [
         'AUTO',    // Go: RenderModeAuto
         'PLAN',    // Go: RenderModePlan  
-        'PROFILE'  // Go: RenderModeProfile
+        'PROFILE', // Go: RenderModeProfile
+        'TIMELINE' // Go: renderModeTimeline
       ];
 
       const typeScriptRenderModes: RenderMode[] = [
         'AUTO',
         'PLAN', 
-        'PROFILE'
+        'PROFILE',
+        'TIMELINE'
       ];
 
       expect(typeScriptRenderModes).toHaveLength(expectedGoRenderModes.length);
@@ -83,12 +90,13 @@ describe('Go-TypeScript Type Synchronization', () => {
     });
 
     it('should not have extra render modes not defined in Go', () => {
-      const validRenderModes = ['AUTO', 'PLAN', 'PROFILE'];
+      const validRenderModes = ['AUTO', 'PLAN', 'PROFILE', 'TIMELINE'];
 
       const testRenderModes: RenderMode[] = [
         'AUTO',
         'PLAN',
-        'PROFILE'
+        'PROFILE',
+        'TIMELINE'
       ];
 
       testRenderModes.forEach(mode => {
@@ -268,4 +276,30 @@ describe('Go-TypeScript Type Synchronization', () => {
       expect(noWrapParams.hangingIndent).toBe(false);
     });
   });
+
+  describe('Generated Definitions', () => {
+    // generated.d.ts is generated from the Go types, so these fail type
+    // checking when a hand-written type misses or adds a field
+    it('should have the fields of the Go request and response structs', () => {
+      expectTypeOf<keyof RenderParams>().toEqualTypeOf<keyof Generated.RenderParams>();
+      expectTypeOf<keyof WasmResponse>().toEqualTypeOf<keyof Generated.Response>();
+      expectTypeOf<keyof WasmError>().toEqualTypeOf<keyof Generated.Error>();
+      expectTypeOf<keyof WasmIssue>().toEqualTypeOf<keyof Generated.Issue>();
+      expectTypeOf<keyof WasmErrorHint>().toEqualTypeOf<keyof Generated.ErrorHint>();
+      expectTypeOf<keyof WasmWarning>().toEqualTypeOf<keyof Generated.Warning>();
+      expectTypeOf<keyof WasmResponseMetadata>().toEqualTypeOf<keyof Generated.ResponseMetadata>();
+      expectTypeOf<keyof PlanCounts>().toEqualTypeOf<keyof Generated.PlanCounts>();
+      expectTypeOf<keyof DMLSummary>().toEqualTypeOf<keyof Generated.DMLSummary>();
+      expectTypeOf<keyof DMLOperation>().toEqualTypeOf<keyof Generated.DMLOperation>();
+    });
+
+    it('should have the Go error type constants', () => {
+      expectTypeOf<WasmErrorType>().toEqualTypeOf<Generated.ErrorType>();
+    });
+
+    it('should accept requests and responses of the hand-written types', () => {
+      expectTypeOf<RenderParams>().toExtend<Generated.RenderParams>();
+      expectTypeOf<WasmResponse>().toExtend<Generated.Response>();
+    });
+  });
 });
@@ -6,10 +6,11 @@
  * They provide the highest confidence in type synchronization.
  */
 
-import { describe, it, expect, beforeAll } from 'vitest';
+import { describe, it, expect, beforeAll, afterEach } from 'vitest';
 import { readFileSync } from 'fs';
 import { join } from 'path';
-import type { WasmResponse, RenderParams, RenderMermaidParams } from '../wasm.js';
+import { gzipSync } from 'zlib';
+import type { WasmResponse, RenderParams, LogHandlerCallback, LogRecord, Capabilities, ColumnConfig, InputValidation, JoinReport, RenderMermaidParams, GlossaryEntry, OperatorDescription, FormatterCallback, FormatterModel, LintFinding, LintRuleCallback, LintRuleScope, CriticalPath, MemoryStats, FanOutReport, NodeDetail, ParsedPlan, PlanFingerprint, PlanRegression, PlanRow, PlanSummary, QueryInfo, RenderJob, RenderPreset, RenderRangeResult, ScanReport, SampleInfo, SearchResult, SelfTestReport, SessionPlanInfo, StatDistribution, StructureDiff, Thresholds, UsageStats, VersionInfo } from '../wasm.js';
 
 // Global declarations for WASM environment
 declare global {
@@ -17,12 +18,18 @@ declare global {
     importObject: WebAssembly.Imports;
     run: (instance: WebAssembly.Instance) => Promise<void>;
     exited: boolean;
+    _scheduledTimeouts: Map<number, ReturnType<typeof setTimeout>>;
   };
   var renderASCII: (paramsJson: string) => string;
   var renderMermaid: (paramsJson: string) => string;
   var renderD2: (paramsJson: string) => string;
 }
 
+// runGo runs a Go program like go.run, then clears the timeouts that the Go
+// scheduler leaves behind at exit, which throw when they fire after it
+const runGo = (go: InstanceType<typeof Go>, instance: WebAssembly.Instance): Promise<void> =>
+  go.run(instance).then(() => go._scheduledTimeouts.forEach(clearTimeout));
+
 const scalarAppendixInput = `
 stats:
   queryPlan:
@@ -96,6 +103,12 @@ stats:
           description: "COUNT()"
 `;
 
+// callWasm invokes a WASM export by name with JSON-encoded params
+function callWasm(name: string, params: unknown): WasmResponse {
+  const fn = (globalThis as Record<string, unknown>)[name] as (paramsJson: string) => string;
+  return JSON.parse(fn(JSON.stringify(params)));
+}
+
 describe('WASM Node.js Integration Tests', () => {
   let renderASCII: (paramsJson: string) => string;
   let renderMermaid: (paramsJson: string) => string;
@@ -153,6 +166,7 @@ describe('WASM Node.js Integration Tests', () => {
       importObject: WebAssembly.Imports;
       run: (instance: WebAssembly.Instance) => Promise<void>;
       exited: boolean;
+      _scheduledTimeouts: Map<number, ReturnType<typeof setTimeout>>;
     };
     const go = new GoClass();
     const wasmBytes = readFileSync(wasmPath);
@@ -161,7 +175,7 @@ describe('WASM Node.js Integration Tests', () => {
     const wasmModule = await WebAssembly.instantiate(wasmBytes, go.importObject);
     
     // Start Go runtime (this will expose renderASCII globally)
-    const _runPromise = go.run(wasmModule.instance);
+    const _runPromise = runGo(go, wasmModule.instance);
     
     // Wait a bit for Go runtime to initialize
     await new Promise(resolve => setTimeout(resolve, 100));
@@ -362,6 +376,50 @@ stats:
     });
   });
 
+  describe('edgeRows', () => {
+    const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        executionStats:
+          rows: { total: "2", unit: "rows" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        executionStats:
+          rows: { total: "1500", unit: "rows" }
+`;
+
+    it('should label edges with the rows flowing from the child', () => {
+      expect(callWasm('renderDOT', { input, edgeRows: true }).result).toContain('n0 -> n1 [label="1500 rows"];');
+      expect(callWasm('renderMermaid', { input, edgeRows: true }).result).toContain('n0 -->|"1500 rows"| n1');
+      expect(callWasm('renderD2', { input, edgeRows: true }).result).toContain('n0 -> n1: "1500 rows"');
+    });
+
+    it('should scale the text of weighted nodes with weightStyle size', () => {
+      const response = callWasm('renderDOT', { input, weightBy: 'rows', weightStyle: 'size' });
+
+      expect(response.result).toContain('n1 [label="1: Scan\\nrows: 1500", fontsize=24];');
+      expect(response.result).not.toContain('fillcolor');
+    });
+
+    it('should warn about the spannerplanviz options it ignores', () => {
+      const response = callWasm('renderMermaid', { input, edgeRows: true, full: true, hideScanTarget: true });
+
+      expect(response.warnings).toContainEqual(expect.objectContaining({ code: 'UNSUPPORTED_OPTION' }));
+      expect(response.warnings?.find(w => w.code === 'UNSUPPORTED_OPTION')?.message).toContain('full, hideScanTarget');
+    });
+
+    it('should reject weightStyle without weightBy', () => {
+      expect(callWasm('renderDOT', { input, weightStyle: 'size' }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
   describe('renderD2', () => {
     it('should return D2 source for a valid query plan', () => {
       const params: RenderMermaidParams = {
@@ -407,253 +465,4507 @@ stats:
     });
   });
 
-  describe('Error Response Validation', () => {
-    it('should return PARSE_ERROR for invalid JSON input', () => {
-      const params: RenderParams = {
-        input: 'invalid json content {',
+  describe('prototext input', () => {
+    it('should render a QueryPlan pasted in protobuf text format', () => {
+      const response = callWasm('renderASCII', {
+        input: `plan_nodes {
+  index: 0
+  kind: RELATIONAL
+  display_name: "Distributed Union"
+  child_links { child_index: 1 }
+}
+plan_nodes {
+  index: 1
+  kind: RELATIONAL
+  display_name: "Scan"
+}`,
         mode: 'AUTO',
-        format: 'CURRENT',
-        wrapWidth: 80
-      };
+        format: 'TRADITIONAL',
+      });
 
-      const resultStr = renderASCII(JSON.stringify(params));
-      const response: WasmResponse = JSON.parse(resultStr);
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('Distributed Union');
+      expect(response.result).toContain('Scan');
+    });
 
-      expect(response.success).toBe(false);
-      expect(response).not.toHaveProperty('result');
-      expect(response).toHaveProperty('error');
+    it('should render compact ResultSetStats debug output', () => {
+      const response = callWasm('renderASCII', {
+        input: 'query_plan:{plan_nodes:{index:0 kind:RELATIONAL display_name:"Scan"}}',
+        mode: 'AUTO',
+        format: 'TRADITIONAL',
+      });
 
-      const error = response.error!;
-      expect(error.type).toBe('PARSE_ERROR');
-      expect(typeof error.message).toBe('string');
-      expect(error.message.length).toBeGreaterThan(0);
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('Scan');
     });
+  });
 
-    it('should return INVALID_PARAMETERS for invalid render mode', () => {
-      const invalidParams = {
-        input: `
-stats:
-  queryPlan:
-    planNodes:
-      - displayName: "Test Node"
-        kind: RELATIONAL
-        index: 0
-`,
-        mode: 'INVALID_MODE',
-        format: 'CURRENT',
-        wrapWidth: 80
-      };
-
-      const resultStr = renderASCII(JSON.stringify(invalidParams));
-      const response: WasmResponse = JSON.parse(resultStr);
+  describe('YAML input', () => {
+    it('should locate YAML syntax errors', () => {
+      const response = callWasm('renderASCII', { input: 'stats:\n  queryPlan:\n\tplanNodes: []\n', mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 });
 
       expect(response.success).toBe(false);
-      expect(response.error?.type).toBe('INVALID_PARAMETERS');
-      expect(response.error?.message).toMatch(/render mode/i);
+      expect(response.error?.type).toBe('PARSE_ERROR');
+      expect(response.error?.message).toContain('invalid YAML at line 3, column 1');
     });
+  });
 
-    it('should return INVALID_PARAMETERS for invalid format type', () => {
-      const invalidParams = {
-        input: `
+  describe('renderLimits', () => {
+    const input = `
 stats:
   queryPlan:
     planNodes:
-      - displayName: "Test Node"  
-        kind: RELATIONAL
-        index: 0
-`,
-        mode: 'AUTO',
-        format: 'INVALID_FORMAT',
-        wrapWidth: 80
-      };
+      - { index: 0, kind: RELATIONAL, displayName: "Serialize Result", childLinks: [{ childIndex: 1 }] }
+      - { index: 1, kind: RELATIONAL, displayName: "Filter", childLinks: [{ childIndex: 2 }] }
+      - { index: 2, kind: RELATIONAL, displayName: "Limit", childLinks: [{ childIndex: 3 }] }
+      - { index: 3, kind: RELATIONAL, displayName: "Scan" }
+`;
+    const params = { input, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, printSections: [] };
 
-      const resultStr = renderASCII(JSON.stringify(invalidParams));
-      const response: WasmResponse = JSON.parse(resultStr);
+    it('should render in full when the output fits', () => {
+      const response = callWasm('renderASCII', { ...params, renderLimits: { maxLines: 100 } });
 
-      expect(response.success).toBe(false);
-      expect(response.error?.type).toBe('INVALID_PARAMETERS');
-      expect(response.error?.message).toMatch(/format/i);
+      expect(response.degradation).toBe('full');
+      expect(response.result).toBe(callWasm('renderASCII', params).result);
     });
 
-    it('should return INVALID_SPANNER_FORMAT for missing plan nodes', () => {
-      const params: RenderParams = {
-        input: `
-stats:
-  queryPlan: {}
-`,
-        mode: 'AUTO',
-        format: 'CURRENT',
-        wrapWidth: 80
-      };
+    it('should collapse deep subtrees to fit', () => {
+      const response = callWasm('renderASCII', { ...params, renderLimits: { maxLines: 7 } });
 
-      const resultStr = renderASCII(JSON.stringify(params));
-      const response: WasmResponse = JSON.parse(resultStr);
+      expect(response.success).toBe(true);
+      expect(response.degradation).toBe('collapsed');
+      expect(response.result).toContain('Filter');
+      expect(response.result).not.toContain('Scan');
+      expect(response.result).toContain('2 operators collapsed');
+      expect(response.warnings?.map(w => w.code)).toContain('RENDER_DEGRADED');
+    });
 
-      expect(response.success).toBe(false);
-      expect(response.error?.type).toBe('INVALID_SPANNER_FORMAT');
-      expect(response.error?.message).toMatch(/plan nodes|query plan/i);
+    it('should fall back to a summary', () => {
+      const response = callWasm('renderASCII', { ...params, renderLimits: { maxBytes: 200, maxLines: 3 } });
+
+      expect(response.degradation).toBe('summary');
+      expect(response.result).toContain('4 operators');
+    });
+
+    it('should reject negative limits', () => {
+      expect(callWasm('renderASCII', { ...params, renderLimits: { maxLines: -1 } }).error?.type).toBe('INVALID_PARAMETERS');
     });
   });
 
-  describe('Parameter Type Validation', () => {
-    it('should validate all render modes work correctly', () => {
-      const testInput = `
+  describe('prettyMetadataKeys', () => {
+    const input = `
 stats:
   queryPlan:
     planNodes:
-      - displayName: "Test Node"
+      - displayName: "Scan"
         kind: RELATIONAL
         index: 0
+        metadata:
+          scan_type: TableScan
+          scan_target: Singers
+          scan_method: Row
+          seekable_key_size: "0"
 `;
+    const params = { input, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
 
-      const renderModes: Array<RenderParams['mode']> = ['AUTO', 'PLAN', 'PROFILE'];
-      
-      for (const mode of renderModes) {
-        const params: RenderParams = {
-          input: testInput,
-          mode,
-          format: 'CURRENT',
-          wrapWidth: 80
-        };
-
-        const resultStr = renderASCII(JSON.stringify(params));
-        const response: WasmResponse = JSON.parse(resultStr);
+    it('should label metadata keys with units', () => {
+      const raw = callWasm('renderASCII', params);
+      const pretty = callWasm('renderASCII', { ...params, prettyMetadataKeys: true });
 
-        expect(typeof response.success).toBe('boolean');
-        
-        if (!response.success) {
-          // Should not fail due to parameter validation
-          expect(response.error!.type).not.toBe('INVALID_PARAMETERS');
-        }
-      }
+      expect(raw.result).toContain('seekable_key_size');
+      expect(pretty.success).toBe(true);
+      expect(pretty.result).toContain('Seekable key size (key columns)');
+      expect(pretty.result).toContain('Scan method');
+      expect(pretty.result).not.toContain('seekable_key_size');
+      expect(pretty.result).toContain('Singers');
     });
+  });
 
-    it('should validate all format types work correctly', () => {
-      const testInput = `
-stats:
-  queryPlan:
-    planNodes:
-      - displayName: "Test Node"
-        kind: RELATIONAL
-        index: 0
-`;
-
-      const formatTypes: Array<RenderParams['format']> = ['CURRENT', 'TRADITIONAL', 'COMPACT'];
-      
-      for (const format of formatTypes) {
-        const params: RenderParams = {
-          input: testInput,
-          mode: 'AUTO',
-          format,
-          wrapWidth: 80
-        };
+  describe('result hash', () => {
+    const params = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
 
-        const resultStr = renderASCII(JSON.stringify(params));
-        const response: WasmResponse = JSON.parse(resultStr);
+    it('should be equal for identical results and differ otherwise', () => {
+      const first = callWasm('renderASCII', params);
+      const unrelated = callWasm('renderASCII', { ...params, recover: true });
+      const different = callWasm('renderASCII', { ...params, format: 'COMPACT' });
 
-        expect(typeof response.success).toBe('boolean');
-        
-        if (!response.success) {
-          // Should not fail due to parameter validation
-          expect(response.error!.type).not.toBe('INVALID_PARAMETERS');
-        }
-      }
+      expect(first.resultHash).toMatch(/^[0-9a-f]{16}$/);
+      expect(unrelated.result).toBe(first.result);
+      expect(unrelated.resultHash).toBe(first.resultHash);
+      expect(different.resultHash).not.toBe(first.resultHash);
     });
 
-    it('should validate wrap width parameter handling', () => {
-      const testInput = `
-stats:
-  queryPlan:
-    planNodes:
-      - displayName: "Test Node"
-        kind: RELATIONAL
-        index: 0
-`;
+    it('should be set for object calls and omitted for errors', () => {
+      const fn = (globalThis as Record<string, unknown>).renderASCII as (params: RenderParams) => WasmResponse;
 
-      const wrapWidths = [0, 40, 80, 120, 160];
-      
-      for (const wrapWidth of wrapWidths) {
-        const params: RenderParams = {
-          input: testInput,
-          mode: 'AUTO',
-          format: 'CURRENT',
-          wrapWidth
-        };
+      expect(fn({ ...params, mode: 'PLAN' }).resultHash).toBe(callWasm('renderASCII', params).resultHash);
+      expect(callWasm('renderASCII', { ...params, input: '{}' }).resultHash).toBeUndefined();
+    });
+  });
 
-        const resultStr = renderASCII(JSON.stringify(params));
-        const response: WasmResponse = JSON.parse(resultStr);
+  describe('chunked results', () => {
+    const params = { input: scalarAppendixInput, mode: 'AUTO', format: 'CURRENT', wrapWidth: 0 };
 
-        expect(typeof response.success).toBe('boolean');
-        
-        if (!response.success) {
-          // Should not fail due to wrap width parameter
-          expect(response.error!.type).not.toBe('INVALID_PARAMETERS');
-        }
+    it('should return large outputs in chunks read with nextChunk', () => {
+      const full = callWasm('renderASCII', params);
+      const first = callWasm('renderASCII', { ...params, chunkSize: 100 });
+
+      expect(first.success).toBe(true);
+      expect(first.chunks?.index).toBe(0);
+      expect(first.chunks?.count).toBeGreaterThan(1);
+      expect(first.resultHash).toBe(full.resultHash);
+      expect(first.warnings).toEqual(full.warnings);
+      let text = first.result ?? '';
+      for (let i = 1; i < (first.chunks?.count ?? 0); i++) {
+        const next = callWasm('nextChunk', { handle: first.chunks?.handle });
+        expect(next.chunks?.index).toBe(i);
+        expect(next.result?.length).toBeLessThanOrEqual(100);
+        text += next.result ?? '';
       }
+      expect(text).toBe(full.result);
+      expect(callWasm('nextChunk', { handle: first.chunks?.handle }).error?.type).toBe('INVALID_PARAMETERS');
     });
 
-    it('should apply hanging indent for wrapped node-local prefixes', () => {
-      const testInput = `
-stats:
-  queryPlan:
-    planNodes:
-      - displayName: "Cross Apply"
-        kind: RELATIONAL
-        index: 0
-        childLinks:
-          - childIndex: 1
-          - childIndex: 2
-            type: "Map"
-      - displayName: "Batch Scan"
-        kind: RELATIONAL
-        index: 1
-        metadata:
-          execution_method: "Row"
-      - displayName: "Serialize Result"
-        kind: RELATIONAL
-        index: 2
-`;
+    it('should return small outputs whole', () => {
+      const response = callWasm('renderASCII', { ...params, chunkSize: 1 << 20 });
 
-      const baseParams: RenderParams = {
-        input: testInput,
-        mode: 'PLAN',
-        format: 'CURRENT',
-        wrapWidth: 21
-      };
+      expect(response.chunks).toBeUndefined();
+      expect(response.result).toBe(callWasm('renderASCII', params).result);
+    });
 
-      const withoutHanging: WasmResponse = JSON.parse(renderASCII(JSON.stringify({
-        ...baseParams,
-        hangingIndent: false
-      })));
-      const withHanging: WasmResponse = JSON.parse(renderASCII(JSON.stringify({
-        ...baseParams,
-        hangingIndent: true
-      })));
+    it('should release unread chunks', () => {
+      const first = callWasm('renderASCII', { ...params, chunkSize: 100 });
 
-      expect(withoutHanging.success).toBe(true);
-      expect(withHanging.success).toBe(true);
-      expect(withHanging.result).not.toBe(withoutHanging.result);
-      expect(withHanging.result).toContain('[Input] Batch Scan');
+      expect(callWasm('releaseChunks', { handle: first.chunks?.handle }).success).toBe(true);
+      expect(callWasm('nextChunk', { handle: first.chunks?.handle }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('renderASCII', { ...params, chunkSize: -1 }).error?.type).toBe('INVALID_PARAMETERS');
     });
+  });
 
-    it('should render selected scalar appendix sections', () => {
-      const params: RenderParams = {
-        input: scalarAppendixInput,
-        mode: 'PLAN',
-        format: 'CURRENT',
-        wrapWidth: 0,
-        printSections: ['ordering', 'aggregate'],
-        resolveScalarVarsRecursive: true
-      };
+  describe('samples', () => {
+    const listSamples = (): SampleInfo[] => {
+      const fn = (globalThis as Record<string, unknown>).listSamples as () => string;
+      const response: WasmResponse = JSON.parse(fn());
+      expect(response.success).toBe(true);
+      return JSON.parse(response.result ?? '[]');
+    };
 
-      const resultStr = renderASCII(JSON.stringify(params));
-      const response: WasmResponse = JSON.parse(resultStr);
+    it('should list the embedded samples', () => {
+      const samples = listSamples();
 
-      expect(response.success).toBe(true);
-      expect(response.result).toContain('Ordering(identified by ID):');
-      expect(response.result).toContain('Aggregates(identified by ID):');
-      expect(response.result).toContain('COUNT_FINAL(COUNT()) DESC, SongGenre');
-      expect(response.result).not.toContain('Predicates(identified by ID):');
+      expect(samples.map(s => s.name)).toEqual(['simple-scan', 'distributed-join', 'dml', 'graph-query']);
+      expect(samples.find(s => s.name === 'simple-scan')?.hasExecutionStats).toBe(true);
+      expect(samples.find(s => s.name === 'dml')?.hasExecutionStats).toBe(false);
+    });
+
+    it('should return samples that render', () => {
+      for (const sample of listSamples()) {
+        const input = callWasm('getSample', { name: sample.name }).result ?? '';
+        const response = callWasm('renderASCII', { input, mode: 'AUTO', format: 'CURRENT', wrapWidth: 0 });
+
+        expect(response.success).toBe(true);
+        expect(response.metadata?.counts.totalNodes).toBe(sample.planNodes);
+      }
+    });
+
+    it('should reject unknown sample names', () => {
+      const response = callWasm('getSample', { name: 'nope' });
+
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+      expect(response.error?.message).toContain('simple-scan');
+    });
+  });
+
+  describe('validateInput', () => {
+    it('should report the node count and stats presence of valid input', () => {
+      const response = callWasm('validateInput', { input: scalarAppendixInput });
+
+      expect(response.success).toBe(true);
+      const validation: InputValidation = JSON.parse(response.result ?? '{}');
+      expect(validation).toEqual({ planNodes: 10, hasExecutionStats: false, detectedFormat: 'yaml', plans: 1 });
+    });
+
+    it('should tell gRPC and REST JSON apart', () => {
+      const node = (key: string) => `{ "index": 0, "kind": "RELATIONAL", "${key}": "Scan" }`;
+      const rest = callWasm('validateInput', { input: `{ "queryPlan": { "planNodes": [${node('displayName')}] } }` });
+      const grpc = callWasm('validateInput', { input: `{ "query_plan": { "plan_nodes": [${node('display_name')}] } }` });
+
+      expect((JSON.parse(rest.result ?? '{}') as InputValidation).detectedFormat).toBe('json-rest');
+      expect((JSON.parse(grpc.result ?? '{}') as InputValidation).detectedFormat).toBe('json-grpc');
+    });
+
+    it('should find plans wrapped in Cloud Logging entries', () => {
+      const stats = '{ "queryPlan": { "planNodes": [{ "index": 0, "kind": "RELATIONAL", "displayName": "Scan" }] } }';
+      const protoPayload = callWasm('validateInput', {
+        input: `{ "insertId": "abc", "resource": { "type": "spanner_instance" }, "protoPayload": { "response": { "stats": ${stats} } } }`,
+      });
+      const jsonPayload = callWasm('validateInput', {
+        input: JSON.stringify({ jsonPayload: { resultSetStats: JSON.parse(stats) } }),
+      });
+      const serialized = callWasm('validateInput', {
+        input: JSON.stringify({ jsonPayload: { message: stats } }),
+      });
+
+      for (const response of [protoPayload, jsonPayload, serialized]) {
+        expect(response.success).toBe(true);
+        const validation: InputValidation = JSON.parse(response.result ?? '{}');
+        expect(validation.planNodes).toBe(1);
+        expect(validation.detectedFormat).toBe('json-rest');
+      }
+    });
+
+    it('should still reject envelopes without a plan', () => {
+      const response = callWasm('validateInput', { input: '{ "jsonPayload": { "stats": { "rows": 1 } } }' });
+
+      expect(response.success).toBe(false);
+    });
+
+    it('should count and select the plans of batch DML responses', () => {
+      const resultSet = (name: string) => ({ stats: { queryPlan: { planNodes: [{ index: 0, kind: 'RELATIONAL', displayName: name }] } } });
+      const input = JSON.stringify({ resultSets: [resultSet('Insert'), { stats: { rowCountExact: '1' } }, resultSet('Update')] });
+
+      const first: InputValidation = JSON.parse(callWasm('validateInput', { input }).result ?? '{}');
+      const outOfRange = callWasm('validateInput', { input, planIndex: 2 });
+
+      expect(first.plans).toBe(2);
+      expect(outOfRange.error?.type).toBe('INVALID_PARAMETERS');
+      expect(outOfRange.error?.message).toContain('Invalid planIndex: 2 (the input has plans 0 to 1)');
+    });
+
+    it('should decompress base64-encoded gzip input', () => {
+      const sample = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+      const input = gzipSync(sample).toString('base64');
+
+      const response = callWasm('validateInput', { input });
+      const zstd = callWasm('validateInput', { input: 'KLUv/QBYAAA=' });
+
+      expect(response.success).toBe(true);
+      expect((JSON.parse(response.result ?? '{}') as InputValidation).detectedFormat).toBe('yaml');
+      expect(zstd.error?.message).toContain('zstd-compressed input is not supported');
+    });
+
+    it('should list the formats that were tried', () => {
+      const response = callWasm('validateInput', { input: '{\n  "queryPlan": [\n' });
+
+      expect(response.error?.type).toBe('PARSE_ERROR');
+      expect(response.error?.details).toMatch(/^Tried json: .+; yaml: .+/);
+    });
+
+    it('should report the errors renderASCII would', () => {
+      const syntaxError = callWasm('validateInput', { input: '{\n  "queryPlan": [\n' });
+      const noNodes = callWasm('validateInput', { input: 'stats:\n  queryPlan:\n    planNodes: []\n' });
+      const badLink = callWasm('validateInput', { input: 'stats:\n  queryPlan:\n    planNodes:\n      - { index: 0, kind: RELATIONAL, displayName: Scan, childLinks: [{ childIndex: 5 }] }\n' });
+
+      expect(syntaxError.error?.type).toBe('PARSE_ERROR');
+      expect(noNodes.error?.type).toBe('INVALID_SPANNER_FORMAT');
+      expect(badLink.error?.type).toBe('INVALID_SPANNER_FORMAT');
+      expect(badLink.error?.details).toBe('stats.queryPlan.planNodes[0].childLinks[0].childIndex');
+    });
+  });
+
+  describe('parse error location', () => {
+    it('should report the line, column, and snippet of JSON syntax errors', () => {
+      const input = '{\n  "queryPlan": {\n    "planNodes": [{ "index": 0 ]\n  }\n}';
+      const response = callWasm('renderASCII', { input, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('PARSE_ERROR');
+      expect(response.error?.line).toBe(3);
+      expect(response.error?.column).toBe(32);
+      expect(response.error?.snippet).toBe('    "planNodes": [{ "index": 0 ]');
+    });
+
+    it('should locate YAML syntax errors', () => {
+      const response = callWasm('renderASCII', { input: 'stats:\n  queryPlan:\n\tplanNodes: []\n', mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.error?.line).toBe(3);
+      expect(response.error?.column).toBe(1);
+    });
+
+    it('should omit the location of other errors', () => {
+      const response = callWasm('renderASCII', { input: '{}', mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.line).toBeUndefined();
+    });
+  });
+
+  describe('base64 binary protobuf input', () => {
+    // ResultSetStats { query_plan { plan_nodes { index: 0, kind: RELATIONAL, display_name: "Scan" } } }
+    const binaryStats = Buffer.from([0x0a, 0x0c, 0x0a, 0x0a, 0x08, 0x00, 0x10, 0x01, 0x1a, 0x04, ...Buffer.from('Scan')]).toString('base64');
+    const params = { mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+
+    it('should detect and decode binary plans', () => {
+      const detected = callWasm('renderASCII', { ...params, input: binaryStats });
+      const explicit = callWasm('renderASCII', { ...params, input: binaryStats, inputEncoding: 'proto-base64' });
+
+      expect(detected.success).toBe(true);
+      expect(detected.result).toContain('Scan');
+      expect(explicit.result).toBe(detected.result);
+    });
+
+    it('should reject invalid binary input and unknown encodings', () => {
+      expect(callWasm('renderASCII', { ...params, input: 'not base64!', inputEncoding: 'proto-base64' }).error?.type).toBe('PARSE_ERROR');
+      expect(callWasm('renderASCII', { ...params, input: binaryStats, inputEncoding: 'hex' }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('renderPrototext', () => {
+    it('should round-trip a plan through protobuf text format', () => {
+      const exported = callWasm('renderPrototext', { input: scalarAppendixInput });
+
+      expect(exported.success).toBe(true);
+      expect(exported.result).toContain('query_plan');
+      expect(exported.result).toContain('display_name: "Sort"');
+
+      const rendered = callWasm('renderASCII', { input: exported.result, mode: 'AUTO', format: 'TRADITIONAL' });
+      expect(rendered.success).toBe(true);
+      expect(rendered.result).toContain('Sort');
+    });
+  });
+
+  describe('registerFormatter', () => {
+    const register = (name: string, callback: FormatterCallback | null): WasmResponse => {
+      const fn = (globalThis as Record<string, unknown>).registerFormatter as (name: string, callback: FormatterCallback | null) => string;
+      return JSON.parse(fn(name, callback));
+    };
+
+    it('should render a custom format with the registered callback', () => {
+      expect(register('ids', model => model.rows.map(row => `${row.depth}:${row.displayName}`).join('\n')).success).toBe(true);
+
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'AUTO', format: 'ids' });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toBe('0:Sort\n1:Aggregate\n2:Scan');
+      register('ids', null);
+    });
+
+    it('should render from the row model of the last options used with the input', () => {
+      const input = 'query_plan:{plan_nodes:{index:0 kind:RELATIONAL display_name:"Distributed Union" child_links:{child_index:1}} plan_nodes:{index:1 kind:RELATIONAL display_name:"Scan"}}';
+      register('names', model => model.rows.map(row => row.displayName).join(','));
+      register('depths', model => model.rows.map(row => row.depth).join(','));
+      const render = (format: string, consoleNaming?: boolean) => callWasm('renderASCII', { input, mode: 'AUTO', format, consoleNaming }).result;
+
+      expect(render('names')).toBe('Distributed Union,Scan');
+      expect(render('depths')).toBe('0,1');
+      expect(render('names', true)).toBe('Distributed union,Scan');
+      expect(render('names')).toBe('Distributed Union,Scan');
+      register('names', null);
+      register('depths', null);
+    });
+
+    it('should hand rows sorted by sortBy to the callback', () => {
+      const profileInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 2
+        executionStats:
+          latency: { total: "12", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        executionStats:
+          latency: { total: "0.5", unit: "secs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+`;
+      register('ids', model => model.rows.map(row => row.id).join(','));
+
+      const byLatency = callWasm('renderASCII', { input: profileInput, mode: 'AUTO', format: 'ids', sortBy: 'latency' });
+      const byID = callWasm('renderASCII', { input: profileInput, mode: 'AUTO', format: 'ids', sortBy: 'id' });
+
+      expect(byLatency.result).toBe('1,0,2');
+      expect(byID.result).toBe('0,1,2');
+      register('ids', null);
+    });
+
+    it('should return INVALID_PARAMETERS for an unknown sortBy', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'AUTO', format: 'CURRENT', sortBy: 'cpu' });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+
+    it('should reject overriding a built-in format', () => {
+      const response = register('TRADITIONAL', () => '');
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+
+    it('should report exceptions thrown by the callback as RENDER_ERROR', () => {
+      register('throws', () => {
+        throw new Error('boom');
+      });
+
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'AUTO', format: 'throws' });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('RENDER_ERROR');
+      expect(response.error?.message).toContain('boom');
+      register('throws', null);
+    });
+  });
+
+  describe('lintPlan', () => {
+    const register = (name: string, callback: LintRuleCallback | null, scope?: LintRuleScope): WasmResponse => {
+      const fn = (globalThis as Record<string, unknown>).registerLintRule as (name: string, callback: LintRuleCallback | null, scope?: LintRuleScope) => string;
+      return JSON.parse(fn(name, callback, scope));
+    };
+
+    it('should merge findings of node and tree scoped custom rules', () => {
+      register('no-sort', (row: PlanRow) => (row.displayName === 'Sort' ? [{ message: 'avoid sorting' }] : null));
+      register('max-rows', (model: FormatterModel) => [{ message: `${model.rows.length} operators` }], 'tree');
+
+      const response = callWasm('lintPlan', { input: scalarAppendixInput });
+
+      expect(response.success).toBe(true);
+      const findings: LintFinding[] = JSON.parse(response.result ?? '[]');
+      expect(findings).toEqual([
+        { id: 'max-rows', rule: 'max-rows', severity: 'warning', message: '3 operators' },
+        { id: 'no-sort:0', rule: 'no-sort', severity: 'warning', message: 'avoid sorting', nodeId: 0, nodeIds: [0] },
+      ]);
+      register('no-sort', null);
+      register('max-rows', null);
+    });
+
+    it('should suggest refreshing statistics when estimates are skewed across the plan', () => {
+      const node = (index: number, estimated: number, actual: number, child?: number) => `
+      - displayName: "${index === 0 ? 'Distributed Union' : 'Scan'}"
+        kind: RELATIONAL
+        index: ${index}${child === undefined ? '' : `
+        childLinks:
+          - childIndex: ${child}`}
+        metadata:
+          estimated_rows: "${estimated}"
+        executionStats:
+          rows: { total: "${actual}", unit: "rows" }`;
+      const input = `
+stats:
+  queryPlan:
+    planNodes:${node(0, 40, 50, 1)}${node(1, 5, 100, 2)}${node(2, 4, 100)}
+`;
+
+      const findings: LintFinding[] = JSON.parse(callWasm('lintPlan', { input }).result ?? '[]');
+
+      expect(findings).toHaveLength(1);
+      expect(findings[0]).toMatchObject({ id: 'stale-statistics:2,1', rule: 'stale-statistics', severity: 'info', nodeId: 2, nodeIds: [2, 1] });
+      expect(findings[0]?.docUrl).toContain('cloud.google.com/spanner/docs');
+      expect(findings[0]?.message).toContain('3 operators (2 by 10x or more, worst: Scan)');
+      expect(findings[0]?.message).toContain('ANALYZE');
+    });
+
+    it('should apply thresholds to full scans and fan-out', () => {
+      const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        executionStats:
+          execution_summary: { num_executions: "1" }
+          remote_calls: { total: "150", unit: "calls" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata:
+          Full scan: "true"
+          scan_type: TableScan
+          scan_target: Singers
+        executionStats:
+          rows: { total: "20", unit: "rows" }
+`;
+      const rules = (thresholds?: Thresholds) =>
+        (JSON.parse(callWasm('lintPlan', { input, thresholds }).result ?? '[]') as LintFinding[]).map(f => f.rule);
+
+      expect(rules()).toEqual(['full-scan', 'high-fan-out']);
+      expect(rules({ fullScanMinRows: 1000, fanOutLimit: 200 })).toEqual([]);
+    });
+
+    it('should report large cross applies, hash builds, and table scans, and many distributed operators', () => {
+      const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 1
+        childLinks:
+          - childIndex: 2
+      - displayName: "Cross Apply"
+        kind: RELATIONAL
+        index: 2
+        childLinks:
+          - childIndex: 3
+          - childIndex: 4
+            type: Map
+      - displayName: "Hash Join"
+        kind: RELATIONAL
+        index: 3
+        childLinks:
+          - childIndex: 5
+            type: Build
+          - childIndex: 6
+            type: Probe
+        executionStats:
+          rows: { total: "20000", unit: "rows" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 4
+        metadata:
+          scan_type: IndexScan
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 5
+        metadata:
+          scan_type: TableScan
+        executionStats:
+          rows: { total: "200000", unit: "rows" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 6
+        metadata:
+          scan_type: IndexScan
+        executionStats:
+          rows: { total: "10", unit: "rows" }
+`;
+      const findings = (thresholds?: Thresholds) =>
+        (JSON.parse(callWasm('lintPlan', { input, thresholds }).result ?? '[]') as LintFinding[]).map(f => f.id);
+
+      expect(findings()).toEqual(['large-cross-apply:2,3', 'large-hash-build:3,5', 'large-table-scan:5']);
+      expect(findings({ distributedOperatorLimit: 2, applyInputRows: 30000 })).toEqual(['large-hash-build:3,5', 'large-table-scan:5', 'many-distributed-operators:0,1']);
+    });
+
+    it('should append findings under the table with the lint option', () => {
+      const input = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+
+      const response = callWasm('renderASCII', { input, mode: 'PROFILE', format: 'CURRENT', lint: true });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toMatch(/\n\nLint findings:\n {2}warning full-scan \(node 4\): /);
+    });
+
+    it('should reject invalid thresholds', () => {
+      const response = callWasm('lintPlan', { input: scalarAppendixInput, thresholds: { misestimateRatio: 0.5 } });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+
+    it('should pass through severity, affected nodes, and doc URLs of custom findings', () => {
+      register('custom-meta', () => [{ message: 'joined', severity: 'error', nodeId: 3, nodeIds: [6], docUrl: 'https://example.com/joined' }], 'tree');
+
+      const findings: LintFinding[] = JSON.parse(callWasm('lintPlan', { input: scalarAppendixInput }).result ?? '[]');
+
+      expect(findings).toContainEqual({ id: 'custom-meta:3,6', rule: 'custom-meta', severity: 'error', message: 'joined', nodeId: 3, nodeIds: [3, 6], docUrl: 'https://example.com/joined' });
+      register('custom-meta', null);
+    });
+
+    it('should reject an unknown scope', () => {
+      const response = register('bad-scope', () => null, 'plan' as LintRuleScope);
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('suggestWhatIf', () => {
+    it('should suggest an index for a selective residual filter on a full scan', () => {
+      const input = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+
+      const response = callWasm('suggestWhatIf', { input });
+
+      expect(response.success).toBe(true);
+      const findings: LintFinding[] = JSON.parse(response.result ?? '[]');
+      expect(findings).toHaveLength(1);
+      expect(findings[0]).toMatchObject({ id: 'selective-residual-filter:3,4', severity: 'info', nodeId: 3 });
+      expect(findings[0]?.message).toContain('keeps 3 of 1000 rows');
+      expect(findings[0]?.suggestions?.map(s => [s.kind, s.statement])).toEqual([
+        ['ddl', 'CREATE INDEX SingersByLastName ON Singers(LastName)'],
+        ['hint', 'Singers@{FORCE_INDEX=SingersByLastName}'],
+      ]);
+    });
+
+    it('should not suggest an index for a filter keeping more than selectiveFilterRatio', () => {
+      const input = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+      const thresholds: Thresholds = { selectiveFilterRatio: 0.001 };
+
+      const response = callWasm('suggestWhatIf', { input, thresholds });
+
+      expect(response.result).toBe('[]');
+    });
+
+    it('should suggest an index in sort order for a large sort below a limit', () => {
+      const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: Limit
+        kind: RELATIONAL
+        index: 0
+        childLinks: [{ childIndex: 1 }]
+      - displayName: Sort
+        kind: RELATIONAL
+        index: 1
+        childLinks: [{ childIndex: 2 }, { childIndex: 4, type: Key }]
+      - displayName: Scan
+        kind: RELATIONAL
+        index: 2
+        metadata: { scan_type: TableScan, scan_target: Albums, "Full scan": "true" }
+        childLinks: [{ childIndex: 3, variable: AlbumTitle }]
+        executionStats: { rows: { total: "50000", unit: rows } }
+      - displayName: Reference
+        kind: SCALAR
+        index: 3
+        shortRepresentation: { description: AlbumTitle }
+      - displayName: Reference
+        kind: SCALAR
+        index: 4
+        shortRepresentation: { description: "$AlbumTitle DESC" }
+`;
+
+      const findings: LintFinding[] = JSON.parse(callWasm('suggestWhatIf', { input }).result ?? '[]');
+
+      expect(findings.map(f => f.id)).toEqual(['sort-before-limit:1,2']);
+      expect(findings[0]?.suggestions?.[0]?.statement).toBe('CREATE INDEX AlbumsByAlbumTitle ON Albums(AlbumTitle DESC)');
+    });
+
+    it('should reject an invalid selectiveFilterRatio', () => {
+      const response = callWasm('suggestWhatIf', { input: scalarAppendixInput, thresholds: { selectiveFilterRatio: 2 } });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.message).toContain('selectiveFilterRatio');
+    });
+  });
+
+  describe('getUsageStats', () => {
+    const setEnabled = (enabled: boolean): WasmResponse => {
+      const fn = (globalThis as Record<string, unknown>).setUsageStatsEnabled as (enabled: boolean) => string;
+      return JSON.parse(fn(enabled));
+    };
+
+    it('should count renders, errors, and cache lookups once enabled', () => {
+      callWasm('getUsageStats', { reset: true });
+      expect(setEnabled(true).success).toBe(true);
+
+      const params = { input: scalarAppendixInput, mode: 'PLAN', format: 'COMPACT', wrapWidth: 0 };
+      callWasm('renderASCII', params);
+      callWasm('renderASCII', params);
+      callWasm('renderASCII', { ...params, mode: 'INVALID' });
+
+      const response = callWasm('getUsageStats', { reset: true });
+      setEnabled(false);
+
+      expect(response.success).toBe(true);
+      const stats: UsageStats = JSON.parse(response.result ?? '{}');
+      expect(stats.enabled).toBe(true);
+      expect(stats.rendersByFormat).toEqual({ COMPACT: 2 });
+      expect(stats.rendersByMode).toEqual({ PLAN: 2 });
+      expect(stats.errorsByType).toEqual({ INVALID_PARAMETERS: 1 });
+      expect(stats.cacheHits).toBeGreaterThanOrEqual(1);
+    });
+
+    it('should not count while disabled', () => {
+      callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'COMPACT', wrapWidth: 0 });
+
+      const stats: UsageStats = JSON.parse(callWasm('getUsageStats', {}).result ?? '{}');
+      expect(stats.enabled).toBe(false);
+      expect(stats.rendersByFormat).toEqual({});
+    });
+  });
+
+
+  describe('setLogHandler', () => {
+    const setLogHandler = (callback: LogHandlerCallback | null): WasmResponse => {
+      const fn = (globalThis as Record<string, unknown>).setLogHandler as (callback: LogHandlerCallback | null) => string;
+      return JSON.parse(fn(callback));
+    };
+    const params = { input: scalarAppendixInput, mode: 'PLAN', format: 'COMPACT', wrapWidth: 0 };
+
+    afterEach(() => {
+      setLogHandler(null);
+      callWasm('logLevel', { level: 'warn' });
+    });
+
+    it('should forward records at the selected level', () => {
+      const records: LogRecord[] = [];
+      expect(setLogHandler(record => { records.push(record); }).success).toBe(true);
+      expect(JSON.parse(callWasm('logLevel', {}).result ?? '{}')).toEqual({ level: 'warn' });
+
+      callWasm('renderASCII', params);
+      expect(records).toEqual([]);
+
+      const response = callWasm('logLevel', { level: 'DEBUG' });
+      expect(JSON.parse(response.result ?? '{}')).toEqual({ level: 'debug' });
+      callWasm('renderASCII', params);
+
+      const rendered = records.find(r => r.message === 'rendered plan');
+      expect(rendered?.level).toBe('debug');
+      expect(rendered?.time).toMatch(/^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$/);
+      expect(rendered?.attrs).toMatchObject({ format: 'COMPACT', mode: 'PLAN', inputBytes: scalarAppendixInput.length });
+      expect(typeof rendered?.attrs?.['duration']).toBe('number');
+      expect(records.some(r => r.message === 'parse cache lookup')).toBe(true);
+    });
+
+    it('should log failed requests at warn level', () => {
+      const records: LogRecord[] = [];
+      setLogHandler(record => { records.push(record); });
+
+      callWasm('renderASCII', { ...params, mode: 'INVALID' });
+
+      expect(records).toHaveLength(1);
+      expect(records[0]?.level).toBe('warn');
+      expect(records[0]?.message).toBe('request failed');
+      expect(records[0]?.attrs?.['type']).toBe('INVALID_PARAMETERS');
+      expect(records[0]?.attrs?.['error']).toContain('Invalid render mode');
+    });
+
+    it('should drop records with the off level or without a handler', () => {
+      const records: LogRecord[] = [];
+      setLogHandler(record => { records.push(record); });
+      callWasm('logLevel', { level: 'off' });
+      callWasm('renderASCII', { ...params, mode: 'INVALID' });
+      expect(records).toEqual([]);
+
+      callWasm('logLevel', { level: 'debug' });
+      setLogHandler(null);
+      callWasm('renderASCII', { ...params, mode: 'INVALID' });
+      expect(records).toEqual([]);
+    });
+
+    it('should not fail renders when the handler throws', () => {
+      callWasm('logLevel', { level: 'debug' });
+      setLogHandler(() => { throw new Error('handler failed'); });
+
+      expect(callWasm('renderASCII', params).success).toBe(true);
+    });
+
+    it('should reject unknown levels and non-function handlers', () => {
+      const response = callWasm('logLevel', { level: 'verbose' });
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+      expect(response.error?.message).toContain('debug, error, info, off, warn');
+
+      const fn = (globalThis as Record<string, unknown>).setLogHandler as (callback: unknown) => string;
+      expect((JSON.parse(fn('console.log')) as WasmResponse).success).toBe(false);
+    });
+  });
+  describe('anonymizePlan', () => {
+    const scanInput = (table: string, filter: string) => `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 0
+        metadata:
+          scan_type: TableScan
+          scan_target: ${table}
+        childLinks:
+          - childIndex: 1
+            type: "Residual Condition"
+      - displayName: "Function"
+        kind: SCALAR
+        index: 1
+        shortRepresentation:
+          description: "($Status = '${filter}')"
+`;
+
+    it('should map the same table to the same pseudonym across inputs with a seed', () => {
+      const before = callWasm('anonymizePlan', { input: scanInput('Orders', 'open'), seed: 'review-42' });
+      const after = callWasm('anonymizePlan', { input: scanInput('Orders', 'closed'), seed: 'review-42' });
+
+      expect(before.success).toBe(true);
+      expect(after.success).toBe(true);
+      expect(before.result).not.toContain('Orders');
+      expect(before.result).not.toContain('open');
+      expect(before.result).not.toContain('Status');
+      expect(before.result).toBe(after.result);
+    });
+
+    it('should produce input that renders', () => {
+      const anonymized = callWasm('anonymizePlan', { input: scanInput('Orders', 'open') });
+      const rendered = callWasm('renderASCII', { input: anonymized.result, mode: 'AUTO', format: 'TRADITIONAL' });
+
+      expect(rendered.success).toBe(true);
+      expect(rendered.result).toMatch(/Table Scan \(Table: table_[0-9a-f]{8}\)/);
+    });
+  });
+
+  describe('response metadata', () => {
+    it('should count nodes, scans, and distributed operators', () => {
+      const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        childLinks:
+          - childIndex: 2
+            type: "Residual Condition"
+        executionStats:
+          rows: { total: "3", unit: "rows" }
+      - displayName: "Function"
+        kind: SCALAR
+        index: 2
+        shortRepresentation:
+          description: "($Status = 'open')"
+`;
+      const response = callWasm('renderASCII', { input, mode: 'AUTO', format: 'CURRENT', wrapWidth: 0, consoleNaming: true });
+
+      expect(response.success).toBe(true);
+      expect(response.metadata?.counts).toEqual({
+        totalNodes: 3,
+        relationalNodes: 2,
+        scalarNodes: 1,
+        leafScans: 1,
+        distributedOperators: 1,
+        hasExecutionStats: true
+      });
+      expect(response.metadata?.detectedFormat).toBe('yaml');
+    });
+
+    it('should report the plans of inputs with several and render the chosen one', () => {
+      const resultSet = (name: string) => ({ stats: { queryPlan: { planNodes: [{ index: 0, kind: 'RELATIONAL', displayName: name }] } } });
+      const input = JSON.stringify([resultSet('First Scan'), resultSet('Second Scan')]);
+
+      const byDefault = callWasm('renderASCII', { input, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 });
+      const second = callWasm('renderASCII', { input, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, planIndex: 1 });
+
+      expect(byDefault.result).toContain('First Scan');
+      expect(byDefault.metadata?.planCount).toBe(2);
+      expect(byDefault.warnings?.map(w => w.code)).toEqual(['MULTIPLE_PLANS']);
+      expect(second.result).toContain('Second Scan');
+      expect(second.warnings).toBeUndefined();
+    });
+
+    it('should warn about unknown options', () => {
+      const response = callWasm('renderASCII', {
+        input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapwidth: 40, hangingIndnet: true,
+        thresholds: { fanOutLimt: 3 },
+      });
+
+      expect(response.success).toBe(true);
+      expect(response.warnings?.map(w => [w.code, w.path])).toEqual([
+        ['UNKNOWN_OPTION', 'hangingIndnet'],
+        ['UNKNOWN_OPTION', 'thresholds.fanOutLimt'],
+        ['UNKNOWN_OPTION', 'wrapwidth'],
+      ]);
+      expect(response.warnings?.[2]?.message).toContain('read as "wrapWidth"');
+    });
+
+    it('should warn about unknown options of object calls, batches, and loaded plans', () => {
+      const renderObject = (globalThis as Record<string, unknown>).renderASCII as (params: Record<string, unknown>) => WasmResponse;
+      const codes = (response: WasmResponse) => response.warnings?.filter(w => w.code === 'UNKNOWN_OPTION').map(w => w.path);
+
+      expect(codes(renderObject({ input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapwidth: 40 }))).toEqual(['wrapwidth']);
+      expect(codes(callWasm('renderBatch', { inputs: [scalarAppendixInput], mode: 'PLAN', format: 'CURRENT', hangingIndnet: true }))).toEqual(['hangingIndnet']);
+      expect(codes(callWasm('renderRange', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', limit: 1, wrapwidth: 40 }))).toEqual(['wrapwidth']);
+
+      const loaded: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput, options: { mode: 'PLAN', format: 'CURRENT', wrapwidth: 40 } }).result ?? '{}');
+      expect(codes(callWasm('renderPlan', { id: loaded.id, options: { Format: 'CURRENT' } }))).toEqual(['wrapwidth', 'Format']);
+      callWasm('releasePlan', { id: loaded.id });
+    });
+
+    it('should reject unsupported apiVersions with UNSUPPORTED_OPTION', () => {
+      const getCapabilities = (globalThis as Record<string, unknown>).getCapabilities as () => string;
+      const caps: Capabilities = JSON.parse(JSON.parse(getCapabilities()).result ?? '{}');
+      const params: RenderParams = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+
+      expect(callWasm('renderASCII', { ...params, apiVersion: caps.apiVersion }).success).toBe(true);
+      const response = callWasm('renderASCII', { ...params, apiVersion: caps.apiVersion + 1 });
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('UNSUPPORTED_OPTION');
+      expect(response.error?.message).toContain('Update the renderer');
+    });
+
+    it('should return metrics with includeMetrics', () => {
+      const params: RenderParams = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+      const response = callWasm('renderASCII', { ...params, includeMetrics: true });
+
+      expect(response.result).toBe(callWasm('renderASCII', params).result);
+      expect(response.metrics).toMatchObject({ inputBytes: scalarAppendixInput.length, outputBytes: response.result?.length, nodeCount: 10 });
+      expect(response.metrics?.parseMillis).toBeGreaterThanOrEqual(0);
+      expect(response.metrics?.renderMillis).toBeGreaterThanOrEqual(0);
+      expect(response.metrics?.heapInUseBytes).toBeGreaterThan(0);
+      expect(callWasm('renderASCII', params).metrics).toBeUndefined();
+    });
+
+    it('should omit metadata on failure', () => {
+      const response = callWasm('renderASCII', { input: 'not a plan', mode: 'AUTO', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.success).toBe(false);
+      expect(response.metadata).toBeUndefined();
+    });
+  });
+
+  describe('latency budget', () => {
+    const profileInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 2
+        executionStats:
+          latency: { total: "90", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        executionStats:
+          latency: { total: "80", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+        executionStats:
+          latency: { total: "5", unit: "msecs" }
+`;
+
+    it('should annotate operators and summarize the violators', () => {
+      const response = callWasm('renderASCII', { input: profileInput, mode: 'PROFILE', format: 'CURRENT', wrapWidth: 0, latencyBudget: '60ms' });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('» over budget: 90 msecs of 60 msecs (+30 msecs)');
+      expect(response.result).toContain('» over budget: 80 msecs of 20 msecs (+60 msecs)');
+      expect(response.result).toContain('» within budget: 5 msecs of 20 msecs');
+      expect(response.result).toMatch(/Latency budget 60 msecs \(actual 90 msecs\):\n {2}1 Scan: \+60 msecs over 20 msecs\n {2}0 Distributed Union: \+30 msecs over 60 msecs\n/);
+    });
+
+    it('should warn when the plan has no latency stats', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'AUTO', format: 'CURRENT', wrapWidth: 0, latencyBudget: '50ms' });
+
+      expect(response.success).toBe(true);
+      expect(response.warnings?.map(w => w.code)).toEqual(['NO_LATENCY_STATS']);
+    });
+
+    it('should return INVALID_PARAMETERS for an invalid budget', () => {
+      const response = callWasm('renderASCII', { input: profileInput, mode: 'AUTO', format: 'CURRENT', wrapWidth: 0, latencyBudget: 'fast' });
+
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('totals', () => {
+    const joinInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Hash Join"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 3
+        executionStats:
+          rows: { total: "5", unit: "rows" }
+          latency: { total: "95", unit: "msecs" }
+      - displayName: "Filter"
+        kind: RELATIONAL
+        index: 1
+        childLinks:
+          - childIndex: 2
+        executionStats:
+          rows: { total: "5", unit: "rows" }
+          latency: { total: "82", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+        executionStats:
+          rows: { total: "999999", unit: "rows" }
+          latency: { total: "80", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 3
+        executionStats:
+          rows: { total: "7", unit: "rows" }
+          latency: { total: "5", unit: "msecs" }
+`;
+
+    it('should append a Total row with the sums of the leaf operators', () => {
+      const response = callWasm('renderASCII', { input: joinInput, mode: 'PROFILE', format: 'CURRENT', totals: {} });
+
+      expect(response.success).toBe(true);
+      const lines = response.result?.split('\n') ?? [];
+      const total = lines.find(line => line.includes('Total (sum of 2 leaves)')) ?? '';
+      expect(total).toMatch(/\| 1000006 \|/);
+      expect(total).toContain('85 msecs');
+      // The Rows column is widened, so every line of the table has the same width
+      const table = lines.filter(line => line.startsWith('+') || line.startsWith('|'));
+      expect(new Set(table.map(line => line.length)).size).toBe(1);
+      expect(lines[lines.indexOf(total) + 1]).toBe(lines[0]);
+    });
+
+    it('should roll up the subtrees of operators with more than one leaf', () => {
+      const response = callWasm('renderASCII', { input: joinInput, mode: 'PROFILE', format: 'CURRENT', totals: { aggregate: 'max', rollups: true } });
+
+      expect(response.result).toContain('» subtree: rows 999999, latency 80 msecs (max of 2 leaves)');
+      expect(response.result).toContain('Total (max of 2 leaves)');
+      // Filter has a single leaf, whose stats it would repeat
+      expect(response.result?.match(/» subtree/g)).toHaveLength(1);
+    });
+
+    it('should total the leaves of the rootNodeId subtree', () => {
+      const response = callWasm('renderASCII', { input: joinInput, mode: 'PROFILE', format: 'CURRENT', rootNodeId: 1, totals: {} });
+
+      expect(response.result).toMatch(/Total \(sum of 1 leaf\) +\| +999999 \|/);
+    });
+
+    it('should warn when the plan has no execution stats', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', totals: {} });
+
+      expect(response.success).toBe(true);
+      expect(response.result).not.toContain('Total (');
+      expect(response.warnings?.map(w => w.code)).toEqual(['NO_EXECUTION_STATS']);
+    });
+
+    it('should return INVALID_PARAMETERS for an unknown aggregate', () => {
+      const response = callWasm('renderASCII', { input: joinInput, mode: 'PROFILE', format: 'CURRENT', totals: { aggregate: 'avg' } });
+
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('JSON-NORMALIZED format', () => {
+    const yamlInput = `
+queryPlan:
+  planNodes:
+    - index: 0
+      kind: RELATIONAL
+      displayName: Filter
+      childLinks:
+        - childIndex: 1
+        - { childIndex: 2, type: Condition }
+      executionStats:
+        rows: { unit: rows, total: "2" }
+    - index: 1
+      kind: RELATIONAL
+      displayName: Scan
+      metadata: { scan_type: TableScan, scan_target: Singers }
+    - index: 2
+      kind: SCALAR
+      displayName: Function
+      shortRepresentation: { description: "($SingerId < 10)" }
+`;
+    const jsonInput = JSON.stringify({
+      queryPlan: {
+        planNodes: [
+          { index: 0, displayName: 'Filter', kind: 'RELATIONAL', executionStats: { rows: { total: '2', unit: 'rows' } }, childLinks: [{ childIndex: 1 }, { type: 'Condition', childIndex: 2 }] },
+          { index: 1, metadata: { scan_target: 'Singers', scan_type: 'TableScan' }, displayName: 'Scan', kind: 'RELATIONAL' },
+          { index: 2, shortRepresentation: { description: '($SingerId < 10)' }, kind: 'SCALAR', displayName: 'Function' },
+        ],
+      },
+    });
+
+    it('should serialize YAML and JSON captures of a plan the same', () => {
+      const fromYAML = callWasm('renderASCII', { input: yamlInput, mode: 'AUTO', format: 'JSON-NORMALIZED' });
+      const fromJSON = callWasm('renderASCII', { input: jsonInput, mode: 'AUTO', format: 'json-normalized' });
+
+      expect(fromYAML.success).toBe(true);
+      expect(fromJSON.success).toBe(true);
+      expect(fromJSON.result).toBe(fromYAML.result);
+      expect(fromYAML.result).toContain([
+        '      {',
+        '        "childLinks": [',
+        '          {',
+        '            "childIndex": 1',
+        '          },',
+        '          {',
+        '            "childIndex": 2,',
+        '            "type": "Condition"',
+        '          }',
+        '        ],',
+        '        "displayName": "Filter",',
+        '        "executionStats": {',
+        '          "rows": {',
+        '            "total": "2",',
+        '            "unit": "rows"',
+        '          }',
+        '        },',
+        '        "kind": "RELATIONAL"',
+        '      },',
+      ].join('\n'));
+      expect(fromYAML.result).toContain('"description": "($SingerId < 10)"');
+      expect(fromYAML.result?.endsWith('}\n')).toBe(true);
+    });
+
+    it('should render its own output unchanged', () => {
+      const first = callWasm('renderASCII', { input: yamlInput, mode: 'AUTO', format: 'JSON-NORMALIZED' });
+      const second = callWasm('renderASCII', { input: first.result ?? '', mode: 'AUTO', format: 'JSON-NORMALIZED' });
+
+      expect(second.success).toBe(true);
+      expect(second.metadata?.detectedFormat).toBe('json-rest');
+      expect(second.result).toBe(first.result);
+    });
+  });
+
+  describe('SPANNER-CLI format', () => {
+    const input = `
+queryPlan:
+  planNodes:
+    - displayName: "Distributed Union"
+      kind: RELATIONAL
+      index: 0
+      childLinks:
+        - childIndex: 1
+      executionStats:
+        rows: { total: "3", unit: "rows" }
+        latency: { total: "1.5", unit: "msecs" }
+        execution_summary: { num_executions: "1" }
+    - displayName: "Scan"
+      kind: RELATIONAL
+      index: 1
+      metadata: { scan_type: TableScan, scan_target: Singers, "Full scan": "true" }
+      executionStats:
+        rows: { total: "3", unit: "rows" }
+        latency: { total: "1.25", unit: "msecs" }
+        execution_summary: { num_executions: "1" }
+`;
+
+    it('should render EXPLAIN with the headers of spanner-cli', () => {
+      const response = callWasm('renderASCII', { input, mode: 'PLAN', format: 'SPANNER-CLI', wrapWidth: 0 });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toBe([
+        '+----+-------------------------------------------------+',
+        '| ID | Query_Execution_Plan (EXPERIMENTAL)             |',
+        '+----+-------------------------------------------------+',
+        '|  0 | Distributed Union                               |',
+        '|  1 | +- Table Scan (Full scan: true, Table: Singers) |',
+        '+----+-------------------------------------------------+',
+        '',
+      ].join('\n'));
+    });
+
+    it('should render EXPLAIN ANALYZE with left-aligned stats', () => {
+      const response = callWasm('renderASCII', { input, mode: 'PROFILE', format: 'spanner-cli', wrapWidth: 0 });
+
+      expect(response.success).toBe(true);
+      const lines = (response.result ?? '').split('\n');
+      expect(lines[1]).toBe('| ID | Query_Execution_Plan                            | Rows_Returned | Executions | Total_Latency |');
+      expect(lines[3]).toBe('|  0 | Distributed Union                               | 3             | 1          | 1.5 msecs     |');
+      expect(lines[4]).toBe('|  1 | +- Table Scan (Full scan: true, Table: Singers) | 3             | 1          | 1.25 msecs    |');
+    });
+  });
+
+  describe('deterministic', () => {
+    const plan = (latency: string) => JSON.stringify({
+      queryPlan: {
+        planNodes: [{
+          displayName: 'Scan',
+          kind: 'RELATIONAL',
+          index: 0,
+          metadata: { scan_type: 'TableScan', scan_target: 'Singers', scan_method: 'Row', execution_method: 'Row' },
+          executionStats: { rows: { total: '1000', unit: 'rows' }, latency: { total: latency, unit: 'msecs' } }
+        }]
+      }
+    });
+
+    it('should render stats spelled differently in the input identically', () => {
+      const params = { mode: 'PROFILE', format: 'CURRENT', wrapWidth: 0, deterministic: true } as const;
+      const fromString = callWasm('renderASCII', { ...params, input: plan('3.50') });
+      const fromShort = callWasm('renderASCII', { ...params, input: plan('3.5') });
+
+      expect(fromString.success).toBe(true);
+      expect(fromString.result).toMatch(/\|\s+3\.5 msecs \|$/m);
+      expect(fromString.result).toBe(fromShort.result);
+      expect(fromString.resultHash).toBe(fromShort.resultHash);
+    });
+
+    it('should reject options that depend on timing', () => {
+      const metrics = callWasm('renderASCII', { input: plan('1'), mode: 'PROFILE', format: 'CURRENT', deterministic: true, includeMetrics: true });
+      const limits = callWasm('renderASCII', { input: plan('1'), mode: 'PROFILE', format: 'CURRENT', deterministic: true, renderLimits: { maxMillis: 10 } });
+
+      expect(metrics.error?.type).toBe('INVALID_PARAMETERS');
+      expect(limits.error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('statsFields', () => {
+    const statsInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        executionStats:
+          rows: { total: "4", unit: "rows" }
+          latency: { total: "2", unit: "msecs" }
+          remote_calls: { total: "3", unit: "calls" }
+          execution_summary: { num_executions: "1" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata: { scan_type: TableScan, scan_target: Singers }
+        executionStats:
+          rows: { total: "4", unit: "rows" }
+          latency: { total: "1", unit: "msecs" }
+          filesystem_delay_seconds: { total: "0.5", unit: "msecs" }
+`;
+
+    it('should list the numeric stats of the plan in the response metadata', () => {
+      const response = callWasm('renderASCII', { input: statsInput, mode: 'PROFILE', format: 'CURRENT' });
+
+      expect(response.metadata?.statsFields).toEqual(['filesystem_delay_seconds', 'latency', 'remote_calls', 'rows']);
+    });
+
+    it('should add the selected stats as columns that the columns option can pick', () => {
+      const response = callWasm('renderASCII', { input: statsInput, mode: 'PROFILE', format: 'CURRENT', statsFields: ['remote_calls', 'scanned_rows'], columns: ['ID', 'remote_calls'] });
+
+      expect(response.success).toBe(true);
+      const result = response.result ?? '';
+      expect(result).toMatch(/^\|\s+ID \| remote_calls \|$/m);
+      expect(result).toMatch(/^\|\s+0 \|\s+3 calls \|$/m);
+      expect(response.warnings?.map(w => w.code)).toEqual(['COLUMN_UNAVAILABLE']);
+      expect(response.warnings?.[0]?.message).toContain('scanned_rows');
+    });
+
+    it('should add every other numeric stat with experimentalStats', () => {
+      const response = callWasm('renderASCII', { input: statsInput, mode: 'PROFILE', format: 'CURRENT', experimentalStats: true });
+
+      expect(response.success).toBe(true);
+      const header = (response.result ?? '').split('\n')[1] ?? '';
+      expect(header).toMatch(/Total Latency \| filesystem_delay_seconds \| remote_calls \|$/);
+      expect(response.result).toMatch(/^\|\s+1 \|.*\|\s+0\.5 msecs \|\s+\|$/m);
+    });
+
+    it('should reject stats that built-in columns show', () => {
+      const response = callWasm('renderASCII', { input: statsInput, mode: 'PROFILE', format: 'CURRENT', statsFields: ['latency'] });
+
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('collapsedNodeIds', () => {
+    const collapseInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Hash Join"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 4
+        executionStats:
+          latency: { total: "95", unit: "msecs" }
+      - displayName: "Filter"
+        kind: RELATIONAL
+        index: 1
+        childLinks:
+          - childIndex: 2
+          - childIndex: 3
+            type: "Condition"
+        executionStats:
+          latency: { total: "82", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+        metadata: { scan_type: TableScan, scan_target: Albums }
+        executionStats:
+          latency: { total: "80", unit: "msecs" }
+      - displayName: "Function"
+        kind: SCALAR
+        index: 3
+        shortRepresentation:
+          description: "($AlbumId > 10)"
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 4
+        executionStats:
+          latency: { total: "5", unit: "msecs" }
+`;
+
+    it('should render a collapsed subtree as one summary row', () => {
+      const response = callWasm('renderASCII', { input: collapseInput, mode: 'PROFILE', format: 'CURRENT', collapsedNodeIds: [1] });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toMatch(/\| \*1 \| \+- ▶ Filter \(\+1 node, 82 msecs\) +\|/);
+      expect(response.result).not.toContain('Albums');
+      // The predicates of the collapsed operator are still listed
+      expect(response.result).toContain('1: Condition: ($AlbumId > 10)');
+      const table = response.result?.split('\n').filter(line => line.startsWith('+') || line.startsWith('|')) ?? [];
+      expect(new Set(table.map(line => line.length)).size).toBe(1);
+    });
+
+    it('should ignore unknown IDs and operators without inputs', () => {
+      const response = callWasm('renderASCII', { input: collapseInput, mode: 'PROFILE', format: 'CURRENT', collapsedNodeIds: [4, 3, 42] });
+
+      expect(response.success).toBe(true);
+      expect(response.result).not.toContain('▶');
+      expect(response.warnings?.map(w => [w.code, w.nodeId])).toEqual([['UNKNOWN_COLLAPSED_NODE', 3], ['UNKNOWN_COLLAPSED_NODE', 42]]);
+    });
+
+    it('should count the hidden operators in the row model', () => {
+      const response = callWasm('renderASCII', { input: collapseInput, mode: 'PROFILE', format: 'HTML', collapsedNodeIds: [0] });
+
+      expect(response.result).toContain('▶ Hash Join (+3 nodes, 95 msecs)');
+      expect(response.result).not.toContain('data-node-id="1"');
+    });
+  });
+
+  describe('estimate column', () => {
+    const estimateInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        metadata:
+          estimated_rows: "40"
+        executionStats:
+          rows: { total: "50", unit: "rows" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata:
+          estimated_rows: "2"
+        executionStats:
+          rows: { total: "50", unit: "rows" }
+`;
+
+    it('should add the column and warn about large misestimates', () => {
+      const response = callWasm('renderASCII', { input: estimateInput, mode: 'PROFILE', format: 'TRADITIONAL', wrapWidth: 0, estimateColumn: true });
+
+      expect(response.success).toBe(true);
+      const lines = (response.result ?? '').split('\n');
+      expect(lines.find(line => line.includes('Est/Actual'))).toMatch(/\|\s+Est\/Actual \|$/);
+      expect(lines.find(line => /^\|\s*\*?0\s*\|/.test(line))).toMatch(/\| 40 \/ 50 \(1.25x\) \|$/);
+      expect(lines.find(line => /^\|\s*\*?1\s*\|/.test(line))).toMatch(/\| {4}2 \/ 50 \(25x\) \|$/);
+      expect(response.warnings).toEqual([
+        { code: 'ROW_MISESTIMATE', message: 'Scan estimated 2 rows but returned 50 (25x)', nodeId: 1 }
+      ]);
+      expect(response.estimates).toEqual([
+        { nodeId: 0, estimatedRows: 40, actualRows: 50, ratio: 1.25 },
+        { nodeId: 1, estimatedRows: 2, actualRows: 50, ratio: 25, misestimated: true },
+      ]);
+    });
+
+    it('should only warn at the configured misestimate ratio', () => {
+      const response = callWasm('renderASCII', { input: estimateInput, mode: 'PROFILE', format: 'TRADITIONAL', wrapWidth: 0, estimateColumn: true, thresholds: { misestimateRatio: 30 } });
+
+      expect(response.success).toBe(true);
+      expect(response.warnings).toBeUndefined();
+      expect(response.estimates?.some(e => e.misestimated)).toBe(false);
+    });
+
+    it('should leave plans without estimates unchanged', () => {
+      const params = { input: scalarAppendixInput, mode: 'AUTO', format: 'CURRENT', wrapWidth: 0 };
+
+      const response = callWasm('renderASCII', { ...params, estimateColumn: true });
+      expect(response.result).toBe(callWasm('renderASCII', params).result);
+      expect(response.estimates).toBeUndefined();
+    });
+  });
+
+  describe('operator filters', () => {
+    const params = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+    const rowIDs = (text: string) => text.split('\n').flatMap(line => /^\|\s*\*?(\d+)\s*\|/.exec(line)?.[1] ?? []);
+
+    it('should keep only the rows of the category', () => {
+      expect(rowIDs(callWasm('renderASCII', { ...params, operatorFilter: 'scans-only' }).result ?? '')).toEqual(['6']);
+      expect(rowIDs(callWasm('renderASCII', { ...params, operatorFilter: 'compute-only' }).result ?? '')).toEqual(['0', '3']);
+      expect(rowIDs(callWasm('renderASCII', { ...params, operatorFilter: 'joins-only' }).result ?? '')).toEqual([]);
+    });
+
+    it('should reject unknown filters', () => {
+      expect(callWasm('renderASCII', { ...params, operatorFilter: 'joins' }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('latency bars', () => {
+    const latencyInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        executionStats:
+          latency: { total: "8", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        executionStats:
+          latency: { total: "2", unit: "msecs" }
+`;
+
+    it('should draw bars relative to the slowest operator', () => {
+      const response = callWasm('renderASCII', { input: latencyInput, mode: 'PROFILE', format: 'TRADITIONAL', wrapWidth: 0, latencyBars: true });
+
+      expect(response.success).toBe(true);
+      const lines = (response.result ?? '').split('\n');
+      expect(lines.find(line => line.includes('Latency Share'))).toMatch(/\| Latency Share \|$/);
+      expect(lines.find(line => /^\|\s*\*?0\s*\|/.test(line))).toMatch(/\| ████████ 100% \|$/);
+      expect(lines.find(line => /^\|\s*\*?1\s*\|/.test(line))).toMatch(/\| ██ {6}  25% \|$/);
+    });
+
+    it('should draw ASCII bars and annotation markers with the ascii charset', () => {
+      const params: RenderParams = { input: latencyInput, mode: 'PROFILE', format: 'TRADITIONAL', wrapWidth: 0, latencyBars: true, annotations: { 1: 'check' } };
+      const unicode = callWasm('renderASCII', params).result ?? '';
+      const ascii = callWasm('renderASCII', { ...params, charset: 'ascii' }).result ?? '';
+
+      expect(ascii).toMatch(/\| ######## 100% \|$/m);
+      expect(ascii).toMatch(/\| ## {6}  25% \|$/m);
+      expect(ascii).toContain('> check');
+      expect(ascii).toMatch(/^[\x00-\x7f]*$/);
+      expect(ascii.split('\n').map(line => line.length)).toEqual(unicode.split('\n').map(line => line.length));
+      expect(callWasm('renderASCII', { ...params, charset: 'ebcdic' }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+
+    it('should color operators by latency share in the ANSI format', () => {
+      const params: RenderParams = { input: latencyInput, mode: 'PROFILE', format: 'ANSI', wrapWidth: 0 };
+      const current = callWasm('renderASCII', { ...params, format: 'CURRENT' }).result ?? '';
+      const ansi = callWasm('renderASCII', params).result ?? '';
+
+      expect(ansi).toContain('\x1b[1;31mDistributed Union\x1b[0m');
+      expect(ansi).toContain('\x1b[1;33mScan\x1b[0m');
+      expect(ansi.replace(/\x1b\[[\d;]*m/g, '')).toBe(current);
+
+      const light = callWasm('renderASCII', { ...params, colorTheme: 'light', cost: { hot: 0.5, critical: 0.9 } }).result ?? '';
+      expect(light).toContain('\x1b[1;33mDistributed Union\x1b[0m');
+      expect(light).toContain('\x1b[1;34mScan\x1b[0m');
+
+      expect(callWasm('renderASCII', { ...params, noColor: true }).result).toBe(current);
+      expect(callWasm('renderASCII', { ...params, colorTheme: 'sepia' }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+
+    it('should leave plans without latency unchanged', () => {
+      const params = { input: scalarAppendixInput, mode: 'AUTO', format: 'CURRENT', wrapWidth: 0 };
+
+      expect(callWasm('renderASCII', { ...params, latencyBars: true }).result).toBe(callWasm('renderASCII', params).result);
+    });
+  });
+
+  describe('column groups', () => {
+    const profileInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 0
+        metadata:
+          estimated_rows: "40"
+        executionStats:
+          rows: { total: "50", unit: "rows" }
+          latency: { total: "1.5", unit: "msecs" }
+`;
+
+    it('should render a group row spanning the grouped columns', () => {
+      const params = { input: profileInput, mode: 'PROFILE', format: 'TRADITIONAL', wrapWidth: 0, estimateColumn: true };
+      const plain = (callWasm('renderASCII', params).result ?? '').split('\n');
+      const response = callWasm('renderASCII', { ...params, columnGroups: [{ title: 'Execution', columns: ['rows', 'Exec.', 'Total Latency'] }] });
+
+      expect(response.success).toBe(true);
+      const lines = (response.result ?? '').split('\n');
+      expect(lines[1]).toMatch(/^\|\s+\|\s+\|\s+Execution\s+\|\s+\|$/);
+      expect(lines[2]).toMatch(/^\|\s+\|\s+\+-+\+-+\+-+\+\s+\|$/);
+      expect(lines[2]?.length).toBe(plain[0]?.length);
+      expect([lines[0], ...lines.slice(3)]).toEqual(plain);
+    });
+
+    it('should reject unknown and non-adjacent columns', () => {
+      const params = { input: profileInput, mode: 'PROFILE', format: 'TRADITIONAL', wrapWidth: 0 };
+
+      expect(callWasm('renderASCII', { ...params, columnGroups: [{ title: 'X', columns: ['Nope'] }] }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('renderASCII', { ...params, columnGroups: [{ title: 'X', columns: ['ID', 'Rows'] }] }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('renderASCII', { ...params, columnGroups: [{ title: '', columns: ['ID'] }] }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('columns', () => {
+    const profileInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 0
+        executionStats:
+          rows: { total: "50", unit: "rows" }
+          latency: { total: "1.5", unit: "msecs" }
+          cpu_time: { total: "1.25", unit: "msecs" }
+`;
+    const params = { input: profileInput, mode: 'PROFILE', format: 'TRADITIONAL', wrapWidth: 0 };
+
+    it('should show the selected columns in the given order', () => {
+      const response = callWasm('renderASCII', { ...params, columns: ['Latency', 'operator', 'CPU', 'ID'] });
+
+      expect(response.success).toBe(true);
+      const lines = (response.result ?? '').split('\n');
+      expect(lines[1]).toMatch(/^\| Total Latency \| Operator\s+\|\s+CPU \| ID \|$/);
+      expect(lines[3]).toMatch(/^\| 1\.5 msecs\s+\| Scan\s+\| 1\.25 msecs \|\s+0 \|$/);
+      expect(lines.every(line => !line.includes('Rows'))).toBe(true);
+    });
+
+    it('should list every unknown column name', () => {
+      const response = callWasm('renderASCII', { ...params, columns: ['ID', 'Opertor', 'Latncy'] });
+
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+      expect(response.error?.message).toContain('"Opertor", "Latncy"');
+    });
+
+    it('should warn about columns the plan does not have', () => {
+      const response = callWasm('renderASCII', { ...params, mode: 'PLAN', columns: ['Operator', 'Rows'] });
+
+      expect(response.success).toBe(true);
+      expect(response.warnings?.map(w => w.code)).toContain('COLUMN_UNAVAILABLE');
+      expect(response.result?.split('\n')[1]).toMatch(/^\| Operator \|$/);
+    });
+  });
+
+  describe('HTML format', () => {
+    const profileInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Filter"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 2
+            type: Condition
+        executionStats:
+          rows: { total: "5", unit: "rows" }
+          latency: { total: "2", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata:
+          scan_type: TableScan
+          scan_target: "<Singers>"
+        executionStats:
+          rows: { total: "50", unit: "rows" }
+          latency: { total: "1.5", unit: "msecs" }
+      - displayName: "Function"
+        kind: SCALAR
+        index: 2
+        shortRepresentation:
+          description: "($a < 1)"
+`;
+
+    it('should render a table with node anchors and column classes', () => {
+      const response = callWasm('renderASCII', { input: profileInput, mode: 'PROFILE', format: 'HTML', annotations: { 1: 'hot' } });
+
+      expect(response.success).toBe(true);
+      const html = response.result ?? '';
+      expect(html).toMatch(/^<table class="rendertree-plan">/);
+      expect(html).toContain('<th class="id">ID</th><th class="operator">Operator</th><th class="rows">Rows</th>');
+      expect(html).toContain('<tr id="node-1" data-node-id="1" data-depth="1"><td class="id">1</td>');
+      expect(html).toContain('Table Scan (Table: &lt;Singers&gt;)');
+      expect(html).toContain('<div class="predicate"><span class="predicate-type">Condition</span>: ($a &lt; 1)</div>');
+      expect(html).toContain('<div class="annotation">hot</div>');
+      expect(html).toContain('<td class="latency">1.5 msecs</td>');
+    });
+
+    it('should honor columns and column groups', () => {
+      const response = callWasm('renderASCII', {
+        input: profileInput, mode: 'PROFILE', format: 'html',
+        columns: ['Operator', 'Rows', 'Latency'],
+        columnGroups: [{ title: 'Execution', columns: ['Rows', 'Total Latency'] }],
+      });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('<tr class="column-groups"><th></th><th colspan="2">Execution</th></tr>');
+      expect(response.result).toContain('<tr><th class="operator">Operator</th><th class="rows">Rows</th><th class="latency">Total Latency</th></tr>');
+    });
+
+    it('should omit stats columns in PLAN mode', () => {
+      const response = callWasm('renderASCII', { input: profileInput, mode: 'PLAN', format: 'HTML' });
+
+      expect(response.result).not.toContain('class="rows"');
+    });
+  });
+
+  describe('filter', () => {
+    const sample = () => callWasm('getSample', { name: 'distributed-join' }).result ?? '';
+    const rowIDs = (result: string | undefined) =>
+      (result ?? '').split('\n').flatMap(line => /^\|\s*\*?(\d+)\s*\|/.exec(line)?.[1] ?? []).map(Number);
+
+    it('should keep matching operators and their ancestors', () => {
+      const response = callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT', filter: 'metadata.Full_scan=true' });
+
+      expect(response.success).toBe(true);
+      expect(rowIDs(response.result)).toEqual([0, 1, 2, 3, 4, 5, 6]);
+    });
+
+    it('should combine conditions and compare numbers', () => {
+      const ids = rowIDs(callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT', filter: 'id<3' }).result);
+      const scans = rowIDs(callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT', filter: 'operator~"scan$" AND id>10' }).result);
+
+      expect(ids).toEqual([0, 1, 2]);
+      expect(scans).toEqual([0, 1, 2, 12, 13, 14, 17, 18]);
+    });
+
+    it('should report invalid conditions', () => {
+      const field = callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT', filter: 'foo=1' });
+      const value = callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT', filter: 'latency>soon' });
+
+      expect(field.error?.message).toContain('Invalid filter field: "foo"');
+      expect(value.error?.message).toContain('or a duration such as 10ms');
+    });
+  });
+
+  describe('rootNodeId', () => {
+    const sample = () => callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+
+    it('should render only the subtree with a breadcrumb', () => {
+      const response = callWasm('renderASCII', { input: sample(), mode: 'PLAN', format: 'CURRENT', rootNodeId: 2, rootBreadcrumb: true });
+
+      expect(response.success).toBe(true);
+      const result = response.result ?? '';
+      expect(result.startsWith('Path: 0 Distributed Union > 1 Local Distributed Union\n')).toBe(true);
+      expect(result).toMatch(/^\|\s+2 \| Serialize Result/m);
+      expect(result).not.toMatch(/^\|\s+\*?[01] \|/m);
+      expect(result).toMatch(/^\|\s+4 \|/m);
+    });
+
+    it('should re-root diagrams and flat exports', () => {
+      const plantUML = callWasm('renderASCII', { input: sample(), mode: 'PLAN', format: 'PLANTUML', rootNodeId: 3 });
+      const csv = callWasm('renderASCII', { input: sample(), mode: 'PLAN', format: 'CSV', rootNodeId: 3 });
+
+      expect(plantUML.result).toMatch(/^\* 3: /m);
+      expect(plantUML.result).not.toMatch(/^\*+ 2: /m);
+      expect(csv.result?.split('\n').filter(l => /^\d/.test(l)).map(l => l.split(',')[0])).toEqual(['3', '4', '5', '6', '7', '8', '9', '10']);
+    });
+
+    it('should reject scalar and unknown nodes', () => {
+      const scalar = callWasm('renderASCII', { input: sample(), mode: 'PLAN', format: 'CURRENT', rootNodeId: 5 });
+      const unknown = callWasm('renderASCII', { input: sample(), mode: 'PLAN', format: 'CURRENT', rootNodeId: 50 });
+
+      expect(scalar.error?.message).toContain('not an operator');
+      expect(unknown.error?.message).toContain('Invalid rootNodeId: 50');
+    });
+  });
+
+  describe('cost', () => {
+    const sample = () => callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+
+    it('should return self-time shares and mark hot operators', () => {
+      const response = callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT', cost: {} });
+
+      expect(response.success).toBe(true);
+      const costs = response.costs ?? [];
+      expect(costs.map(c => c.nodeId)).toEqual([0, 1, 2, 3, 4]);
+      const total = costs.reduce((sum, c) => sum + (c.latencyShare ?? 0), 0);
+      expect(total).toBeCloseTo(1);
+      expect(costs.find(c => c.nodeId === 4)?.marker).toBe('!!');
+      expect(response.result).toMatch(/\|\s+Cost \|/);
+      expect(response.result).toMatch(/^\|\s+4 \|.*\d+\.\d% !! \|$/m);
+    });
+
+    it('should use the CPU metric and thresholds in HTML', () => {
+      const response = callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'HTML', cost: { metric: 'cpu', hot: 0.05 } });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('<th class="cost">Cost</th>');
+      expect(response.costs?.find(c => c.nodeId === 3)?.marker).toBe('*');
+    });
+
+    it('should reject invalid options', () => {
+      const metric = callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT', cost: { metric: 'io' as never } });
+      const shares = callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT', cost: { hot: 0.5, critical: 0.2 } });
+
+      expect(metric.error?.message).toContain('Invalid cost metric');
+      expect(shares.error?.message).toContain('critical 0.2 is less than hot 0.5');
+    });
+  });
+
+  describe('heatmap', () => {
+    const sample = () => callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+
+    it('should return the shares of every operator', () => {
+      const response = callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT', heatmap: true });
+
+      expect(response.success).toBe(true);
+      const annotations = response.annotations ?? [];
+      expect(annotations.map(a => a.nodeId)).toEqual([0, 1, 2, 3, 4]);
+      for (const key of ['latencyShare', 'rowsShare', 'cpuShare'] as const) {
+        const shares = annotations.map(a => a[key] ?? 0);
+        expect(shares.every(v => v >= 0 && v <= 1)).toBe(true);
+        expect(shares.reduce((sum, v) => sum + v, 0)).toBeCloseTo(1);
+      }
+      const costs = callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT', cost: {} }).costs ?? [];
+      expect(annotations.map(a => a.latencyShare)).toEqual(costs.map(c => c.latencyShare));
+    });
+
+    it('should return the shares for HTML and be omitted unless requested', () => {
+      const plan = callWasm('renderASCII', { input: sample(), mode: 'PLAN', format: 'HTML', heatmap: true });
+      const plain = callWasm('renderASCII', { input: sample(), mode: 'PROFILE', format: 'CURRENT' });
+
+      expect(plan.success).toBe(true);
+      expect(plan.annotations?.length).toBe(5);
+      expect(plain.annotations).toBeUndefined();
+    });
+  });
+
+  describe('lineMap', () => {
+    it('should map row lines, including wrapped lines and annotations, to their nodes', () => {
+      const response = callWasm('renderASCII', {
+        input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 12,
+        annotations: { 0: 'root note' }, showQueryText: true, lineMap: true,
+      });
+
+      expect(response.success).toBe(true);
+      const lines = (response.result ?? '').split('\n');
+      const entries = response.lineMap ?? [];
+      expect(entries.length).toBeGreaterThan(3);
+      for (const entry of entries) {
+        const line = lines[entry.line] ?? '';
+        expect(line.startsWith('|')).toBe(true);
+        if (!entry.continuation) {
+          expect(line).toMatch(new RegExp(`^\\|\\s*\\*?\\s*${entry.nodeId}\\s*\\|`));
+        }
+      }
+      expect(entries.some(e => e.continuation)).toBe(true);
+      const annotation = lines.findIndex(l => l.includes('root note'));
+      const wrapIndex = annotation - (entries.find(e => e.nodeId === 0)?.line ?? 0);
+      expect(entries.find(e => e.line === annotation)).toEqual({ line: annotation, nodeId: 0, continuation: true, wrapIndex, rowId: `0:${wrapIndex}` });
+    });
+
+    it('should identify rows across renders with other options', () => {
+      const wrapped = callWasm('renderASCII', {
+        input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 12, annotations: { 0: 'root note' }, lineMap: true,
+      });
+      const unwrapped = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, lineMap: true });
+
+      const wrappedIds = (wrapped.lineMap ?? []).map(e => e.rowId);
+      const unwrappedIds = (unwrapped.lineMap ?? []).map(e => e.rowId);
+      expect(new Set(wrappedIds).size).toBe(wrappedIds.length);
+      // Every row starts with wrap index 0 in both renders
+      const firstLines = unwrappedIds.filter(id => id.endsWith(':0'));
+      expect(firstLines.length).toBeGreaterThan(0);
+      for (const id of firstLines) {
+        const entry = wrapped.lineMap?.find(e => e.rowId === id);
+        expect(entry?.wrapIndex).toBe(0);
+        expect(`${entry?.nodeId}:0`).toBe(id);
+      }
+      expect(wrappedIds.length).toBeGreaterThan(unwrappedIds.length);
+    });
+
+    it('should be omitted unless requested', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT' });
+
+      expect(response.lineMap).toBeUndefined();
+    });
+  });
+
+  describe('borderStyle', () => {
+    const params: RenderParams = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+    const render = (borderStyle: NonNullable<RenderParams['borderStyle']>): string[] =>
+      (callWasm('renderASCII', { ...params, borderStyle }).result ?? '').split('\n');
+
+    it('should draw the borders with box-drawing characters', () => {
+      const ascii = (callWasm('renderASCII', params).result ?? '').split('\n');
+
+      for (const [style, corners] of [['light', '┌┐└┘'], ['heavy', '┏┓┗┛'], ['double', '╔╗╚╝']] as const) {
+        const lines = render(style);
+        const bottom = lines.findIndex(line => line.startsWith(corners.charAt(2)));
+        expect(lines[0]?.startsWith(corners.charAt(0))).toBe(true);
+        expect(lines[0]?.endsWith(corners.charAt(1))).toBe(true);
+        expect(lines[bottom]?.endsWith(corners.charAt(3))).toBe(true);
+        expect(lines.slice(0, bottom + 1).join('')).not.toMatch(/^[+|]|[+|]$/m);
+        // Only borders change: widths, tree connectors, and appendices are kept
+        expect(lines.map(line => line.length)).toEqual(ascii.map(line => line.length));
+        expect(lines.slice(bottom + 1)).toEqual(ascii.slice(bottom + 1));
+      }
+    });
+
+    it('should keep only the header rule with minimal borders', () => {
+      const lines = render('minimal');
+
+      expect(lines[0]).toMatch(/^\| ID +\| Operator/);
+      expect(lines[1]).toMatch(/^\|-+\|-+/);
+      expect(lines.filter(line => line.startsWith('+'))).toEqual([]);
+    });
+
+    it('should separate columns with spaces without borders', () => {
+      const lines = render('none');
+
+      expect(lines[0]).toMatch(/^ ID +Operator$/);
+      expect(lines.filter(line => /^[+|]/.test(line))).toEqual([]);
+    });
+
+    it('should keep the lineMap on the styled lines', () => {
+      for (const borderStyle of ['light', 'minimal', 'none'] as const) {
+        const response = callWasm('renderASCII', { ...params, borderStyle, lineMap: true });
+        const lines = (response.result ?? '').split('\n');
+
+        expect(response.lineMap?.length).toBeGreaterThan(0);
+        for (const entry of response.lineMap ?? []) {
+          if (!entry.continuation) {
+            expect(lines[entry.line]).toMatch(new RegExp(`^\\S?\\s*\\*?\\s*${entry.nodeId}\\s`));
+          }
+        }
+      }
+    });
+
+    it('should reject unknown styles', () => {
+      expect(callWasm('renderASCII', { ...params, borderStyle: 'dotted' }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('wrapMode', () => {
+    const longInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Sort Limit"
+        kind: RELATIONAL
+        index: 0
+        metadata:
+          call_type: "Local"
+          sort_key: "$sort_expr.order_by, $sort_expr.created_at (DESC)"
+        childLinks:
+          - childIndex: 1
+      - displayName: "Table Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata:
+          scan_target: "SongsBySingerAlbumSongNameDesc"
+`;
+    const render = (wrapMode?: RenderParams['wrapMode']) =>
+      callWasm('renderASCII', { input: longInput, mode: 'PLAN', format: 'TRADITIONAL', wrapWidth: 24, wrapMode });
+
+    it('should keep tokens whole and the table aligned', () => {
+      for (const wrapMode of ['word', 'smart'] as const) {
+        const lines = (render(wrapMode).result ?? '').split('\n');
+        const end = lines.findIndex(line => !/^[|+]/.test(line));
+        const table = end < 0 ? lines : lines.slice(0, end);
+
+        expect(table.length, wrapMode).toBeGreaterThan(4);
+        expect(new Set(table.map(line => line.length)).size, wrapMode).toBe(1);
+        expect(table.some(line => line.includes('$sort_expr.order_by')), wrapMode).toBe(true);
+      }
+    });
+
+    it('should wrap mid-token by default', () => {
+      expect(render('char').result).toBe(render().result);
+      expect(render('hyphenate' as RenderParams['wrapMode']).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('targetWidth', () => {
+    const params: RenderParams = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+    const tableWidth = (result?: string) => (result ?? '').split('\n').find(line => line.startsWith('+'))?.length ?? 0;
+
+    it('should pick the widest wrap width that fits', () => {
+      const unwrapped = callWasm('renderASCII', params).result;
+      const target = tableWidth(unwrapped) - 2;
+      const fitted = callWasm('renderASCII', { ...params, targetWidth: target });
+
+      expect(fitted.success).toBe(true);
+      expect(fitted.warnings).toBeUndefined();
+      expect(tableWidth(fitted.result)).toBeLessThanOrEqual(target);
+      expect(callWasm('renderASCII', { ...params, targetWidth: 1000 }).result).toBe(unwrapped);
+    });
+
+    it('should warn when the table cannot fit', () => {
+      const response = callWasm('renderASCII', { ...params, targetWidth: 5 });
+
+      expect(response.success).toBe(true);
+      expect(response.warnings?.map(w => w.code)).toContain('TARGET_WIDTH_EXCEEDED');
+    });
+
+    it('should not be combined with wrapWidth', () => {
+      expect(callWasm('renderASCII', { ...params, wrapWidth: 40, targetWidth: 80 }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('columnConfig', () => {
+    const params: RenderParams = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+    const render = (columnConfig: Record<string, ColumnConfig>) => callWasm('renderASCII', { ...params, columnConfig });
+
+    it('should truncate cells with an ellipsis', () => {
+      const lines = (render({ Operator: { truncate: 6 } }).result ?? '').split('\n');
+
+      expect(lines[1]).toMatch(/^\| +ID \| Opera… \|$/);
+      expect(lines.find(line => /^\|\s*\*?3\s*\|/.test(line))).toMatch(/\| \+- Ag… \|$/);
+      expect(new Set(lines.filter(line => /^[|+]/.test(line)).map(line => line.length)).size).toBe(1);
+    });
+
+    it('should cut cells at maxWidth and align them', () => {
+      const lines = (render({ ID: { align: 'left' }, Operator: { maxWidth: 4 } }).result ?? '').split('\n');
+
+      expect(lines[1]).toMatch(/^\| ID +\| Oper \|$/);
+      expect(lines.find(line => /^\| \*?3 +\| \+- A \|$/.test(line))).toBeDefined();
+    });
+
+    it('should reject invalid configs', () => {
+      expect(render({ Operator: { align: 'right' } }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(render({ Rows: { maxWidth: 5, truncate: 5 } }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(render({ Nope: { truncate: 5 } }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(render({ Latency: { align: 'right' } }).success).toBe(true);
+    });
+  });
+
+  describe('stat unit normalization', () => {
+    const mixedUnitsInput = (latency: string, cpu: string) => `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        executionStats:
+          rows: { total: "1,234", unit: "rows" }
+          latency: { total: "${latency}" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata:
+          scan_type: TableScan
+          scan_target: Songs
+        executionStats:
+          rows: { total: "3.50", unit: "rows" }
+          latency: { total: "${cpu}", unit: "us" }
+`;
+
+    it('should render mixed units in one duration unit', () => {
+      const response = callWasm('renderASCII', {
+        input: mixedUnitsInput('1.23 secs', '500'), mode: 'PROFILE', format: 'CURRENT', numberFormat: { durationUnit: 'ms' },
+      });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toMatch(/^\|\s+0 \| Distributed Union\s+\|\s+1234 \|\s+\|\s+1230 msecs \|$/m);
+      expect(response.result).toMatch(/^\|\s+1 \|.*\|\s+0\.5 msecs \|$/m);
+    });
+
+    it('should parse stats with the decimal separator', () => {
+      const input = mixedUnitsInput('1.234,5 ms', '2,5');
+      const plan: ParsedPlan = JSON.parse(callWasm('parsePlan', { input, decimalSeparator: ',' }).result ?? '{}');
+
+      expect(plan.nodes[0]?.statValues).toEqual({ rows: 1.234, latency: 1234.5 });
+      expect(plan.nodes[0]?.executionStats).toMatchObject({ latency: { total: '1234.5', unit: 'msecs' } });
+      expect(plan.nodes[1]?.statValues).toEqual({ rows: 3.5, latency: 0.0025 });
+    });
+
+    it('should keep plain decimals with the comma separator', () => {
+      const input = mixedUnitsInput('18.4', '18.4');
+      const plan: ParsedPlan = JSON.parse(callWasm('parsePlan', { input, decimalSeparator: ',' }).result ?? '{}');
+
+      expect(plan.nodes[0]?.statValues).toEqual({ rows: 1.234, latency: 18.4 });
+      expect(plan.nodes[1]?.executionStats).toMatchObject({ rows: { total: '3.50' }, latency: { total: '18.4', unit: 'usecs' } });
+      expect(plan.nodes[1]?.statValues).toEqual({ rows: 3.5, latency: 0.0184 });
+    });
+
+    it('should convert durations to statDurationUnit in every format and the JSON APIs', () => {
+      const input = mixedUnitsInput('1.23 secs', '15000');
+
+      const table = callWasm('renderASCII', { input, mode: 'PROFILE', format: 'CURRENT', statDurationUnit: 'ms' });
+      expect(table.result).toMatch(/^\|\s+0 \| Distributed Union\s+\|\s+1234 \|\s+\| 1230 msecs\s+\|$/m);
+      expect(table.result).toMatch(/^\|\s+1 \|.*\| 15 msecs\s+\|$/m);
+      const compact = callWasm('renderASCII', { input, mode: 'PROFILE', format: 'COMPACT', statDurationUnit: 'ms' });
+      expect(compact.result).toContain('1230 msecs');
+      expect(compact.result).not.toMatch(/\d secs/);
+
+      const plan: ParsedPlan = JSON.parse(callWasm('parsePlan', { input, statDurationUnit: 's' }).result ?? '{}');
+      expect(plan.nodes[0]?.executionStats).toMatchObject({ latency: { total: '1.23', unit: 'secs' } });
+      expect(plan.nodes[1]?.executionStats).toMatchObject({ latency: { total: '0.015', unit: 'secs' } });
+      expect(plan.nodes.map(n => n.statValues?.latency)).toEqual([1230, 15]);
+
+      const invalid = callWasm('renderASCII', { input, mode: 'PROFILE', format: 'CURRENT', statDurationUnit: 'min' as never });
+      expect(invalid.error?.type).toBe('INVALID_PARAMETERS');
+      expect(invalid.error?.message).toContain('Invalid statDurationUnit');
+    });
+
+    it('should reject unknown decimal separators', () => {
+      const response = callWasm('renderASCII', { input: mixedUnitsInput('1', '1'), mode: 'PROFILE', format: 'CURRENT', decimalSeparator: ';' as never });
+
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+      expect(response.error?.message).toContain('Invalid decimalSeparator');
+    });
+  });
+
+  describe('numberFormat', () => {
+    const statsInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Table Scan"
+        kind: RELATIONAL
+        index: 0
+        executionStats:
+          rows: { total: "123456789", unit: "rows" }
+          latency: { total: "1500", unit: "msecs" }
+`;
+    const render = (numberFormat: RenderParams['numberFormat']) =>
+      callWasm('renderASCII', { input: statsInput, mode: 'PROFILE', format: 'CURRENT', wrapWidth: 0, numberFormat });
+    const row = (response: WasmResponse) => (response.result ?? '').split('\n').find(line => /^\|\s*\*?0\s*\|/.test(line)) ?? '';
+
+    it('should format counts and durations', () => {
+      expect(row(render({ thousandsSeparator: true }))).toMatch(/\| 123,456,789 \|.*\|\s+1,500 msecs \|$/);
+      expect(row(render({ siUnits: true }))).toContain('| 123.5M |');
+      expect(row(render({ durationUnit: 's' }))).toMatch(/\|\s+1\.5 secs \|$/);
+      expect(row(render({ durationUnit: 'µs', thousandsSeparator: true }))).toMatch(/\|\s+1,500,000 usecs \|$/);
+    });
+
+    it('should keep the table aligned and reject unknown units', () => {
+      const lines = (render({ thousandsSeparator: true, siUnits: true }).result ?? '').split('\n').filter(line => /^[|+]/.test(line));
+
+      expect(new Set(lines.map(line => line.length)).size).toBe(1);
+      expect(render({ durationUnit: 'h' as NonNullable<RenderParams['numberFormat']>['durationUnit'] }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('latencyDistribution', () => {
+    const distributionInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        executionStats:
+          latency: { total: "12.5", unit: "msecs", mean: "2.5", std_deviation: "0.75" }
+      - displayName: "Table Scan"
+        kind: RELATIONAL
+        index: 1
+        executionStats:
+          latency:
+            total: "10"
+            unit: "msecs"
+            histogram:
+              - { lower_bound: "0", upper_bound: "1", count: "2" }
+              - { lower_bound: "1", upper_bound: "4", count: "3" }
+`;
+    const render = (params: Partial<RenderParams>) =>
+      callWasm('renderASCII', { input: distributionInput, mode: 'PROFILE', format: 'CURRENT', wrapWidth: 0, ...params });
+    const row = (response: WasmResponse, id: number) => (response.result ?? '').split('\n').find(line => new RegExp(`^\\|\\s*\\*?${id}\\s*\\|`).test(line)) ?? '';
+
+    it('should add mean ± stddev, or p50 and p99 from histograms, to the latency cells', () => {
+      const response = render({ latencyDistribution: true });
+
+      expect(response.success).toBe(true);
+      expect(row(response, 0)).toMatch(/\|\s+12\.5 msecs \(2\.5 ± 0\.75\) \|$/);
+      expect(row(response, 1)).toMatch(/\| 10 msecs \(p50 1\.5, p99 3\.95\) \|$/);
+      expect(row(render({}), 0)).not.toContain('±');
+    });
+
+    it('should format the distribution with numberFormat', () => {
+      expect(row(render({ latencyDistribution: true, numberFormat: { durationUnit: 'µs' } }), 0)).toMatch(/\|\s+12500 usecs \(2500 ± 750\) \|$/);
+    });
+
+    it('should expose the distribution in the row model', () => {
+      const register = (globalThis as Record<string, unknown>).registerFormatter as (name: string, callback: FormatterCallback | null) => string;
+      register('distribution-test', (model) => JSON.stringify(model.rows.map(r => r.stats?.['latency']?.distribution)));
+      try {
+        const distributions: StatDistribution[] = JSON.parse(render({ format: 'DISTRIBUTION-TEST' }).result ?? '[]');
+        expect(distributions[0]).toEqual({ mean: 2.5, stdDeviation: 0.75 });
+        expect(distributions[1]?.histogram).toEqual([{ lowerBound: 0, upperBound: 1, count: 2 }, { lowerBound: 1, upperBound: 4, count: 3 }]);
+        expect(distributions[1]?.p50).toBe(1.5);
+      } finally {
+        register('distribution-test', null);
+      }
+    });
+  });
+
+  describe('sortChildrenBy', () => {
+    const fanOutInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Union All"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 2
+          - childIndex: 3
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        executionStats:
+          rows: { total: "30", unit: "rows" }
+          latency: { total: "1", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+        executionStats:
+          rows: { total: "10", unit: "rows" }
+          latency: { total: "5", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 3
+`;
+    const rowIDs = (sortChildrenBy?: RenderParams['sortChildrenBy']) =>
+      (callWasm('renderASCII', { input: fanOutInput, mode: 'PROFILE', format: 'CURRENT', wrapWidth: 0, sortChildrenBy }).result ?? '')
+        .split('\n').flatMap(line => /^\|\s*\*?(\d+)\s*\|/.exec(line)?.[1] ?? []);
+
+    it('should put the most expensive branch first', () => {
+      expect(rowIDs()).toEqual(['0', '1', '2', '3']);
+      expect(rowIDs('none')).toEqual(['0', '1', '2', '3']);
+      expect(rowIDs('latency')).toEqual(['0', '2', '1', '3']);
+      expect(rowIDs('rows')).toEqual(['0', '1', '2', '3']);
+    });
+
+    it('should reject unknown metrics', () => {
+      expect(callWasm('renderASCII', { input: fanOutInput, mode: 'PROFILE', format: 'CURRENT', wrapWidth: 0, sortChildrenBy: 'name' }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('childLinks and linkLabels', () => {
+    const subqueryInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Cross Apply"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 2
+            type: "Map"
+            variable: "batch"
+          - childIndex: 3
+            type: "Scalar"
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+      - displayName: "Scalar Subquery"
+        kind: SCALAR
+        index: 3
+        childLinks:
+          - childIndex: 4
+      - displayName: "Aggregate"
+        kind: RELATIONAL
+        index: 4
+`;
+    const render = (params: Partial<RenderParams>) =>
+      callWasm('renderASCII', { input: subqueryInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, ...params });
+    const rowIDs = (childLinks?: RenderParams['childLinks']) =>
+      (render({ childLinks }).result ?? '').split('\n').flatMap(line => /^\|\s*\*?(\d+)\s*\|/.exec(line)?.[1] ?? []);
+
+    it('should hide or drop scalar subqueries', () => {
+      expect(rowIDs()).toEqual(['0', '1', '2', '3', '4']);
+      expect(rowIDs('all')).toEqual(['0', '1', '2', '3', '4']);
+      expect(rowIDs('hideScalar')).toEqual(['0', '1', '2', '4']);
+      expect(rowIDs('relational')).toEqual(['0', '1', '2']);
+    });
+
+    it('should label relational edges with their link type and variable', () => {
+      expect(render({}).result).toContain('[Map] Scan');
+      expect(render({ linkLabels: true }).result).toContain('[Map $batch] Scan');
+      expect(render({ linkLabels: true }).result).toContain('[Scalar] Scalar Subquery');
+    });
+
+    it('should reject unknown childLinks values', () => {
+      expect(render({ childLinks: 'none' as RenderParams['childLinks'] }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('TIMELINE mode', () => {
+    const timelineInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Union All"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 2
+        executionStats:
+          latency: { total: "10", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        executionStats:
+          latency: { total: "4", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+        executionStats:
+          latency: { total: "5000", unit: "usecs" }
+`;
+
+    it('should lay out inputs one after another within their parent', () => {
+      const response = callWasm('renderASCII', { input: timelineInput, mode: 'TIMELINE', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.success).toBe(true);
+      expect(response.timeline).toEqual([
+        { nodeId: 0, startMillis: 0, durationMillis: 10 },
+        { nodeId: 1, startMillis: 0, durationMillis: 4 },
+        { nodeId: 2, startMillis: 4, durationMillis: 5 },
+      ]);
+      const lines = (response.result ?? '').split('\n');
+      expect(lines.find(line => line.includes('Timeline'))).toMatch(/\| {33}Timeline \|$/);
+      expect(lines.find(line => /^\|\s*\*?0\s*\|/.test(line))).toMatch(/\| █{40} \|$/);
+      expect(lines.find(line => /^\|\s*\*?2\s*\|/.test(line))).toMatch(/\| {17}█{20} {5}\|$/);
+    });
+
+    it('should warn about plans without latency', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'TIMELINE', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.timeline).toBeUndefined();
+      expect(response.warnings?.map(w => w.code)).toContain('NO_LATENCY_STATS');
+    });
+  });
+
+  describe('TREE format', () => {
+    const treeInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+      - displayName: "Filter Scan"
+        kind: RELATIONAL
+        index: 1
+        childLinks:
+          - childIndex: 2
+          - childIndex: 3
+            type: "Residual Condition"
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+        metadata:
+          scan_type: IndexScan
+          scan_target: SongsBySingerAlbum
+      - displayName: "Function"
+        kind: SCALAR
+        index: 3
+        shortRepresentation:
+          description: "($Duration > 300)"
+`;
+
+    it('should render indented operators with scan targets and predicates', () => {
+      const response = callWasm('renderASCII', { input: treeInput, mode: 'PROFILE', format: 'TREE' });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toBe([
+        'Distributed Union',
+        '  Filter Scan',
+        '    - Residual Condition: ($Duration > 300)',
+        '    Index Scan on SongsBySingerAlbum',
+        ''
+      ].join('\n'));
+    });
+
+    it('should render one line per operator with treeOneLine', () => {
+      const response = callWasm('renderASCII', { input: treeInput, mode: 'PLAN', format: 'tree', treeOneLine: true, rootNodeId: 1 });
+
+      expect(response.result).toBe('Filter Scan\n  Index Scan on SongsBySingerAlbum\n');
+    });
+  });
+
+  describe('CSV, TSV, and JSONL formats', () => {
+    it('should export a row per plan node with metadata and stat columns', () => {
+      const input = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+
+      const response = callWasm('renderASCII', { input, mode: 'PROFILE', format: 'csv' });
+
+      expect(response.success).toBe(true);
+      const lines = (response.result ?? '').trimEnd().split('\n');
+      expect(lines).toHaveLength(15);
+      const header = lines[0]?.split(',') ?? [];
+      expect(header.slice(0, 7)).toEqual(['id', 'parent_id', 'depth', 'kind', 'display_name', 'title', 'short_representation']);
+      expect(header).toContain('metadata_Full_scan');
+      expect(header).toContain('stats_latency_total');
+      expect(header).toContain('stats_execution_summary_num_executions');
+      expect(lines[5]).toMatch(/^4,3,4,RELATIONAL,Scan,"Table Scan \(Table: Singers,/);
+      expect(lines[9]).toBe(`8,3,4,SCALAR,Function,Function,($LastName = @last_name)${','.repeat(header.length - 7)}`);
+    });
+
+    it('should separate TSV fields with tabs and omit stats in PLAN mode', () => {
+      const input = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+
+      const response = callWasm('renderASCII', { input, mode: 'PLAN', format: 'TSV' });
+
+      const header = response.result?.split('\n')[0] ?? '';
+      expect(header.startsWith('id\tparent_id\tdepth')).toBe(true);
+      expect(header).not.toContain('stats_');
+    });
+
+    it('should export a JSON object per plan node with nested metadata and stats', () => {
+      const input = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+
+      const response = callWasm('renderASCII', { input, mode: 'PROFILE', format: 'jsonl' });
+
+      expect(response.success).toBe(true);
+      const nodes = (response.result ?? '').trimEnd().split('\n').map(line => JSON.parse(line));
+      expect(nodes).toHaveLength(14);
+      expect(nodes[0]).toMatchObject({ id: 0, parent_id: null, depth: 0, kind: 'RELATIONAL' });
+      expect(nodes[4]).toMatchObject({ id: 4, parent_id: 3, display_name: 'Scan', metadata: { scan_target: 'Singers' } });
+      expect(nodes[4].stats.execution_summary.num_executions).toBeDefined();
+      expect(nodes[8]).toMatchObject({ id: 8, kind: 'SCALAR', short_representation: '($LastName = @last_name)' });
+    });
+
+    it('should omit stats from JSONL in PLAN mode', () => {
+      const input = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+
+      const response = callWasm('renderASCII', { input, mode: 'PLAN', format: 'JSONL', rootNodeId: 3 });
+
+      const nodes = (response.result ?? '').trimEnd().split('\n').map(line => JSON.parse(line));
+      expect(nodes[0]?.id).toBe(3);
+      expect(nodes.every(n => n.stats === undefined)).toBe(true);
+    });
+  });
+
+  describe('template columns', () => {
+    const profileInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 0
+        metadata:
+          scan_type: TableScan
+          scan_target: Singers
+        executionStats:
+          rows: { total: "50", unit: "rows" }
+          latency: { total: "2.5", unit: "msecs" }
+`;
+    const params = { input: profileInput, mode: 'PROFILE', format: 'TRADITIONAL', wrapWidth: 0 };
+
+    it('should add a column per template', () => {
+      const response = callWasm('renderASCII', {
+        ...params,
+        templateColumns: [
+          { title: 'Target', template: '{{.Metadata.scan_type}} {{.Metadata.scan_target}}' },
+          { title: 'Rows/ms', template: '{{div .Stats.rows (ms .Stats.latency) | printf "%.1f"}}' },
+        ],
+        columns: ['ID', 'Target', 'Rows/ms'],
+      });
+
+      expect(response.success).toBe(true);
+      const lines = (response.result ?? '').split('\n');
+      expect(lines[1]).toMatch(/^\| ID \|\s+Target \| Rows\/ms \|$/);
+      expect(lines[3]).toMatch(/^\|\s+0 \| TableScan Singers \|\s+20\.0 \|$/);
+    });
+
+    it('should warn about operators a template fails for', () => {
+      const response = callWasm('renderASCII', { ...params, templateColumns: [{ title: 'Ratio', template: '{{div .Stats.rows .Stats.cpu_time}}' }] });
+
+      expect(response.success).toBe(true);
+      expect(response.warnings?.map(w => w.code)).toContain('TEMPLATE_COLUMN_ERROR');
+    });
+
+    it('should reject invalid templates and taken titles', () => {
+      expect(callWasm('renderASCII', { ...params, templateColumns: [{ title: 'X', template: '{{' }] }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('renderASCII', { ...params, templateColumns: [{ title: 'rows', template: 'x' }] }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('fingerprintPlan', () => {
+    const planInput = (query: string, scanType: string, rows: string, target = 'Singers') => `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 0
+        metadata:
+          scan_type: ${scanType}
+          scan_target: ${target}
+        executionStats:
+          rows: { total: "${rows}", unit: "rows" }
+  queryStats:
+    query_text: ${JSON.stringify(query)}
+`;
+
+    const fingerprint = (input: string): PlanFingerprint => JSON.parse(callWasm('fingerprintPlan', { input }).result ?? '{}');
+
+    it('should give the same fingerprints to runs with different literals and stats', () => {
+      const a = fingerprint(planInput("SELECT * FROM Singers WHERE Id IN (1, 2, 3) AND Name = 'A' -- first", 'TableScan', '3'));
+      const b = fingerprint(planInput('select *\n  from Singers where Id in (42) and Name = "B"', 'TableScan', '1'));
+
+      expect(a.normalizedQuery).toBe('select * from singers where id in (?) and name = ?');
+      expect(a.query).toMatch(/^[0-9a-f]{16}$/);
+      expect(a).toEqual(b);
+    });
+
+    it('should keep the query fingerprint when the plan changes', () => {
+      const a = fingerprint(planInput('SELECT * FROM Singers WHERE Id = 1', 'TableScan', '1'));
+      const b = fingerprint(planInput('SELECT * FROM Singers WHERE Id = 2', 'IndexScan', '1'));
+
+      expect(a.query).toBe(b.query);
+      expect(a.plan).not.toBe(b.plan);
+    });
+
+    it('should tell scans of different indexes apart only with targets', () => {
+      const a = fingerprint(planInput('SELECT * FROM Singers WHERE Name = "A"', 'IndexScan', '1', 'SingersByName'));
+      const b = fingerprint(planInput('SELECT * FROM Singers WHERE Name = "A"', 'IndexScan', '1', 'SingersByNameAndId'));
+
+      expect(a.planWithTargets).toMatch(/^[0-9a-f]{16}$/);
+      expect(a.plan).toBe(b.plan);
+      expect(a.planWithTargets).not.toBe(b.planWithTargets);
+    });
+
+    it('should omit the query fingerprint without query text', () => {
+      const fp = fingerprint(scalarAppendixInput);
+
+      expect(fp.plan).toMatch(/^[0-9a-f]{16}$/);
+      expect(fp.query).toBeUndefined();
+    });
+  });
+
+  describe('checkPlanRegression', () => {
+    const planInput = (query: string, scanType: string, target: string, filter: boolean) => `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata:
+          scan_type: ${scanType}
+          scan_target: ${target}${filter ? `
+        childLinks:
+          - childIndex: 2
+      - displayName: "Filter"
+        kind: RELATIONAL
+        index: 2` : ''}
+  queryStats:
+    query_text: ${JSON.stringify(query)}
+`;
+    const query = 'SELECT * FROM Singers WHERE Id = 1';
+
+    const check = (input: string, params: Record<string, unknown> = {}) => {
+      const baseline: PlanFingerprint = JSON.parse(callWasm('fingerprintPlan', { input: planInput(query, 'TableScan', 'Singers', true) }).result ?? '{}');
+      const response = callWasm('checkPlanRegression', { input, baselines: [{ ...baseline, label: 'ci' }], ...params });
+      const result: PlanRegression = JSON.parse(response.result ?? '{}');
+      return { response, result };
+    };
+
+    it('should match a baseline of the same plan with other literals', () => {
+      const { response, result } = check(planInput('SELECT * FROM Singers WHERE Id = 42', 'TableScan', 'Singers', true));
+
+      expect(response.success).toBe(true);
+      expect(result.matches).toBe(true);
+      expect(result.matchedBaseline).toBe(0);
+      expect(result.diff).toBeUndefined();
+      expect(result.fingerprint.shape).toEqual(['Distributed Union', '  Table Scan (Singers)', '    Filter']);
+    });
+
+    it('should diff the shape of a changed plan against the baseline', () => {
+      const { result } = check(planInput(query, 'IndexScan', 'Singers', false));
+
+      expect(result.matches).toBe(false);
+      expect(result.targetsChanged).toBeUndefined();
+      expect(result.diff?.lines).toEqual(['  Distributed Union', '-   Table Scan (Singers)', '-     Filter', '+   Index Scan (Singers)']);
+      expect(result.diff?.summary).toBe('1 operator added and 2 operators removed since baseline "ci"');
+    });
+
+    it('should report scans of other tables or indexes unless ignoreTargets', () => {
+      const input = planInput(query, 'TableScan', 'SingersByName', true);
+      const { result } = check(input);
+
+      expect(result.matches).toBe(false);
+      expect(result.targetsChanged).toBe(true);
+      expect(result.diff?.added).toBe(1);
+      expect(check(input, { ignoreTargets: true }).result.matches).toBe(true);
+    });
+
+    it('should warn when no baseline is of the query', () => {
+      const { response, result } = check(planInput('SELECT 1', 'TableScan', 'Singers', true));
+
+      expect(result.matches).toBe(true);
+      expect(response.warnings?.map((w) => w.code)).toEqual(['BASELINE_QUERY_MISMATCH']);
+    });
+
+    it('should require baselines', () => {
+      const response = callWasm('checkPlanRegression', { input: planInput(query, 'TableScan', 'Singers', true), baselines: [] });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('summarizePlan', () => {
+    it('should count operators per depth and find the widest level', () => {
+      const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - { index: 0, kind: RELATIONAL, displayName: "Union All", childLinks: [{ childIndex: 1 }, { childIndex: 2 }] }
+      - { index: 1, kind: RELATIONAL, displayName: "Scan", childLinks: [{ childIndex: 3 }] }
+      - { index: 2, kind: RELATIONAL, displayName: "Scan" }
+      - { index: 3, kind: SCALAR, displayName: "Reference", shortRepresentation: { description: "x" } }
+`;
+      const response = callWasm('summarizePlan', { input });
+
+      expect(response.success).toBe(true);
+      const summary: PlanSummary = JSON.parse(response.result ?? '{}');
+      expect(summary.counts.relationalNodes).toBe(3);
+      expect(summary.depth).toBe(2);
+      expect(summary.operatorsPerDepth).toEqual([1, 2]);
+      expect(summary.widestDepth).toBe(1);
+      expect(summary.widestOperators).toBe(2);
+      expect(summary.queryStats).toBeUndefined();
+      expect(summary.operatorTypes).toEqual([{ type: 'scan', operators: 2 }, { type: 'other', operators: 1 }]);
+    });
+
+    it('should return the query stats and self times per operator type of PROFILE captures', () => {
+      const input = callWasm('getSample', { name: 'distributed-join' }).result ?? '';
+
+      const summary: PlanSummary = JSON.parse(callWasm('summarizePlan', { input }).result ?? '{}');
+
+      expect(summary.queryStats).toEqual({
+        elapsedMillis: 18.71,
+        cpuMillis: 9.82,
+        rowsReturned: 60,
+        rowsScanned: 80,
+        optimizerVersion: '7',
+        optimizerStatisticsPackage: 'auto_20250601_05_12_34UTC',
+      });
+      expect(summary.operatorTypes.map(t => t.type)).toEqual(['scan', 'join', 'distribution', 'other']);
+      expect(summary.operatorTypes.find(t => t.type === 'scan')).toEqual({ type: 'scan', operators: 4, latencyMillis: 17, cpuMillis: 8.6 });
+      expect(summary.optimizer).toEqual({
+        version: '7',
+        statisticsPackage: 'auto_20250601_05_12_34UTC',
+        statisticsTimestamp: '2025-06-01T05:12:34Z',
+      });
+    });
+  });
+
+  describe('optimizer info', () => {
+    it('should prepend the optimizer version and statistics package', () => {
+      const input = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+      const response = callWasm('renderASCII', { input, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, showOptimizerInfo: true });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toMatch(/^Optimizer:\n Version: 7\n Statistics package: auto_20250601_05_12_34UTC \(constructed 2025-06-01 05:12:34 UTC\)\n\n\+/);
+    });
+
+    it('should put the optimizer header after the query text', () => {
+      const input = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+      const response = callWasm('renderASCII', { input, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, showQueryText: true, showOptimizerInfo: true });
+
+      expect(response.result?.indexOf('Query:')).toBe(0);
+      expect(response.result).toContain('\n\nOptimizer:\n');
+    });
+
+    it('should warn when the input has no optimizer settings', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, showOptimizerInfo: true });
+
+      expect(response.success).toBe(true);
+      expect(response.result).not.toContain('Optimizer:');
+      expect(response.warnings?.map(w => w.code)).toEqual(['NO_OPTIMIZER_INFO']);
+    });
+
+    it('should leave the timestamp out for package names without one', () => {
+      const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - { index: 0, kind: RELATIONAL, displayName: "Scan" }
+  queryStats:
+    optimizer_statistics_package: "manual_package"
+`;
+      const summary: PlanSummary = JSON.parse(callWasm('summarizePlan', { input }).result ?? '{}');
+
+      expect(summary.optimizer).toEqual({ statisticsPackage: 'manual_package' });
+    });
+  });
+
+  describe('DML plans', () => {
+    const dmlInput = (rowCount: string) => `
+stats:
+  ${rowCount}
+  queryPlan:
+    planNodes:
+      - displayName: "Apply Mutations"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        metadata: { operation_type: UPDATE, table: Albums }
+        executionStats:
+          rows: { total: "3", unit: "rows" }
+          execution_summary: { num_executions: "4" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata: { scan_type: TableScan, scan_target: Albums }
+`;
+
+    it('should summarize the rows modified by DML', () => {
+      const response = callWasm('renderASCII', { input: dmlInput('rowCountExact: "3"'), mode: 'PROFILE', format: 'CURRENT' });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toMatch(/\nRows modified: 3 \(UPDATE Albums\)\n$/);
+      expect(response.metadata?.dml).toEqual({
+        operations: [{ nodeId: 0, operation: 'UPDATE', table: 'Albums', mutations: 3, executions: 4 }],
+        rowsModified: 3,
+      });
+    });
+
+    it('should report the lower bound and partition executions of partitioned DML', () => {
+      const response = callWasm('renderASCII', { input: dmlInput('rowCountLowerBound: "1000"'), mode: 'PROFILE', format: 'CURRENT' });
+
+      expect(response.result).toContain('Rows modified: at least 1000 (partitioned DML, UPDATE Albums, 4 partition executions)');
+      expect(response.metadata?.dml).toMatchObject({ rowsModifiedLowerBound: 1000, partitioned: true, partitionExecutions: 4 });
+    });
+
+    it('should not count Apply Mutations as a join', () => {
+      const summary: PlanSummary = JSON.parse(callWasm('summarizePlan', { input: dmlInput('') }).result ?? '{}');
+
+      expect(summary.operatorTypes.map(t => t.type)).toEqual(['scan', 'dml']);
+      expect(summary.dml?.operations[0]?.operation).toBe('UPDATE');
+    });
+
+    it('should leave plans without a modified row count unchanged', () => {
+      const response = callWasm('renderASCII', { input: dmlInput(''), mode: 'PLAN', format: 'CURRENT' });
+
+      expect(response.result).not.toContain('Rows modified');
+      expect(response.metadata?.dml?.rowsModified).toBeUndefined();
+    });
+
+    it('should narrate the mutations', () => {
+      const response = callWasm('explainPlan', { input: dmlInput('') });
+
+      expect(response.result).toContain('Spanner applies the UPDATE mutations of the input rows to the table Albums.');
+    });
+  });
+
+  describe('getCapabilities', () => {
+    const getCapabilities = (): Capabilities => {
+      const fn = (globalThis as Record<string, unknown>).getCapabilities as () => string;
+      const response: WasmResponse = JSON.parse(fn());
+      expect(response.success).toBe(true);
+      return JSON.parse(response.result ?? '{}');
+    };
+
+    it('should list values that renderASCII accepts', () => {
+      const caps = getCapabilities();
+
+      expect(caps.modes.map(m => m.value)).toEqual(['AUTO', 'PLAN', 'PROFILE', 'TIMELINE']);
+      expect(caps.formats.filter(f => f.kind === 'table').map(f => f.value)).toEqual(['CURRENT', 'TRADITIONAL', 'COMPACT', 'SPANNER-CLI']);
+      expect(caps.formats.filter(f => f.kind === 'ansi').map(f => f.value)).toEqual(['ANSI']);
+      expect(caps.formats.filter(f => f.kind === 'diagram').map(f => f.value)).toEqual(['DOT', 'MERMAID', 'PLANTUML']);
+      expect(caps.formats.filter(f => f.kind === 'html').map(f => f.value)).toEqual(['HTML']);
+      expect(caps.formats.filter(f => f.kind === 'flat').map(f => f.value)).toEqual(['CSV', 'TSV', 'JSONL']);
+      expect(caps.formats.filter(f => f.kind === 'tree').map(f => f.value)).toEqual(['TREE']);
+      expect(caps.formats.filter(f => f.kind === 'json').map(f => f.value)).toEqual(['JSON-NORMALIZED']);
+      for (const mode of caps.modes) {
+        for (const format of caps.formats) {
+          const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: mode.value, format: format.value, wrapWidth: 0 });
+          expect(response.success, `${mode.value} ${format.value}`).toBe(true);
+        }
+      }
+      for (const option of caps.options.filter(o => o.name === 'operatorFilter' || o.name === 'scalarRepresentation')) {
+        for (const value of option.values ?? []) {
+          const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: caps.defaultMode, format: caps.defaultFormat, wrapWidth: 0, [option.name]: value.value });
+          expect(response.success, `${option.name}: ${value.value}`).toBe(true);
+        }
+      }
+    });
+
+    it('should say which formats an option applies to', () => {
+      const options = getCapabilities().options;
+
+      expect(options.find(o => o.name === 'wrapWidth')?.formatKinds).toEqual(['table', 'ansi']);
+      expect(options.find(o => o.name === 'sortBy')?.formatKinds).toEqual(['custom']);
+      expect(options.find(o => o.name === 'consoleNaming')?.formatKinds).toEqual(['table', 'ansi', 'diagram', 'html', 'flat', 'tree', 'custom', 'experimental']);
+      expect(options.find(o => o.name === 'recover')?.formatKinds).toEqual(['table', 'ansi', 'diagram', 'html', 'flat', 'tree', 'custom', 'json', 'experimental']);
+      expect(options.find(o => o.name === 'colorTheme')?.formatKinds).toEqual(['ansi']);
+    });
+
+    it('should list registered formatters as custom formats', () => {
+      const register = (globalThis as Record<string, unknown>).registerFormatter as (name: string, callback: FormatterCallback | null) => string;
+      register('capabilities-test', () => '');
+      try {
+        expect(getCapabilities().formats).toContainEqual(expect.objectContaining({ value: 'CAPABILITIES-TEST', kind: 'custom' }));
+      } finally {
+        register('capabilities-test', null);
+      }
+      expect(getCapabilities().formats.some(f => f.value === 'CAPABILITIES-TEST')).toBe(false);
+    });
+
+    it('should list the built-in renderers after the table formats and refuse their names for formatters', () => {
+      const caps = getCapabilities();
+      const kinds = caps.formats.map(f => f.kind);
+      expect(kinds.indexOf('diagram')).toBeGreaterThan(kinds.lastIndexOf('html'));
+      expect(caps.formats.filter(f => f.kind === 'experimental')).toEqual([]);
+
+      const register = (globalThis as Record<string, unknown>).registerFormatter as (name: string, callback: FormatterCallback | null) => string;
+      for (const name of ['tree', 'JSON-NORMALIZED', 'PlantUML']) {
+        const response: WasmResponse = JSON.parse(register(name, () => ''));
+        expect(response.error?.type, name).toBe('INVALID_PARAMETERS');
+      }
+    });
+  });
+
+  describe('getVersionInfo', () => {
+    it('should report the Go and spannerplan versions and compiled-in features', () => {
+      const fn = (globalThis as Record<string, unknown>).getVersionInfo as () => string;
+      const response: WasmResponse = JSON.parse(fn());
+
+      expect(response.success).toBe(true);
+      const info: VersionInfo = JSON.parse(response.result ?? '{}');
+      expect(info.goVersion).toMatch(/^go/);
+      expect(info.spannerplanVersion).toBe(info.dependencies['github.com/apstndb/spannerplan']);
+      expect(info.spannerplanVersion).toMatch(/^v/);
+      expect(info.features).toContain('core');
+    });
+  });
+
+  describe('getFanOutReport', () => {
+    it('should estimate splits per distributed operator from remote calls and subquery executions', () => {
+      const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        metadata:
+          distribution_table: Singers
+          subquery_cluster_node: "1"
+        executionStats:
+          execution_summary: { num_executions: "2" }
+          remote_calls: { total: "6", unit: "calls" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        executionStats:
+          execution_summary: { num_executions: "8" }
+`;
+      const response = callWasm('getFanOutReport', { input });
+
+      expect(response.success).toBe(true);
+      const report: FanOutReport = JSON.parse(response.result ?? '{}');
+      expect(report).toEqual({
+        points: [{
+          nodeId: 0,
+          operator: 'Distributed Union',
+          distributionTable: 'Singers',
+          executions: 2,
+          remoteCalls: 6,
+          subqueryExecutions: 8,
+          estimatedSplits: 8,
+          splitsPerExecution: 4
+        }],
+        totalRemoteCalls: 6,
+        maxSplitsPerExecution: 4
+      });
+    });
+
+    it('should return an empty report for plans without distributed operators', () => {
+      const response = callWasm('getFanOutReport', { input: scalarAppendixInput });
+
+      expect(JSON.parse(response.result ?? '{}')).toEqual({ points: [], totalRemoteCalls: 0, maxSplitsPerExecution: 0 });
+    });
+  });
+
+  describe('extractScans', () => {
+    it('should list scans with key ranges and summarize them per table', () => {
+      const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 4
+            type: "Split Range"
+        metadata: { distribution_table: Albums }
+      - displayName: "Filter Scan"
+        kind: RELATIONAL
+        index: 1
+        childLinks:
+          - childIndex: 2
+          - childIndex: 3
+            type: "Seek Condition"
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+        metadata: { scan_type: IndexScan, scan_target: AlbumsByTitle, scan_method: Row }
+        executionStats:
+          rows: { total: "4", unit: "rows" }
+          execution_summary: { num_executions: "1" }
+      - displayName: "Function"
+        kind: SCALAR
+        index: 3
+        shortRepresentation: { description: "($AlbumTitle = 'Go')" }
+      - displayName: "Function"
+        kind: SCALAR
+        index: 4
+        shortRepresentation: { description: "($AlbumTitle = 'Go')" }
+`;
+      const response = callWasm('extractScans', { input });
+
+      expect(response.success).toBe(true);
+      const report: ScanReport = JSON.parse(response.result ?? '{}');
+      expect(report.scans).toEqual([{
+        nodeId: 2,
+        target: 'AlbumsByTitle',
+        method: 'index',
+        scanMethod: 'Row',
+        keyRanges: [
+          { type: 'Seek Condition', description: "($AlbumTitle = 'Go')" },
+          { type: 'Split Range', description: "($AlbumTitle = 'Go')" }
+        ],
+        rows: 4,
+        executions: 1
+      }]);
+      expect(report.tables).toEqual([{ target: 'AlbumsByTitle', method: 'index', scans: 1, fullScans: 0, rows: 4 }]);
+    });
+
+    it('should count full scans and leave batch scans out of the summary', () => {
+      const response = callWasm('extractScans', { input: callWasm('getSample', { name: 'distributed-join' }).result ?? '' });
+
+      expect(response.success).toBe(true);
+      const report: ScanReport = JSON.parse(response.result ?? '{}');
+      expect(report.scans.map(s => [s.target, s.method, s.fullScan ?? false])).toEqual([
+        ['Singers', 'table', true],
+        ['$v2', 'batch', false],
+        ['Albums', 'table', false]
+      ]);
+      expect(report.tables.map(t => [t.target, t.scans, t.fullScans])).toEqual([['Albums', 1, 0], ['Singers', 1, 1]]);
+    });
+  });
+
+  describe('prettyPredicates', () => {
+    const predicateInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Filter Scan"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+            type: "Residual Condition"
+        metadata:
+          scan_type: TableScan
+          scan_target: Songs
+      - displayName: "Function"
+        kind: SCALAR
+        index: 1
+        shortRepresentation:
+          description: "((($Duration > 300) AND ($Genre = 'ROCK OR POP')) OR ($Duration BETWEEN 10 AND 20))"
+`;
+
+    it('should break the predicates under the table at AND and OR', () => {
+      const response = callWasm('renderASCII', { input: predicateInput, mode: 'PLAN', format: 'CURRENT', prettyPredicates: true });
+
+      expect(response.success).toBe(true);
+      expect(response.result?.split('Predicates(identified by ID):\n')[1]).toBe([
+        ' 0: Residual Condition: (',
+        '                          ($Duration > 300)',
+        "                          AND ($Genre = 'ROCK OR POP')",
+        '                        )',
+        '                        OR ($Duration BETWEEN 10 AND 20)',
+        ''
+      ].join('\n'));
+    });
+
+    it('should break the predicates of the TREE format', () => {
+      const response = callWasm('renderASCII', { input: predicateInput, mode: 'PLAN', format: 'TREE', prettyPredicates: true });
+
+      expect(response.result?.split('\n').slice(1, 3)).toEqual([
+        '  - Residual Condition: (',
+        '                          ($Duration > 300)',
+      ]);
+    });
+
+    it('should return the parsed predicates in the node detail', () => {
+      const detail: NodeDetail = JSON.parse(callWasm('getNodeDetail', { input: predicateInput, nodeId: 0 }).result ?? '{}');
+
+      expect(detail.predicates).toEqual([{
+        childId: 1,
+        type: 'Residual Condition',
+        description: "((($Duration > 300) AND ($Genre = 'ROCK OR POP')) OR ($Duration BETWEEN 10 AND 20))",
+        expression: {
+          op: 'OR',
+          operands: [
+            { op: 'AND', operands: [{ text: '($Duration > 300)' }, { text: "($Genre = 'ROCK OR POP')" }] },
+            { text: '($Duration BETWEEN 10 AND 20)' }
+          ]
+        }
+      }]);
+    });
+  });
+
+  describe('getNodeDetail', () => {
+    it('should describe a node with its child links and ancestors', () => {
+      const response = callWasm('getNodeDetail', { input: scalarAppendixInput, nodeId: 3 });
+
+      expect(response.success).toBe(true);
+      const detail: NodeDetail = JSON.parse(response.result ?? '{}');
+      expect(detail.id).toBe(3);
+      expect(detail.kind).toBe('RELATIONAL');
+      expect(detail.displayName).toBe('Aggregate');
+      expect(detail.childLinks).toEqual([
+        { childId: 4, type: 'Key', variable: "group_SongGenre'", kind: 'SCALAR', displayName: 'Reference' },
+        { childId: 5, type: 'Agg', variable: 'SongCount', kind: 'SCALAR', displayName: 'Reference' },
+        { childId: 6, kind: 'RELATIONAL', displayName: 'Scan' }
+      ]);
+      expect(detail.parentChain.map(a => [a.id, a.displayName])).toEqual([[0, 'Sort']]);
+    });
+
+    it('should return the short representation of scalar nodes', () => {
+      const response = callWasm('getNodeDetail', { input: scalarAppendixInput, nodeId: 5 });
+
+      const detail: NodeDetail = JSON.parse(response.result ?? '{}');
+      expect(detail.shortRepresentation).toEqual({ description: 'COUNT_FINAL($v1)' });
+      expect(detail.parentChain.map(a => a.id)).toEqual([0, 3]);
+      expect(detail.childLinks).toBeUndefined();
+    });
+
+    it('should look up nodes of loaded plans', () => {
+      const loaded: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput }).result ?? '{}');
+
+      const response = callWasm('getNodeDetail', { id: loaded.id, nodeId: 0 });
+
+      expect(response.success).toBe(true);
+      const detail: NodeDetail = JSON.parse(response.result ?? '{}');
+      expect(detail.displayName).toBe('Sort');
+      expect(detail.parentChain).toEqual([]);
+      callWasm('releasePlan', { id: loaded.id });
+    });
+
+    it('should reject unknown nodes and ambiguous plans', () => {
+      expect(callWasm('getNodeDetail', { input: scalarAppendixInput, nodeId: 99 }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('getNodeDetail', { input: scalarAppendixInput }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('getNodeDetail', { input: scalarAppendixInput, id: 'plan-1', nodeId: 0 }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('searchPlan', () => {
+    const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        metadata:
+          distribution_table: Songs
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata:
+          scan_type: IndexScan
+          scan_target: SongsBySingerAlbumSongNameDesc
+`;
+
+    it('should match display names, scan targets, and metadata values case-insensitively', () => {
+      const response = callWasm('searchPlan', { input, query: 'songs' });
+
+      expect(response.success).toBe(true);
+      const result: SearchResult = JSON.parse(response.result ?? '{}');
+      expect(result).toEqual({
+        matches: [
+          { nodeId: 0, field: 'metadata.distribution_table', value: 'Songs' },
+          { nodeId: 1, field: 'scanTarget', value: 'SongsBySingerAlbumSongNameDesc' }
+        ],
+        nodeIds: [0, 1]
+      });
+    });
+
+    it('should match regular expressions', () => {
+      const result: SearchResult = JSON.parse(callWasm('searchPlan', { input, query: '^(scan|index)', regex: true }).result ?? '{}');
+
+      expect(result.matches).toEqual([
+        { nodeId: 1, field: 'displayName', value: 'Scan' },
+        { nodeId: 1, field: 'metadata.scan_type', value: 'IndexScan' }
+      ]);
+      expect(result.nodeIds).toEqual([1]);
+    });
+
+    it('should return no matches for absent text', () => {
+      const result: SearchResult = JSON.parse(callWasm('searchPlan', { input, query: 'Singers Table' }).result ?? '{}');
+
+      expect(result).toEqual({ matches: [], nodeIds: [] });
+    });
+
+    it('should reject empty queries and invalid patterns', () => {
+      expect(callWasm('searchPlan', { input, query: '' }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('searchPlan', { input, query: '(', regex: true }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('analyzeJoins', () => {
+    const hashJoinInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Hash Join"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+            type: Build
+          - childIndex: 2
+            type: Probe
+        executionStats:
+          rows: { total: "30", unit: "rows" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata: { scan_type: TableScan, scan_target: Singers }
+        executionStats:
+          rows: { total: "10", unit: "rows" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+        metadata: { scan_type: TableScan, scan_target: Albums }
+        executionStats:
+          rows: { total: "120", unit: "rows" }
+`;
+
+    it('should report the sides of a hash join and the selectivity over the probe side', () => {
+      const response = callWasm('analyzeJoins', { input: hashJoinInput });
+
+      expect(response.success).toBe(true);
+      const report: JoinReport = JSON.parse(response.result ?? '{}');
+      expect(report).toEqual({
+        joins: [{
+          nodeId: 0,
+          operator: 'Hash Join',
+          type: 'hash',
+          build: { role: 'Build', nodeId: 1, operator: 'Table Scan (Table: Singers)', rows: 10 },
+          probe: { role: 'Probe', nodeId: 2, operator: 'Table Scan (Table: Albums)', rows: 120 },
+          outputRows: 30,
+          selectivity: 0.25
+        }]
+      });
+    });
+
+    it('should treat the map side of an apply as its probe side and render a table', () => {
+      const input = callWasm('getSample', { name: 'distributed-join' }).result ?? '';
+      const response = callWasm('analyzeJoins', { input, render: true });
+
+      expect(response.success).toBe(true);
+      const report: JoinReport = JSON.parse(response.result ?? '{}');
+      expect(report.joins.map(j => [j.nodeId, j.type, j.build?.role, j.probe?.role, j.selectivity])).toEqual([
+        [2, 'apply', 'Input', 'Map', 3],
+        [13, 'apply', 'Input', 'Map', 3]
+      ]);
+      const lines = (report.text ?? '').split('\n');
+      expect(lines[0]).toBe('Joins: 2');
+      expect(lines[1]).toMatch(/^\s+ID\s+Type\s+Build\s+Probe\s+Output\s+Selectivity\s+Operator$/);
+      expect(lines[2]).toMatch(/^\s+2\s+apply\s+3 \(20 rows\)\s+12 \(60 rows\)\s+60\s+3\s+Distributed Cross Apply/);
+    });
+
+    it('should return an empty report for plans without joins', () => {
+      const input = callWasm('getSample', { name: 'simple-scan' }).result ?? '';
+
+      expect(JSON.parse(callWasm('analyzeJoins', { input, render: true }).result ?? '{}')).toEqual({ joins: [] });
+    });
+  });
+
+  describe('analyzeCriticalPath', () => {
+    it('should follow the slowest input with each hop\'s contribution', () => {
+      const input = callWasm('getSample', { name: 'distributed-join' }).result ?? '';
+
+      const response = callWasm('analyzeCriticalPath', { input, render: true });
+
+      expect(response.success).toBe(true);
+      const path: CriticalPath = JSON.parse(response.result ?? '{}');
+      expect(path.hops.map(h => h.nodeId)).toEqual([0, 1, 2, 12, 13, 17, 18]);
+      expect(path.latencyMillis).toBe(18.4);
+      const total = path.hops.reduce((sum, h) => sum + h.contributionMillis, 0);
+      expect(total).toBeCloseTo(path.latencyMillis);
+      expect(path.hops.at(-1)?.share).toBeCloseTo(0.75);
+      expect(path.text).toContain('Critical path: 18.4 msecs');
+      expect(path.text).toMatch(/^\s+18\s+13\.8 msecs\s+13\.8 msecs\s+75\.0%\s+Table Scan/m);
+    });
+
+    it('should return an empty path with a warning without latency stats', () => {
+      const response = callWasm('analyzeCriticalPath', { input: scalarAppendixInput, render: true });
+
+      expect(response.success).toBe(true);
+      expect(JSON.parse(response.result ?? '{}')).toEqual({ hops: [], latencyMillis: 0 });
+      expect(response.warnings?.map(w => w.code)).toContain('NO_LATENCY_STATS');
+    });
+  });
+
+  describe('parsePlan', () => {
+    it('should return nodes with resolved links, metadata, and stats', () => {
+      const response = callWasm('parsePlan', { input: scalarAppendixInput });
+
+      expect(response.success).toBe(true);
+      const plan: ParsedPlan = JSON.parse(response.result ?? '{}');
+      expect(plan.nodes).toHaveLength(10);
+      expect(plan.nodes[0]).toMatchObject({ id: 0, kind: 'RELATIONAL', displayName: 'Sort', title: 'Sort', depth: 0 });
+      expect(plan.nodes[0]?.children).toEqual([
+        { childId: 1, type: 'Key', variable: 'sort_count' },
+        { childId: 2, type: 'Key', variable: 'sort_genre' },
+        { childId: 3 }
+      ]);
+      expect(plan.nodes[5]).toMatchObject({ kind: 'SCALAR', description: 'COUNT_FINAL($v1)', parentId: 3, depth: 2 });
+      expect(response.metadata?.counts.totalNodes).toBe(10);
+    });
+
+    it('should map variable references to their defining nodes', () => {
+      const input = callWasm('getSample', { name: 'distributed-join' }).result ?? '';
+      const plan: ParsedPlan = JSON.parse(callWasm('parsePlan', { input }).result ?? '{}');
+
+      expect(plan.variables).toContainEqual({ variable: 'SingerId_1', nodeId: 19, operatorId: 18 });
+      expect(plan.variables).toContainEqual({ variable: 'v2', nodeId: 3, operatorId: 3 });
+      expect(plan.nodes[14]?.references).toEqual([{ reference: '$v2', variable: 'v2', definedBy: 3 }]);
+      expect(plan.nodes[22]?.references?.map(r => [r.reference, r.definedBy])).toEqual([['$SingerId_1', 19], ['$batched_SingerId', 15]]);
+    });
+  });
+
+  describe('variableLinks', () => {
+    const sample = () => callWasm('getSample', { name: 'distributed-join' }).result ?? '';
+
+    it('should list the variables of each operator with their defining nodes', () => {
+      const response = callWasm('renderASCII', { input: sample(), mode: 'PLAN', format: 'CURRENT', variableLinks: true });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain([
+        'Variables(identified by ID):',
+        '  1: $batched_FirstName ← node 16',
+        '     $AlbumTitle ← node 21',
+        '  3: $v1 ← node 5',
+      ].join('\n'));
+      expect(response.result).toMatch(/^ 14: \$v2 ← node 3$/m);
+    });
+
+    it('should use ASCII arrows and keep variables of full representations', () => {
+      const response = callWasm('renderASCII', {
+        input: sample(), mode: 'PLAN', format: 'CURRENT', variableLinks: true, charset: 'ascii', scalarRepresentation: 'full',
+      });
+
+      expect(response.result).toMatch(/^ 17: \$SingerId_1 <- node 19$/m);
+    });
+  });
+
+  describe('DOT format', () => {
+    it('should render the same DOT source as renderDOT', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'dot', wrapWidth: 0 });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('digraph {');
+      expect(response.result).toBe(callWasm('renderDOT', { input: scalarAppendixInput }).result);
+      expect(response.metadata?.counts.relationalNodes).toBe(3);
+    });
+
+    it('should reject rootNodeId', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'DOT', rootNodeId: 3 });
+
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+      expect(response.error?.message).toContain('rootNodeId is not supported by the DOT format');
+    });
+
+    it('should not be overridable with registerFormatter', () => {
+      const fn = (globalThis as Record<string, unknown>).registerFormatter as (name: string, callback: FormatterCallback) => string;
+      const response: WasmResponse = JSON.parse(fn('DOT', () => ''));
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('Mermaid format', () => {
+    it('should render the same flowchart as renderMermaid', () => {
+      const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        executionStats:
+          rows: { total: "3", unit: "rows" }
+          latency: { total: "1.5", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata: { scan_type: TableScan, scan_target: Singers }
+        executionStats:
+          rows: { total: "3", unit: "rows" }
+`;
+      const plan = callWasm('renderASCII', { input, mode: 'PLAN', format: 'MERMAID', wrapWidth: 0 });
+      const profile = callWasm('renderASCII', { input, mode: 'PROFILE', format: 'MERMAID', wrapWidth: 0 });
+
+      expect(plan.success).toBe(true);
+      expect(plan.result).toContain('graph TD');
+      expect(plan.result).toBe(callWasm('renderMermaid', { input }).result);
+      expect(profile.result).toBe(callWasm('renderMermaid', { input, executionStats: true }).result);
+    });
+
+    it('should render a PlantUML WBS with a level per depth', () => {
+      const input = `
+queryPlan:
+  planNodes:
+    - displayName: "Distributed Union"
+      kind: RELATIONAL
+      index: 0
+      childLinks:
+        - childIndex: 1
+        - childIndex: 2
+      executionStats:
+        rows: { total: "5", unit: "rows" }
+        latency: { total: "2.5", unit: "msecs" }
+    - displayName: "Scan"
+      kind: RELATIONAL
+      index: 1
+      metadata: { scan_type: TableScan, scan_target: Singers }
+      executionStats:
+        rows: { total: "3", unit: "rows" }
+    - displayName: "Scan"
+      kind: RELATIONAL
+      index: 2
+      metadata: { scan_type: TableScan, scan_target: Albums }
+`;
+      const response = callWasm('renderASCII', { input, mode: 'PROFILE', format: 'PLANTUML', wrapWidth: 0 });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toBe([
+        '@startwbs',
+        '* 0: Distributed Union\\nrows: 5\\nlatency: 2.5 msecs',
+        '** 1: Table Scan (Table: Singers)\\nrows: 3',
+        '** 2: Table Scan (Table: Albums)',
+        '@endwbs',
+        '',
+      ].join('\n'));
+    });
+
+    it('should escape Creole markup in PlantUML labels', () => {
+      const input = JSON.stringify({
+        queryPlan: { planNodes: [{ displayName: 'Scan', kind: 'RELATIONAL', index: 0, metadata: { scan_type: 'TableScan', scan_target: '__Singers--<b>' } }] }
+      });
+      const response = callWasm('renderASCII', { input, mode: 'PLAN', format: 'PLANTUML', wrapWidth: 0 });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('~__Singers~--~<b>');
+    });
+  });
+
+  describe('renderBatch', () => {
+    const scanPlan = (table: string) => JSON.stringify({
+      stats: { queryPlan: { planNodes: [{ displayName: 'Scan', kind: 'RELATIONAL', index: 0, metadata: { scan_type: 'TableScan', scan_target: table } }] } }
+    });
+
+    it('should render each input and report failures individually', () => {
+      const response = callWasm('renderBatch', { inputs: [scanPlan('Singers'), 'not a plan', scanPlan('Albums')], mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.success).toBe(true);
+      const results: WasmResponse[] = JSON.parse(response.result ?? '[]');
+      expect(results.map(r => r.success)).toEqual([true, false, true]);
+      expect(results[0]?.result).toContain('Singers');
+      expect(results[1]?.error?.type).toBeDefined();
+      expect(results[2]?.result).toContain('Albums');
+    });
+
+    it('should split concatenated JSON documents', () => {
+      const response = callWasm('renderBatch', { input: `${scanPlan('Singers')}\n${scanPlan('Albums')}\n{"broken":`, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 });
+
+      const results: WasmResponse[] = JSON.parse(response.result ?? '[]');
+      expect(results.map(r => r.success)).toEqual([true, true, false]);
+    });
+
+    it('should require inputs', () => {
+      expect(callWasm('renderBatch', { mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('renderRange', () => {
+    const params = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+    const rowLine = (text: string, id: number) => text.split('\n').find(line => new RegExp(`^\\|\\s*\\*?${id}\\s*\\|`).test(line));
+
+    it('should page operator rows and end with the appendices', () => {
+      const full = callWasm('renderASCII', params).result ?? '';
+      const first: RenderRangeResult = JSON.parse(callWasm('renderRange', { ...params, limit: 2 }).result ?? '{}');
+      const last: RenderRangeResult = JSON.parse(callWasm('renderRange', { ...params, offset: 2 }).result ?? '{}');
+
+      expect([first.totalRows, first.offset, first.rows]).toEqual([3, 0, 2]);
+      expect(rowLine(first.text, 6)).toBeUndefined();
+      expect(first.text.trimEnd().split('\n').pop()).toMatch(/^\+[-+]+\+$/);
+      expect([last.offset, last.rows]).toEqual([2, 1]);
+      expect(rowLine(last.text, 0)).toBeUndefined();
+      expect(full.endsWith(last.text.slice(last.text.indexOf(rowLine(last.text, 6) ?? '')))).toBe(true);
+    });
+
+    it('should repeat the ancestors of a page starting mid-subtree', () => {
+      const full = callWasm('renderASCII', params).result ?? '';
+      const page: RenderRangeResult = JSON.parse(callWasm('renderRange', { ...params, offset: 2, limit: 1, stickyPrefix: true }).result ?? '{}');
+
+      expect(page.rows).toBe(1);
+      expect(rowLine(page.text, 0)).toBe(rowLine(full, 0));
+      expect(rowLine(page.text, 3)).toBe(rowLine(full, 3));
+      expect(page.text.indexOf(rowLine(full, 3) ?? '')).toBeLessThan(page.text.indexOf(rowLine(full, 6) ?? ''));
+    });
+
+    it('should reject negative ranges and diagram formats', () => {
+      expect(callWasm('renderRange', { ...params, offset: -1 }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('renderRange', { ...params, format: 'DOT' }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('renderRange', { ...params, format: 'JSON-NORMALIZED' }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('cyclic child links', () => {
+    const cyclicInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - { displayName: "Distributed Union", kind: RELATIONAL, index: 0, childLinks: [{ childIndex: 1 }] }
+      - { displayName: "Filter", kind: RELATIONAL, index: 1, childLinks: [{ childIndex: 0 }] }
+`;
+
+    it('should reject links back to an ancestor with their path', () => {
+      const response = callWasm('renderASCII', { input: cyclicInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_SPANNER_FORMAT');
+      expect(response.error?.details).toBe('stats.queryPlan.planNodes[1].childLinks[0].childIndex');
+    });
+
+    it('should drop them in recover mode', () => {
+      const response = callWasm('renderASCII', { input: cyclicInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, recover: true });
+
+      expect(response.success).toBe(true);
+      expect(response.warnings?.map(w => w.code)).toEqual(['DROPPED_CHILD_LINK']);
+    });
+  });
+
+  describe('input limits', () => {
+    it('should reject input over maxBytes with its size and the limit', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, inputLimits: { maxBytes: 100 } });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INPUT_TOO_LARGE');
+      expect(response.error?.details).toBe('inputLimits.maxBytes');
+      expect(response.error?.size).toBe(new TextEncoder().encode(scalarAppendixInput).length);
+      expect(response.error?.limit).toBe(100);
+    });
+
+    it('should reject plans over maxNodes', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, inputLimits: { maxNodes: 1 } });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INPUT_TOO_LARGE');
+      expect(response.error?.details).toBe('inputLimits.maxNodes');
+      expect(response.error?.size).toBeGreaterThan(1);
+      expect(response.error?.message).toContain('rootNodeId');
+    });
+
+    it('should render input within the default limits', () => {
+      expect(callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 }).success).toBe(true);
+    });
+  });
+
+  describe('error hints', () => {
+    it('should suggest PROFILE for query results without a plan', () => {
+      const input = JSON.stringify({ metadata: { rowType: { fields: [{ name: 'x', type: { code: 'INT64' } }] } }, rows: [['1']] });
+      const response = callWasm('renderASCII', { input, mode: 'AUTO', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.hints?.map(h => h.code)).toEqual(['CAPTURE_PROFILE']);
+      expect(response.error?.hints?.[0]?.message).toContain('--query-mode=PROFILE');
+    });
+
+    it('should suggest the casing of the detected source', () => {
+      const input = JSON.stringify({ query_plan: { PlanNodes: [{ index: 0, kind: 'RELATIONAL', display_name: 'Scan' }] } });
+      const response = callWasm('validateInput', { input });
+
+      expect(response.error?.type).toBe('INVALID_SPANNER_FORMAT');
+      const hint = response.error?.hints?.find(h => h.code === 'FIELD_CASING');
+      expect(hint?.message).toContain('gRPC');
+      expect(hint?.message).toContain('PlanNodes to plan_nodes');
+    });
+
+    it('should point at tabs in YAML indentation', () => {
+      const input = 'queryPlan:\n  planNodes:\n  - index: 0\n\tkind: RELATIONAL\n';
+      const response = callWasm('parsePlan', { input });
+
+      expect(response.error?.type).toBe('PARSE_ERROR');
+      expect(response.error?.hints).toEqual([{ code: 'TAB_INDENTATION', message: expect.stringContaining('Line 4') }]);
+    });
+
+    it('should suggest raising the exceeded input limit', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, inputLimits: { maxNodes: 1 } });
+
+      expect(response.error?.hints?.map(h => h.code)).toEqual(['RAISE_INPUT_LIMIT']);
+      expect(response.error?.hints?.[0]?.message).toContain('inputLimits.maxNodes');
+    });
+
+    it('should have no hints for well-formed plans with structural problems', () => {
+      const response = callWasm('renderASCII', { input: '{"queryPlan":{"planNodes":[]}}', mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.hints).toBeUndefined();
+    });
+  });
+
+  describe('lenient rendering', () => {
+    // Node 1 has execution stats the library cannot read
+    const brokenStatsInput = JSON.stringify({
+      queryPlan: {
+        planNodes: [
+          { index: 0, kind: 'RELATIONAL', displayName: 'Distributed Union', childLinks: [{ childIndex: 1 }, { childIndex: 2 }] },
+          { index: 1, kind: 'RELATIONAL', displayName: 'Broken Scan', executionStats: { latency: 5 } },
+          { index: 2, kind: 'RELATIONAL', displayName: 'Table Scan', metadata: { scan_target: 'Singers' } },
+        ],
+      },
+    });
+
+    it('should fail without lenient', () => {
+      const response = callWasm('renderASCII', { input: brokenStatsInput, mode: 'PROFILE', format: 'CURRENT', wrapWidth: 0 });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('RENDER_ERROR');
+    });
+
+    it('should render broken nodes as placeholder rows with warnings', () => {
+      const response = callWasm('renderASCII', { input: brokenStatsInput, mode: 'PROFILE', format: 'CURRENT', wrapWidth: 0, lenient: true });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('Table Scan on Singers');
+      expect(response.result).toMatch(/ 1 \| \+- <render error: json: cannot unmarshal number/);
+      expect(response.warnings?.map(w => [w.code, w.nodeId])).toEqual([['NODE_RENDER_ERROR', 1]]);
+    });
+  });
+
+  describe('selfTest', () => {
+    it('should render every fixture through every format without failures', () => {
+      const response = callWasm('selfTest', {});
+
+      expect(response.success).toBe(true);
+      const report: SelfTestReport = JSON.parse(response.result ?? '{}');
+      expect(report.failures).toEqual([]);
+      expect(report.cases).toBeGreaterThanOrEqual(4 * 5);
+    });
+
+    it('should run only the requested fixtures', () => {
+      const report: SelfTestReport = JSON.parse(callWasm('selfTest', { fixtures: ['cyclic-links'] }).result ?? '{}');
+
+      expect(report.cases).toBe(6);
+    });
+
+    it('should reject unknown fixtures', () => {
+      expect(callWasm('selfTest', { fixtures: ['nope'] }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('diffPlans', () => {
+    const plan = (scan: string, rows: number, latency: number, filter: boolean) => `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        executionStats:
+          rows: { total: "${rows}", unit: "rows" }
+          latency: { total: "${latency}", unit: "msecs" }
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1${filter ? `
+        childLinks:
+          - childIndex: 2` : ''}
+        metadata: ${scan}
+        executionStats:
+          rows: { total: "${rows}", unit: "rows" }${filter ? `
+      - displayName: "Filter"
+        kind: RELATIONAL
+        index: 2` : ''}
+`;
+    const before = plan('{ scan_type: TableScan, scan_target: Singers }', 1000, 20, true);
+    const after = plan('{ scan_type: IndexScan, scan_target: SingersByName }', 10, 2, false);
+
+    it('should mark changed and removed operators with stat deltas', () => {
+      const response = callWasm('diffPlans', { before, after });
+
+      expect(response.success).toBe(true);
+      const lines = (response.result ?? '').split('\n');
+      expect(lines[0]).toBe('  Distributed Union (0 → 0)  rows: 1000 → 10 (-99%), latency: 20 msecs → 2 msecs (-90%)');
+      expect(lines[1]).toMatch(/^~ {3}Table Scan .* → Index Scan .* \(1 → 1\) {2}rows: 1000 → 10 \(-99%\)$/);
+      expect(lines[2]).toBe('-     Filter (2 → -)');
+      expect(lines).toContain('0 added, 1 removed, 1 changed, 1 unchanged');
+    });
+
+    it('should report which plan failed to parse', () => {
+      const response = callWasm('diffPlans', { before, after: 'not a plan' });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.message).toMatch(/^after plan: /);
+    });
+  });
+
+  describe('diffPlanStructure', () => {
+    const plan = (nodes: string[]) => `
+stats:
+  queryPlan:
+    planNodes:
+${nodes.map((node, i) => `      - { index: ${i}, kind: RELATIONAL, ${node} }`).join('\n')}
+`;
+    const structure = (before: string, after: string): StructureDiff => {
+      const response = callWasm('diffPlanStructure', { before, after });
+      expect(response.success).toBe(true);
+      return JSON.parse(response.result ?? '{}');
+    };
+
+    it('should report changed operators and scan targets with stat deltas', () => {
+      const diff = structure(
+        plan([
+          'displayName: "Distributed Union", childLinks: [{ childIndex: 1 }], executionStats: { rows: { total: "1000", unit: "rows" } }',
+          'displayName: "Scan", metadata: { scan_type: TableScan, scan_target: Singers }',
+        ]),
+        plan([
+          'displayName: "Distributed Union", childLinks: [{ childIndex: 1 }], executionStats: { rows: { total: "10", unit: "rows" } }',
+          'displayName: "Scan", metadata: { scan_type: IndexScan, scan_target: SingersByName }',
+        ]),
+      );
+
+      expect(diff.edits[0]).toEqual({ kind: 'unchanged', beforeId: 0, afterId: 0, operator: 'Distributed Union', stats: [{ name: 'rows', before: 1000, after: 10, delta: -990 }] });
+      expect(diff.edits[1]).toMatchObject({
+        kind: 'changed',
+        operator: 'Index Scan',
+        beforeOperator: 'Table Scan',
+        scanTarget: 'SingersByName',
+        beforeScanTarget: 'Singers',
+        operatorChanged: true,
+        scanTargetChanged: true,
+      });
+      expect(diff.counts).toEqual({ unchanged: 1, changed: 1, added: 0, removed: 0, moved: 0 });
+    });
+
+    it('should pair operators that moved in the tree', () => {
+      const diff = structure(
+        plan([
+          'displayName: "Distributed Union", childLinks: [{ childIndex: 1 }]',
+          'displayName: "Filter", childLinks: [{ childIndex: 2 }]',
+          'displayName: "Scan", metadata: { scan_type: TableScan, scan_target: Singers }',
+        ]),
+        plan([
+          'displayName: "Distributed Union", childLinks: [{ childIndex: 1 }]',
+          'displayName: "Scan", metadata: { scan_type: IndexScan, scan_target: SingersByName }, childLinks: [{ childIndex: 2 }]',
+          'displayName: "Filter"',
+        ]),
+      );
+
+      expect(diff.edits.find((e) => e.operator === 'Filter')).toEqual({ kind: 'moved', beforeId: 1, afterId: 2, beforeParentId: 0, afterParentId: 1, operator: 'Filter' });
+      expect(diff.counts).toEqual({ unchanged: 1, changed: 0, added: 1, removed: 1, moved: 1 });
+    });
+  });
+
+  describe('plan session', () => {
+    it('should restore plans, handles, and presets from an exported blob', () => {
+      const loaded: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput, options: { mode: 'PLAN', format: 'COMPACT' } }).result ?? '{}');
+      expect(loaded.id).toMatch(/^plan-\d+$/);
+      expect(loaded.fingerprint.plan).toMatch(/^[0-9a-f]{16}$/);
+      callWasm('savePreset', { name: 'session-preset', options: { wrapWidth: 80 } });
+
+      const blob = callWasm('exportSession', {}).result ?? '';
+      callWasm('releasePlan', { id: loaded.id });
+      callWasm('savePreset', { name: 'session-preset', options: null });
+
+      const restored = callWasm('importSession', { blob });
+      expect(restored.success).toBe(true);
+      const plans: SessionPlanInfo[] = JSON.parse(restored.result ?? '[]');
+      expect(plans).toContainEqual(loaded);
+      expect(JSON.parse(callWasm('applyPreset', { name: 'session-preset' }).result ?? '{}')).toEqual({ wrapWidth: 80 });
+
+      const next: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput }).result ?? '{}');
+      expect(next.id).not.toBe(loaded.id);
+      callWasm('releasePlan', { id: loaded.id });
+      callWasm('releasePlan', { id: next.id });
+      callWasm('savePreset', { name: 'session-preset', options: null });
+    });
+
+    it('should reject options with input and unknown handles and blobs', () => {
+      expect(callWasm('loadPlan', { input: scalarAppendixInput, options: { input: 'x' } }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('releasePlan', { id: 'plan-0' }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('importSession', { blob: 'not a blob' }).error?.type).toBe('PARSE_ERROR');
+    });
+
+    it('should render loaded plans with kept and given options', () => {
+      const loaded: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput, options: { mode: 'PLAN', format: 'COMPACT' } }).result ?? '{}');
+
+      const rendered = callWasm('renderPlan', { id: loaded.id });
+      expect(rendered.success).toBe(true);
+      expect(rendered.result).toBe(callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'COMPACT' }).result);
+      const wrapped = callWasm('renderPlan', { id: loaded.id, options: { format: 'CURRENT', wrapWidth: 20 } });
+      expect(wrapped.result).toBe(callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 20 }).result);
+
+      expect(callWasm('renderPlan', { id: loaded.id, options: { input: 'x' } }).error?.type).toBe('INVALID_PARAMETERS');
+      callWasm('releasePlan', { id: loaded.id });
+      expect(callWasm('renderPlan', { id: loaded.id }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+
+    it('should render the same output when switching formats of a loaded plan', () => {
+      const loaded: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput, options: { mode: 'PLAN' } }).result ?? '{}');
+
+      const formats = ['CURRENT', 'HTML', 'CURRENT', 'TRADITIONAL', 'HTML'] as const;
+      for (const format of formats) {
+        const rendered = callWasm('renderPlan', { id: loaded.id, options: { format, latencyBars: true } });
+        expect(rendered.success).toBe(true);
+        expect(rendered.result).toBe(callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format, latencyBars: true }).result);
+      }
+      const wrapped = callWasm('renderPlan', { id: loaded.id, options: { format: 'CURRENT', wrapWidth: 20 } });
+      expect(wrapped.result).toBe(callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 20 }).result);
+
+      callWasm('freeMemory', {});
+      const afterFree = callWasm('renderPlan', { id: loaded.id, options: { format: 'HTML' } });
+      expect(afterFree.result).toBe(callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'HTML' }).result);
+      callWasm('releasePlan', { id: loaded.id });
+    });
+
+    it('should keep labels in exports and head diffs of handles with them', () => {
+      const before: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput, labels: ['before index', ' ', 'before index'] }).result ?? '{}');
+      expect(before.labels).toEqual(['before index']);
+      const after: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput }).result ?? '{}');
+      const labeled: SessionPlanInfo = JSON.parse(callWasm('labelPlan', { id: after.id, labels: ['after index', 'prod 2024-06'] }).result ?? '{}');
+      expect(labeled.labels).toEqual(['after index', 'prod 2024-06']);
+
+      const diff = callWasm('diffPlans', { beforeId: before.id, afterId: after.id });
+      expect(diff.success).toBe(true);
+      expect(diff.result?.split('\n').slice(0, 2)).toEqual([`--- ${before.id} [before index]`, `+++ ${after.id} [after index, prod 2024-06]`]);
+      expect(callWasm('diffPlans', { before: scalarAppendixInput, beforeId: before.id, afterId: after.id }).error?.type).toBe('INVALID_PARAMETERS');
+
+      const blob = callWasm('exportSession', {}).result ?? '';
+      const plans: SessionPlanInfo[] = JSON.parse(callWasm('importSession', { blob }).result ?? '[]');
+      expect(plans).toContainEqual(labeled);
+      callWasm('releasePlan', { id: before.id });
+      callWasm('releasePlan', { id: after.id });
+    });
+
+    it('should merge the plans of a blob under new handles', () => {
+      const shared: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput, options: { mode: 'PLAN', format: 'CURRENT' }, labels: ['shared'] }).result ?? '{}');
+      callWasm('savePreset', { name: 'merged-preset', options: { wrapWidth: 60 } });
+      const blob = callWasm('exportSession', {}).result ?? '';
+      const own: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput, labels: ['own'] }).result ?? '{}');
+      callWasm('savePreset', { name: 'merged-preset', options: null });
+
+      const merged = callWasm('importSession', { blob, merge: true });
+      expect(merged.success).toBe(true);
+      const plans: SessionPlanInfo[] = JSON.parse(merged.result ?? '[]');
+      expect(plans).toContainEqual(shared);
+      expect(plans).toContainEqual(own);
+      const copy = plans.find((p) => p.labels?.[0] === 'shared' && p.id !== shared.id);
+      expect(copy).toBeDefined();
+      expect(Number(copy?.id.slice('plan-'.length))).toBeGreaterThan(Number(own.id.slice('plan-'.length)));
+      const rendered = callWasm('renderPlan', { id: copy?.id ?? '' });
+      expect(rendered.success).toBe(true);
+      expect(rendered.result).toBe(callWasm('renderPlan', { id: shared.id }).result);
+      expect(JSON.parse(callWasm('applyPreset', { name: 'merged-preset' }).result ?? '{}')).toEqual({ wrapWidth: 60 });
+
+      for (const p of plans) {
+        callWasm('releasePlan', { id: p.id });
+      }
+      callWasm('savePreset', { name: 'merged-preset', options: null });
+    });
+
+    it('should reject blobs with a plan that does not parse and keep the session', () => {
+      const loaded: SessionPlanInfo = JSON.parse(callWasm('loadPlan', { input: scalarAppendixInput, options: { mode: 'PLAN', format: 'CURRENT' } }).result ?? '{}');
+      const corrupt = gzipSync(JSON.stringify({ version: 1, plans: { 'plan-1': { input: 'not a plan', fingerprint: loaded.fingerprint } } })).toString('base64');
+
+      const response = callWasm('importSession', { blob: corrupt });
+      expect(response.error?.type).toBe('PARSE_ERROR');
+      expect(response.error?.message).toContain('"plan-1"');
+      expect(callWasm('renderPlan', { id: loaded.id }).success).toBe(true);
+      callWasm('releasePlan', { id: loaded.id });
+    });
+  });
+
+  describe('object parameters', () => {
+    const renderObject = (params: Record<string, unknown>): WasmResponse =>
+      ((globalThis as Record<string, unknown>).renderASCII as (params: Record<string, unknown>) => WasmResponse)(params);
+
+    it('should return the same response as the JSON string form', () => {
+      const params: RenderParams = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT' };
+      const response = renderObject({ ...params });
+
+      expect(typeof response).toBe('object');
+      expect(response.success).toBe(true);
+      expect(response.result).toBe(callWasm('renderASCII', params).result);
+    });
+
+    it('should read the input from a Uint8Array', () => {
+      const params = { mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+      const fromString = renderObject({ ...params, input: scalarAppendixInput });
+      const fromBytes = renderObject({ ...params, input: new TextEncoder().encode(scalarAppendixInput) });
+      const gzipped = renderObject({ ...params, input: new Uint8Array(gzipSync(scalarAppendixInput)) });
+
+      expect(fromBytes.success).toBe(true);
+      expect(fromBytes.result).toBe(fromString.result);
+      expect(gzipped.result).toBe(fromString.result);
+    });
+
+    it('should reject a non-string input', () => {
+      const response = renderObject({ input: 42, mode: 'PLAN', format: 'CURRENT' });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('render jobs', () => {
+    const renderAsync = (params: RenderParams): RenderJob =>
+      ((globalThis as Record<string, unknown>).renderAsync as (paramsJson: string) => RenderJob)(JSON.stringify(params));
+    const params: RenderParams = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+
+    it('should resolve with the renderASCII response', async () => {
+      const job = renderAsync(params);
+
+      const response = JSON.parse(await job.response) as WasmResponse;
+      expect(response.result).toBe(callWasm('renderASCII', params).result);
+      expect(callWasm('cancelRender', { jobId: job.jobId }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+
+    it('should warn about unknown options', async () => {
+      const response = JSON.parse(await renderAsync({ ...params, wrapwidth: 40 } as RenderParams).response) as WasmResponse;
+
+      expect(response.success).toBe(true);
+      expect(response.warnings?.map(w => [w.code, w.path])).toEqual([['UNKNOWN_OPTION', 'wrapwidth']]);
+    });
+
+    it('should stop cancelled jobs with a CANCELLED error', async () => {
+      const job = renderAsync(params);
+
+      expect(callWasm('cancelRender', { jobId: job.jobId }).success).toBe(true);
+      const response = JSON.parse(await job.response) as WasmResponse;
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('CANCELLED');
+    });
+
+    it('should run overlapping jobs one at a time in call order', async () => {
+      const finished: number[] = [];
+      const jobs = [0, 1, 2, 3].map(i =>
+        renderAsync({ ...params, wrapWidth: 40 + i }).response.then(response => {
+          finished.push(i);
+          return JSON.parse(response) as WasmResponse;
+        }));
+
+      const responses = await Promise.all(jobs);
+      expect(finished).toEqual([0, 1, 2, 3]);
+      expect(responses.every(r => r.success)).toBe(true);
+    });
+
+    it('should resolve with QUEUE_FULL when too many jobs are waiting', async () => {
+      const jobs = Array.from({ length: 40 }, () => renderAsync(params).response);
+
+      const responses = (await Promise.all(jobs)).map(r => JSON.parse(r) as WasmResponse);
+      const rejected = responses.filter(r => !r.success);
+      expect(rejected.length).toBeGreaterThan(0);
+      expect(rejected.every(r => r.error?.type === 'QUEUE_FULL')).toBe(true);
+      expect(responses.filter(r => r.success).length).toBeGreaterThanOrEqual(32);
+      expect(JSON.parse(await renderAsync(params).response).success).toBe(true);
+    });
+  });
+
+  describe('memory management', () => {
+    const memoryCall = (name: 'getMemoryStats' | 'freeMemory'): MemoryStats => {
+      const fn = (globalThis as Record<string, unknown>)[name] as () => string;
+      const response = JSON.parse(fn()) as WasmResponse;
+      expect(response.success).toBe(true);
+      return JSON.parse(response.result ?? '{}') as MemoryStats;
+    };
+
+    it('should report the memory use of the Go runtime', () => {
+      callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT' });
+
+      const stats = memoryCall('getMemoryStats');
+      expect(stats.heapAllocBytes).toBeGreaterThan(0);
+      expect(stats.sysBytes).toBeGreaterThanOrEqual(stats.heapInUseBytes);
+      expect(stats.parseCacheEntries).toBeGreaterThan(0);
+    });
+
+    it('should clear the parse cache and collect garbage', () => {
+      callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT' });
+      const before = memoryCall('getMemoryStats');
+
+      const after = memoryCall('freeMemory');
+      expect(after.parseCacheEntries).toBe(0);
+      expect(after.gcCycles).toBeGreaterThan(before.gcCycles);
+    });
+  });
+
+  describe('async functions', () => {
+    const asyncFn = (name: string) =>
+      (globalThis as Record<string, unknown>)[`${name}Async`] as (params: unknown) => Promise<unknown>;
+
+    it('should resolve with the same response as the synchronous function', async () => {
+      const params = JSON.stringify({ input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 });
+
+      await expect(asyncFn('renderASCII')(params)).resolves.toBe(renderASCII(params));
+      const diagram = JSON.stringify({ input: scalarAppendixInput });
+      await expect(asyncFn('renderMermaid')(diagram)).resolves.toBe(renderMermaid(diagram));
+    });
+
+    it('should reject panics with RENDER_ERROR and keep the module usable', async () => {
+      const params = { mode: 'PLAN', format: 'CURRENT', get input(): string { throw new Error('boom'); } };
+
+      await expect(asyncFn('renderASCII')(params)).rejects.toMatchObject({ type: 'RENDER_ERROR', message: expect.stringContaining('boom') });
+      expect(callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 }).success).toBe(true);
+    });
+
+    it('should only add variants of exports that do not return Promises', () => {
+      const exports = globalThis as Record<string, unknown>;
+
+      expect(typeof exports.renderASCIIAsync).toBe('function');
+      expect(typeof exports.cancelRenderAsync).toBe('function');
+      expect(exports.renderAsyncAsync).toBeUndefined();
+    });
+
+    it('should reject synchronous calls that change state with BUSY while a call is running', async () => {
+      const register = (globalThis as Record<string, unknown>).registerFormatter as (name: string, callback: FormatterCallback | null) => string;
+      const params = { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 };
+      const preset = { name: 'busy', options: { format: 'COMPACT' } };
+      const reads: WasmResponse[] = [];
+      const writes: WasmResponse[] = [];
+      // The formatter runs inside the renderASCIIAsync job
+      register('busy', () => {
+        reads.push(callWasm('renderASCII', params), callWasm('validateInput', { input: scalarAppendixInput }));
+        writes.push(callWasm('savePreset', preset));
+        return '';
+      });
+
+      const response = await asyncFn('renderASCII')(JSON.stringify({ ...params, format: 'busy' }));
+      register('busy', null);
+
+      expect(JSON.parse(response as string).success).toBe(true);
+      expect(reads.map(r => r.success)).toEqual([true, true]);
+      expect(writes.map(r => r.error?.type)).toEqual(['BUSY']);
+      expect(callWasm('savePreset', preset).success).toBe(true);
+      callWasm('savePreset', { name: preset.name, options: null });
+    });
+
+    it('should return synchronous panics as RENDER_ERROR responses', () => {
+      const params = { mode: 'PLAN', format: 'CURRENT', get input(): string { throw new Error('boom'); } };
+      const renderObject = renderASCII as unknown as (params: unknown) => WasmResponse;
+
+      expect(renderObject(params)).toMatchObject({ success: false, error: { type: 'RENDER_ERROR', message: expect.stringContaining('boom') } });
+      expect(callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0 }).success).toBe(true);
+    });
+  });
+
+  describe('presets', () => {
+    it('should save presets and return them all for persistence', () => {
+      const saved = callWasm('savePreset', { name: 'review', options: { format: 'COMPACT', wrapWidth: 100, consoleNaming: true } });
+
+      expect(saved.success).toBe(true);
+      expect(JSON.parse(saved.result ?? '{}')).toEqual({
+        review: { format: 'COMPACT', wrapWidth: 100, consoleNaming: true }
+      });
+    });
+
+    it('should return the options of a saved preset', () => {
+      callWasm('savePreset', { name: 'narrow', options: { wrapWidth: 60, hangingIndent: true } });
+
+      const applied = callWasm('applyPreset', { name: 'narrow' });
+
+      expect(applied.success).toBe(true);
+      const options: RenderPreset = JSON.parse(applied.result ?? '{}');
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', ...options });
+      expect(response.success).toBe(true);
+    });
+
+    it('should delete a preset saved with null options', () => {
+      callWasm('savePreset', { name: 'gone', options: { format: 'TRADITIONAL' } });
+      const deleted = callWasm('savePreset', { name: 'gone', options: null });
+
+      expect(JSON.parse(deleted.result ?? '{}')).not.toHaveProperty('gone');
+      expect(callWasm('applyPreset', { name: 'gone' }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+
+    it('should reject unknown options and input', () => {
+      expect(callWasm('savePreset', { name: 'typo', options: { wrapWidht: 80 } }).error?.type).toBe('INVALID_PARAMETERS');
+      expect(callWasm('savePreset', { name: 'input', options: { input: 'stats: {}' } }).error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('query text header', () => {
+    const queryInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 0
+        metadata:
+          scan_type: TableScan
+          scan_target: Singers
+  queryStats:
+    query_text: "SELECT * FROM Singers WHERE FirstName LIKE @prefix AND Note != '@literal'"
+    query_parameters:
+      prefix: "A%"
+`;
+
+    it('should prepend the query text', () => {
+      const response = callWasm('renderASCII', { input: queryInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, showQueryText: true });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toMatch(/^Query:\nSELECT \* FROM Singers WHERE FirstName LIKE @prefix/);
+    });
+
+    it('should substitute parameters outside string literals', () => {
+      const response = callWasm('renderASCII', { input: queryInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, substituteParameters: true });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain(`LIKE "A%"/*@prefix*/ AND Note != '@literal'`);
+      expect(response.warnings).toBeUndefined();
+    });
+
+    it('should prefer explicit parameters and warn about unbound ones', () => {
+      const input = queryInput.replace('@prefix AND', '@prefix AND LastName = @last AND');
+      const response = callWasm('renderASCII', {
+        input, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0,
+        substituteParameters: true, queryParameters: { prefix: 'B%' }
+      });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('LIKE "B%"/*@prefix*/ AND LastName = @last');
+      expect(response.warnings?.map(w => w.code)).toEqual(['UNBOUND_QUERY_PARAMETER']);
+    });
+
+    it('should warn when the input has no query text', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, showQueryText: true });
+
+      expect(response.success).toBe(true);
+      expect(response.result).not.toContain('Query:');
+      expect(response.warnings?.map(w => w.code)).toEqual(['NO_QUERY_TEXT']);
+    });
+
+    it('should return the query text and parameters from getQueryInfo', () => {
+      const input = queryInput.replace('@prefix AND', '@prefix AND LastName = @last AND').replace('prefix: "A%"', 'prefix: "A%"\n      limit: 10');
+      const response = callWasm('getQueryInfo', { input });
+
+      expect(response.success).toBe(true);
+      const info: QueryInfo = JSON.parse(response.result ?? '{}');
+      expect(info.text).toContain('WHERE FirstName LIKE @prefix AND LastName = @last');
+      expect(info.parameters).toEqual([
+        { name: 'prefix', type: 'STRING', value: 'A%', referenced: true },
+        { name: 'last', referenced: true },
+        { name: 'limit', type: 'INT64', value: 10 },
+      ]);
+    });
+
+    it('should return the optimizer settings of PROFILE captures from getQueryInfo', () => {
+      const input = callWasm('getSample', { name: 'distributed-join' }).result ?? '';
+
+      const info: QueryInfo = JSON.parse(callWasm('getQueryInfo', { input }).result ?? '{}');
+
+      expect(info.optimizerVersion).toBe('7');
+      expect(info.optimizerStatisticsPackage).toBe('auto_20250601_05_12_34UTC');
+      expect(info.parameters).toEqual([]);
+    });
+  });
+
+  describe('annotations', () => {
+    it('should inject annotation lines under the annotated rows', () => {
+      const response = callWasm('renderASCII', {
+        input: scalarAppendixInput, mode: 'PLAN', format: 'TRADITIONAL', wrapWidth: 0,
+        annotations: { 3: 'Hash aggregate\nspills for large groups' }
+      });
+
+      expect(response.success).toBe(true);
+      const lines = (response.result ?? '').split('\n');
+      const row = lines.findIndex(line => /^\|\s*\*?3\s*\|/.test(line));
+      expect(row).toBeGreaterThan(0);
+      expect(lines[row + 1]).toMatch(/^\|\s+\| » Hash aggregate$/);
+      expect(lines[row + 2]).toMatch(/^\|\s+\| » spills for large groups$/);
+      expect(response.warnings).toBeUndefined();
+    });
+
+    it('should warn about annotations without a rendered row', () => {
+      const response = callWasm('renderASCII', {
+        input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0,
+        annotations: { 99: 'missing' }
+      });
+
+      expect(response.success).toBe(true);
+      expect(response.result).not.toContain('missing');
+      expect(response.warnings).toEqual([
+        { code: 'UNKNOWN_ANNOTATION_NODE', message: 'No rendered row for annotated node 99', nodeId: 99 }
+      ]);
+    });
+  });
+
+  describe('explainPlan', () => {
+    it('should describe operators from the leaves up', () => {
+      const response = callWasm('explainPlan', { input: scalarAppendixInput });
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('Step 1: Spanner scans a table.');
+      expect(response.result).toContain('The rows are sorted.');
+    });
+
+    it('should return INVALID_PARAMETERS for an unsupported locale', () => {
+      const response = callWasm('explainPlan', { input: scalarAppendixInput, locale: 'fr' });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('getGlossary', () => {
+    it('should return a single entry by name', () => {
+      const response = callWasm('getGlossary', { name: 'Distributed Union' });
+
+      expect(response.success).toBe(true);
+      const entries: GlossaryEntry[] = JSON.parse(response.result ?? '[]');
+      expect(entries).toHaveLength(1);
+      expect(entries[0]?.category).toBe('distributed');
+      expect(entries[0]?.docUrl).toContain('query-execution-operators');
+    });
+
+    it('should return INVALID_PARAMETERS for an unknown operator', () => {
+      const response = callWasm('getGlossary', { name: 'No Such Operator' });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+    });
+  });
+
+  describe('describeOperator', () => {
+    it('should resolve qualified and console names to the glossary entry', () => {
+      const response = callWasm('describeOperator', { name: 'local distributed union' });
+
+      expect(response.success).toBe(true);
+      const description: OperatorDescription = JSON.parse(response.result ?? '{}');
+      expect(description.name).toBe('Distributed Union');
+      expect(description.qualifier).toBe('local');
+      expect(description.category).toBe('distributed');
+      expect(description.hints?.length).toBeGreaterThan(0);
+      expect(description.related).toContain('Local Split Union');
+    });
+
+    it('should return INVALID_PARAMETERS for an unknown operator', () => {
+      const response = callWasm('describeOperator', { name: 'No Such Operator' });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+      expect(response.error?.message).toContain('No Such Operator');
+    });
+
+    it('should append an explanation of each kind of operator under the table', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', operatorGlossary: true });
+
+      expect(response.success).toBe(true);
+      const glossary = response.result?.slice(response.result.indexOf('Operators:\n')) ?? '';
+      expect(glossary.split('\n').filter(line => line.startsWith('  ')).map(line => line.split(':')[0]?.trim())).toEqual(['Sort', 'Aggregate', 'Scan']);
+    });
+
+    it('should explain only the operators of the rootNodeId subtree', () => {
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'CURRENT', operatorGlossary: true, rootNodeId: 6 });
+
+      expect(response.result).toContain('Operators:\n  Scan: ');
+      expect(response.result).not.toContain('  Sort: ');
+    });
+  });
+
+  describe('Error Response Validation', () => {
+    it('should return PARSE_ERROR for invalid JSON input', () => {
+      const params: RenderParams = {
+        input: 'invalid json content {',
+        mode: 'AUTO',
+        format: 'CURRENT',
+        wrapWidth: 80
+      };
+
+      const resultStr = renderASCII(JSON.stringify(params));
+      const response: WasmResponse = JSON.parse(resultStr);
+
+      expect(response.success).toBe(false);
+      expect(response).not.toHaveProperty('result');
+      expect(response).toHaveProperty('error');
+
+      const error = response.error!;
+      expect(error.type).toBe('PARSE_ERROR');
+      expect(typeof error.message).toBe('string');
+      expect(error.message.length).toBeGreaterThan(0);
+    });
+
+    it('should return INVALID_PARAMETERS for invalid render mode', () => {
+      const invalidParams = {
+        input: `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Test Node"
+        kind: RELATIONAL
+        index: 0
+`,
+        mode: 'INVALID_MODE',
+        format: 'CURRENT',
+        wrapWidth: 80
+      };
+
+      const resultStr = renderASCII(JSON.stringify(invalidParams));
+      const response: WasmResponse = JSON.parse(resultStr);
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+      expect(response.error?.message).toMatch(/render mode/i);
+    });
+
+    it('should return INVALID_PARAMETERS for invalid format type', () => {
+      const invalidParams = {
+        input: `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Test Node"  
+        kind: RELATIONAL
+        index: 0
+`,
+        mode: 'AUTO',
+        format: 'INVALID_FORMAT',
+        wrapWidth: 80
+      };
+
+      const resultStr = renderASCII(JSON.stringify(invalidParams));
+      const response: WasmResponse = JSON.parse(resultStr);
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+      expect(response.error?.message).toMatch(/format/i);
+    });
+
+    it('should return INVALID_SPANNER_FORMAT for missing plan nodes', () => {
+      const params: RenderParams = {
+        input: `
+stats:
+  queryPlan: {}
+`,
+        mode: 'AUTO',
+        format: 'CURRENT',
+        wrapWidth: 80
+      };
+
+      const resultStr = renderASCII(JSON.stringify(params));
+      const response: WasmResponse = JSON.parse(resultStr);
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_SPANNER_FORMAT');
+      expect(response.error?.message).toMatch(/plan nodes|query plan/i);
+      expect(response.error?.details).toBe('stats.queryPlan.planNodes');
+    });
+
+    it('should report the JSON path of a dangling child link', () => {
+      const params: RenderParams = {
+        input: `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 5
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 1
+`,
+        mode: 'AUTO',
+        format: 'CURRENT',
+        wrapWidth: 80
+      };
+
+      const resultStr = renderASCII(JSON.stringify(params));
+      const response: WasmResponse = JSON.parse(resultStr);
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_SPANNER_FORMAT');
+      expect(response.error?.details).toBe('stats.queryPlan.planNodes[0].childLinks[1].childIndex');
+    });
+
+    it('should report paths from the root of the input with its field names', () => {
+      const nodes = [{ index: 0, kind: 'RELATIONAL', displayName: 'Distributed Union', childLinks: [{ childIndex: 5 }] }];
+      const snakeNodes = [{ index: 0, kind: 'RELATIONAL', display_name: 'Distributed Union', child_links: [{ child_index: 5 }] }];
+      const inputs: [string, string][] = [
+        [JSON.stringify({ stats: { queryPlan: { planNodes: nodes } } }), 'stats.queryPlan.planNodes[0].childLinks[0].childIndex'],
+        [JSON.stringify({ queryPlan: { planNodes: nodes } }), 'queryPlan.planNodes[0].childLinks[0].childIndex'],
+        [JSON.stringify({ planNodes: nodes }), 'planNodes[0].childLinks[0].childIndex'],
+        [JSON.stringify({ query_plan: { plan_nodes: snakeNodes } }), 'query_plan.plan_nodes[0].child_links[0].child_index'],
+        [JSON.stringify({ logName: 'projects/p/logs/spanner', jsonPayload: { queryPlan: { planNodes: nodes } } }), 'queryPlan.planNodes[0].childLinks[0].childIndex'],
+        ['queryPlan:\n  planNodes:\n    - index: 0\n      kind: RELATIONAL\n      childLinks:\n        - childIndex: 5\n', 'queryPlan.planNodes[0].childLinks[0].childIndex'],
+        ['planNodes:\n  - index: 0\n    kind: RELATIONAL\n    childLinks:\n      - childIndex: 5\n', 'planNodes[0].childLinks[0].childIndex'],
+        ['plan_nodes {\n  index: 0\n  kind: RELATIONAL\n  child_links { child_index: 5 }\n}\n', 'plan_nodes[0].child_links[0].child_index'],
+      ];
+
+      for (const [input, path] of inputs) {
+        const response = callWasm('renderASCII', { input, mode: 'PLAN', format: 'CURRENT' });
+        expect(response.error?.type).toBe('INVALID_SPANNER_FORMAT');
+        expect(response.error?.details).toBe(path);
+        expect(callWasm('renderASCII', { input, mode: 'PLAN', format: 'CURRENT', recover: true }).warnings?.[0]?.path).toBe(path);
+      }
+      const noNodes = callWasm('renderASCII', { input: JSON.stringify({ queryPlan: {} }), mode: 'PLAN', format: 'CURRENT' });
+      expect(noNodes.error?.details).toBe('queryPlan.planNodes');
+    });
+
+    it('should report every problem in one response', () => {
+      const params: RenderParams = {
+        input: `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 7
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 3
+`,
+        mode: 'INVALID_MODE' as RenderParams['mode'],
+        format: 'CURRENT',
+        wrapWidth: 80
+      };
+
+      const resultStr = renderASCII(JSON.stringify(params));
+      const response: WasmResponse = JSON.parse(resultStr);
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
+      expect(response.error?.issues?.map(issue => issue.type)).toEqual([
+        'INVALID_PARAMETERS',
+        'INVALID_SPANNER_FORMAT',
+        'INVALID_SPANNER_FORMAT',
+      ]);
+      expect(response.error?.issues?.[2]?.details).toBe('stats.queryPlan.planNodes[1].index');
+    });
+  });
+
+  describe('Parameter Type Validation', () => {
+    it('should validate all render modes work correctly', () => {
+      const testInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Test Node"
+        kind: RELATIONAL
+        index: 0
+`;
+
+      const renderModes: Array<RenderParams['mode']> = ['AUTO', 'PLAN', 'PROFILE'];
+      
+      for (const mode of renderModes) {
+        const params: RenderParams = {
+          input: testInput,
+          mode,
+          format: 'CURRENT',
+          wrapWidth: 80
+        };
+
+        const resultStr = renderASCII(JSON.stringify(params));
+        const response: WasmResponse = JSON.parse(resultStr);
+
+        expect(typeof response.success).toBe('boolean');
+        
+        if (!response.success) {
+          // Should not fail due to parameter validation
+          expect(response.error!.type).not.toBe('INVALID_PARAMETERS');
+        }
+      }
+    });
+
+    it('should validate all format types work correctly', () => {
+      const testInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Test Node"
+        kind: RELATIONAL
+        index: 0
+`;
+
+      const formatTypes: Array<RenderParams['format']> = ['CURRENT', 'TRADITIONAL', 'COMPACT'];
+      
+      for (const format of formatTypes) {
+        const params: RenderParams = {
+          input: testInput,
+          mode: 'AUTO',
+          format,
+          wrapWidth: 80
+        };
+
+        const resultStr = renderASCII(JSON.stringify(params));
+        const response: WasmResponse = JSON.parse(resultStr);
+
+        expect(typeof response.success).toBe('boolean');
+        
+        if (!response.success) {
+          // Should not fail due to parameter validation
+          expect(response.error!.type).not.toBe('INVALID_PARAMETERS');
+        }
+      }
+    });
+
+    it('should validate wrap width parameter handling', () => {
+      const testInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Test Node"
+        kind: RELATIONAL
+        index: 0
+`;
+
+      const wrapWidths = [0, 40, 80, 120, 160];
+      
+      for (const wrapWidth of wrapWidths) {
+        const params: RenderParams = {
+          input: testInput,
+          mode: 'AUTO',
+          format: 'CURRENT',
+          wrapWidth
+        };
+
+        const resultStr = renderASCII(JSON.stringify(params));
+        const response: WasmResponse = JSON.parse(resultStr);
+
+        expect(typeof response.success).toBe('boolean');
+        
+        if (!response.success) {
+          // Should not fail due to wrap width parameter
+          expect(response.error!.type).not.toBe('INVALID_PARAMETERS');
+        }
+      }
+    });
+
+    it('should apply hanging indent for wrapped node-local prefixes', () => {
+      const testInput = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Cross Apply"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+          - childIndex: 2
+            type: "Map"
+      - displayName: "Batch Scan"
+        kind: RELATIONAL
+        index: 1
+        metadata:
+          execution_method: "Row"
+      - displayName: "Serialize Result"
+        kind: RELATIONAL
+        index: 2
+`;
+
+      const baseParams: RenderParams = {
+        input: testInput,
+        mode: 'PLAN',
+        format: 'CURRENT',
+        wrapWidth: 21
+      };
+
+      const withoutHanging: WasmResponse = JSON.parse(renderASCII(JSON.stringify({
+        ...baseParams,
+        hangingIndent: false
+      })));
+      const withHanging: WasmResponse = JSON.parse(renderASCII(JSON.stringify({
+        ...baseParams,
+        hangingIndent: true
+      })));
+
+      expect(withoutHanging.success).toBe(true);
+      expect(withHanging.success).toBe(true);
+      expect(withHanging.result).not.toBe(withoutHanging.result);
+      expect(withHanging.result).toContain('[Input] Batch Scan');
+    });
+
+    it('should render selected scalar appendix sections', () => {
+      const params: RenderParams = {
+        input: scalarAppendixInput,
+        mode: 'PLAN',
+        format: 'CURRENT',
+        wrapWidth: 0,
+        printSections: ['ordering', 'aggregate'],
+        resolveScalarVarsRecursive: true
+      };
+
+      const resultStr = renderASCII(JSON.stringify(params));
+      const response: WasmResponse = JSON.parse(resultStr);
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('Ordering(identified by ID):');
+      expect(response.result).toContain('Aggregates(identified by ID):');
+      expect(response.result).toContain('COUNT_FINAL(COUNT()) DESC, SongGenre');
+      expect(response.result).not.toContain('Predicates(identified by ID):');
+    });
+
+    it('should footnote full scalar expressions', () => {
+      const params: RenderParams = {
+        input: scalarAppendixInput,
+        mode: 'PLAN',
+        format: 'CURRENT',
+        wrapWidth: 0,
+        printSections: ['ordering'],
+        scalarRepresentation: 'footnote'
+      };
+
+      const response: WasmResponse = JSON.parse(renderASCII(JSON.stringify(params)));
+
+      expect(response.success).toBe(true);
+      expect(response.result).toContain('Full expressions:');
+      expect(response.result).toContain('[1] COUNT_FINAL(COUNT()) (DESC)');
+    });
+
+    it('should return INVALID_PARAMETERS for an unknown scalar representation', () => {
+      const response = callWasm('renderASCII', {
+        input: scalarAppendixInput,
+        mode: 'PLAN',
+        format: 'CURRENT',
+        wrapWidth: 0,
+        scalarRepresentation: 'long'
+      });
+
+      expect(response.success).toBe(false);
+      expect(response.error?.type).toBe('INVALID_PARAMETERS');
     });
 
     it('should suppress appendices with explicit empty print sections', () => {
@@ -750,6 +5062,73 @@ stats:
     });
   });
 
+  describe('consoleNaming', () => {
+    const input = `
+stats:
+  queryPlan:
+    planNodes:
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 0
+        childLinks:
+          - childIndex: 1
+        metadata:
+          distribution_table: Singers
+          execution_method: Row
+          split_ranges_aligned: "false"
+          subquery_cluster_node: "1"
+      - displayName: "Cross Apply"
+        kind: RELATIONAL
+        index: 1
+        childLinks:
+          - childIndex: 2
+          - childIndex: 3
+            type: "Map"
+        metadata:
+          execution_method: Row
+      - displayName: "Scan"
+        kind: RELATIONAL
+        index: 2
+        metadata:
+          scan_type: TableScan
+          scan_target: Singers
+          execution_method: Row
+          "Full scan": "true"
+      - displayName: "Distributed Union"
+        kind: RELATIONAL
+        index: 3
+        metadata:
+          call_type: Local
+          execution_method: Row
+          seekable_key_size: "0"
+`;
+    const render = (consoleNaming: boolean): WasmResponse => {
+      const params: RenderParams = { input, mode: 'PLAN', format: 'CURRENT', wrapWidth: 0, consoleNaming };
+      return JSON.parse(renderASCII(JSON.stringify(params)));
+    };
+
+    it('should rename operators and labels without changing the titles', () => {
+      const response = render(true);
+
+      expect(response.success).toBe(true);
+      const result = response.result ?? '';
+      expect(result).toMatch(/\| Distributed union on Singers <Row> +\|$/m);
+      expect(result).toMatch(/\| {4}\+- \[Input\] Table Scan on Singers <Row> \(Full scan\) +\|$/m);
+      expect(result).toMatch(/\| {4}\+- \[Map\] Local distributed union <Row> \(Seekable key size: 0\) +\|$/m);
+      expect(result).toContain('+- Cross apply <Row>');
+      expect(result).not.toMatch(/Distribution table|Execution method|Split ranges aligned|Subquery cluster node/);
+    });
+
+    it('should keep the title of every operator', () => {
+      // The title is what spannerplan derives from the metadata: the table and the execution method
+      const titles = (consoleNaming: boolean) =>
+        [...(render(consoleNaming).result ?? '').matchAll(/(?: on \w+)? <\w+>/g)].map(m => m[0]);
+
+      expect(titles(false)).toEqual([' on Singers <Row>', ' <Row>', ' on Singers <Row>', ' <Row>']);
+      expect(titles(true)).toEqual(titles(false));
+    });
+  });
+
   describe('Performance and Edge Cases', () => {
     it('should handle large input without crashing', () => {
       // Generate large but valid query plan
@@ -792,4 +5171,34 @@ ${Array.from({ length: 50 }, (_, i) => `      - displayName: "Node ${i}"
       expect(response.error?.type).toBe('PARSE_ERROR');
     });
   });
+
+  // Runs last: it replaces the module the other tests use
+  describe('shutdown', () => {
+    const startModule = async (): Promise<{ exited: Promise<void> }> => {
+      const go = new Go();
+      const wasmModule = await WebAssembly.instantiate(readFileSync(join(process.cwd(), 'dist', 'rendertree.wasm')), go.importObject);
+      const exited = runGo(go, wasmModule.instance);
+      await new Promise(resolve => setTimeout(resolve, 100));
+      return { exited };
+    };
+    const shutdown = (): WasmResponse => {
+      const fn = (globalThis as Record<string, unknown>).shutdown as () => string;
+      return JSON.parse(fn());
+    };
+
+    it('should remove the exports and let a new instance start', async () => {
+      expect(shutdown().success).toBe(true);
+      expect((globalThis as Record<string, unknown>).renderASCII).toBeUndefined();
+      expect((globalThis as Record<string, unknown>).renderASCIIAsync).toBeUndefined();
+      expect((globalThis as Record<string, unknown>).shutdown).toBeUndefined();
+
+      const { exited } = await startModule();
+      const response = callWasm('renderASCII', { input: scalarAppendixInput, mode: 'PLAN', format: 'COMPACT', wrapWidth: 0 });
+      expect(response.success).toBe(true);
+
+      expect(shutdown().success).toBe(true);
+      await expect(exited).resolves.toBeUndefined();
+      await startModule();
+    });
+  });
 });
@@ -70,11 +70,63 @@ describe('WASM types', () => {
       return `direction: down\nnode0.label: |md **${params.input}** |`;
     };
 
+    const mockResponse = (): string => JSON.stringify({ success: true, result: '' });
+
     const wasmFunctions: WasmFunctions = {
-      renderASCII: mockRenderASCII,
+      renderASCII: mockRenderASCII as WasmFunctions['renderASCII'],
+      renderASCIIAsync: (async (params: string) => mockRenderASCII(params)) as WasmFunctions['renderASCIIAsync'],
       renderMermaid: mockRenderMermaid,
       renderDOT: mockRenderDOT,
       renderD2: mockRenderD2,
+      explainPlan: mockResponse,
+      getGlossary: mockResponse,
+      renderPrototext: mockResponse,
+      registerFormatter: mockResponse,
+      lintPlan: mockResponse,
+      registerLintRule: mockResponse,
+      getUsageStats: mockResponse,
+      setUsageStatsEnabled: mockResponse,
+      anonymizePlan: mockResponse,
+      savePreset: mockResponse,
+      applyPreset: mockResponse,
+      fingerprintPlan: mockResponse,
+      getFanOutReport: mockResponse,
+      parsePlan: mockResponse,
+      renderBatch: mockResponse,
+      selfTest: mockResponse,
+      diffPlans: mockResponse,
+      loadPlan: mockResponse,
+      releasePlan: mockResponse,
+      exportSession: mockResponse,
+      importSession: mockResponse,
+      labelPlan: mockResponse,
+      renderPlan: mockResponse,
+      renderRange: mockResponse,
+      summarizePlan: mockResponse,
+      getCapabilities: mockResponse,
+      getVersionInfo: mockResponse,
+      validateInput: mockResponse,
+      listSamples: mockResponse,
+      getSample: mockResponse,
+      nextChunk: mockResponse,
+      releaseChunks: mockResponse,
+      suggestWhatIf: mockResponse,
+      analyzeCriticalPath: mockResponse,
+      getQueryInfo: mockResponse,
+      renderAsync: () => ({ jobId: 'render-1', response: Promise.resolve(mockResponse()) }),
+      cancelRender: mockResponse,
+      getMemoryStats: mockResponse,
+      freeMemory: mockResponse,
+      getNodeDetail: mockResponse,
+      searchPlan: mockResponse,
+      checkPlanRegression: mockResponse,
+      diffPlanStructure: mockResponse,
+      describeOperator: mockResponse,
+      extractScans: mockResponse,
+      analyzeJoins: mockResponse,
+      logLevel: mockResponse,
+      setLogHandler: mockResponse,
+      shutdown: mockResponse,
     };
 
     expect(typeof wasmFunctions.renderASCII).toBe('function');
@@ -3,16 +3,49 @@
  * - AUTO: Automatically choose between PLAN and PROFILE based on data
  * - PLAN: Show only plan structure without execution statistics  
  * - PROFILE: Show execution statistics and performance data
+ * - TIMELINE: PROFILE with a Gantt-style Timeline column of each operator's
+ *   estimated span within the query (table formats); the spans are returned
+ *   in WasmResponse.timeline
  */
-export type RenderMode = "AUTO" | "PLAN" | "PROFILE";
+export type RenderMode = "AUTO" | "PLAN" | "PROFILE" | "TIMELINE";
 
 /**
  * Output format for rendered query plan
  * - CURRENT: Modern format with improved readability
  * - TRADITIONAL: Classic format for compatibility
  * - COMPACT: Dense format for large plans
+ * - ANSI: CURRENT with ANSI colors for terminals; see colorTheme and noColor
+ * - SPANNER-CLI: TRADITIONAL with the headers and left-aligned stats of
+ *   spanner-cli EXPLAIN ("Query_Execution_Plan (EXPERIMENTAL)") and EXPLAIN
+ *   ANALYZE ("Rows_Returned", "Executions", "Total_Latency"), for comparison
+ *   with captured spanner-cli output; use wrapWidth 0 as spanner-cli does not
+ *   wrap
+ * - DOT: Graphviz DOT graph of the operators with rows and latency in the
+ *   labels; table options such as annotations and columns do not apply
+ * - MERMAID: Mermaid `flowchart TD` of the operators, for Markdown; labels and
+ *   options as for DOT
+ * - PLANTUML: PlantUML `@startwbs` work breakdown structure of the operators,
+ *   which lays out the inputs of the root side by side and suits wide but
+ *   shallow distributed plans; labels and options as for DOT
+ * - HTML: `<table class="rendertree-plan">` with a CSS class per column, and
+ *   per operator row a `data-node-id` attribute and a `node-<ID>` anchor;
+ *   predicates and annotations are in the operator cell
+ * - CSV, TSV: a row per plan node, scalar nodes included, with the columns id,
+ *   parent_id, depth, kind, display_name, title, and short_representation,
+ *   then a metadata_<key> column per metadata key and, with execution stats,
+ *   a stats_<stat>_<field> column per stat field (e.g. stats_latency_total)
+ * - JSONL: JSON Lines with an object per plan node, in the order of CSV, with
+ *   the fields of the CSV columns (parent_id is null for the root), and
+ *   metadata and, with execution stats, stats as nested objects
+ * - TREE: an indented line per operator with its scan target, followed by its
+ *   predicates as "- " lines, without borders or stats; see treeOneLine
+ * - JSON-NORMALIZED: the parsed plan re-serialized as ResultSetStats JSON with
+ *   lowerCamelCase field names, sorted keys, and two-space indents, the same
+ *   for REST JSON, gRPC JSON, and YAML captures of a plan, for storing plans
+ *   in git; only options about the input, such as recover and planIndex,
+ *   apply
  */
-export type FormatType = "CURRENT" | "TRADITIONAL" | "COMPACT";
+export type FormatType = "CURRENT" | "TRADITIONAL" | "COMPACT" | "ANSI" | "SPANNER-CLI" | "DOT" | "MERMAID" | "PLANTUML" | "HTML" | "CSV" | "TSV" | "JSONL" | "TREE" | "JSON-NORMALIZED";
 
 /**
  * Appendix sections that can be printed after the rendered tree table
@@ -47,40 +80,1998 @@ export interface RenderAppendixOptions {
  */
 export type OutputView = "ascii" | "diagram" | "svg" | "d2";
 
+/**
+ * RenderParams with the input as its UTF-8 bytes, or gzip of them, for the
+ * object form of renderASCII. The bytes are copied into WASM memory once,
+ * where a string input is encoded and copied several times; switch to it for
+ * inputs of several megabytes that are at hand as bytes, e.g. from
+ * File.arrayBuffer() or a fetch response, instead of decoding them to a
+ * string first. For strings already in memory, the string form is as fast.
+ */
+export interface RenderBytesParams extends Omit<RenderParams, "input"> {
+  input: Uint8Array;
+}
+
 /**
  * Parameters for WASM renderMermaid/renderDOT functions
  */
-export interface RenderPlanVizParams {
-  /** Query plan text in YAML or JSON format */
+export interface RenderPlanVizParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Enable all diagram detail flags (spannerplanviz --full) */
+  full?: boolean;
+  metadata?: boolean;
+  executionStats?: boolean;
+  executionSummary?: boolean;
+  serializeResult?: boolean;
+  hideScanTarget?: boolean;
+  nonVariableScalar?: boolean;
+  variableScalar?: boolean;
+  /** Use Cloud Console query plan visualizer names for operators and metadata labels */
+  consoleNaming?: boolean;
+  /** Render invalid plan nodes as placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+  /**
+   * Weight each node by its share of the given execution stat, as weightStyle
+   * selects. Uses the simpler Go-side diagram emitters instead of
+   * spannerplanviz; the options above other than consoleNaming and recover
+   * are then ignored with an UNSUPPORTED_OPTION warning.
+   */
+  weightBy?: DiagramWeightBy;
+  /**
+   * How weightBy shows the weights: a heat fill color, a larger font, or
+   * both (default "color"). Requires weightBy.
+   */
+  weightStyle?: DiagramWeightStyle;
+  /**
+   * Label each edge with the rows the child operator returned, showing the
+   * data-flow volume. Uses the Go-side diagram emitters like weightBy.
+   */
+  edgeRows?: boolean;
+  /**
+   * Decimal separator of execution stats spelled with digit grouping, such
+   * as "1.234,5 ms" with ",". Stats are normalized to plain decimals and the
+   * duration units "secs", "msecs", "usecs", and "nsecs" (default "."); plain
+   * decimals such as "18.4" are read as they are with either separator
+   */
+  decimalSeparator?: DecimalSeparator;
+  /**
+   * Convert the duration execution stats to one unit as they are normalized,
+   * so that every format and the JSON APIs show e.g. "1230 msecs" instead of
+   * "1.23 secs" next to "15 msecs"
+   */
+  statDurationUnit?: DurationUnit;
+}
+
+/** Decimal separator of execution stats */
+export type DecimalSeparator = "." | ",";
+
+/** Duration unit of statDurationUnit and NumberFormat.durationUnit */
+export type DurationUnit = "s" | "ms" | "µs" | "us";
+
+/**
+ * Execution stat used to weight diagram nodes
+ */
+export type DiagramWeightBy = "latency" | "rows" | "cpu";
+
+/**
+ * How the weightBy diagram option shows the weights
+ */
+export type DiagramWeightStyle = "color" | "size" | "both";
+
+/** @deprecated Use RenderPlanVizParams */
+export type RenderMermaidParams = RenderPlanVizParams;
+
+/**
+ * Parameters for WASM renderASCII function
+ */
+export interface RenderParams extends RenderAppendixOptions {
+  /**
+   * Query plan text in YAML, JSON, or protobuf text format, or a
+   * base64-encoded binary protobuf. Any of them may be gzipped and
+   * base64-encoded; zstd is not supported
+   */
+  input: string; 
+  /**
+   * "proto-base64" reads input as a base64-encoded binary ResultSetStats,
+   * ResultSet, or QueryPlan; without it, such input is detected
+   */
+  inputEncoding?: "proto-base64";
+  /**
+   * Plan to render of inputs with several, such as a JSON array of result
+   * sets or a batch DML response; the first by default, with a
+   * MULTIPLE_PLANS warning. WasmResponseMetadata.planCount is the number
+   */
+  planIndex?: number;
+  /** Return RenderMetrics in WasmResponse.metrics */
+  includeMetrics?: boolean;
+  /** Rendering mode */
+  mode: RenderMode; 
+  /** Output format: a built-in format or a name registered with registerFormatter */
+  format: FormatType | (string & {}); 
+  /** Text wrapping width (0 = no wrap) */
+  wrapWidth: number;
+  /**
+   * How table formats wrap the Operator column at wrapWidth: mid-token
+   * ("char", the default), at spaces ("word"), or at spaces and after
+   * commas and parentheses ("smart")
+   */
+  wrapMode?: "char" | "word" | "smart";
+  /**
+   * Wrap table formats at the widest wrapWidth whose table fits this width;
+   * an alternative to wrapWidth. Other formats ignore it
+   */
+  targetWidth?: number;
+  /** Whether wrapped lines should align after node-local prefixes such as [Input] or [Map] */
+  hangingIndent?: boolean;
+  /** Use Cloud Console query plan visualizer names for operators and metadata labels */
+  consoleNaming?: boolean;
+  /** Render invalid plan nodes as placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+  /**
+   * Render table formats even when plan nodes fail to render, e.g. for
+   * malformed execution stats: such nodes become `<render error: ...>` rows
+   * and NODE_RENDER_ERROR warnings. Implies recover
+   */
+  lenient?: boolean;
+  /**
+   * Show metadata keys of the table formats as readable labels with the unit
+   * of their values where known, e.g. "Seekable key size (key columns)" for
+   * seekable_key_size. Keys the renderer folds into titles are unchanged.
+   */
+  prettyMetadataKeys?: boolean;
+  /**
+   * How scalar expressions are displayed; defaults to "short"
+   * - short: the short representation from the plan, with $variable references
+   * - full: variable references expanded into the expressions that define them
+   * - footnote: short representation with a [n] marker and the full expression listed after the output
+   */
+  scalarRepresentation?: "short" | "full" | "footnote";
+  /** Prepend the query text from the capture's query stats, if present */
+  showQueryText?: boolean;
+  /**
+   * Prepend the optimizer version and statistics package from the capture's
+   * query stats, which explain why plans differ between environments
+   */
+  showOptimizerInfo?: boolean;
+  /**
+   * Prepend the query text with @parameters replaced by their values, each
+   * followed by a comment naming the parameter. Values come from the
+   * capture's query_parameters stat, overridden by queryParameters.
+   */
+  substituteParameters?: boolean;
+  /** Parameter values for substituteParameters, keyed by name without the @ */
+  queryParameters?: Record<string, string | number | boolean | null | unknown[]>;
+  /**
+   * Comments keyed by node ID, rendered as lines starting with "» " under the
+   * node's row. IDs without a rendered row are reported as
+   * UNKNOWN_ANNOTATION_NODE warnings.
+   */
+  annotations?: Record<number, string>;
+  /**
+   * Order of the flat row listing handed to custom formatters; defaults to
+   * tree pre-order. Built-in tree formats ignore it.
+   * - latency: latency descending, rows without stats last
+   * - rows: returned rows descending, rows without stats last
+   * - id: node ID ascending
+   */
+  sortBy?: RowSortBy;
+  /**
+   * Order of the relational children of each operator, in every format, so
+   * that the most expensive branch comes first; children without the stat go
+   * last. Scalar children and node IDs are unchanged. Default "none", the
+   * plan order
+   * - latency, cpu: total latency or CPU time of the child's subtree descending
+   * - rows: returned rows descending
+   */
+  sortChildrenBy?: "latency" | "rows" | "cpu" | "none";
+  /**
+   * Which child links of each operator are drawn, in every format. Scalar
+   * subqueries are the scalar nodes linked with type "Scalar", which the
+   * table draws as operators. Node IDs are unchanged. Default "all"
+   * - hideScalar: hide scalar subqueries and attach their inputs to the
+   *   operator in their place
+   * - relational: drop scalar subqueries with their inputs
+   */
+  childLinks?: "all" | "hideScalar" | "relational";
+  /** Label the relational edges of the table tree with their child link type and variable, e.g. [Map $v1] */
+  linkLabels?: boolean;
+  /**
+   * Keep only the operators of one category, in table rows and in the row
+   * model of registered formatters:
+   * - scans-only: table, index, and filter scans
+   * - joins-only: joins and applies
+   * - distributed-only: distributed unions and applies
+   * - compute-only: compute, aggregate, sort, filter, and limit operators
+   */
+  operatorFilter?: OperatorFilter;
+  /**
+   * Keep only the operators matching every condition, and their ancestors.
+   * Conditions are separated by spaces or AND, e.g.
+   * `operator~"Scan" metadata.scan_type=TableScan latency>10ms`:
+   * - fields: operator, id, rows, latency, cpu, executions, metadata.<key>
+   *   (spaces in keys written as underscores), stats.<name>
+   * - `~` and `!~` match a case-insensitive regular expression; `=`, `!=`,
+   *   `<`, `<=`, `>`, and `>=` compare numbers, or durations such as 10ms for
+   *   latency and cpu; `=` and `!=` compare operator names and metadata text
+   * - values with spaces are double-quoted
+   * Combined with operatorFilter, rows must pass both.
+   */
+  filter?: string;
+  /**
+   * Target latency such as "50ms" or "1.5s". The target is split evenly across
+   * the relational operators; each operator with latency stats gets an
+   * annotation saying whether its subtree fits its share, and a summary of
+   * the biggest violators follows the output.
+   */
+  latencyBudget?: string;
+  /**
+   * Add an "Est/Actual" column with the estimated rows, actual rows, and their
+   * ratio for operators whose metadata has estimated_rows and whose stats
+   * have rows. Misestimates of thresholds.misestimateRatio (default 10x) or
+   * more are reported as ROW_MISESTIMATE warnings. The comparisons are also
+   * returned in WasmResponse.estimates.
+   */
+  estimateColumn?: boolean;
+  /**
+   * Add a "Latency Share" column with a bar and percentage of each
+   * operator's latency relative to the slowest operator. Plans without
+   * latency stats are unchanged.
+   */
+  latencyBars?: boolean;
+  /**
+   * Characters of the table decorations. Tree connectors are always ASCII
+   * and borders are drawn per borderStyle; "ascii" also replaces latency
+   * bars, annotation markers (»), and ellipses with ASCII characters of the
+   * same width, for terminals and ticketing systems that mangle Unicode.
+   * Default "unicode"
+   */
+  charset?: "unicode" | "ascii";
+  /**
+   * Borders of the table formats: "heavy", "light", and "double" draw them
+   * with box-drawing characters, "minimal" keeps the column separators and
+   * the rule under the header, as in Markdown tables, and "none" separates
+   * the columns with spaces. The lineMap follows the styled lines. Default
+   * the ASCII borders (+, -, |)
+   */
+  borderStyle?: "heavy" | "light" | "double" | "minimal" | "none";
+  /**
+   * Colors of the ANSI format, which renders the CURRENT table with ANSI
+   * escapes for terminals: operator names are colored, hot and critical
+   * operators by the cost options' shares (latency by default) are yellow
+   * and red, and metadata is dimmed. Default "dark"
+   */
+  colorTheme?: "dark" | "light";
+  /**
+   * Render the ANSI format without escapes, as the CURRENT table, e.g. when
+   * the output is not a terminal or NO_COLOR is set
+   */
+  noColor?: boolean;
+  /** Render one line per operator in the TREE format, without predicates */
+  treeOneLine?: boolean;
+  /**
+   * Decimal separator of execution stats spelled with digit grouping, such
+   * as "1.234,5 ms" with ",". Stats are normalized to plain decimals and the
+   * duration units "secs", "msecs", "usecs", and "nsecs" (default "."); plain
+   * decimals such as "18.4" are read as they are with either separator
+   */
+  decimalSeparator?: DecimalSeparator;
+  /**
+   * Convert the duration execution stats to one unit as they are normalized,
+   * so that every format and the JSON APIs show e.g. "1230 msecs" instead of
+   * "1.23 secs" next to "15 msecs"
+   */
+  statDurationUnit?: DurationUnit;
+  /**
+   * Append the variables each operator refers to with the node that defines
+   * them, e.g. "$v2 ← node 3" (table and HTML formats)
+   */
+  variableLinks?: boolean;
+  /**
+   * Break the predicates under the table and in the TREE format at their AND
+   * and OR operators, one condition per line, with nested groups indented
+   */
+  prettyPredicates?: boolean;
+  /**
+   * Append the lintPlan findings under the table (table and HTML formats),
+   * using thresholds. Not available in builds with the nolint tag
+   */
+  lint?: boolean;
+  /** Tune when built-in warnings are reported */
+  thresholds?: Thresholds;
+  /**
+   * Columns of the table formats to show, in this order, such as
+   * ["ID", "Operator", "Rows", "Latency", "CPU"]. Names are matched
+   * case-insensitively and "Latency", "Exec", and "Estimate" are accepted
+   * for "Total Latency", "Exec.", and "Est/Actual"; getCapabilities lists
+   * them. Selecting "Est/Actual", "Latency Share", or "CPU" adds the column.
+   * Unknown names are INVALID_PARAMETERS errors listing every unknown name;
+   * columns the plan does not have, such as stats columns in PLAN mode, are
+   * skipped with COLUMN_UNAVAILABLE warnings. Column groups refer to the
+   * selected columns.
+   */
+  columns?: string[];
+  /**
+   * Columns computed for each operator by Go text/template expressions over
+   * its PlanRow, e.g. "{{.Metadata.scan_type}} {{.Metadata.scan_target}}".
+   * The functions num, add, sub, mul, and div take numbers, numeric strings,
+   * or stats, and ms converts a duration stat to milliseconds, for ratios
+   * such as '{{div .Stats.rows (ms .Stats.latency) | printf "%.1f"}}'.
+   * Invalid templates and titles of built-in columns are INVALID_PARAMETERS
+   * errors; operators a template fails for get an empty cell and a
+   * TEMPLATE_COLUMN_ERROR warning. The columns option can select them by
+   * title.
+   */
+  templateColumns?: TemplateColumn[];
+  /**
+   * Super-headers spanning adjacent columns of the table formats, rendered
+   * as an extra header row. Unknown or non-adjacent columns are
+   * INVALID_PARAMETERS errors.
+   */
+  columnGroups?: ColumnGroup[];
+  /**
+   * Layout of table columns by name or alias, e.g.
+   * { Operator: { truncate: 80 }, Rows: { align: "right" } }. Columns the
+   * table does not have are skipped; unknown names are INVALID_PARAMETERS
+   * errors.
+   */
+  columnConfig?: Record<string, ColumnConfig>;
+  /**
+   * Formatting of the Rows, Exec., Total Latency, and CPU columns of the
+   * table formats. Structured results such as costs and estimates keep the
+   * raw values.
+   */
+  numberFormat?: NumberFormat;
+  /**
+   * Add p50 and p99, when the latency stat has a histogram, or else mean ±
+   * standard deviation to the Total Latency cells of the table formats.
+   * Values are formatted by numberFormat.
+   */
+  latencyDistribution?: boolean;
+  /**
+   * Append an explanation of each kind of operator in the rendered plan, or
+   * in the rootNodeId subtree, under the table: one line per glossary entry
+   * in the order the operators first appear. HTML renders it in a
+   * `glossary` section.
+   */
+  operatorGlossary?: boolean;
+  /**
+   * Append a Total row to the table formats with the sum or max of the rows,
+   * latency, and CPU time of the leaf operators, or of the leaves of the
+   * rootNodeId subtree. Plans without execution stats are rendered without
+   * it, with a NO_EXECUTION_STATS warning
+   */
+  totals?: TotalsOptions;
+  /**
+   * Operators rendered as one row that summarizes their subtree, e.g.
+   * "▶ Distributed Union (+23 nodes, 120 msecs)", for UIs with collapsible
+   * subtrees. The hidden operators are left out of every later step of the
+   * render. IDs that are not operators of the plan are ignored with an
+   * UNKNOWN_COLLAPSED_NODE warning; operators without inputs stay as they are
+   */
+  collapsedNodeIds?: number[];
+  /**
+   * Raw execution stat keys, e.g. "remote_calls" or "filesystem_delay_seconds",
+   * added as columns titled with the key, with the total and unit of the stat.
+   * WasmResponseMetadata.statsFields lists the keys of the plan; keys no
+   * operator has get a COLUMN_UNAVAILABLE warning. The columns and
+   * columnConfig options accept the keys as column names
+   */
+  statsFields?: string[];
+  /**
+   * Add a column for every numeric execution stat of the plan that no other
+   * column shows, after the statsFields columns
+   */
+  experimentalStats?: boolean;
+  /**
+   * Guarantee output that depends only on the input and the options, for
+   * snapshot tests. This is a compatibility contract: within an apiVersion,
+   * the same input and options render byte-identical results, warnings, and
+   * metadata, on every platform and in every locale. Metadata keys are in a
+   * fixed order, and the execution stat columns of table formats are
+   * formatted the same way however the capture spelled them (e.g. "3.50 msecs"
+   * as "3.5 msecs"), as with an empty numberFormat. Options
+   * that depend on timing, includeMetrics and renderLimits.maxMillis, are
+   * INVALID_PARAMETERS
+   */
+  deterministic?: boolean;
+  /**
+   * Bounds on the output. When the full render exceeds them, renderASCII
+   * falls back to deep subtrees collapsed, then the operator tree without
+   * stats, appendices, or added columns, and finally a one-line summary,
+   * reporting the chosen level in degradation and a RENDER_DEGRADED warning.
+   */
+  renderLimits?: RenderLimits;
+  /**
+   * Bounds on the input, checked before it is parsed and rendered; larger
+   * input fails with INPUT_TOO_LARGE instead of hanging the page
+   */
+  inputLimits?: InputLimits;
+  /**
+   * Return outputs larger than this many bytes in chunks, split at line ends
+   * where possible: result is the first chunk, chunks describes it, and
+   * nextChunk returns the rest when the caller is ready to display them.
+   * resultHash is the hash of the whole output. Zero disables chunking.
+   */
+  chunkSize?: number;
+  /**
+   * Return WasmResponse.lineMap, the plan node of each operator row line of
+   * table formats, e.g. to highlight a node across views on hover
+   */
+  lineMap?: boolean;
+  /**
+   * Render only the subtree of this operator, drawn as the root; node IDs
+   * are unchanged. 0, the plan root, renders the whole plan. The DOT and
+   * MERMAID formats, rendered by spannerplanviz, reject it
+   */
+  rootNodeId?: number;
+  /** Prepend the path from the plan root to the rootNodeId operator, e.g. "Path: 0 Distributed Union > 1 Local Distributed Union" */
+  rootBreadcrumb?: boolean;
+  /**
+   * Version of the options the caller was written for (see
+   * Capabilities.apiVersion). Versions this build does not render as
+   * documented fail with UNSUPPORTED_OPTION; omitted means the current one
+   */
+  apiVersion?: number;
+  /**
+   * Add a Cost column (table and HTML formats) of each operator's share of
+   * the self latency or CPU time of all operators, marking hot operators, and
+   * return the shares in WasmResponse.costs, e.g. for a heatmap. PROFILE only
+   */
+  cost?: CostOptions;
+  /**
+   * Return each operator's share of the latency, rows, and CPU time of the
+   * plan in WasmResponse.annotations, for painting a heat-colored tree or
+   * minimap (table, HTML, and custom formats)
+   */
+  heatmap?: boolean;
+}
+
+/** Metric of the cost option */
+export type CostMetric = "latency" | "cpu";
+
+/**
+ * Options of the cost option. Omitted or zero fields use the defaults.
+ */
+export interface CostOptions {
+  /** Share shown in the Cost column and used for markers (default "latency") */
+  metric?: CostMetric;
+  /** Share from which operators are marked "*" (default 0.1) */
+  hot?: number;
+  /** Share from which operators are marked "!!" (default 0.3) */
+  critical?: number;
+}
+
+/** Aggregate of the totals option */
+export type TotalsAggregate = "sum" | "max";
+
+/**
+ * Options of the totals option. Omitted fields use the defaults.
+ */
+export interface TotalsOptions {
+  /** Aggregate of the leaf operator stats (default "sum") */
+  aggregate?: TotalsAggregate;
+  /**
+   * Annotate each operator with more than one leaf under it with the totals
+   * of its subtree, e.g. to compare the branches of a join; also applies to
+   * the HTML format
+   */
+  rollups?: boolean;
+}
+
+/**
+ * Memory use of the Go runtime, returned by getMemoryStats and freeMemory
+ */
+export interface MemoryStats {
+  /** Live and not yet collected heap objects */
+  heapAllocBytes: number;
+  heapInUseBytes: number;
+  /**
+   * Memory obtained by the Go runtime. WebAssembly memory cannot shrink, so
+   * it only grows; freed memory is reused by later renders
+   */
+  sysBytes: number;
+  gcCycles: number;
+  /** Parsed inputs cached for re-renders */
+  parseCacheEntries: number;
+  /** Plans loaded with loadPlan */
+  sessionPlans: number;
+}
+
+/**
+ * Timings and sizes of a render (the includeMetrics option)
+ */
+export interface RenderMetrics {
+  /** Time to extract the plan from the input; near zero when cached */
+  parseMillis: number;
+  /** Time of the rest of the call */
+  renderMillis: number;
+  inputBytes: number;
+  /** Size of the whole output, also for chunked outputs */
+  outputBytes: number;
+  nodeCount: number;
+  /** Go heap in use after the render */
+  heapInUseBytes: number;
+}
+
+/**
+ * Optimizer row estimate of an operator compared with its actual rows (the
+ * estimateColumn option)
+ */
+export interface RowEstimate {
+  nodeId: number;
+  estimatedRows: number;
+  actualRows: number;
+  /** actualRows / estimatedRows, with estimates of zero rows taken as one row */
+  ratio: number;
+  /** Set when ratio is off by thresholds.misestimateRatio or more in either direction */
+  misestimated?: boolean;
+}
+
+/**
+ * Relative cost of an operator (the cost option). Self time excludes the
+ * time of the operator's inputs.
+ */
+export interface NodeCost {
+  nodeId: number;
+  /** Share of the self latency of all operators, from 0 to 1 */
+  latencyShare?: number;
+  /** Share of the self CPU time of all operators, from 0 to 1 */
+  cpuShare?: number;
+  /** "*" for hot and "!!" for critical operators by the chosen metric */
+  marker?: string;
+}
+
+/**
+ * Heat of an operator (the heatmap option). Each share is from 0 to 1 and
+ * the shares of a stat sum to 1; operators without the stat have none.
+ */
+export interface NodeAnnotation {
+  nodeId: number;
+  /** Share of the self latency of all operators */
+  latencyShare?: number;
+  /** Share of the rows returned by all operators */
+  rowsShare?: number;
+  /** Share of the self CPU time of all operators */
+  cpuShare?: number;
+}
+
+/**
+ * Output bounds of the renderLimits option. Omitted or zero fields are
+ * unlimited.
+ */
+export interface RenderLimits {
+  maxBytes?: number;
+  maxLines?: number;
+  /** Time spent looking for output that fits; once exceeded, the summary is returned */
+  maxMillis?: number;
+}
+
+/**
+ * Input bounds of the inputLimits option. Omitted or zero fields use the
+ * defaults of 64 MiB and 100,000 plan nodes.
+ */
+export interface InputLimits {
+  /** Largest input in bytes */
+  maxBytes?: number;
+  /** Largest number of plan nodes */
+  maxNodes?: number;
+}
+
+/**
+ * Table column computed per operator by a Go text/template
+ */
+export interface TemplateColumn {
+  title: string;
+  template: string;
+}
+
+/**
+ * Plan node of a line of a rendered table (the lineMap option)
+ */
+export interface LineMapEntry {
+  /** Index of the line in the result, from 0 */
+  line: number;
+  nodeId: number;
+  /** Set on the lines of a row after its first line: wrapped text and annotations */
+  continuation?: boolean;
+  /** Index of the line within its row, from 0 */
+  wrapIndex: number;
+  /**
+   * Identifies the line across renders of the same plan with other options:
+   * `${nodeId}:${wrapIndex}`, e.g. "12:0". To keep the scroll position when
+   * switching renders, look up the rowId of the top line in the new lineMap,
+   * falling back to the last line of the row when it wraps to fewer lines
+   */
+  rowId: string;
+}
+
+/**
+ * Chunk of an output split by the chunkSize option
+ */
+export interface ChunkInfo {
+  /** Pass to nextChunk for the next chunk; released after the last chunk */
+  handle: string;
+  /** Index of this chunk, from 0 */
+  index: number;
+  count: number;
+  /** Size of the whole output in bytes */
+  totalBytes: number;
+}
+
+/**
+ * Render job started with renderAsync
+ */
+export interface RenderJob {
+  /** Pass to cancelRender to stop the render */
+  jobId: string;
+  /** Resolves with the JSON WasmResponse; a CANCELLED error if cancelled */
+  response: Promise<string>;
+}
+
+/**
+ * Parameters for cancelRender
+ */
+export interface CancelRenderParams {
+  jobId: string;
+}
+
+/**
+ * Parameters for nextChunk and releaseChunks
+ */
+export interface ChunkHandleParams {
+  handle: string;
+}
+
+/**
+ * Level of detail chosen to fit the renderLimits option
+ */
+export type Degradation = "full" | "collapsed" | "tree-only" | "summary";
+
+/**
+ * Super-header spanning adjacent table columns, e.g.
+ * { title: "Execution", columns: ["Rows", "Exec.", "Total Latency"] }
+ */
+export interface ColumnGroup {
+  title: string;
+  /** Headers of the spanned columns, matched case-insensitively */
+  columns: string[];
+}
+
+/**
+ * Bar of an operator in WasmResponse.timeline
+ */
+export interface TimelineEntry {
+  nodeId: number;
+  /**
+   * Estimated start from the start of the query. Spanner reports no start
+   * times, so the inputs of an operator are laid out one after another from
+   * its start, overlapping when they do not fit in it
+   */
+  startMillis: number;
+  durationMillis: number;
+}
+
+/**
+ * Layout of a table column in RenderParams.columnConfig
+ */
+export interface ColumnConfig {
+  /** Cut cells longer than this many characters */
+  maxWidth?: number;
+  /** Cut cells longer than this many characters, ending them in an ellipsis (…). Exclusive with maxWidth */
+  truncate?: number;
+  /**
+   * Alignment of the cells; by default they keep the table's alignment. The
+   * Operator column cannot be aligned
+   */
+  align?: "left" | "right";
+}
+
+/**
+ * Formatting of the execution stat columns in RenderParams.numberFormat
+ */
+export interface NumberFormat {
+  /** Group integer digits with commas, e.g. 123,456,789 */
+  thousandsSeparator?: boolean;
+  /** Abbreviate counts of 1,000 and more with SI prefixes, e.g. 1.2k, 123.5M, 3.4G */
+  siUnits?: boolean;
+  /** Convert latency and CPU time to one unit; by default each keeps its stat's unit */
+  durationUnit?: DurationUnit;
+}
+
+/**
+ * Thresholds behind built-in findings and warnings. The defaults suit
+ * production-scale plans; raise them to quiet small test databases. Unset or
+ * zero fields use the defaults.
+ */
+export interface Thresholds {
+  /** Rows a full scan must return to be reported by the full-scan rule (default 0). Scans without stats are always reported */
+  fullScanMinRows?: number;
+  /** Splits per execution from which the high-fan-out rule reports a distributed operator (default 100) */
+  fanOutLimit?: number;
+  /** Ratio between actual and estimated rows, in either direction, from which an estimate is a misestimate (default 10; must be greater than 1) */
+  misestimateRatio?: number;
+  /** Fraction of scanned rows from which a residual filter is not selective enough for suggestWhatIf to suggest an index (default 0.1; at most 1) */
+  selectiveFilterRatio?: number;
+  /** Input rows from which suggestWhatIf suggests an index for a sort below a limit (default 10000) */
+  largeSortRows?: number;
+  /** Rows a table scan without an index must return to be reported (default 100000) */
+  largeScanRows?: number;
+  /** Input rows from which a cross apply is reported (default 10000) */
+  applyInputRows?: number;
+  /** Build side rows from which a hash join is reported (default 100000) */
+  hashBuildRows?: number;
+  /** Distributed operators from which a plan is reported (default 6) */
+  distributedOperatorLimit?: number;
+}
+
+/**
+ * Order of flat row listings
+ */
+/**
+ * Built-in triage views of the operatorFilter option
+ */
+export type OperatorFilter = "scans-only" | "joins-only" | "distributed-only" | "compute-only";
+
+export type RowSortBy = "latency" | "rows" | "id";
+
+/**
+ * Parameters for selfTest
+ */
+export interface SelfTestParams {
+  /** Run only the named fixtures, e.g. "cyclic-links" */
+  fixtures?: string[];
+}
+
+/**
+ * Fixture and format combination that panicked, failed unexpectedly, or
+ * produced malformed output
+ */
+export interface SelfTestFailure {
+  fixture: string;
+  format: string;
+  message: string;
+}
+
+/**
+ * Result of selfTest; attach it to bug reports
+ */
+export interface SelfTestReport {
+  /** Number of fixture and format combinations run */
+  cases: number;
+  failures: SelfTestFailure[];
+}
+
+/**
+ * Parameters for diffPlans. Operators are aligned by structure and operator
+ * name; each line of the result starts with " ", "+", "-", or "~" and ends
+ * with the before and after node IDs. Each side is given either as input or
+ * as a loadPlan handle; diffs of handles are headed with their labels.
+ */
+export interface DiffPlansParams {
+  /** Plan before the change, e.g. before adding an index */
+  before?: string;
+  /** Plan after the change */
+  after?: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+  /** loadPlan handle of the plan before the change, instead of before */
+  beforeId?: string;
+  /** loadPlan handle of the plan after the change, instead of after */
+  afterId?: string;
+}
+
+/**
+ * Kind of a StructureEdit: "changed" operators are aligned but have another
+ * title, and "moved" operators are in both plans at another place in the tree
+ */
+export type StructureEditKind = "unchanged" | "changed" | "added" | "removed" | "moved";
+
+/**
+ * An execution stat of an operator in the before and after plans. Durations
+ * are in milliseconds.
+ */
+export interface StatDelta {
+  name: "rows" | "latency" | "cpuTime";
+  before?: number;
+  after?: number;
+  /** after - before, when both are set */
+  delta?: number;
+}
+
+/**
+ * An operator of the merged tree of two plans
+ */
+export interface StructureEdit {
+  kind: StructureEditKind;
+  /** Node ID in the before plan; omitted for added operators */
+  beforeId?: number;
+  /** Node ID in the after plan; omitted for removed operators */
+  afterId?: number;
+  /** Parent in the before plan, for moved operators */
+  beforeParentId?: number;
+  /** Parent in the after plan, for moved operators */
+  afterParentId?: number;
+  /** Qualified operator name, of the after plan when the operator is in both */
+  operator: string;
+  /** Operator name in the before plan, when it changed */
+  beforeOperator?: string;
+  scanTarget?: string;
+  /** Scan target in the before plan, when it changed */
+  beforeScanTarget?: string;
+  operatorChanged?: boolean;
+  scanTargetChanged?: boolean;
+  /** Stats either side has */
+  stats?: StatDelta[];
+}
+
+/**
+ * Result of diffPlanStructure, which takes DiffPlansParams. Edits are in
+ * pre-order of the merged tree, keyed by the node IDs of both plans.
+ */
+export interface StructureDiff {
+  edits: StructureEdit[];
+  counts: Record<StructureEditKind, number>;
+}
+
+/**
+ * Parameters for loadPlan
+ */
+export interface LoadPlanParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+  /** renderASCII options to keep with the plan; input is not allowed */
+  options?: Omit<RenderParams, "input">;
+  /** User-assigned tags, e.g. "before index" */
+  labels?: string[];
+}
+
+/**
+ * Plan loaded into the session, without its input
+ */
+export interface SessionPlanInfo {
+  /** Opaque handle, e.g. "plan-1" */
+  id: string;
+  fingerprint: PlanFingerprint;
+  options?: Omit<RenderParams, "input">;
+  labels?: string[];
+}
+
+/**
+ * Parameters for releasePlan
+ */
+export interface ReleasePlanParams {
+  id: string;
+}
+
+/**
+ * Parameters for renderPlan
+ */
+export interface RenderPlanParams {
+  id: string;
+  /** renderASCII options applied over those kept with the plan; input is not allowed */
+  options?: Omit<RenderParams, "input">;
+}
+
+/**
+ * Parameters for labelPlan
+ */
+export interface LabelPlanParams {
+  id: string;
+  /** Labels replacing those of the plan; blank and duplicate labels are dropped */
+  labels: string[];
+}
+
+/**
+ * Parameters for exportSession, which takes none
+ */
+export type ExportSessionParams = Record<string, never>;
+
+/**
+ * Parameters for importSession
+ */
+export interface ImportSessionParams {
+  /** Blob returned by exportSession */
+  blob: string;
+  /**
+   * Add the plans of the blob under new handles, and its presets over those
+   * of the same name, instead of replacing the session
+   */
+  merge?: boolean;
+}
+
+/**
+ * Parameters for renderRange: renderASCII parameters with the operator rows
+ * of a page
+ */
+export interface RenderRangeParams extends RenderParams {
+  /** Index of the first operator row of the page */
+  offset?: number;
+  /** Number of operator rows of the page; 0 or omitted means the rest */
+  limit?: number;
+  /**
+   * Repeat the first lines of the ancestors of the first row above a page
+   * that starts mid-subtree, so that the page can be read on its own
+   */
+  stickyPrefix?: boolean;
+}
+
+/**
+ * Page returned by renderRange
+ */
+export interface RenderRangeResult {
+  text: string;
+  /** Number of operator rows of the whole table */
+  totalRows: number;
+  offset: number;
+  /** Number of operator rows on the page, not counting repeated ancestors */
+  rows: number;
+}
+
+/**
+ * Parameters for renderBatch: renderASCII options applied to every plan.
+ * Pass the plans as inputs, or as concatenated JSON documents in input.
+ */
+export interface RenderBatchParams extends Omit<RenderParams, "input"> {
+  input?: string;
+  inputs?: string[];
+}
+
+/**
+ * Parameters for WASM explainPlan function
+ */
+export interface ExplainPlanParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Narrative language; defaults to "en" */
+  locale?: "en" | "ja";
+}
+
+/**
+ * Condition-like scalar child of an operator, e.g. "Residual Condition"
+ */
+export interface PlanPredicate {
+  type: string;
+  description: string;
+}
+
+/**
+ * Bucket of an execution stat histogram, in the unit of the stat
+ */
+export interface HistogramBucket {
+  lowerBound: number;
+  upperBound: number;
+  count: number;
+  percentage?: number;
+}
+
+/**
+ * Distribution of an execution stat over the executions of an operator, in
+ * the unit of the stat
+ */
+export interface StatDistribution {
+  mean?: number;
+  stdDeviation?: number;
+  histogram?: HistogramBucket[];
+  /** Interpolated from the histogram, if there is one */
+  p50?: number;
+  /** Interpolated from the histogram, if there is one */
+  p99?: number;
+}
+
+/**
+ * Total of one execution stat, e.g. rows or latency, with its distribution
+ * over executions if the plan reports one
+ */
+export interface PlanStat {
+  total: string;
+  unit?: string;
+  /** Total as a number, in milliseconds for durations */
+  value?: number;
+  distribution?: StatDistribution;
+}
+
+/**
+ * One relational operator in the row model, in pre-order
+ */
+export interface PlanRow {
+  id: number;
+  parentId?: number;
+  depth: number;
+  displayName: string;
+  /** Operator title as rendered by spannerplan, including metadata */
+  title: string;
+  metadata?: Record<string, string>;
+  predicates?: PlanPredicate[];
+  stats?: Record<string, PlanStat>;
+  /** Comment from RenderParams.annotations for this node */
+  annotation?: string;
+  /** Number of operators hidden under the row by RenderParams.collapsedNodeIds */
+  collapsed?: number;
+}
+
+/**
+ * Argument passed to a custom formatter callback
+ */
+export interface FormatterModel {
+  rows: PlanRow[];
+}
+
+/**
+ * Custom formatter registered with registerFormatter; returns the rendered text
+ */
+export type FormatterCallback = (model: FormatterModel) => string;
+
+/**
+ * Parameters for lintPlan
+ */
+export interface LintParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+  /** Tune when built-in rules report findings */
+  thresholds?: Thresholds;
+}
+
+/**
+ * Plan problem reported by a lint rule. lintPlan returns a JSON array of
+ * these in WasmResponse.result.
+ */
+export interface LintFinding {
+  /** Rule name followed by the affected node IDs, e.g. "full-scan:3"; stable for the same plan */
+  id: string;
+  /** Name of the built-in or registered rule */
+  rule: string;
+  severity: LintSeverity;
+  message: string;
+  /** Node the finding is reported on */
+  nodeId?: number;
+  /** All affected nodes, starting with nodeId */
+  nodeIds?: number[];
+  /** Documentation explaining the problem and its fixes */
+  docUrl?: string;
+  /** Changes to try, set on suggestWhatIf findings */
+  suggestions?: WhatIfSuggestion[];
+}
+
+/**
+ * Concrete change suggested by suggestWhatIf. Suggestions are derived from
+ * the plan alone, without the schema, so review them before applying.
+ */
+export interface WhatIfSuggestion {
+  kind: WhatIfSuggestionKind;
+  /** DDL statement, or the table reference with the hint, e.g. "Singers@{FORCE_INDEX=SingersByLastName}" */
+  statement: string;
+  rationale: string;
+}
+
+/**
+ * Kind of a what-if suggestion: schema change or query hint
+ */
+export type WhatIfSuggestionKind = "ddl" | "hint";
+
+/**
+ * Severity of a lint finding
+ */
+export type LintSeverity = "info" | "warning" | "error";
+
+/**
+ * Finding returned by a custom lint rule; the rule name and ID are filled in
+ * by Go, severity defaults to "warning", and node-scoped rules default nodeId
+ * to the row being checked
+ */
+export interface CustomLintFinding {
+  message: string;
+  severity?: LintSeverity;
+  nodeId?: number;
+  nodeIds?: number[];
+  docUrl?: string;
+}
+
+/**
+ * Scope of a custom lint rule: called per PlanRow ("node") or once with the
+ * whole FormatterModel ("tree")
+ */
+export type LintRuleScope = "node" | "tree";
+
+/**
+ * Custom lint rule registered with registerLintRule
+ */
+export type LintRuleCallback =
+  | ((row: PlanRow) => CustomLintFinding[] | null | undefined)
+  | ((model: FormatterModel) => CustomLintFinding[] | null | undefined);
+
+/**
+ * Parameters for getUsageStats
+ */
+export interface UsageStatsParams {
+  /** Clear the counters after taking the snapshot */
+  reset?: boolean;
+}
+
+/**
+ * Lowest level of the log records passed to the log handler; "off" drops
+ * every record
+ */
+export type LogLevel = "debug" | "info" | "warn" | "error" | "off";
+
+/**
+ * Parameters for logLevel
+ */
+export interface LogLevelParams {
+  /** Level to set; omit to only read the level in effect ("warn" by default) */
+  level?: LogLevel;
+}
+
+/**
+ * Result of logLevel, returned as JSON in WasmResponse.result
+ */
+export interface LogLevelResult {
+  /** Level in effect after the call */
+  level: LogLevel;
+}
+
+/**
+ * A log record of the renderer, passed to the callback set with setLogHandler
+ */
+export interface LogRecord {
+  /** RFC 3339 UTC time with milliseconds */
+  time: string;
+  level: Exclude<LogLevel, "off">;
+  message: string;
+  /**
+   * Structured fields, e.g. format, mode, inputBytes, and duration (in
+   * milliseconds) of "rendered plan" at debug level, or type and error of
+   * "request failed" at warn level; groups are nested objects
+   */
+  attrs?: Record<string, unknown>;
+}
+
+/**
+ * Callback set with setLogHandler. Records logged while it runs, such as by
+ * exports it calls, are dropped, and exceptions it throws lose the record
+ * without failing the call that logged it.
+ */
+export type LogHandlerCallback = (record: LogRecord) => void;
+
+/**
+ * Usage counters snapshot. getUsageStats returns it as JSON in
+ * WasmResponse.result. Counting is opt-in via setUsageStatsEnabled.
+ */
+export interface UsageStats {
+  enabled: boolean;
+  /** Successful renders keyed by upper-cased format (e.g. TRADITIONAL, MERMAID) */
+  rendersByFormat: Record<string, number>;
+  /** Successful renderASCII calls keyed by render mode */
+  rendersByMode: Record<string, number>;
+  errorsByType: Partial<Record<WasmErrorType, number>>;
+  cacheHits: number;
+  cacheMisses: number;
+  /** cacheHits / (cacheHits + cacheMisses), or 0 before the first lookup */
+  cacheHitRate: number;
+}
+
+/**
+ * Parameters for anonymizePlan
+ */
+export interface AnonymizePlanParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /**
+   * Makes pseudonyms stable across calls and inputs: with the same seed, the
+   * same table or column always maps to the same pseudonym. Defaults to a
+   * per-session random seed.
+   */
+  seed?: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+}
+
+/**
+ * renderASCII options stored in a preset: everything except the input
+ */
+export type RenderPreset = Partial<Omit<RenderParams, "input">>;
+
+/**
+ * Parameters for savePreset
+ */
+export interface SavePresetParams {
+  name: string;
+  /** Options to store, or null to delete the preset */
+  options: RenderPreset | null;
+}
+
+/**
+ * Parameters for applyPreset
+ */
+export interface ApplyPresetParams {
+  name: string;
+}
+
+/**
+ * Parameters for fingerprintPlan
+ */
+export interface FingerprintParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+}
+
+/**
+ * Result of fingerprintPlan. Group history entries by query to find "same
+ * query, different plan" cases.
+ */
+export interface PlanFingerprint {
+  /** Hash of the operator tree, ignoring execution stats and the tables and indexes scanned */
+  plan: string;
+  /** Hash of the operator tree and the table or index each scan reads */
+  planWithTargets: string;
+  /** Hash of normalizedQuery, when the input has query text */
+  query?: string;
+  /** Query text with literals replaced by ?, comments dropped, and whitespace and case normalized */
+  normalizedQuery?: string;
+  /** Operator outline, one operator per line indented by depth, which checkPlanRegression diffs against */
+  shape?: string[];
+}
+
+/**
+ * A known-good plan for checkPlanRegression: a PlanFingerprint, e.g.
+ * exported from CI, with an optional label
+ */
+export interface PlanBaseline extends PlanFingerprint {
+  label?: string;
+}
+
+/**
+ * Parameters for checkPlanRegression
+ */
+export interface RegressionParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Known-good fingerprints to compare the plan with; at least one is required */
+  baselines: PlanBaseline[];
+  /** Match by plan shape alone, so that scans of other tables or indexes are not a regression */
+  ignoreTargets?: boolean;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+}
+
+/**
+ * Structural difference between the closest baseline and the plan
+ */
+export interface ShapeDiff {
+  /** Index of the baseline compared with */
+  baseline: number;
+  label?: string;
+  added: number;
+  removed: number;
+  /** Shape lines marked "+ " for operators only in the plan, "- " for operators only in the baseline, and "  " for both */
+  lines: string[];
+  summary: string;
+}
+
+/**
+ * Result of checkPlanRegression. Baselines of other queries are only
+ * compared with, with a BASELINE_QUERY_MISMATCH warning, when no baseline
+ * is of the query of the input.
+ */
+export interface PlanRegression {
+  matches: boolean;
+  /** Index of the first baseline the plan matches */
+  matchedBaseline?: number;
+  /** The plan has the shape of a baseline but scans other tables or indexes */
+  targetsChanged?: boolean;
+  /** Fingerprint of the input, to store as a new baseline */
+  fingerprint: PlanFingerprint;
+  /** Diff against the closest baseline with a shape, when the plan matches none */
+  diff?: ShapeDiff;
+}
+
+/**
+ * Parameters for getFanOutReport
+ */
+export interface FanOutParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+}
+
+/**
+ * Estimated fan-out of one distributed operator. The numeric fields need
+ * execution stats and are absent for PLAN captures.
+ */
+export interface FanOutPoint {
+  nodeId: number;
+  /** Operator name, e.g. "Distributed Cross Apply" */
+  operator: string;
+  distributionTable?: string;
+  /** How often the operator itself ran */
+  executions?: number;
+  /** Calls to other servers; 0 means every split was local */
+  remoteCalls?: number;
+  /** How often the distributed subquery ran, roughly once per split */
+  subqueryExecutions?: number;
+  /** The larger of remoteCalls and subqueryExecutions */
+  estimatedSplits?: number;
+  /** estimatedSplits / executions */
+  splitsPerExecution?: number;
+}
+
+/**
+ * Result of getFanOutReport
+ */
+export interface FanOutReport {
+  /** Distributed operators in tree pre-order */
+  points: FanOutPoint[];
+  totalRemoteCalls: number;
+  maxSplitsPerExecution: number;
+}
+
+/**
+ * Parameters for extractScans
+ */
+export interface ExtractScansParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+}
+
+/** How a scan reads rows, from its scan_type metadata */
+export type ScanMethod = "table" | "index" | "batch";
+
+/**
+ * One scan operator of a plan. rows and executions need execution stats and
+ * are absent for PLAN captures.
+ */
+export interface ScanUsage {
+  nodeId: number;
+  /** Scanned table or index, or the batch variable of a batch scan */
+  target: string;
+  method: ScanMethod;
+  /** scan_method metadata, e.g. "Row" or "Batch" */
+  scanMethod?: string;
+  fullScan?: boolean;
+  /**
+   * Seek Condition of the Filter Scan above the scan and Split Range of the
+   * nearest distributed operator above it
+   */
+  keyRanges?: PlanPredicate[];
+  /** Rows scanned */
+  rows?: number;
+  executions?: number;
+}
+
+/**
+ * Scans of one table or index
+ */
+export interface TableScanSummary {
+  target: string;
+  method: Exclude<ScanMethod, "batch">;
+  scans: number;
+  fullScans: number;
+  /** Sum of the rows of the scans that report them */
+  rows?: number;
+}
+
+/**
+ * Result of extractScans
+ */
+export interface ScanReport {
+  /** Scan operators in tree pre-order */
+  scans: ScanUsage[];
+  /** Table and index scans by target, sorted by target; batch scans are not summarized */
+  tables: TableScanSummary[];
+}
+
+/**
+ * Parameters for getNodeDetail. Pass the plan as input or as the id of a
+ * loaded plan.
+ */
+export interface NodeDetailParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input?: string;
+  /** Handle returned by loadPlan, instead of input */
+  id?: string;
+  nodeId: number;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+}
+
+/**
+ * Child link of a NodeDetail with the kind and name of the child
+ */
+export interface NodeDetailLink {
+  childId: number;
+  /** Link type such as "Input", "Map", or "Residual Condition" */
+  type?: string;
+  variable?: string;
+  kind: "RELATIONAL" | "SCALAR" | "KIND_UNSPECIFIED";
+  displayName: string;
+}
+
+/**
+ * Structure of a predicate description. Conditions without AND or OR at their
+ * top level are leaves with text; nested operations with the same operator
+ * are flattened.
+ */
+export interface PredicateExpression {
+  /** Operator of the operands; omitted for leaves */
+  op?: "AND" | "OR";
+  operands?: PredicateExpression[];
+  /** Condition as in the description, e.g. "($a > 1)" */
+  text?: string;
+}
+
+/**
+ * Predicate of a NodeDetail
+ */
+export interface NodeDetailPredicate {
+  childId: number;
+  /** Link type such as "Residual Condition" or "Seek Condition" */
+  type: string;
+  description: string;
+  expression: PredicateExpression;
+}
+
+/**
+ * Ancestor of a node in NodeDetail.parentChain
+ */
+export interface NodeDetailAncestor {
+  id: number;
+  displayName: string;
+  /** Operator title (relational nodes) */
+  title?: string;
+}
+
+/**
+ * Result of getNodeDetail
+ */
+export interface NodeDetail {
+  id: number;
+  kind: "RELATIONAL" | "SCALAR" | "KIND_UNSPECIFIED";
+  displayName: string;
+  /** Operator title as rendered by spannerplan (relational nodes) */
+  title?: string;
+  shortRepresentation?: {
+    description: string;
+    /** Subquery variable names mapped to node IDs */
+    subqueries?: Record<string, number>;
+  };
+  metadata?: Record<string, unknown>;
+  executionStats?: Record<string, unknown>;
+  childLinks?: NodeDetailLink[];
+  /** Predicate-like scalar children, such as "Residual Condition", with their parsed structure */
+  predicates?: NodeDetailPredicate[];
+  /** Ancestors from the plan root down to the parent; empty for the root and unreachable nodes */
+  parentChain: NodeDetailAncestor[];
+}
+
+/**
+ * Parameters for searchPlan
+ */
+export interface SearchPlanParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Case-insensitive substring, or pattern with regex */
+  query: string;
+  /** Match query as a case-insensitive regular expression */
+  regex?: boolean;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+}
+
+/**
+ * Operator field that matched the query of searchPlan
+ */
+export interface SearchMatch {
+  nodeId: number;
+  /** "displayName", "scanTarget", or "metadata.<key>" */
+  field: string;
+  value: string;
+}
+
+/**
+ * Result of searchPlan
+ */
+export interface SearchResult {
+  /** In tree pre-order, then in field order */
+  matches: SearchMatch[];
+  /** Distinct operators in matches */
+  nodeIds: number[];
+}
+
+/**
+ * Parameters for analyzeCriticalPath
+ */
+export interface CriticalPathParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+  /** Also render the path as a text table in CriticalPath.text */
+  render?: boolean;
+}
+
+/**
+ * Operator on the critical path
+ */
+export interface CriticalPathHop {
+  nodeId: number;
+  /** Operator title, e.g. "Table Scan (Table: Singers, ...)" */
+  operator: string;
+  /** Latency of the operator, its inputs included */
+  latencyMillis: number;
+  /** latencyMillis minus the latency of the next hop: the time the path spends in this operator */
+  contributionMillis: number;
+  /** contributionMillis over the latency of the root */
+  share: number;
+}
+
+/**
+ * Result of analyzeCriticalPath
+ */
+export interface CriticalPath {
+  /**
+   * Operators from the root to a leaf, following the input with the highest
+   * latency at each operator; empty without latency stats
+   */
+  hops: CriticalPathHop[];
+  latencyMillis: number;
+  /** The path as a text table (only present with render) */
+  text?: string;
+}
+
+/**
+ * Parameters for analyzeJoins
+ */
+export interface AnalyzeJoinsParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+  /** Also render the joins as a text table in JoinReport.text */
+  render?: boolean;
+}
+
+/** Join algorithm of a JoinOperator */
+export type JoinType = "hash" | "apply" | "merge";
+
+/**
+ * One input of a join
+ */
+export interface JoinSide {
+  /** Child link type, or the role of an untyped input: "Build", "Probe", "Input", "Map", "Left", or "Right" */
+  role: string;
+  nodeId: number;
+  /** Operator title of the input */
+  operator: string;
+  /** Rows the input returned over all executions */
+  rows?: number;
+}
+
+/**
+ * Join operator with its inputs. The map side of an apply is its probe side
+ * and the input side its build side; a merge join reports its left input as
+ * the build side.
+ */
+export interface JoinOperator {
+  nodeId: number;
+  /** Operator title, e.g. "Hash Join (join_type: INNER)" */
+  operator: string;
+  type: JoinType;
+  build?: JoinSide;
+  probe?: JoinSide;
+  /** Rows the join returned */
+  outputRows?: number;
+  /**
+   * outputRows per row of the side that drives the join: the probe side of
+   * hash joins, and the build side of applies and merge joins
+   */
+  selectivity?: number;
+}
+
+/**
+ * Result of analyzeJoins
+ */
+export interface JoinReport {
+  /** Join operators in tree pre-order */
+  joins: JoinOperator[];
+  /** The joins as a text table (only present with render) */
+  text?: string;
+}
+
+/**
+ * Parameters for getQueryInfo
+ */
+export interface QueryInfoParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+}
+
+/**
+ * Result of getQueryInfo
+ */
+export interface QueryInfo {
+  /** Query text (only present when the input has one) */
+  text?: string;
+  /** Parameters referenced by the text in order of first use, then other recorded parameters by name */
+  parameters: QueryParameter[];
+  optimizerVersion?: string;
+  optimizerStatisticsPackage?: string;
+}
+
+/**
+ * Query parameter of QueryInfo
+ */
+export interface QueryParameter {
+  name: string;
+  /** GoogleSQL type inferred from the recorded value, e.g. "INT64" or "ARRAY"; absent without a value or for NULL */
+  type?: string;
+  /** Recorded value, from query_parameters of the query stats */
+  value?: unknown;
+  /** Set when the query text uses the parameter */
+  referenced?: boolean;
+}
+
+/**
+ * Parameters for summarizePlan
+ */
+export interface SummarizePlanParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+}
+
+/**
+ * Result of summarizePlan: the size and shape of the plan, to anticipate the
+ * size of a render
+ */
+export interface PlanSummary {
+  counts: PlanCounts;
+  /** Number of levels of the operator tree */
+  depth: number;
+  /** Operators (rows of a table render) at each depth; index 0 is the root */
+  operatorsPerDepth: number[];
+  /** Shallowest depth with the most operators */
+  widestDepth: number;
+  /** Number of operators at widestDepth */
+  widestOperators: number;
+  /** Query-wide totals of a PROFILE capture (only present when the input has query stats) */
+  queryStats?: QueryTotals;
+  /** Operators and their self times per type; types without operators are skipped */
+  operatorTypes: OperatorTypeStats[];
+  /** Mutations of DML plans */
+  dml?: DMLSummary;
+  /** Optimizer settings of a PROFILE capture (only present when the query stats have them) */
+  optimizer?: OptimizerInfo;
+}
+
+/**
+ * Optimizer version and statistics package recorded with a PROFILE capture
+ */
+export interface OptimizerInfo {
+  version?: string;
+  statisticsPackage?: string;
+  /**
+   * When the statistics package was constructed, in RFC 3339, as encoded in
+   * names such as `auto_20250601_05_12_34UTC`
+   */
+  statisticsTimestamp?: string;
+}
+
+/**
+ * Query-wide stats of PlanSummary. Fields missing from the input are omitted.
+ */
+export interface QueryTotals {
+  elapsedMillis?: number;
+  cpuMillis?: number;
+  rowsReturned?: number;
+  rowsScanned?: number;
+  bytesReturned?: number;
+  optimizerVersion?: string;
+  optimizerStatisticsPackage?: string;
+}
+
+/** Operator type of OperatorTypeStats */
+export type OperatorType = "scan" | "join" | "distribution" | "aggregate" | "sort" | "dml" | "other";
+
+/**
+ * Operators of one type in PlanSummary. The times sum self times, which
+ * exclude the time of relational inputs, and need execution stats.
+ */
+export interface OperatorTypeStats {
+  type: OperatorType;
+  operators: number;
+  latencyMillis?: number;
+  cpuMillis?: number;
+}
+
+/**
+ * An accepted value of an enumerated option
+ */
+export interface EnumValue {
+  value: string;
+  description: string;
+}
+
+/**
+ * Kind of a renderASCII format, which decides the options that apply
+ */
+export type FormatKind = "table" | "ansi" | "diagram" | "html" | "flat" | "tree" | "json" | "custom" | "experimental";
+
+/**
+ * An accepted renderASCII format
+ */
+export interface FormatCapability {
+  value: string;
+  description: string;
+  /**
+   * "custom" for formats registered with registerFormatter, and
+   * "experimental" for renderers registered from Go with RegisterRenderer
+   */
+  kind: FormatKind;
+}
+
+/**
+ * A renderASCII option
+ */
+export interface OptionCapability {
+  /** Name of the RenderParams field */
+  name: string;
+  description: string;
+  type: "boolean" | "number" | "string" | "enum" | "enumList" | "object";
+  /** Accepted values of enum and enumList options */
+  values?: EnumValue[];
+  /** Value used when the option is omitted, if not the zero value */
+  default?: string;
+  /** Kinds of the formats the option applies to */
+  formatKinds: FormatKind[];
+}
+
+/**
+ * Result of getCapabilities: what renderASCII accepts in this build
+ */
+export interface Capabilities {
+  modes: EnumValue[];
+  /** Mode to preselect; mode is a required parameter */
+  defaultMode: RenderMode;
+  formats: FormatCapability[];
+  /** Format to preselect; format is a required parameter */
+  defaultFormat: string;
+  options: OptionCapability[];
+  /** Latest apiVersion of the options this build renders */
+  apiVersion: number;
+}
+
+/**
+ * Result of getVersionInfo
+ */
+export interface VersionInfo {
+  /** Module version stamped by the Go toolchain, e.g. a pseudo-version of the commit, or "(devel)" */
+  version: string;
+  /** e.g. "go1.25.0" */
+  goVersion: string;
+  spannerplanVersion?: string;
+  /** VCS revision built from */
+  revision?: string;
+  /** Commit time of revision */
+  revisionTime?: string;
+  /** Whether the working tree had uncommitted changes */
+  modified?: boolean;
+  /** Build time, when the build scripts set it */
+  buildTime?: string;
+  /** Versions of the linked modules, keyed by module path */
+  dependencies: Record<string, string>;
+  /** Optional features compiled in, e.g. "diagram" */
+  features: string[];
+}
+
+/**
+ * Entry of the listSamples result
+ */
+export interface SampleInfo {
+  /** Name to pass to getSample, e.g. "simple-scan" */
+  name: string;
+  title: string;
+  description: string;
+  /** Statement the plan was captured for */
+  query: string;
+  planNodes: number;
+  /** Whether the sample is a profile, to preselect PROFILE mode */
+  hasExecutionStats: boolean;
+}
+
+/**
+ * Parameters for getSample
+ */
+export interface GetSampleParams {
+  /** SampleInfo.name */
+  name: string;
+}
+
+/**
+ * Parameters for validateInput
+ */
+export interface ValidateInputParams {
+  /** Query plan text in YAML, JSON, or protobuf text format, or a base64-encoded binary protobuf, optionally gzipped */
+  input: string;
+  /** See RenderParams.inputEncoding */
+  inputEncoding?: "proto-base64";
+  /** See RenderParams.planIndex */
+  planIndex?: number;
+}
+
+/**
+ * Result of validateInput for valid input
+ */
+export interface InputValidation {
+  planNodes: number;
+  hasExecutionStats: boolean;
+  /** Input format that was understood */
+  detectedFormat?: InputFormat;
+  /** Number of plans of the input; planIndex chooses the one validated */
+  plans: number;
+}
+
+/**
+ * Input format understood by the input sniffer: base64 of a binary
+ * ResultSet, protobuf text, JSON with gRPC (plan_nodes) or REST (planNodes)
+ * field names, or YAML
+ */
+export type InputFormat = "proto-base64" | "prototext" | "json-grpc" | "json-rest" | "yaml";
+
+/**
+ * Parameters for parsePlan
+ */
+export interface ParsePlanParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
+  input: string;
+  /** Use Cloud Console query plan visualizer names for operators and metadata labels */
+  consoleNaming?: boolean;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
+  /** Decimal separator of execution stats spelled with digit grouping (default ".") */
+  decimalSeparator?: DecimalSeparator;
+  /** Convert the duration execution stats to one unit */
+  statDurationUnit?: DurationUnit;
+}
+
+/**
+ * Child link of a ParsedNode
+ */
+export interface ParsedLink {
+  childId: number;
+  /** Link type such as "Input", "Map", or "Residual Condition" */
+  type?: string;
+  variable?: string;
+}
+
+/**
+ * Variable bound by a child link of a plan. Structs and batches such as "v2",
+ * of the fields "v2.Batch", are defined by the operator that builds them.
+ */
+export interface VariableDefinition {
+  variable: string;
+  /** Node the variable is bound to */
+  nodeId: number;
+  /** Operator whose child link binds the variable */
+  operatorId: number;
+}
+
+/**
+ * Reference to a variable in a scalar description or scan target
+ */
+export interface VariableReference {
+  /** Reference as written, e.g. "$v2.Batch" */
+  reference: string;
+  variable: string;
+  /** Node the variable is bound to */
+  definedBy: number;
+}
+
+/**
+ * Plan node with resolved links. Nodes unreachable from the root have no
+ * parentId and depth 0.
+ */
+export interface ParsedNode {
+  id: number;
+  kind: "RELATIONAL" | "SCALAR" | "KIND_UNSPECIFIED";
+  displayName: string;
+  /** Operator title as rendered by spannerplan (relational nodes) */
+  title?: string;
+  /** Short representation (scalar nodes) */
+  description?: string;
+  parentId?: number;
+  depth: number;
+  children?: ParsedLink[];
+  metadata?: Record<string, unknown>;
+  executionStats?: Record<string, unknown>;
+  /** Totals of the execution stats as numbers, with durations in milliseconds whatever their unit */
+  statValues?: Record<string, number>;
+  /** Variables the description or scan target refers to, with the nodes that define them */
+  references?: VariableReference[];
+}
+
+/**
+ * Result of parsePlan
+ */
+export interface ParsedPlan {
+  /** Indexed by node ID; nodes[0] is the root */
+  nodes: ParsedNode[];
+  /** Variables bound by child links, sorted by name; names bound to different expressions are left out */
+  variables: VariableDefinition[];
+  queryStats?: Record<string, unknown>;
+}
+
+/**
+ * Parameters for renderPrototext
+ */
+export interface RenderPrototextParams {
+  /** Query plan text in YAML, JSON, or protobuf text format */
   input: string;
-  /** Enable all diagram detail flags (spannerplanviz --full) */
-  full?: boolean;
-  metadata?: boolean;
-  executionStats?: boolean;
-  executionSummary?: boolean;
-  serializeResult?: boolean;
-  hideScanTarget?: boolean;
-  nonVariableScalar?: boolean;
-  variableScalar?: boolean;
+  /** Replace invalid plan nodes with placeholders and report them as warnings instead of failing */
+  recover?: boolean;
 }
 
-/** @deprecated Use RenderPlanVizParams */
-export type RenderMermaidParams = RenderPlanVizParams;
+/**
+ * Operator categories used by the glossary
+ */
+export type OperatorCategory = "leaf" | "unary" | "binary" | "n-ary" | "distributed" | "scalar";
 
 /**
- * Parameters for WASM renderASCII function
+ * Parameters for getGlossary; omitted filters match every entry
  */
-export interface RenderParams extends RenderAppendixOptions {
-  /** Query plan text in YAML or JSON format */
-  input: string; 
-  /** Rendering mode */
-  mode: RenderMode; 
-  /** Output format */
-  format: FormatType; 
-  /** Text wrapping width (0 = no wrap) */
-  wrapWidth: number; 
-  /** Whether wrapped lines should align after node-local prefixes such as [Input] or [Map] */
-  hangingIndent?: boolean;
+export interface GlossaryParams {
+  /** Operator display name, e.g. "Distributed Union" */
+  name?: string;
+  /** Restrict to one category */
+  category?: OperatorCategory;
+}
+
+/**
+ * Glossary entry for a query plan operator. getGlossary returns a JSON array
+ * of these in WasmResponse.result.
+ */
+export interface GlossaryEntry {
+  name: string;
+  category: OperatorCategory;
+  description: string;
+  /** Link to the Spanner query execution operators reference */
+  docUrl: string;
+}
+
+/**
+ * Parameters for describeOperator
+ */
+export interface DescribeOperatorParams {
+  /**
+   * Operator name: a plan display name, a console name, or a name qualified
+   * by its call, iterator, or scan type such as "Local Distributed Union" or
+   * "Index Scan". Names are matched case-insensitively.
+   */
+  name: string;
+}
+
+/**
+ * Explanation of an operator returned by describeOperator as JSON in
+ * WasmResponse.result
+ */
+export interface OperatorDescription extends GlossaryEntry {
+  /** Words of the name before the glossary name, e.g. "Local" */
+  qualifier?: string;
+  /** What to look for when the operator is slow or unexpected */
+  hints?: string[];
+  /** Glossary names of operators often confused or seen with it */
+  related?: string[];
 }
 
 /**
@@ -95,7 +2086,20 @@ export type WasmErrorType =
   /** General rendering failures */
   | "RENDER_ERROR" 
   /** Invalid function parameters */
-  | "INVALID_PARAMETERS";
+  | "INVALID_PARAMETERS"
+  /** Render job cancelled with cancelRender */
+  | "CANCELLED"
+  /** apiVersion not supported by this build */
+  | "UNSUPPORTED_OPTION"
+  /** Input over the inputLimits; size and limit tell by how much */
+  | "INPUT_TOO_LARGE"
+  /** Too many Promise-returning calls waiting for earlier ones to finish */
+  | "QUEUE_FULL"
+  /**
+   * Synchronous call that changes state other calls use, such as loadPlan or
+   * registerFormatter, made while another call is running
+   */
+  | "BUSY";
 
 /**
  * Structured error response from WASM
@@ -105,8 +2109,87 @@ export interface WasmError {
   type: WasmErrorType;
   /** Human-readable error message */
   message: string;
-  /** Optional additional error details */
+  /**
+   * Optional additional error details. For INVALID_SPANNER_FORMAT this is the
+   * JSON path of the offending element from the root of the input and with
+   * its field names (e.g. `stats.queryPlan.planNodes[12].childLinks[0].childIndex`
+   * for a ResultSet, `planNodes[12]...` for a QueryPlan);
+   * for PARSE_ERROR of the input, the formats that were tried and why each
+   * failed (e.g. `Tried json: ...; yaml: ...`)
+   */
+  details?: string;
+  /** 1-based line of a syntax error in the input, when known */
+  line?: number;
+  /** 1-based column, in characters, of a syntax error in the input, when known */
+  column?: number;
+  /** The input line at `line`, shortened around `column` if long */
+  snippet?: string;
+  /**
+   * Measured size of INPUT_TOO_LARGE input, in bytes or plan nodes by the
+   * limit named in details (`inputLimits.maxBytes` or `inputLimits.maxNodes`)
+   */
+  size?: number;
+  /** The exceeded limit of INPUT_TOO_LARGE errors, in the unit of size */
+  limit?: number;
+  /** Every problem found, present when validation reported more than one */
+  issues?: WasmIssue[];
+  /** Suggested fixes for the error and its issues, if any apply */
+  hints?: WasmErrorHint[];
+}
+
+/**
+ * Code of a WasmErrorHint
+ */
+export type WasmErrorHintCode =
+  /** Query results without a plan; re-run with --query-mode=PROFILE */
+  | "CAPTURE_PROFILE"
+  /** Plan fields spelled in the casing of another source, or mixing two */
+  | "FIELD_CASING"
+  /** YAML indented with tabs */
+  | "TAB_INDENTATION"
+  /** Input over an inputLimits limit */
+  | "RAISE_INPUT_LIMIT";
+
+/**
+ * A suggested fix for an error
+ */
+export interface WasmErrorHint {
+  /** Machine-readable hint code */
+  code: WasmErrorHintCode;
+  /** The suggestion, e.g. how to capture the input again */
+  message: string;
+}
+
+/**
+ * A single problem in a multi-error validation report
+ */
+export interface WasmIssue {
+  /** Error classification type of this problem */
+  type: WasmErrorType;
+  /** Human-readable message */
+  message: string;
+  /** JSON path or other details, if any */
   details?: string;
+  /** 1-based line of a syntax error in the input, when known */
+  line?: number;
+  /** 1-based column of a syntax error in the input, when known */
+  column?: number;
+  /** The input line at `line` */
+  snippet?: string;
+}
+
+/**
+ * Non-fatal problem reported alongside a successful result
+ */
+export interface WasmWarning {
+  /** Machine-readable warning code (e.g. SKIPPED_INVALID_NODE) */
+  code: string;
+  /** Human-readable warning message */
+  message: string;
+  /** Plan node the warning refers to, if any */
+  nodeId?: number;
+  /** JSON path of the offending input element, if any */
+  path?: string;
 }
 
 /**
@@ -118,10 +2201,131 @@ export interface WasmResponse {
   success: boolean;
   /** Rendered ASCII output (only present on success) */
   result?: string;
+  /**
+   * Hash of result, equal for byte-identical results, so that callers can
+   * skip DOM updates when a re-render produced the same output (only present
+   * with a result)
+   */
+  resultHash?: string;
+  /** Non-fatal problems (only present on success) */
+  warnings?: WasmWarning[];
+  /** Facts about the plan (renderASCII and parsePlan, only present on success) */
+  metadata?: WasmResponseMetadata;
+  /** Level of detail chosen to fit renderLimits (only present with renderLimits) */
+  degradation?: Degradation;
+  /** Set when result is a chunk of a larger output (renderASCII with chunkSize, and nextChunk) */
+  chunks?: ChunkInfo;
+  /** Plan node of each operator row line (renderASCII with lineMap and a table format); in the first chunk of chunked outputs */
+  lineMap?: LineMapEntry[];
+  /** Relative cost of each operator with execution stats (renderASCII with cost or the Cost column) */
+  costs?: NodeCost[];
+  /** Estimated and actual rows of each operator that has both (renderASCII with estimateColumn or the Est/Actual column) */
+  estimates?: RowEstimate[];
+  /**
+   * Estimated span of each operator with latency stats, in pre-order, for
+   * drawing a Gantt chart (table formats in the TIMELINE mode or with the
+   * Timeline column)
+   */
+  timeline?: TimelineEntry[];
+  /** Heat of every operator in pre-order (renderASCII with heatmap) */
+  annotations?: NodeAnnotation[];
+  /** Timings and sizes of the render (renderASCII with includeMetrics) */
+  metrics?: RenderMetrics;
   /** Error details (only present on failure) */
   error?: WasmError;
 }
 
+/**
+ * Metadata attached to successful render responses
+ */
+export interface WasmResponseMetadata {
+  counts: PlanCounts;
+  /** Input format that was understood (renderASCII) */
+  detectedFormat?: InputFormat;
+  /** Number of plans of inputs with more than one (renderASCII) */
+  planCount?: number;
+  /** Mutations of DML plans (renderASCII) */
+  dml?: DMLSummary;
+  /** Keys of the numeric execution stats of the plan, for RenderParams.statsFields (renderASCII) */
+  statsFields?: string[];
+}
+
+/**
+ * DML operators of a DML plan and, for PROFILE captures, the rows the
+ * statement modified. Text renders end with a "Rows modified" line when the
+ * count is known.
+ */
+export interface DMLSummary {
+  /** DML operators in pre-order */
+  operations: DMLOperation[];
+  /** Exact number of modified rows */
+  rowsModified?: number;
+  /** Lower bound of the modified rows, reported by partitioned DML */
+  rowsModifiedLowerBound?: number;
+  partitioned?: boolean;
+  /** Executions of the plan root of partitioned DML, one per partition */
+  partitionExecutions?: number;
+}
+
+/**
+ * An operator that applies mutations
+ */
+export interface DMLOperation {
+  nodeId: number;
+  /** Operation type, e.g. "INSERT", "UPDATE", or "DELETE" */
+  operation?: string;
+  table?: string;
+  /** Rows the operator applied mutations for, from its execution stats */
+  mutations?: number;
+  executions?: number;
+}
+
+/**
+ * Lightweight plan statistics for UI badges
+ */
+export interface PlanCounts {
+  totalNodes: number;
+  relationalNodes: number;
+  scalarNodes: number;
+  /** Scan operators without relational inputs */
+  leafScans: number;
+  /** Distributed Union, Distributed Cross Apply, and other distributed operators */
+  distributedOperators: number;
+  hasExecutionStats: boolean;
+}
+
+/**
+ * Error a Promise-returning function rejects with when Go panics. The module
+ * stays usable afterwards.
+ */
+export interface WasmPanicError extends Error {
+  type: 'RENDER_ERROR';
+  /** Go stack trace of the panic */
+  details: string;
+}
+
+/**
+ * Promise-returning variants of the WASM functions, registered on
+ * globalThis with an Async suffix, except for renderAsync, which returns a
+ * Promise already. They run on a goroutine and reject with
+ * a WasmPanicError instead of killing the runtime when Go panics; other
+ * errors still resolve with error responses. Overlapping calls, including
+ * renderAsync jobs, run one at a time in call order; with
+ * 32 calls waiting, further calls resolve with a QUEUE_FULL error response.
+ * Synchronous calls cannot wait, so they run right away, unless they change
+ * state other calls use, such as savePreset, loadPlan, releasePlan,
+ * labelPlan, importSession, freeMemory, registerFormatter,
+ * registerLintRule, and setLogHandler: made while another call is running,
+ * such as from its callbacks or while a renderAsync job yields, those
+ * return a BUSY error response. Synchronous calls that panic return a
+ * RENDER_ERROR response.
+ */
+export type AsyncWasmFunctions = {
+  [K in Exclude<keyof WasmFunctions, 'renderASCIIAsync' | 'renderAsync'> as `${K}Async`]: WasmFunctions[K] extends (...args: infer A) => infer R
+    ? (...args: A) => Promise<R>
+    : never;
+} & Pick<WasmFunctions, 'renderASCIIAsync'>;
+
 /**
  * Interface for WASM functions exposed from Go
  * The renderASCII function now returns structured JSON responses
@@ -129,11 +2333,24 @@ export interface WasmResponse {
  */
 export interface WasmFunctions { 
   /**
-   * Renders Spanner query plan as ASCII tree
+   * Renders Spanner query plan as ASCII tree. Passing RenderParams as an
+   * object returns the WasmResponse as an object, skipping the JSON round
+   * trips that dominate latency for multi-megabyte plans. The object form
+   * also takes the input as bytes (RenderBytesParams).
    * @param paramsJson - JSON string containing RenderParams
    * @returns JSON string containing WasmResponse
    */
-  renderASCII: (paramsJson: string) => string;
+  renderASCII: {
+    (paramsJson: string): string;
+    (params: RenderParams | RenderBytesParams): WasmResponse;
+  };
+  /**
+   * Promise-returning renderASCII (see AsyncWasmFunctions)
+   */
+  renderASCIIAsync: {
+    (paramsJson: string): Promise<string>;
+    (params: RenderParams | RenderBytesParams): Promise<WasmResponse>;
+  };
   /**
    * Renders Spanner query plan as Mermaid.js source
    * @param paramsJson - JSON string containing RenderMermaidParams
@@ -156,4 +2373,346 @@ export interface WasmFunctions {
    * @returns JSON string containing WasmResponse
    */
   renderD2: (paramsJson: string) => string;
+  /**
+   * Describes the plan in prose, one paragraph per operator in execution order
+   * @param paramsJson - JSON string containing ExplainPlanParams
+   * @returns JSON string containing WasmResponse
+   */
+  explainPlan: (paramsJson: string) => string;
+  /**
+   * Returns the operator glossary (name, category, description, doc link)
+   * as a JSON array of GlossaryEntry in the result
+   * @param paramsJson - JSON string containing GlossaryParams
+   * @returns JSON string containing WasmResponse
+   */
+  getGlossary: (paramsJson: string) => string;
+  /**
+   * Re-emits the parsed plan in protobuf text format (a ResultSet when the
+   * input has a row type, otherwise a ResultSetStats)
+   * @param paramsJson - JSON string containing RenderPrototextParams
+   * @returns JSON string containing WasmResponse
+   */
+  renderPrototext: (paramsJson: string) => string;
+  /**
+   * Registers a JS callback as the renderer for a custom renderASCII format name.
+   * Built-in format names cannot be overridden; pass null to unregister.
+   * @param name - Format name (case-insensitive)
+   * @param callback - Receives the FormatterModel and returns the rendered text
+   * @returns JSON string containing WasmResponse
+   */
+  registerFormatter: (name: string, callback: FormatterCallback | null) => string;
+  /**
+   * Runs the built-in and registered lint rules against the plan and returns
+   * a JSON array of LintFinding in the result
+   * @param paramsJson - JSON string containing LintParams
+   * @returns JSON string containing WasmResponse
+   */
+  lintPlan: (paramsJson: string) => string;
+  /**
+   * Registers a JS callback as a custom lint rule whose findings are merged into
+   * lintPlan results. Built-in rule names cannot be overridden; pass null to unregister.
+   * @param name - Rule name reported in LintFinding.rule
+   * @param callback - Receives a PlanRow (node scope) or the FormatterModel (tree scope)
+   * @param scope - "node" (default) or "tree"
+   * @returns JSON string containing WasmResponse
+   */
+  registerLintRule: (name: string, callback: LintRuleCallback | null, scope?: LintRuleScope) => string;
+  /**
+   * Returns the usage counters as a JSON UsageStats in the result
+   * @param paramsJson - JSON string containing UsageStatsParams
+   * @returns JSON string containing WasmResponse
+   */
+  getUsageStats: (paramsJson: string) => string;
+  /**
+   * Turns opt-in usage counting on or off; existing counts are kept
+   * @param enabled - Whether to count renders, errors, and cache lookups
+   * @returns JSON string containing WasmResponse
+   */
+  setUsageStatsEnabled: (enabled: boolean) => string;
+  /**
+   * Replaces table, index, and column names with seeded pseudonyms and masks
+   * string literals. The result is JSON plan input accepted by the renderers.
+   * @param paramsJson - JSON string containing AnonymizePlanParams
+   * @returns JSON string containing WasmResponse
+   */
+  anonymizePlan: (paramsJson: string) => string;
+  /**
+   * Store a named renderASCII option bundle; null options delete it
+   * Result is a JSON object of every preset, for the frontend to persist
+   * @param paramsJson - JSON string containing SavePresetParams
+   * @returns JSON string containing WasmResponse
+   */
+  savePreset: (paramsJson: string) => string;
+  /**
+   * Return the options of a named preset as a JSON object
+   * @param paramsJson - JSON string containing ApplyPresetParams
+   * @returns JSON string containing WasmResponse
+   */
+  applyPreset: (paramsJson: string) => string;
+  /**
+   * Fingerprint the plan shape and the normalized query text
+   * Result is a JSON PlanFingerprint
+   * @param paramsJson - JSON string containing FingerprintParams
+   * @returns JSON string containing WasmResponse
+   */
+  fingerprintPlan: (paramsJson: string) => string;
+  /**
+   * Estimate the splits touched at each distributed operator
+   * Result is a JSON FanOutReport
+   * @param paramsJson - JSON string containing FanOutParams
+   * @returns JSON string containing WasmResponse
+   */
+  getFanOutReport: (paramsJson: string) => string;
+  /**
+   * Parse the plan into structured JSON for interactive views
+   * Result is a JSON ParsedPlan
+   * @param paramsJson - JSON string containing ParsePlanParams
+   * @returns JSON string containing WasmResponse
+   */
+  parsePlan: (paramsJson: string) => string;
+  /**
+   * Render several plans with the same options
+   * Result is a JSON array with one WasmResponse per plan
+   * @param paramsJson - JSON string containing RenderBatchParams
+   * @returns JSON string containing WasmResponse
+   */
+  renderBatch: (paramsJson: string) => string;
+  /**
+   * Renders embedded adversarial fixtures (huge metadata, unusual Unicode, cyclic links)
+   * through every format and returns a SelfTestReport as JSON in the result
+   * @param paramsJson - JSON string containing SelfTestParams
+   * @returns JSON string containing WasmResponse
+   */
+  selfTest: (paramsJson: string) => string;
+  /**
+   * Renders the merged operator tree of two plans, marking added (+), removed (-),
+   * and changed (~) operators with row and latency deltas
+   * @param paramsJson - JSON string containing DiffPlansParams
+   * @returns JSON string containing WasmResponse
+   */
+  diffPlans: (paramsJson: string) => string;
+  /**
+   * Validates a plan and adds it to the session; the result is a SessionPlanInfo
+   * whose id is the plan's handle
+   * @param paramsJson - JSON string containing LoadPlanParams
+   * @returns JSON string containing WasmResponse
+   */
+  loadPlan: (paramsJson: string) => string;
+  /**
+   * Removes a plan from the session and returns the remaining SessionPlanInfo array
+   * @param paramsJson - JSON string containing ReleasePlanParams
+   * @returns JSON string containing WasmResponse
+   */
+  releasePlan: (paramsJson: string) => string;
+  /**
+   * Returns the loaded plans and presets as a compressed, base64-encoded blob in the result
+   * @param paramsJson - JSON string containing ExportSessionParams
+   * @returns JSON string containing WasmResponse
+   */
+  exportSession: (paramsJson: string) => string;
+  /**
+   * Replaces the loaded plans and presets with those of an exportSession blob,
+   * or adds them with merge, and returns the SessionPlanInfo array of the
+   * session; without merge, handles stay valid. Blobs with a plan that does
+   * not parse are rejected whole
+   * @param paramsJson - JSON string containing ImportSessionParams
+   * @returns JSON string containing WasmResponse
+   */
+  importSession: (paramsJson: string) => string;
+  /**
+   * Replaces the labels of a loaded plan, which name it in diffPlans output
+   * and exportSession blobs, and returns its SessionPlanInfo
+   * @param paramsJson - JSON string containing LabelPlanParams
+   * @returns JSON string containing WasmResponse
+   */
+  labelPlan: (paramsJson: string) => string;
+  /**
+   * Renders a loaded plan like renderASCII without parsing its input again;
+   * the options kept with the plan apply first, then those given
+   * @param paramsJson - JSON string containing RenderPlanParams
+   * @returns JSON string containing WasmResponse
+   */
+  renderPlan: (paramsJson: string) => string;
+  /**
+   * Renders a page of the operator rows of the table formats; appendices
+   * follow the last page. The result is a JSON RenderRangeResult
+   * @param paramsJson - JSON string containing RenderRangeParams
+   * @returns JSON string containing WasmResponse
+   */
+  renderRange: (paramsJson: string) => string;
+  /**
+   * Summarize the size and shape of a plan without rendering it, with the
+   * query stats and per-operator-type totals of PROFILE captures
+   * Result is a JSON PlanSummary
+   * @param paramsJson - JSON string containing SummarizePlanParams
+   * @returns JSON string containing WasmResponse
+   */
+  summarizePlan: (paramsJson: string) => string;
+  /**
+   * Lists the modes, formats, and options renderASCII accepts
+   * Result is a JSON Capabilities
+   */
+  getCapabilities: () => string;
+  /**
+   * Returns the module, Go, and dependency versions of this build, for bug
+   * reports. Result is a JSON VersionInfo
+   */
+  getVersionInfo: () => string;
+  /**
+   * Extracts and structurally checks a plan without rendering it, for live
+   * feedback while typing. Result is a JSON InputValidation; errors are those
+   * renderASCII would report
+   * @param paramsJson - JSON string containing ValidateInputParams
+   * @returns JSON string containing WasmResponse
+   */
+  validateInput: (paramsJson: string) => string;
+  /**
+   * Lists the embedded sample plans
+   * Result is a JSON array of SampleInfo
+   */
+  listSamples: () => string;
+  /**
+   * Returns the YAML text of an embedded sample plan, ready to use as input
+   * Unknown names are INVALID_PARAMETERS errors
+   * @param paramsJson - JSON string containing GetSampleParams
+   * @returns JSON string containing WasmResponse
+   */
+  getSample: (paramsJson: string) => string;
+  /**
+   * Returns the next chunk of a renderASCII output split by chunkSize; the
+   * handle is released after the last chunk
+   * @param paramsJson - JSON string containing ChunkHandleParams
+   * @returns JSON string containing WasmResponse
+   */
+  nextChunk: (paramsJson: string) => string;
+  /**
+   * Drops the unread chunks of a chunked renderASCII output
+   * @param paramsJson - JSON string containing ChunkHandleParams
+   * @returns JSON string containing WasmResponse
+   */
+  releaseChunks: (paramsJson: string) => string;
+  /**
+   * Experimental: returns what-if suggestions, such as candidate index DDL and
+   * FORCE_INDEX hints, as a JSON array of LintFinding with suggestions in the result
+   * @param paramsJson - JSON string containing LintParams
+   * @returns JSON string containing WasmResponse
+   */
+  suggestWhatIf: (paramsJson: string) => string;
+  /**
+   * Return the longest-latency path from the plan root to a leaf operator
+   * with each operator's contribution; render adds the path as text
+   * Result is a JSON CriticalPath
+   * @param paramsJson - JSON string containing CriticalPathParams
+   * @returns JSON string containing WasmResponse
+   */
+  analyzeCriticalPath: (paramsJson: string) => string;
+  /**
+   * Return the query text, its parameters with recorded values, and the
+   * optimizer version and statistics package of the plan
+   * Result is a JSON QueryInfo
+   * @param paramsJson - JSON string containing QueryInfoParams
+   * @returns JSON string containing WasmResponse
+   */
+  getQueryInfo: (paramsJson: string) => string;
+  /**
+   * Starts renderASCII as a job that cancelRender can stop. The job yields to
+   * the event loop between the stages of the render (after parsing, after
+   * validation, and around table rendering) and stops at the next one once
+   * cancelled; a stage in progress is not interrupted
+   * @param paramsJson - JSON string containing RenderParams
+   * @returns The RenderJob
+   */
+  renderAsync: (paramsJson: string) => RenderJob;
+  /**
+   * Cancels a renderAsync job; unknown or finished jobs are INVALID_PARAMETERS
+   * @param paramsJson - JSON string containing CancelRenderParams
+   * @returns JSON string containing WasmResponse
+   */
+  cancelRender: (paramsJson: string) => string;
+  /**
+   * Returns the memory use of the Go runtime
+   * Result is a JSON MemoryStats
+   */
+  getMemoryStats: () => string;
+  /**
+   * Clears the parse and table caches and the layouts cached with session
+   * plans, collects garbage, and returns freed memory to the runtime, for
+   * long sessions that render many large plans. Session plans, presets, and
+   * unread chunks are kept. Result is a JSON MemoryStats afterwards
+   */
+  freeMemory: () => string;
+  /**
+   * Returns the metadata, execution stats, child links, and ancestors of one
+   * plan node for a detail panel
+   * @param paramsJson - JSON string containing NodeDetailParams
+   * @returns JSON string containing WasmResponse
+   */
+  getNodeDetail: (paramsJson: string) => string;
+  /**
+   * Finds the operators whose display name, scan target, or metadata values
+   * match a case-insensitive substring or regular expression
+   * @param paramsJson - JSON string containing SearchPlanParams
+   * @returns JSON string containing WasmResponse
+   */
+  searchPlan: (paramsJson: string) => string;
+  /**
+   * Compare the plan with known-good baseline fingerprints
+   * Result is a JSON PlanRegression
+   * @param paramsJson - JSON string containing RegressionParams
+   * @returns JSON string containing WasmResponse
+   */
+  checkPlanRegression: (paramsJson: string) => string;
+  /**
+   * Compare two plans as an edit script keyed by node IDs
+   * Result is a JSON StructureDiff
+   * @param paramsJson - JSON string containing DiffPlansParams
+   * @returns JSON string containing WasmResponse
+   */
+  diffPlanStructure: (paramsJson: string) => string;
+  /**
+   * Explains an operator from the glossary with hints and related operators
+   * as an OperatorDescription in the result; unknown names are INVALID_PARAMETERS
+   * @param paramsJson - JSON string containing DescribeOperatorParams
+   * @returns JSON string containing WasmResponse
+   */
+  describeOperator: (paramsJson: string) => string;
+  /**
+   * Lists the scans of a plan with their targets, key ranges, and rows, and
+   * summarizes them per table and index to check index usage
+   * Result is a JSON ScanReport
+   * @param paramsJson - JSON string containing ExtractScansParams
+   * @returns JSON string containing WasmResponse
+   */
+  extractScans: (paramsJson: string) => string;
+  /**
+   * Lists the joins of a plan with their type, build and probe sides, input
+   * rows, and selectivity
+   * Result is a JSON JoinReport
+   * @param paramsJson - JSON string containing AnalyzeJoinsParams
+   * @returns JSON string containing WasmResponse
+   */
+  analyzeJoins: (paramsJson: string) => string;
+  /**
+   * Sets the lowest level of the log records passed to the log handler, if
+   * given, and returns the level in effect as a JSON LogLevelResult in the result
+   * @param paramsJson - JSON string containing LogLevelParams
+   * @returns JSON string containing WasmResponse
+   */
+  logLevel: (paramsJson: string) => string;
+  /**
+   * Sets a JS callback that receives the structured log records of the Go
+   * side at the levels selected with logLevel; pass null to remove it
+   * @param callback - Receives each LogRecord
+   * @returns JSON string containing WasmResponse
+   */
+  setLogHandler: (callback: LogHandlerCallback | null) => string;
+  /**
+   * Stops the module so that a new one can be instantiated: cancels render
+   * jobs, drops caches, session plans, presets, and registered callbacks,
+   * and removes every export from globalThis. The Go runtime exits on the
+   * next turn of the event loop. Use shutdownWasm in the frontend, which also
+   * forgets the cached instance.
+   * @returns JSON string containing WasmResponse
+   */
+  shutdown: () => string;
 }
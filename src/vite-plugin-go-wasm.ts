@@ -26,7 +26,7 @@ export default function goWasm(): Plugin {
     
     try {
       // Build Go WASM
-      await execAsync('GOOS=js GOARCH=wasm go build -ldflags="-s -w" -o dist/rendertree.wasm ./');
+      await execAsync(`GOOS=js GOARCH=wasm go build -ldflags="-s -w -X main.buildTime=${new Date().toISOString()}" -o dist/rendertree.wasm ./`);
       console.log(`${hookName}: Go WASM built successfully`);
 
       // Copy wasm_exec.js
@@ -1,5 +1,5 @@
 // No need to import wasm_exec.js as it's loaded from GOROOT in index.html
-import type { WasmFunctions, RenderParams, RenderPlanVizParams, RenderMode, FormatType, RenderAppendixOptions, WasmResponse } from './types/wasm';
+import type { WasmFunctions, RenderParams, RenderBytesParams, RenderPlanVizParams, RenderMode, FormatType, RenderAppendixOptions, WasmResponse, FormatterCallback, LintRuleCallback, LintRuleScope, RenderJob, LogHandlerCallback } from './types/wasm';
 import { logger } from './utils/logger';
 import { WasmInitializationError, WasmRenderingError } from './errors/WasmErrors';
 import { extractErrorInfo } from './utils/errorHandling';
@@ -9,12 +9,66 @@ import { extractErrorInfo } from './utils/errorHandling';
 
 // These functions will be globally available after WASM initialization
 declare function renderASCII(paramsJson: string): string;
+declare function renderASCII(params: RenderParams | RenderBytesParams): WasmResponse;
+declare function renderASCIIAsync(paramsJson: string): Promise<string>;
+declare function renderASCIIAsync(params: RenderParams | RenderBytesParams): Promise<WasmResponse>;
 declare function renderMermaid(paramsJson: string): string;
 declare function renderDOT(paramsJson: string): string;
 declare function renderD2(paramsJson: string): string;
+declare function explainPlan(paramsJson: string): string;
+declare function getGlossary(paramsJson: string): string;
+declare function renderPrototext(paramsJson: string): string;
+declare function registerFormatter(name: string, callback: FormatterCallback | null): string;
+declare function lintPlan(paramsJson: string): string;
+declare function registerLintRule(name: string, callback: LintRuleCallback | null, scope?: LintRuleScope): string;
+declare function getUsageStats(paramsJson: string): string;
+declare function setUsageStatsEnabled(enabled: boolean): string;
+declare function anonymizePlan(paramsJson: string): string;
+declare function savePreset(paramsJson: string): string;
+declare function applyPreset(paramsJson: string): string;
+declare function fingerprintPlan(paramsJson: string): string;
+declare function getFanOutReport(paramsJson: string): string;
+declare function parsePlan(paramsJson: string): string;
+declare function renderBatch(paramsJson: string): string;
+declare function selfTest(paramsJson: string): string;
+declare function diffPlans(paramsJson: string): string;
+declare function loadPlan(paramsJson: string): string;
+declare function releasePlan(paramsJson: string): string;
+declare function exportSession(paramsJson: string): string;
+declare function importSession(paramsJson: string): string;
+declare function labelPlan(paramsJson: string): string;
+declare function renderPlan(paramsJson: string): string;
+declare function renderRange(paramsJson: string): string;
+declare function summarizePlan(paramsJson: string): string;
+declare function getCapabilities(): string;
+declare function getVersionInfo(): string;
+declare function validateInput(paramsJson: string): string;
+declare function listSamples(): string;
+declare function getSample(paramsJson: string): string;
+declare function nextChunk(paramsJson: string): string;
+declare function releaseChunks(paramsJson: string): string;
+declare function suggestWhatIf(paramsJson: string): string;
+declare function analyzeCriticalPath(paramsJson: string): string;
+declare function getQueryInfo(paramsJson: string): string;
+declare function renderAsync(paramsJson: string): RenderJob;
+declare function cancelRender(paramsJson: string): string;
+declare function getMemoryStats(): string;
+declare function freeMemory(): string;
+declare function getNodeDetail(paramsJson: string): string;
+declare function searchPlan(paramsJson: string): string;
+declare function checkPlanRegression(paramsJson: string): string;
+declare function diffPlanStructure(paramsJson: string): string;
+declare function describeOperator(paramsJson: string): string;
+declare function extractScans(paramsJson: string): string;
+declare function analyzeJoins(paramsJson: string): string;
+declare function logLevel(paramsJson: string): string;
+declare function setLogHandler(callback: LogHandlerCallback | null): string;
+declare function shutdown(): string;
 
 let cachedWasmFunctions: WasmFunctions | null = null;
 let initPromise: Promise<WasmFunctions> | null = null;
+// Settles when the Go runtime of the current instance exits
+let goExit: Promise<void> | null = null;
 
 /**
  * Wait for Go class to be available (loaded by wasm_exec.js)
@@ -42,7 +96,10 @@ function parseWasmResponse(resultStr: string): string {
     logger.error('Failed to parse WASM response JSON:', parseError instanceof Error ? parseError.message : String(parseError));
     throw new WasmRenderingError('Invalid response format from WASM module');
   }
+  return unwrapWasmResponse(response);
+}
 
+function unwrapWasmResponse(response: WasmResponse): string {
   if (response.success && response.result !== undefined) {
     return response.result;
   }
@@ -64,6 +121,18 @@ function invokeWasm(fn: (paramsJson: string) => string, paramsJson: string): str
   return parseWasmResponse(resultStr);
 }
 
+/**
+ * Like invokeWasm, for Promise-returning functions that take and return
+ * plain objects. Go panics reject with a WasmPanicError.
+ */
+async function invokeWasmAsync<P>(fn: (params: P) => Promise<WasmResponse>, params: P): Promise<string> {
+  const startTime = performance.now();
+  const response = await fn(params);
+  const endTime = performance.now();
+  logger.info(`WASM call completed in ${(endTime - startTime).toFixed(2)}ms`);
+  return unwrapWasmResponse(response);
+}
+
 /**
  * Initialize WebAssembly module
  */
@@ -95,13 +164,47 @@ export function isWasmInitialized(): boolean {
   return cachedWasmFunctions !== null;
 }
 
+/**
+ * Stop the WASM module and forget it, so that the next initWasm
+ * instantiates a fresh one.
+ *
+ * Used by the error boundary's "restart engine" action when the module is in
+ * a bad state. Session plans and presets are dropped; save them with
+ * exportSession first to import them into the new instance.
+ */
+export async function shutdownWasm(): Promise<void> {
+  const pending = initPromise;
+  const exited = goExit;
+  cachedWasmFunctions = null;
+  initPromise = null;
+  goExit = null;
+  if (!pending) {
+    return;
+  }
+
+  logger.info('Shutting down WASM module');
+  try {
+    const wasmFunctions = await pending;
+    const response: WasmResponse = JSON.parse(wasmFunctions.shutdown());
+    if (!response.success) {
+      throw new WasmRenderingError(response.error?.message ?? 'Invalid response structure from WASM module');
+    }
+  } catch (e) {
+    // A module that failed to start or has already exited has nothing to stop
+    logger.warn('WASM shutdown failed:', extractErrorInfo(e).message);
+    return;
+  }
+  await exited;
+  logger.info('WASM module shut down');
+}
+
 async function initializeWasm(): Promise<WasmFunctions> {
   logger.info('Starting WASM initialization');
 
   try {
     await waitForGo();
 
-    const go = new ((globalThis as typeof globalThis & { Go: new () => { importObject: WebAssembly.Imports; run: (instance: WebAssembly.Instance) => Promise<void> } }).Go)();
+    const go = new ((globalThis as typeof globalThis & { Go: new () => { importObject: WebAssembly.Imports; run: (instance: WebAssembly.Instance) => Promise<void>; _scheduledTimeouts: Map<number, ReturnType<typeof setTimeout>> } }).Go)();
 
     const isDevelopment = import.meta.env.DEV;
     const wasmPath = isDevelopment ? './dist/rendertree.wasm' : './assets/rendertree.wasm';
@@ -117,9 +220,13 @@ async function initializeWasm(): Promise<WasmFunctions> {
     }
 
     const result = await WebAssembly.instantiateStreaming(fetchResponse, go.importObject);
-    void go.run(result.instance);
+    // The Go scheduler can leave a timeout behind at exit, which throws
+    // "Go program has already exited" when it fires
+    goExit = go.run(result.instance).then(() => go._scheduledTimeouts.forEach(clearTimeout)).catch((e: unknown) => {
+      logger.error('Go runtime exited with an error:', extractErrorInfo(e).message);
+    });
 
-    cachedWasmFunctions = { renderASCII, renderMermaid, renderDOT, renderD2 };
+    cachedWasmFunctions = { renderASCII, renderASCIIAsync, renderMermaid, renderDOT, renderD2, explainPlan, getGlossary, renderPrototext, registerFormatter, lintPlan, registerLintRule, getUsageStats, setUsageStatsEnabled, anonymizePlan, savePreset, applyPreset, fingerprintPlan, getFanOutReport, parsePlan, renderBatch, selfTest, diffPlans, loadPlan, releasePlan, exportSession, importSession, labelPlan, renderPlan, renderRange, summarizePlan, getCapabilities, getVersionInfo, validateInput, listSamples, getSample, nextChunk, releaseChunks, suggestWhatIf, analyzeCriticalPath, getQueryInfo, renderAsync, cancelRender, getMemoryStats, freeMemory, getNodeDetail, searchPlan, checkPlanRegression, diffPlanStructure, describeOperator, extractScans, analyzeJoins, logLevel, setLogHandler, shutdown };
     logger.info('WASM initialization completed successfully');
     return cachedWasmFunctions;
   } catch (e) {
@@ -154,7 +261,7 @@ export async function renderASCIITree(
       hangingIndent,
       ...appendixOptions,
     };
-    return invokeWasm(wasmFunctions.renderASCII, JSON.stringify(params));
+    return await invokeWasmAsync<RenderParams>(wasmFunctions.renderASCIIAsync, params);
   } catch (e) {
     const { message, originalError } = extractErrorInfo(e);
     logger.error('Error during rendering:', message);
@@ -0,0 +1,37 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// runWASI is the main function of the WASI build, which main_wasip1.go calls
+// with the arguments and standard streams of the process, and returns its
+// exit status. It is built for the host too, where it is tested.
+func runWASI(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	name := "renderASCII"
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	var resp render.Response
+	paramsJSON, err := io.ReadAll(stdin)
+	if err != nil {
+		resp = render.Respond(render.Response{}, render.NewParseError(fmt.Sprintf("Failed to read parameters: %v", err)))
+	} else {
+		resp = render.Invoke(name, string(paramsJSON))
+	}
+	if err := json.NewEncoder(stdout).Encode(resp); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if !resp.Success {
+		return 1
+	}
+	return 0
+}
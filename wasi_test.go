@@ -0,0 +1,89 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/apstndb/rendertree-web/render"
+)
+
+// failingReader fails every read.
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("closed") }
+
+func TestRunWASI(t *testing.T) {
+	input, err := os.ReadFile("render/samples/simple-scan.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderParams, err := json.Marshal(map[string]any{"input": string(input), "mode": "PROFILE", "format": "CURRENT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := render.Render(input, render.Options{Mode: "PROFILE", Format: "CURRENT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantStatus int
+		wantError  string
+		wantResult string
+	}{
+		{"renderASCII by default", nil, string(renderParams), 0, "", want.Result},
+		{"named export", []string{"renderASCII"}, string(renderParams), 0, "", want.Result},
+		{"export without parameters", []string{"getCapabilities"}, "", 0, "", ""},
+		{"invalid parameters", nil, "{", 1, render.ErrorTypeParseError, ""},
+		{"unknown export", []string{"noSuchExport"}, "{}", 1, render.ErrorTypeInvalidParameters, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if status := runWASI(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr); status != tt.wantStatus {
+				t.Errorf("runWASI(%q) = %d, want %d, stderr:\n%s", tt.args, status, tt.wantStatus, stderr.String())
+			}
+			resp := checkWASIResponse(t, stdout.Bytes(), tt.wantError)
+			if tt.wantResult != "" && resp.Result != tt.wantResult {
+				t.Errorf("runWASI(%q) result differs from Render:\n%s", tt.args, resp.Result)
+			}
+		})
+	}
+
+	t.Run("unreadable stdin", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if status := runWASI(nil, failingReader{}, &stdout, &stderr); status != 1 {
+			t.Errorf("runWASI() = %d, want 1", status)
+		}
+		checkWASIResponse(t, stdout.Bytes(), render.ErrorTypeParseError)
+	})
+}
+
+// checkWASIResponse checks that out is one JSON response line of the error
+// type wantError, or a success for "", and returns the response.
+func checkWASIResponse(t *testing.T, out []byte, wantError string) render.Response {
+	t.Helper()
+	if bytes.Count(out, []byte("\n")) != 1 {
+		t.Errorf("output is not one line:\n%s", out)
+	}
+	var resp render.Response
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v\n%s", err, out)
+	}
+	if resp.Success != (wantError == "") {
+		t.Errorf("success = %v, want %v: %+v", resp.Success, wantError == "", resp.Error)
+	}
+	if wantError != "" && (resp.Error == nil || resp.Error.Type != wantError) {
+		t.Errorf("error = %+v, want type %s", resp.Error, wantError)
+	}
+	return resp
+}